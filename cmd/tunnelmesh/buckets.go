@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"crypto/tls"
 	"encoding/xml"
 	"fmt"
@@ -15,6 +16,7 @@ import (
 	"github.com/tunnelmesh/tunnelmesh/internal/auth"
 	"github.com/tunnelmesh/tunnelmesh/internal/config"
 	"github.com/tunnelmesh/tunnelmesh/internal/context"
+	"github.com/tunnelmesh/tunnelmesh/internal/coord/s3"
 )
 
 func newBucketsCmd() *cobra.Command {
@@ -36,7 +38,13 @@ Examples:
   tunnelmesh buckets ls my-bucket
 
   # Delete an empty bucket
-  tunnelmesh buckets delete my-bucket`,
+  tunnelmesh buckets delete my-bucket
+
+  # Share a download link valid for one day
+  tunnelmesh buckets presign my-bucket reports/q3.pdf --expires 24h
+
+  # Expire logs after 30 days
+  tunnelmesh buckets lifecycle set my-bucket --id logs --prefix logs/ --expire-days 30`,
 	}
 
 	// List buckets
@@ -76,9 +84,97 @@ Examples:
 	}
 	bucketsCmd.AddCommand(lsCmd)
 
+	// Presign object URL
+	presignCmd := &cobra.Command{
+		Use:   "presign <bucket-name> <key>",
+		Short: "Create a time-limited URL for an object",
+		Long: `Create a presigned URL that grants GET (download) or PUT (upload) access
+to a single object without sharing your credentials. The URL is signed with
+your S3 credentials, so it grants no more than you can do yourself.`,
+		Args: cobra.ExactArgs(2),
+		RunE: runBucketsPresign,
+	}
+	presignCmd.Flags().StringVar(&presignMethod, "method", "GET", "HTTP method the URL is valid for: GET or PUT")
+	presignCmd.Flags().DurationVar(&presignExpires, "expires", time.Hour, "How long the URL is valid (max 168h)")
+	bucketsCmd.AddCommand(presignCmd)
+
+	bucketsCmd.AddCommand(newBucketsLifecycleCmd())
+
 	return bucketsCmd
 }
 
+func newBucketsLifecycleCmd() *cobra.Command {
+	lifecycleCmd := &cobra.Command{
+		Use:   "lifecycle",
+		Short: "Manage bucket lifecycle rules",
+		Long: `Manage the lifecycle rules that garbage collection applies to a bucket.
+
+Each rule applies to keys under a prefix and can expire current objects,
+expire noncurrent versions, abort incomplete multipart uploads and keep
+deleted objects out of the recycle bin.`,
+	}
+
+	getCmd := &cobra.Command{
+		Use:     "get <bucket-name>",
+		Aliases: []string{"list", "ls"},
+		Short:   "Show a bucket's lifecycle rules",
+		Args:    cobra.ExactArgs(1),
+		RunE:    runBucketsLifecycleGet,
+	}
+	lifecycleCmd.AddCommand(getCmd)
+
+	setCmd := &cobra.Command{
+		Use:   "set <bucket-name>",
+		Short: "Add or replace a lifecycle rule",
+		Long: `Add a lifecycle rule to a bucket, replacing any existing rule with the same ID.
+
+Examples:
+  # Expire objects under logs/ after 30 days
+  tunnelmesh buckets lifecycle set my-bucket --id logs --prefix logs/ --expire-days 30
+
+  # Keep old versions for a week and abort stale uploads after a day
+  tunnelmesh buckets lifecycle set my-bucket --id tidy --noncurrent-days 7 --abort-uploads-days 1
+
+  # Delete scratch files permanently instead of recycling them
+  tunnelmesh buckets lifecycle set my-bucket --id scratch --prefix scratch/ --skip-recycle-bin`,
+		Args: cobra.ExactArgs(1),
+		RunE: runBucketsLifecycleSet,
+	}
+	setCmd.Flags().StringVar(&lifecycleRuleID, "id", "", "Rule ID (required)")
+	setCmd.Flags().StringVar(&lifecyclePrefix, "prefix", "", "Key prefix the rule applies to (default: all keys)")
+	setCmd.Flags().IntVar(&lifecycleExpireDays, "expire-days", 0, "Expire current objects this many days after their last modification")
+	setCmd.Flags().IntVar(&lifecycleNoncurrentDays, "noncurrent-days", 0, "Remove old versions this many days after they were replaced")
+	setCmd.Flags().IntVar(&lifecycleAbortUploadDays, "abort-uploads-days", 0, "Abort multipart uploads this many days after they started")
+	setCmd.Flags().BoolVar(&lifecycleSkipRecycleBin, "skip-recycle-bin", false, "Purge deleted objects instead of keeping them in the recycle bin")
+	setCmd.Flags().BoolVar(&lifecycleDisabled, "disabled", false, "Create the rule disabled")
+	_ = setCmd.MarkFlagRequired("id")
+	lifecycleCmd.AddCommand(setCmd)
+
+	rmCmd := &cobra.Command{
+		Use:     "delete <bucket-name> [rule-id]",
+		Aliases: []string{"rm"},
+		Short:   "Delete one lifecycle rule, or all of them",
+		Args:    cobra.RangeArgs(1, 2),
+		RunE:    runBucketsLifecycleDelete,
+	}
+	lifecycleCmd.AddCommand(rmCmd)
+
+	return lifecycleCmd
+}
+
+var (
+	presignMethod  string
+	presignExpires time.Duration
+
+	lifecycleRuleID          string
+	lifecyclePrefix          string
+	lifecycleExpireDays      int
+	lifecycleNoncurrentDays  int
+	lifecycleAbortUploadDays int
+	lifecycleSkipRecycleBin  bool
+	lifecycleDisabled        bool
+)
+
 // s3Client holds the configuration for making S3 requests.
 type s3Client struct {
 	endpoint  string
@@ -163,9 +259,14 @@ func newS3Client() (*s3Client, error) {
 
 // doRequest makes an authenticated S3 request.
 func (c *s3Client) doRequest(method, path string) (*http.Response, error) {
+	return c.doRequestWithBody(method, path, nil)
+}
+
+// doRequestWithBody makes an authenticated S3 request with a request body.
+func (c *s3Client) doRequestWithBody(method, path string, body []byte) (*http.Response, error) {
 	url := c.endpoint + path
 
-	req, err := http.NewRequest(method, url, nil)
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
@@ -317,6 +418,34 @@ func runBucketsDelete(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runBucketsPresign(cmd *cobra.Command, args []string) error {
+	method := strings.ToUpper(presignMethod)
+	if method != http.MethodGet && method != http.MethodPut {
+		return fmt.Errorf("invalid method %q - must be one of: GET, PUT", presignMethod)
+	}
+
+	client, err := newS3Client()
+	if err != nil {
+		return err
+	}
+
+	presigned, err := s3.PresignURL(s3.PresignOptions{
+		Endpoint:  client.endpoint,
+		Method:    method,
+		Bucket:    args[0],
+		Key:       args[1],
+		AccessKey: client.accessKey,
+		SecretKey: client.secretKey,
+		Expires:   presignExpires,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(presigned)
+	return nil
+}
+
 func runBucketsObjects(cmd *cobra.Command, args []string) error {
 	bucketName := args[0]
 	prefix := ""
@@ -397,3 +526,200 @@ func formatBytes(bytes int64) string {
 	}
 	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
 }
+
+// getLifecycle fetches a bucket's lifecycle configuration. A bucket without
+// one yields an empty configuration.
+func (c *s3Client) getLifecycle(bucketName string) (*s3.LifecycleConfiguration, error) {
+	resp, err := c.doRequest(http.MethodGet, "/"+bucketName+"?lifecycle")
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	var cfg s3.LifecycleConfiguration
+	if resp.StatusCode != http.StatusOK {
+		var errResp errorResponse
+		if xml.Unmarshal(body, &errResp) == nil && errResp.Code == "NoSuchLifecycleConfiguration" {
+			return &cfg, nil
+		}
+		if errResp.Message != "" {
+			return nil, fmt.Errorf("%s: %s", errResp.Code, errResp.Message)
+		}
+		return nil, fmt.Errorf("request failed: %s", resp.Status)
+	}
+
+	if err := xml.Unmarshal(body, &cfg); err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+	return &cfg, nil
+}
+
+// putLifecycle replaces a bucket's lifecycle configuration, or deletes it if
+// there are no rules left.
+func (c *s3Client) putLifecycle(bucketName string, cfg *s3.LifecycleConfiguration) error {
+	var resp *http.Response
+	var err error
+	if len(cfg.Rules) == 0 {
+		resp, err = c.doRequest(http.MethodDelete, "/"+bucketName+"?lifecycle")
+	} else {
+		body, marshalErr := xml.Marshal(cfg)
+		if marshalErr != nil {
+			return fmt.Errorf("marshal lifecycle configuration: %w", marshalErr)
+		}
+		resp, err = c.doRequestWithBody(http.MethodPut, "/"+bucketName+"?lifecycle", body)
+	}
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		var errResp errorResponse
+		if xml.Unmarshal(body, &errResp) == nil && errResp.Message != "" {
+			return fmt.Errorf("%s: %s", errResp.Code, errResp.Message)
+		}
+		return fmt.Errorf("request failed: %s", resp.Status)
+	}
+	return nil
+}
+
+func runBucketsLifecycleGet(cmd *cobra.Command, args []string) error {
+	client, err := newS3Client()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := client.getLifecycle(args[0])
+	if err != nil {
+		return err
+	}
+
+	if len(cfg.Rules) == 0 {
+		fmt.Println("No lifecycle rules configured.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tPREFIX\tSTATUS\tEXPIRE\tNONCURRENT\tABORT UPLOADS\tSKIP RECYCLE BIN")
+	for _, rule := range cfg.Rules {
+		prefix := "*"
+		if rule.Filter != nil && rule.Filter.Prefix != "" {
+			prefix = rule.Filter.Prefix
+		}
+		expire, noncurrent, abort := "-", "-", "-"
+		if rule.Expiration != nil && rule.Expiration.Days > 0 {
+			expire = fmt.Sprintf("%dd", rule.Expiration.Days)
+		}
+		if rule.NoncurrentVersionExpiration != nil {
+			noncurrent = fmt.Sprintf("%dd", rule.NoncurrentVersionExpiration.NoncurrentDays)
+		}
+		if rule.AbortIncompleteMultipartUpload != nil {
+			abort = fmt.Sprintf("%dd", rule.AbortIncompleteMultipartUpload.DaysAfterInitiation)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%t\n", rule.ID, prefix, rule.Status, expire, noncurrent, abort, rule.SkipRecycleBin)
+	}
+	_ = w.Flush()
+
+	return nil
+}
+
+func runBucketsLifecycleSet(cmd *cobra.Command, args []string) error {
+	bucketName := args[0]
+	if lifecycleExpireDays < 0 || lifecycleNoncurrentDays < 0 || lifecycleAbortUploadDays < 0 {
+		return fmt.Errorf("lifecycle days must be positive")
+	}
+	if lifecycleExpireDays == 0 && lifecycleNoncurrentDays == 0 && lifecycleAbortUploadDays == 0 && !lifecycleSkipRecycleBin {
+		return fmt.Errorf("specify at least one of --expire-days, --noncurrent-days, --abort-uploads-days or --skip-recycle-bin")
+	}
+
+	rule := s3.LifecycleConfigurationRule{
+		ID:             lifecycleRuleID,
+		Filter:         &s3.LifecycleFilter{Prefix: lifecyclePrefix},
+		Status:         "Enabled",
+		SkipRecycleBin: lifecycleSkipRecycleBin,
+	}
+	if lifecycleDisabled {
+		rule.Status = "Disabled"
+	}
+	if lifecycleExpireDays > 0 {
+		rule.Expiration = &s3.LifecycleExpiration{Days: lifecycleExpireDays}
+	}
+	if lifecycleNoncurrentDays > 0 {
+		rule.NoncurrentVersionExpiration = &s3.NoncurrentVersionExpiration{NoncurrentDays: lifecycleNoncurrentDays}
+	}
+	if lifecycleAbortUploadDays > 0 {
+		rule.AbortIncompleteMultipartUpload = &s3.AbortIncompleteMultipartUpload{DaysAfterInitiation: lifecycleAbortUploadDays}
+	}
+
+	client, err := newS3Client()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := client.getLifecycle(bucketName)
+	if err != nil {
+		return err
+	}
+	replaced := false
+	for i := range cfg.Rules {
+		if cfg.Rules[i].ID == rule.ID {
+			cfg.Rules[i] = rule
+			replaced = true
+		}
+	}
+	if !replaced {
+		cfg.Rules = append(cfg.Rules, rule)
+	}
+
+	if err := client.putLifecycle(bucketName, cfg); err != nil {
+		return err
+	}
+
+	fmt.Printf("Lifecycle rule '%s' set on bucket '%s'.\n", rule.ID, bucketName)
+	return nil
+}
+
+func runBucketsLifecycleDelete(cmd *cobra.Command, args []string) error {
+	bucketName := args[0]
+
+	client, err := newS3Client()
+	if err != nil {
+		return err
+	}
+
+	cfg := &s3.LifecycleConfiguration{}
+	if len(args) > 1 {
+		cfg, err = client.getLifecycle(bucketName)
+		if err != nil {
+			return err
+		}
+		kept := cfg.Rules[:0]
+		for _, rule := range cfg.Rules {
+			if rule.ID != args[1] {
+				kept = append(kept, rule)
+			}
+		}
+		if len(kept) == len(cfg.Rules) {
+			return fmt.Errorf("lifecycle rule '%s' not found on bucket '%s'", args[1], bucketName)
+		}
+		cfg.Rules = kept
+	}
+
+	if err := client.putLifecycle(bucketName, cfg); err != nil {
+		return err
+	}
+
+	if len(args) > 1 {
+		fmt.Printf("Lifecycle rule '%s' deleted from bucket '%s'.\n", args[1], bucketName)
+	} else {
+		fmt.Printf("Lifecycle rules deleted from bucket '%s'.\n", bucketName)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
+)
+
+func newCaCmd() *cobra.Command {
+	caCmd := &cobra.Command{
+		Use:   "ca",
+		Short: "Manage the mesh certificate authority",
+		Long: `Manage the mesh certificate authority.
+
+Mesh TLS certificates last a year by default (see cert_lifetime in the
+coordinator config, e.g. 24h for short-lived certificates) and peers renew
+them automatically over the relay connection. Revoked certificates are listed in the CRL served at /ca.crl.
+
+Rotating the CA is done in two steps. "rotate" stages a new root, which
+peers start trusting straight away. "promote" makes it the signing root;
+connected peers then renew their certificates with it, and the old root
+stays trusted for twice the certificate lifetime so nothing breaks in the
+meantime. The system trust store is updated with the new root the next time
+each peer joins.
+
+Examples:
+  # Show roots and issued certificates
+  tunnelmesh ca status
+
+  # Rotate the CA
+  tunnelmesh ca rotate
+  tunnelmesh ca promote
+
+  # Revoke a single certificate
+  tunnelmesh ca revoke 5f1e0c3a9b7d2e41`,
+	}
+
+	// Status subcommand
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show CA roots and issued certificates",
+		Args:  cobra.NoArgs,
+		RunE:  runCAStatus,
+	}
+	caCmd.AddCommand(statusCmd)
+
+	// Rotate subcommand
+	rotateCmd := &cobra.Command{
+		Use:   "rotate",
+		Short: "Stage a new CA root",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return runCAStep("/api/ca/rotate", "stage new root")
+		},
+	}
+	caCmd.AddCommand(rotateCmd)
+
+	// Promote subcommand
+	promoteCmd := &cobra.Command{
+		Use:   "promote",
+		Short: "Make the staged root the signing root",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return runCAStep("/api/ca/promote", "promote staged root")
+		},
+	}
+	caCmd.AddCommand(promoteCmd)
+
+	// Revoke subcommand
+	revokeCmd := &cobra.Command{
+		Use:   "revoke <serial>",
+		Short: "Revoke a certificate by serial number",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runCARevoke,
+	}
+	caCmd.AddCommand(revokeCmd)
+
+	return caCmd
+}
+
+func runCAStatus(_ *cobra.Command, _ []string) error {
+	var status proto.CAStatus
+	if err := getAdminJSON("/api/ca", "get CA status", &status); err != nil {
+		return err
+	}
+	printCAStatus(status)
+	return nil
+}
+
+// runCAStep posts a CA rollover step and prints the resulting status.
+func runCAStep(path, what string) error {
+	resp, err := makeAdminRequest("POST", getAdminURL()+path, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to %s: %s", what, string(body))
+	}
+
+	var status proto.CAStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	printCAStatus(status)
+	return nil
+}
+
+func runCARevoke(_ *cobra.Command, args []string) error {
+	body, _ := json.Marshal(map[string]string{"serial": args[0]})
+
+	resp, err := makeAdminRequest("POST", getAdminURL()+"/api/ca/revoke", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to revoke certificate: %s", string(respBody))
+	}
+
+	var cert proto.IssuedCert
+	if err := json.NewDecoder(resp.Body).Decode(&cert); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	fmt.Printf("Certificate %s (%s) revoked\n", cert.Serial, cert.Peer)
+	return nil
+}
+
+// printCAStatus prints the CA roots and issued certificates as tables.
+func printCAStatus(status proto.CAStatus) {
+	fmt.Printf("Certificate lifetime: %s\n\n", status.CertLifetime)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ROOT\tSTATE\tFINGERPRINT\tEXPIRES\tTRUSTED UNTIL")
+	for _, root := range status.Roots {
+		trustedUntil := "-"
+		if !root.TrustedUntil.IsZero() {
+			trustedUntil = root.TrustedUntil.Local().Format(time.RFC3339)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			root.Name, root.State, root.Fingerprint[:16],
+			root.NotAfter.Local().Format("2006-01-02"), trustedUntil)
+	}
+	_ = w.Flush()
+
+	if len(status.Certs) == 0 {
+		fmt.Println("\nNo certificates issued")
+		return
+	}
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "SERIAL\tPEER\tSTATUS\tEXPIRES")
+	for _, cert := range status.Certs {
+		state := "valid"
+		if !cert.RevokedAt.IsZero() {
+			state = "revoked"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", cert.Serial, cert.Peer, state, formatKeyExpiry(cert.NotAfter))
+	}
+	_ = w.Flush()
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
+)
+
+func newDNSCmd() *cobra.Command {
+	dnsCmd := &cobra.Command{
+		Use:   "dns",
+		Short: "Manage custom DNS records",
+		Long: `Manage custom DNS records in the mesh zone.
+
+Besides peer names and aliases, the coordinator serves arbitrary A, AAAA,
+CNAME, SRV and TXT records to every peer's resolver. Names are relative to
+the mesh domain, so grafana.ops resolves as grafana.ops.tunnelmesh.
+
+Examples:
+  # Point a name at a container on some peer
+  tunnelmesh dns add grafana.ops A 10.42.0.5
+
+  # Round-robin a name over three peers
+  tunnelmesh dns add web A 10.42.0.5 10.42.0.6 10.42.0.7
+
+  # Alias a peer, and advertise a service on it
+  tunnelmesh dns add dashboard CNAME grafana.ops
+  tunnelmesh dns add _metrics._tcp.ops SRV "10 5 9090 grafana.ops"
+
+  # List records
+  tunnelmesh dns list
+
+  # Remove one value, one type or a whole name
+  tunnelmesh dns remove web A 10.42.0.7
+  tunnelmesh dns remove web`,
+	}
+
+	// List subcommand
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List custom DNS records",
+		RunE:  runDNSList,
+	}
+	dnsCmd.AddCommand(listCmd)
+
+	// Add subcommand
+	addCmd := &cobra.Command{
+		Use:   "add <name> <type> <value>...",
+		Short: "Add values to a DNS record",
+		Long: `Add values to a DNS record, creating it if needed.
+
+Values are IP addresses for A and AAAA, a target name for CNAME,
+"priority weight port target" for SRV and free text for TXT. Target names
+ending in "." are absolute (example.com.); others are mesh names.`,
+		Args: cobra.MinimumNArgs(3),
+		RunE: runDNSAdd,
+	}
+	addCmd.Flags().Uint32("ttl", 0, "Record TTL in seconds (default: the resolver's TTL)")
+	dnsCmd.AddCommand(addCmd)
+
+	// Remove subcommand
+	removeCmd := &cobra.Command{
+		Use:     "remove <name> [type] [value]",
+		Aliases: []string{"rm"},
+		Short:   "Remove DNS records",
+		Args:    cobra.RangeArgs(1, 3),
+		RunE:    runDNSRemove,
+	}
+	dnsCmd.AddCommand(removeCmd)
+
+	return dnsCmd
+}
+
+func runDNSList(_ *cobra.Command, _ []string) error {
+	resp, err := makeAdminRequest("GET", getAdminURL()+"/api/dns/records", nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to list DNS records: %s", string(body))
+	}
+
+	var records []proto.DNSZoneRecord
+	if err := json.NewDecoder(resp.Body).Decode(&records); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if len(records) == 0 {
+		fmt.Println("No custom DNS records")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NAME\tTYPE\tTTL\tVALUE")
+	for _, rec := range records {
+		ttl := "-"
+		if rec.TTL > 0 {
+			ttl = fmt.Sprintf("%d", rec.TTL)
+		}
+		for _, v := range rec.Values {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", rec.Name, rec.Type, ttl, v)
+		}
+	}
+	_ = w.Flush()
+
+	return nil
+}
+
+func runDNSAdd(cmd *cobra.Command, args []string) error {
+	ttl, _ := cmd.Flags().GetUint32("ttl")
+	rec := proto.DNSZoneRecord{
+		Name:   args[0],
+		Type:   strings.ToUpper(args[1]),
+		Values: args[2:],
+		TTL:    ttl,
+	}
+	body, _ := json.Marshal(rec)
+
+	resp, err := makeAdminRequest("POST", getAdminURL()+"/api/dns/records", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusCreated {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to add DNS record: %s", string(respBody))
+	}
+
+	fmt.Printf("DNS record %s %s added\n", rec.Name, rec.Type)
+	return nil
+}
+
+func runDNSRemove(_ *cobra.Command, args []string) error {
+	target := getAdminURL() + "/api/dns/records/" + url.PathEscape(args[0])
+	query := url.Values{}
+	if len(args) > 1 {
+		query.Set("type", strings.ToUpper(args[1]))
+	}
+	if len(args) > 2 {
+		query.Set("value", args[2])
+	}
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	resp, err := makeAdminRequest("DELETE", target, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to remove DNS record: %s", string(body))
+	}
+
+	fmt.Printf("DNS record %s removed\n", strings.Join(args, " "))
+	return nil
+}
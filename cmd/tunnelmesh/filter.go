@@ -8,7 +8,10 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/tunnelmesh/tunnelmesh/internal/config"
 	"github.com/tunnelmesh/tunnelmesh/internal/control"
+	"github.com/tunnelmesh/tunnelmesh/internal/routing"
+	"github.com/tunnelmesh/tunnelmesh/internal/tunnel"
 )
 
 func newFilterCmd() *cobra.Command {
@@ -18,6 +21,9 @@ func newFilterCmd() *cobra.Command {
 		Long: `Manage packet filter rules for incoming traffic.
 
 The packet filter controls which ports are accessible on this peer.
+Rules match a port or port range for TCP/UDP, or a message type for
+ICMP, and can be narrowed to a source peer, a source CIDR, the RBAC
+group of the source peer, or an RBAC group this peer must be in.
 Rules can be added temporarily via CLI (persisted when S3 is enabled on
 the coordinator) or permanently via the config file.
 
@@ -26,12 +32,18 @@ Rule precedence (most restrictive wins):
   2. Peer config (local rules)
   3. Temporary rules (CLI/admin panel)
 
-If any rule denies a port, it is denied regardless of other allow rules.`,
+If any rule denies a port, it is denied regardless of other allow rules.
+
+The filter tracks connections: replies to traffic this peer sends, and
+further packets of connections the rules let in, pass without rule checks.
+Changing the rules drops the connections other peers opened, so that their
+traffic is checked again.`,
 	}
 
 	cmd.AddCommand(newFilterListCmd())
 	cmd.AddCommand(newFilterAddCmd())
 	cmd.AddCommand(newFilterRemoveCmd())
+	cmd.AddCommand(newFilterFlowsCmd())
 
 	return cmd
 }
@@ -56,7 +68,7 @@ func newFilterListCmd() *cobra.Command {
 
 			// Print header
 			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			_, _ = fmt.Fprintf(w, "PORT\tPROTOCOL\tACTION\tSOURCE PEER\tSOURCE\tEXPIRES\n")
+			_, _ = fmt.Fprintf(w, "PORT\tPROTOCOL\tACTION\tFROM\tTO\tSOURCE\tEXPIRES\n")
 
 			for _, rule := range resp.Rules {
 				expires := "-"
@@ -68,12 +80,15 @@ func newFilterListCmd() *cobra.Command {
 						expires = "expired"
 					}
 				}
-				sourcePeer := "*"
-				if rule.SourcePeer != "" {
-					sourcePeer = rule.SourcePeer
+				to := "*"
+				if rule.DestGroup != "" {
+					to = "group:" + rule.DestGroup
 				}
-				_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n",
-					rule.Port, strings.ToUpper(rule.Protocol), rule.Action, sourcePeer, rule.Source, expires)
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					formatRulePorts(rule.Protocol, rule.Port, rule.PortEnd, rule.ICMPType),
+					strings.ToUpper(rule.Protocol), rule.Action,
+					formatRuleSource(rule.SourcePeer, rule.SourceCIDR, rule.SourceGroup),
+					to, rule.Source, expires)
 			}
 			_ = w.Flush()
 
@@ -93,11 +108,9 @@ func newFilterListCmd() *cobra.Command {
 func newFilterAddCmd() *cobra.Command {
 	var (
 		socketPath string
-		port       uint16
-		protocol   string
 		action     string
 		ttl        int64
-		sourcePeer string
+		sel        filterSelectorFlags
 	)
 
 	cmd := &cobra.Command{
@@ -122,14 +135,21 @@ Examples:
   tunnelmesh filter add --port 22 --protocol tcp --source-peer trusted-peer
 
   # Block specific peer from accessing port 80
-  tunnelmesh filter add --port 80 --protocol tcp --action deny --source-peer untrusted-peer`,
+  tunnelmesh filter add --port 80 --protocol tcp --action deny --source-peer untrusted-peer
+
+  # Allow a port range from the office LAN behind a subnet router
+  tunnelmesh filter add --port 8000 --port-end 8100 --source-cidr 192.168.10.0/24
+
+  # Allow ping, deny every other ICMP type
+  tunnelmesh filter add --protocol icmp --action deny
+  tunnelmesh filter add --protocol icmp --icmp-type 8
+
+  # Allow SSH from peers in group ops, while this peer is in group prod
+  tunnelmesh filter add --port 22 --source-group ops --dest-group prod`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if port == 0 {
-				return fmt.Errorf("--port is required - must be between 1 and 65535")
-			}
-			protocol = strings.ToLower(protocol)
-			if protocol != "tcp" && protocol != "udp" {
-				return fmt.Errorf("invalid protocol %q - must be one of: tcp, udp", protocol)
+			rule, err := sel.rule()
+			if err != nil {
+				return err
 			}
 			action = strings.ToLower(action)
 			if action != "allow" && action != "deny" {
@@ -141,7 +161,19 @@ Examples:
 			}
 
 			client := control.NewClient(socketPath)
-			if err := client.FilterAddForPeer(port, protocol, action, ttl, sourcePeer); err != nil {
+			err = client.FilterAddRule(control.FilterAddRequest{
+				Port:        sel.port,
+				PortEnd:     sel.portEnd,
+				Protocol:    sel.protocol,
+				ICMPType:    sel.icmpType,
+				Action:      action,
+				TTL:         ttl,
+				SourcePeer:  sel.sourcePeer,
+				SourceCIDR:  sel.sourceCIDR,
+				SourceGroup: sel.sourceGroup,
+				DestGroup:   sel.destGroup,
+			})
+			if err != nil {
 				return fmt.Errorf("failed to add rule: %w", err)
 			}
 
@@ -149,23 +181,15 @@ Examples:
 			if ttl > 0 {
 				ttlStr = fmt.Sprintf("expires in %s", formatDuration(time.Duration(ttl)*time.Second))
 			}
-			peerStr := "all peers"
-			if sourcePeer != "" {
-				peerStr = fmt.Sprintf("peer '%s'", sourcePeer)
-			}
-			fmt.Printf("Added rule: %s port %d/%s from %s (%s)\n", action, port, strings.ToUpper(protocol), peerStr, ttlStr)
+			fmt.Printf("Added rule: %s %s (%s)\n", action, describeFilterRule(rule), ttlStr)
 			return nil
 		},
 	}
 
 	cmd.Flags().StringVar(&socketPath, "socket", "", "Control socket path")
-	cmd.Flags().Uint16Var(&port, "port", 0, "Port number (required)")
-	cmd.Flags().StringVar(&protocol, "protocol", "tcp", "Protocol: tcp or udp")
+	sel.register(cmd, "Source peer name (empty = any peer)")
 	cmd.Flags().StringVar(&action, "action", "allow", "Action: allow or deny")
 	cmd.Flags().Int64Var(&ttl, "ttl", 0, "Time to live in seconds (0 = permanent)")
-	cmd.Flags().StringVar(&sourcePeer, "source-peer", "", "Source peer name (empty = any peer)")
-
-	_ = cmd.MarkFlagRequired("port")
 
 	return cmd
 }
@@ -173,9 +197,7 @@ Examples:
 func newFilterRemoveCmd() *cobra.Command {
 	var (
 		socketPath string
-		port       uint16
-		protocol   string
-		sourcePeer string
+		sel        filterSelectorFlags
 	)
 
 	cmd := &cobra.Command{
@@ -185,20 +207,21 @@ func newFilterRemoveCmd() *cobra.Command {
 
 Note: Only temporary rules (added via CLI or admin panel) can be removed.
 Rules from config files must be removed by editing the config.
+The selectors must match those the rule was added with.
 
 Examples:
   # Remove global rule for port 22
   tunnelmesh filter remove --port 22 --protocol tcp
 
   # Remove peer-specific rule
-  tunnelmesh filter remove --port 22 --protocol tcp --source-peer untrusted-peer`,
+  tunnelmesh filter remove --port 22 --protocol tcp --source-peer untrusted-peer
+
+  # Remove a port range rule
+  tunnelmesh filter remove --port 8000 --port-end 8100 --source-cidr 192.168.10.0/24`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if port == 0 {
-				return fmt.Errorf("--port is required - must be between 1 and 65535")
-			}
-			protocol = strings.ToLower(protocol)
-			if protocol != "tcp" && protocol != "udp" {
-				return fmt.Errorf("invalid protocol %q - must be one of: tcp, udp", protocol)
+			rule, err := sel.rule()
+			if err != nil {
+				return err
 			}
 
 			if socketPath == "" {
@@ -206,29 +229,191 @@ Examples:
 			}
 
 			client := control.NewClient(socketPath)
-			if err := client.FilterRemoveForPeer(port, protocol, sourcePeer); err != nil {
+			err = client.FilterRemoveRule(control.FilterRemoveRequest{
+				Port:        sel.port,
+				PortEnd:     sel.portEnd,
+				Protocol:    sel.protocol,
+				ICMPType:    sel.icmpType,
+				SourcePeer:  sel.sourcePeer,
+				SourceCIDR:  sel.sourceCIDR,
+				SourceGroup: sel.sourceGroup,
+				DestGroup:   sel.destGroup,
+			})
+			if err != nil {
 				return fmt.Errorf("failed to remove rule: %w", err)
 			}
 
-			peerStr := "all peers"
-			if sourcePeer != "" {
-				peerStr = fmt.Sprintf("peer '%s'", sourcePeer)
-			}
-			fmt.Printf("Removed temporary rule for port %d/%s from %s\n", port, strings.ToUpper(protocol), peerStr)
+			fmt.Printf("Removed temporary rule for %s\n", describeFilterRule(rule))
 			return nil
 		},
 	}
 
 	cmd.Flags().StringVar(&socketPath, "socket", "", "Control socket path")
-	cmd.Flags().Uint16Var(&port, "port", 0, "Port number (required)")
-	cmd.Flags().StringVar(&protocol, "protocol", "tcp", "Protocol: tcp or udp")
-	cmd.Flags().StringVar(&sourcePeer, "source-peer", "", "Source peer name (empty = global rule)")
+	sel.register(cmd, "Source peer name (empty = global rule)")
+
+	return cmd
+}
+
+func newFilterFlowsCmd() *cobra.Command {
+	var socketPath string
+
+	cmd := &cobra.Command{
+		Use:   "flows",
+		Short: "List tracked connections",
+		Long: `List the connections tracked by the packet filter.
+
+Direction "out" is a connection this peer opened, whose replies are let in;
+"in" is one a remote peer opened that the rules allowed.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if socketPath == "" {
+				socketPath = control.DefaultSocketPath()
+			}
+
+			client := control.NewClient(socketPath)
+			resp, err := client.FilterFlows()
+			if err != nil {
+				return fmt.Errorf("failed to list flows: %w", err)
+			}
+
+			if len(resp.Flows) == 0 {
+				fmt.Println("No tracked connections")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			_, _ = fmt.Fprintf(w, "PROTOCOL\tDIR\tSOURCE\tDESTINATION\tSTATE\tPEER\tPACKETS\tEXPIRES\n")
+			for _, fl := range resp.Flows {
+				peer := fl.Peer
+				if peer == "" {
+					peer = "-"
+				}
+				expires := "expired"
+				if remaining := time.Until(time.Unix(fl.Expires, 0)); remaining > 0 {
+					expires = formatDuration(remaining)
+				}
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d/%d\t%s\n",
+					strings.ToUpper(fl.Protocol), fl.Direction, fl.Src, fl.Dst, fl.State, peer,
+					fl.PacketsOrig, fl.PacketsReply, expires)
+			}
+			_ = w.Flush()
+
+			fmt.Printf("\nTotal flows: %d\n", len(resp.Flows))
+			return nil
+		},
+	}
 
-	_ = cmd.MarkFlagRequired("port")
+	cmd.Flags().StringVar(&socketPath, "socket", "", "Control socket path (default: ~/.tunnelmesh/control.sock)")
 
 	return cmd
 }
 
+// filterSelectorFlags holds the flags selecting the traffic a rule applies to,
+// shared by filter add and filter remove.
+type filterSelectorFlags struct {
+	port        uint16
+	portEnd     uint16
+	protocol    string
+	icmpType    uint8
+	sourcePeer  string
+	sourceCIDR  string
+	sourceGroup string
+	destGroup   string
+}
+
+func (f *filterSelectorFlags) register(cmd *cobra.Command, sourcePeerUsage string) {
+	cmd.Flags().Uint16Var(&f.port, "port", 0, "Port number (required for tcp and udp)")
+	cmd.Flags().Uint16Var(&f.portEnd, "port-end", 0, "Last port of a port range (default: --port only)")
+	cmd.Flags().StringVar(&f.protocol, "protocol", "tcp", "Protocol: tcp, udp, icmp or icmpv6")
+	cmd.Flags().Uint8Var(&f.icmpType, "icmp-type", 0, "ICMP message type, e.g. 8 for echo request (0 = any)")
+	cmd.Flags().StringVar(&f.sourcePeer, "source-peer", "", sourcePeerUsage)
+	cmd.Flags().StringVar(&f.sourceCIDR, "source-cidr", "", "Source address prefix, e.g. 192.168.10.0/24 (empty = any)")
+	cmd.Flags().StringVar(&f.sourceGroup, "source-group", "", "RBAC group the source peer must be in (empty = any)")
+	cmd.Flags().StringVar(&f.destGroup, "dest-group", "", "RBAC group this peer must be in for the rule to apply")
+}
+
+// rule validates the flags and returns the rule they select.
+func (f *filterSelectorFlags) rule() (routing.FilterRule, error) {
+	f.protocol = strings.ToLower(f.protocol)
+	proto := routing.ProtocolFromString(f.protocol)
+	if proto == 0 {
+		return routing.FilterRule{}, fmt.Errorf("invalid protocol %q - must be one of: tcp, udp, icmp, icmpv6", f.protocol)
+	}
+	rule := routing.FilterRule{
+		Port:        f.port,
+		PortEnd:     f.portEnd,
+		Protocol:    proto,
+		ICMPType:    f.icmpType,
+		SourcePeer:  f.sourcePeer,
+		SourceCIDR:  f.sourceCIDR,
+		SourceGroup: f.sourceGroup,
+		DestGroup:   f.destGroup,
+	}
+	if err := rule.Validate(); err != nil {
+		return routing.FilterRule{}, err
+	}
+	return rule, nil
+}
+
+// describeFilterRule formats a rule's selectors for confirmation messages,
+// e.g. "port 8000-8100/TCP from 192.168.10.0/24".
+func describeFilterRule(r routing.FilterRule) string {
+	proto := routing.ProtocolToString(r.Protocol)
+	var desc string
+	if r.Protocol == routing.ProtoICMP || r.Protocol == routing.ProtoICMPv6 {
+		desc = strings.ToUpper(proto)
+		if r.ICMPType != 0 {
+			desc += fmt.Sprintf(" type %d", r.ICMPType)
+		}
+	} else {
+		desc = fmt.Sprintf("port %s/%s", formatRulePorts(proto, r.Port, r.PortEnd, 0), strings.ToUpper(proto))
+	}
+
+	from := formatRuleSource(r.SourcePeer, r.SourceCIDR, r.SourceGroup)
+	if from == "*" {
+		from = "all peers"
+	}
+	desc += " from " + from
+	if r.DestGroup != "" {
+		desc += fmt.Sprintf(" when in group '%s'", r.DestGroup)
+	}
+	return desc
+}
+
+// formatRulePorts formats the port column of a rule: a port or port range
+// for TCP/UDP, the message type (or "*" for any) for ICMP.
+func formatRulePorts(protocol string, port, portEnd uint16, icmpType uint8) string {
+	switch strings.ToLower(protocol) {
+	case "icmp", "icmpv6":
+		if icmpType == 0 {
+			return "*"
+		}
+		return fmt.Sprintf("type %d", icmpType)
+	}
+	if portEnd > port {
+		return fmt.Sprintf("%d-%d", port, portEnd)
+	}
+	return fmt.Sprintf("%d", port)
+}
+
+// formatRuleSource formats the source selectors of a rule, "*" if it matches
+// any source.
+func formatRuleSource(sourcePeer, sourceCIDR, sourceGroup string) string {
+	var parts []string
+	if sourcePeer != "" {
+		parts = append(parts, sourcePeer)
+	}
+	if sourceCIDR != "" {
+		parts = append(parts, sourceCIDR)
+	}
+	if sourceGroup != "" {
+		parts = append(parts, "group:"+sourceGroup)
+	}
+	if len(parts) == 0 {
+		return "*"
+	}
+	return strings.Join(parts, ",")
+}
+
 func formatDuration(d time.Duration) string {
 	if d < time.Minute {
 		return fmt.Sprintf("%ds", int(d.Seconds()))
@@ -248,3 +433,33 @@ func policyString(defaultDeny bool) string {
 	}
 	return "allow (blocklist mode - all ports accessible unless denied)"
 }
+
+// filterRuleFromConfig converts a filter rule from a config file.
+func filterRuleFromConfig(r config.FilterRule) routing.FilterRule {
+	return routing.FilterRule{
+		Port:        r.Port,
+		PortEnd:     r.PortEnd,
+		Protocol:    r.ProtocolNumber(),
+		ICMPType:    r.ICMPType,
+		Action:      routing.ParseFilterAction(r.Action),
+		SourcePeer:  r.SourcePeer,
+		SourceCIDR:  r.SourceCIDR,
+		SourceGroup: r.SourceGroup,
+		DestGroup:   r.DestGroup,
+	}
+}
+
+// filterRuleFromWire converts a filter rule pushed by the coordinator.
+func filterRuleFromWire(r tunnel.FilterRuleWire) routing.FilterRule {
+	return routing.FilterRule{
+		Port:        r.Port,
+		PortEnd:     r.PortEnd,
+		Protocol:    routing.ProtocolFromString(r.Protocol),
+		ICMPType:    r.ICMPType,
+		Action:      routing.ParseFilterAction(r.Action),
+		SourcePeer:  r.SourcePeer,
+		SourceCIDR:  r.SourceCIDR,
+		SourceGroup: r.SourceGroup,
+		DestGroup:   r.DestGroup,
+	}
+}
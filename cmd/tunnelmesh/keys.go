@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
+)
+
+func newKeysCmd() *cobra.Command {
+	keysCmd := &cobra.Command{
+		Use:   "keys",
+		Short: "Manage join keys and revoked peers",
+		Long: `Manage join keys and revoked peers.
+
+A join key is used in place of the mesh token to join a peer
+(TUNNELMESH_TOKEN=<key>). A single-use key joins one peer, a reusable key
+any number until it expires. A key can assign the peer's name, groups and
+tags. Revoking a key stops new peers joining with it; peers that already
+joined keep their access until they are revoked themselves.
+
+Revoking a peer removes it from the mesh and rejects its registrations,
+relay tokens and mesh TLS certificate, whatever token it uses.
+
+Examples:
+  # Create a single-use key valid for a day
+  tunnelmesh keys create --name build-01 --group ci --tag tag:ci
+
+  # Create a reusable key for a week
+  tunnelmesh keys create --reusable --ttl 604800 --description "CI runners"
+
+  # List keys and revoked peers
+  tunnelmesh keys list
+
+  # Stop new peers joining with a key
+  tunnelmesh keys revoke 3f9a1c0b7e2d
+
+  # Cut a peer off, and let it back in
+  tunnelmesh keys revoke-peer lost-laptop
+  tunnelmesh keys restore-peer <peer-id>`,
+	}
+
+	// Create subcommand
+	createCmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create a join key",
+		Args:  cobra.NoArgs,
+		RunE:  runKeysCreate,
+	}
+	createCmd.Flags().StringP("description", "d", "", "Key description")
+	createCmd.Flags().Bool("reusable", false, "Allow any number of peers to join with the key")
+	createCmd.Flags().Int("ttl", 86400, "Seconds until the key expires (0 for never)")
+	createCmd.Flags().String("name", "", "Name given to peers joining with the key")
+	createCmd.Flags().StringSlice("group", nil, "Group peers joining with the key are added to (repeatable)")
+	createCmd.Flags().StringSlice("tag", nil, "Tag given to peers joining with the key, e.g. tag:prod (repeatable)")
+	keysCmd.AddCommand(createCmd)
+
+	// List subcommand
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List join keys and revoked peers",
+		Args:  cobra.NoArgs,
+		RunE:  runKeysList,
+	}
+	keysCmd.AddCommand(listCmd)
+
+	// Revoke subcommand
+	revokeCmd := &cobra.Command{
+		Use:   "revoke <key-id>",
+		Short: "Revoke a join key",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runKeysRevoke,
+	}
+	keysCmd.AddCommand(revokeCmd)
+
+	// Revoke-peer subcommand
+	revokePeerCmd := &cobra.Command{
+		Use:   "revoke-peer <peer>",
+		Short: "Revoke a peer's access, by name or peer ID",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runKeysRevokePeer,
+	}
+	keysCmd.AddCommand(revokePeerCmd)
+
+	// Restore-peer subcommand
+	restorePeerCmd := &cobra.Command{
+		Use:   "restore-peer <peer-id>",
+		Short: "Lift the revocation of a peer",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runKeysRestorePeer,
+	}
+	keysCmd.AddCommand(restorePeerCmd)
+
+	return keysCmd
+}
+
+func runKeysCreate(cmd *cobra.Command, _ []string) error {
+	req := proto.JoinKeyRequest{}
+	req.Description, _ = cmd.Flags().GetString("description")
+	req.Reusable, _ = cmd.Flags().GetBool("reusable")
+	req.TTL, _ = cmd.Flags().GetInt("ttl")
+	req.Name, _ = cmd.Flags().GetString("name")
+	req.Groups, _ = cmd.Flags().GetStringSlice("group")
+	req.Tags, _ = cmd.Flags().GetStringSlice("tag")
+	body, _ := json.Marshal(req)
+
+	resp, err := makeAdminRequest("POST", getAdminURL()+"/api/keys", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusCreated {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to create key: %s", string(respBody))
+	}
+
+	var key proto.JoinKey
+	if err := json.NewDecoder(resp.Body).Decode(&key); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	fmt.Printf("Join key %s created (%s, %s)\n\n", key.ID, keyUsage(key), formatKeyExpiry(key.ExpiresAt))
+	fmt.Printf("  %s\n\n", key.Key)
+	fmt.Println("The key is not shown again. Join a peer with:")
+	fmt.Printf("  TUNNELMESH_TOKEN=%s tunnelmesh join\n", key.Key)
+	return nil
+}
+
+func runKeysList(_ *cobra.Command, _ []string) error {
+	var keys []proto.JoinKey
+	if err := getAdminJSON("/api/keys", "list keys", &keys); err != nil {
+		return err
+	}
+	var revoked []proto.RevokedPeer
+	if err := getAdminJSON("/api/revoked-peers", "list revoked peers", &revoked); err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		fmt.Println("No join keys")
+	} else {
+		now := time.Now()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "ID\tUSAGE\tSTATUS\tPEERS\tNAME\tGROUPS\tTAGS\tEXPIRES\tDESCRIPTION")
+		for _, key := range keys {
+			status := "active"
+			switch {
+			case key.Revoked:
+				status = "revoked"
+			case !key.ExpiresAt.IsZero() && !now.Before(key.ExpiresAt):
+				status = "expired"
+			case !key.CanJoin(now):
+				status = "used"
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\n",
+				key.ID, keyUsage(key), status, len(key.PeerIDs), orDash(key.Name),
+				orDash(strings.Join(key.Groups, ",")), orDash(strings.Join(key.Tags, ",")),
+				formatKeyExpiry(key.ExpiresAt), key.Description)
+		}
+		_ = w.Flush()
+	}
+
+	if len(revoked) > 0 {
+		fmt.Println("\nRevoked peers:")
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "NAME\tPEER ID\tREVOKED")
+		for _, rp := range revoked {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", rp.Name, rp.PeerID, rp.RevokedAt.Local().Format(time.RFC3339))
+		}
+		_ = w.Flush()
+	}
+
+	return nil
+}
+
+func runKeysRevoke(_ *cobra.Command, args []string) error {
+	resp, err := makeAdminRequest("DELETE", getAdminURL()+"/api/keys/"+url.PathEscape(args[0]), nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to revoke key: %s", string(body))
+	}
+
+	fmt.Printf("Join key %s revoked\n", args[0])
+	return nil
+}
+
+func runKeysRevokePeer(_ *cobra.Command, args []string) error {
+	body, _ := json.Marshal(map[string]string{"peer": args[0]})
+
+	resp, err := makeAdminRequest("POST", getAdminURL()+"/api/revoked-peers", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusCreated {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to revoke peer: %s", string(respBody))
+	}
+
+	var rp proto.RevokedPeer
+	if err := json.NewDecoder(resp.Body).Decode(&rp); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	fmt.Printf("Peer %s (%s) revoked\n", rp.Name, rp.PeerID)
+	return nil
+}
+
+func runKeysRestorePeer(_ *cobra.Command, args []string) error {
+	resp, err := makeAdminRequest("DELETE", getAdminURL()+"/api/revoked-peers/"+url.PathEscape(args[0]), nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to restore peer: %s", string(body))
+	}
+
+	fmt.Printf("Peer %s restored\n", args[0])
+	return nil
+}
+
+// getAdminJSON fetches an admin API path and decodes the JSON response.
+func getAdminJSON(path, what string, v any) error {
+	resp, err := makeAdminRequest("GET", getAdminURL()+path, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to %s: %s", what, string(body))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// keyUsage describes whether a join key is single-use or reusable.
+func keyUsage(key proto.JoinKey) string {
+	if key.Reusable {
+		return "reusable"
+	}
+	return "single-use"
+}
+
+// formatKeyExpiry formats the time left until a join key expires.
+func formatKeyExpiry(expiresAt time.Time) string {
+	if expiresAt.IsZero() {
+		return "never"
+	}
+	remaining := time.Until(expiresAt)
+	if remaining <= 0 {
+		return "expired"
+	}
+	return formatDuration(remaining)
+}
+
+// orDash returns s, or "-" if it is empty.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
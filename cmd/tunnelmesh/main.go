@@ -6,7 +6,6 @@ import (
 	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/tls"
-	"crypto/x509"
 	"encoding/hex"
 	"fmt"
 	"io"
@@ -44,7 +43,9 @@ import (
 	"github.com/tunnelmesh/tunnelmesh/internal/svc"
 	"github.com/tunnelmesh/tunnelmesh/internal/tracing"
 	"github.com/tunnelmesh/tunnelmesh/internal/transport"
+	quictransport "github.com/tunnelmesh/tunnelmesh/internal/transport/quic"
 	sshtransport "github.com/tunnelmesh/tunnelmesh/internal/transport/ssh"
+	tlstransport "github.com/tunnelmesh/tunnelmesh/internal/transport/tls"
 	udptransport "github.com/tunnelmesh/tunnelmesh/internal/transport/udp"
 	"github.com/tunnelmesh/tunnelmesh/internal/tun"
 	"github.com/tunnelmesh/tunnelmesh/internal/tunnel"
@@ -73,6 +74,7 @@ var (
 	// Exit peer flags
 	exitPeerFlag     string
 	allowExitTraffic bool
+	allowRelay       bool
 
 	// Context flag for join command
 	joinContext string
@@ -171,6 +173,7 @@ Auth token must be set via TUNNELMESH_TOKEN environment variable.`,
 	joinCmd.Flags().StringVar(&city, "city", "", "city name for manual geolocation (shown in admin UI)")
 	joinCmd.Flags().StringVar(&exitPeerFlag, "exit-peer", "", "name of peer to route internet traffic through")
 	joinCmd.Flags().BoolVar(&allowExitTraffic, "allow-exit-traffic", false, "allow this peer to act as exit peer for other peers")
+	joinCmd.Flags().BoolVar(&allowRelay, "allow-relay", false, "relay traffic between peers that cannot connect directly")
 	joinCmd.Flags().BoolVar(&enableTracing, "enable-tracing", false, "enable runtime tracing (exposes /debug/trace endpoint)")
 	joinCmd.Flags().StringVar(&joinContext, "context", "", "save/update context with this name after joining")
 	joinCmd.Flags().StringVar(&keygenSeed, "keygen-seed", "", "seed for deterministic key generation (TESTING ONLY - reduces security)")
@@ -272,6 +275,21 @@ Examples:
 	// Share command - manage file shares
 	rootCmd.AddCommand(newShareCmd())
 
+	// DNS command - manage custom DNS records
+	rootCmd.AddCommand(newDNSCmd())
+
+	// Routes command - approve subnet routes advertised by peers
+	rootCmd.AddCommand(newRoutesCmd())
+
+	// Keys command - issue join keys and revoke peers
+	rootCmd.AddCommand(newKeysCmd())
+
+	// Tags command - tag peers for access policies
+	rootCmd.AddCommand(newTagsCmd())
+
+	// CA command - rotate the mesh CA and revoke certificates
+	rootCmd.AddCommand(newCaCmd())
+
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
 	}
@@ -692,6 +710,9 @@ func runJoin(cmd *cobra.Command, args []string) error {
 	if allowExitTraffic {
 		cfg.AllowExitTraffic = true
 	}
+	if allowRelay {
+		cfg.AllowRelay = true
+	}
 
 	// Enable coordinator mode for bootstrap if no server URL
 	ensureCoordinatorConfig(cfg)
@@ -756,24 +777,24 @@ func discoverAndRegisterWithCoordinator(
 		client := coord.NewClient(coordURL, cfg.AuthToken)
 		hasMonitoring := cfg.Coordinator.Enabled &&
 			(cfg.Coordinator.Monitoring.PrometheusURL != "" || cfg.Coordinator.Monitoring.GrafanaURL != "")
-		resp, err := client.RegisterWithRetry(
-			ctx,
-			cfg.Name,
-			publicKey,
-			publicIPs,
-			privateIPs,
-			sshPort,
-			udpPort,
-			behindNAT,
-			version,
-			location,
-			cfg.ExitPeer,
-			cfg.AllowExitTraffic,
-			cfg.DNS.Aliases,
-			cfg.Coordinator.Enabled,
-			hasMonitoring,
-			coord.DefaultRetryConfig(),
-		)
+		resp, err := client.RegisterWithRetry(ctx, proto.RegisterRequest{
+			Name:              cfg.Name,
+			PublicKey:         publicKey,
+			PublicIPs:         publicIPs,
+			PrivateIPs:        privateIPs,
+			SSHPort:           sshPort,
+			UDPPort:           udpPort,
+			BehindNAT:         behindNAT,
+			Version:           version,
+			Location:          location,
+			ExitPeer:          cfg.ExitPeer,
+			AllowsExitTraffic: cfg.AllowExitTraffic,
+			Aliases:           cfg.DNS.Aliases,
+			Services:          peer.AdvertisedServices(cfg),
+			Routes:            cfg.AdvertiseRoutes,
+			IsCoordinator:     cfg.Coordinator.Enabled,
+			HasMonitoring:     hasMonitoring,
+		}, coord.DefaultRetryConfig())
 
 		if err == nil {
 			log.Info().
@@ -836,6 +857,7 @@ func runJoinWithConfigAndCallback(ctx context.Context, cfg *config.PeerConfig, o
 		// Start periodic background tasks
 		srv.StartPeriodicSave(ctx)
 		srv.StartPeriodicCleanup(ctx)
+		srv.StartPSKRotation(ctx)
 
 		// Start replicator if clustering is enabled
 		if err := srv.StartReplicator(); err != nil {
@@ -910,6 +932,7 @@ func runJoinWithConfigAndCallback(ctx context.Context, cfg *config.PeerConfig, o
 			Latitude:  cfg.Geolocation.Latitude,
 			Longitude: cfg.Geolocation.Longitude,
 			City:      cfg.Geolocation.City,
+			Country:   cfg.Geolocation.Country,
 			Source:    "manual",
 		}
 		log.Info().
@@ -1019,11 +1042,15 @@ func runJoinWithConfigAndCallback(ctx context.Context, cfg *config.PeerConfig, o
 		MTU:     cfg.TUN.MTU,
 		Address: resp.MeshIP + "/" + strings.Split(resp.MeshCIDR, "/")[1],
 	}
+	if resp.MeshIPv6 != "" && resp.MeshCIDRv6 != "" {
+		tunCfg.Address6 = resp.MeshIPv6 + "/" + strings.Split(resp.MeshCIDRv6, "/")[1]
+	}
 
 	log.Info().
 		Str("name", tunCfg.Name).
 		Int("mtu", tunCfg.MTU).
 		Str("address", tunCfg.Address).
+		Str("address6", tunCfg.Address6).
 		Msg("creating TUN device")
 
 	tunDev, err := tun.Create(tunCfg)
@@ -1050,25 +1077,36 @@ func runJoinWithConfigAndCallback(ctx context.Context, cfg *config.PeerConfig, o
 			Msg("hostname conflict - using assigned name")
 	}
 	node := peer.NewMeshNode(identity, client)
+	if tlsMgr != nil {
+		// Renewed over the persistent relay, wired up on connect
+		node.Certs = peer.NewCertRenewer(tlsMgr)
+	}
 
 	// Set up forwarder with node's tunnel manager and router
 	forwarder := routing.NewForwarder(node.Router(), node.TunnelMgr())
+	forwarder.SetLocalName(identity.Name)
+	if cfg.AllowRelay {
+		log.Info().Msg("this node relays traffic between peers that cannot connect directly")
+		forwarder.SetAllowRelay(true)
+	}
 	if tunDev != nil {
 		forwarder.SetTUN(tunDev)
 		forwarder.SetLocalIP(net.ParseIP(resp.MeshIP))
+		if ip6 := tunDev.IP6(); ip6 != nil {
+			forwarder.SetLocalIPv6(ip6)
+		}
+		// Subnet routes advertised by other peers are installed on the TUN device
+		node.TUNName = tunDev.Name()
 	}
 	node.Forwarder = forwarder
 
 	// Initialize packet filter from config
 	filter := routing.NewPacketFilter(cfg.Filter.IsDefaultDeny())
+	filter.SetICMPDefaultDeny(cfg.Filter.ICMPDefaultDeny)
 	if len(cfg.Filter.Rules) > 0 {
 		filterRules := make([]routing.FilterRule, 0, len(cfg.Filter.Rules))
 		for _, r := range cfg.Filter.Rules {
-			filterRules = append(filterRules, routing.FilterRule{
-				Port:     r.Port,
-				Protocol: r.ProtocolNumber(),
-				Action:   routing.ParseFilterAction(r.Action),
-			})
+			filterRules = append(filterRules, filterRuleFromConfig(r))
 		}
 		filter.SetPeerConfigRules(filterRules)
 		log.Info().Int("rules", len(filterRules)).Msg("loaded filter rules from config")
@@ -1097,21 +1135,17 @@ func runJoinWithConfigAndCallback(ctx context.Context, cfg *config.PeerConfig, o
 			return fmt.Errorf("failed to load TLS certificate for coordinator services: %w", err)
 		}
 
+		// Serve renewed certificates from the coordinator services
+		node.Certs.OnRenewed(srv.SetTLSCertificate)
+
 		// Configure mesh TLS for inter-coordinator replication and forwarding.
 		// Trust the CA from registration (not the locally-generated CA) since all
 		// coordinator certs are signed by the primary's CA. Include our own cert
-		// for client authentication.
-		caPEM, caErr := os.ReadFile(tlsMgr.CAPath())
-		if caErr != nil {
+		// for client authentication. Both follow renewals and CA rollovers.
+		if _, caErr := os.Stat(tlsMgr.CAPath()); caErr != nil {
 			return fmt.Errorf("failed to load mesh CA for inter-coordinator TLS: %w", caErr)
 		}
-		caPool := x509.NewCertPool()
-		caPool.AppendCertsFromPEM(caPEM)
-		srv.SetMeshTLS(&tls.Config{
-			Certificates: []tls.Certificate{tlsCert},
-			RootCAs:      caPool,
-			MinVersion:   tls.VersionTLS12,
-		})
+		srv.SetMeshTLS(tlsMgr.ClientTLSConfig())
 
 		{
 			// Start admin HTTPS server if enabled
@@ -1181,7 +1215,8 @@ func runJoinWithConfigAndCallback(ctx context.Context, cfg *config.PeerConfig, o
 		}
 	}
 
-	// Configure exit node settings
+	// Configure exit node and subnet router settings
+	var subnetCfg tun.SubnetRouteConfig
 	if tunDev != nil {
 		// Parse mesh CIDR for split-tunnel detection
 		_, meshNet, err := net.ParseCIDR(resp.MeshCIDR)
@@ -1195,20 +1230,35 @@ func runJoinWithConfigAndCallback(ctx context.Context, cfg *config.PeerConfig, o
 				MeshCIDR:      resp.MeshCIDR,
 			}
 
-			// Configure exit node routing (client side)
-			if cfg.ExitPeer != "" {
-				// Strip domain suffix if present (allow both "peer" and "peer.tunnelmesh")
-				exitNodeName := cfg.ExitPeer
-				if resp.Domain != "" && strings.HasSuffix(exitNodeName, resp.Domain) {
-					exitNodeName = strings.TrimSuffix(exitNodeName, resp.Domain)
-				}
-				forwarder.SetExitPeer(exitNodeName)
-				log.Info().Str("exit_node", exitNodeName).Msg("exit node configured, internet traffic will route through peer")
+			// IPv6 traffic is split the same way once the TUN has a mesh IPv6 address
+			if _, meshNet6, err := net.ParseCIDR(resp.MeshCIDRv6); err == nil && tunDev.IP6() != nil {
+				forwarder.SetMeshCIDRv6(meshNet6)
+				exitCfg.MeshCIDR6 = resp.MeshCIDRv6
+			}
 
+			// Configure exit peer routing (client side)
+			exitPeers := trimDomain(cfg.ExitPeerList(), resp.Domain)
+			if len(exitPeers) > 0 || len(cfg.ExitPolicies) > 0 {
+				forwarder.SetExitPeers(exitPeers)
+				forwarder.Exits().SetPolicies(buildExitPolicies(cfg.ExitPolicies, resp.Domain))
+				log.Info().Strs("exit_peers", exitPeers).Int("policies", len(cfg.ExitPolicies)).
+					Msg("exit peers configured, internet traffic will route through peer")
+			}
+			if len(exitPeers) > 0 {
 				if err := tun.ConfigureExitRoutes(exitCfg); err != nil {
 					log.Warn().Err(err).Msg("failed to configure exit routes, manual setup may be required")
 				} else {
-					log.Info().Msg("exit routes configured (0.0.0.0/1, 128.0.0.0/1 via TUN)")
+					log.Info().Bool("ipv6", exitCfg.MeshCIDR6 != "").Msg("exit routes configured (0.0.0.0/1, 128.0.0.0/1 via TUN)")
+				}
+			} else {
+				// Without default exit peers only the policy destinations go through the mesh
+				for _, policy := range cfg.ExitPolicies {
+					for _, dest := range policy.Destinations {
+						_, ipNet, _ := net.ParseCIDR(dest)
+						if err := tun.AddRoute(ipNet, nil, tunDev.Name()); err != nil {
+							log.Warn().Err(err).Str("route", dest).Msg("failed to add exit policy route")
+						}
+					}
 				}
 			}
 
@@ -1217,12 +1267,39 @@ func runJoinWithConfigAndCallback(ctx context.Context, cfg *config.PeerConfig, o
 				log.Info().Msg("this node allows exit traffic from other peers")
 				exitCfg.IsExitPeer = true
 
+				// Until the peer list shows which peers carry the tags, no peer may use the exit
+				if len(cfg.ExitAllowTags) > 0 {
+					forwarder.SetExitClients([]string{})
+					var local []*net.IPNet
+					for _, route := range cfg.AdvertiseRoutes {
+						if _, ipNet, err := net.ParseCIDR(route); err == nil {
+							local = append(local, ipNet)
+						}
+					}
+					forwarder.SetLocalRoutes(local)
+					log.Info().Strs("tags", cfg.ExitAllowTags).Msg("exit traffic restricted to tagged peers")
+				}
+
 				if err := tun.ConfigureExitNAT(exitCfg); err != nil {
 					log.Warn().Err(err).Msg("failed to configure exit NAT, manual setup may be required")
 				} else {
 					log.Info().Msg("exit NAT configured (IP forwarding + masquerade)")
 				}
 			}
+
+			// Forward mesh traffic into the LAN prefixes this node advertises
+			if len(cfg.AdvertiseRoutes) > 0 {
+				subnetCfg = tun.SubnetRouteConfig{
+					InterfaceName: tunDev.Name(),
+					MeshCIDR:      resp.MeshCIDR,
+					Routes:        cfg.AdvertiseRoutes,
+				}
+				if err := tun.ConfigureSubnetNAT(subnetCfg); err != nil {
+					log.Warn().Err(err).Msg("failed to configure subnet NAT, manual setup may be required")
+				} else {
+					log.Info().Strs("routes", cfg.AdvertiseRoutes).Msg("advertising subnet routes, pending approval on the coordinator")
+				}
+			}
 		}
 	}
 
@@ -1235,13 +1312,18 @@ func runJoinWithConfigAndCallback(ctx context.Context, cfg *config.PeerConfig, o
 		}
 	})
 
-	// Create transport registry with default order: UDP -> SSH
+	// Create transport registry with default order: UDP -> QUIC -> TLS -> SSH
 	// UDP is first for better performance (lower latency, no head-of-line blocking)
+	// QUIC is next for paths where middleboxes mangle the UDP transport's framing
+	// TLS is next for networks that only allow outbound HTTPS
+	// (QUIC and TLS are skipped for peers that don't run them)
 	// Falls back to SSH when UDP hole-punching fails or times out
 	// Relay traffic is handled by PersistentRelay separately (DERP-like architecture)
 	transportRegistry := transport.NewRegistry(transport.RegistryConfig{
 		DefaultOrder: []transport.TransportType{
 			transport.TransportUDP,
+			transport.TransportQUIC,
+			transport.TransportTLS,
 			transport.TransportSSH,
 		},
 	})
@@ -1355,13 +1437,15 @@ func runJoinWithConfigAndCallback(ctx context.Context, cfg *config.PeerConfig, o
 			}
 
 			udpTransport, err := udptransport.New(udptransport.Config{
-				Port:           cfg.SSHPort + 1, // Use SSH port + 1 for UDP
-				LocalPeerName:  cfg.Name,
-				StaticPrivate:  privKey,
-				StaticPublic:   pubKey,
-				CoordServerURL: cfg.PrimaryServer(),
-				AuthToken:      cfg.AuthToken,
-				PeerResolver:   peerResolver,
+				Port:                       cfg.SSHPort + 1, // Use SSH port + 1 for UDP
+				LocalPeerName:              cfg.Name,
+				StaticPrivate:              privKey,
+				StaticPublic:               pubKey,
+				CoordServerURL:             cfg.PrimaryServer(),
+				AuthToken:                  cfg.AuthToken,
+				AuthTokenFunc:              client.APIToken,
+				PeerResolver:               peerResolver,
+				AllowHandshakesWithoutMACs: cfg.UDP.AllowHandshakesWithoutMACs,
 			})
 			if err != nil {
 				log.Warn().Err(err).Msg("failed to create UDP transport, UDP disabled")
@@ -1419,6 +1503,28 @@ func runJoinWithConfigAndCallback(ctx context.Context, cfg *config.PeerConfig, o
 		}
 	}
 
+	// Create and register QUIC transport (authenticated with the mesh TLS certificate)
+	if cfg.QUIC.Enabled {
+		if tlsMgr == nil {
+			log.Warn().Msg("no mesh TLS certificate, QUIC disabled")
+		} else if err := startQUICTransport(ctx, cfg, tlsMgr, transportRegistry, node); err != nil {
+			log.Warn().Err(err).Msg("failed to start QUIC transport, QUIC disabled")
+		} else {
+			log.Info().Int("port", cfg.QUIC.Port).Msg("QUIC transport listening")
+		}
+	}
+
+	// Create and register TLS transport (dialed by every peer with a mesh TLS certificate)
+	if tlsMgr != nil {
+		if err := startTLSTransport(ctx, cfg, tlsMgr, transportRegistry, node); err != nil {
+			log.Warn().Err(err).Msg("failed to start TLS transport, TLS disabled")
+		} else if cfg.TLSTransport.Enabled {
+			log.Info().Int("port", cfg.TLSTransport.Port).Msg("TLS transport listening")
+		}
+	} else if cfg.TLSTransport.Enabled {
+		log.Warn().Msg("no mesh TLS certificate, TLS transport disabled")
+	}
+
 	// Create transport negotiator
 	// Note: Relay traffic is handled by PersistentRelay separately (DERP-like architecture)
 	transportNegotiator := transport.NewNegotiator(transportRegistry, transport.NegotiatorConfig{
@@ -1445,23 +1551,14 @@ func runJoinWithConfigAndCallback(ctx context.Context, cfg *config.PeerConfig, o
 			// Convert wire rules to routing filter rules and set as coordinator rules
 			filterRules := make([]routing.FilterRule, 0, len(rules))
 			for _, r := range rules {
-				filterRules = append(filterRules, routing.FilterRule{
-					Port:     r.Port,
-					Protocol: routing.ProtocolFromString(r.Protocol),
-					Action:   routing.ParseFilterAction(r.Action),
-				})
+				filterRules = append(filterRules, filterRuleFromWire(r))
 			}
 			filter.SetCoordinatorRules(filterRules)
 			log.Info().Int("rules", len(rules)).Msg("synced coordinator filter rules")
 		})
 
 		node.PersistentRelay.SetFilterRuleAddHandler(func(rule tunnel.FilterRuleWire) {
-			filter.AddTemporaryRule(routing.FilterRule{
-				Port:       rule.Port,
-				Protocol:   routing.ProtocolFromString(rule.Protocol),
-				Action:     routing.ParseFilterAction(rule.Action),
-				SourcePeer: rule.SourcePeer,
-			})
+			filter.AddTemporaryRule(filterRuleFromWire(rule))
 			log.Info().
 				Uint16("port", rule.Port).
 				Str("protocol", rule.Protocol).
@@ -1470,11 +1567,12 @@ func runJoinWithConfigAndCallback(ctx context.Context, cfg *config.PeerConfig, o
 				Msg("added temporary filter rule from coordinator")
 		})
 
-		node.PersistentRelay.SetFilterRuleRemoveHandler(func(port uint16, protocol string) {
-			filter.RemoveTemporaryRule(port, routing.ProtocolFromString(protocol))
+		node.PersistentRelay.SetFilterRuleRemoveHandler(func(rule tunnel.FilterRuleWire) {
+			filter.RemoveTemporaryRuleKey(filterRuleFromWire(rule).Key())
 			log.Info().
-				Uint16("port", port).
-				Str("protocol", protocol).
+				Uint16("port", rule.Port).
+				Str("protocol", rule.Protocol).
+				Str("source_peer", rule.SourcePeer).
 				Msg("removed temporary filter rule from coordinator")
 		})
 
@@ -1498,17 +1596,32 @@ func runJoinWithConfigAndCallback(ctx context.Context, cfg *config.PeerConfig, o
 			wireRules := make([]tunnel.FilterRuleWithSourceWire, 0, len(allRules))
 			for _, r := range allRules {
 				wireRules = append(wireRules, tunnel.FilterRuleWithSourceWire{
-					Port:       r.Rule.Port,
-					Protocol:   routing.ProtocolToString(r.Rule.Protocol),
-					Action:     r.Rule.Action.String(),
-					SourcePeer: r.Rule.SourcePeer,
-					Source:     r.Source.String(),
-					Expires:    r.Rule.Expires,
+					Port:        r.Rule.Port,
+					PortEnd:     r.Rule.PortEnd,
+					Protocol:    routing.ProtocolToString(r.Rule.Protocol),
+					ICMPType:    r.Rule.ICMPType,
+					Action:      r.Rule.Action.String(),
+					SourcePeer:  r.Rule.SourcePeer,
+					SourceCIDR:  r.Rule.SourceCIDR,
+					SourceGroup: r.Rule.SourceGroup,
+					DestGroup:   r.Rule.DestGroup,
+					Source:      r.Source.String(),
+					Expires:     r.Rule.Expires,
 				})
 			}
 			return wireRules
 		})
 
+		// Connection table query handler - returns the flows tracked by the packet filter
+		node.PersistentRelay.SetGetFilterFlowsHandler(func() []tunnel.FilterFlowWire {
+			details := control.FlowDetails(filter.Flows())
+			wireFlows := make([]tunnel.FilterFlowWire, 0, len(details))
+			for _, d := range details {
+				wireFlows = append(wireFlows, tunnel.FilterFlowWire(d))
+			}
+			return wireFlows
+		})
+
 		// Coordinator list update handler - updates DNS round-robin when coordinators join/leave
 		node.PersistentRelay.SetCoordListUpdateHandler(func(coordIPs []string) {
 			if node.Resolver != nil {
@@ -1587,12 +1700,14 @@ func runJoinWithConfigAndCallback(ctx context.Context, cfg *config.PeerConfig, o
 						PublicKey: c.PublicKey,
 						MeshIP:    c.MeshIP,
 						DNSName:   c.DNSName,
+						Tags:      c.Tags,
 						Enabled:   c.Enabled,
 						CreatedAt: c.CreatedAt,
 						LastSeen:  c.LastSeen,
 					}
 				}
 				wgRouter.UpdateClients(wgClients)
+				node.ClientTags = wgStore.TagsByIP
 
 				log.Info().
 					Int("port", cfg.WireGuard.ListenPort).
@@ -1663,6 +1778,20 @@ func runJoinWithConfigAndCallback(ctx context.Context, cfg *config.PeerConfig, o
 	} else if len(resp.CoordMeshIPs) > 0 {
 		resolver.SetCoordMeshIPs(resp.CoordMeshIPs)
 	}
+	// Forward non-mesh queries to upstream servers if configured
+	if len(cfg.DNS.Upstreams) > 0 || len(cfg.DNS.Forward) > 0 {
+		rules := make([]meshdns.ForwardRule, 0, len(cfg.DNS.Forward))
+		for _, rule := range cfg.DNS.Forward {
+			rules = append(rules, meshdns.ForwardRule{Domain: rule.Domain, Upstreams: rule.Upstreams})
+		}
+		if forwarder, err := meshdns.NewForwarder(cfg.DNS.Upstreams, rules); err != nil {
+			log.Warn().Err(err).Msg("invalid DNS forwarding configuration, resolving mesh names only")
+		} else {
+			// Domain exit policies route the addresses names resolve to
+			forwarder.SetObserver(node.Forwarder.Exits().LearnDomain)
+			resolver.SetForwarder(forwarder)
+		}
+	}
 	node.Resolver = resolver
 
 	// Initial DNS sync
@@ -1677,8 +1806,14 @@ func runJoinWithConfigAndCallback(ctx context.Context, cfg *config.PeerConfig, o
 	}()
 	log.Info().Str("listen", cfg.DNS.Listen).Msg("DNS server started")
 
-	// Configure system resolver
-	if err := configureSystemResolver(resp.Domain, cfg.DNS.Listen); err != nil {
+	// Configure system resolver: either for mesh domains only, or for everything
+	if cfg.DNS.SystemDefault {
+		if err := configureDefaultResolver(cfg.DNS.Listen); err != nil {
+			log.Warn().Err(err).Msg("failed to install default system resolver")
+		} else {
+			dnsConfigured = true
+		}
+	} else if err := configureSystemResolver(resp.Domain, cfg.DNS.Listen); err != nil {
 		log.Warn().Err(err).Msg("failed to configure system resolver")
 	} else {
 		dnsConfigured = true
@@ -1763,6 +1898,12 @@ func runJoinWithConfigAndCallback(ctx context.Context, cfg *config.PeerConfig, o
 		)
 	}
 
+	// Only set when UDP is enabled, so the interface isn't a typed nil
+	var handshakeStats metrics.HandshakeStatsProvider
+	if node.UDPTransport != nil {
+		handshakeStats = node.UDPTransport
+	}
+
 	// Create metrics collector
 	relayWrapper := metrics.NewRelayWrapper(node.PersistentRelay)
 	metricsCollector := metrics.NewCollector(peerMetrics, metrics.CollectorConfig{
@@ -1777,23 +1918,26 @@ func runJoinWithConfigAndCallback(ctx context.Context, cfg *config.PeerConfig, o
 		WGEnabled:           cfg.WireGuard.Enabled,
 		WGConcentrator:      wgWrapper,
 		Filter:              filter,
+		Handshakes:          handshakeStats,
 	})
 
 	// Register reconnect observer for metrics
 	node.Connections.AddObserver(metricsCollector.ReconnectObserver())
+	if node.UDPTransport != nil {
+		node.UDPTransport.SetPSKFailureCallback(metricsCollector.PSKFailureCallback())
+	}
 
 	// Start metrics collection loop
 	go metricsCollector.Run(ctx, 10*time.Second)
 
 	// Start metrics admin server on mesh IP
 	if tlsMgr != nil {
-		tlsCert, err := tlsMgr.LoadCert()
-		if err != nil {
+		if _, err := tlsMgr.Certificate(); err != nil {
 			log.Warn().Err(err).Msg("failed to load TLS cert for metrics server")
 		} else {
 			metricsAddr := fmt.Sprintf("%s:%d", resp.MeshIP, cfg.MetricsPort)
 			adminServer := admin.NewAdminServer()
-			if err := adminServer.Start(metricsAddr, tlsCert); err != nil {
+			if err := adminServer.Start(metricsAddr, tlsMgr.GetCertificate); err != nil {
 				log.Warn().Err(err).Msg("failed to start metrics admin server")
 			} else {
 				log.Info().
@@ -1821,6 +1965,12 @@ func runJoinWithConfigAndCallback(ctx context.Context, cfg *config.PeerConfig, o
 	// Start heartbeat loop
 	go node.RunHeartbeat(ctx)
 
+	// Start mesh TLS certificate renewal
+	go node.RunCertRenewal(ctx)
+
+	// Start exit peer health checks for failover
+	go node.RunExitHealthCheck(ctx)
+
 	// Show ready message
 	fmt.Fprintf(os.Stderr, "\n  ✓ Connected to mesh as %s (%s)\n", cfg.Name, resp.MeshIP)
 	fmt.Fprintf(os.Stderr, "  Opening https://this.tm in 3 seconds...\n")
@@ -1855,6 +2005,12 @@ func runJoinWithConfigAndCallback(ctx context.Context, cfg *config.PeerConfig, o
 		}
 	}
 
+	// Remove subnet routes and forwarding
+	node.RemoveSubnetRoutes()
+	if len(subnetCfg.Routes) > 0 {
+		_ = tun.RemoveSubnetNAT(subnetCfg)
+	}
+
 	// Close all connections via FSM (properly transitions states and triggers observers)
 	node.Connections.CloseAll()
 
@@ -1949,6 +2105,9 @@ func runStatus(cmd *cobra.Command, args []string) error {
 
 	if myPeer != nil {
 		fmt.Printf("  Mesh IP:     %s\n", myPeer.MeshIP)
+		if myPeer.MeshIPv6 != "" {
+			fmt.Printf("  Mesh IPv6:   %s\n", myPeer.MeshIPv6)
+		}
 		fmt.Printf("  Last Seen:   %s\n", myPeer.LastSeen.Format("2006-01-02 15:04:05"))
 		if myPeer.Connectable {
 			fmt.Println("  Connectable: yes")
@@ -1965,6 +2124,11 @@ func runStatus(cmd *cobra.Command, args []string) error {
 
 	fmt.Println()
 
+	if len(cfg.ExitPeerList()) > 0 || len(cfg.ExitPolicies) > 0 {
+		printExitStatus(cfg, myPeer)
+		fmt.Println()
+	}
+
 	// TUN device info
 	fmt.Println("TUN Device:")
 	fmt.Printf("  Name:        %s\n", cfg.TUN.Name)
@@ -1981,6 +2145,35 @@ func runStatus(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// printExitStatus prints the configured exit peers and the ones in use, as
+// last reported to the coordinator.
+func printExitStatus(cfg *config.PeerConfig, myPeer *proto.Peer) {
+	fmt.Println("Exit Peers:")
+	if exitPeers := cfg.ExitPeerList(); len(exitPeers) > 0 {
+		fmt.Printf("  Configured:  %s\n", strings.Join(exitPeers, ", "))
+	}
+	if myPeer == nil {
+		return
+	}
+	if len(myPeer.ExitPeers) > 0 {
+		active := myPeer.ExitPeer
+		if active == "" {
+			active = "(none healthy, internet traffic is dropped)"
+		}
+		fmt.Printf("  Active:      %s\n", active)
+	}
+	for _, policy := range cfg.ExitPolicies {
+		exit, ok := myPeer.ExitRoutes[policy.Name]
+		switch {
+		case !ok:
+			exit = "(unknown)"
+		case exit == "":
+			exit = "(none healthy, using default)"
+		}
+		fmt.Printf("  Policy:      %s -> %s\n", policy.Name, exit)
+	}
+}
+
 func runPeers(cmd *cobra.Command, args []string) error {
 	setupLogging()
 
@@ -2150,17 +2343,114 @@ func loadConfig() (*config.PeerConfig, error) {
 }
 
 func syncDNS(client *coord.Client, resolver *meshdns.Resolver) error {
-	records, err := client.GetDNSRecords()
+	update, err := client.GetDNS()
 	if err != nil {
 		return err
 	}
 
-	recordMap := make(map[string]string, len(records))
-	for _, r := range records {
-		recordMap[r.Hostname] = r.MeshIP
+	resolver.SyncRecords(update.Records)
+	resolver.SyncZone(update.Zone)
+	return nil
+}
+
+// trimDomain strips the mesh domain suffix from peer names, so both "peer"
+// and "peer.tunnelmesh" can be used in the config.
+func trimDomain(names []string, domain string) []string {
+	trimmed := make([]string, 0, len(names))
+	for _, name := range names {
+		if domain != "" {
+			name = strings.TrimSuffix(name, domain)
+		}
+		trimmed = append(trimmed, name)
 	}
+	return trimmed
+}
 
-	resolver.UpdateRecords(recordMap)
+// buildExitPolicies converts the validated exit policies of the config.
+func buildExitPolicies(policies []config.ExitPolicyConfig, domain string) []routing.ExitPolicy {
+	result := make([]routing.ExitPolicy, 0, len(policies))
+	for _, p := range policies {
+		policy := routing.ExitPolicy{
+			Name:    p.Name,
+			Domains: p.Domains,
+			Peers:   trimDomain(p.ExitPeers, domain),
+			Country: p.Country,
+		}
+		for _, dest := range p.Destinations {
+			if _, ipNet, err := net.ParseCIDR(dest); err == nil {
+				policy.CIDRs = append(policy.CIDRs, ipNet)
+			}
+		}
+		result = append(result, policy)
+	}
+	return result
+}
+
+// startQUICTransport starts the QUIC transport, accepting connections from
+// peers with certificates from the mesh CA, and registers it for dialing.
+func startQUICTransport(ctx context.Context, cfg *config.PeerConfig, tlsMgr *peer.TLSManager, registry *transport.Registry, node *peer.MeshNode) error {
+	quicTransport, err := quictransport.New(quictransport.Config{
+		Port:             cfg.QUIC.Port,
+		Certificate:      tlsMgr.Certificate,
+		VerifyConnection: tlsMgr.VerifyConnection,
+	})
+	if err != nil {
+		return fmt.Errorf("create QUIC transport: %w", err)
+	}
+	if err := quicTransport.Start(); err != nil {
+		return fmt.Errorf("start QUIC transport: %w", err)
+	}
+	listener, err := quicTransport.Listen(ctx, transport.ListenOptions{})
+	if err != nil {
+		_ = quicTransport.Close()
+		return fmt.Errorf("create QUIC listener: %w", err)
+	}
+	if err := registry.Register(quicTransport); err != nil {
+		_ = quicTransport.Close()
+		return fmt.Errorf("register QUIC transport: %w", err)
+	}
+	go node.HandleIncomingQUIC(ctx, listener)
+	// Advertised with the next heartbeat
+	node.QUICPort = cfg.QUIC.Port
+	return nil
+}
+
+// startTLSTransport registers the TLS transport for dialing and, when
+// enabled, starts accepting connections from peers with certificates from
+// the mesh CA, proxying other connections to the fallback.
+func startTLSTransport(ctx context.Context, cfg *config.PeerConfig, tlsMgr *peer.TLSManager, registry *transport.Registry, node *peer.MeshNode) error {
+	tlsConfig := tlstransport.Config{
+		Certificate:      tlsMgr.Certificate,
+		VerifyConnection: tlsMgr.VerifyConnection,
+		Fallback:         cfg.TLSTransport.Fallback,
+	}
+	if cfg.TLSTransport.Enabled && cfg.TLSTransport.CertFile != "" {
+		siteCert, err := tls.LoadX509KeyPair(cfg.TLSTransport.CertFile, cfg.TLSTransport.KeyFile)
+		if err != nil {
+			return fmt.Errorf("load TLS transport site certificate: %w", err)
+		}
+		tlsConfig.SiteCertificate = func() (*tls.Certificate, error) { return &siteCert, nil }
+	}
+	tlsTransport, err := tlstransport.New(tlsConfig)
+	if err != nil {
+		return fmt.Errorf("create TLS transport: %w", err)
+	}
+	if cfg.TLSTransport.Enabled {
+		listener, err := tlsTransport.Listen(ctx, transport.ListenOptions{Port: cfg.TLSTransport.Port})
+		if err != nil {
+			return fmt.Errorf("create TLS listener: %w", err)
+		}
+		go node.HandleIncomingTLS(ctx, listener)
+		// Advertised with the next heartbeat
+		node.TLSPort = cfg.TLSTransport.Port
+		node.TLSServerName = cfg.TLSTransport.ServerName
+	}
+	if err := registry.Register(tlsTransport); err != nil {
+		_ = tlsTransport.Close()
+		node.TLSPort = 0
+		node.TLSServerName = ""
+		return fmt.Errorf("register TLS transport: %w", err)
+	}
 	return nil
 }
 
@@ -2438,7 +2728,115 @@ Domains=~%s
 	return nil
 }
 
+// resolvConfPath and resolvConfBackupPath are used when the mesh resolver
+// replaces /etc/resolv.conf on Linux systems without systemd-resolved.
+const (
+	resolvConfPath       = "/etc/resolv.conf"
+	resolvConfBackupPath = "/etc/resolv.conf.tunnelmesh-backup"
+)
+
+// configureDefaultResolver makes our DNS server the system's default resolver,
+// so it answers mesh names and forwards everything else upstream. Only Linux
+// is supported: via systemd-resolved if available, otherwise by replacing
+// /etc/resolv.conf (which cannot specify a port, so dns.listen must use 53).
+func configureDefaultResolver(dnsAddr string) error {
+	if runtime.GOOS != "linux" {
+		log.Warn().Str("os", runtime.GOOS).Msg("dns.system_default is only supported on Linux, configuring mesh domains only")
+		return configureSystemResolver("", dnsAddr)
+	}
+
+	ip, port, err := net.SplitHostPort(dnsAddr)
+	if err != nil {
+		return fmt.Errorf("invalid DNS address: %s", dnsAddr)
+	}
+
+	if _, err := exec.LookPath("resolvectl"); err == nil {
+		log.Info().Msg("installing default resolver in systemd-resolved (requires sudo)...")
+
+		// "~." routes all queries to this link; the mesh domains are listed
+		// explicitly so they never leak to other links' servers.
+		domains := []string{"~."}
+		for _, suffix := range mesh.AllSuffixes() {
+			domains = append(domains, "~"+strings.TrimPrefix(suffix, "."))
+		}
+		commands := [][]string{
+			{"resolvectl", "dns", "lo", net.JoinHostPort(ip, port)},
+			append([]string{"resolvectl", "domain", "lo"}, domains...),
+			{"resolvectl", "default-route", "lo", "yes"},
+		}
+		for _, args := range commands {
+			cmd := exec.Command("sudo", args...)
+			cmd.Stdin = os.Stdin
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			if err := cmd.Run(); err != nil {
+				return fmt.Errorf("%s failed: %w", strings.Join(args[:2], " "), err)
+			}
+		}
+		log.Info().Msg("systemd-resolved now uses the mesh resolver by default")
+		return nil
+	}
+
+	if port != "53" {
+		return fmt.Errorf("resolv.conf cannot use port %s; set dns.listen to an address on port 53", port)
+	}
+
+	log.Info().Msg("replacing /etc/resolv.conf (requires sudo)...")
+	content := fmt.Sprintf("# Managed by tunnelmesh; the original is in %s\nnameserver %s\n", resolvConfBackupPath, ip)
+
+	// Keep the first backup if a previous run did not restore it
+	if _, err := os.Lstat(resolvConfBackupPath); os.IsNotExist(err) {
+		if err := runPrivileged(nil, "cp", "-P", resolvConfPath, resolvConfBackupPath); err != nil {
+			return fmt.Errorf("back up resolv.conf: %w", err)
+		}
+	}
+	// Remove first so a symlink (e.g. to a stub resolver) is replaced, not followed
+	if err := runPrivileged(nil, "rm", "-f", resolvConfPath); err != nil {
+		return fmt.Errorf("remove resolv.conf: %w", err)
+	}
+	if err := runPrivileged(strings.NewReader(content), "tee", resolvConfPath); err != nil {
+		return fmt.Errorf("write resolv.conf: %w", err)
+	}
+
+	log.Info().Str("file", resolvConfPath).Msg("system resolver configured")
+	return nil
+}
+
+// restoreResolvConf puts back the resolv.conf saved by configureDefaultResolver.
+func restoreResolvConf() error {
+	if _, err := os.Lstat(resolvConfBackupPath); err != nil {
+		return nil
+	}
+	if err := runPrivileged(nil, "mv", "-f", resolvConfBackupPath, resolvConfPath); err != nil {
+		return fmt.Errorf("restore resolv.conf: %w", err)
+	}
+	log.Info().Str("file", resolvConfPath).Msg("original resolv.conf restored")
+	return nil
+}
+
+// runPrivileged runs a command directly when root, otherwise through sudo.
+func runPrivileged(stdin io.Reader, name string, args ...string) error {
+	var cmd *exec.Cmd
+	if os.Geteuid() == 0 {
+		cmd = exec.Command(name, args...)
+	} else {
+		cmd = exec.Command("sudo", append([]string{name}, args...)...)
+	}
+	if stdin != nil {
+		cmd.Stdin = stdin
+	} else {
+		cmd.Stdin = os.Stdin
+	}
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
 func removeLinuxResolver(_ string) error {
+	// Undo dns.system_default if it replaced resolv.conf
+	if err := restoreResolvConf(); err != nil {
+		log.Warn().Err(err).Msg("failed to restore resolv.conf")
+	}
+
 	// Try resolvectl first
 	if _, err := exec.LookPath("resolvectl"); err == nil {
 		cmd := exec.Command("sudo", "resolvectl", "revert", "lo")
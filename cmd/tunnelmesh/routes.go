@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
+)
+
+func newRoutesCmd() *cobra.Command {
+	routesCmd := &cobra.Command{
+		Use:   "routes",
+		Short: "Manage subnet routes advertised by peers",
+		Long: `Manage subnet routes advertised by peers.
+
+A peer lists LAN prefixes in advertise_routes in its config, making the
+networks behind it reachable from the mesh. Other peers only route a prefix
+through it once an admin approves the route on the coordinator.
+
+Examples:
+  # List advertised and approved routes
+  tunnelmesh routes list
+
+  # Route the office LAN through the office peer
+  tunnelmesh routes approve office 192.168.10.0/24
+
+  # Stop routing it
+  tunnelmesh routes revoke office 192.168.10.0/24`,
+	}
+
+	// List subcommand
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List subnet routes",
+		RunE:  runRoutesList,
+	}
+	routesCmd.AddCommand(listCmd)
+
+	// Approve subcommand
+	approveCmd := &cobra.Command{
+		Use:   "approve <peer> <prefix>",
+		Short: "Approve a subnet route advertised by a peer",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(_ *cobra.Command, args []string) error {
+			return postRouteChange("approve", args[0], args[1])
+		},
+	}
+	routesCmd.AddCommand(approveCmd)
+
+	// Revoke subcommand
+	revokeCmd := &cobra.Command{
+		Use:   "revoke <peer> <prefix>",
+		Short: "Revoke the approval of a subnet route",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(_ *cobra.Command, args []string) error {
+			return postRouteChange("revoke", args[0], args[1])
+		},
+	}
+	routesCmd.AddCommand(revokeCmd)
+
+	return routesCmd
+}
+
+func runRoutesList(_ *cobra.Command, _ []string) error {
+	resp, err := makeAdminRequest("GET", getAdminURL()+"/api/routes", nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to list routes: %s", string(body))
+	}
+
+	var routes []proto.SubnetRoute
+	if err := json.NewDecoder(resp.Body).Decode(&routes); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if len(routes) == 0 {
+		fmt.Println("No subnet routes")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "PEER\tPREFIX\tSTATUS")
+	for _, route := range routes {
+		var status string
+		switch {
+		case route.Advertised && route.Approved:
+			status = "active"
+		case route.Advertised:
+			status = "pending approval"
+		default:
+			status = "approved, not advertised"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", route.Peer, route.Prefix, status)
+	}
+	_ = w.Flush()
+
+	return nil
+}
+
+// postRouteChange approves or revokes a subnet route.
+func postRouteChange(action, peerName, prefix string) error {
+	body, _ := json.Marshal(proto.SubnetRoute{Peer: peerName, Prefix: prefix})
+
+	resp, err := makeAdminRequest("POST", getAdminURL()+"/api/routes/"+action, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to %s route: %s", action, string(respBody))
+	}
+
+	if action == "approve" {
+		fmt.Printf("Route %s via %s approved\n", prefix, peerName)
+	} else {
+		fmt.Printf("Route %s via %s revoked\n", prefix, peerName)
+	}
+	return nil
+}
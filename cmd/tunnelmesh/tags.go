@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
+)
+
+func newTagsCmd() *cobra.Command {
+	tagsCmd := &cobra.Command{
+		Use:   "tags",
+		Short: "Manage peer tags",
+		Long: `Manage the tags assigned to peers.
+
+Tags such as tag:prod group peers for access policies: packet filter rules
+select them with source_group and dest_group, RBAC groups list them as
+members, exit peers limit their exit traffic to them with exit_allow_tags,
+and <name>.tag.tunnelmesh resolves to every peer with the tag. Join keys
+can assign tags to the peers joining with them.
+
+Examples:
+  # List tagged peers
+  tunnelmesh tags list
+
+  # Tag a peer, replacing its tags
+  tunnelmesh tags set web-1 tag:prod tag:eu
+
+  # Remove all tags from a peer
+  tunnelmesh tags clear web-1`,
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List tagged peers",
+		RunE:  runTagsList,
+	}
+	tagsCmd.AddCommand(listCmd)
+
+	setCmd := &cobra.Command{
+		Use:   "set <peer> <tag>...",
+		Short: "Set the tags of a peer",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(_ *cobra.Command, args []string) error {
+			return putPeerTags(args[0], args[1:])
+		},
+	}
+	tagsCmd.AddCommand(setCmd)
+
+	clearCmd := &cobra.Command{
+		Use:   "clear <peer>",
+		Short: "Remove all tags from a peer",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			return putPeerTags(args[0], nil)
+		},
+	}
+	tagsCmd.AddCommand(clearCmd)
+
+	return tagsCmd
+}
+
+func runTagsList(_ *cobra.Command, _ []string) error {
+	var tags []proto.PeerTags
+	if err := getAdminJSON("/api/tags", "list tags", &tags); err != nil {
+		return err
+	}
+
+	if len(tags) == 0 {
+		fmt.Println("No tagged peers")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "PEER\tTAGS")
+	for _, peer := range tags {
+		_, _ = fmt.Fprintf(w, "%s\t%s\n", peer.Peer, strings.Join(peer.Tags, ","))
+	}
+	_ = w.Flush()
+
+	return nil
+}
+
+// putPeerTags replaces the tags of a peer.
+func putPeerTags(peerName string, tags []string) error {
+	for _, tag := range tags {
+		if err := proto.ValidateTag(tag); err != nil {
+			return err
+		}
+	}
+	body, _ := json.Marshal(proto.PeerTags{Peer: peerName, Tags: tags})
+
+	resp, err := makeAdminRequest("PUT", getAdminURL()+"/api/tags", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to set tags: %s", string(respBody))
+	}
+
+	if len(tags) == 0 {
+		fmt.Printf("Tags of %s removed\n", peerName)
+	} else {
+		fmt.Printf("Tags of %s set to %s\n", peerName, strings.Join(tags, ","))
+	}
+	return nil
+}
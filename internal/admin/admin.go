@@ -32,13 +32,15 @@ func NewAdminServer() *AdminServer {
 }
 
 // Start starts the admin server with TLS on the given address.
-func (s *AdminServer) Start(addr string, cert *tls.Certificate) error {
+// The certificate is fetched per handshake, so a renewed certificate is
+// served without a restart.
+func (s *AdminServer) Start(addr string, getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)) error {
 	s.server = &http.Server{
 		Addr:    addr,
 		Handler: s.mux,
 		TLSConfig: &tls.Config{
-			Certificates: []tls.Certificate{*cert},
-			MinVersion:   tls.VersionTLS12,
+			GetCertificate: getCertificate,
+			MinVersion:     tls.VersionTLS12,
 		},
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 30 * time.Second,
@@ -172,7 +172,8 @@ func TestAdminServer_StartTLS(t *testing.T) {
 	addr := listener.Addr().String()
 	_ = listener.Close()
 
-	if err := server.Start(addr, cert); err != nil {
+	getCert := func(*tls.ClientHelloInfo) (*tls.Certificate, error) { return cert, nil }
+	if err := server.Start(addr, getCert); err != nil {
 		t.Fatalf("Failed to start TLS server: %v", err)
 	}
 	defer func() { _ = server.Stop() }()
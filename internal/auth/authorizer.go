@@ -62,13 +62,20 @@ func NewAuthorizerWithGroups() *Authorizer {
 // Authorize checks if a peer can perform a verb on a resource in a bucket.
 // The objectKey parameter is used for object-level prefix permission checks.
 // Returns true if any of the peer's direct bindings or group bindings allow the action.
+// Bindings with object tag conditions never match here; use AuthorizeWithTags.
 func (a *Authorizer) Authorize(peerID, verb, resource, bucketName, objectKey string) bool {
+	return a.AuthorizeWithTags(peerID, verb, resource, bucketName, objectKey, nil)
+}
+
+// AuthorizeWithTags is like Authorize but also evaluates object tag conditions
+// on bindings against the object's current tags.
+func (a *Authorizer) AuthorizeWithTags(peerID, verb, resource, bucketName, objectKey string, objectTags map[string]string) bool {
 	// Check direct peer bindings first
-	allowed := a.checkPeerBindings(peerID, verb, resource, bucketName, objectKey)
+	allowed := a.checkPeerBindings(peerID, verb, resource, bucketName, objectKey, objectTags)
 
 	// Check group bindings if groups are enabled and not already allowed
 	if !allowed && a.Groups != nil && a.GroupBindings != nil {
-		allowed = a.checkGroupBindings(peerID, verb, resource, bucketName, objectKey)
+		allowed = a.checkGroupBindings(peerID, verb, resource, bucketName, objectKey, objectTags)
 	}
 
 	// Log authorization decision for security audit (lock-free read)
@@ -86,12 +93,12 @@ func (a *Authorizer) Authorize(peerID, verb, resource, bucketName, objectKey str
 }
 
 // checkPeerBindings checks direct peer role bindings.
-func (a *Authorizer) checkPeerBindings(peerID, verb, resource, bucketName, objectKey string) bool {
+func (a *Authorizer) checkPeerBindings(peerID, verb, resource, bucketName, objectKey string, objectTags map[string]string) bool {
 	bindings := a.Bindings.GetForPeer(peerID)
 
 	for _, binding := range bindings {
-		// Check bucket and object prefix scope
-		if !binding.AppliesToObject(bucketName, objectKey) {
+		// Check bucket, object prefix and object tag scope
+		if !binding.AppliesToObject(bucketName, objectKey) || !binding.AppliesToTags(objectKey, objectTags) {
 			continue
 		}
 
@@ -119,7 +126,7 @@ func (a *Authorizer) checkPeerBindings(peerID, verb, resource, bucketName, objec
 }
 
 // checkGroupBindings checks group-based role bindings.
-func (a *Authorizer) checkGroupBindings(peerID, verb, resource, bucketName, objectKey string) bool {
+func (a *Authorizer) checkGroupBindings(peerID, verb, resource, bucketName, objectKey string, objectTags map[string]string) bool {
 	// Get all groups the peer belongs to
 	peerGroups := a.Groups.GetGroupsForPeer(peerID)
 
@@ -127,8 +134,8 @@ func (a *Authorizer) checkGroupBindings(peerID, verb, resource, bucketName, obje
 		bindings := a.GroupBindings.GetForGroup(groupName)
 
 		for _, binding := range bindings {
-			// Check bucket and object prefix scope
-			if !binding.AppliesToObject(bucketName, objectKey) {
+			// Check bucket, object prefix and object tag scope
+			if !binding.AppliesToObject(bucketName, objectKey) || !binding.AppliesToTags(objectKey, objectTags) {
 				continue
 			}
 
@@ -161,6 +161,19 @@ func TestAuthorizer_AuthorizeWithGroups(t *testing.T) {
 	assert.False(t, auth.Authorize("bob", "get", "objects", "any-bucket", ""))
 }
 
+func TestAuthorizer_AuthorizeWithTaggedGroup(t *testing.T) {
+	auth := NewAuthorizerWithGroups()
+
+	_, _ = auth.Groups.Create("ci", "")
+	_ = auth.Groups.AddMember("ci", "tag:ci")
+	auth.GroupBindings.Add(NewGroupBinding("ci", RoleBucketWrite, "artifacts"))
+	auth.Groups.SetPeerTags("builder", []string{"tag:ci"})
+
+	assert.True(t, auth.Authorize("builder", "put", "objects", "artifacts", ""))
+	assert.False(t, auth.Authorize("builder", "put", "objects", "other", ""))
+	assert.False(t, auth.Authorize("alice", "put", "objects", "artifacts", ""))
+}
+
 func TestAuthorizer_AuthorizeWithScopedGroupBinding(t *testing.T) {
 	auth := NewAuthorizerWithGroups()
 
@@ -294,6 +307,35 @@ func TestAuthorizer_ObjectPrefix_Group(t *testing.T) {
 	assert.False(t, auth.Authorize("bob", "put", "objects", "projects", "teamA/doc.txt"))
 }
 
+func TestAuthorizer_ObjectTags(t *testing.T) {
+	auth := NewAuthorizerWithGroups()
+
+	// Alice can read objects tagged classification=public
+	binding := NewRoleBinding("alice", RoleBucketRead, "projects")
+	binding.ObjectTags = map[string]string{"classification": "public"}
+	auth.Bindings.Add(binding)
+
+	// The ops group can write objects tagged team=ops
+	_, _ = auth.Groups.Create("ops", "Operations")
+	_ = auth.Groups.AddMember("ops", "bob")
+	groupBinding := NewGroupBinding("ops", RoleBucketWrite, "projects")
+	groupBinding.ObjectTags = map[string]string{"team": "ops"}
+	auth.GroupBindings.Add(groupBinding)
+
+	public := map[string]string{"classification": "public", "team": "web"}
+	secret := map[string]string{"classification": "secret"}
+	ops := map[string]string{"team": "ops"}
+
+	assert.True(t, auth.AuthorizeWithTags("alice", "get", "objects", "projects", "doc.txt", public))
+	assert.False(t, auth.AuthorizeWithTags("alice", "get", "objects", "projects", "doc.txt", secret))
+	assert.False(t, auth.AuthorizeWithTags("alice", "get", "objects", "projects", "doc.txt", nil))
+	assert.False(t, auth.Authorize("alice", "get", "objects", "projects", "doc.txt"))
+	assert.True(t, auth.Authorize("alice", "list", "objects", "projects", ""), "bucket-level ops skip tag conditions")
+
+	assert.True(t, auth.AuthorizeWithTags("bob", "put", "objects", "projects", "deploy.sh", ops))
+	assert.False(t, auth.AuthorizeWithTags("bob", "put", "objects", "projects", "deploy.sh", public))
+}
+
 func TestAuthorizer_ObjectPrefix_MultipleBindings(t *testing.T) {
 	auth := NewAuthorizer()
 
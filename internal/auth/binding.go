@@ -10,13 +10,14 @@ import (
 
 // RoleBinding binds a peer to a role with optional bucket, object prefix, or panel scope.
 type RoleBinding struct {
-	Name         string    `json:"name"`                    // Unique binding name
-	PeerID       string    `json:"peer_id"`                 // Peer being granted access
-	RoleName     string    `json:"role_name"`               // Role being granted
-	BucketScope  string    `json:"bucket_scope,omitempty"`  // Optional: scope to specific bucket
-	ObjectPrefix string    `json:"object_prefix,omitempty"` // Optional: scope to object key prefix
-	PanelScope   string    `json:"panel_scope,omitempty"`   // Optional: scope to specific panel ID
-	CreatedAt    time.Time `json:"created_at"`              // When this binding was created
+	Name         string            `json:"name"`                    // Unique binding name
+	PeerID       string            `json:"peer_id"`                 // Peer being granted access
+	RoleName     string            `json:"role_name"`               // Role being granted
+	BucketScope  string            `json:"bucket_scope,omitempty"`  // Optional: scope to specific bucket
+	ObjectPrefix string            `json:"object_prefix,omitempty"` // Optional: scope to object key prefix
+	ObjectTags   map[string]string `json:"object_tags,omitempty"`   // Optional: require these object tags
+	PanelScope   string            `json:"panel_scope,omitempty"`   // Optional: scope to specific panel ID
+	CreatedAt    time.Time         `json:"created_at"`              // When this binding was created
 }
 
 // NewRoleBinding creates a new role binding.
@@ -70,6 +71,15 @@ func (rb *RoleBinding) AppliesToObject(bucket, key string) bool {
 	return true
 }
 
+// AppliesToTags checks this binding's object tag conditions against an object's tags.
+// Empty key (bucket-level operations like list) always passes the tag check.
+func (rb *RoleBinding) AppliesToTags(key string, tags map[string]string) bool {
+	if key == "" {
+		return true
+	}
+	return tagsMatch(rb.ObjectTags, tags)
+}
+
 // AppliesToPanel checks if this binding grants access to a panel.
 // Empty PanelScope means access to all panels (for this role).
 func (rb *RoleBinding) AppliesToPanel(panelID string) bool {
@@ -79,6 +89,16 @@ func (rb *RoleBinding) AppliesToPanel(panelID string) bool {
 	return rb.PanelScope == panelID
 }
 
+// tagsMatch reports whether tags contains every key/value pair in required.
+func tagsMatch(required, tags map[string]string) bool {
+	for k, v := range required {
+		if got, ok := tags[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
 // BindingStore manages role bindings in memory.
 type BindingStore struct {
 	bindings map[string]*RoleBinding // keyed by binding name
@@ -119,6 +119,19 @@ func TestRoleBinding_AppliesToObject(t *testing.T) {
 	}
 }
 
+func TestRoleBinding_AppliesToTags(t *testing.T) {
+	binding := RoleBinding{ObjectTags: map[string]string{"env": "prod", "owner": ""}}
+
+	assert.True(t, binding.AppliesToTags("k", map[string]string{"env": "prod", "owner": "", "x": "y"}))
+	assert.False(t, binding.AppliesToTags("k", map[string]string{"env": "prod"}), "empty required value still requires the key")
+	assert.False(t, binding.AppliesToTags("k", map[string]string{"env": "dev", "owner": ""}))
+	assert.False(t, binding.AppliesToTags("k", nil))
+	assert.True(t, binding.AppliesToTags("", nil), "bucket-level ops always pass")
+
+	unscoped := RoleBinding{}
+	assert.True(t, unscoped.AppliesToTags("k", nil))
+}
+
 func TestBindingStore(t *testing.T) {
 	store := NewBindingStore()
 	require.NotNil(t, store)
@@ -2,6 +2,8 @@ package auth
 
 import (
 	"errors"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 )
@@ -12,6 +14,10 @@ const (
 	GroupAdmins   = "admins"   // All peers with admin role
 )
 
+// TagPrefix starts group members that are peer tags, such as "tag:prod":
+// every peer with the tag is a member of the group.
+const TagPrefix = "tag:"
+
 // Group errors.
 var (
 	ErrGroupExists   = errors.New("group already exists")
@@ -39,16 +45,23 @@ func NewGroup(name, description string) *Group {
 	}
 }
 
+// IsTagMember reports whether a group member is a peer tag.
+func IsTagMember(member string) bool {
+	return strings.HasPrefix(member, TagPrefix)
+}
+
 // GroupStore manages groups in memory.
 type GroupStore struct {
-	groups map[string]*Group
-	mu     sync.RWMutex
+	groups   map[string]*Group
+	peerTags map[string][]string // Peer ID -> tags, for groups with tag members
+	mu       sync.RWMutex
 }
 
 // NewGroupStore creates a new group store with built-in groups.
 func NewGroupStore() *GroupStore {
 	store := &GroupStore{
-		groups: make(map[string]*Group),
+		groups:   make(map[string]*Group),
+		peerTags: make(map[string][]string),
 	}
 
 	// Initialize built-in groups
@@ -152,24 +165,36 @@ func (gs *GroupStore) RemoveMember(groupName, peerID string) error {
 	return nil // Not a member, idempotent
 }
 
-// GetGroupsForPeer returns the names of all groups a peer belongs to.
+// SetPeerTags sets the tags of a peer, which make it a member of the groups
+// listing one of them.
+func (gs *GroupStore) SetPeerTags(peerID string, tags []string) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+
+	if len(tags) == 0 {
+		delete(gs.peerTags, peerID)
+		return
+	}
+	gs.peerTags[peerID] = slices.Clone(tags)
+}
+
+// GetGroupsForPeer returns the names of all groups a peer belongs to,
+// directly or through its tags.
 func (gs *GroupStore) GetGroupsForPeer(peerID string) []string {
 	gs.mu.RLock()
 	defer gs.mu.RUnlock()
 
 	var groups []string
 	for name, group := range gs.groups {
-		for _, member := range group.Members {
-			if member == peerID {
-				groups = append(groups, name)
-				break
-			}
+		if gs.isMemberLocked(group, peerID) {
+			groups = append(groups, name)
 		}
 	}
 	return groups
 }
 
-// IsMember checks if a peer is a member of a group.
+// IsMember checks if a peer is a member of a group, directly or through its
+// tags.
 func (gs *GroupStore) IsMember(groupName, peerID string) bool {
 	gs.mu.RLock()
 	defer gs.mu.RUnlock()
@@ -178,9 +203,15 @@ func (gs *GroupStore) IsMember(groupName, peerID string) bool {
 	if !exists {
 		return false
 	}
+	return gs.isMemberLocked(group, peerID)
+}
 
+// isMemberLocked checks if a peer is a member of a group. Must be called
+// with mu held.
+func (gs *GroupStore) isMemberLocked(group *Group, peerID string) bool {
+	tags := gs.peerTags[peerID]
 	for _, m := range group.Members {
-		if m == peerID {
+		if m == peerID || (IsTagMember(m) && slices.Contains(tags, m)) {
 			return true
 		}
 	}
@@ -239,6 +270,7 @@ func (gs *GroupStore) RemovePeerFromAllGroups(peerID string) {
 	gs.mu.Lock()
 	defer gs.mu.Unlock()
 
+	delete(gs.peerTags, peerID)
 	for _, group := range gs.groups {
 		for i, m := range group.Members {
 			if m == peerID {
@@ -10,13 +10,14 @@ import (
 
 // GroupBinding binds a group to a role with optional bucket, object prefix, or panel scope.
 type GroupBinding struct {
-	Name         string    `json:"name"`                    // Unique binding name
-	GroupName    string    `json:"group_name"`              // Group being granted access
-	RoleName     string    `json:"role_name"`               // Role being granted
-	BucketScope  string    `json:"bucket_scope,omitempty"`  // Optional: scope to specific bucket
-	ObjectPrefix string    `json:"object_prefix,omitempty"` // Optional: scope to object key prefix
-	PanelScope   string    `json:"panel_scope,omitempty"`   // Optional: scope to specific panel ID
-	CreatedAt    time.Time `json:"created_at"`              // When this binding was created
+	Name         string            `json:"name"`                    // Unique binding name
+	GroupName    string            `json:"group_name"`              // Group being granted access
+	RoleName     string            `json:"role_name"`               // Role being granted
+	BucketScope  string            `json:"bucket_scope,omitempty"`  // Optional: scope to specific bucket
+	ObjectPrefix string            `json:"object_prefix,omitempty"` // Optional: scope to object key prefix
+	ObjectTags   map[string]string `json:"object_tags,omitempty"`   // Optional: require these object tags
+	PanelScope   string            `json:"panel_scope,omitempty"`   // Optional: scope to specific panel ID
+	CreatedAt    time.Time         `json:"created_at"`              // When this binding was created
 }
 
 // NewGroupBinding creates a new group binding.
@@ -70,6 +71,15 @@ func (gb *GroupBinding) AppliesToObject(bucket, key string) bool {
 	return true
 }
 
+// AppliesToTags checks this binding's object tag conditions against an object's tags.
+// Empty key (bucket-level operations like list) always passes the tag check.
+func (gb *GroupBinding) AppliesToTags(key string, tags map[string]string) bool {
+	if key == "" {
+		return true
+	}
+	return tagsMatch(gb.ObjectTags, tags)
+}
+
 // AppliesToPanel checks if this binding grants access to a panel.
 // Empty PanelScope means access to all panels (for this role).
 func (gb *GroupBinding) AppliesToPanel(panelID string) bool {
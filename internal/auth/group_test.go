@@ -155,6 +155,32 @@ func TestGroupStore_IsMember(t *testing.T) {
 	assert.False(t, store.IsMember("nonexistent", "alice"))
 }
 
+func TestGroupStore_TagMembers(t *testing.T) {
+	store := NewGroupStore()
+
+	_, _ = store.Create("production", "")
+	_ = store.AddMember("production", "tag:prod")
+	_ = store.AddMember("production", "carol")
+
+	store.SetPeerTags("alice", []string{"tag:ci", "tag:prod"})
+	store.SetPeerTags("bob", []string{"tag:ci"})
+
+	assert.True(t, store.IsMember("production", "alice"))
+	assert.False(t, store.IsMember("production", "bob"))
+	assert.True(t, store.IsMember("production", "carol"))
+	assert.Equal(t, []string{"production"}, store.GetGroupsForPeer("alice"))
+	assert.Empty(t, store.GetGroupsForPeer("bob"))
+
+	// Removing the tag removes the membership
+	store.SetPeerTags("alice", nil)
+	assert.False(t, store.IsMember("production", "alice"))
+
+	// Expiring a peer drops its tags
+	store.SetPeerTags("bob", []string{"tag:prod"})
+	store.RemovePeerFromAllGroups("bob")
+	assert.False(t, store.IsMember("production", "bob"))
+}
+
 func TestGroupStore_BuiltinGroups(t *testing.T) {
 	store := NewGroupStore()
 
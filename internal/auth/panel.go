@@ -23,6 +23,7 @@ const (
 	PanelGroups     = "groups"
 	PanelBindings   = "bindings"
 	PanelDocker     = "docker"
+	PanelKeys       = "keys"
 )
 
 // Panel tabs
@@ -94,6 +95,7 @@ func (r *PanelRegistry) registerBuiltinPanels() {
 		{ID: PanelPeerMgmt, Name: "Peers", Tab: PanelTabData, Category: PanelCategoryAdmin, SortOrder: 10, Builtin: true},
 		{ID: PanelGroups, Name: "Groups", Tab: PanelTabData, Category: PanelCategoryAdmin, SortOrder: 20, Builtin: true},
 		{ID: PanelBindings, Name: "Role Bindings", Tab: PanelTabData, Category: PanelCategoryAdmin, SortOrder: 30, Builtin: true},
+		{ID: PanelKeys, Name: "Join Keys", Tab: PanelTabData, Category: PanelCategoryAdmin, SortOrder: 35, Builtin: true},
 		{ID: PanelDNS, Name: "DNS Records", Tab: PanelTabData, Category: PanelCategoryNetwork, SortOrder: 40, Builtin: true},
 	}
 
@@ -322,7 +324,7 @@ func BuiltinPanelIDs() []string {
 	return []string{
 		PanelVisualizer, PanelMap, PanelAlerts, PanelPeers,
 		PanelLogs, PanelWireGuard, PanelFilter, PanelDNS,
-		PanelS3, PanelShares, PanelPeerMgmt, PanelGroups, PanelBindings, PanelDocker,
+		PanelS3, PanelShares, PanelPeerMgmt, PanelGroups, PanelBindings, PanelDocker, PanelKeys,
 	}
 }
 
@@ -338,6 +340,6 @@ func DefaultPeerPanels() []string {
 func DefaultAdminPanels() []string {
 	return []string{
 		PanelPeers, PanelLogs, PanelWireGuard, PanelFilter, PanelDNS,
-		PanelPeerMgmt, PanelGroups, PanelBindings, PanelDocker,
+		PanelPeerMgmt, PanelGroups, PanelBindings, PanelDocker, PanelKeys,
 	}
 }
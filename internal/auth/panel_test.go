@@ -14,7 +14,7 @@ func TestPanelRegistry_BuiltinPanels(t *testing.T) {
 
 	// Should have all built-in panels
 	panels := registry.List()
-	assert.Len(t, panels, 14) // visualizer, map, alerts, peers, logs, wireguard, filter, dns, s3, shares, users, groups, bindings, docker
+	assert.Len(t, panels, 15) // visualizer, map, alerts, peers, logs, wireguard, filter, dns, s3, shares, users, groups, bindings, docker, keys
 
 	// Check specific panels exist
 	assert.NotNil(t, registry.Get(PanelVisualizer))
@@ -42,8 +42,8 @@ func TestPanelRegistry_ListByTab(t *testing.T) {
 	// App tab should have: s3, shares, docker
 	assert.Len(t, appPanels, 3)
 
-	// Data tab should have: peers-mgmt, groups, bindings, dns
-	assert.Len(t, dataPanels, 4)
+	// Data tab should have: peers-mgmt, groups, bindings, keys, dns
+	assert.Len(t, dataPanels, 5)
 
 	// Mesh tab should have: visualizer, map, alerts, peers, logs, wireguard, filter
 	assert.Len(t, meshPanels, 7)
@@ -222,7 +222,7 @@ func TestAuthorizer_GetAccessiblePanels_Admin(t *testing.T) {
 	auth.Bindings.Add(NewRoleBinding("alice", RoleAdmin, ""))
 
 	panels := auth.GetAccessiblePanels("alice")
-	assert.Len(t, panels, 14) // All built-in panels
+	assert.Len(t, panels, 15) // All built-in panels
 }
 
 func TestAuthorizer_GetAccessiblePanels_DirectBindings(t *testing.T) {
@@ -284,7 +284,7 @@ func TestAuthorizer_GetAccessiblePanels_AdminViaGroup(t *testing.T) {
 
 	// admins should get all panels via IsAdmin check
 	panels := auth.GetAccessiblePanels("alice")
-	assert.Len(t, panels, 14) // All panels
+	assert.Len(t, panels, 15) // All panels
 }
 
 // --- Default panel tests ---
@@ -82,7 +82,7 @@ func BuiltinRoles() []Role {
 			Name:    RoleBucketAdmin,
 			Builtin: true,
 			Rules: []Rule{
-				{Verbs: []string{"create", "delete", "get", "list"}, Resources: []string{"buckets"}},
+				{Verbs: []string{"create", "delete", "get", "list", "put"}, Resources: []string{"buckets"}},
 				{Verbs: []string{"*"}, Resources: []string{"objects"}},
 			},
 		},
@@ -3,12 +3,17 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/netip"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/rs/zerolog"
+	"github.com/tunnelmesh/tunnelmesh/internal/mesh"
 	"github.com/tunnelmesh/tunnelmesh/pkg/bytesize"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
 	"gopkg.in/yaml.v3"
 )
 
@@ -48,20 +53,32 @@ type CoordinatorConfig struct {
 	Filter             FilterConfig          `yaml:"filter"`               // Global packet filter rules for all peers
 	ServicePorts       []uint16              `yaml:"service_ports"`        // Service ports to auto-allow on peers (default: [9443] for metrics)
 	LandingPage        string                `yaml:"landing_page"`         // Path to custom landing page HTML file (default: built-in)
+	CertLifetime       string                `yaml:"cert_lifetime"`        // Lifetime of peer TLS certificates, renewed automatically (default: 8760h, e.g. 24h for short-lived)
+	PSK                PSKConfig             `yaml:"psk"`                  // Pre-shared keys mixed into UDP handshakes
+	RequireJoinKeys    bool                  `yaml:"require_join_keys"`    // Refuse the mesh token for registering peers other than coordinators
+}
+
+// PSKConfig configures the pre-shared keys the coordinator distributes for
+// UDP handshakes, a symmetric layer on top of the Noise key exchange.
+type PSKConfig struct {
+	Enabled          bool       `yaml:"enabled"`           // Distribute a mesh-wide PSK
+	RotationInterval string     `yaml:"rotation_interval"` // How often keys are rotated (default: 24h, minimum 10m)
+	Pairs            [][]string `yaml:"pairs"`             // Peer pairs given their own PSK instead of the mesh-wide one
 }
 
 // S3Config holds configuration for the S3-compatible storage service.
 // S3 is always enabled when coordinator is enabled (port hardcoded to 9000).
 type S3Config struct {
-	DataDir                 string                 `yaml:"data_dir"`                   // Storage directory for S3 objects (default: {data_dir}/s3)
-	MaxSize                 bytesize.Size          `yaml:"max_size"`                   // Maximum storage size (e.g., "10Gi", "500Mi") - defaults to 1Gi
-	ObjectExpiryDays        int                    `yaml:"object_expiry_days"`         // Days until objects expire (default: 9125 = 25 years)
-	ShareExpiryDays         int                    `yaml:"share_expiry_days"`          // Days until file shares expire (default: 365 = 1 year)
-	RecycleBinRetentionDays int                    `yaml:"recycle_bin_retention_days"` // Days to keep recycled items before deletion (default: 90)
-	VersionRetentionDays    int                    `yaml:"version_retention_days"`     // Days to keep object versions (default: 30)
-	MaxVersionsPerObject    int                    `yaml:"max_versions_per_object"`    // Max versions to keep per object (default: 100, 0 = unlimited)
-	VersionRetention        VersionRetentionConfig `yaml:"version_retention"`          // Tiered version retention policy
-	DefaultShareQuota       bytesize.Size          `yaml:"default_share_quota"`        // Auto-share quota per peer (default: 10Mi)
+	DataDir                   string                 `yaml:"data_dir"`                     // Storage directory for S3 objects (default: {data_dir}/s3)
+	MaxSize                   bytesize.Size          `yaml:"max_size"`                     // Maximum storage size (e.g., "10Gi", "500Mi") - defaults to 1Gi
+	ObjectExpiryDays          int                    `yaml:"object_expiry_days"`           // Days until objects expire (default: 9125 = 25 years)
+	ShareExpiryDays           int                    `yaml:"share_expiry_days"`            // Days until file shares expire (default: 365 = 1 year)
+	RecycleBinRetentionDays   int                    `yaml:"recycle_bin_retention_days"`   // Days to keep recycled items before deletion (default: 90)
+	MultipartUploadExpiryDays int                    `yaml:"multipart_upload_expiry_days"` // Days before incomplete multipart uploads are aborted (default: 7)
+	VersionRetentionDays      int                    `yaml:"version_retention_days"`       // Days to keep object versions (default: 30)
+	MaxVersionsPerObject      int                    `yaml:"max_versions_per_object"`      // Max versions to keep per object (default: 100, 0 = unlimited)
+	VersionRetention          VersionRetentionConfig `yaml:"version_retention"`            // Tiered version retention policy
+	DefaultShareQuota         bytesize.Size          `yaml:"default_share_quota"`          // Auto-share quota per peer (default: 10Mi)
 }
 
 // VersionRetentionConfig configures smart tiered version retention.
@@ -83,6 +100,28 @@ type WireGuardPeerConfig struct {
 	SyncInterval string `yaml:"sync_interval"` // Config sync interval (default: "30s")
 }
 
+// UDPTransportConfig holds configuration for the UDP transport.
+type UDPTransportConfig struct {
+	AllowHandshakesWithoutMACs bool `yaml:"allow_handshakes_without_macs"` // Accept handshakes from peers predating handshake MACs when not under load
+}
+
+// QUICPeerConfig holds configuration for the QUIC transport.
+type QUICPeerConfig struct {
+	Enabled bool `yaml:"enabled"` // Accept and dial QUIC connections (requires a mesh TLS certificate)
+	Port    int  `yaml:"port"`    // QUIC UDP port (default: ssh_port + 2)
+}
+
+// TLSTransportConfig holds configuration for accepting TLS transport
+// connections. Peers with a mesh TLS certificate always dial it.
+type TLSTransportConfig struct {
+	Enabled    bool   `yaml:"enabled"`     // Accept TLS connections from peers (requires a mesh TLS certificate)
+	Port       int    `yaml:"port"`        // TCP port (default: 443)
+	ServerName string `yaml:"server_name"` // Web site the port serves, sent by peers in their TLS handshakes
+	CertFile   string `yaml:"cert_file"`   // Certificate of that web site (default: the mesh certificate)
+	KeyFile    string `yaml:"key_file"`    // Private key of that certificate
+	Fallback   string `yaml:"fallback"`    // HTTPS server (host:port) of that web site, which other connections are proxied to
+}
+
 // PeerConfig holds configuration for a peer node.
 type PeerConfig struct {
 	Name    string   `yaml:"name"`
@@ -101,13 +140,21 @@ type PeerConfig struct {
 	TUN               TUNConfig           `yaml:"tun"`
 	DNS               DNSConfig           `yaml:"dns"`
 	WireGuard         WireGuardPeerConfig `yaml:"wireguard"`
-	Geolocation       GeolocationConfig   `yaml:"geolocation"`        // Manual geolocation coordinates
-	ExitPeer          string              `yaml:"exit_peer"`          // Name of peer to route internet traffic through
-	AllowExitTraffic  bool                `yaml:"allow_exit_traffic"` // Allow this peer to act as exit peer for other peers
-	Filter            FilterConfig        `yaml:"filter"`             // Local packet filter rules
-	Loki              LokiConfig          `yaml:"loki"`               // Loki log shipping configuration
-	Docker            DockerConfig        `yaml:"docker"`             // Docker container orchestration
-	Coordinator       CoordinatorConfig   `yaml:"coordinator"`        // Coordinator services (optional, auto-enabled if admin)
+	UDP               UDPTransportConfig  `yaml:"udp"`                        // UDP transport
+	QUIC              QUICPeerConfig      `yaml:"quic"`                       // QUIC transport
+	TLSTransport      TLSTransportConfig  `yaml:"tls_transport"`              // TLS over TCP transport for networks that only allow HTTPS
+	Geolocation       GeolocationConfig   `yaml:"geolocation"`                // Manual geolocation coordinates
+	ExitPeer          string              `yaml:"exit_peer"`                  // Name of peer to route internet traffic through
+	ExitPeers         []string            `yaml:"exit_peers,omitempty"`       // Further exit peers to fail over to, in order of preference
+	ExitPolicies      []ExitPolicyConfig  `yaml:"exit_policies,omitempty"`    // Exit peers for specific destinations
+	AllowExitTraffic  bool                `yaml:"allow_exit_traffic"`         // Allow this peer to act as exit peer for other peers
+	ExitAllowTags     []string            `yaml:"exit_allow_tags,omitempty"`  // Only peers with one of these tags may use this exit peer (default: all)
+	AllowRelay        bool                `yaml:"allow_relay"`                // Relay traffic between peers that cannot connect directly
+	AdvertiseRoutes   []string            `yaml:"advertise_routes,omitempty"` // LAN prefixes this peer routes into the mesh (CIDR), once approved by an admin
+	Filter            FilterConfig        `yaml:"filter"`                     // Local packet filter rules
+	Loki              LokiConfig          `yaml:"loki"`                       // Loki log shipping configuration
+	Docker            DockerConfig        `yaml:"docker"`                     // Docker container orchestration
+	Coordinator       CoordinatorConfig   `yaml:"coordinator"`                // Coordinator services (optional, auto-enabled if admin)
 }
 
 // TUNConfig holds configuration for the TUN interface.
@@ -119,9 +166,28 @@ type TUNConfig struct {
 // DNSConfig holds configuration for the local DNS resolver.
 // DNS is always enabled for all peers.
 type DNSConfig struct {
-	Listen   string   `yaml:"listen"`
-	CacheTTL int      `yaml:"cache_ttl"`
-	Aliases  []string `yaml:"aliases,omitempty"` // Custom DNS aliases for this peer
+	Listen        string             `yaml:"listen"`
+	CacheTTL      int                `yaml:"cache_ttl"`
+	Aliases       []string           `yaml:"aliases,omitempty"`        // Custom DNS aliases for this peer
+	Services      []ServiceConfig    `yaml:"services,omitempty"`       // Services advertised as DNS SRV records
+	Upstreams     []string           `yaml:"upstreams,omitempty"`      // Servers for non-mesh names: "1.1.1.1", "tcp://", "tls://", "https://"
+	Forward       []DNSForwardConfig `yaml:"forward,omitempty"`        // Per-domain upstreams (split-horizon)
+	SystemDefault bool               `yaml:"system_default,omitempty"` // Install as the system's default resolver (Linux)
+}
+
+// DNSForwardConfig sends queries for a domain and its subdomains to specific
+// upstream servers, e.g. a corporate resolver reachable through an exit peer.
+type DNSForwardConfig struct {
+	Domain    string   `yaml:"domain"`
+	Upstreams []string `yaml:"upstreams"`
+}
+
+// ServiceConfig is a service this peer advertises to the mesh, resolvable as
+// _name._protocol.peer.tunnelmesh SRV records.
+type ServiceConfig struct {
+	Name     string `yaml:"name"`     // Service name without the leading underscore, e.g. "http"
+	Protocol string `yaml:"protocol"` // "tcp" (default) or "udp"
+	Port     int    `yaml:"port"`
 }
 
 // GeolocationConfig holds manual geolocation coordinates for a peer.
@@ -129,6 +195,17 @@ type GeolocationConfig struct {
 	Latitude  float64 `yaml:"latitude"`  // Manual latitude (-90 to 90)
 	Longitude float64 `yaml:"longitude"` // Manual longitude (-180 to 180)
 	City      string  `yaml:"city"`      // Optional city name for display
+	Country   string  `yaml:"country"`   // Optional country name, for exit policies selecting by country
+}
+
+// ExitPolicyConfig routes traffic for some destinations through specific
+// exit peers instead of the default ones.
+type ExitPolicyConfig struct {
+	Name         string   `yaml:"name"`                   // Policy name, shown in status output
+	Destinations []string `yaml:"destinations,omitempty"` // Destination prefixes (CIDR)
+	Domains      []string `yaml:"domains,omitempty"`      // Destination domains, including subdomains
+	ExitPeers    []string `yaml:"exit_peers,omitempty"`   // Exit peers in order of preference
+	Country      string   `yaml:"country,omitempty"`      // Then any exit peer located in this country
 }
 
 // LokiConfig holds configuration for shipping logs to Loki.
@@ -147,19 +224,29 @@ type DockerConfig struct {
 
 // FilterRule represents a single packet filter rule for config files.
 type FilterRule struct {
-	Port       uint16 `yaml:"port"`        // Port number (1-65535)
-	Protocol   string `yaml:"protocol"`    // "tcp" or "udp"
-	Action     string `yaml:"action"`      // "allow" or "deny"
-	SourcePeer string `yaml:"source_peer"` // Source peer name (empty = any peer)
+	Port        uint16 `yaml:"port"`         // Port number (1-65535), first of a range with port_end
+	PortEnd     uint16 `yaml:"port_end"`     // Last port of a range (optional)
+	Protocol    string `yaml:"protocol"`     // "tcp", "udp", "icmp" or "icmpv6"
+	ICMPType    uint8  `yaml:"icmp_type"`    // ICMP message type, e.g. 8 for ping (0 = any type)
+	Action      string `yaml:"action"`       // "allow" or "deny"
+	SourcePeer  string `yaml:"source_peer"`  // Source peer name (empty = any peer)
+	SourceCIDR  string `yaml:"source_cidr"`  // Source address prefix (empty = any address)
+	SourceGroup string `yaml:"source_group"` // RBAC group of the source peer (empty = any peer)
+	DestGroup   string `yaml:"dest_group"`   // RBAC group the receiving peer must be in (empty = every peer)
 }
 
-// ProtocolNumber returns the IP protocol number (6 for TCP, 17 for UDP).
+// ProtocolNumber returns the IP protocol number (6 for TCP, 17 for UDP, 1 for
+// ICMP, 58 for ICMPv6).
 func (r *FilterRule) ProtocolNumber() uint8 {
 	switch strings.ToLower(r.Protocol) {
 	case "tcp":
 		return 6
 	case "udp":
 		return 17
+	case "icmp":
+		return 1
+	case "icmpv6":
+		return 58
 	default:
 		return 0
 	}
@@ -168,8 +255,9 @@ func (r *FilterRule) ProtocolNumber() uint8 {
 // FilterConfig holds packet filter configuration.
 // When default_deny is true (allowlist mode), all ports are blocked unless explicitly allowed.
 type FilterConfig struct {
-	DefaultDeny *bool        `yaml:"default_deny"` // Block all by default (default: true)
-	Rules       []FilterRule `yaml:"rules"`        // Filter rules
+	DefaultDeny     *bool        `yaml:"default_deny"`      // Block all by default (default: true)
+	ICMPDefaultDeny bool         `yaml:"icmp_default_deny"` // Apply default_deny to ICMP too (default: false, ICMP allowed unless denied)
+	Rules           []FilterRule `yaml:"rules"`             // Filter rules
 }
 
 // IsDefaultDeny returns whether the filter defaults to denying traffic.
@@ -181,12 +269,30 @@ func (f *FilterConfig) IsDefaultDeny() bool {
 // Validate checks if filter configuration is valid.
 func (f *FilterConfig) Validate() error {
 	for i, rule := range f.Rules {
-		if rule.Port == 0 {
-			return fmt.Errorf("filter.rules[%d].port must be between 1 and 65535 (got 0)", i)
+		switch strings.ToLower(rule.Protocol) {
+		case "tcp", "udp":
+			if rule.Port == 0 {
+				return fmt.Errorf("filter.rules[%d].port must be between 1 and 65535 (got 0)", i)
+			}
+			if rule.PortEnd != 0 && rule.PortEnd < rule.Port {
+				return fmt.Errorf("filter.rules[%d].port_end %d is below port %d", i, rule.PortEnd, rule.Port)
+			}
+			if rule.ICMPType != 0 {
+				return fmt.Errorf("filter.rules[%d].icmp_type requires protocol icmp or icmpv6", i)
+			}
+		case "icmp", "icmpv6":
+			if rule.Port != 0 || rule.PortEnd != 0 {
+				return fmt.Errorf("filter.rules[%d]: ports do not apply to protocol %s", i, rule.Protocol)
+			}
+		default:
+			return fmt.Errorf("filter.rules[%d]: invalid protocol %q - must be one of: tcp, udp, icmp, icmpv6", i, rule.Protocol)
 		}
-		proto := strings.ToLower(rule.Protocol)
-		if proto != "tcp" && proto != "udp" {
-			return fmt.Errorf("filter.rules[%d]: invalid protocol %q - must be one of: tcp, udp", i, rule.Protocol)
+		if rule.SourceCIDR != "" {
+			if _, err := netip.ParsePrefix(rule.SourceCIDR); err != nil {
+				if _, err := netip.ParseAddr(rule.SourceCIDR); err != nil {
+					return fmt.Errorf("filter.rules[%d].source_cidr %q is not an IP address or CIDR", i, rule.SourceCIDR)
+				}
+			}
 		}
 		if rule.Action != "allow" && rule.Action != "deny" {
 			return fmt.Errorf("filter.rules[%d]: invalid action %q - must be one of: allow, deny", i, rule.Action)
@@ -291,6 +397,16 @@ func LoadPeerConfig(path string) (*PeerConfig, error) {
 		}
 	}
 
+	// QUIC transport defaults
+	if cfg.QUIC.Enabled && cfg.QUIC.Port == 0 {
+		cfg.QUIC.Port = cfg.SSHPort + 2
+	}
+
+	// TLS transport defaults
+	if cfg.TLSTransport.Enabled && cfg.TLSTransport.Port == 0 {
+		cfg.TLSTransport.Port = 443
+	}
+
 	// Docker defaults
 	if cfg.Docker.Socket == "" {
 		cfg.Docker.Socket = "unix:///var/run/docker.sock"
@@ -334,6 +450,9 @@ func LoadPeerConfig(path string) (*PeerConfig, error) {
 		if cfg.Coordinator.S3.RecycleBinRetentionDays == 0 {
 			cfg.Coordinator.S3.RecycleBinRetentionDays = 90
 		}
+		if cfg.Coordinator.S3.MultipartUploadExpiryDays == 0 {
+			cfg.Coordinator.S3.MultipartUploadExpiryDays = 7
+		}
 		if cfg.Coordinator.S3.VersionRetentionDays == 0 {
 			cfg.Coordinator.S3.VersionRetentionDays = 30
 		}
@@ -380,6 +499,30 @@ func (c *PeerConfig) Validate() error {
 	if c.SSHPort <= 0 || c.SSHPort > 65535 {
 		return fmt.Errorf("ssh_port must be between 1 and 65535")
 	}
+	if c.QUIC.Enabled {
+		if c.QUIC.Port <= 0 || c.QUIC.Port > 65535 {
+			return fmt.Errorf("quic.port must be between 1 and 65535")
+		}
+		if c.QUIC.Port == c.SSHPort+1 {
+			return fmt.Errorf("quic.port must differ from the UDP transport port (ssh_port + 1)")
+		}
+	}
+	if c.TLSTransport.Enabled {
+		if c.TLSTransport.Port <= 0 || c.TLSTransport.Port > 65535 {
+			return fmt.Errorf("tls_transport.port must be between 1 and 65535")
+		}
+		if c.TLSTransport.Port == c.SSHPort {
+			return fmt.Errorf("tls_transport.port must differ from ssh_port")
+		}
+		if c.TLSTransport.Fallback != "" {
+			if _, _, err := net.SplitHostPort(c.TLSTransport.Fallback); err != nil {
+				return fmt.Errorf("tls_transport.fallback must be host:port: %w", err)
+			}
+		}
+		if (c.TLSTransport.CertFile == "") != (c.TLSTransport.KeyFile == "") {
+			return fmt.Errorf("tls_transport.cert_file and tls_transport.key_file must be set together")
+		}
+	}
 	if c.TUN.MTU < 576 || c.TUN.MTU > 65535 {
 		return fmt.Errorf("tun.mtu must be between 576 and 65535")
 	}
@@ -393,6 +536,26 @@ func (c *PeerConfig) Validate() error {
 	if err := c.DNS.ValidateAliases(c.Name); err != nil {
 		return err
 	}
+	if err := c.DNS.ValidateServices(); err != nil {
+		return err
+	}
+	if err := c.DNS.ValidateForwarding(); err != nil {
+		return err
+	}
+	if err := c.ValidateAdvertiseRoutes(); err != nil {
+		return err
+	}
+	if err := c.ValidateExitPolicies(); err != nil {
+		return err
+	}
+	if len(c.ExitAllowTags) > 0 {
+		if !c.AllowExitTraffic {
+			return fmt.Errorf("exit_allow_tags requires allow_exit_traffic")
+		}
+		if _, err := proto.NormalizeTags(c.ExitAllowTags); err != nil {
+			return fmt.Errorf("exit_allow_tags: %w", err)
+		}
+	}
 	// Validate filter config
 	if err := c.Filter.Validate(); err != nil {
 		return err
@@ -409,6 +572,82 @@ func (c *PeerConfig) Validate() error {
 	return nil
 }
 
+// ValidateAdvertiseRoutes checks that advertised routes are IPv4 network
+// prefixes in CIDR notation outside the mesh, and that none is listed twice.
+func (c *PeerConfig) ValidateAdvertiseRoutes() error {
+	if len(c.AdvertiseRoutes) > proto.MaxRoutes {
+		return fmt.Errorf("advertise_routes: too many routes (max %d)", proto.MaxRoutes)
+	}
+	_, meshNet, _ := net.ParseCIDR(mesh.CIDR)
+	seen := make(map[string]bool, len(c.AdvertiseRoutes))
+	for _, route := range c.AdvertiseRoutes {
+		ipNet, err := proto.ParseRoute(route)
+		if err != nil {
+			return fmt.Errorf("advertise_routes: %w", err)
+		}
+		if ipNet.String() != route {
+			return fmt.Errorf("advertise_routes: %q is not a network address, did you mean %q?", route, ipNet.String())
+		}
+		if ipNet.Contains(meshNet.IP) || meshNet.Contains(ipNet.IP) {
+			return fmt.Errorf("advertise_routes: %q overlaps the mesh network %s", route, mesh.CIDR)
+		}
+		if seen[route] {
+			return fmt.Errorf("advertise_routes: duplicate route %q", route)
+		}
+		seen[route] = true
+	}
+	return nil
+}
+
+// ExitPeerList returns the exit peers in order of preference: exit_peer
+// followed by exit_peers, without duplicates.
+func (c *PeerConfig) ExitPeerList() []string {
+	var peers []string
+	for _, peer := range append([]string{c.ExitPeer}, c.ExitPeers...) {
+		if peer != "" && !slices.Contains(peers, peer) {
+			peers = append(peers, peer)
+		}
+	}
+	return peers
+}
+
+// ValidateExitPolicies checks that each exit policy selects destinations and
+// exit peers. Domain policies only see traffic when this peer resolves all
+// names and sends all internet traffic through the mesh, so they require
+// dns.system_default and default exit peers.
+func (c *PeerConfig) ValidateExitPolicies() error {
+	seen := make(map[string]bool, len(c.ExitPolicies))
+	for i, policy := range c.ExitPolicies {
+		if policy.Name == "" {
+			return fmt.Errorf("exit_policies[%d]: name is required", i)
+		}
+		if seen[policy.Name] {
+			return fmt.Errorf("exit_policies: duplicate name %q", policy.Name)
+		}
+		seen[policy.Name] = true
+		if len(policy.Destinations) == 0 && len(policy.Domains) == 0 {
+			return fmt.Errorf("exit_policies %q: destinations or domains are required", policy.Name)
+		}
+		if len(policy.ExitPeers) == 0 && policy.Country == "" {
+			return fmt.Errorf("exit_policies %q: exit_peers or country is required", policy.Name)
+		}
+		for _, dest := range policy.Destinations {
+			if _, _, err := net.ParseCIDR(dest); err != nil {
+				return fmt.Errorf("exit_policies %q: invalid destination %q: %w", policy.Name, dest, err)
+			}
+		}
+		for _, domain := range policy.Domains {
+			if err := validateDNSLabel(strings.TrimSuffix(domain, ".")); err != nil {
+				return fmt.Errorf("exit_policies %q: domain %q is invalid: %w", policy.Name, domain, err)
+			}
+		}
+		if len(policy.Domains) > 0 && (!c.DNS.SystemDefault || len(c.ExitPeerList()) == 0) {
+			return fmt.Errorf("exit_policies %q: domains require dns.system_default and exit_peer", policy.Name)
+		}
+	}
+	return nil
+}
+
 // PrimaryServer returns the first server in the Servers list, or empty string if none configured.
 // This provides safe access to the primary coordinator without risking index out of bounds.
 func (c *PeerConfig) PrimaryServer() string {
@@ -436,6 +675,82 @@ func (d *DNSConfig) ValidateAliases(peerName string) error {
 	return nil
 }
 
+// ValidateServices checks that advertised services have valid names, protocols
+// and ports, and that no service is listed twice.
+func (d *DNSConfig) ValidateServices() error {
+	seen := make(map[string]bool)
+	for _, svc := range d.Services {
+		if len(svc.Name) > 15 {
+			return fmt.Errorf("dns.services: %q exceeds 15 characters", svc.Name)
+		}
+		if err := validateDNSLabel(svc.Name); err != nil || strings.Contains(svc.Name, ".") {
+			return fmt.Errorf("dns.services: %q is not a valid service name", svc.Name)
+		}
+		protocol := svc.Protocol
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		if protocol != "tcp" && protocol != "udp" {
+			return fmt.Errorf("dns.services: %q protocol must be tcp or udp", svc.Name)
+		}
+		if svc.Port <= 0 || svc.Port > 65535 {
+			return fmt.Errorf("dns.services: %q port must be between 1 and 65535", svc.Name)
+		}
+		key := svc.Name + "/" + protocol
+		if seen[key] {
+			return fmt.Errorf("dns.services: duplicate service %q", key)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// ValidateForwarding checks upstream server addresses and forwarding rules.
+func (d *DNSConfig) ValidateForwarding() error {
+	for _, upstream := range d.Upstreams {
+		if err := validateUpstream(upstream); err != nil {
+			return fmt.Errorf("dns.upstreams: %w", err)
+		}
+	}
+	for _, rule := range d.Forward {
+		if rule.Domain == "" {
+			return fmt.Errorf("dns.forward: domain is required")
+		}
+		if len(rule.Upstreams) == 0 {
+			return fmt.Errorf("dns.forward: %q needs at least one upstream", rule.Domain)
+		}
+		for _, upstream := range rule.Upstreams {
+			if err := validateUpstream(upstream); err != nil {
+				return fmt.Errorf("dns.forward: %q: %w", rule.Domain, err)
+			}
+		}
+	}
+	if d.SystemDefault && len(d.Upstreams) == 0 {
+		return fmt.Errorf("dns.system_default requires dns.upstreams")
+	}
+	return nil
+}
+
+// validateUpstream checks the scheme of an upstream DNS server address.
+func validateUpstream(upstream string) error {
+	if upstream == "" {
+		return fmt.Errorf("empty upstream")
+	}
+	scheme, rest, ok := strings.Cut(upstream, "://")
+	if !ok {
+		return nil // Plain host[:port]
+	}
+	switch scheme {
+	case "udp", "tcp", "tls", "https":
+	default:
+		return fmt.Errorf("%q: scheme must be udp, tcp, tls or https", upstream)
+	}
+	if rest == "" {
+		return fmt.Errorf("%q: missing host", upstream)
+	}
+	return nil
+}
+
 // validateDNSLabel checks if a string is a valid DNS label (RFC 1123).
 func validateDNSLabel(label string) error {
 	if len(label) == 0 {
@@ -124,6 +124,27 @@ func TestPeerConfig_Validate(t *testing.T) {
 			modify:  func(c *PeerConfig) { c.TUN.MTU = 100 },
 			wantErr: true,
 		},
+		{
+			name: "exit allow tags",
+			modify: func(c *PeerConfig) {
+				c.AllowExitTraffic = true
+				c.ExitAllowTags = []string{"tag:staff"}
+			},
+			wantErr: false,
+		},
+		{
+			name:    "exit allow tags without exit traffic",
+			modify:  func(c *PeerConfig) { c.ExitAllowTags = []string{"tag:staff"} },
+			wantErr: true,
+		},
+		{
+			name: "invalid exit allow tag",
+			modify: func(c *PeerConfig) {
+				c.AllowExitTraffic = true
+				c.ExitAllowTags = []string{"staff"}
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -373,6 +394,56 @@ exit_peer: "exit-server"
 	assert.Equal(t, "exit-server", cfg.ExitPeer)
 }
 
+func TestLoadPeerConfig_QUIC(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	content := `
+name: "quic-node"
+ssh_port: 2300
+quic:
+  enabled: true
+`
+	configPath := testutil.TempFile(t, dir, "peer.yaml", content)
+
+	cfg, err := LoadPeerConfig(configPath)
+	require.NoError(t, err)
+
+	assert.True(t, cfg.QUIC.Enabled)
+	assert.Equal(t, 2302, cfg.QUIC.Port, "quic.port should default to ssh_port + 2")
+
+	cfg.QUIC.Port = 2301
+	assert.Error(t, cfg.Validate(), "quic.port must not collide with the UDP transport")
+}
+
+func TestLoadPeerConfig_TLSTransport(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	content := `
+name: "tls-node"
+tls_transport:
+  enabled: true
+  fallback: "127.0.0.1:8443"
+`
+	configPath := testutil.TempFile(t, dir, "peer.yaml", content)
+
+	cfg, err := LoadPeerConfig(configPath)
+	require.NoError(t, err)
+
+	assert.True(t, cfg.TLSTransport.Enabled)
+	assert.Equal(t, 443, cfg.TLSTransport.Port, "tls_transport.port should default to 443")
+	assert.Equal(t, "127.0.0.1:8443", cfg.TLSTransport.Fallback)
+	require.NoError(t, cfg.Validate())
+
+	cfg.TLSTransport.Fallback = "localhost"
+	assert.Error(t, cfg.Validate(), "fallback without a port should be rejected")
+
+	cfg.TLSTransport.Fallback = ""
+	cfg.TLSTransport.CertFile = "site.crt"
+	assert.Error(t, cfg.Validate(), "cert_file without key_file should be rejected")
+}
+
 func TestLoadPeerConfig_WithAllowExitTraffic(t *testing.T) {
 	dir, cleanup := testutil.TempDir(t)
 	defer cleanup()
@@ -407,6 +478,71 @@ name: "regular-node"
 	assert.False(t, cfg.AllowExitTraffic, "allow_exit_traffic should default to false")
 }
 
+func TestLoadPeerConfig_WithExitPeersAndPolicies(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	content := `
+name: "client-node"
+exit_peer: "exit-home"
+exit_peers: ["exit-cloud", "exit-home"]
+exit_policies:
+  - name: corp
+    destinations: ["203.0.113.0/24"]
+    exit_peers: ["exit-office"]
+  - name: uk
+    destinations: ["198.51.100.0/24"]
+    country: GB
+`
+	configPath := testutil.TempFile(t, dir, "peer.yaml", content)
+
+	cfg, err := LoadPeerConfig(configPath)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"exit-home", "exit-cloud"}, cfg.ExitPeerList())
+	require.Len(t, cfg.ExitPolicies, 2)
+	assert.Equal(t, []string{"exit-office"}, cfg.ExitPolicies[0].ExitPeers)
+	assert.Equal(t, "GB", cfg.ExitPolicies[1].Country)
+	assert.NoError(t, cfg.ValidateExitPolicies())
+}
+
+func TestPeerConfig_ValidateExitPolicies(t *testing.T) {
+	tests := []struct {
+		name          string
+		policies      []ExitPolicyConfig
+		systemDefault bool
+		wantErr       bool
+	}{
+		{"no policies", nil, false, false},
+		{"CIDR policy", []ExitPolicyConfig{{Name: "corp", Destinations: []string{"10.0.0.0/8"}, ExitPeers: []string{"office"}}}, false, false},
+		{"country policy", []ExitPolicyConfig{{Name: "uk", Destinations: []string{"2001:db8::/32"}, Country: "GB"}}, false, false},
+		{"domain policy", []ExitPolicyConfig{{Name: "video", Domains: []string{"example.com"}, ExitPeers: []string{"office"}}}, true, false},
+		{"domain policy without system DNS", []ExitPolicyConfig{{Name: "video", Domains: []string{"example.com"}, ExitPeers: []string{"office"}}}, false, true},
+		{"missing name", []ExitPolicyConfig{{Destinations: []string{"10.0.0.0/8"}, ExitPeers: []string{"office"}}}, false, true},
+		{"duplicate name", []ExitPolicyConfig{
+			{Name: "corp", Destinations: []string{"10.0.0.0/8"}, ExitPeers: []string{"office"}},
+			{Name: "corp", Destinations: []string{"10.1.0.0/16"}, ExitPeers: []string{"office"}},
+		}, false, true},
+		{"no destinations", []ExitPolicyConfig{{Name: "corp", ExitPeers: []string{"office"}}}, false, true},
+		{"no exit peers", []ExitPolicyConfig{{Name: "corp", Destinations: []string{"10.0.0.0/8"}}}, false, true},
+		{"bad CIDR", []ExitPolicyConfig{{Name: "corp", Destinations: []string{"10.0.0.1"}, ExitPeers: []string{"office"}}}, false, true},
+		{"bad domain", []ExitPolicyConfig{{Name: "video", Domains: []string{"exa mple.com"}, ExitPeers: []string{"office"}}}, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := PeerConfig{ExitPeer: "exit-home", ExitPolicies: tt.policies}
+			cfg.DNS.SystemDefault = tt.systemDefault
+			err := cfg.ValidateExitPolicies()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
 // DNS Alias Tests
 
 func TestValidateDNSLabel(t *testing.T) {
@@ -564,6 +700,96 @@ func TestDNSConfig_ValidateAliases(t *testing.T) {
 	}
 }
 
+func TestDNSConfig_ValidateServices(t *testing.T) {
+	tests := []struct {
+		name     string
+		services []ServiceConfig
+		wantErr  bool
+	}{
+		{"no services", nil, false},
+		{"tcp by default", []ServiceConfig{{Name: "http", Port: 80}}, false},
+		{"tcp and udp with the same name", []ServiceConfig{{Name: "dns", Protocol: "tcp", Port: 53}, {Name: "dns", Protocol: "udp", Port: 53}}, false},
+		{"uppercase name", []ServiceConfig{{Name: "HTTP", Port: 80}}, true},
+		{"leading underscore", []ServiceConfig{{Name: "_http", Port: 80}}, true},
+		{"dotted name", []ServiceConfig{{Name: "a.b", Port: 80}}, true},
+		{"name too long", []ServiceConfig{{Name: "abcdefghijklmnop", Port: 80}}, true},
+		{"bad protocol", []ServiceConfig{{Name: "http", Protocol: "sctp", Port: 80}}, true},
+		{"missing port", []ServiceConfig{{Name: "http"}}, true},
+		{"port out of range", []ServiceConfig{{Name: "http", Port: 70000}}, true},
+		{"duplicate", []ServiceConfig{{Name: "http", Port: 80}, {Name: "http", Protocol: "tcp", Port: 8080}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dns := DNSConfig{Services: tt.services}
+			err := dns.ValidateServices()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDNSConfig_ValidateForwarding(t *testing.T) {
+	tests := []struct {
+		name    string
+		dns     DNSConfig
+		wantErr bool
+	}{
+		{"no forwarding", DNSConfig{}, false},
+		{"plain and encrypted upstreams", DNSConfig{Upstreams: []string{"1.1.1.1", "tcp://9.9.9.9:53", "tls://1.1.1.1", "https://1.1.1.1/dns-query"}}, false},
+		{"unsupported scheme", DNSConfig{Upstreams: []string{"quic://1.1.1.1"}}, true},
+		{"scheme without host", DNSConfig{Upstreams: []string{"tls://"}}, true},
+		{"forward rule", DNSConfig{Forward: []DNSForwardConfig{{Domain: "corp.example.com", Upstreams: []string{"10.0.0.53"}}}}, false},
+		{"forward rule without domain", DNSConfig{Forward: []DNSForwardConfig{{Upstreams: []string{"10.0.0.53"}}}}, true},
+		{"forward rule without upstreams", DNSConfig{Forward: []DNSForwardConfig{{Domain: "corp.example.com"}}}, true},
+		{"system default with upstreams", DNSConfig{SystemDefault: true, Upstreams: []string{"1.1.1.1"}}, false},
+		{"system default without upstreams", DNSConfig{SystemDefault: true}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.dns.ValidateForwarding()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestPeerConfig_ValidateAdvertiseRoutes(t *testing.T) {
+	tests := []struct {
+		name    string
+		routes  []string
+		wantErr bool
+	}{
+		{"no routes", nil, false},
+		{"LAN and docker bridge", []string{"192.168.10.0/24", "172.17.0.0/16"}, false},
+		{"host bits set", []string{"192.168.10.1/24"}, true},
+		{"bare address", []string{"192.168.10.0"}, true},
+		{"default route", []string{"0.0.0.0/0"}, true},
+		{"inside the mesh", []string{"10.42.8.0/24"}, true},
+		{"IPv6", []string{"fd00::/64"}, true},
+		{"duplicate", []string{"192.168.10.0/24", "192.168.10.0/24"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := PeerConfig{AdvertiseRoutes: tt.routes}
+			err := cfg.ValidateAdvertiseRoutes()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
 func TestPeerConfig_ValidateAliases(t *testing.T) {
 	validConfig := func() PeerConfig {
 		return PeerConfig{
@@ -918,15 +1144,76 @@ func TestFilterConfig_Validate(t *testing.T) {
 			wantErr: true,
 			errMsg:  "must be between 1 and 65535",
 		},
+		{
+			name: "valid port range, CIDR and group rule",
+			config: FilterConfig{
+				Rules: []FilterRule{
+					{Port: 8000, PortEnd: 8100, Protocol: "tcp", Action: "allow", SourceCIDR: "192.168.10.0/24"},
+					{Port: 22, Protocol: "tcp", Action: "allow", SourceGroup: "ops", DestGroup: "prod"},
+					{Port: 53, Protocol: "udp", Action: "allow", SourceCIDR: "10.99.0.7"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid ICMP rules",
+			config: FilterConfig{
+				Rules: []FilterRule{
+					{Protocol: "icmp", ICMPType: 8, Action: "allow"},
+					{Protocol: "ICMPv6", Action: "deny"},
+				},
+			},
+			wantErr: false,
+		},
 		{
 			name: "invalid protocol",
+			config: FilterConfig{
+				Rules: []FilterRule{
+					{Port: 22, Protocol: "gre", Action: "allow"},
+				},
+			},
+			wantErr: true,
+			errMsg:  "must be one of: tcp, udp, icmp, icmpv6",
+		},
+		{
+			name: "reversed port range",
+			config: FilterConfig{
+				Rules: []FilterRule{
+					{Port: 8100, PortEnd: 8000, Protocol: "tcp", Action: "allow"},
+				},
+			},
+			wantErr: true,
+			errMsg:  "port_end 8000 is below port 8100",
+		},
+		{
+			name: "port on ICMP rule",
 			config: FilterConfig{
 				Rules: []FilterRule{
 					{Port: 22, Protocol: "icmp", Action: "allow"},
 				},
 			},
 			wantErr: true,
-			errMsg:  "must be one of: tcp, udp",
+			errMsg:  "ports do not apply",
+		},
+		{
+			name: "ICMP type on TCP rule",
+			config: FilterConfig{
+				Rules: []FilterRule{
+					{Port: 22, Protocol: "tcp", ICMPType: 8, Action: "allow"},
+				},
+			},
+			wantErr: true,
+			errMsg:  "icmp_type requires protocol icmp",
+		},
+		{
+			name: "invalid source CIDR",
+			config: FilterConfig{
+				Rules: []FilterRule{
+					{Port: 22, Protocol: "tcp", Action: "allow", SourceCIDR: "10.0.0.0/40"},
+				},
+			},
+			wantErr: true,
+			errMsg:  "source_cidr",
 		},
 		{
 			name: "invalid action",
@@ -1004,7 +1291,9 @@ func TestFilterRule_ProtocolNumber(t *testing.T) {
 		{"TCP", 6},
 		{"udp", 17},
 		{"UDP", 17},
-		{"icmp", 0},
+		{"icmp", 1},
+		{"icmpv6", 58},
+		{"gre", 0},
 		{"", 0},
 	}
 
@@ -26,6 +26,7 @@ const (
 	CmdFilterList   = "filter.list"
 	CmdFilterAdd    = "filter.add"
 	CmdFilterRemove = "filter.remove"
+	CmdFilterFlows  = "filter.flows"
 )
 
 // Timeouts for control socket operations.
@@ -51,18 +52,29 @@ type Response struct {
 
 // FilterAddRequest is the payload for filter.add command.
 type FilterAddRequest struct {
-	Port       uint16 `json:"port"`
-	Protocol   string `json:"protocol"`    // "tcp" or "udp"
-	Action     string `json:"action"`      // "allow" or "deny"
-	TTL        int64  `json:"ttl"`         // Seconds until expiry, 0 = permanent
-	SourcePeer string `json:"source_peer"` // Source peer name (optional, empty = any)
+	Port        uint16 `json:"port"`
+	PortEnd     uint16 `json:"port_end,omitempty"`     // Last port of a range (optional)
+	Protocol    string `json:"protocol"`               // "tcp", "udp", "icmp" or "icmpv6"
+	ICMPType    uint8  `json:"icmp_type,omitempty"`    // ICMP message type (optional, 0 = any)
+	Action      string `json:"action"`                 // "allow" or "deny"
+	TTL         int64  `json:"ttl"`                    // Seconds until expiry, 0 = permanent
+	SourcePeer  string `json:"source_peer"`            // Source peer name (optional, empty = any)
+	SourceCIDR  string `json:"source_cidr,omitempty"`  // Source address prefix (optional)
+	SourceGroup string `json:"source_group,omitempty"` // RBAC group of the source peer (optional)
+	DestGroup   string `json:"dest_group,omitempty"`   // RBAC group this peer must be in (optional)
 }
 
 // FilterRemoveRequest is the payload for filter.remove command.
+// The selectors must match those the rule was added with.
 type FilterRemoveRequest struct {
-	Port       uint16 `json:"port"`
-	Protocol   string `json:"protocol"`
-	SourcePeer string `json:"source_peer"` // Source peer name (optional, empty = global rule)
+	Port        uint16 `json:"port"`
+	PortEnd     uint16 `json:"port_end,omitempty"`
+	Protocol    string `json:"protocol"`
+	ICMPType    uint8  `json:"icmp_type,omitempty"`
+	SourcePeer  string `json:"source_peer"` // Source peer name (optional, empty = global rule)
+	SourceCIDR  string `json:"source_cidr,omitempty"`
+	SourceGroup string `json:"source_group,omitempty"`
+	DestGroup   string `json:"dest_group,omitempty"`
 }
 
 // FilterListResponse is the response for filter.list command.
@@ -73,12 +85,58 @@ type FilterListResponse struct {
 
 // FilterRuleDetail includes rule info and source for display.
 type FilterRuleDetail struct {
-	Port       uint16 `json:"port"`
-	Protocol   string `json:"protocol"`
-	Action     string `json:"action"`
-	Source     string `json:"source"`      // "coordinator", "config", "temporary"
-	Expires    int64  `json:"expires"`     // Unix timestamp, 0 = permanent
-	SourcePeer string `json:"source_peer"` // Source peer name (empty = any peer)
+	Port        uint16 `json:"port"`
+	PortEnd     uint16 `json:"port_end,omitempty"` // Last port of a range (0 = port only)
+	Protocol    string `json:"protocol"`
+	ICMPType    uint8  `json:"icmp_type,omitempty"` // ICMP message type (0 = any)
+	Action      string `json:"action"`
+	Source      string `json:"source"`                 // "coordinator", "config", "temporary"
+	Expires     int64  `json:"expires"`                // Unix timestamp, 0 = permanent
+	SourcePeer  string `json:"source_peer"`            // Source peer name (empty = any peer)
+	SourceCIDR  string `json:"source_cidr,omitempty"`  // Source address prefix (empty = any)
+	SourceGroup string `json:"source_group,omitempty"` // RBAC group of the source peer
+	DestGroup   string `json:"dest_group,omitempty"`   // RBAC group this peer must be in
+}
+
+// FilterFlowsResponse is the response for filter.flows command.
+type FilterFlowsResponse struct {
+	Flows []FilterFlowDetail `json:"flows"`
+}
+
+// FilterFlowDetail describes a flow in the packet filter's connection table.
+type FilterFlowDetail struct {
+	Protocol     string `json:"protocol"`
+	Src          string `json:"src"`       // Originator address:port (ICMP: echo identifier)
+	Dst          string `json:"dst"`       // Responder address:port
+	State        string `json:"state"`     // e.g. "ESTABLISHED", "REPLIED"
+	Direction    string `json:"direction"` // "in" if a remote peer originated the flow, "out" otherwise
+	Peer         string `json:"peer,omitempty"`
+	PacketsOrig  uint64 `json:"packets_orig"`
+	PacketsReply uint64 `json:"packets_reply"`
+	Expires      int64  `json:"expires"` // Unix timestamp the flow expires at if idle
+}
+
+// FlowDetails converts connection table flows for display.
+func FlowDetails(flows []routing.Flow) []FilterFlowDetail {
+	details := make([]FilterFlowDetail, 0, len(flows))
+	for _, fl := range flows {
+		direction := "out"
+		if fl.Inbound {
+			direction = "in"
+		}
+		details = append(details, FilterFlowDetail{
+			Protocol:     routing.ProtocolToString(fl.Protocol),
+			Src:          fl.Src.String(),
+			Dst:          fl.Dst.String(),
+			State:        fl.State.String(),
+			Direction:    direction,
+			Peer:         fl.Peer,
+			PacketsOrig:  fl.PacketsOrig,
+			PacketsReply: fl.PacketsReply,
+			Expires:      fl.Expires.Unix(),
+		})
+	}
+	return details
 }
 
 // Server is a Unix socket control server.
@@ -220,6 +278,9 @@ func (s *Server) handleCommand(req Request) Response {
 		return s.handleFilterAdd(filter, req.Payload)
 	case CmdFilterRemove:
 		return s.handleFilterRemove(filter, req.Payload)
+	case CmdFilterFlows:
+		data, _ := json.Marshal(FilterFlowsResponse{Flows: FlowDetails(filter.Flows())})
+		return Response{Success: true, Data: data}
 	default:
 		return Response{Success: false, Error: fmt.Sprintf("unknown command: %s", req.Command)}
 	}
@@ -231,12 +292,17 @@ func (s *Server) handleFilterList(filter *routing.PacketFilter) Response {
 	details := make([]FilterRuleDetail, 0, len(rules))
 	for _, r := range rules {
 		details = append(details, FilterRuleDetail{
-			Port:       r.Rule.Port,
-			Protocol:   routing.ProtocolToString(r.Rule.Protocol),
-			Action:     r.Rule.Action.String(),
-			Source:     r.Source.String(),
-			Expires:    r.Rule.Expires,
-			SourcePeer: r.Rule.SourcePeer,
+			Port:        r.Rule.Port,
+			PortEnd:     r.Rule.PortEnd,
+			Protocol:    routing.ProtocolToString(r.Rule.Protocol),
+			ICMPType:    r.Rule.ICMPType,
+			Action:      r.Rule.Action.String(),
+			Source:      r.Source.String(),
+			Expires:     r.Rule.Expires,
+			SourcePeer:  r.Rule.SourcePeer,
+			SourceCIDR:  r.Rule.SourceCIDR,
+			SourceGroup: r.Rule.SourceGroup,
+			DestGroup:   r.Rule.DestGroup,
 		})
 	}
 
@@ -256,14 +322,24 @@ func (s *Server) handleFilterAdd(filter *routing.PacketFilter, payload json.RawM
 	}
 
 	// Validate
-	if req.Port == 0 {
-		return Response{Success: false, Error: "invalid port: must be between 1 and 65535 (port is required)"}
-	}
 	proto := routing.ProtocolFromString(req.Protocol)
 	if proto == 0 {
-		return Response{Success: false, Error: fmt.Sprintf("invalid protocol %q - must be one of: tcp, udp", req.Protocol)}
+		return Response{Success: false, Error: fmt.Sprintf("invalid protocol %q - must be one of: tcp, udp, icmp, icmpv6", req.Protocol)}
+	}
+	rule := routing.FilterRule{
+		Port:        req.Port,
+		PortEnd:     req.PortEnd,
+		Protocol:    proto,
+		ICMPType:    req.ICMPType,
+		Action:      routing.ParseFilterAction(req.Action),
+		SourcePeer:  req.SourcePeer,
+		SourceCIDR:  req.SourceCIDR,
+		SourceGroup: req.SourceGroup,
+		DestGroup:   req.DestGroup,
+	}
+	if err := rule.Validate(); err != nil {
+		return Response{Success: false, Error: err.Error()}
 	}
-	action := routing.ParseFilterAction(req.Action)
 
 	// Prevent self-targeting: a peer can't filter traffic from itself
 	if req.SourcePeer != "" && req.SourcePeer == s.localPeerName {
@@ -271,17 +347,8 @@ func (s *Server) handleFilterAdd(filter *routing.PacketFilter, payload json.RawM
 	}
 
 	// Calculate expiry
-	var expires int64
 	if req.TTL > 0 {
-		expires = time.Now().Unix() + req.TTL
-	}
-
-	rule := routing.FilterRule{
-		Port:       req.Port,
-		Protocol:   proto,
-		Action:     action,
-		Expires:    expires,
-		SourcePeer: req.SourcePeer,
+		rule.Expires = time.Now().Unix() + req.TTL
 	}
 
 	filter.AddTemporaryRule(rule)
@@ -313,10 +380,19 @@ func (s *Server) handleFilterRemove(filter *routing.PacketFilter, payload json.R
 
 	proto := routing.ProtocolFromString(req.Protocol)
 	if proto == 0 {
-		return Response{Success: false, Error: fmt.Sprintf("invalid protocol %q - must be one of: tcp, udp", req.Protocol)}
+		return Response{Success: false, Error: fmt.Sprintf("invalid protocol %q - must be one of: tcp, udp, icmp, icmpv6", req.Protocol)}
 	}
 
-	filter.RemoveTemporaryRuleForPeer(req.Port, proto, req.SourcePeer)
+	filter.RemoveTemporaryRuleKey(routing.FilterRule{
+		Port:        req.Port,
+		PortEnd:     req.PortEnd,
+		Protocol:    proto,
+		ICMPType:    req.ICMPType,
+		SourcePeer:  req.SourcePeer,
+		SourceCIDR:  req.SourceCIDR,
+		SourceGroup: req.SourceGroup,
+		DestGroup:   req.DestGroup,
+	}.Key())
 
 	// Notify persistence layer
 	s.mu.RLock()
@@ -391,6 +467,23 @@ func (c *Client) FilterList() (*FilterListResponse, error) {
 	return &result, nil
 }
 
+// FilterFlows retrieves the flows in the packet filter's connection table.
+func (c *Client) FilterFlows() (*FilterFlowsResponse, error) {
+	resp, err := c.Send(Request{Command: CmdFilterFlows})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, errors.New(resp.Error)
+	}
+
+	var result FilterFlowsResponse
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("unmarshal response: %w", err)
+	}
+	return &result, nil
+}
+
 // FilterAdd adds a temporary global filter rule.
 func (c *Client) FilterAdd(port uint16, protocol, action string, ttl int64) error {
 	return c.FilterAddForPeer(port, protocol, action, ttl, "")
@@ -399,13 +492,18 @@ func (c *Client) FilterAdd(port uint16, protocol, action string, ttl int64) erro
 // FilterAddForPeer adds a temporary filter rule for a specific source peer.
 // Pass empty string for sourcePeer to create a global rule (applies to all peers).
 func (c *Client) FilterAddForPeer(port uint16, protocol, action string, ttl int64, sourcePeer string) error {
-	payload, _ := json.Marshal(FilterAddRequest{
+	return c.FilterAddRule(FilterAddRequest{
 		Port:       port,
 		Protocol:   protocol,
 		Action:     action,
 		TTL:        ttl,
 		SourcePeer: sourcePeer,
 	})
+}
+
+// FilterAddRule adds a temporary filter rule with any selectors.
+func (c *Client) FilterAddRule(req FilterAddRequest) error {
+	payload, _ := json.Marshal(req)
 
 	resp, err := c.Send(Request{Command: CmdFilterAdd, Payload: payload})
 	if err != nil {
@@ -425,11 +523,16 @@ func (c *Client) FilterRemove(port uint16, protocol string) error {
 // FilterRemoveForPeer removes a temporary filter rule for a specific source peer.
 // Pass empty string for sourcePeer to remove a global rule.
 func (c *Client) FilterRemoveForPeer(port uint16, protocol, sourcePeer string) error {
-	payload, _ := json.Marshal(FilterRemoveRequest{
+	return c.FilterRemoveRule(FilterRemoveRequest{
 		Port:       port,
 		Protocol:   protocol,
 		SourcePeer: sourcePeer,
 	})
+}
+
+// FilterRemoveRule removes the temporary filter rule with the given selectors.
+func (c *Client) FilterRemoveRule(req FilterRemoveRequest) error {
+	payload, _ := json.Marshal(req)
 
 	resp, err := c.Send(Request{Command: CmdFilterRemove, Payload: payload})
 	if err != nil {
@@ -158,10 +158,10 @@ func TestClient_InvalidProtocol(t *testing.T) {
 
 	client := NewClient(socketPath)
 
-	err := client.FilterAdd(8080, "icmp", "allow", 0)
+	err := client.FilterAdd(8080, "gre", "allow", 0)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "invalid protocol")
-	assert.Contains(t, err.Error(), "must be one of: tcp, udp")
+	assert.Contains(t, err.Error(), "must be one of: tcp, udp, icmp, icmpv6")
 }
 
 func TestClient_MissingPort(t *testing.T) {
@@ -184,6 +184,80 @@ func TestClient_MissingPort(t *testing.T) {
 	assert.Contains(t, err.Error(), "1 and 65535")
 }
 
+func TestClient_FilterAddRemoveRule(t *testing.T) {
+	dir := t.TempDir()
+	socketPath := filepath.Join(dir, "test.sock")
+
+	filter := routing.NewPacketFilter(true)
+
+	server := NewServer(socketPath, filter, "test-peer")
+	require.NoError(t, server.Start())
+	defer func() { _ = server.Stop() }()
+
+	time.Sleep(10 * time.Millisecond)
+
+	client := NewClient(socketPath)
+
+	require.NoError(t, client.FilterAddRule(FilterAddRequest{
+		Port: 8000, PortEnd: 8100, Protocol: "tcp", Action: "allow",
+		SourceCIDR: "192.168.10.0/24", SourceGroup: "ops",
+	}))
+	require.NoError(t, client.FilterAddRule(FilterAddRequest{Protocol: "icmp", ICMPType: 8, Action: "allow"}))
+
+	resp, err := client.FilterList()
+	require.NoError(t, err)
+	assert.Len(t, resp.Rules, 2)
+
+	// Ports do not apply to ICMP
+	err = client.FilterAddRule(FilterAddRequest{Port: 22, Protocol: "icmp", Action: "allow"})
+	assert.Error(t, err)
+
+	// Removal needs the same selectors
+	require.NoError(t, client.FilterRemoveRule(FilterRemoveRequest{Port: 8000, PortEnd: 8100, Protocol: "tcp"}))
+	assert.Equal(t, 2, filter.RuleCount())
+	require.NoError(t, client.FilterRemoveRule(FilterRemoveRequest{
+		Port: 8000, PortEnd: 8100, Protocol: "tcp", SourceCIDR: "192.168.10.0/24", SourceGroup: "ops",
+	}))
+	require.NoError(t, client.FilterRemoveRule(FilterRemoveRequest{Protocol: "icmp", ICMPType: 8}))
+	assert.Equal(t, 0, filter.RuleCount())
+}
+
+func TestClient_FilterFlows(t *testing.T) {
+	dir := t.TempDir()
+	socketPath := filepath.Join(dir, "test.sock")
+
+	filter := routing.NewPacketFilter(true)
+	// Outbound DNS query: IPv4/UDP 10.42.0.1:40000 -> 10.42.0.2:53
+	query := make([]byte, 28)
+	query[0] = 0x45
+	query[9] = routing.ProtoUDP
+	copy(query[12:16], []byte{10, 42, 0, 1})
+	copy(query[16:20], []byte{10, 42, 0, 2})
+	query[20], query[21] = 0x9c, 0x40
+	query[23] = 53
+	filter.TrackOutbound(query, "peer2")
+
+	server := NewServer(socketPath, filter, "test-peer")
+	require.NoError(t, server.Start())
+	defer func() { _ = server.Stop() }()
+
+	time.Sleep(10 * time.Millisecond)
+
+	client := NewClient(socketPath)
+	resp, err := client.FilterFlows()
+	require.NoError(t, err)
+	require.Len(t, resp.Flows, 1)
+
+	fl := resp.Flows[0]
+	assert.Equal(t, "udp", fl.Protocol)
+	assert.Equal(t, "10.42.0.1:40000", fl.Src)
+	assert.Equal(t, "10.42.0.2:53", fl.Dst)
+	assert.Equal(t, "UNREPLIED", fl.State)
+	assert.Equal(t, "out", fl.Direction)
+	assert.Equal(t, uint64(1), fl.PacketsOrig)
+	assert.Greater(t, fl.Expires, time.Now().Unix())
+}
+
 func TestClient_ConnectionRefused(t *testing.T) {
 	client := NewClient("/nonexistent/socket.sock")
 
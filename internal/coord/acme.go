@@ -0,0 +1,857 @@
+package coord
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tunnelmesh/tunnelmesh/internal/coord/s3"
+	"github.com/tunnelmesh/tunnelmesh/internal/mesh"
+)
+
+// ACME endpoint (RFC 8555) on the mesh CA, served on the admin interface at
+// /acme/directory. Peers get certificates for the names they own: their own
+// name and DNS aliases under the mesh domain suffixes, and their mesh IPs.
+// The requesting peer is identified by the mesh IP the request comes from,
+// so authorizations are valid as soon as they are created and no challenge
+// has to be solved.
+
+const (
+	acmeNonceTTL       = time.Hour
+	acmeMaxNonces      = 10000
+	acmeOrderTTL       = time.Hour
+	acmeMaxIdentifiers = 20
+	acmeMaxRequestSize = 64 * 1024
+)
+
+// ACME object statuses.
+const (
+	acmeStatusReady      = "ready"
+	acmeStatusProcessing = "processing"
+	acmeStatusValid      = "valid"
+	acmeStatusInvalid    = "invalid"
+)
+
+type acmeIdentifier struct {
+	Type  string `json:"type"` // "dns" or "ip"
+	Value string `json:"value"`
+}
+
+type acmeOrder struct {
+	accountID   string
+	status      string
+	expires     time.Time
+	identifiers []acmeIdentifier
+	authzIDs    []string
+	certID      string
+}
+
+type acmeAuthz struct {
+	accountID  string
+	identifier acmeIdentifier
+	expires    time.Time
+	token      string
+	validated  time.Time
+}
+
+type acmeCert struct {
+	accountID string
+	chainPEM  []byte
+	notAfter  time.Time
+}
+
+// acmeState holds the ACME nonces, accounts, orders, authorizations and
+// certificates. Only accounts are persisted; the rest is kept in memory
+// until it expires.
+type acmeState struct {
+	mu       sync.Mutex
+	nonces   map[string]time.Time
+	accounts map[string]s3.ACMEAccount // By account ID (JWK thumbprint)
+	orders   map[string]*acmeOrder
+	authzs   map[string]*acmeAuthz
+	certs    map[string]*acmeCert
+
+	saveMu sync.Mutex // Serializes account saves
+}
+
+func newACMEState() *acmeState {
+	return &acmeState{
+		nonces:   make(map[string]time.Time),
+		accounts: make(map[string]s3.ACMEAccount),
+		orders:   make(map[string]*acmeOrder),
+		authzs:   make(map[string]*acmeAuthz),
+		certs:    make(map[string]*acmeCert),
+	}
+}
+
+// newNonce issues a nonce for the Replay-Nonce header.
+func (a *acmeState) newNonce() string {
+	nonce := rand.Text()
+	now := time.Now()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.nonces) >= acmeMaxNonces {
+		for n, expires := range a.nonces {
+			if now.After(expires) {
+				delete(a.nonces, n)
+			}
+		}
+		// Still full: drop an arbitrary nonce, its client retries with badNonce
+		for n := range a.nonces {
+			if len(a.nonces) < acmeMaxNonces {
+				break
+			}
+			delete(a.nonces, n)
+		}
+	}
+	a.nonces[nonce] = now.Add(acmeNonceTTL)
+	return nonce
+}
+
+// useNonce consumes a nonce. Returns false if it was not issued, already
+// used or expired.
+func (a *acmeState) useNonce(nonce string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	expires, ok := a.nonces[nonce]
+	delete(a.nonces, nonce)
+	return ok && time.Now().Before(expires)
+}
+
+// pruneLocked drops expired orders, authorizations and certificates.
+// Caller must hold a.mu.
+func (a *acmeState) pruneLocked(now time.Time) {
+	for id, o := range a.orders {
+		if now.After(o.expires) {
+			delete(a.orders, id)
+		}
+	}
+	for id, authz := range a.authzs {
+		if now.After(authz.expires) {
+			delete(a.authzs, id)
+		}
+	}
+	for id, c := range a.certs {
+		if now.After(c.notAfter) {
+			delete(a.certs, id)
+		}
+	}
+}
+
+// acmeRequest is a verified JWS-signed ACME request.
+type acmeRequest struct {
+	peer      string // Requesting peer, by mesh IP
+	accountID string
+	account   s3.ACMEAccount
+	jwk       json.RawMessage // Account key, new-account requests only
+	payload   []byte          // Empty for POST-as-GET
+}
+
+// acmeProblem writes an RFC 7807 problem document with an ACME error type.
+func acmeProblem(w http.ResponseWriter, errType, detail string, status int) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"type":   "urn:ietf:params:acme:error:" + errType,
+		"detail": detail,
+		"status": status,
+	})
+}
+
+// acmeJSON writes an ACME object, with its URL in the Location header if given.
+func acmeJSON(w http.ResponseWriter, status int, location string, v any) {
+	if location != "" {
+		w.Header().Set("Location", location)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// acmeURL returns the absolute URL of an ACME resource, as seen by the client.
+func acmeURL(r *http.Request, path string) string {
+	scheme := "https"
+	if r.TLS == nil {
+		scheme = "http"
+	}
+	return scheme + "://" + r.Host + "/acme/" + path
+}
+
+// handleACME routes ACME requests.
+func (s *Server) handleACME(w http.ResponseWriter, r *http.Request) {
+	if s.ca == nil || s.acme == nil {
+		acmeProblem(w, "serverInternal", "CA not initialized", http.StatusServiceUnavailable)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/acme/")
+
+	w.Header().Set("Replay-Nonce", s.acme.newNonce())
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Add("Link", "<"+acmeURL(r, "directory")+`>;rel="index"`)
+
+	switch path {
+	case "directory":
+		if r.Method != http.MethodGet {
+			acmeProblem(w, "malformed", "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		acmeJSON(w, http.StatusOK, "", map[string]any{
+			"newNonce":   acmeURL(r, "new-nonce"),
+			"newAccount": acmeURL(r, "new-account"),
+			"newOrder":   acmeURL(r, "new-order"),
+			"revokeCert": acmeURL(r, "revoke-cert"),
+			"meta": map[string]any{
+				"externalAccountRequired": false,
+			},
+		})
+		return
+	case "new-nonce":
+		switch r.Method {
+		case http.MethodHead:
+			w.WriteHeader(http.StatusOK)
+		case http.MethodGet:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			acmeProblem(w, "malformed", "method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		acmeProblem(w, "malformed", "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req, ok := s.acmeVerify(w, r, path)
+	if !ok {
+		return
+	}
+
+	switch {
+	case path == "new-account":
+		s.acmeNewAccount(w, r, req)
+	case strings.HasPrefix(path, "account/"):
+		s.acmeAccount(w, r, req, strings.TrimPrefix(path, "account/"))
+	case path == "new-order":
+		s.acmeNewOrder(w, r, req)
+	case strings.HasPrefix(path, "orders/"):
+		s.acmeOrderList(w, r, req, strings.TrimPrefix(path, "orders/"))
+	case strings.HasPrefix(path, "order/") && strings.HasSuffix(path, "/finalize"):
+		s.acmeFinalize(w, r, req, strings.TrimSuffix(strings.TrimPrefix(path, "order/"), "/finalize"))
+	case strings.HasPrefix(path, "order/"):
+		s.acmeGetOrder(w, r, req, strings.TrimPrefix(path, "order/"))
+	case strings.HasPrefix(path, "authz/"):
+		s.acmeGetAuthz(w, r, req, strings.TrimPrefix(path, "authz/"))
+	case strings.HasPrefix(path, "chall/"):
+		s.acmeGetChallenge(w, r, req, strings.TrimPrefix(path, "chall/"))
+	case strings.HasPrefix(path, "cert/"):
+		s.acmeGetCert(w, req, strings.TrimPrefix(path, "cert/"))
+	case path == "revoke-cert":
+		s.acmeRevokeCert(w, r, req)
+	default:
+		acmeProblem(w, "malformed", "unknown resource", http.StatusNotFound)
+	}
+}
+
+// acmeVerify checks the JWS of an ACME POST request: its nonce, URL and
+// signature, and that it comes from the peer owning the account. Writes a
+// problem document and returns false otherwise.
+func (s *Server) acmeVerify(w http.ResponseWriter, r *http.Request, path string) (*acmeRequest, bool) {
+	if ct := r.Header.Get("Content-Type"); ct != "application/jose+json" {
+		acmeProblem(w, "malformed", "content type must be application/jose+json", http.StatusUnsupportedMediaType)
+		return nil, false
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, acmeMaxRequestSize))
+	if err != nil {
+		acmeProblem(w, "malformed", "failed to read request", http.StatusBadRequest)
+		return nil, false
+	}
+	var msg jwsMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		acmeProblem(w, "malformed", "invalid JWS", http.StatusBadRequest)
+		return nil, false
+	}
+	protected, err := b64.DecodeString(msg.Protected)
+	var header jwsHeader
+	if err != nil || json.Unmarshal(protected, &header) != nil {
+		acmeProblem(w, "malformed", "invalid JWS protected header", http.StatusBadRequest)
+		return nil, false
+	}
+
+	if !s.acme.useNonce(header.Nonce) {
+		acmeProblem(w, "badNonce", "invalid or expired nonce", http.StatusBadRequest)
+		return nil, false
+	}
+	if header.URL != acmeURL(r, path) {
+		acmeProblem(w, "unauthorized", "JWS url does not match the request", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	req := &acmeRequest{peer: s.getPeerByRemoteAddr(r.RemoteAddr)}
+	if req.peer == "" {
+		acmeProblem(w, "unauthorized", "requests must come from a mesh peer", http.StatusForbidden)
+		return nil, false
+	}
+
+	var keyData json.RawMessage
+	if path == "new-account" {
+		if header.JWK == nil || header.KID != "" {
+			acmeProblem(w, "malformed", "new-account requests must be signed with a jwk", http.StatusBadRequest)
+			return nil, false
+		}
+		keyData = header.JWK
+		req.jwk = header.JWK
+	} else {
+		if header.KID == "" || header.JWK != nil {
+			acmeProblem(w, "malformed", "requests must be signed with an account kid", http.StatusBadRequest)
+			return nil, false
+		}
+		id, ok := strings.CutPrefix(header.KID, acmeURL(r, "account/"))
+		s.acme.mu.Lock()
+		account, exists := s.acme.accounts[id]
+		s.acme.mu.Unlock()
+		if !ok || !exists || account.Deactivated {
+			acmeProblem(w, "accountDoesNotExist", "account not found", http.StatusBadRequest)
+			return nil, false
+		}
+		if account.Peer != req.peer {
+			acmeProblem(w, "unauthorized", "account belongs to another peer", http.StatusForbidden)
+			return nil, false
+		}
+		req.accountID, req.account = id, account
+		keyData = account.JWK
+	}
+
+	key, jwk, err := parseJWK(keyData)
+	if err != nil {
+		acmeProblem(w, "badPublicKey", err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	if err := verifyJWS(&msg, header.Alg, key); err != nil {
+		acmeProblem(w, "badSignatureAlgorithm", err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	if path == "new-account" {
+		req.accountID = jwk.thumbprint()
+	}
+
+	if msg.Payload != "" {
+		if req.payload, err = b64.DecodeString(msg.Payload); err != nil {
+			acmeProblem(w, "malformed", "invalid JWS payload", http.StatusBadRequest)
+			return nil, false
+		}
+	}
+	return req, true
+}
+
+// acmeAccountObject is the JSON representation of an account.
+func acmeAccountObject(r *http.Request, id string, account s3.ACMEAccount) map[string]any {
+	status := acmeStatusValid
+	if account.Deactivated {
+		status = "deactivated"
+	}
+	obj := map[string]any{
+		"status": status,
+		"orders": acmeURL(r, "orders/"+id),
+	}
+	if len(account.Contact) > 0 {
+		obj["contact"] = account.Contact
+	}
+	return obj
+}
+
+func (s *Server) acmeNewAccount(w http.ResponseWriter, r *http.Request, req *acmeRequest) {
+	var payload struct {
+		Contact              []string `json:"contact"`
+		TermsOfServiceAgreed bool     `json:"termsOfServiceAgreed"`
+		OnlyReturnExisting   bool     `json:"onlyReturnExisting"`
+	}
+	if err := json.Unmarshal(req.payload, &payload); err != nil {
+		acmeProblem(w, "malformed", "invalid new-account payload", http.StatusBadRequest)
+		return
+	}
+	location := acmeURL(r, "account/"+req.accountID)
+
+	s.acme.mu.Lock()
+	account, exists := s.acme.accounts[req.accountID]
+	if exists {
+		s.acme.mu.Unlock()
+		if account.Deactivated || account.Peer != req.peer {
+			acmeProblem(w, "unauthorized", "account key is deactivated or belongs to another peer", http.StatusForbidden)
+			return
+		}
+		acmeJSON(w, http.StatusOK, location, acmeAccountObject(r, req.accountID, account))
+		return
+	}
+	if payload.OnlyReturnExisting {
+		s.acme.mu.Unlock()
+		acmeProblem(w, "accountDoesNotExist", "account not found", http.StatusBadRequest)
+		return
+	}
+	account = s3.ACMEAccount{
+		JWK:       req.jwk,
+		Peer:      req.peer,
+		Contact:   payload.Contact,
+		CreatedAt: time.Now(),
+	}
+	s.acme.accounts[req.accountID] = account
+	s.acme.mu.Unlock()
+
+	log.Info().Str("peer", req.peer).Str("account", req.accountID).Msg("ACME account registered")
+	s.saveACMEAccountsAsync()
+	acmeJSON(w, http.StatusCreated, location, acmeAccountObject(r, req.accountID, account))
+}
+
+func (s *Server) acmeAccount(w http.ResponseWriter, r *http.Request, req *acmeRequest, id string) {
+	if id != req.accountID {
+		acmeProblem(w, "unauthorized", "not the signing account", http.StatusForbidden)
+		return
+	}
+
+	account := req.account
+	if len(req.payload) > 0 {
+		var payload struct {
+			Status  string   `json:"status"`
+			Contact []string `json:"contact"`
+		}
+		if err := json.Unmarshal(req.payload, &payload); err != nil {
+			acmeProblem(w, "malformed", "invalid account payload", http.StatusBadRequest)
+			return
+		}
+		switch payload.Status {
+		case "":
+		case "deactivated":
+			account.Deactivated = true
+		default:
+			acmeProblem(w, "malformed", "status can only be changed to deactivated", http.StatusBadRequest)
+			return
+		}
+		if payload.Contact != nil {
+			account.Contact = payload.Contact
+		}
+		s.acme.mu.Lock()
+		s.acme.accounts[id] = account
+		s.acme.mu.Unlock()
+		s.saveACMEAccountsAsync()
+	}
+
+	acmeJSON(w, http.StatusOK, "", acmeAccountObject(r, id, account))
+}
+
+// acmeOwns reports whether a peer owns an identifier: a DNS name that is
+// the peer's name or one of its aliases under a mesh domain suffix, or one
+// of its mesh IPs. The identifier must be normalized.
+func (s *Server) acmeOwns(peerName string, id acmeIdentifier) bool {
+	s.peersMu.RLock()
+	defer s.peersMu.RUnlock()
+	info, ok := s.peers[peerName]
+	if !ok {
+		return false
+	}
+
+	switch id.Type {
+	case "dns":
+		for _, suffix := range mesh.AllSuffixes() {
+			label, ok := strings.CutSuffix(id.Value, suffix)
+			if !ok || label == "" || strings.Contains(label, ".") {
+				continue
+			}
+			if label == peerName || slices.Contains(info.aliases, label) {
+				return true
+			}
+		}
+	case "ip":
+		return id.Value == info.peer.MeshIP || (info.peer.MeshIPv6 != "" && id.Value == net.ParseIP(info.peer.MeshIPv6).String())
+	}
+	return false
+}
+
+// normalizeACMEIdentifier lowercases DNS names and canonicalizes IPs.
+// Returns false for unsupported identifier types and malformed values.
+func normalizeACMEIdentifier(id acmeIdentifier) (acmeIdentifier, bool) {
+	switch id.Type {
+	case "dns":
+		id.Value = strings.TrimSuffix(strings.ToLower(id.Value), ".")
+		return id, id.Value != "" && !strings.Contains(id.Value, "*")
+	case "ip":
+		ip := net.ParseIP(id.Value)
+		if ip == nil {
+			return id, false
+		}
+		id.Value = ip.String()
+		return id, true
+	}
+	return id, false
+}
+
+// acmeOrderObject is the JSON representation of an order.
+func acmeOrderObject(r *http.Request, id string, o *acmeOrder) map[string]any {
+	authzURLs := make([]string, len(o.authzIDs))
+	for i, authzID := range o.authzIDs {
+		authzURLs[i] = acmeURL(r, "authz/"+authzID)
+	}
+	obj := map[string]any{
+		"status":         o.status,
+		"expires":        o.expires.UTC().Format(time.RFC3339),
+		"identifiers":    o.identifiers,
+		"authorizations": authzURLs,
+		"finalize":       acmeURL(r, "order/"+id+"/finalize"),
+	}
+	if o.certID != "" {
+		obj["certificate"] = acmeURL(r, "cert/"+o.certID)
+	}
+	return obj
+}
+
+func (s *Server) acmeNewOrder(w http.ResponseWriter, r *http.Request, req *acmeRequest) {
+	var payload struct {
+		Identifiers []acmeIdentifier `json:"identifiers"`
+		NotBefore   string           `json:"notBefore"`
+		NotAfter    string           `json:"notAfter"`
+	}
+	if err := json.Unmarshal(req.payload, &payload); err != nil {
+		acmeProblem(w, "malformed", "invalid new-order payload", http.StatusBadRequest)
+		return
+	}
+	if len(payload.Identifiers) == 0 || len(payload.Identifiers) > acmeMaxIdentifiers {
+		acmeProblem(w, "malformed", "an order needs between 1 and 20 identifiers", http.StatusBadRequest)
+		return
+	}
+	if payload.NotBefore != "" || payload.NotAfter != "" {
+		acmeProblem(w, "malformed", "the validity period is set by the CA", http.StatusBadRequest)
+		return
+	}
+
+	var identifiers []acmeIdentifier
+	for _, id := range payload.Identifiers {
+		normalized, ok := normalizeACMEIdentifier(id)
+		if !ok {
+			acmeProblem(w, "rejectedIdentifier", "unsupported identifier "+id.Type+":"+id.Value, http.StatusBadRequest)
+			return
+		}
+		if !s.acmeOwns(req.peer, normalized) {
+			acmeProblem(w, "rejectedIdentifier", "peer "+req.peer+" does not own "+normalized.Value, http.StatusForbidden)
+			return
+		}
+		if !slices.Contains(identifiers, normalized) {
+			identifiers = append(identifiers, normalized)
+		}
+	}
+
+	now := time.Now()
+	order := &acmeOrder{
+		accountID:   req.accountID,
+		status:      acmeStatusReady,
+		expires:     now.Add(acmeOrderTTL),
+		identifiers: identifiers,
+	}
+	orderID := rand.Text()
+
+	s.acme.mu.Lock()
+	s.acme.pruneLocked(now)
+	// Ownership was checked above, so the authorizations are valid already
+	for _, id := range identifiers {
+		authzID := rand.Text()
+		s.acme.authzs[authzID] = &acmeAuthz{
+			accountID:  req.accountID,
+			identifier: id,
+			expires:    order.expires,
+			token:      rand.Text(),
+			validated:  now,
+		}
+		order.authzIDs = append(order.authzIDs, authzID)
+	}
+	s.acme.orders[orderID] = order
+	obj := acmeOrderObject(r, orderID, order)
+	s.acme.mu.Unlock()
+
+	acmeJSON(w, http.StatusCreated, acmeURL(r, "order/"+orderID), obj)
+}
+
+func (s *Server) acmeOrderList(w http.ResponseWriter, r *http.Request, req *acmeRequest, accountID string) {
+	if accountID != req.accountID {
+		acmeProblem(w, "unauthorized", "not the signing account", http.StatusForbidden)
+		return
+	}
+	s.acme.mu.Lock()
+	urls := []string{}
+	for id, o := range s.acme.orders {
+		if o.accountID == accountID {
+			urls = append(urls, acmeURL(r, "order/"+id))
+		}
+	}
+	s.acme.mu.Unlock()
+	slices.Sort(urls)
+	acmeJSON(w, http.StatusOK, "", map[string]any{"orders": urls})
+}
+
+func (s *Server) acmeGetOrder(w http.ResponseWriter, r *http.Request, req *acmeRequest, id string) {
+	s.acme.mu.Lock()
+	order, ok := s.acme.orders[id]
+	var obj map[string]any
+	if ok && order.accountID == req.accountID {
+		obj = acmeOrderObject(r, id, order)
+	}
+	s.acme.mu.Unlock()
+	if obj == nil {
+		acmeProblem(w, "malformed", "order not found", http.StatusNotFound)
+		return
+	}
+	acmeJSON(w, http.StatusOK, "", obj)
+}
+
+func (s *Server) acmeFinalize(w http.ResponseWriter, r *http.Request, req *acmeRequest, id string) {
+	var payload struct {
+		CSR string `json:"csr"`
+	}
+	if err := json.Unmarshal(req.payload, &payload); err != nil || payload.CSR == "" {
+		acmeProblem(w, "malformed", "invalid finalize payload", http.StatusBadRequest)
+		return
+	}
+
+	s.acme.mu.Lock()
+	order, ok := s.acme.orders[id]
+	if !ok || order.accountID != req.accountID {
+		s.acme.mu.Unlock()
+		acmeProblem(w, "malformed", "order not found", http.StatusNotFound)
+		return
+	}
+	if order.status != acmeStatusReady {
+		s.acme.mu.Unlock()
+		acmeProblem(w, "orderNotReady", "order is "+order.status, http.StatusForbidden)
+		return
+	}
+	identifiers := order.identifiers
+	s.acme.mu.Unlock()
+
+	csrDER, err := b64.DecodeString(payload.CSR)
+	if err != nil {
+		acmeProblem(w, "badCSR", "invalid CSR encoding", http.StatusBadRequest)
+		return
+	}
+	csr, err := x509.ParseCertificateRequest(csrDER)
+	if err == nil {
+		err = csr.CheckSignature()
+	}
+	if err != nil {
+		acmeProblem(w, "badCSR", "invalid CSR: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The CSR must ask for exactly the names in the order. Names are
+	// compared once normalized and deduplicated, like the order's, so a
+	// repeated name can't stand in for a missing one.
+	var requested []acmeIdentifier
+	for _, name := range csr.DNSNames {
+		requested = append(requested, acmeIdentifier{Type: "dns", Value: name})
+	}
+	for _, ip := range csr.IPAddresses {
+		requested = append(requested, acmeIdentifier{Type: "ip", Value: ip.String()})
+	}
+	var names []acmeIdentifier
+	var dnsNames []string
+	var ips []net.IP
+	for _, id := range requested {
+		normalized, _ := normalizeACMEIdentifier(id)
+		if !slices.Contains(identifiers, normalized) {
+			acmeProblem(w, "badCSR", "CSR names "+normalized.Value+" which is not in the order", http.StatusBadRequest)
+			return
+		}
+		if slices.Contains(names, normalized) {
+			continue
+		}
+		names = append(names, normalized)
+		if normalized.Type == "dns" {
+			dnsNames = append(dnsNames, normalized.Value)
+		} else {
+			ips = append(ips, net.ParseIP(normalized.Value))
+		}
+	}
+	if len(names) != len(identifiers) {
+		acmeProblem(w, "badCSR", "CSR must name every identifier in the order", http.StatusBadRequest)
+		return
+	}
+	if cn := strings.ToLower(csr.Subject.CommonName); cn != "" &&
+		!slices.Contains(identifiers, acmeIdentifier{Type: "dns", Value: cn}) &&
+		!slices.Contains(identifiers, acmeIdentifier{Type: "ip", Value: cn}) {
+		acmeProblem(w, "badCSR", "CSR common name is not in the order", http.StatusBadRequest)
+		return
+	}
+
+	// The peer may have lost a name since the order was created
+	for _, id := range identifiers {
+		if !s.acmeOwns(req.peer, id) {
+			acmeProblem(w, "unauthorized", "peer "+req.peer+" no longer owns "+id.Value, http.StatusForbidden)
+			return
+		}
+	}
+
+	// Claim the order before signing, so concurrent finalize requests
+	// can't both issue a certificate for it
+	s.acme.mu.Lock()
+	if order.status != acmeStatusReady {
+		status := order.status
+		s.acme.mu.Unlock()
+		acmeProblem(w, "orderNotReady", "order is "+status, http.StatusForbidden)
+		return
+	}
+	order.status = acmeStatusProcessing
+	s.acme.mu.Unlock()
+
+	certPEM, err := s.ca.IssueServiceCert(req.peer, dnsNames, ips, csr.PublicKey)
+	if err != nil {
+		s.acme.mu.Lock()
+		order.status = acmeStatusInvalid
+		s.acme.mu.Unlock()
+		log.Warn().Err(err).Str("peer", req.peer).Msg("failed to issue ACME certificate")
+		acmeProblem(w, "serverInternal", "failed to issue certificate", http.StatusInternalServerError)
+		return
+	}
+	s.saveIssuedCerts(r.Context())
+
+	certID := rand.Text()
+	s.acme.mu.Lock()
+	s.acme.certs[certID] = &acmeCert{
+		accountID: req.accountID,
+		chainPEM:  append(certPEM, s.ca.CACertPEM()...),
+		notAfter:  time.Now().Add(s.ca.CertLifetime()),
+	}
+	order.status = acmeStatusValid
+	order.certID = certID
+	obj := acmeOrderObject(r, id, order)
+	s.acme.mu.Unlock()
+
+	log.Info().Str("peer", req.peer).Strs("names", dnsNames).Msg("issued ACME certificate")
+	acmeJSON(w, http.StatusOK, acmeURL(r, "order/"+id), obj)
+}
+
+// acmeAuthzObject is the JSON representation of an authorization and its
+// one, already valid, challenge.
+func acmeAuthzObject(r *http.Request, id string, authz *acmeAuthz) map[string]any {
+	return map[string]any{
+		"status":     acmeStatusValid,
+		"expires":    authz.expires.UTC().Format(time.RFC3339),
+		"identifier": authz.identifier,
+		"challenges": []any{acmeChallengeObject(r, id, authz)},
+	}
+}
+
+func acmeChallengeObject(r *http.Request, id string, authz *acmeAuthz) map[string]any {
+	return map[string]any{
+		"type":      "http-01",
+		"url":       acmeURL(r, "chall/"+id),
+		"token":     authz.token,
+		"status":    acmeStatusValid,
+		"validated": authz.validated.UTC().Format(time.RFC3339),
+	}
+}
+
+// lookupAuthz returns an authorization of the signing account, or writes a
+// problem document and returns nil.
+func (s *Server) lookupAuthz(w http.ResponseWriter, req *acmeRequest, id string) *acmeAuthz {
+	s.acme.mu.Lock()
+	authz, ok := s.acme.authzs[id]
+	s.acme.mu.Unlock()
+	if !ok || authz.accountID != req.accountID {
+		acmeProblem(w, "malformed", "authorization not found", http.StatusNotFound)
+		return nil
+	}
+	return authz
+}
+
+func (s *Server) acmeGetAuthz(w http.ResponseWriter, r *http.Request, req *acmeRequest, id string) {
+	if authz := s.lookupAuthz(w, req, id); authz != nil {
+		acmeJSON(w, http.StatusOK, "", acmeAuthzObject(r, id, authz))
+	}
+}
+
+func (s *Server) acmeGetChallenge(w http.ResponseWriter, r *http.Request, req *acmeRequest, id string) {
+	if authz := s.lookupAuthz(w, req, id); authz != nil {
+		w.Header().Add("Link", "<"+acmeURL(r, "authz/"+id)+`>;rel="up"`)
+		acmeJSON(w, http.StatusOK, "", acmeChallengeObject(r, id, authz))
+	}
+}
+
+func (s *Server) acmeGetCert(w http.ResponseWriter, req *acmeRequest, id string) {
+	s.acme.mu.Lock()
+	cert, ok := s.acme.certs[id]
+	s.acme.mu.Unlock()
+	if !ok || cert.accountID != req.accountID {
+		acmeProblem(w, "malformed", "certificate not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pem-certificate-chain")
+	_, _ = w.Write(cert.chainPEM)
+}
+
+func (s *Server) acmeRevokeCert(w http.ResponseWriter, r *http.Request, req *acmeRequest) {
+	var payload struct {
+		Certificate string `json:"certificate"`
+	}
+	if err := json.Unmarshal(req.payload, &payload); err != nil {
+		acmeProblem(w, "malformed", "invalid revoke-cert payload", http.StatusBadRequest)
+		return
+	}
+	der, err := b64.DecodeString(payload.Certificate)
+	var cert *x509.Certificate
+	if err == nil {
+		cert, err = x509.ParseCertificate(der)
+	}
+	if err != nil {
+		acmeProblem(w, "malformed", "invalid certificate", http.StatusBadRequest)
+		return
+	}
+
+	// A peer can revoke the certificates issued to it
+	serial := cert.SerialNumber.Text(16)
+	issued, ok := s.ca.IssuedCerts()[serial]
+	if !ok || issued.Peer != req.peer {
+		acmeProblem(w, "unauthorized", "certificate was not issued to this peer", http.StatusForbidden)
+		return
+	}
+	if !issued.RevokedAt.IsZero() {
+		acmeProblem(w, "alreadyRevoked", "certificate already revoked", http.StatusBadRequest)
+		return
+	}
+	if _, ok := s.ca.RevokeCert(serial); !ok {
+		acmeProblem(w, "malformed", "certificate expired", http.StatusBadRequest)
+		return
+	}
+	log.Info().Str("serial", serial).Str("peer", req.peer).Msg("certificate revoked over ACME")
+	s.saveIssuedCerts(r.Context())
+	s.broadcastTrustUpdate()
+	w.WriteHeader(http.StatusOK)
+}
+
+// saveACMEAccountsAsync persists the ACME accounts in the background.
+func (s *Server) saveACMEAccountsAsync() {
+	if s.s3SystemStore == nil {
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.acme.saveMu.Lock()
+		defer s.acme.saveMu.Unlock()
+
+		s.acme.mu.Lock()
+		accounts := make(map[string]s3.ACMEAccount, len(s.acme.accounts))
+		for id, account := range s.acme.accounts {
+			accounts[id] = account
+		}
+		s.acme.mu.Unlock()
+
+		if err := s.s3SystemStore.SaveACMEAccounts(context.Background(), accounts); err != nil {
+			log.Warn().Err(err).Msg("failed to persist ACME accounts")
+		}
+	}()
+}
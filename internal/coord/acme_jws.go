@@ -0,0 +1,170 @@
+package coord
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// jwsMessage is a JWS in flattened JSON serialization, as sent by ACME clients.
+type jwsMessage struct {
+	Protected string `json:"protected"`
+	Payload   string `json:"payload"`
+	Signature string `json:"signature"`
+}
+
+// jwsHeader is the protected header of an ACME request.
+type jwsHeader struct {
+	Alg   string          `json:"alg"`
+	Nonce string          `json:"nonce"`
+	URL   string          `json:"url"`
+	JWK   json.RawMessage `json:"jwk,omitempty"`
+	KID   string          `json:"kid,omitempty"`
+}
+
+// jsonWebKey holds the members of a JWK used by ACME account keys.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+var b64 = base64.RawURLEncoding
+
+// parseJWK parses an EC (P-256, P-384), RSA or Ed25519 public key.
+func parseJWK(data []byte) (crypto.PublicKey, *jsonWebKey, error) {
+	var jwk jsonWebKey
+	if err := json.Unmarshal(data, &jwk); err != nil {
+		return nil, nil, fmt.Errorf("invalid jwk: %w", err)
+	}
+
+	switch jwk.Kty {
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, errX := b64.DecodeString(jwk.X)
+		y, errY := b64.DecodeString(jwk.Y)
+		if errX != nil || errY != nil {
+			return nil, nil, errors.New("invalid EC key coordinates")
+		}
+		// Uncompressed point encoding, validated by ecdsa.ParseUncompressedPublicKey
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, nil, errors.New("invalid EC key coordinates")
+		}
+		point := append(append([]byte{4}, x...), y...)
+		key, err := ecdsa.ParseUncompressedPublicKey(curve, point)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid EC key: %w", err)
+		}
+		return key, &jwk, nil
+	case "RSA":
+		n, errN := b64.DecodeString(jwk.N)
+		e, errE := b64.DecodeString(jwk.E)
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			return nil, nil, errors.New("invalid RSA key")
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if key.N.BitLen() < 2048 {
+			return nil, nil, errors.New("RSA keys must be at least 2048 bits")
+		}
+		return key, &jwk, nil
+	case "OKP":
+		x, err := b64.DecodeString(jwk.X)
+		if jwk.Crv != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, nil, errors.New("invalid OKP key")
+		}
+		return ed25519.PublicKey(x), &jwk, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+}
+
+// thumbprint returns the RFC 7638 thumbprint of the key, base64url-encoded.
+func (jwk *jsonWebKey) thumbprint() string {
+	// Required members only, in lexicographic order
+	var canonical string
+	switch jwk.Kty {
+	case "EC":
+		canonical = fmt.Sprintf(`{"crv":%q,"kty":"EC","x":%q,"y":%q}`, jwk.Crv, jwk.X, jwk.Y)
+	case "RSA":
+		canonical = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, jwk.E, jwk.N)
+	case "OKP":
+		canonical = fmt.Sprintf(`{"crv":%q,"kty":"OKP","x":%q}`, jwk.Crv, jwk.X)
+	}
+	sum := sha256.Sum256([]byte(canonical))
+	return b64.EncodeToString(sum[:])
+}
+
+// verifyJWS checks the signature of a JWS made with the given key and algorithm.
+func verifyJWS(msg *jwsMessage, alg string, key crypto.PublicKey) error {
+	sig, err := b64.DecodeString(msg.Signature)
+	if err != nil {
+		return errors.New("invalid signature encoding")
+	}
+	signed := []byte(msg.Protected + "." + msg.Payload)
+
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		var digest []byte
+		switch {
+		case alg == "ES256" && k.Curve == elliptic.P256():
+			sum := sha256.Sum256(signed)
+			digest = sum[:]
+		case alg == "ES384" && k.Curve == elliptic.P384():
+			sum := sha512.Sum384(signed)
+			digest = sum[:]
+		default:
+			return fmt.Errorf("algorithm %q does not match the key", alg)
+		}
+		// Signature is r || s, each the size of the curve order
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errors.New("invalid signature length")
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errors.New("signature verification failed")
+		}
+		return nil
+	case *rsa.PublicKey:
+		if alg != "RS256" {
+			return fmt.Errorf("algorithm %q does not match the key", alg)
+		}
+		sum := sha256.Sum256(signed)
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, sum[:], sig); err != nil {
+			return errors.New("signature verification failed")
+		}
+		return nil
+	case ed25519.PublicKey:
+		if alg != "EdDSA" {
+			return fmt.Errorf("algorithm %q does not match the key", alg)
+		}
+		if !ed25519.Verify(k, signed, sig) {
+			return errors.New("signature verification failed")
+		}
+		return nil
+	default:
+		return errors.New("unsupported key")
+	}
+}
@@ -0,0 +1,203 @@
+package coord
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/acme"
+)
+
+// newTestACME starts the admin mux with peers "laptop" (alias "web") and
+// "desktop" registered, laptop's mesh IP being the loopback address the
+// test client connects from. Returns an ACME client with a new account key.
+func newTestACME(t *testing.T) (*Server, *acme.Client) {
+	t.Helper()
+	srv := newTestServerWithS3(t)
+	useTempCA(t, srv)
+
+	for _, name := range []string{"laptop", "desktop"} {
+		pubKey, _ := generateTestSSHPubKey(t)
+		rec := registerWithToken(t, srv, "test-token", name, pubKey)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	}
+	srv.peersMu.Lock()
+	srv.peers["laptop"].peer.MeshIP = "127.0.0.1"
+	srv.peers["laptop"].aliases = []string{"web"}
+	srv.peersMu.Unlock()
+
+	ts := httptest.NewServer(srv.adminMux)
+	t.Cleanup(ts.Close)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return srv, &acme.Client{Key: key, DirectoryURL: ts.URL + "/acme/directory"}
+}
+
+func newTestCSR(t *testing.T, dnsNames []string, ips []net.IP) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		DNSNames:    dnsNames,
+		IPAddresses: ips,
+	}, key)
+	require.NoError(t, err)
+	return csr
+}
+
+func requireACMEError(t *testing.T, err error, problemType string) {
+	t.Helper()
+	var acmeErr *acme.Error
+	require.True(t, errors.As(err, &acmeErr), "expected an ACME error, got %v", err)
+	assert.Equal(t, "urn:ietf:params:acme:error:"+problemType, acmeErr.ProblemType)
+}
+
+func TestACME_IssueOwnNames(t *testing.T) {
+	srv, client := newTestACME(t)
+	ctx := t.Context()
+
+	_, err := client.Register(ctx, &acme.Account{}, acme.AcceptTOS)
+	require.NoError(t, err)
+
+	order, err := client.AuthorizeOrder(ctx, append(
+		acme.DomainIDs("laptop.tunnelmesh", "WEB.tunnelmesh."),
+		acme.IPIDs("127.0.0.1")...))
+	require.NoError(t, err)
+	assert.Equal(t, acme.StatusReady, order.Status)
+	require.Len(t, order.AuthzURLs, 3)
+	authz, err := client.GetAuthorization(ctx, order.AuthzURLs[0])
+	require.NoError(t, err)
+	assert.Equal(t, acme.StatusValid, authz.Status)
+
+	csr := newTestCSR(t, []string{"laptop.tunnelmesh", "web.tunnelmesh"}, []net.IP{net.ParseIP("127.0.0.1")})
+	der, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	require.NoError(t, err)
+	require.Len(t, der, 3, "leaf, service intermediate and CA")
+
+	cert, err := x509.ParseCertificate(der[0])
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"laptop.tunnelmesh", "web.tunnelmesh"}, cert.DNSNames)
+	assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, cert.ExtKeyUsage)
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(srv.ca.CACertPEM())
+	intermediates := x509.NewCertPool()
+	intermediate, err := x509.ParseCertificate(der[1])
+	require.NoError(t, err)
+	intermediates.AddCert(intermediate)
+	_, err = cert.Verify(x509.VerifyOptions{DNSName: "web.tunnelmesh", Roots: roots, Intermediates: intermediates})
+	require.NoError(t, err)
+	_, err = cert.Verify(x509.VerifyOptions{DNSName: "web.tunnelmesh", Roots: roots})
+	assert.Error(t, err, "not signed by the root itself")
+
+	issued := srv.ca.IssuedCerts()[cert.SerialNumber.Text(16)]
+	assert.Equal(t, "laptop", issued.Peer)
+
+	// The peer can revoke its certificate
+	require.NoError(t, client.RevokeCert(ctx, nil, der[0], acme.CRLReasonUnspecified))
+	assert.True(t, srv.ca.IsRevoked(cert.SerialNumber))
+
+	// The account survives a restart
+	srv.wg.Wait()
+	accounts, err := srv.s3SystemStore.LoadACMEAccounts(ctx)
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	for _, account := range accounts {
+		assert.Equal(t, "laptop", account.Peer)
+	}
+}
+
+func TestACME_RejectsOtherPeersNames(t *testing.T) {
+	_, client := newTestACME(t)
+	ctx := t.Context()
+
+	_, err := client.Register(ctx, &acme.Account{}, acme.AcceptTOS)
+	require.NoError(t, err)
+
+	for _, ids := range [][]acme.AuthzID{
+		acme.DomainIDs("desktop.tunnelmesh"),
+		acme.DomainIDs("laptop.tunnelmesh", "desktop.tm"),
+		acme.DomainIDs("*.laptop.tunnelmesh"),
+		acme.DomainIDs("laptop.example.com"),
+		acme.IPIDs("10.99.0.1"),
+	} {
+		_, err := client.AuthorizeOrder(ctx, ids)
+		requireACMEError(t, err, "rejectedIdentifier")
+	}
+
+	// The CSR must match the order
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs("laptop.tunnelmesh"))
+	require.NoError(t, err)
+	csr := newTestCSR(t, []string{"laptop.tunnelmesh", "desktop.tunnelmesh"}, nil)
+	_, _, err = client.CreateOrderCert(ctx, order.FinalizeURL, csr, false)
+	requireACMEError(t, err, "badCSR")
+
+	// A repeated name doesn't make up for a missing one
+	order, err = client.AuthorizeOrder(ctx, acme.DomainIDs("laptop.tunnelmesh", "web.tunnelmesh"))
+	require.NoError(t, err)
+	csr = newTestCSR(t, []string{"laptop.tunnelmesh", "LAPTOP.tunnelmesh"}, nil)
+	_, _, err = client.CreateOrderCert(ctx, order.FinalizeURL, csr, false)
+	requireACMEError(t, err, "badCSR")
+}
+
+func TestACME_FinalizeIssuesOnce(t *testing.T) {
+	srv, client := newTestACME(t)
+	ctx := t.Context()
+
+	_, err := client.Register(ctx, &acme.Account{}, acme.AcceptTOS)
+	require.NoError(t, err)
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs("laptop.tunnelmesh"))
+	require.NoError(t, err)
+	before := len(srv.ca.IssuedCerts())
+
+	const requests = 8
+	var wg sync.WaitGroup
+	errs := make([]error, requests)
+	for i := range requests {
+		wg.Go(func() {
+			csr := newTestCSR(t, []string{"laptop.tunnelmesh"}, nil)
+			_, _, errs[i] = client.CreateOrderCert(ctx, order.FinalizeURL, csr, false)
+		})
+	}
+	wg.Wait()
+
+	issued := 0
+	for _, err := range errs {
+		if err == nil {
+			issued++
+		} else {
+			requireACMEError(t, err, "orderNotReady")
+		}
+	}
+	assert.Equal(t, 1, issued)
+	assert.Len(t, srv.ca.IssuedCerts(), before+1)
+}
+
+func TestACME_RequiresMeshPeer(t *testing.T) {
+	srv, client := newTestACME(t)
+	srv.peersMu.Lock()
+	srv.peers["laptop"].peer.MeshIP = "10.42.0.9"
+	srv.peersMu.Unlock()
+
+	_, err := client.Register(t.Context(), &acme.Account{}, acme.AcceptTOS)
+	requireACMEError(t, err, "unauthorized")
+}
+
+func TestACME_RejectsReplayedNonce(t *testing.T) {
+	srv := newTestServerWithS3(t)
+	useTempCA(t, srv)
+
+	nonce := srv.acme.newNonce()
+	assert.True(t, srv.acme.useNonce(nonce))
+	assert.False(t, srv.acme.useNonce(nonce))
+	assert.False(t, srv.acme.useNonce("unknown"))
+}
@@ -6,6 +6,7 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -125,11 +126,18 @@ type AdminPeerInfo struct {
 	Version             string             `json:"version,omitempty"`
 	Location            *proto.GeoLocation `json:"location,omitempty"`
 	// Exit node info
-	AllowsExitTraffic bool     `json:"allows_exit_traffic,omitempty"`
-	ExitPeer          string   `json:"exit_node,omitempty"`
-	ExitClients       []string `json:"exit_clients,omitempty"`
+	AllowsExitTraffic bool              `json:"allows_exit_traffic,omitempty"`
+	ExitPeer          string            `json:"exit_node,omitempty"`
+	ExitPeers         []string          `json:"exit_peers,omitempty"`  // Configured exit peers, in order of preference
+	ExitRoutes        map[string]string `json:"exit_routes,omitempty"` // Exit policy name -> exit peer in use
+	ExitClients       []string          `json:"exit_clients,omitempty"`
+	ExitAllowTags     []string          `json:"exit_allow_tags,omitempty"` // Tags of the peers allowed to use this exit peer
 	// Connection info (peer -> transport type)
 	Connections map[string]string `json:"connections,omitempty"`
+	// Discovered path MTU of UDP connections (peer -> MTU)
+	PathMTUs map[string]int `json:"path_mtus,omitempty"`
+	// Tags assigned by an admin or join key
+	Tags []string `json:"tags,omitempty"`
 	// DNS aliases for this peer
 	Aliases []string `json:"aliases,omitempty"`
 	// Latency metrics
@@ -166,8 +174,17 @@ func (s *Server) handleAdminOverview(w http.ResponseWriter, r *http.Request) {
 	// Build exit client map (which clients use which exit node)
 	exitClients := make(map[string][]string) // exitNodeName -> [clientNames]
 	for _, info := range s.peers {
+		exits := make([]string, 0, 1+len(info.peer.ExitRoutes))
 		if info.peer.ExitPeer != "" {
-			exitClients[info.peer.ExitPeer] = append(exitClients[info.peer.ExitPeer], info.peer.Name)
+			exits = append(exits, info.peer.ExitPeer)
+		}
+		for _, exit := range info.peer.ExitRoutes {
+			if exit != "" && !slices.Contains(exits, exit) {
+				exits = append(exits, exit)
+			}
+		}
+		for _, exit := range exits {
+			exitClients[exit] = append(exitClients[exit], info.peer.Name)
 		}
 	}
 
@@ -194,7 +211,11 @@ func (s *Server) handleAdminOverview(w http.ResponseWriter, r *http.Request) {
 			Version:           info.peer.Version,
 			AllowsExitTraffic: info.peer.AllowsExitTraffic,
 			ExitPeer:          info.peer.ExitPeer,
+			ExitPeers:         info.peer.ExitPeers,
+			ExitRoutes:        info.peer.ExitRoutes,
+			ExitAllowTags:     info.peer.ExitAllowTags,
 			Aliases:           info.aliases,
+			Tags:              info.peer.Tags,
 		}
 
 		// Include exit clients if this peer allows exit traffic
@@ -206,6 +227,9 @@ func (s *Server) handleAdminOverview(w http.ResponseWriter, r *http.Request) {
 		if info.stats != nil && len(info.stats.Connections) > 0 {
 			peerInfo.Connections = info.stats.Connections
 		}
+		if info.stats != nil && len(info.stats.PathMTUs) > 0 {
+			peerInfo.PathMTUs = info.stats.PathMTUs
+		}
 
 		// Include latency metrics
 		peerInfo.CoordinatorRTTMs = info.coordinatorRTT
@@ -277,6 +301,7 @@ func (s *Server) setupAdminRoutes() {
 
 	// Filter rule management
 	s.adminMux.HandleFunc("/api/filter/rules", s.handleFilterRules)
+	s.adminMux.HandleFunc("/api/filter/flows", s.handleFilterFlows)
 
 	// Group management API
 	s.adminMux.HandleFunc("/api/groups", s.handleGroups)
@@ -295,6 +320,31 @@ func (s *Server) setupAdminRoutes() {
 
 	// DNS records
 	s.adminMux.HandleFunc("/api/dns", s.handleDNS)
+	s.adminMux.HandleFunc("/api/dns/records", s.handleDNSRecords)
+	s.adminMux.HandleFunc("/api/dns/records/", s.handleDNSRecordByName)
+
+	// Subnet routes advertised by peers
+	s.adminMux.HandleFunc("/api/routes", s.handleRoutes)
+	s.adminMux.HandleFunc("/api/routes/approve", s.handleRouteApprove)
+	s.adminMux.HandleFunc("/api/routes/revoke", s.handleRouteRevoke)
+
+	// Peer tags, for tag-based filter rules, groups and exit permissions
+	s.adminMux.HandleFunc("/api/tags", s.handleTags)
+
+	// Join keys and peer revocation
+	s.adminMux.HandleFunc("/api/keys", s.handleJoinKeys)
+	s.adminMux.HandleFunc("/api/keys/", s.handleJoinKeyByID)
+	s.adminMux.HandleFunc("/api/revoked-peers", s.handleRevokedPeers)
+	s.adminMux.HandleFunc("/api/revoked-peers/", s.handleRevokedPeerByID)
+
+	// Mesh CA: status, staged root rollover and certificate revocation
+	s.adminMux.HandleFunc("/api/ca", s.handleCA)
+	s.adminMux.HandleFunc("/api/ca/rotate", s.handleCARotate)
+	s.adminMux.HandleFunc("/api/ca/promote", s.handleCAPromote)
+	s.adminMux.HandleFunc("/api/ca/revoke", s.handleCARevoke)
+
+	// ACME (RFC 8555) for certificates for the peers' own services
+	s.adminMux.HandleFunc("/acme/", s.handleACME)
 
 	// S3 bucket management API (specific routes before proxy catch-all)
 	s.adminMux.HandleFunc("/api/s3/buckets", func(w http.ResponseWriter, r *http.Request) {
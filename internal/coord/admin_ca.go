@@ -0,0 +1,116 @@
+package coord
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+)
+
+// rolloverWindowFactor is the transition window after a root is promoted, in
+// certificate lifetimes. Peers renew at two thirds of the lifetime, so by the
+// end of the window every certificate signed by the retired root has expired.
+const rolloverWindowFactor = 2
+
+// requireCAAdmin checks that a CA request comes from an admin. Writes an
+// error response and returns false otherwise.
+func (s *Server) requireCAAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if s.ca == nil {
+		s.jsonError(w, "CA not initialized", http.StatusServiceUnavailable)
+		return false
+	}
+	if s.s3Authorizer == nil || !s.s3Authorizer.IsAdmin(s.getRequestOwner(r)) {
+		s.jsonError(w, "admin access required", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// handleCA returns the CA's roots and the unexpired certificates it issued.
+func (s *Server) handleCA(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.requireCAAdmin(w, r) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.ca.Status())
+}
+
+// handleCARotate stages a new root. Peers are sent the new trust bundle; the
+// current root keeps signing until the new root is promoted.
+func (s *Server) handleCARotate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.requireCAAdmin(w, r) {
+		return
+	}
+
+	if err := s.ca.Stage(); err != nil {
+		s.jsonError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	s.broadcastTrustUpdate()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.ca.Status())
+}
+
+// handleCAPromote makes the staged root the signing root. Connected peers
+// get the new trust bundle and renew their certificates with the new root;
+// the old root stays trusted for the transition window.
+func (s *Server) handleCAPromote(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.requireCAAdmin(w, r) {
+		return
+	}
+
+	if err := s.ca.Promote(rolloverWindowFactor * s.ca.CertLifetime()); err != nil {
+		s.jsonError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	s.broadcastTrustUpdate()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.ca.Status())
+}
+
+// handleCARevoke revokes a single certificate by serial number and publishes
+// the new CRL.
+func (s *Server) handleCARevoke(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.requireCAAdmin(w, r) {
+		return
+	}
+
+	var req struct {
+		Serial string `json:"serial"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Serial == "" {
+		s.jsonError(w, "serial is required", http.StatusBadRequest)
+		return
+	}
+
+	cert, ok := s.ca.RevokeCert(req.Serial)
+	if !ok {
+		s.jsonError(w, "certificate not found or expired", http.StatusNotFound)
+		return
+	}
+	log.Info().Str("serial", cert.Serial).Str("peer", cert.Peer).Msg("certificate revoked")
+	s.saveIssuedCerts(r.Context())
+	s.broadcastTrustUpdate()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(cert)
+}
@@ -0,0 +1,132 @@
+package coord
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
+)
+
+// useTempCA replaces the server's CA with one kept in a temp directory, so
+// rollover files are not written to the working directory.
+func useTempCA(t *testing.T, srv *Server) *CertificateAuthority {
+	t.Helper()
+	ca, err := NewCertificateAuthority(t.TempDir(), "")
+	require.NoError(t, err)
+	srv.ca = ca
+	return ca
+}
+
+func decodeCAStatus(t *testing.T, rec *httptest.ResponseRecorder) proto.CAStatus {
+	t.Helper()
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var status proto.CAStatus
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&status))
+	return status
+}
+
+func TestCAAPI_RequireAdmin(t *testing.T) {
+	srv := newTestServerWithS3(t)
+	useTempCA(t, srv)
+
+	for _, path := range []string{"/api/ca/rotate", "/api/ca/promote", "/api/ca/revoke"} {
+		rec := doAdminRequest(t, srv, http.MethodPost, path, nil)
+		assert.Equal(t, http.StatusForbidden, rec.Code, path)
+	}
+	rec := doAdminRequest(t, srv, http.MethodGet, "/api/ca", nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestCAAPI_Rollover(t *testing.T) {
+	srv := newTestServerWithS3(t)
+	makeTestAdmin(srv)
+	useTempCA(t, srv)
+
+	status := decodeCAStatus(t, doAdminRequest(t, srv, http.MethodGet, "/api/ca", nil))
+	require.Len(t, status.Roots, 1)
+	assert.Equal(t, DefaultCertLifetime.String(), status.CertLifetime)
+	signing := status.Roots[0].Fingerprint
+
+	rec := doAdminRequest(t, srv, http.MethodPost, "/api/ca/promote", nil)
+	assert.Equal(t, http.StatusConflict, rec.Code, "nothing staged")
+
+	status = decodeCAStatus(t, doAdminRequest(t, srv, http.MethodPost, "/api/ca/rotate", nil))
+	require.Len(t, status.Roots, 2)
+	assert.Equal(t, proto.CARootStaged, status.Roots[1].State)
+	staged := status.Roots[1].Fingerprint
+
+	// The trust bundle served to peers includes the staged root
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ca.crt", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 2, bytes.Count(rec.Body.Bytes(), []byte("BEGIN CERTIFICATE")))
+
+	status = decodeCAStatus(t, doAdminRequest(t, srv, http.MethodPost, "/api/ca/promote", nil))
+	require.Len(t, status.Roots, 2)
+	assert.Equal(t, staged, status.Roots[0].Fingerprint)
+	assert.Equal(t, signing, status.Roots[1].Fingerprint)
+	assert.Equal(t, proto.CARootRetired, status.Roots[1].State)
+}
+
+func TestCAAPI_RevokeCert(t *testing.T) {
+	srv := newTestServerWithS3(t)
+	makeTestAdmin(srv)
+	ca := useTempCA(t, srv)
+
+	certPEM, _, err := ca.GeneratePeerCert("laptop", "", "10.42.0.5")
+	require.NoError(t, err)
+	cert := parseCertPEM(t, certPEM)
+	require.NoError(t, srv.verifyClientCert([][]byte{cert.Raw}, nil))
+
+	rec := doAdminRequest(t, srv, http.MethodPost, "/api/ca/revoke", map[string]string{})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = doAdminRequest(t, srv, http.MethodPost, "/api/ca/revoke", map[string]string{"serial": "deadbeef"})
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = doAdminRequest(t, srv, http.MethodPost, "/api/ca/revoke", map[string]string{"serial": cert.SerialNumber.Text(16)})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var revoked proto.IssuedCert
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&revoked))
+	assert.Equal(t, "laptop", revoked.Peer)
+
+	// Rejected by the coordinator and listed on the CRL
+	assert.Error(t, srv.verifyClientCert([][]byte{cert.Raw}, nil))
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ca.crl", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/pkix-crl", rec.Header().Get("Content-Type"))
+	crl, err := x509.ParseRevocationList(rec.Body.Bytes())
+	require.NoError(t, err)
+	require.Len(t, crl.RevokedCertificateEntries, 1)
+	assert.Equal(t, 0, crl.RevokedCertificateEntries[0].SerialNumber.Cmp(cert.SerialNumber))
+
+	// Persisted for recovery
+	srv.wg.Wait()
+	stored, err := srv.s3SystemStore.LoadIssuedCerts(t.Context())
+	require.NoError(t, err)
+	require.Contains(t, stored, revoked.Serial)
+	assert.False(t, stored[revoked.Serial].RevokedAt.IsZero())
+}
+
+func TestRevokedPeers_RevokesCerts(t *testing.T) {
+	srv := newTestServerWithS3(t)
+	makeTestAdmin(srv)
+	useTempCA(t, srv)
+
+	pubKey, _ := generateTestSSHPubKey(t)
+	rec := registerWithToken(t, srv, "test-token", "laptop", pubKey)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp proto.RegisterResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	cert := parseCertPEM(t, []byte(resp.TLSCert))
+
+	rec = doAdminRequest(t, srv, http.MethodPost, "/api/revoked-peers", map[string]string{"peer": "laptop"})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.True(t, srv.ca.IsRevoked(cert.SerialNumber))
+}
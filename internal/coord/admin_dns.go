@@ -0,0 +1,220 @@
+package coord
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tunnelmesh/tunnelmesh/internal/mesh"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
+)
+
+// handleDNSRecords handles GET (list) and POST (add) for custom DNS records.
+func (s *Server) handleDNSRecords(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.peersMu.RLock()
+		records := slices.Clone(s.dnsZone)
+		s.peersMu.RUnlock()
+		if records == nil {
+			records = []proto.DNSZoneRecord{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(records)
+	case http.MethodPost:
+		s.handleDNSRecordAdd(w, r)
+	default:
+		s.jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleDNSRecordAdd adds values to a record set, creating it if needed, so
+// repeated adds of A records build a round-robin name. A non-zero TTL replaces
+// the set's TTL.
+func (s *Server) handleDNSRecordAdd(w http.ResponseWriter, r *http.Request) {
+	var req proto.DNSZoneRecord
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.jsonError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Name = normalizeZoneName(req.Name)
+	req.Type = strings.ToUpper(strings.TrimSpace(req.Type))
+	for i, v := range req.Values {
+		if req.Type != "TXT" {
+			req.Values[i] = strings.TrimSpace(v)
+		}
+	}
+	if err := req.Validate(); err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.peersMu.Lock()
+	if req.Name == "this" {
+		s.peersMu.Unlock()
+		s.jsonError(w, `"this" is reserved for the coordinators`, http.StatusConflict)
+		return
+	}
+	if _, exists := s.dnsCache[req.Name]; exists {
+		s.peersMu.Unlock()
+		s.jsonError(w, fmt.Sprintf("%q is already a peer name or alias", req.Name), http.StatusConflict)
+		return
+	}
+
+	idx := -1
+	for i, rec := range s.dnsZone {
+		if rec.Name != req.Name {
+			continue
+		}
+		if rec.Type == req.Type {
+			idx = i
+		} else if rec.Type == "CNAME" || req.Type == "CNAME" {
+			s.peersMu.Unlock()
+			s.jsonError(w, fmt.Sprintf("%s already has a %s record; a CNAME cannot coexist with other records", req.Name, rec.Type), http.StatusConflict)
+			return
+		}
+	}
+
+	merged := req
+	if idx >= 0 {
+		merged = s.dnsZone[idx]
+		merged.Values = slices.Clone(merged.Values)
+		for _, v := range req.Values {
+			if !slices.Contains(merged.Values, v) {
+				merged.Values = append(merged.Values, v)
+			}
+		}
+		if req.TTL != 0 {
+			merged.TTL = req.TTL
+		}
+		if err := merged.Validate(); err != nil {
+			s.peersMu.Unlock()
+			s.jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.dnsZone[idx] = merged
+	} else {
+		s.dnsZone = append(s.dnsZone, merged)
+		sortZoneRecords(s.dnsZone)
+	}
+	s.peersMu.Unlock()
+
+	log.Info().Str("name", merged.Name).Str("type", merged.Type).Strs("values", merged.Values).Msg("DNS record updated")
+	s.saveDNSZone(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(merged)
+}
+
+// handleDNSRecordByName handles GET and DELETE for the records of one name:
+// /api/dns/records/{name}[?type=A[&value=10.42.0.5]]. DELETE removes all
+// records of the name, all of one type, or a single value.
+func (s *Server) handleDNSRecordByName(w http.ResponseWriter, r *http.Request) {
+	name := normalizeZoneName(strings.TrimPrefix(r.URL.Path, "/api/dns/records/"))
+	if name == "" {
+		s.jsonError(w, "record name required", http.StatusBadRequest)
+		return
+	}
+	rrtype := strings.ToUpper(r.URL.Query().Get("type"))
+	value := r.URL.Query().Get("value")
+	if value != "" && rrtype == "" {
+		s.jsonError(w, "type is required when removing a single value", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.peersMu.RLock()
+		var records []proto.DNSZoneRecord
+		for _, rec := range s.dnsZone {
+			if rec.Name == name && (rrtype == "" || rec.Type == rrtype) {
+				records = append(records, rec)
+			}
+		}
+		s.peersMu.RUnlock()
+		if len(records) == 0 {
+			s.jsonError(w, "record not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(records)
+
+	case http.MethodDelete:
+		s.peersMu.Lock()
+		removed := false
+		kept := s.dnsZone[:0]
+		for _, rec := range s.dnsZone {
+			if rec.Name != name || (rrtype != "" && rec.Type != rrtype) {
+				kept = append(kept, rec)
+				continue
+			}
+			if value == "" {
+				removed = true
+				continue
+			}
+			if i := slices.Index(rec.Values, value); i >= 0 {
+				removed = true
+				rec.Values = slices.Delete(slices.Clone(rec.Values), i, i+1)
+			}
+			if len(rec.Values) > 0 {
+				kept = append(kept, rec)
+			}
+		}
+		s.dnsZone = kept
+		s.peersMu.Unlock()
+
+		if !removed {
+			s.jsonError(w, "record not found", http.StatusNotFound)
+			return
+		}
+		log.Info().Str("name", name).Str("type", rrtype).Str("value", value).Msg("DNS record removed")
+		s.saveDNSZone(r.Context())
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"status": "deleted"})
+
+	default:
+		s.jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// saveDNSZone persists the custom DNS records to S3.
+func (s *Server) saveDNSZone(ctx context.Context) {
+	if s.s3SystemStore == nil {
+		return
+	}
+	s.peersMu.RLock()
+	records := slices.Clone(s.dnsZone)
+	s.peersMu.RUnlock()
+
+	if err := s.s3SystemStore.SaveDNSZone(ctx, records); err != nil {
+		log.Warn().Err(err).Msg("failed to persist DNS zone")
+	}
+}
+
+// normalizeZoneName lowercases a record name and strips any mesh domain suffix.
+func normalizeZoneName(name string) string {
+	name = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+	for _, suffix := range mesh.AllSuffixes() {
+		if strings.HasSuffix(name, suffix) {
+			return strings.TrimSuffix(name, suffix)
+		}
+	}
+	return name
+}
+
+// sortZoneRecords orders records by name, then type.
+func sortZoneRecords(records []proto.DNSZoneRecord) {
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Name != records[j].Name {
+			return records[i].Name < records[j].Name
+		}
+		return records[i].Type < records[j].Type
+	})
+}
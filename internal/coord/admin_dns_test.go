@@ -0,0 +1,133 @@
+package coord
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
+)
+
+func doAdminRequest(t *testing.T, srv *Server, method, target string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, target, reader)
+	rec := httptest.NewRecorder()
+	srv.adminMux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestDNSRecords_AddMergesValues(t *testing.T) {
+	srv := newTestServerWithS3AndBucket(t)
+
+	rec := doAdminRequest(t, srv, http.MethodPost, "/api/dns/records",
+		proto.DNSZoneRecord{Name: "Pool.tunnelmesh", Type: "a", Values: []string{"10.42.0.1"}})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	rec = doAdminRequest(t, srv, http.MethodPost, "/api/dns/records",
+		proto.DNSZoneRecord{Name: "pool", Type: "A", Values: []string{"10.42.0.2", "10.42.0.1"}, TTL: 30})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	var got proto.DNSZoneRecord
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+	assert.Equal(t, proto.DNSZoneRecord{Name: "pool", Type: "A", Values: []string{"10.42.0.1", "10.42.0.2"}, TTL: 30}, got)
+
+	rec = doAdminRequest(t, srv, http.MethodGet, "/api/dns/records", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var list []proto.DNSZoneRecord
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&list))
+	assert.Equal(t, []proto.DNSZoneRecord{got}, list)
+
+	// Persisted for recovery
+	zone, err := srv.s3SystemStore.LoadDNSZone(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, list, zone)
+
+	// Served to peers with the DNS records
+	rec = doAdminRequest(t, srv, http.MethodGet, "/api/dns", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var update proto.DNSUpdateNotification
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&update))
+	assert.Equal(t, list, update.Zone)
+}
+
+func TestDNSRecords_AddRejectsConflicts(t *testing.T) {
+	srv := newTestServerWithS3AndBucket(t)
+	srv.peersMu.Lock()
+	srv.dnsCache["web"] = "10.42.0.5"
+	srv.peersMu.Unlock()
+
+	rec := doAdminRequest(t, srv, http.MethodPost, "/api/dns/records",
+		proto.DNSZoneRecord{Name: "web", Type: "A", Values: []string{"10.42.0.9"}})
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = doAdminRequest(t, srv, http.MethodPost, "/api/dns/records",
+		proto.DNSZoneRecord{Name: "this", Type: "A", Values: []string{"10.42.0.9"}})
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = doAdminRequest(t, srv, http.MethodPost, "/api/dns/records",
+		proto.DNSZoneRecord{Name: "grafana.ops", Type: "CNAME", Values: []string{"web"}})
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	// A CNAME excludes other records, and has a single target
+	rec = doAdminRequest(t, srv, http.MethodPost, "/api/dns/records",
+		proto.DNSZoneRecord{Name: "grafana.ops", Type: "TXT", Values: []string{"owner=ops"}})
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	rec = doAdminRequest(t, srv, http.MethodPost, "/api/dns/records",
+		proto.DNSZoneRecord{Name: "grafana.ops", Type: "CNAME", Values: []string{"db"}})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = doAdminRequest(t, srv, http.MethodPost, "/api/dns/records",
+		proto.DNSZoneRecord{Name: "bad", Type: "A", Values: []string{"not-an-ip"}})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestDNSRecordByName_Delete(t *testing.T) {
+	srv := newTestServerWithS3AndBucket(t)
+
+	for _, r := range []proto.DNSZoneRecord{
+		{Name: "pool", Type: "A", Values: []string{"10.42.0.1", "10.42.0.2"}},
+		{Name: "pool", Type: "TXT", Values: []string{"round-robin"}},
+	} {
+		rec := doAdminRequest(t, srv, http.MethodPost, "/api/dns/records", r)
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	rec := doAdminRequest(t, srv, http.MethodGet, "/api/dns/records/pool", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var got []proto.DNSZoneRecord
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+	assert.Len(t, got, 2)
+
+	// A single value
+	rec = doAdminRequest(t, srv, http.MethodDelete, "/api/dns/records/pool?type=A&value=10.42.0.1", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	srv.peersMu.RLock()
+	assert.Equal(t, []string{"10.42.0.2"}, srv.dnsZone[0].Values)
+	srv.peersMu.RUnlock()
+
+	rec = doAdminRequest(t, srv, http.MethodDelete, "/api/dns/records/pool?type=A&value=10.42.0.1", nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// Everything under the name
+	rec = doAdminRequest(t, srv, http.MethodDelete, "/api/dns/records/pool.tunnelmesh", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	rec = doAdminRequest(t, srv, http.MethodGet, "/api/dns/records/pool", nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	zone, err := srv.s3SystemStore.LoadDNSZone(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, zone)
+}
@@ -11,12 +11,47 @@ import (
 
 // FilterRulesRequest is the request for adding/removing filter rules.
 type FilterRulesRequest struct {
-	PeerName   string `json:"peer"`        // Target peer name
-	Port       uint16 `json:"port"`        // Port number
-	Protocol   string `json:"protocol"`    // "tcp" or "udp"
-	Action     string `json:"action"`      // "allow" or "deny"
-	SourcePeer string `json:"source_peer"` // Source peer (optional, empty = any peer)
-	TTL        int64  `json:"ttl"`         // Time to live in seconds (0 = permanent)
+	PeerName    string `json:"peer"`                   // Target peer name
+	Port        uint16 `json:"port"`                   // Port number, first of a range with port_end
+	PortEnd     uint16 `json:"port_end,omitempty"`     // Last port of a range (optional)
+	Protocol    string `json:"protocol"`               // "tcp", "udp", "icmp" or "icmpv6"
+	ICMPType    uint8  `json:"icmp_type,omitempty"`    // ICMP message type (optional, 0 = any)
+	Action      string `json:"action"`                 // "allow" or "deny"
+	SourcePeer  string `json:"source_peer"`            // Source peer (optional, empty = any peer)
+	SourceCIDR  string `json:"source_cidr,omitempty"`  // Source address prefix (optional)
+	SourceGroup string `json:"source_group,omitempty"` // RBAC group of the source peer (optional)
+	DestGroup   string `json:"dest_group,omitempty"`   // RBAC group of the target peer (optional)
+	TTL         int64  `json:"ttl"`                    // Time to live in seconds (0 = permanent)
+}
+
+// rule returns the filter rule described by the request.
+func (req *FilterRulesRequest) rule() routing.FilterRule {
+	return routing.FilterRule{
+		Port:        req.Port,
+		PortEnd:     req.PortEnd,
+		Protocol:    routing.ProtocolFromString(req.Protocol),
+		ICMPType:    req.ICMPType,
+		Action:      routing.ParseFilterAction(req.Action),
+		SourcePeer:  req.SourcePeer,
+		SourceCIDR:  req.SourceCIDR,
+		SourceGroup: req.SourceGroup,
+		DestGroup:   req.DestGroup,
+	}
+}
+
+// wire returns the request's rule in the relay wire format.
+func (req *FilterRulesRequest) wire() FilterRuleWire {
+	return FilterRuleWire{
+		Port:        req.Port,
+		PortEnd:     req.PortEnd,
+		Protocol:    routing.ProtocolToString(routing.ProtocolFromString(req.Protocol)),
+		ICMPType:    req.ICMPType,
+		Action:      req.Action,
+		SourcePeer:  req.SourcePeer,
+		SourceCIDR:  req.SourceCIDR,
+		SourceGroup: req.SourceGroup,
+		DestGroup:   req.DestGroup,
+	}
 }
 
 // FilterRulesResponse is the response for listing filter rules.
@@ -30,12 +65,37 @@ type FilterRulesResponse struct {
 
 // FilterRuleInfo represents a filter rule for API responses.
 type FilterRuleInfo struct {
-	Port       uint16 `json:"port"`
-	Protocol   string `json:"protocol"`
-	Action     string `json:"action"`
-	Source     string `json:"source"`      // "coordinator", "config", "temporary"
-	Expires    int64  `json:"expires"`     // Unix timestamp, 0=permanent
-	SourcePeer string `json:"source_peer"` // Source peer (empty = any peer)
+	Port        uint16 `json:"port"`
+	PortEnd     uint16 `json:"port_end,omitempty"` // Last port of a range (0 = port only)
+	Protocol    string `json:"protocol"`
+	ICMPType    uint8  `json:"icmp_type,omitempty"` // ICMP message type (0 = any)
+	Action      string `json:"action"`
+	Source      string `json:"source"`                 // "coordinator", "config", "temporary"
+	Expires     int64  `json:"expires"`                // Unix timestamp, 0=permanent
+	SourcePeer  string `json:"source_peer"`            // Source peer (empty = any peer)
+	SourceCIDR  string `json:"source_cidr,omitempty"`  // Source address prefix (empty = any)
+	SourceGroup string `json:"source_group,omitempty"` // RBAC group of the source peer
+	DestGroup   string `json:"dest_group,omitempty"`   // RBAC group of the peer
+}
+
+// FilterFlowsResponse is the response for listing a peer's connection table.
+type FilterFlowsResponse struct {
+	PeerName string           `json:"peer"`
+	Flows    []FilterFlowInfo `json:"flows"`
+	Error    string           `json:"error,omitempty"` // Set when query failed (peer offline/timeout)
+}
+
+// FilterFlowInfo represents a flow tracked by a peer's packet filter.
+type FilterFlowInfo struct {
+	Protocol     string `json:"protocol"`
+	Src          string `json:"src"`       // Originator address:port (ICMP: echo identifier)
+	Dst          string `json:"dst"`       // Responder address:port
+	State        string `json:"state"`     // e.g. "ESTABLISHED", "REPLIED"
+	Direction    string `json:"direction"` // "in" if a remote peer originated the flow, "out" otherwise
+	Peer         string `json:"peer,omitempty"`
+	PacketsOrig  uint64 `json:"packets_orig"`
+	PacketsReply uint64 `json:"packets_reply"`
+	Expires      int64  `json:"expires"` // Unix timestamp the flow expires at if idle
 }
 
 // handleFilterRules handles GET (list) and POST/DELETE for filter rules.
@@ -82,12 +142,17 @@ func (s *Server) handleFilterRulesList(w http.ResponseWriter, r *http.Request) {
 
 	// Parse the response from the peer
 	var peerRules []struct {
-		Port       uint16 `json:"port"`
-		Protocol   string `json:"protocol"`
-		Action     string `json:"action"`
-		SourcePeer string `json:"source_peer"`
-		Source     string `json:"source"`
-		Expires    int64  `json:"expires"`
+		Port        uint16 `json:"port"`
+		PortEnd     uint16 `json:"port_end"`
+		Protocol    string `json:"protocol"`
+		ICMPType    uint8  `json:"icmp_type"`
+		Action      string `json:"action"`
+		SourcePeer  string `json:"source_peer"`
+		SourceCIDR  string `json:"source_cidr"`
+		SourceGroup string `json:"source_group"`
+		DestGroup   string `json:"dest_group"`
+		Source      string `json:"source"`
+		Expires     int64  `json:"expires"`
 	}
 	if err := json.Unmarshal(rulesJSON, &peerRules); err != nil {
 		log.Error().Err(err).Str("peer", peerName).Msg("failed to parse peer filter rules")
@@ -99,12 +164,17 @@ func (s *Server) handleFilterRulesList(w http.ResponseWriter, r *http.Request) {
 	rules := make([]FilterRuleInfo, 0, len(peerRules))
 	for _, r := range peerRules {
 		rules = append(rules, FilterRuleInfo{
-			Port:       r.Port,
-			Protocol:   r.Protocol,
-			Action:     r.Action,
-			Source:     r.Source,
-			Expires:    r.Expires,
-			SourcePeer: r.SourcePeer,
+			Port:        r.Port,
+			PortEnd:     r.PortEnd,
+			Protocol:    r.Protocol,
+			ICMPType:    r.ICMPType,
+			Action:      r.Action,
+			Source:      r.Source,
+			Expires:     r.Expires,
+			SourcePeer:  r.SourcePeer,
+			SourceCIDR:  r.SourceCIDR,
+			SourceGroup: r.SourceGroup,
+			DestGroup:   r.DestGroup,
 		})
 	}
 
@@ -119,6 +189,37 @@ func (s *Server) handleFilterRulesList(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(resp)
 }
 
+// handleFilterFlows returns the connection table of a peer's packet filter by
+// querying the peer directly (requires ?peer=name query param).
+func (s *Server) handleFilterFlows(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	peerName := r.URL.Query().Get("peer")
+	if peerName == "" {
+		s.jsonError(w, "peer parameter required", http.StatusBadRequest)
+		return
+	}
+
+	resp := FilterFlowsResponse{PeerName: peerName, Flows: []FilterFlowInfo{}}
+	flowsJSON, err := s.relay.QueryFilterFlows(r.Context(), peerName, 10*time.Second)
+	if err != nil {
+		log.Debug().Err(err).Str("peer", peerName).Msg("failed to query peer filter flows")
+		resp.Error = "Peer offline or unreachable"
+	} else if err := json.Unmarshal(flowsJSON, &resp.Flows); err != nil {
+		log.Error().Err(err).Str("peer", peerName).Msg("failed to parse peer filter flows")
+		s.jsonError(w, "failed to parse peer filter flows", http.StatusInternalServerError)
+		return
+	}
+	if resp.Flows == nil {
+		resp.Flows = []FilterFlowInfo{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
 // handleFilterRuleAdd adds a temporary filter rule to a peer.
 func (s *Server) handleFilterRuleAdd(w http.ResponseWriter, r *http.Request) {
 	var req FilterRulesRequest
@@ -131,12 +232,9 @@ func (s *Server) handleFilterRuleAdd(w http.ResponseWriter, r *http.Request) {
 		s.jsonError(w, "peer is required", http.StatusBadRequest)
 		return
 	}
-	if req.Port == 0 {
-		s.jsonError(w, "port is required", http.StatusBadRequest)
-		return
-	}
-	if req.Protocol != "tcp" && req.Protocol != "udp" {
-		s.jsonError(w, "protocol must be 'tcp' or 'udp'", http.StatusBadRequest)
+	rule := req.rule()
+	if err := rule.Validate(); err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 	if req.Action != "allow" && req.Action != "deny" {
@@ -149,43 +247,35 @@ func (s *Server) handleFilterRuleAdd(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Validate TTL bounds
+	if req.TTL < 0 {
+		s.jsonError(w, "ttl cannot be negative", http.StatusBadRequest)
+		return
+	}
+	if req.TTL > 365*24*3600 { // Max 1 year
+		s.jsonError(w, "ttl exceeds maximum (1 year)", http.StatusBadRequest)
+		return
+	}
+
 	// Push the rule to peer(s) via relay
+	wire := req.wire()
 	if req.PeerName == "__all__" {
 		// Broadcast to all connected peers (skip self-referencing rules)
 		for _, peerName := range s.relay.GetConnectedPeerNames() {
 			if req.SourcePeer != "" && req.SourcePeer == peerName {
 				continue // Skip: peer can't filter traffic from itself
 			}
-			s.relay.PushFilterRuleAdd(peerName, req.Port, req.Protocol, req.Action, req.SourcePeer)
+			s.relay.PushFilterRuleAdd(peerName, wire)
 		}
 	} else {
-		s.relay.PushFilterRuleAdd(req.PeerName, req.Port, req.Protocol, req.Action, req.SourcePeer)
-	}
-
-	// Validate TTL bounds
-	if req.TTL < 0 {
-		s.jsonError(w, "ttl cannot be negative", http.StatusBadRequest)
-		return
-	}
-	if req.TTL > 365*24*3600 { // Max 1 year
-		s.jsonError(w, "ttl exceeds maximum (1 year)", http.StatusBadRequest)
-		return
+		s.relay.PushFilterRuleAdd(req.PeerName, wire)
 	}
 
 	// Update coordinator's filter and persist to S3
 	if s.filter != nil {
 		// Calculate expiry if TTL specified
-		var expires int64
 		if req.TTL > 0 {
-			expires = time.Now().Unix() + req.TTL
-		}
-
-		rule := routing.FilterRule{
-			Port:       req.Port,
-			Protocol:   routing.ProtocolFromString(req.Protocol),
-			Action:     routing.ParseFilterAction(req.Action),
-			Expires:    expires,
-			SourcePeer: req.SourcePeer,
+			rule.Expires = time.Now().Unix() + req.TTL
 		}
 		s.filter.AddTemporaryRule(rule)
 		s.saveFilterRulesAsync()
@@ -207,12 +297,9 @@ func (s *Server) handleFilterRuleRemove(w http.ResponseWriter, r *http.Request)
 		s.jsonError(w, "peer is required", http.StatusBadRequest)
 		return
 	}
-	if req.Port == 0 {
-		s.jsonError(w, "port is required", http.StatusBadRequest)
-		return
-	}
-	if req.Protocol != "tcp" && req.Protocol != "udp" {
-		s.jsonError(w, "protocol must be 'tcp' or 'udp'", http.StatusBadRequest)
+	rule := req.rule()
+	if err := rule.Validate(); err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 	// Prevent self-targeting
@@ -222,22 +309,22 @@ func (s *Server) handleFilterRuleRemove(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Push the rule removal to peer(s) via relay (if relay is enabled)
+	wire := req.wire()
 	if req.PeerName == "__all__" {
 		// Broadcast to all connected peers (skip self-referencing rules)
 		for _, peerName := range s.relay.GetConnectedPeerNames() {
 			if req.SourcePeer != "" && req.SourcePeer == peerName {
 				continue
 			}
-			s.relay.PushFilterRuleRemove(peerName, req.Port, req.Protocol, req.SourcePeer)
+			s.relay.PushFilterRuleRemove(peerName, wire)
 		}
 	} else {
-		s.relay.PushFilterRuleRemove(req.PeerName, req.Port, req.Protocol, req.SourcePeer)
+		s.relay.PushFilterRuleRemove(req.PeerName, wire)
 	}
 
 	// Update coordinator's filter and persist to S3
 	if s.filter != nil {
-		proto := routing.ProtocolFromString(req.Protocol)
-		s.filter.RemoveTemporaryRuleForPeer(req.Port, proto, req.SourcePeer)
+		s.filter.RemoveTemporaryRuleKey(rule.Key())
 		s.saveFilterRulesAsync()
 	}
 
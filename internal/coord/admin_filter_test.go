@@ -1,12 +1,14 @@
 package coord
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/tunnelmesh/tunnelmesh/internal/routing"
 )
 
 func TestFilterRules_MethodNotAllowed(t *testing.T) {
@@ -32,3 +34,85 @@ func TestFilterRulesList_NoPeer(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 	assert.Contains(t, rec.Body.String(), "peer parameter required")
 }
+
+func TestFilterRuleAdd_RangeAndGroups(t *testing.T) {
+	srv := newTestServerWithS3(t)
+	require.NotNil(t, srv.filter)
+
+	rec := doAdminRequest(t, srv, http.MethodPost, "/api/filter/rules", FilterRulesRequest{
+		PeerName: "web", Port: 8000, PortEnd: 8100, Protocol: "TCP", Action: "allow",
+		SourceCIDR: "192.168.10.7/24", SourceGroup: "ops", DestGroup: "prod",
+	})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = doAdminRequest(t, srv, http.MethodPost, "/api/filter/rules", FilterRulesRequest{
+		PeerName: "web", Protocol: "icmp", ICMPType: 8, Action: "allow",
+	})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var temporary []routing.FilterRule
+	for _, r := range srv.filter.ListRules() {
+		if r.Source == routing.SourceTemporary {
+			temporary = append(temporary, r.Rule)
+		}
+	}
+	assert.ElementsMatch(t, []routing.FilterRule{
+		{Port: 8000, PortEnd: 8100, Protocol: routing.ProtoTCP, Action: routing.ActionAllow,
+			SourceCIDR: "192.168.10.7/24", SourceGroup: "ops", DestGroup: "prod"},
+		{Protocol: routing.ProtoICMP, ICMPType: 8, Action: routing.ActionAllow},
+	}, temporary)
+
+	// Removal matches the selectors, with the CIDR in any spelling
+	rec = doAdminRequest(t, srv, http.MethodDelete, "/api/filter/rules", FilterRulesRequest{
+		PeerName: "web", Port: 8000, PortEnd: 8100, Protocol: "tcp",
+		SourceCIDR: "192.168.10.0/24", SourceGroup: "ops", DestGroup: "prod",
+	})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = doAdminRequest(t, srv, http.MethodDelete, "/api/filter/rules", FilterRulesRequest{
+		PeerName: "web", Protocol: "icmp", ICMPType: 8,
+	})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, 0, srv.filter.RuleCountBySource().Temporary)
+}
+
+func TestFilterRuleAdd_Invalid(t *testing.T) {
+	srv := newTestServerWithS3(t)
+
+	tests := []struct {
+		name string
+		req  FilterRulesRequest
+		want string
+	}{
+		{"protocol", FilterRulesRequest{PeerName: "web", Port: 22, Protocol: "gre", Action: "allow"}, "invalid protocol"},
+		{"missing port", FilterRulesRequest{PeerName: "web", Protocol: "tcp", Action: "allow"}, "port is required"},
+		{"reversed range", FilterRulesRequest{PeerName: "web", Port: 100, PortEnd: 90, Protocol: "tcp", Action: "allow"}, "invalid port range"},
+		{"icmp port", FilterRulesRequest{PeerName: "web", Port: 22, Protocol: "icmp", Action: "allow"}, "icmp"},
+		{"cidr", FilterRulesRequest{PeerName: "web", Port: 22, Protocol: "tcp", Action: "allow", SourceCIDR: "office"}, "invalid source CIDR"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doAdminRequest(t, srv, http.MethodPost, "/api/filter/rules", tt.req)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.want)
+		})
+	}
+}
+
+func TestFilterFlows(t *testing.T) {
+	srv := newTestServerWithS3(t)
+
+	rec := doAdminRequest(t, srv, http.MethodGet, "/api/filter/flows", nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = doAdminRequest(t, srv, http.MethodPost, "/api/filter/flows?peer=web", nil)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	// An offline peer yields an empty table with an error
+	rec = doAdminRequest(t, srv, http.MethodGet, "/api/filter/flows?peer=web", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp FilterFlowsResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, "web", resp.PeerName)
+	assert.Empty(t, resp.Flows)
+	assert.NotEmpty(t, resp.Error)
+}
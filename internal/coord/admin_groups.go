@@ -7,6 +7,7 @@ import (
 
 	"github.com/rs/zerolog/log"
 	"github.com/tunnelmesh/tunnelmesh/internal/auth"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
 )
 
 // GroupCreateRequest is the request body for creating a group.
@@ -22,9 +23,10 @@ type GroupMemberRequest struct {
 
 // GroupBindingRequest is the request body for granting a role to a group.
 type GroupBindingRequest struct {
-	RoleName     string `json:"role_name"`
-	BucketScope  string `json:"bucket_scope,omitempty"`
-	ObjectPrefix string `json:"object_prefix,omitempty"`
+	RoleName     string            `json:"role_name"`
+	BucketScope  string            `json:"bucket_scope,omitempty"`
+	ObjectPrefix string            `json:"object_prefix,omitempty"`
+	ObjectTags   map[string]string `json:"object_tags,omitempty"`
 }
 
 // handleGroups handles GET (list) and POST (create) for groups.
@@ -69,6 +71,11 @@ func (s *Server) handleGroupCreate(w http.ResponseWriter, r *http.Request) {
 		s.jsonError(w, "name is required", http.StatusBadRequest)
 		return
 	}
+	if auth.IsTagMember(req.Name) {
+		// Filter rules select groups and tags by the same name
+		s.jsonError(w, "group names cannot start with "+auth.TagPrefix, http.StatusBadRequest)
+		return
+	}
 
 	// Check if group already exists
 	if s.s3Authorizer.Groups.Get(req.Name) != nil {
@@ -186,6 +193,16 @@ func (s *Server) handleGroupMembers(w http.ResponseWriter, r *http.Request, grou
 			s.jsonError(w, "user_id is required", http.StatusBadRequest)
 			return
 		}
+		if auth.IsTagMember(req.UserID) {
+			if err := proto.ValidateTag(req.UserID); err != nil {
+				s.jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if group.Builtin {
+				s.jsonError(w, "built-in groups cannot have tag members", http.StatusBadRequest)
+				return
+			}
+		}
 
 		if err := s.s3Authorizer.Groups.AddMember(groupName, req.UserID); err != nil {
 			s.jsonError(w, err.Error(), http.StatusInternalServerError)
@@ -257,6 +274,7 @@ func (s *Server) handleGroupBindings(w http.ResponseWriter, r *http.Request, gro
 		}
 
 		binding := auth.NewGroupBindingWithPrefix(groupName, req.RoleName, req.BucketScope, req.ObjectPrefix)
+		binding.ObjectTags = req.ObjectTags
 		s.s3Authorizer.GroupBindings.Add(binding)
 
 		// Persist
@@ -166,3 +166,23 @@ func TestGroupBindings_AddAndList(t *testing.T) {
 	srv.adminMux.ServeHTTP(rec, req)
 	assert.Equal(t, http.StatusOK, rec.Code)
 }
+
+func TestGroups_TagMembers(t *testing.T) {
+	srv := newTestServerWithS3AndBucket(t)
+
+	rec := doAdminRequest(t, srv, http.MethodPost, "/api/groups", GroupCreateRequest{Name: "tag:prod"})
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "group names cannot look like tags")
+
+	rec = doAdminRequest(t, srv, http.MethodPost, "/api/groups", GroupCreateRequest{Name: "production"})
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	rec = doAdminRequest(t, srv, http.MethodPost, "/api/groups/production/members", GroupMemberRequest{UserID: "tag:Prod"})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = doAdminRequest(t, srv, http.MethodPost, "/api/groups/admins/members", GroupMemberRequest{UserID: "tag:prod"})
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "no tag members in built-in groups")
+
+	rec = doAdminRequest(t, srv, http.MethodPost, "/api/groups/production/members", GroupMemberRequest{UserID: "tag:prod"})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	srv.s3Authorizer.Groups.SetPeerTags("peer-1", []string{"tag:prod"})
+	assert.True(t, srv.s3Authorizer.Groups.IsMember("production", "peer-1"))
+}
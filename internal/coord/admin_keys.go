@@ -0,0 +1,468 @@
+package coord
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tunnelmesh/tunnelmesh/internal/auth"
+	"github.com/tunnelmesh/tunnelmesh/internal/config"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
+)
+
+// joinKeyIDLength is the number of hex characters of the key hash used as
+// the key ID.
+const joinKeyIDLength = 12
+
+// joinKeyNamePattern matches the peer names a join key can assign: a DNS label.
+var joinKeyNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// hashJoinKey returns the hex SHA-256 hash join keys are stored by.
+func hashJoinKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// peerIDFromPublicKey derives a peer ID from an SSH public key.
+// Returns "" if the key is not an ED25519 key.
+func peerIDFromPublicKey(publicKey string) string {
+	edPubKey, err := config.DecodeED25519PublicKey(publicKey)
+	if err != nil {
+		return ""
+	}
+	return auth.ComputePeerID(edPubKey)
+}
+
+// joinKeyAuthorizes reports whether a bearer token is a join key that is
+// neither revoked nor expired. Whether the registering peer may use it is
+// checked by claimJoinKey.
+func (s *Server) joinKeyAuthorizes(token string) bool {
+	s.keysMu.RLock()
+	defer s.keysMu.RUnlock()
+
+	key, ok := s.joinKeys[hashJoinKey(token)]
+	return ok && !key.Revoked && !key.Expired(time.Now())
+}
+
+// tokenAuthorizes reports whether a bearer token grants access to a peer API
+// request: the mesh token, the token a peer got when registering, or for
+// registration only, a join key. Join keys don't identify the peer using
+// them, so once joined, peers are authorized by their own token, which
+// stops working when they are revoked, and renew it by registering with it.
+func (s *Server) tokenAuthorizes(r *http.Request, token string) bool {
+	if token == s.cfg.AuthToken {
+		return true
+	}
+	if r.URL.Path == "/api/v1/register" && s.joinKeyAuthorizes(token) {
+		return true
+	}
+	_, err := s.ValidateToken(token)
+	return err == nil
+}
+
+// requestJoinKey returns the join key a request is authorized with, and its
+// hash. Returns nil if the request uses the mesh token.
+func (s *Server) requestJoinKey(r *http.Request) (*proto.JoinKey, string) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == s.cfg.AuthToken {
+		return nil, ""
+	}
+	hash := hashJoinKey(token)
+
+	s.keysMu.RLock()
+	defer s.keysMu.RUnlock()
+	key, ok := s.joinKeys[hash]
+	if !ok {
+		return nil, ""
+	}
+	k := *key
+	return &k, hash
+}
+
+// claimJoinKey records a peer joining with a join key. Revoked and expired
+// keys are refused; peers that joined with a single-use key before can use
+// it again until then, to retry a registration whose response was lost.
+// Returns whether the peer is new to the key.
+func (s *Server) claimJoinKey(hash, peerID string) (bool, error) {
+	s.keysMu.Lock()
+	defer s.keysMu.Unlock()
+
+	key, ok := s.joinKeys[hash]
+	if !ok {
+		return false, errors.New("join key not found")
+	}
+	switch {
+	case key.Revoked:
+		return false, errors.New("join key revoked")
+	case key.Expired(time.Now()):
+		return false, errors.New("join key expired")
+	case slices.Contains(key.PeerIDs, peerID):
+		return false, nil
+	case !key.Reusable && len(key.PeerIDs) > 0:
+		return false, errors.New("join key already used")
+	}
+	key.PeerIDs = append(key.PeerIDs, peerID)
+	return true, nil
+}
+
+// isPeerRevoked reports whether an admin revoked a peer's access.
+func (s *Server) isPeerRevoked(peerID string) bool {
+	if peerID == "" {
+		return false
+	}
+	s.keysMu.RLock()
+	defer s.keysMu.RUnlock()
+	_, revoked := s.revokedPeers[peerID]
+	return revoked
+}
+
+// verifyClientCert rejects mesh TLS client certificates on the CRL, which
+// includes the certificates of revoked peers. Certificates are checked by
+// serial rather than name, since the name of a revoked peer can be taken by
+// a new one. Used as tls.Config.VerifyPeerCertificate on the admin server.
+func (s *Server) verifyClientCert(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		return nil
+	}
+	cert, err := x509.ParseCertificate(rawCerts[0])
+	if err != nil {
+		return fmt.Errorf("parse client certificate: %w", err)
+	}
+	if s.ca != nil && s.ca.IsRevoked(cert.SerialNumber) {
+		return fmt.Errorf("revoked certificate %s", cert.SerialNumber.Text(16))
+	}
+	return nil
+}
+
+// requireKeysAdmin checks that a join key or revocation request comes from
+// an admin. Writes an error response and returns false otherwise.
+func (s *Server) requireKeysAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if s.s3Authorizer == nil || s.s3SystemStore == nil {
+		s.jsonError(w, "join keys not enabled", http.StatusServiceUnavailable)
+		return false
+	}
+	if !s.s3Authorizer.IsAdmin(s.getRequestOwner(r)) {
+		s.jsonError(w, "admin access required", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// handleJoinKeys lists join keys (GET) or creates one (POST).
+func (s *Server) handleJoinKeys(w http.ResponseWriter, r *http.Request) {
+	if !s.requireKeysAdmin(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.keysMu.RLock()
+		keys := make([]proto.JoinKey, 0, len(s.joinKeys))
+		for _, key := range s.joinKeys {
+			k := *key
+			k.PeerIDs = slices.Clone(key.PeerIDs)
+			keys = append(keys, k)
+		}
+		s.keysMu.RUnlock()
+
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		})
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(keys)
+
+	case http.MethodPost:
+		var req proto.JoinKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.jsonError(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := s.validateJoinKeyRequest(&req); err != nil {
+			s.jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Keys have the format of the mesh token, so peers use them in its place
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			s.jsonError(w, "failed to generate key", http.StatusInternalServerError)
+			return
+		}
+		hash := hashJoinKey(hex.EncodeToString(secret))
+		key := proto.JoinKey{
+			ID:          hash[:joinKeyIDLength],
+			Description: req.Description,
+			Reusable:    req.Reusable,
+			Name:        req.Name,
+			Groups:      req.Groups,
+			Tags:        req.Tags,
+			CreatedAt:   time.Now().UTC().Truncate(time.Second),
+		}
+		if req.TTL > 0 {
+			key.ExpiresAt = key.CreatedAt.Add(time.Duration(req.TTL) * time.Second)
+		}
+
+		stored := key
+		s.keysMu.Lock()
+		s.joinKeys[hash] = &stored
+		s.keysMu.Unlock()
+
+		log.Info().Str("id", key.ID).Bool("reusable", key.Reusable).Msg("join key created")
+		s.saveJoinKeys(r.Context())
+
+		key.Key = hex.EncodeToString(secret)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(key)
+
+	default:
+		s.jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// validateJoinKeyRequest checks the peer name, groups and tags a join key
+// assigns, sorting and deduplicating the groups and tags.
+func (s *Server) validateJoinKeyRequest(req *proto.JoinKeyRequest) error {
+	if req.TTL < 0 {
+		return errors.New("ttl must not be negative")
+	}
+	if req.Name != "" {
+		if isReservedPeerName(req.Name) {
+			return fmt.Errorf("peer name %q is reserved", req.Name)
+		}
+		if !joinKeyNamePattern.MatchString(req.Name) {
+			return fmt.Errorf("invalid peer name %q: must be a lowercase DNS label", req.Name)
+		}
+		s.peersMu.RLock()
+		owner, isAlias := s.aliasOwner[req.Name]
+		s.peersMu.RUnlock()
+		if isAlias {
+			return fmt.Errorf("peer name %q is an alias of peer %q", req.Name, owner)
+		}
+	}
+	for _, group := range req.Groups {
+		if s.s3Authorizer.Groups.Get(group) == nil {
+			return fmt.Errorf("group %q not found", group)
+		}
+	}
+	tags, err := proto.NormalizeTags(req.Tags)
+	if err != nil {
+		return err
+	}
+	req.Tags = tags
+	slices.Sort(req.Groups)
+	req.Groups = slices.Compact(req.Groups)
+	return nil
+}
+
+// handleJoinKeyByID revokes a join key (DELETE /api/keys/{id}). Peers that
+// joined with the key stay in the mesh; revoke them individually.
+func (s *Server) handleJoinKeyByID(w http.ResponseWriter, r *http.Request) {
+	if !s.requireKeysAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodDelete {
+		s.jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/keys/")
+	s.keysMu.Lock()
+	var found bool
+	for _, key := range s.joinKeys {
+		if key.ID == id {
+			key.Revoked = true
+			found = true
+			break
+		}
+	}
+	s.keysMu.Unlock()
+
+	if !found {
+		s.jsonError(w, "join key not found", http.StatusNotFound)
+		return
+	}
+
+	log.Info().Str("id", id).Msg("join key revoked")
+	s.saveJoinKeys(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "revoked"})
+}
+
+// handleRevokedPeers lists revoked peers (GET) or revokes a peer (POST), by
+// name or peer ID. Revoking removes the peer from the mesh and rejects its
+// registrations, relay tokens and mesh TLS certificate.
+func (s *Server) handleRevokedPeers(w http.ResponseWriter, r *http.Request) {
+	if !s.requireKeysAdmin(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.keysMu.RLock()
+		revoked := make([]proto.RevokedPeer, 0, len(s.revokedPeers))
+		for _, rp := range s.revokedPeers {
+			revoked = append(revoked, rp)
+		}
+		s.keysMu.RUnlock()
+
+		sort.Slice(revoked, func(i, j int) bool {
+			return revoked[i].RevokedAt.Before(revoked[j].RevokedAt)
+		})
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(revoked)
+
+	case http.MethodPost:
+		var req struct {
+			Peer string `json:"peer"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Peer == "" {
+			s.jsonError(w, "peer is required", http.StatusBadRequest)
+			return
+		}
+
+		rp, ok := s.resolvePeer(r.Context(), req.Peer)
+		if !ok {
+			s.jsonError(w, fmt.Sprintf("peer %q not found", req.Peer), http.StatusNotFound)
+			return
+		}
+		if rp.Name == s.cfg.Name {
+			s.jsonError(w, "cannot revoke this coordinator", http.StatusBadRequest)
+			return
+		}
+		rp.RevokedAt = time.Now().UTC().Truncate(time.Second)
+
+		s.keysMu.Lock()
+		s.revokedPeers[rp.PeerID] = rp
+		s.keysMu.Unlock()
+
+		// Cut the peer off: drop its registration and relay connection
+		s.removePeer(rp.Name)
+		if pc, ok := s.relay.GetPersistent(rp.Name); ok {
+			pc.Close()
+		}
+
+		log.Info().Str("peer", rp.Name).Str("peer_id", rp.PeerID).Msg("peer revoked")
+		s.saveRevokedPeers(r.Context())
+
+		// Revoke its certificates and publish the new CRL
+		if s.ca != nil && s.ca.RevokePeerCerts(rp.Name) > 0 {
+			s.saveIssuedCerts(r.Context())
+			s.broadcastTrustUpdate()
+		}
+
+		// Replace the handshake PSKs it was sent
+		if err := s.rotatePSKSecret(r.Context()); err != nil {
+			log.Warn().Err(err).Msg("failed to replace PSK secret")
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(rp)
+
+	default:
+		s.jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleRevokedPeerByID restores a revoked peer's access
+// (DELETE /api/revoked-peers/{peer_id}).
+func (s *Server) handleRevokedPeerByID(w http.ResponseWriter, r *http.Request) {
+	if !s.requireKeysAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodDelete {
+		s.jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	peerID := strings.TrimPrefix(r.URL.Path, "/api/revoked-peers/")
+	s.keysMu.Lock()
+	rp, found := s.revokedPeers[peerID]
+	delete(s.revokedPeers, peerID)
+	s.keysMu.Unlock()
+
+	if !found {
+		s.jsonError(w, "peer not revoked", http.StatusNotFound)
+		return
+	}
+
+	log.Info().Str("peer", rp.Name).Str("peer_id", peerID).Msg("peer revocation lifted")
+	s.saveRevokedPeers(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "restored"})
+}
+
+// resolvePeer finds a connected or previously registered peer by name or
+// peer ID.
+func (s *Server) resolvePeer(ctx context.Context, nameOrID string) (proto.RevokedPeer, bool) {
+	s.peersMu.RLock()
+	for name, info := range s.peers {
+		if info.peerID != "" && (name == nameOrID || info.peerID == nameOrID) {
+			s.peersMu.RUnlock()
+			return proto.RevokedPeer{PeerID: info.peerID, Name: name}, true
+		}
+	}
+	s.peersMu.RUnlock()
+
+	peers, err := s.s3SystemStore.LoadPeers(ctx)
+	if err != nil {
+		return proto.RevokedPeer{}, false
+	}
+	for _, p := range peers {
+		if p.ID == nameOrID || p.Name == nameOrID {
+			return proto.RevokedPeer{PeerID: p.ID, Name: p.Name}, true
+		}
+	}
+	return proto.RevokedPeer{}, false
+}
+
+// saveJoinKeys persists the join keys to S3.
+func (s *Server) saveJoinKeys(ctx context.Context) {
+	if s.s3SystemStore == nil {
+		return
+	}
+	s.keysMu.RLock()
+	keys := make(map[string]proto.JoinKey, len(s.joinKeys))
+	for hash, key := range s.joinKeys {
+		k := *key
+		k.PeerIDs = slices.Clone(key.PeerIDs)
+		keys[hash] = k
+	}
+	s.keysMu.RUnlock()
+
+	if err := s.s3SystemStore.SaveJoinKeys(ctx, keys); err != nil {
+		log.Warn().Err(err).Msg("failed to persist join keys")
+	}
+}
+
+// saveRevokedPeers persists the revoked peers to S3.
+func (s *Server) saveRevokedPeers(ctx context.Context) {
+	if s.s3SystemStore == nil {
+		return
+	}
+	s.keysMu.RLock()
+	revoked := make([]proto.RevokedPeer, 0, len(s.revokedPeers))
+	for _, rp := range s.revokedPeers {
+		revoked = append(revoked, rp)
+	}
+	s.keysMu.RUnlock()
+
+	if err := s.s3SystemStore.SaveRevokedPeers(ctx, revoked); err != nil {
+		log.Warn().Err(err).Msg("failed to persist revoked peers")
+	}
+}
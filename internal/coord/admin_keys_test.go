@@ -0,0 +1,410 @@
+package coord
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tunnelmesh/tunnelmesh/internal/config"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
+)
+
+// registerWithToken registers a peer through the peer API, authorized with
+// the given token.
+func registerWithToken(t *testing.T, srv *Server, token, name, publicKey string) *httptest.ResponseRecorder {
+	t.Helper()
+	body, _ := json.Marshal(proto.RegisterRequest{Name: name, PublicKey: publicKey, SSHPort: 2222})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/register", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	return rec
+}
+
+func createJoinKey(t *testing.T, srv *Server, req proto.JoinKeyRequest) proto.JoinKey {
+	t.Helper()
+	rec := doAdminRequest(t, srv, http.MethodPost, "/api/keys", req)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var key proto.JoinKey
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&key))
+	require.Len(t, key.Key, 64)
+	return key
+}
+
+func TestJoinKeys_RequireAdmin(t *testing.T) {
+	srv := newTestServerWithS3(t)
+
+	rec := doAdminRequest(t, srv, http.MethodPost, "/api/keys", proto.JoinKeyRequest{})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = doAdminRequest(t, srv, http.MethodGet, "/api/revoked-peers", nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestJoinKeys_SingleUse(t *testing.T) {
+	srv := newTestServerWithS3(t)
+	makeTestAdmin(srv)
+	_, err := srv.s3Authorizer.Groups.Create("ci", "")
+	require.NoError(t, err)
+
+	key := createJoinKey(t, srv, proto.JoinKeyRequest{
+		Description: "build box",
+		Name:        "builder",
+		Groups:      []string{"ci"},
+		Tags:        []string{"tag:ci"},
+		TTL:         3600,
+	})
+	assert.Empty(t, key.PeerIDs)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), key.ExpiresAt, 5*time.Second)
+
+	pubKey, _ := generateTestSSHPubKey(t)
+	rec := registerWithToken(t, srv, key.Key, "anything", pubKey)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp proto.RegisterResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, "builder", resp.PeerName, "key assigns the name")
+
+	peerID := peerIDFromPublicKey(pubKey)
+	assert.True(t, srv.s3Authorizer.Groups.IsMember("ci", peerID))
+	srv.peersMu.RLock()
+	assert.Equal(t, []string{"tag:ci"}, srv.peers["builder"].peer.Tags)
+	srv.peersMu.RUnlock()
+
+	// The peer keeps using the key, but no other peer can join with it
+	rec = registerWithToken(t, srv, key.Key, "builder", pubKey)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	otherKey, _ := generateTestSSHPubKey(t)
+	rec = registerWithToken(t, srv, key.Key, "other", otherKey)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/peers", nil)
+	req.Header.Set("Authorization", "Bearer "+resp.Token)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Listed without the key itself
+	rec = doAdminRequest(t, srv, http.MethodGet, "/api/keys", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var keys []proto.JoinKey
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&keys))
+	require.Len(t, keys, 1)
+	assert.Empty(t, keys[0].Key)
+	assert.Equal(t, []string{peerID}, keys[0].PeerIDs)
+
+	// Persisted for recovery
+	stored, err := srv.s3SystemStore.LoadJoinKeys(t.Context())
+	require.NoError(t, err)
+	require.Contains(t, stored, hashJoinKey(key.Key))
+	assert.Empty(t, stored[hashJoinKey(key.Key)].Key)
+}
+
+func TestJoinKeys_ReusableAndRevoked(t *testing.T) {
+	srv := newTestServerWithS3(t)
+	makeTestAdmin(srv)
+
+	key := createJoinKey(t, srv, proto.JoinKeyRequest{Reusable: true})
+	first, _ := generateTestSSHPubKey(t)
+	second, _ := generateTestSSHPubKey(t)
+	rec := registerWithToken(t, srv, key.Key, "runner-1", first)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp proto.RegisterResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, http.StatusOK, registerWithToken(t, srv, key.Key, "runner-2", second).Code)
+
+	rec = doAdminRequest(t, srv, http.MethodDelete, "/api/keys/"+key.ID, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	// The key is refused outright, even for the peers that joined with it,
+	// which stay and register again with their own token
+	assert.Equal(t, http.StatusUnauthorized, registerWithToken(t, srv, key.Key, "runner-1", first).Code)
+	third, _ := generateTestSSHPubKey(t)
+	assert.Equal(t, http.StatusUnauthorized, registerWithToken(t, srv, key.Key, "runner-3", third).Code)
+	assert.Equal(t, http.StatusOK, registerWithToken(t, srv, resp.Token, "runner-1", first).Code)
+
+	assert.Equal(t, http.StatusUnauthorized, registerWithToken(t, srv, "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", "x", third).Code)
+}
+
+func TestJoinKeys_Expired(t *testing.T) {
+	srv := newTestServerWithS3(t)
+	makeTestAdmin(srv)
+
+	key := createJoinKey(t, srv, proto.JoinKeyRequest{TTL: 60})
+	srv.keysMu.Lock()
+	srv.joinKeys[hashJoinKey(key.Key)].ExpiresAt = time.Now().Add(-time.Second)
+	srv.keysMu.Unlock()
+
+	pubKey, _ := generateTestSSHPubKey(t)
+	assert.Equal(t, http.StatusUnauthorized, registerWithToken(t, srv, key.Key, "late", pubKey).Code)
+
+	// Nor can peers that joined with it before it expired
+	srv.keysMu.Lock()
+	srv.joinKeys[hashJoinKey(key.Key)].PeerIDs = []string{peerIDFromPublicKey(pubKey)}
+	srv.keysMu.Unlock()
+	assert.Equal(t, http.StatusUnauthorized, registerWithToken(t, srv, key.Key, "late", pubKey).Code)
+}
+
+func TestJoinKeys_RenewWithPeerToken(t *testing.T) {
+	srv := newTestServerWithS3(t)
+	makeTestAdmin(srv)
+
+	key := createJoinKey(t, srv, proto.JoinKeyRequest{Name: "kiosk"})
+	pubKey, _ := generateTestSSHPubKey(t)
+	rec := registerWithToken(t, srv, key.Key, "anything", pubKey)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp proto.RegisterResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+
+	// The peer registers again with its token, under the name it was given
+	rec = registerWithToken(t, srv, resp.Token, "renamed", pubKey)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var renewed proto.RegisterResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&renewed))
+	assert.Equal(t, "kiosk", renewed.PeerName)
+	assert.NotEmpty(t, renewed.Token)
+
+	// The token doesn't register other peers
+	otherKey, _ := generateTestSSHPubKey(t)
+	assert.Equal(t, http.StatusUnauthorized, registerWithToken(t, srv, resp.Token, "other", otherKey).Code)
+}
+
+func TestJoinKeys_RequireJoinKeys(t *testing.T) {
+	srv := newTestServerWithS3(t)
+	makeTestAdmin(srv)
+	srv.cfg.Coordinator.RequireJoinKeys = true
+
+	// The mesh token only registers coordinators
+	pubKey, _ := generateTestSSHPubKey(t)
+	assert.Equal(t, http.StatusForbidden, registerWithToken(t, srv, "test-token", "laptop", pubKey).Code)
+
+	body, _ := json.Marshal(proto.RegisterRequest{Name: "coord-2", PublicKey: pubKey, SSHPort: 2222, IsCoordinator: true})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/register", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-token")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	key := createJoinKey(t, srv, proto.JoinKeyRequest{})
+	otherKey, _ := generateTestSSHPubKey(t)
+	assert.Equal(t, http.StatusOK, registerWithToken(t, srv, key.Key, "laptop", otherKey).Code)
+}
+
+func TestJoinKeys_AliasesDontTakePeerNames(t *testing.T) {
+	srv := newTestServerWithS3(t)
+	makeTestAdmin(srv)
+	createJoinKey(t, srv, proto.JoinKeyRequest{Name: "kiosk"})
+
+	register := func(name string, aliases ...string) *httptest.ResponseRecorder {
+		pubKey, _ := generateTestSSHPubKey(t)
+		body, _ := json.Marshal(proto.RegisterRequest{Name: name, PublicKey: pubKey, SSHPort: 2222, Aliases: aliases})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/register", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-token")
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The name a join key gives a peer that hasn't joined yet is taken
+	assert.Equal(t, http.StatusConflict, register("laptop", "kiosk").Code)
+
+	// A peer joining under another peer's alias is renamed
+	require.Equal(t, http.StatusOK, register("laptop", "bob").Code)
+	rec := register("bob")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp proto.RegisterResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, "bob-2", resp.PeerName)
+
+	// Join keys can't give a peer another peer's alias
+	rec = doAdminRequest(t, srv, http.MethodPost, "/api/keys", proto.JoinKeyRequest{Name: "bob"})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestJoinKeys_InvalidRequests(t *testing.T) {
+	srv := newTestServerWithS3(t)
+	makeTestAdmin(srv)
+
+	for _, req := range []proto.JoinKeyRequest{
+		{TTL: -1},
+		{Name: "admin"},
+		{Name: "Not A Label"},
+		{Groups: []string{"missing"}},
+		{Tags: []string{"prod"}},
+	} {
+		rec := doAdminRequest(t, srv, http.MethodPost, "/api/keys", req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, "%+v", req)
+	}
+	rec := doAdminRequest(t, srv, http.MethodDelete, "/api/keys/unknown", nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestRevokedPeers_ReplacesPSKs(t *testing.T) {
+	srv := newTestServerWithS3(t)
+	makeTestAdmin(srv)
+	keys, err := newPSKKeys(bytes.Repeat([]byte{1}, 32), config.PSKConfig{Enabled: true})
+	require.NoError(t, err)
+	srv.psk = keys
+	before := srv.psk.update("desktop", time.Now())
+
+	pubKey, _ := generateTestSSHPubKey(t)
+	require.Equal(t, http.StatusOK, registerWithToken(t, srv, "test-token", "laptop", pubKey).Code)
+	rec := doAdminRequest(t, srv, http.MethodPost, "/api/revoked-peers", map[string]string{"peer": "laptop"})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	// None of the keys the revoked peer was sent work any longer, and the
+	// new secret is kept for restarts and other coordinators
+	after := srv.psk.update("desktop", time.Now())
+	require.Equal(t, before.Current, after.Current)
+	for i := range after.Keys {
+		assert.NotEqual(t, before.Keys[i].Mesh, after.Keys[i].Mesh)
+	}
+	secret, err := srv.s3SystemStore.LoadPSKSecret(t.Context())
+	require.NoError(t, err)
+	srv.psk.mu.RLock()
+	assert.Equal(t, srv.psk.secret, secret)
+	srv.psk.mu.RUnlock()
+}
+
+func TestReloadPSKSecret(t *testing.T) {
+	srv := newTestServerWithS3(t)
+	keys, err := newPSKKeys(bytes.Repeat([]byte{1}, 32), config.PSKConfig{Enabled: true})
+	require.NoError(t, err)
+	srv.psk = keys
+	before := srv.psk.update("desktop", time.Now())
+
+	// Nothing saved yet: the current secret is kept
+	srv.reloadPSKSecret(t.Context())
+	assert.Equal(t, before, srv.psk.update("desktop", time.Now()))
+
+	// Another coordinator replaced the secret when revoking a peer
+	replaced := bytes.Repeat([]byte{2}, 32)
+	require.NoError(t, srv.s3SystemStore.SavePSKSecret(t.Context(), replaced))
+	srv.reloadPSKSecret(t.Context())
+	after := srv.psk.update("desktop", time.Now())
+	for i := range after.Keys {
+		assert.NotEqual(t, before.Keys[i].Mesh, after.Keys[i].Mesh)
+	}
+	srv.psk.mu.RLock()
+	assert.Equal(t, replaced, srv.psk.secret)
+	srv.psk.mu.RUnlock()
+}
+
+func TestRevokedPeers_RevokeAndRestore(t *testing.T) {
+	srv := newTestServerWithS3(t)
+	makeTestAdmin(srv)
+
+	pubKey, _ := generateTestSSHPubKey(t)
+	peerID := peerIDFromPublicKey(pubKey)
+	rec := registerWithToken(t, srv, "test-token", "laptop", pubKey)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp proto.RegisterResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	_, err := srv.ValidateToken(resp.Token)
+	require.NoError(t, err)
+
+	rec = doAdminRequest(t, srv, http.MethodPost, "/api/revoked-peers", map[string]string{"peer": "laptop"})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var rp proto.RevokedPeer
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&rp))
+	assert.Equal(t, peerID, rp.PeerID)
+
+	// Removed from the mesh, and locked out whatever token it uses
+	srv.peersMu.RLock()
+	_, registered := srv.peers["laptop"]
+	srv.peersMu.RUnlock()
+	assert.False(t, registered)
+	assert.Equal(t, http.StatusForbidden, registerWithToken(t, srv, "test-token", "laptop", pubKey).Code)
+	_, err = srv.ValidateToken(resp.Token)
+	assert.Error(t, err)
+
+	stored, err := srv.s3SystemStore.LoadRevokedPeers(t.Context())
+	require.NoError(t, err)
+	assert.Len(t, stored, 1)
+
+	// Restoring lets the peer back in
+	rec = doAdminRequest(t, srv, http.MethodDelete, "/api/revoked-peers/"+peerID, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, http.StatusOK, registerWithToken(t, srv, "test-token", "laptop", pubKey).Code)
+
+	rec = doAdminRequest(t, srv, http.MethodPost, "/api/revoked-peers", map[string]string{"peer": "nobody"})
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestRevokedPeers_CutsOffSingleUseKey(t *testing.T) {
+	srv := newTestServerWithS3(t)
+	makeTestAdmin(srv)
+
+	key := createJoinKey(t, srv, proto.JoinKeyRequest{})
+	pubKey, _ := generateTestSSHPubKey(t)
+	require.Equal(t, http.StatusOK, registerWithToken(t, srv, key.Key, "kiosk", pubKey).Code)
+
+	rec := doAdminRequest(t, srv, http.MethodPost, "/api/revoked-peers", map[string]string{"peer": peerIDFromPublicKey(pubKey)})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	// The key's only peer is revoked, and no other peer can take its place
+	assert.Equal(t, http.StatusForbidden, registerWithToken(t, srv, key.Key, "kiosk", pubKey).Code)
+	otherKey, _ := generateTestSSHPubKey(t)
+	assert.Equal(t, http.StatusForbidden, registerWithToken(t, srv, key.Key, "kiosk", otherKey).Code)
+}
+
+func TestJoinKeys_OnlyAuthorizeRegistration(t *testing.T) {
+	srv := newTestServerWithS3(t)
+	makeTestAdmin(srv)
+
+	key := createJoinKey(t, srv, proto.JoinKeyRequest{Reusable: true})
+	pubKey, _ := generateTestSSHPubKey(t)
+	rec := registerWithToken(t, srv, key.Key, "kiosk", pubKey)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp proto.RegisterResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+
+	peerAPI := func(method, path, token string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// The key only joins; the peer API takes the peer's own token
+	assert.Equal(t, http.StatusUnauthorized, peerAPI(http.MethodGet, "/api/v1/peers", key.Key))
+	assert.Equal(t, http.StatusUnauthorized, peerAPI(http.MethodGet, "/api/v1/dns", key.Key))
+	assert.Equal(t, http.StatusUnauthorized, peerAPI(http.MethodDelete, "/api/v1/peers/kiosk", key.Key))
+	assert.Equal(t, http.StatusOK, peerAPI(http.MethodGet, "/api/v1/peers", resp.Token))
+
+	// Another peer joining with the reusable key keeps it valid for joining,
+	// but the revoked peer's token is cut off
+	otherKey, _ := generateTestSSHPubKey(t)
+	require.Equal(t, http.StatusOK, registerWithToken(t, srv, key.Key, "kiosk-2", otherKey).Code)
+	rec = doAdminRequest(t, srv, http.MethodPost, "/api/revoked-peers", map[string]string{"peer": peerIDFromPublicKey(pubKey)})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.True(t, srv.joinKeyAuthorizes(key.Key))
+	assert.Equal(t, http.StatusUnauthorized, peerAPI(http.MethodGet, "/api/v1/peers", resp.Token))
+	assert.Equal(t, http.StatusUnauthorized, peerAPI(http.MethodGet, "/api/v1/peers", key.Key))
+	assert.Equal(t, http.StatusForbidden, registerWithToken(t, srv, key.Key, "kiosk", pubKey).Code)
+}
+
+func TestRevokedPeers_CertificatesRevokedBySerial(t *testing.T) {
+	srv := newTestServerWithS3(t)
+	makeTestAdmin(srv)
+	ca := useTempCA(t, srv)
+
+	pubKey, _ := generateTestSSHPubKey(t)
+	require.Equal(t, http.StatusOK, registerWithToken(t, srv, "test-token", "laptop", pubKey).Code)
+	oldPEM, _, err := ca.GeneratePeerCert("laptop", "", "10.42.0.5")
+	require.NoError(t, err)
+	oldCert := parseCertPEM(t, oldPEM)
+
+	rec := doAdminRequest(t, srv, http.MethodPost, "/api/revoked-peers", map[string]string{"peer": "laptop"})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.Error(t, srv.verifyClientCert([][]byte{oldCert.Raw}, nil))
+
+	// A new peer given the name later is not locked out by it
+	newPEM, _, err := ca.GeneratePeerCert("laptop", "", "10.42.0.5")
+	require.NoError(t, err)
+	assert.NoError(t, srv.verifyClientCert([][]byte{parseCertPEM(t, newPEM).Raw}, nil))
+}
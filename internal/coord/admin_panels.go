@@ -345,6 +345,13 @@ func (s *Server) handleSystemHealth(w http.ResponseWriter, r *http.Request) {
 		s3.WGConcentratorPath,
 		s3.DNSCachePath,
 		s3.DNSAliasPath,
+		s3.DNSZonePath,
+		s3.RouteApprovalsPath,
+		s3.JoinKeysPath,
+		s3.RevokedPeersPath,
+		s3.IssuedCertsPath,
+		s3.ACMEAccountsPath,
+		s3.PeerTagsPath,
 	}
 
 	for _, path := range files {
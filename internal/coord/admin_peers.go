@@ -2,12 +2,16 @@ package coord
 
 import (
 	"encoding/json"
+	"errors"
 	"net"
 	"net/http"
+	"net/netip"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/tunnelmesh/tunnelmesh/internal/auth"
+	"github.com/tunnelmesh/tunnelmesh/internal/mesh"
 )
 
 // PeerInfo represents peer information for the API.
@@ -116,7 +120,7 @@ func (s *Server) getRequestOwner(r *http.Request) string {
 	return peerName
 }
 
-// getPeerByRemoteAddr looks up a peer by their mesh IP address.
+// getPeerByRemoteAddr looks up a peer by their mesh IPv4 or IPv6 address.
 // Returns the peer name if found, empty string otherwise.
 func (s *Server) getPeerByRemoteAddr(remoteAddr string) string {
 	// Extract IP from "ip:port" format
@@ -129,10 +133,48 @@ func (s *Server) getPeerByRemoteAddr(remoteAddr string) string {
 	s.peersMu.RLock()
 	defer s.peersMu.RUnlock()
 
+	ip := net.ParseIP(host)
 	for _, info := range s.peers {
-		if info.peer.MeshIP == host {
+		if info.peer.MeshIP == host || (ip != nil && info.peer.MeshIPv6 != "" && ip.Equal(net.ParseIP(info.peer.MeshIPv6))) {
 			return info.peer.Name
 		}
 	}
 	return ""
 }
+
+// resolveWebhookHost resolves the host of an S3 webhook endpoint, a peer's
+// mesh IP or mesh name, to that peer's mesh addresses. Other hosts are
+// refused, and so are coordinators, whose admin and internal APIs webhooks
+// must not reach.
+func (s *Server) resolveWebhookHost(host string) ([]netip.Addr, error) {
+	ip, ipErr := netip.ParseAddr(host)
+	name, _ := strings.CutSuffix(strings.ToLower(host), mesh.DomainSuffix)
+	coordIPs := s.GetCoordMeshIPs()
+
+	s.peersMu.RLock()
+	defer s.peersMu.RUnlock()
+	for _, info := range s.peers {
+		var addrs []netip.Addr
+		for _, meshIP := range []string{info.peer.MeshIP, info.peer.MeshIPv6} {
+			if addr, err := netip.ParseAddr(meshIP); err == nil {
+				addrs = append(addrs, addr)
+			}
+		}
+		if ipErr == nil {
+			if !slices.Contains(addrs, ip.Unmap()) {
+				continue
+			}
+			addrs = []netip.Addr{ip.Unmap()}
+		} else if info.peer.Name != name {
+			continue
+		}
+
+		if info.peer.IsCoordinator || slices.ContainsFunc(addrs, func(addr netip.Addr) bool {
+			return slices.Contains(coordIPs, addr.String())
+		}) {
+			return nil, errors.New("coordinators cannot receive webhooks")
+		}
+		return addrs, nil
+	}
+	return nil, errors.New("not a mesh peer")
+}
@@ -5,10 +5,12 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
 )
 
 func TestPeersMgmt_MethodNotAllowed(t *testing.T) {
@@ -50,7 +52,7 @@ func TestGetPeerByRemoteAddr_Found(t *testing.T) {
 	ts := httptest.NewServer(srv)
 	defer ts.Close()
 	client := NewClient(ts.URL, "test-token")
-	_, err = client.Register("addr-peer", "SHA256:addrkey", []string{"1.2.3.4"}, nil, 2222, 0, false, "v1.0.0", nil, "", false, nil, false, false)
+	_, err = client.Register(proto.RegisterRequest{Name: "addr-peer", PublicKey: "SHA256:addrkey", PublicIPs: []string{"1.2.3.4"}, SSHPort: 2222, Version: "v1.0.0"})
 	require.NoError(t, err)
 
 	// Look up by mesh IP (peer gets allocated a mesh IP on registration)
@@ -69,3 +71,27 @@ func TestGetPeerByRemoteAddr_NotFound(t *testing.T) {
 	result := srv.getPeerByRemoteAddr("192.168.99.99:12345")
 	assert.Empty(t, result)
 }
+
+func TestResolveWebhookHost(t *testing.T) {
+	srv := newTestServer(t)
+
+	srv.peersMu.Lock()
+	srv.peers["alice"] = &peerInfo{peer: &proto.Peer{Name: "alice", MeshIP: "10.42.0.100", MeshIPv6: "fd00::100"}}
+	srv.peers["coord"] = &peerInfo{peer: &proto.Peer{Name: "coord", MeshIP: "10.42.0.1", IsCoordinator: true}}
+	srv.peersMu.Unlock()
+
+	addrs, err := srv.resolveWebhookHost("10.42.0.100")
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Addr{netip.MustParseAddr("10.42.0.100")}, addrs)
+
+	for _, host := range []string{"alice", "alice.tunnelmesh", "Alice.TunnelMesh"} {
+		addrs, err = srv.resolveWebhookHost(host)
+		require.NoError(t, err, host)
+		assert.Equal(t, []netip.Addr{netip.MustParseAddr("10.42.0.100"), netip.MustParseAddr("fd00::100")}, addrs, host)
+	}
+
+	for _, host := range []string{"coord", "10.42.0.1", "127.0.0.1", "169.254.169.254", "example.com", "bob.tunnelmesh"} {
+		_, err = srv.resolveWebhookHost(host)
+		assert.Error(t, err, host)
+	}
+}
@@ -12,24 +12,26 @@ import (
 
 // RoleBindingRequest is the request body for creating a peer role binding.
 type RoleBindingRequest struct {
-	PeerID       string `json:"peer_id"`
-	RoleName     string `json:"role_name"`
-	BucketScope  string `json:"bucket_scope,omitempty"`
-	ObjectPrefix string `json:"object_prefix,omitempty"`
+	PeerID       string            `json:"peer_id"`
+	RoleName     string            `json:"role_name"`
+	BucketScope  string            `json:"bucket_scope,omitempty"`
+	ObjectPrefix string            `json:"object_prefix,omitempty"`
+	ObjectTags   map[string]string `json:"object_tags,omitempty"`
 }
 
 // handleBindings handles GET (list) and POST (create) for peer role bindings.
 // BindingInfo represents a role binding (peer or group) for the UI.
 type BindingInfo struct {
-	Name         string    `json:"name"`
-	PeerID       string    `json:"peer_id,omitempty"`
-	GroupName    string    `json:"group_name,omitempty"`
-	RoleName     string    `json:"role_name"`
-	BucketScope  string    `json:"bucket_scope,omitempty"`
-	ObjectPrefix string    `json:"object_prefix,omitempty"`
-	PanelScope   string    `json:"panel_scope,omitempty"`
-	Protected    bool      `json:"protected"`
-	CreatedAt    time.Time `json:"created_at"`
+	Name         string            `json:"name"`
+	PeerID       string            `json:"peer_id,omitempty"`
+	GroupName    string            `json:"group_name,omitempty"`
+	RoleName     string            `json:"role_name"`
+	BucketScope  string            `json:"bucket_scope,omitempty"`
+	ObjectPrefix string            `json:"object_prefix,omitempty"`
+	ObjectTags   map[string]string `json:"object_tags,omitempty"`
+	PanelScope   string            `json:"panel_scope,omitempty"`
+	Protected    bool              `json:"protected"`
+	CreatedAt    time.Time         `json:"created_at"`
 }
 
 func (s *Server) handleBindings(w http.ResponseWriter, r *http.Request) {
@@ -52,6 +54,7 @@ func (s *Server) handleBindings(w http.ResponseWriter, r *http.Request) {
 				RoleName:     b.RoleName,
 				BucketScope:  b.BucketScope,
 				ObjectPrefix: b.ObjectPrefix,
+				ObjectTags:   b.ObjectTags,
 				PanelScope:   b.PanelScope,
 				Protected:    protected,
 				CreatedAt:    b.CreatedAt,
@@ -67,6 +70,7 @@ func (s *Server) handleBindings(w http.ResponseWriter, r *http.Request) {
 				RoleName:     gb.RoleName,
 				BucketScope:  gb.BucketScope,
 				ObjectPrefix: gb.ObjectPrefix,
+				ObjectTags:   gb.ObjectTags,
 				PanelScope:   gb.PanelScope,
 				Protected:    protected,
 				CreatedAt:    gb.CreatedAt,
@@ -92,6 +96,7 @@ func (s *Server) handleBindings(w http.ResponseWriter, r *http.Request) {
 		}
 
 		binding := auth.NewRoleBindingWithPrefix(req.PeerID, req.RoleName, req.BucketScope, req.ObjectPrefix)
+		binding.ObjectTags = req.ObjectTags
 		s.s3Authorizer.Bindings.Add(binding)
 
 		// Persist
@@ -0,0 +1,192 @@
+package coord
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"sort"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
+)
+
+// handleRoutes lists subnet routes: those advertised by connected peers, and
+// approvals for routes no longer advertised.
+func (s *Server) handleRoutes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.peersMu.RLock()
+	routes := make([]proto.SubnetRoute, 0)
+	for name, info := range s.peers {
+		for _, prefix := range info.routes {
+			routes = append(routes, proto.SubnetRoute{
+				Peer:       name,
+				Prefix:     prefix,
+				Advertised: true,
+				Approved:   slices.Contains(s.routeApprovals[name], prefix),
+			})
+		}
+	}
+	for name, approved := range s.routeApprovals {
+		for _, prefix := range approved {
+			if info, ok := s.peers[name]; !ok || !slices.Contains(info.routes, prefix) {
+				routes = append(routes, proto.SubnetRoute{Peer: name, Prefix: prefix, Approved: true})
+			}
+		}
+	}
+	s.peersMu.RUnlock()
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Peer != routes[j].Peer {
+			return routes[i].Peer < routes[j].Peer
+		}
+		return routes[i].Prefix < routes[j].Prefix
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(routes)
+}
+
+// handleRouteApprove approves a subnet route advertised by a peer, so other
+// peers route the prefix through it. A prefix is approved for one peer only.
+func (s *Server) handleRouteApprove(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.decodeRouteRequest(w, r)
+	if !ok {
+		return
+	}
+
+	s.peersMu.Lock()
+	info, exists := s.peers[req.Peer]
+	if !exists || !slices.Contains(info.routes, req.Prefix) {
+		s.peersMu.Unlock()
+		s.jsonError(w, fmt.Sprintf("peer %q does not advertise %s", req.Peer, req.Prefix), http.StatusNotFound)
+		return
+	}
+	for name, approved := range s.routeApprovals {
+		if name != req.Peer && slices.Contains(approved, req.Prefix) {
+			s.peersMu.Unlock()
+			s.jsonError(w, fmt.Sprintf("%s is already approved for peer %q", req.Prefix, name), http.StatusConflict)
+			return
+		}
+	}
+	if !slices.Contains(s.routeApprovals[req.Peer], req.Prefix) {
+		approved := append(slices.Clone(s.routeApprovals[req.Peer]), req.Prefix)
+		sort.Strings(approved)
+		s.routeApprovals[req.Peer] = approved
+		s.refreshPeerRoutes(req.Peer)
+	}
+	s.peersMu.Unlock()
+
+	log.Info().Str("peer", req.Peer).Str("prefix", req.Prefix).Msg("subnet route approved")
+	s.saveRouteApprovals(r.Context())
+
+	req.Advertised = true
+	req.Approved = true
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(req)
+}
+
+// handleRouteRevoke revokes the approval of a subnet route.
+func (s *Server) handleRouteRevoke(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.decodeRouteRequest(w, r)
+	if !ok {
+		return
+	}
+
+	s.peersMu.Lock()
+	approved := s.routeApprovals[req.Peer]
+	i := slices.Index(approved, req.Prefix)
+	if i < 0 {
+		s.peersMu.Unlock()
+		s.jsonError(w, "route not approved", http.StatusNotFound)
+		return
+	}
+	if approved = slices.Delete(slices.Clone(approved), i, i+1); len(approved) > 0 {
+		s.routeApprovals[req.Peer] = approved
+	} else {
+		delete(s.routeApprovals, req.Peer)
+	}
+	s.refreshPeerRoutes(req.Peer)
+	s.peersMu.Unlock()
+
+	log.Info().Str("peer", req.Peer).Str("prefix", req.Prefix).Msg("subnet route revoked")
+	s.saveRouteApprovals(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "revoked"})
+}
+
+// decodeRouteRequest checks that a route change comes from an admin and
+// decodes its {peer, prefix} body, canonicalizing the prefix. Writes an error
+// response and returns false if the request is not allowed or invalid.
+func (s *Server) decodeRouteRequest(w http.ResponseWriter, r *http.Request) (proto.SubnetRoute, bool) {
+	var req proto.SubnetRoute
+	if r.Method != http.MethodPost {
+		s.jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return req, false
+	}
+	if s.s3Authorizer == nil || !s.s3Authorizer.IsAdmin(s.getRequestOwner(r)) {
+		s.jsonError(w, "admin access required", http.StatusForbidden)
+		return req, false
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.jsonError(w, "invalid request body", http.StatusBadRequest)
+		return req, false
+	}
+	if req.Peer == "" {
+		s.jsonError(w, "peer is required", http.StatusBadRequest)
+		return req, false
+	}
+	ipNet, err := proto.ParseRoute(req.Prefix)
+	if err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadRequest)
+		return req, false
+	}
+	req.Prefix = ipNet.String()
+	return req, true
+}
+
+// approvedRoutes returns the routes a peer advertises that an admin approved,
+// the ones served to other peers. Must be called with peersMu held.
+func (s *Server) approvedRoutes(name string, advertised []string) []string {
+	var routes []string
+	for _, prefix := range advertised {
+		if slices.Contains(s.routeApprovals[name], prefix) {
+			routes = append(routes, prefix)
+		}
+	}
+	return routes
+}
+
+// refreshPeerRoutes updates the routes served for a connected peer after its
+// approvals change. The peer is copied rather than modified in place, as
+// handlers encode it after releasing the lock. Must be called with peersMu held.
+func (s *Server) refreshPeerRoutes(name string) {
+	info, ok := s.peers[name]
+	if !ok {
+		return
+	}
+	peer := *info.peer
+	peer.Routes = s.approvedRoutes(name, info.routes)
+	info.peer = &peer
+}
+
+// saveRouteApprovals persists the approved subnet routes to S3.
+func (s *Server) saveRouteApprovals(ctx context.Context) {
+	if s.s3SystemStore == nil {
+		return
+	}
+	s.peersMu.RLock()
+	approvals := maps.Clone(s.routeApprovals)
+	s.peersMu.RUnlock()
+
+	if err := s.s3SystemStore.SaveRouteApprovals(ctx, approvals); err != nil {
+		log.Warn().Err(err).Msg("failed to persist subnet route approvals")
+	}
+}
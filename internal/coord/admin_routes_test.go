@@ -0,0 +1,126 @@
+package coord
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
+)
+
+func registerWithRoutes(t *testing.T, srv *Server, name string, routes []string) *httptest.ResponseRecorder {
+	t.Helper()
+	body, err := json.Marshal(proto.RegisterRequest{
+		Name:      name,
+		PublicKey: "SHA256:" + name,
+		SSHPort:   2222,
+		Routes:    routes,
+	})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/register", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-token")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	return rec
+}
+
+func listPeerRoutes(t *testing.T, srv *Server, name string) []string {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/peers/"+name, nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var peer proto.Peer
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&peer))
+	return peer.Routes
+}
+
+func TestRegister_RejectsInvalidRoutes(t *testing.T) {
+	srv := newTestServer(t)
+
+	for _, routes := range [][]string{
+		{"0.0.0.0/0"},
+		{"10.42.5.0/24"}, // Inside the mesh
+		{"10.0.0.0/8"},   // Contains the mesh
+		{"fd00::/64"},
+	} {
+		rec := registerWithRoutes(t, srv, "office", routes)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, routes)
+	}
+
+	rec := registerWithRoutes(t, srv, "office", []string{"192.168.10.1/24", "192.168.10.0/24"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	srv.peersMu.RLock()
+	assert.Equal(t, []string{"192.168.10.0/24"}, srv.peers["office"].routes)
+	srv.peersMu.RUnlock()
+}
+
+func TestRoutes_RequireAdmin(t *testing.T) {
+	srv := newTestServerWithS3AndBucket(t)
+	require.Equal(t, http.StatusOK, registerWithRoutes(t, srv, "office", []string{"192.168.10.0/24"}).Code)
+
+	for _, path := range []string{"/api/routes/approve", "/api/routes/revoke"} {
+		rec := doAdminRequest(t, srv, http.MethodPost, path, proto.SubnetRoute{Peer: "office", Prefix: "192.168.10.0/24"})
+		assert.Equal(t, http.StatusForbidden, rec.Code, path)
+	}
+	assert.Empty(t, listPeerRoutes(t, srv, "office"))
+}
+
+func TestRoutes_ApproveAndRevoke(t *testing.T) {
+	srv := newTestServerWithS3AndBucket(t)
+	makeTestAdmin(srv)
+
+	require.Equal(t, http.StatusOK, registerWithRoutes(t, srv, "office", []string{"192.168.10.0/24", "192.168.20.0/24"}).Code)
+	require.Equal(t, http.StatusOK, registerWithRoutes(t, srv, "lab", []string{"192.168.10.0/24"}).Code)
+
+	// Advertised routes are not served until approved
+	assert.Empty(t, listPeerRoutes(t, srv, "office"))
+
+	rec := doAdminRequest(t, srv, http.MethodPost, "/api/routes/approve", proto.SubnetRoute{Peer: "office", Prefix: "192.168.10.0/24"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, []string{"192.168.10.0/24"}, listPeerRoutes(t, srv, "office"))
+
+	// A prefix is approved for one peer only
+	rec = doAdminRequest(t, srv, http.MethodPost, "/api/routes/approve", proto.SubnetRoute{Peer: "lab", Prefix: "192.168.10.0/24"})
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	// Only advertised routes can be approved
+	rec = doAdminRequest(t, srv, http.MethodPost, "/api/routes/approve", proto.SubnetRoute{Peer: "office", Prefix: "172.16.0.0/12"})
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec = doAdminRequest(t, srv, http.MethodPost, "/api/routes/approve", proto.SubnetRoute{Peer: "office", Prefix: "bogus"})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = doAdminRequest(t, srv, http.MethodGet, "/api/routes", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var routes []proto.SubnetRoute
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&routes))
+	assert.Equal(t, []proto.SubnetRoute{
+		{Peer: "lab", Prefix: "192.168.10.0/24", Advertised: true},
+		{Peer: "office", Prefix: "192.168.10.0/24", Advertised: true, Approved: true},
+		{Peer: "office", Prefix: "192.168.20.0/24", Advertised: true},
+	}, routes)
+
+	// Approvals survive re-registration and are persisted
+	require.Equal(t, http.StatusOK, registerWithRoutes(t, srv, "office", []string{"192.168.10.0/24"}).Code)
+	assert.Equal(t, []string{"192.168.10.0/24"}, listPeerRoutes(t, srv, "office"))
+	approvals, err := srv.s3SystemStore.LoadRouteApprovals(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"office": {"192.168.10.0/24"}}, approvals)
+
+	rec = doAdminRequest(t, srv, http.MethodPost, "/api/routes/revoke", proto.SubnetRoute{Peer: "office", Prefix: "192.168.10.0/24"})
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, listPeerRoutes(t, srv, "office"))
+
+	rec = doAdminRequest(t, srv, http.MethodPost, "/api/routes/revoke", proto.SubnetRoute{Peer: "office", Prefix: "192.168.10.0/24"})
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	approvals, err = srv.s3SystemStore.LoadRouteApprovals(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, approvals)
+}
@@ -336,8 +336,8 @@ func (s *Server) handleS3Proxy(w http.ResponseWriter, r *http.Request) {
 		if strings.HasPrefix(parts[1], "objects/") {
 			objPath := strings.TrimPrefix(parts[1], "objects/")
 
-			// Check for version subresources (e.g., objects/{key}/versions, objects/{key}/restore, objects/{key}/undelete)
-			// Split on /versions, /restore, or /undelete to extract key and subresource
+			// Check for subresources (e.g., objects/{key}/versions, objects/{key}/restore, objects/{key}/undelete, objects/{key}/presign)
+			// Split on /versions, /restore, /undelete or a trailing /presign to extract key and subresource
 			var key, subresource string
 			if idx := strings.Index(objPath, "/versions"); idx >= 0 {
 				key = objPath[:idx]
@@ -348,6 +348,9 @@ func (s *Server) handleS3Proxy(w http.ResponseWriter, r *http.Request) {
 			} else if idx := strings.Index(objPath, "/undelete"); idx >= 0 {
 				key = objPath[:idx]
 				subresource = "undelete"
+			} else if strings.HasSuffix(objPath, "/presign") {
+				key = strings.TrimSuffix(objPath, "/presign")
+				subresource = "presign"
 			} else {
 				key = objPath
 			}
@@ -377,6 +380,10 @@ func (s *Server) handleS3Proxy(w http.ResponseWriter, r *http.Request) {
 				s.withS3AdminMetrics(w, "undeleteObject", func(w http.ResponseWriter) {
 					s.handleS3UndeleteObject(w, r, bucket, key)
 				})
+			case "presign":
+				s.withS3AdminMetrics(w, "presignObject", func(w http.ResponseWriter) {
+					s.handleS3PresignObject(w, r, bucket, key)
+				})
 			default:
 				s.handleS3Object(w, r, bucket, key)
 			}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
@@ -22,6 +23,7 @@ type S3VersionInfo struct {
 	ETag         string `json:"etag"`
 	LastModified string `json:"last_modified"`
 	IsCurrent    bool   `json:"is_current"`
+	DeleteMarker bool   `json:"delete_marker,omitempty"`
 }
 
 // RestoreVersionRequest is the request body for restoring a version.
@@ -29,6 +31,22 @@ type RestoreVersionRequest struct {
 	VersionID string `json:"version_id"`
 }
 
+// PresignRequest is the request body for minting a presigned object URL.
+type PresignRequest struct {
+	Method    string `json:"method"`     // GET (default) or PUT
+	ExpiresIn int64  `json:"expires_in"` // Seconds; defaults to DefaultPresignExpiry
+}
+
+// PresignResponse is returned when a presigned URL is minted.
+type PresignResponse struct {
+	URL       string `json:"url"`
+	Method    string `json:"method"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// DefaultPresignExpiry is the validity of presigned URLs when none is requested.
+const DefaultPresignExpiry = time.Hour
+
 // MaxS3ObjectSize is the maximum size for S3 object uploads (10MB).
 const MaxS3ObjectSize = 10 * 1024 * 1024
 
@@ -340,6 +358,7 @@ func (s *Server) handleS3PutObject(w http.ResponseWriter, r *http.Request, bucke
 	if s.replicator != nil {
 		s.replicator.EnqueueReplication(bucket, key, "put")
 	}
+	s.notifyBucketEvent(r, s3.EventObjectCreatedPut, bucket, key, meta)
 
 	w.Header().Set("ETag", meta.ETag)
 	w.WriteHeader(http.StatusOK)
@@ -375,10 +394,25 @@ func (s *Server) handleS3DeleteObject(w http.ResponseWriter, r *http.Request, bu
 	if s.replicator != nil {
 		s.replicator.EnqueueReplication(bucket, key, "delete")
 	}
+	s.notifyBucketEvent(r, s3.EventObjectRemovedDelete, bucket, key, nil)
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// notifyBucketEvent sends a bucket event for a change made through the admin API.
+func (s *Server) notifyBucketEvent(r *http.Request, name, bucket, key string, meta *s3.ObjectMeta) {
+	if s.s3Notifier == nil {
+		return
+	}
+	event := s3.BucketEvent{Name: name, Bucket: bucket, Key: key, UserID: s.getRequestOwner(r)}
+	if meta != nil {
+		event.Size = meta.Size
+		event.ETag = meta.ETag
+		event.VersionID = meta.VersionID
+	}
+	s.s3Notifier.NotifyEvent(event)
+}
+
 // handleS3HeadObject returns object metadata.
 // Tries local storage first; forwards to primary coordinator on miss.
 func (s *Server) handleS3HeadObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
@@ -449,6 +483,7 @@ func (s *Server) handleS3ListVersions(w http.ResponseWriter, r *http.Request, bu
 			ETag:         v.ETag,
 			LastModified: v.LastModified.Format(time.RFC3339),
 			IsCurrent:    v.IsCurrent,
+			DeleteMarker: v.DeleteMarker,
 		})
 	}
 
@@ -517,6 +552,8 @@ func (s *Server) handleS3RestoreVersion(w http.ResponseWriter, r *http.Request,
 			s.jsonError(w, "bucket not found", http.StatusNotFound)
 		case errors.Is(err, s3.ErrObjectNotFound):
 			s.jsonError(w, "version not found", http.StatusNotFound)
+		case errors.Is(err, s3.ErrDeleteMarker):
+			s.jsonError(w, "cannot restore a delete marker", http.StatusBadRequest)
 		case errors.Is(err, s3.ErrAccessDenied):
 			s.jsonError(w, "access denied", http.StatusForbidden)
 		default:
@@ -580,6 +617,12 @@ func (s *Server) handleS3UndeleteObject(w http.ResponseWriter, r *http.Request,
 	// Update listing index: move from recycled back to objects
 	s.updateListingIndex(bucket, key, nil, "undelete")
 
+	if s.s3Notifier != nil {
+		if meta, err := s.s3Store.HeadObject(r.Context(), bucket, key); err == nil {
+			s.notifyBucketEvent(r, s3.EventObjectRestoredRecycleBin, bucket, key, meta)
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(map[string]interface{}{
 		"status": "restored",
@@ -677,3 +720,97 @@ func (s *Server) handleS3GetRecycledObject(w http.ResponseWriter, r *http.Reques
 
 	_, _ = io.Copy(w, reader)
 }
+
+// handleS3PresignObject mints a presigned S3 API URL for an object, signed with
+// the caller's own S3 credentials. The URL grants only what the caller could do
+// themselves, and only until it expires.
+func (s *Server) handleS3PresignObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	if r.Method != http.MethodPost {
+		s.jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req PresignRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(io.LimitReader(r.Body, 4096)).Decode(&req); err != nil {
+			s.jsonError(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	method := strings.ToUpper(req.Method)
+	var verb string
+	switch method {
+	case "", http.MethodGet:
+		method, verb = http.MethodGet, "get"
+	case http.MethodPut:
+		verb = "put"
+		if bucket == auth.SystemBucket {
+			s.jsonError(w, "bucket is read-only", http.StatusForbidden)
+			return
+		}
+	default:
+		s.jsonError(w, "method must be GET or PUT", http.StatusBadRequest)
+		return
+	}
+
+	expires := DefaultPresignExpiry
+	if req.ExpiresIn != 0 {
+		expires = time.Duration(req.ExpiresIn) * time.Second
+	}
+	if expires < time.Second || expires > s3.MaxPresignExpiry {
+		s.jsonError(w, "expires_in must be between 1 and 604800 seconds", http.StatusBadRequest)
+		return
+	}
+
+	userID := s.getRequestOwner(r)
+	if userID == "" {
+		s.jsonError(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+	if !s.s3Authorizer.Authorize(userID, verb, "objects", bucket, key) {
+		s.jsonError(w, "permission denied", http.StatusForbidden)
+		return
+	}
+
+	accessKey, secretKey, ok := s.s3Credentials.GetCredentials(userID)
+	if !ok {
+		s.jsonError(w, "no S3 credentials registered for caller", http.StatusForbidden)
+		return
+	}
+
+	// The S3 API listens on the same mesh address as the admin API.
+	s3Port := s.s3Port.Load()
+	if s3Port == 0 {
+		s.jsonError(w, "S3 API not listening", http.StatusServiceUnavailable)
+		return
+	}
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	now := time.Now().UTC()
+	presigned, err := s3.PresignURL(s3.PresignOptions{
+		Endpoint:  "https://" + net.JoinHostPort(host, strconv.Itoa(int(s3Port))),
+		Method:    method,
+		Bucket:    bucket,
+		Key:       key,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		Expires:   expires,
+		Now:       now,
+	})
+	if err != nil {
+		s.jsonError(w, "failed to presign URL: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().Str("user_id", userID).Str("bucket", bucket).Str("key", key).Str("method", method).Dur("expires", expires).Msg("minted presigned S3 URL")
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(PresignResponse{
+		URL:       presigned,
+		Method:    method,
+		ExpiresAt: now.Add(expires).Format(time.RFC3339),
+	})
+}
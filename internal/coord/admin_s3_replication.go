@@ -5,6 +5,7 @@ import (
 	"net"
 	"net/http"
 	"net/http/httputil"
+	"strings"
 	"time"
 )
 
@@ -123,6 +124,8 @@ func (s *Server) forwardS3Request(w http.ResponseWriter, r *http.Request, target
 
 	proxy := &httputil.ReverseProxy{
 		Director: func(req *http.Request) {
+			// Preserve the client's Host so the target can verify SigV4 signatures.
+			req.Header.Set("X-TunnelMesh-Original-Host", req.Host)
 			req.URL.Scheme = "https"
 			req.URL.Host = targetIP
 			req.Host = targetIP
@@ -136,6 +139,53 @@ func (s *Server) forwardS3Request(w http.ResponseWriter, r *http.Request, target
 	proxy.ServeHTTP(w, r)
 }
 
+// forwardingHeaders are set by coordinators forwarding S3 requests to each
+// other, and trusted by the target.
+var forwardingHeaders = []string{
+	"X-TunnelMesh-Forwarded",
+	"X-TunnelMesh-Original-Host",
+	"X-TunnelMesh-Bucket-Owner",
+}
+
+// stripForwardingHeaders removes the forwarding headers from requests that
+// don't come from another coordinator, so clients can't forge them.
+func (s *Server) stripForwardingHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.isCoordinatorRequest(r) {
+			for _, h := range forwardingHeaders {
+				r.Header.Del(h)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isCoordinatorRequest reports whether a request comes from another
+// coordinator: its TLS client certificate verifies against the mesh trust
+// bundle and CRL, and names a registered coordinator.
+func (s *Server) isCoordinatorRequest(r *http.Request) bool {
+	cfg := s.meshTLS.Load()
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 || cfg == nil || cfg.VerifyConnection == nil {
+		return false
+	}
+	cs := *r.TLS
+	cs.ServerName = "" // The client's certificate isn't issued for our name
+	if err := cfg.VerifyConnection(cs); err != nil {
+		return false
+	}
+	name := strings.TrimSuffix(cs.PeerCertificates[0].Subject.CommonName, ".tunnelmesh")
+
+	s.peersMu.RLock()
+	defer s.peersMu.RUnlock()
+	_, ok := s.coordinators[name]
+	return ok
+}
+
+// PrimaryCoordinator implements s3.RequestForwarder.
+func (s *Server) PrimaryCoordinator(bucket, key string) string {
+	return s.objectPrimaryCoordinator(bucket, key)
+}
+
 // ForwardS3Request implements s3.RequestForwarder. It checks if the given bucket/key
 // should be handled by a different coordinator and forwards the request if so.
 // The port parameter specifies the target port (e.g. "9000" for S3 API, "" for default 443).
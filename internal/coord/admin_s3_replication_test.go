@@ -2,12 +2,17 @@ package coord
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
 )
 
 func TestUpdatePeerListingsAfterForward_Put(t *testing.T) {
@@ -262,3 +267,50 @@ func TestObjectPrimaryCoordinator_NilIPs(t *testing.T) {
 	// No stored IPs - should return empty
 	assert.Equal(t, "", srv.objectPrimaryCoordinator("bucket", "key"))
 }
+
+func TestStripForwardingHeaders(t *testing.T) {
+	srv := newTestServerWithListingIndex(t)
+	srv.peersMu.Lock()
+	srv.coordinators["coord2"] = &peerInfo{peer: &proto.Peer{Name: "coord2", IsCoordinator: true}}
+	srv.peersMu.Unlock()
+
+	var verifyErr error
+	srv.SetMeshTLS(&tls.Config{
+		VerifyConnection: func(tls.ConnectionState) error { return verifyErr },
+	})
+
+	var got http.Header
+	handler := srv.stripForwardingHeaders(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	send := func(clientCN string) {
+		req := httptest.NewRequest(http.MethodGet, "/bucket/key", nil)
+		req.Header.Set("X-TunnelMesh-Forwarded", "true")
+		req.Header.Set("X-TunnelMesh-Original-Host", "10.42.0.1:9000")
+		req.Header.Set("X-TunnelMesh-Bucket-Owner", "alice-id")
+		if clientCN != "" {
+			req.TLS = &tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: clientCN}}},
+			}
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// Forwarded by a coordinator
+	send("coord2.tunnelmesh")
+	assert.Equal(t, "true", got.Get("X-TunnelMesh-Forwarded"))
+	assert.Equal(t, "10.42.0.1:9000", got.Get("X-TunnelMesh-Original-Host"))
+	assert.Equal(t, "alice-id", got.Get("X-TunnelMesh-Bucket-Owner"))
+
+	// Forged by a client without a certificate, a peer that isn't a
+	// coordinator, or a certificate that doesn't verify
+	send("")
+	assert.Empty(t, got.Get("X-TunnelMesh-Forwarded"))
+	assert.Empty(t, got.Get("X-TunnelMesh-Original-Host"))
+	send("laptop.tunnelmesh")
+	assert.Empty(t, got.Get("X-TunnelMesh-Original-Host"))
+	verifyErr = errors.New("unknown authority")
+	send("coord2.tunnelmesh")
+	assert.Empty(t, got.Get("X-TunnelMesh-Original-Host"))
+	assert.Empty(t, got.Get("X-TunnelMesh-Bucket-Owner"))
+}
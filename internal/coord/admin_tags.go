@@ -0,0 +1,131 @@
+package coord
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"slices"
+	"sort"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
+)
+
+// handleTags lists the peer tags (GET) or sets the tags of a peer (PUT).
+// Tags are kept by peer name, so peers can be tagged before they join.
+func (s *Server) handleTags(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.peersMu.RLock()
+		tags := make([]proto.PeerTags, 0, len(s.peerTags))
+		for name, peerTags := range s.peerTags {
+			tags = append(tags, proto.PeerTags{Peer: name, Tags: slices.Clone(peerTags)})
+		}
+		s.peersMu.RUnlock()
+
+		sort.Slice(tags, func(i, j int) bool { return tags[i].Peer < tags[j].Peer })
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(tags)
+
+	case http.MethodPut:
+		// Tags grant group memberships, so only admins can set them
+		if s.s3Authorizer == nil || !s.s3Authorizer.IsAdmin(s.getRequestOwner(r)) {
+			s.jsonError(w, "admin access required", http.StatusForbidden)
+			return
+		}
+
+		var req proto.PeerTags
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.jsonError(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.Peer == "" {
+			s.jsonError(w, "peer is required", http.StatusBadRequest)
+			return
+		}
+		tags, err := proto.NormalizeTags(req.Tags)
+		if err != nil {
+			s.jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s.peersMu.Lock()
+		if len(tags) > 0 {
+			s.peerTags[req.Peer] = tags
+		} else {
+			delete(s.peerTags, req.Peer)
+		}
+		s.refreshPeerTags(req.Peer)
+		s.peersMu.Unlock()
+
+		log.Info().Str("peer", req.Peer).Strs("tags", tags).Msg("peer tags set")
+		s.savePeerTags(r.Context())
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(proto.PeerTags{Peer: req.Peer, Tags: tags})
+
+	default:
+		s.jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// addPeerTags adds tags to a peer's, returning whether any was new. Must be
+// called with peersMu held.
+func (s *Server) addPeerTags(name string, tags []string) bool {
+	merged := slices.Clone(s.peerTags[name])
+	for _, tag := range tags {
+		if !slices.Contains(merged, tag) {
+			merged = append(merged, tag)
+		}
+	}
+	if len(merged) == len(s.peerTags[name]) {
+		return false
+	}
+	slices.Sort(merged)
+	s.peerTags[name] = merged
+	return true
+}
+
+// refreshPeerTags updates the tags served for a connected peer, and the
+// groups they make it a member of, after they change. The peer is copied
+// rather than modified in place, as handlers encode it after releasing the
+// lock. Must be called with peersMu held.
+func (s *Server) refreshPeerTags(name string) {
+	info, ok := s.peers[name]
+	if !ok {
+		return
+	}
+	peer := *info.peer
+	peer.Tags = slices.Clone(s.peerTags[name])
+	info.peer = &peer
+
+	if info.peerID != "" && s.s3Authorizer != nil && s.s3Authorizer.Groups != nil {
+		s.s3Authorizer.Groups.SetPeerTags(info.peerID, peer.Tags)
+	}
+}
+
+// clientTags returns the tags of the WireGuard clients, by mesh IP, as
+// reported by the concentrators serving them. Must be called with peersMu
+// held.
+func (s *Server) clientTags() map[string][]string {
+	tags := make(map[string][]string)
+	for _, info := range s.peers {
+		maps.Copy(tags, info.peer.ClientTags)
+	}
+	return tags
+}
+
+// savePeerTags persists the peer tags to S3.
+func (s *Server) savePeerTags(ctx context.Context) {
+	if s.s3SystemStore == nil {
+		return
+	}
+	s.peersMu.RLock()
+	tags := maps.Clone(s.peerTags)
+	s.peersMu.RUnlock()
+
+	if err := s.s3SystemStore.SavePeerTags(ctx, tags); err != nil {
+		log.Warn().Err(err).Msg("failed to persist peer tags")
+	}
+}
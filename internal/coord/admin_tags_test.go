@@ -0,0 +1,138 @@
+package coord
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
+)
+
+func TestTags_RequireAdmin(t *testing.T) {
+	srv := newTestServerWithS3(t)
+
+	rec := doAdminRequest(t, srv, http.MethodPut, "/api/tags", proto.PeerTags{Peer: "web", Tags: []string{"tag:prod"}})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	srv.peersMu.RLock()
+	assert.Empty(t, srv.peerTags["web"])
+	srv.peersMu.RUnlock()
+}
+
+func TestTags_SetAndList(t *testing.T) {
+	srv := newTestServerWithS3(t)
+	makeTestAdmin(srv)
+	_, err := srv.s3Authorizer.Groups.Create("production", "")
+	require.NoError(t, err)
+	require.NoError(t, srv.s3Authorizer.Groups.AddMember("production", "tag:prod"))
+
+	pubKey, _ := generateTestSSHPubKey(t)
+	rec := registerWithToken(t, srv, "test-token", "web", pubKey)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	peerID := peerIDFromPublicKey(pubKey)
+	assert.False(t, srv.s3Authorizer.Groups.IsMember("production", peerID))
+
+	rec = doAdminRequest(t, srv, http.MethodPut, "/api/tags", proto.PeerTags{Peer: "web", Tags: []string{"tag:prod", "tag:eu", "tag:prod"}})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	srv.peersMu.RLock()
+	assert.Equal(t, []string{"tag:eu", "tag:prod"}, srv.peers["web"].peer.Tags)
+	srv.peersMu.RUnlock()
+	assert.True(t, srv.s3Authorizer.Groups.IsMember("production", peerID), "member through tag:prod")
+
+	// Peers not connected yet can be tagged too
+	rec = doAdminRequest(t, srv, http.MethodPut, "/api/tags", proto.PeerTags{Peer: "db", Tags: []string{"tag:prod"}})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = doAdminRequest(t, srv, http.MethodGet, "/api/tags", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var tags []proto.PeerTags
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&tags))
+	assert.Equal(t, []proto.PeerTags{
+		{Peer: "db", Tags: []string{"tag:prod"}},
+		{Peer: "web", Tags: []string{"tag:eu", "tag:prod"}},
+	}, tags)
+
+	stored, err := srv.s3SystemStore.LoadPeerTags(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tag:eu", "tag:prod"}, stored["web"])
+
+	// Tags survive re-registration
+	rec = registerWithToken(t, srv, "test-token", "web", pubKey)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	srv.peersMu.RLock()
+	assert.Equal(t, []string{"tag:eu", "tag:prod"}, srv.peers["web"].peer.Tags)
+	srv.peersMu.RUnlock()
+
+	// Clearing the tags ends the membership
+	rec = doAdminRequest(t, srv, http.MethodPut, "/api/tags", proto.PeerTags{Peer: "web"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.False(t, srv.s3Authorizer.Groups.IsMember("production", peerID))
+}
+
+func TestTags_Invalid(t *testing.T) {
+	srv := newTestServerWithS3(t)
+	makeTestAdmin(srv)
+
+	rec := doAdminRequest(t, srv, http.MethodPut, "/api/tags", proto.PeerTags{Peer: "web", Tags: []string{"prod"}})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = doAdminRequest(t, srv, http.MethodPut, "/api/tags", proto.PeerTags{Tags: []string{"tag:prod"}})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = doAdminRequest(t, srv, http.MethodDelete, "/api/tags", nil)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestTags_JoinKeyTagsKept(t *testing.T) {
+	srv := newTestServerWithS3(t)
+	makeTestAdmin(srv)
+	key := createJoinKey(t, srv, proto.JoinKeyRequest{Tags: []string{"tag:ci"}})
+
+	pubKey, _ := generateTestSSHPubKey(t)
+	rec := registerWithToken(t, srv, key.Key, "builder", pubKey)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	// Rejoining with the mesh token keeps the key's tags
+	rec = registerWithToken(t, srv, "test-token", "builder", pubKey)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	srv.peersMu.RLock()
+	assert.Equal(t, []string{"tag:ci"}, srv.peers["builder"].peer.Tags)
+	srv.peersMu.RUnlock()
+
+	// An admin can take them away again
+	rec = doAdminRequest(t, srv, http.MethodPut, "/api/tags", proto.PeerTags{Peer: "builder"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = registerWithToken(t, srv, key.Key, "builder", pubKey)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	srv.peersMu.RLock()
+	assert.Empty(t, srv.peers["builder"].peer.Tags)
+	srv.peersMu.RUnlock()
+}
+
+func TestTags_DNSRecords(t *testing.T) {
+	srv := newTestServerWithS3(t)
+	makeTestAdmin(srv)
+
+	pubKey, _ := generateTestSSHPubKey(t)
+	rec := registerWithToken(t, srv, "test-token", "web", pubKey)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = doAdminRequest(t, srv, http.MethodPut, "/api/tags", proto.PeerTags{Peer: "web", Tags: []string{"tag:prod"}})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	// A WireGuard concentrator reports its clients' tags in heartbeats
+	srv.peersMu.Lock()
+	srv.peers["web"].peer.ClientTags = map[string][]string{"10.42.100.2": {"tag:laptop"}}
+	srv.dnsCache["alice-phone"] = "10.42.100.2"
+	srv.peersMu.Unlock()
+
+	rec = doAdminRequest(t, srv, http.MethodGet, "/api/dns", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var update proto.DNSUpdateNotification
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&update))
+	tags := make(map[string][]string)
+	for _, record := range update.Records {
+		tags[record.Hostname] = record.Tags
+	}
+	assert.Equal(t, []string{"tag:prod"}, tags["web"])
+	assert.Equal(t, []string{"tag:laptop"}, tags["alice-phone"])
+}
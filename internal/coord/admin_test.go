@@ -46,7 +46,7 @@ func TestAdminOverview_IncludesLocation(t *testing.T) {
 		City:      "London",
 		Country:   "United Kingdom",
 	}
-	_, err = client.Register("geonode", "SHA256:abc123", []string{"1.2.3.4"}, nil, 2222, 0, false, "v1.0.0", location, "", false, nil, false, false)
+	_, err = client.Register(proto.RegisterRequest{Name: "geonode", PublicKey: "SHA256:abc123", PublicIPs: []string{"1.2.3.4"}, SSHPort: 2222, Version: "v1.0.0", Location: location})
 	require.NoError(t, err)
 
 	// Fetch admin overview from adminMux (internal mesh only)
@@ -84,11 +84,11 @@ func TestAdminOverview_ExitPeerInfo(t *testing.T) {
 
 	// Register an exit node
 	client := NewClient(ts.URL, "test-token")
-	_, err = client.Register("exit-node", "SHA256:exitkey", []string{"1.2.3.4"}, nil, 2222, 0, false, "v1.0.0", nil, "", true, nil, false, false)
+	_, err = client.Register(proto.RegisterRequest{Name: "exit-node", PublicKey: "SHA256:exitkey", PublicIPs: []string{"1.2.3.4"}, SSHPort: 2222, Version: "v1.0.0", AllowsExitTraffic: true})
 	require.NoError(t, err)
 
 	// Register a client that uses the exit node
-	_, err = client.Register("client1", "SHA256:client1key", []string{"5.6.7.8"}, nil, 2223, 0, false, "v1.0.0", nil, "exit-node", false, nil, false, false)
+	_, err = client.Register(proto.RegisterRequest{Name: "client1", PublicKey: "SHA256:client1key", PublicIPs: []string{"5.6.7.8"}, SSHPort: 2223, Version: "v1.0.0", ExitPeer: "exit-node"})
 	require.NoError(t, err)
 
 	// Fetch admin overview from adminMux (internal mesh only)
@@ -141,7 +141,7 @@ func TestAdminOverview_ConnectionTypes(t *testing.T) {
 
 	// Register a peer
 	client := NewClient(ts.URL, "test-token")
-	_, err = client.Register("peer1", "SHA256:peer1key", []string{"1.2.3.4"}, nil, 2222, 0, false, "v1.0.0", nil, "", false, nil, false, false)
+	_, err = client.Register(proto.RegisterRequest{Name: "peer1", PublicKey: "SHA256:peer1key", PublicIPs: []string{"1.2.3.4"}, SSHPort: 2222, Version: "v1.0.0"})
 	require.NoError(t, err)
 
 	// Directly set stats on the server (simulating heartbeat)
@@ -2198,6 +2198,56 @@ func TestS3RestoreVersion_ForwardsToPrimary(t *testing.T) {
 	assert.Contains(t, string(receivedBody), "v1")
 }
 
+func TestS3PresignObject(t *testing.T) {
+	srv := newTestServerWithS3AndBucket(t)
+
+	srv.peersMu.Lock()
+	srv.peers["alice"] = &peerInfo{
+		peer:   &proto.Peer{Name: "alice", MeshIP: "10.42.0.100"},
+		peerID: "alice-id",
+	}
+	srv.peersMu.Unlock()
+	_, _, err := srv.s3Credentials.RegisterUser("alice-id", "alice-public-key")
+	require.NoError(t, err)
+	srv.s3Authorizer.Bindings.Add(auth.NewRoleBinding("alice-id", auth.RoleBucketRead, "test-bucket"))
+
+	presign := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/s3/buckets/test-bucket/objects/docs%2Freport.pdf/presign",
+			strings.NewReader(body))
+		req.Host = "10.42.0.1:443"
+		req.RemoteAddr = "10.42.0.100:12345"
+		rec := httptest.NewRecorder()
+		srv.adminMux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// URLs point at the S3 listener, so none can be minted before it starts
+	rec := presign(`{"method":"GET","expires_in":600}`)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	srv.s3Port.Store(9443)
+
+	rec = presign(`{"method":"GET","expires_in":600}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp PresignResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, http.MethodGet, resp.Method)
+	assert.True(t, strings.HasPrefix(resp.URL, "https://10.42.0.1:9443/test-bucket/docs/report.pdf?"), resp.URL)
+
+	// The URL authenticates as the caller against the S3 API
+	rbac := s3.NewRBACAuthorizer(srv.s3Credentials, srv.s3Authorizer)
+	userID, err := rbac.AuthorizeRequest(httptest.NewRequest(http.MethodGet, resp.URL, nil), "get", "objects", "test-bucket", "docs/report.pdf")
+	require.NoError(t, err)
+	assert.Equal(t, "alice-id", userID)
+
+	// Read-only callers cannot mint upload URLs
+	rec = presign(`{"method":"PUT"}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = presign(`{"expires_in":999999999}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
 func TestS3RestoreVersion_NoForwardWhenAlreadyForwarded(t *testing.T) {
 	srv := newTestServerWithS3AndBucket(t)
 	srv.storeCoordIPs([]string{"10.0.0.1", "10.0.0.2"})
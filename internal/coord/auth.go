@@ -10,6 +10,7 @@ import (
 // JWTClaims contains the custom claims for relay authentication.
 type JWTClaims struct {
 	PeerName string `json:"peer_name"`
+	PeerID   string `json:"peer_id,omitempty"`
 	MeshIP   string `json:"mesh_ip"`
 	jwt.RegisteredClaims
 }
@@ -18,9 +19,11 @@ type JWTClaims struct {
 const TokenExpiry = 24 * time.Hour
 
 // GenerateToken creates a JWT token for relay authentication.
-func (s *Server) GenerateToken(peerName, meshIP string) (string, error) {
+// The peer ID is used to reject tokens of revoked peers.
+func (s *Server) GenerateToken(peerName, peerID, meshIP string) (string, error) {
 	claims := JWTClaims{
 		PeerName: peerName,
+		PeerID:   peerID,
 		MeshIP:   meshIP,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(TokenExpiry)),
@@ -49,6 +52,9 @@ func (s *Server) ValidateToken(tokenString string) (*JWTClaims, error) {
 	if !ok || !token.Valid {
 		return nil, fmt.Errorf("invalid token claims")
 	}
+	if s.isPeerRevoked(claims.PeerID) {
+		return nil, fmt.Errorf("peer %s has been revoked", claims.PeerName)
+	}
 
 	return claims, nil
 }
@@ -1,41 +1,96 @@
 package coord
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/pem"
 	"fmt"
+	"maps"
 	"math/big"
 	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/tunnelmesh/tunnelmesh/internal/mesh"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
+)
+
+// DefaultCertLifetime is the lifetime of peer certificates unless configured
+// otherwise: one year, as before certificates were renewed, so peers that
+// can't renew keep working. Peers that can renew their certificate over the
+// persistent relay once two thirds of it have passed, so a short lifetime
+// such as 24h can be configured once they all do.
+const DefaultCertLifetime = 365 * 24 * time.Hour
+
+// crlValidity is how long a published CRL stays valid.
+const crlValidity = time.Hour
+
+// CA files in the data directory. A staged root is written next to the
+// signing root until it is promoted; the root it replaces is kept as the
+// retired root, with the end of its transition window in a PEM header.
+const (
+	caCertFile         = "ca.crt"
+	caKeyFile          = "ca.key"
+	caNextCertFile     = "ca-next.crt"
+	caNextKeyFile      = "ca-next.key"
+	caPrevCertFile     = "ca-prev.crt"
+	serviceCertFile    = "service-ca.crt"
+	serviceKeyFile     = "service-ca.key"
+	trustedUntilHeader = "Trusted-Until"
 )
 
 // CertificateAuthority manages TLS certificates for the mesh network.
+// It tracks the certificates it issues until they expire so they can be
+// revoked, and rolls its root over in stages: a new root is first staged
+// (trusted, not signing), then promoted, after which the old root stays
+// trusted for a transition window.
+//
+// Peer certificates are signed by the root itself. Service certificates are
+// signed by an intermediate limited to server authentication, so they can't
+// pass for a peer's identity on mesh transports, which only accept leaf
+// certificates signed directly by a root.
 type CertificateAuthority struct {
 	dataDir string
-	caCert  *x509.Certificate
-	caKey   *ecdsa.PrivateKey
+
+	mu           sync.RWMutex
+	caCert       *x509.Certificate
+	caKey        *ecdsa.PrivateKey
+	nextCert     *x509.Certificate // Staged root, nil if none
+	nextKey      *ecdsa.PrivateKey
+	prevCert     *x509.Certificate // Retired root, nil if none
+	prevUntil    time.Time         // End of the retired root's transition window
+	svcCert      *x509.Certificate // Service intermediate, nil until first used
+	svcKey       *ecdsa.PrivateKey
+	certLifetime time.Duration
+	certs        map[string]proto.IssuedCert // Unexpired certificates, keyed by hex serial
 }
 
 // NewCertificateAuthority creates or loads a CA from the given data directory.
 // The domainSuffix parameter is ignored - the canonical domain suffix is always used.
 func NewCertificateAuthority(dataDir, _ string) (*CertificateAuthority, error) {
 	ca := &CertificateAuthority{
-		dataDir: dataDir,
+		dataDir:      dataDir,
+		certLifetime: DefaultCertLifetime,
+		certs:        make(map[string]proto.IssuedCert),
 	}
 
-	certPath := filepath.Join(dataDir, "ca.crt")
-	keyPath := filepath.Join(dataDir, "ca.key")
+	certPath := ca.path(caCertFile)
+	keyPath := ca.path(caKeyFile)
 
 	// Check if CA already exists
 	if _, err := os.Stat(certPath); err == nil {
@@ -44,6 +99,16 @@ func NewCertificateAuthority(dataDir, _ string) (*CertificateAuthority, error) {
 			return nil, fmt.Errorf("load CA: %w", err)
 		}
 		log.Info().Str("path", certPath).Msg("loaded existing CA certificate")
+		if err := ca.loadRollover(); err != nil {
+			return nil, fmt.Errorf("load CA rollover: %w", err)
+		}
+		if _, err := os.Stat(ca.path(serviceCertFile)); err == nil {
+			cert, key, err := loadKeyPair(ca.path(serviceCertFile), ca.path(serviceKeyFile))
+			if err != nil {
+				return nil, fmt.Errorf("load service CA: %w", err)
+			}
+			ca.svcCert, ca.svcKey = cert, key
+		}
 	} else {
 		// Generate new CA
 		if err := ca.generate(); err != nil {
@@ -58,18 +123,34 @@ func NewCertificateAuthority(dataDir, _ string) (*CertificateAuthority, error) {
 	return ca, nil
 }
 
+// path returns the path of a CA file in the data directory.
+func (ca *CertificateAuthority) path(name string) string {
+	return filepath.Join(ca.dataDir, name)
+}
+
 // generate creates a new CA certificate and private key.
 func (ca *CertificateAuthority) generate() error {
+	cert, key, err := generateRoot()
+	if err != nil {
+		return err
+	}
+	ca.caCert = cert
+	ca.caKey = key
+	return nil
+}
+
+// generateRoot creates a self-signed root certificate and its private key.
+func generateRoot() (*x509.Certificate, *ecdsa.PrivateKey, error) {
 	// Generate ECDSA P-256 private key
 	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
-		return fmt.Errorf("generate key: %w", err)
+		return nil, nil, fmt.Errorf("generate key: %w", err)
 	}
 
 	// Generate serial number
 	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
 	if err != nil {
-		return fmt.Errorf("generate serial: %w", err)
+		return nil, nil, fmt.Errorf("generate serial: %w", err)
 	}
 
 	// Create CA certificate template
@@ -91,76 +172,123 @@ func (ca *CertificateAuthority) generate() error {
 	// Self-sign the CA certificate
 	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
 	if err != nil {
-		return fmt.Errorf("create certificate: %w", err)
+		return nil, nil, fmt.Errorf("create certificate: %w", err)
 	}
 
 	cert, err := x509.ParseCertificate(certDER)
 	if err != nil {
-		return fmt.Errorf("parse certificate: %w", err)
+		return nil, nil, fmt.Errorf("parse certificate: %w", err)
 	}
 
+	return cert, key, nil
+}
+
+// load reads the CA certificate and key from disk.
+func (ca *CertificateAuthority) load(certPath, keyPath string) error {
+	cert, key, err := loadKeyPair(certPath, keyPath)
+	if err != nil {
+		return err
+	}
 	ca.caCert = cert
 	ca.caKey = key
 	return nil
 }
 
-// load reads the CA certificate and key from disk.
-func (ca *CertificateAuthority) load(certPath, keyPath string) error {
+// loadRollover reads the staged and retired roots of a rollover in progress.
+// A retired root whose transition window has ended is removed.
+func (ca *CertificateAuthority) loadRollover() error {
+	if _, err := os.Stat(ca.path(caNextCertFile)); err == nil {
+		cert, key, err := loadKeyPair(ca.path(caNextCertFile), ca.path(caNextKeyFile))
+		if err != nil {
+			return fmt.Errorf("staged root: %w", err)
+		}
+		ca.nextCert, ca.nextKey = cert, key
+		log.Info().Str("path", ca.path(caNextCertFile)).Msg("loaded staged CA certificate")
+	}
+
+	prevPEM, err := os.ReadFile(ca.path(caPrevCertFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read retired root: %w", err)
+	}
+	block, _ := pem.Decode(prevPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return fmt.Errorf("invalid retired root PEM")
+	}
+	until, err := time.Parse(time.RFC3339, block.Headers[trustedUntilHeader])
+	if err != nil {
+		return fmt.Errorf("retired root: invalid %s header: %w", trustedUntilHeader, err)
+	}
+	if !time.Now().Before(until) {
+		_ = os.Remove(ca.path(caPrevCertFile))
+		return nil
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("parse retired root: %w", err)
+	}
+	ca.prevCert, ca.prevUntil = cert, until
+	return nil
+}
+
+// loadKeyPair reads a certificate and its EC private key from disk.
+func loadKeyPair(certPath, keyPath string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
 	// Load certificate
 	certPEM, err := os.ReadFile(certPath)
 	if err != nil {
-		return fmt.Errorf("read cert: %w", err)
+		return nil, nil, fmt.Errorf("read cert: %w", err)
 	}
 
 	block, _ := pem.Decode(certPEM)
 	if block == nil || block.Type != "CERTIFICATE" {
-		return fmt.Errorf("invalid certificate PEM")
+		return nil, nil, fmt.Errorf("invalid certificate PEM")
 	}
 
 	cert, err := x509.ParseCertificate(block.Bytes)
 	if err != nil {
-		return fmt.Errorf("parse cert: %w", err)
+		return nil, nil, fmt.Errorf("parse cert: %w", err)
 	}
 
 	// Load private key
 	keyPEM, err := os.ReadFile(keyPath)
 	if err != nil {
-		return fmt.Errorf("read key: %w", err)
+		return nil, nil, fmt.Errorf("read key: %w", err)
 	}
 
 	block, _ = pem.Decode(keyPEM)
 	if block == nil || block.Type != "EC PRIVATE KEY" {
-		return fmt.Errorf("invalid private key PEM")
+		return nil, nil, fmt.Errorf("invalid private key PEM")
 	}
 
 	key, err := x509.ParseECPrivateKey(block.Bytes)
 	if err != nil {
-		return fmt.Errorf("parse key: %w", err)
+		return nil, nil, fmt.Errorf("parse key: %w", err)
 	}
 
-	ca.caCert = cert
-	ca.caKey = key
-	return nil
+	return cert, key, nil
 }
 
 // save writes the CA certificate and key to disk.
 func (ca *CertificateAuthority) save(certPath, keyPath string) error {
+	return saveKeyPair(ca.caCert, ca.caKey, certPath, keyPath)
+}
+
+// saveKeyPair writes a certificate and its EC private key to disk.
+func saveKeyPair(cert *x509.Certificate, key *ecdsa.PrivateKey, certPath, keyPath string) error {
 	// Ensure directory exists
 	if err := os.MkdirAll(filepath.Dir(certPath), 0755); err != nil {
 		return fmt.Errorf("create dir: %w", err)
 	}
 
 	// Save certificate
-	certPEM := pem.EncodeToMemory(&pem.Block{
-		Type:  "CERTIFICATE",
-		Bytes: ca.caCert.Raw,
-	})
-	if err := os.WriteFile(certPath, certPEM, 0644); err != nil {
+	if err := os.WriteFile(certPath, encodeCertPEM(cert), 0644); err != nil {
 		return fmt.Errorf("write cert: %w", err)
 	}
 
 	// Save private key (restricted permissions)
-	keyDER, err := x509.MarshalECPrivateKey(ca.caKey)
+	keyDER, err := x509.MarshalECPrivateKey(key)
 	if err != nil {
 		return fmt.Errorf("marshal key: %w", err)
 	}
@@ -175,15 +303,39 @@ func (ca *CertificateAuthority) save(certPath, keyPath string) error {
 	return nil
 }
 
+// encodeCertPEM returns a certificate in PEM format.
+func encodeCertPEM(cert *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: cert.Raw,
+	})
+}
+
 // Name returns the CA certificate's Common Name.
 // This is used by clients to identify which CA to trust/remove.
 func (ca *CertificateAuthority) Name() string {
+	ca.mu.RLock()
+	defer ca.mu.RUnlock()
 	if ca.caCert != nil {
 		return ca.caCert.Subject.CommonName
 	}
 	return ""
 }
 
+// SetCertLifetime sets the lifetime of the peer certificates issued from now on.
+func (ca *CertificateAuthority) SetCertLifetime(lifetime time.Duration) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	ca.certLifetime = lifetime
+}
+
+// CertLifetime returns the lifetime of issued peer certificates.
+func (ca *CertificateAuthority) CertLifetime() time.Duration {
+	ca.mu.RLock()
+	defer ca.mu.RUnlock()
+	return ca.certLifetime
+}
+
 // GeneratePeerCert creates a new certificate for a peer, signed by the CA.
 // Returns PEM-encoded certificate and private key.
 // The domainSuffix parameter is ignored - all supported suffixes are included in SAN.
@@ -194,12 +346,40 @@ func (ca *CertificateAuthority) GeneratePeerCert(peerName, _ string, meshIP stri
 		return nil, nil, fmt.Errorf("generate key: %w", err)
 	}
 
-	// Generate serial number
-	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	certPEM, err = ca.issue(peerName, meshIP, &key.PublicKey)
 	if err != nil {
-		return nil, nil, fmt.Errorf("generate serial: %w", err)
+		return nil, nil, err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("marshal key: %w", err)
 	}
+	keyPEM = pem.EncodeToMemory(&pem.Block{
+		Type:  "EC PRIVATE KEY",
+		Bytes: keyDER,
+	})
+
+	return certPEM, keyPEM, nil
+}
 
+// SignPeerCSR issues a certificate for a peer renewing its certificate. Only
+// the public key is taken from the DER-encoded request; the names come from
+// the peer's registration. Returns the PEM-encoded certificate.
+func (ca *CertificateAuthority) SignPeerCSR(peerName, meshIP string, csrDER []byte) ([]byte, error) {
+	csr, err := x509.ParseCertificateRequest(csrDER)
+	if err != nil {
+		return nil, fmt.Errorf("parse CSR: %w", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("CSR signature: %w", err)
+	}
+	return ca.issue(peerName, meshIP, csr.PublicKey)
+}
+
+// issue signs a peer certificate for the given public key with the signing
+// root and records it. Returns the PEM-encoded certificate.
+func (ca *CertificateAuthority) issue(peerName, meshIP string, pub any) ([]byte, error) {
 	// Build DNS names for SAN - include all supported suffixes
 	var dnsNames []string
 	for _, suffix := range mesh.AllSuffixes() {
@@ -213,65 +393,396 @@ func (ca *CertificateAuthority) GeneratePeerCert(peerName, _ string, meshIP stri
 		ipAddresses = append(ipAddresses, ip)
 	}
 
-	// Create certificate template
+	return ca.sign(peerName, &x509.Certificate{
+		Subject: pkix.Name{
+			Organization: []string{"TunnelMesh"},
+			CommonName:   peerName + mesh.DomainSuffix, // Use canonical domain suffix
+		},
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:    dnsNames,
+		IPAddresses: ipAddresses,
+	}, pub)
+}
+
+// IssueServiceCert issues a server certificate for a service running on a
+// peer, for names the caller has checked the peer owns. The certificate is
+// signed by the service intermediate and tracked under the peer's name, so
+// revoking the peer revokes it too. Returns the PEM-encoded certificate
+// followed by the intermediate.
+func (ca *CertificateAuthority) IssueServiceCert(peerName string, dnsNames []string, ips []net.IP, pub any) ([]byte, error) {
+	commonName := peerName + mesh.DomainSuffix
+	if len(dnsNames) > 0 {
+		commonName = dnsNames[0]
+	}
+	return ca.sign(peerName, &x509.Certificate{
+		Subject: pkix.Name{
+			Organization: []string{"TunnelMesh"},
+			CommonName:   commonName,
+		},
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:    dnsNames,
+		IPAddresses: ips,
+	}, pub)
+}
+
+// serviceCA returns the intermediate that signs service certificates,
+// creating it when there is none or the signing root has changed since it
+// was created. Must be called with mu held.
+func (ca *CertificateAuthority) serviceCA() (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	if ca.svcCert != nil && ca.svcCert.CheckSignatureFrom(ca.caCert) == nil &&
+		time.Now().Add(ca.certLifetime).Before(ca.svcCert.NotAfter) {
+		return ca.svcCert, ca.svcKey, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generate key: %w", err)
+	}
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, fmt.Errorf("generate serial: %w", err)
+	}
 	template := &x509.Certificate{
 		SerialNumber: serialNumber,
 		Subject: pkix.Name{
 			Organization: []string{"TunnelMesh"},
-			CommonName:   peerName + mesh.DomainSuffix, // Use canonical domain suffix
+			CommonName:   fmt.Sprintf("TunnelMesh Service CA (%s)", mesh.DomainSuffix),
 		},
 		NotBefore:             time.Now(),
-		NotAfter:              time.Now().AddDate(1, 0, 0), // 1 year
-		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		NotAfter:              ca.caCert.NotAfter,
+		KeyUsage:              x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
 		BasicConstraintsValid: true,
-		DNSNames:              dnsNames,
-		IPAddresses:           ipAddresses,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
 	}
-
-	// Sign with CA
 	certDER, err := x509.CreateCertificate(rand.Reader, template, ca.caCert, &key.PublicKey, ca.caKey)
 	if err != nil {
 		return nil, nil, fmt.Errorf("create certificate: %w", err)
 	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse certificate: %w", err)
+	}
+	if err := saveKeyPair(cert, key, ca.path(serviceCertFile), ca.path(serviceKeyFile)); err != nil {
+		return nil, nil, fmt.Errorf("save service CA: %w", err)
+	}
+	ca.svcCert, ca.svcKey = cert, key
+	log.Info().Str("fingerprint", certFingerprint(cert)).Msg("generated service CA certificate")
+	return cert, key, nil
+}
+
+// sign completes the template with a serial number and validity period,
+// signs it and records the certificate under the peer's name. Peer
+// certificates are signed with the signing root, server-only certificates
+// with the service intermediate. Returns the PEM-encoded certificate,
+// followed by the intermediate if any.
+func (ca *CertificateAuthority) sign(peerName string, template *x509.Certificate, pub any) ([]byte, error) {
+	// Generate serial number
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("generate serial: %w", err)
+	}
+
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	now := time.Now()
+	notAfter := now.Add(ca.certLifetime)
+	template.SerialNumber = serialNumber
+	template.NotBefore = now
+	template.NotAfter = notAfter
+	template.BasicConstraintsValid = true
+
+	// Sign with CA, or with the service intermediate for certificates that
+	// can't authenticate peers
+	issuer, issuerKey := ca.caCert, ca.caKey
+	var chain []byte
+	if !slices.Contains(template.ExtKeyUsage, x509.ExtKeyUsageClientAuth) {
+		if issuer, issuerKey, err = ca.serviceCA(); err != nil {
+			return nil, err
+		}
+		chain = encodeCertPEM(issuer)
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, issuer, pub, issuerKey)
+	if err != nil {
+		return nil, fmt.Errorf("create certificate: %w", err)
+	}
+
+	// Track the certificate until it expires, dropping expired ones
+	for serial, c := range ca.certs {
+		if !now.Before(c.NotAfter) {
+			delete(ca.certs, serial)
+		}
+	}
+	serial := serialNumber.Text(16)
+	ca.certs[serial] = proto.IssuedCert{Serial: serial, Peer: peerName, NotAfter: notAfter}
+
+	log.Debug().
+		Str("peer", peerName).
+		Strs("dns_names", template.DNSNames).
+		Str("serial", serial).
+		Time("not_after", notAfter).
+		Msg("generated peer certificate")
 
 	// Encode to PEM
-	certPEM = pem.EncodeToMemory(&pem.Block{
+	return append(pem.EncodeToMemory(&pem.Block{
 		Type:  "CERTIFICATE",
 		Bytes: certDER,
-	})
+	}), chain...), nil
+}
 
-	keyDER, err := x509.MarshalECPrivateKey(key)
+// IssuedCerts returns the unexpired certificates the CA issued, keyed by hex
+// serial number, for persistence.
+func (ca *CertificateAuthority) IssuedCerts() map[string]proto.IssuedCert {
+	ca.mu.RLock()
+	defer ca.mu.RUnlock()
+	certs := make(map[string]proto.IssuedCert, len(ca.certs))
+	maps.Copy(certs, ca.certs)
+	return certs
+}
+
+// RestoreIssuedCerts restores the certificates loaded from persistence,
+// skipping expired ones.
+func (ca *CertificateAuthority) RestoreIssuedCerts(certs map[string]proto.IssuedCert) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	now := time.Now()
+	for serial, c := range certs {
+		if now.Before(c.NotAfter) {
+			ca.certs[serial] = c
+		}
+	}
+}
+
+// RevokeCert revokes the certificate with the given hex serial number.
+// Returns false if the CA has no unexpired certificate with that serial.
+func (ca *CertificateAuthority) RevokeCert(serial string) (proto.IssuedCert, bool) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	serial = strings.TrimLeft(strings.ToLower(strings.ReplaceAll(serial, ":", "")), "0")
+	c, ok := ca.certs[serial]
+	if !ok || !time.Now().Before(c.NotAfter) {
+		return proto.IssuedCert{}, false
+	}
+	if c.RevokedAt.IsZero() {
+		c.RevokedAt = time.Now()
+		ca.certs[serial] = c
+	}
+	return c, true
+}
+
+// RevokePeerCerts revokes every unexpired certificate issued to a peer and
+// returns how many were newly revoked.
+func (ca *CertificateAuthority) RevokePeerCerts(peerName string) int {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	now := time.Now()
+	revoked := 0
+	for serial, c := range ca.certs {
+		if c.Peer == peerName && c.RevokedAt.IsZero() && now.Before(c.NotAfter) {
+			c.RevokedAt = now
+			ca.certs[serial] = c
+			revoked++
+		}
+	}
+	return revoked
+}
+
+// IsRevoked reports whether the certificate with the given serial number
+// was revoked.
+func (ca *CertificateAuthority) IsRevoked(serial *big.Int) bool {
+	ca.mu.RLock()
+	defer ca.mu.RUnlock()
+	c, ok := ca.certs[serial.Text(16)]
+	return ok && !c.RevokedAt.IsZero()
+}
+
+// CRL returns a DER-encoded certificate revocation list of the revoked,
+// unexpired certificates, signed by the signing root. It covers certificates
+// of every trusted root, since serial numbers are random.
+func (ca *CertificateAuthority) CRL() ([]byte, error) {
+	ca.mu.RLock()
+	defer ca.mu.RUnlock()
+
+	now := time.Now()
+	var entries []x509.RevocationListEntry
+	for serial, c := range ca.certs {
+		if c.RevokedAt.IsZero() || !now.Before(c.NotAfter) {
+			continue
+		}
+		n, ok := new(big.Int).SetString(serial, 16)
+		if !ok {
+			continue
+		}
+		entries = append(entries, x509.RevocationListEntry{SerialNumber: n, RevocationTime: c.RevokedAt})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].SerialNumber.Cmp(entries[j].SerialNumber) < 0 })
+
+	template := &x509.RevocationList{
+		Number:                    big.NewInt(now.UnixNano()),
+		ThisUpdate:                now,
+		NextUpdate:                now.Add(crlValidity),
+		RevokedCertificateEntries: entries,
+	}
+	crl, err := x509.CreateRevocationList(rand.Reader, template, ca.caCert, ca.caKey)
 	if err != nil {
-		return nil, nil, fmt.Errorf("marshal key: %w", err)
+		return nil, fmt.Errorf("create CRL: %w", err)
 	}
-	keyPEM = pem.EncodeToMemory(&pem.Block{
-		Type:  "EC PRIVATE KEY",
-		Bytes: keyDER,
+	return crl, nil
+}
+
+// Stage generates a new root and adds it to the trust bundle without signing
+// with it yet, so peers can pick it up before it is promoted.
+func (ca *CertificateAuthority) Stage() error {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	if ca.nextCert != nil {
+		return fmt.Errorf("a new root is already staged")
+	}
+
+	cert, key, err := generateRoot()
+	if err != nil {
+		return err
+	}
+	if err := saveKeyPair(cert, key, ca.path(caNextCertFile), ca.path(caNextKeyFile)); err != nil {
+		return fmt.Errorf("save staged root: %w", err)
+	}
+	ca.nextCert, ca.nextKey = cert, key
+	log.Info().Str("fingerprint", certFingerprint(cert)).Msg("staged new CA root")
+	return nil
+}
+
+// Promote makes the staged root the signing root. The previous signing root
+// is retired: it stays in the trust bundle for the given transition window,
+// until the certificates it signed have been renewed or have expired.
+func (ca *CertificateAuthority) Promote(window time.Duration) error {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	if ca.nextCert == nil {
+		return fmt.Errorf("no root staged")
+	}
+	now := time.Now()
+	if ca.prevCert != nil && now.Before(ca.prevUntil) {
+		return fmt.Errorf("the previous root is trusted until %s", ca.prevUntil.Format(time.RFC3339))
+	}
+
+	until := now.Add(window)
+	prevPEM := pem.EncodeToMemory(&pem.Block{
+		Type:    "CERTIFICATE",
+		Headers: map[string]string{trustedUntilHeader: until.UTC().Format(time.RFC3339)},
+		Bytes:   ca.caCert.Raw,
 	})
+	if err := os.WriteFile(ca.path(caPrevCertFile), prevPEM, 0644); err != nil {
+		return fmt.Errorf("write retired root: %w", err)
+	}
+	if err := saveKeyPair(ca.nextCert, ca.nextKey, ca.path(caCertFile), ca.path(caKeyFile)); err != nil {
+		return fmt.Errorf("save signing root: %w", err)
+	}
+	_ = os.Remove(ca.path(caNextCertFile))
+	_ = os.Remove(ca.path(caNextKeyFile))
+
+	ca.prevCert, ca.prevUntil = ca.caCert, until
+	ca.caCert, ca.caKey = ca.nextCert, ca.nextKey
+	ca.nextCert, ca.nextKey = nil, nil
+	log.Info().
+		Str("fingerprint", certFingerprint(ca.caCert)).
+		Time("retired_until", until).
+		Msg("promoted staged CA root")
+	return nil
+}
 
-	log.Debug().
-		Str("peer", peerName).
-		Strs("dns_names", dnsNames).
-		Str("mesh_ip", meshIP).
-		Msg("generated peer certificate")
+// roots returns the trusted roots, signing root first, then the staged root
+// and the retired root if still in its transition window.
+func (ca *CertificateAuthority) roots() []*x509.Certificate {
+	roots := []*x509.Certificate{ca.caCert}
+	if ca.nextCert != nil {
+		roots = append(roots, ca.nextCert)
+	}
+	if ca.prevCert != nil && time.Now().Before(ca.prevUntil) {
+		roots = append(roots, ca.prevCert)
+	}
+	return roots
+}
 
-	return certPEM, keyPEM, nil
+// Status describes the trusted roots and the unexpired issued certificates.
+func (ca *CertificateAuthority) Status() proto.CAStatus {
+	ca.mu.RLock()
+	defer ca.mu.RUnlock()
+
+	root := func(cert *x509.Certificate, state string) proto.CARoot {
+		return proto.CARoot{
+			Name:        cert.Subject.CommonName,
+			Fingerprint: certFingerprint(cert),
+			State:       state,
+			NotAfter:    cert.NotAfter,
+		}
+	}
+	status := proto.CAStatus{
+		Roots:        []proto.CARoot{root(ca.caCert, proto.CARootSigning)},
+		CertLifetime: ca.certLifetime.String(),
+		Certs:        []proto.IssuedCert{},
+	}
+	if ca.nextCert != nil {
+		status.Roots = append(status.Roots, root(ca.nextCert, proto.CARootStaged))
+	}
+	now := time.Now()
+	if ca.prevCert != nil && now.Before(ca.prevUntil) {
+		retired := root(ca.prevCert, proto.CARootRetired)
+		retired.TrustedUntil = ca.prevUntil
+		status.Roots = append(status.Roots, retired)
+	}
+	for _, c := range ca.certs {
+		if now.Before(c.NotAfter) {
+			status.Certs = append(status.Certs, c)
+		}
+	}
+	sort.Slice(status.Certs, func(i, j int) bool {
+		if status.Certs[i].Peer != status.Certs[j].Peer {
+			return status.Certs[i].Peer < status.Certs[j].Peer
+		}
+		return status.Certs[i].NotAfter.Before(status.Certs[j].NotAfter)
+	})
+	return status
 }
 
-// CACertPEM returns the CA certificate in PEM format.
+// certFingerprint returns the hex-encoded SHA-256 of a certificate.
+func certFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// CACertPEM returns the signing CA certificate in PEM format.
 func (ca *CertificateAuthority) CACertPEM() []byte {
-	return pem.EncodeToMemory(&pem.Block{
-		Type:  "CERTIFICATE",
-		Bytes: ca.caCert.Raw,
-	})
+	ca.mu.RLock()
+	defer ca.mu.RUnlock()
+	return encodeCertPEM(ca.caCert)
+}
+
+// TrustBundlePEM returns the trusted roots in PEM format, signing root first.
+// Peers trust every root in the bundle, so certificates signed by a staged or
+// retired root verify during a rollover.
+func (ca *CertificateAuthority) TrustBundlePEM() []byte {
+	ca.mu.RLock()
+	defer ca.mu.RUnlock()
+	var bundle []byte
+	for _, root := range ca.roots() {
+		bundle = append(bundle, encodeCertPEM(root)...)
+	}
+	return bundle
 }
 
 // GetClientTLSConfig returns a TLS config for clients to verify server certificates.
-// This creates a cert pool with the CA certificate for verification.
+// This creates a cert pool with the trusted roots for verification.
 func (ca *CertificateAuthority) GetClientTLSConfig() *tls.Config {
+	ca.mu.RLock()
+	defer ca.mu.RUnlock()
 	certPool := x509.NewCertPool()
-	certPool.AddCert(ca.caCert)
+	for _, root := range ca.roots() {
+		certPool.AddCert(root)
+	}
 
 	return &tls.Config{
 		RootCAs:    certPool,
@@ -280,7 +791,7 @@ func (ca *CertificateAuthority) GetClientTLSConfig() *tls.Config {
 	}
 }
 
-// handleCACert serves the CA certificate for download.
+// handleCACert serves the CA trust bundle for download.
 func (s *Server) handleCACert(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		s.jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -295,5 +806,152 @@ func (s *Server) handleCACert(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/x-pem-file")
 	w.Header().Set("Content-Disposition", "attachment; filename=\"tunnelmesh-ca.crt\"")
 	w.Header().Set("X-TunnelMesh-CA-Name", s.ca.Name())
-	_, _ = w.Write(s.ca.CACertPEM())
+	_, _ = w.Write(s.ca.TrustBundlePEM())
+}
+
+// handleCACRL serves the CA's certificate revocation list (DER).
+func (s *Server) handleCACRL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.ca == nil {
+		s.jsonError(w, "CA not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	crl, err := s.ca.CRL()
+	if err != nil {
+		s.jsonError(w, "failed to create CRL", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pkix-crl")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"tunnelmesh-ca.crl\"")
+	_, _ = w.Write(crl)
+}
+
+// trustPayload encodes the trust bundle and CRL for the persistent relay.
+// Format: [bundle_len:2][bundle PEM][CRL DER]
+func (s *Server) trustPayload() ([]byte, error) {
+	bundle := s.ca.TrustBundlePEM()
+	crl, err := s.ca.CRL()
+	if err != nil {
+		return nil, err
+	}
+	payload := make([]byte, 2+len(bundle)+len(crl))
+	binary.BigEndian.PutUint16(payload, uint16(len(bundle)))
+	copy(payload[2:], bundle)
+	copy(payload[2+len(bundle):], crl)
+	return payload, nil
+}
+
+// pushTrustUpdate sends the trust bundle and CRL to a connected peer.
+func (s *Server) pushTrustUpdate(peerName string) {
+	if s.ca == nil || s.relay == nil {
+		return
+	}
+	payload, err := s.trustPayload()
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to build trust update")
+		return
+	}
+	s.relay.PushTrustUpdate(peerName, payload)
+}
+
+// broadcastTrustUpdate sends the trust bundle and CRL to all connected peers,
+// after a rollover step or a revocation.
+func (s *Server) broadcastTrustUpdate() {
+	if s.ca == nil || s.relay == nil {
+		return
+	}
+	payload, err := s.trustPayload()
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to build trust update")
+		return
+	}
+	s.relay.BroadcastTrustUpdate(payload)
+}
+
+// handleCertRenewal issues a new certificate for the key in a peer's
+// certificate signing request, received over its persistent relay, and
+// sends it back with the trust bundle and CRL.
+// Reply format: [MsgTypeCertIssued][cert_len:2][cert PEM][bundle_len:2][bundle PEM][CRL DER]
+func (s *Server) handleCertRenewal(peerName string, csrDER []byte) {
+	if s.ca == nil {
+		return
+	}
+	s.peersMu.RLock()
+	info, ok := s.peers[peerName]
+	var meshIP, peerID string
+	if ok {
+		meshIP, peerID = info.peer.MeshIP, info.peerID
+	}
+	s.peersMu.RUnlock()
+	if !ok {
+		log.Debug().Str("peer", peerName).Msg("certificate renewal from unregistered peer")
+		return
+	}
+	if s.isPeerRevoked(peerID) {
+		log.Warn().Str("peer", peerName).Msg("refused certificate renewal of revoked peer")
+		return
+	}
+
+	certPEM, err := s.ca.SignPeerCSR(peerName, meshIP, csrDER)
+	if err != nil {
+		log.Warn().Err(err).Str("peer", peerName).Msg("failed to renew peer certificate")
+		return
+	}
+	s.saveIssuedCerts(context.Background())
+
+	payload, err := s.trustPayload()
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to build trust update")
+		return
+	}
+	msg := make([]byte, 3+len(certPEM)+len(payload))
+	msg[0] = MsgTypeCertIssued
+	binary.BigEndian.PutUint16(msg[1:], uint16(len(certPEM)))
+	copy(msg[3:], certPEM)
+	copy(msg[3+len(certPEM):], payload)
+
+	pc, ok := s.relay.GetPersistent(peerName)
+	if !ok {
+		return
+	}
+	select {
+	case pc.writeChan <- msg:
+		log.Info().Str("peer", peerName).Msg("renewed peer certificate")
+	default:
+		log.Debug().Str("peer", peerName).Msg("failed to send renewed certificate: channel full")
+	}
+}
+
+// saveIssuedCerts persists the certificates issued by the CA.
+func (s *Server) saveIssuedCerts(ctx context.Context) {
+	if s.s3SystemStore == nil || s.ca == nil {
+		return
+	}
+	s.certsSaveMu.Lock()
+	defer s.certsSaveMu.Unlock()
+	if err := s.s3SystemStore.SaveIssuedCerts(ctx, s.ca.IssuedCerts()); err != nil {
+		log.Warn().Err(err).Msg("failed to persist issued certificates")
+	}
+}
+
+// SetTLSCertificate replaces the mesh TLS certificate served by the admin,
+// S3 and NFS servers, e.g. after the coordinator renewed its certificate.
+func (s *Server) SetTLSCertificate(cert *tls.Certificate) {
+	s.tlsCert.Store(cert)
+}
+
+// getTLSCertificate returns the current mesh TLS certificate.
+// Used as tls.Config.GetCertificate so renewed certificates are served
+// without restarting the servers.
+func (s *Server) getTLSCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cert := s.tlsCert.Load()
+	if cert == nil {
+		return nil, fmt.Errorf("no TLS certificate")
+	}
+	return cert, nil
 }
@@ -0,0 +1,245 @@
+package coord
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
+)
+
+func parseCertPEM(t *testing.T, certPEM []byte) *x509.Certificate {
+	t.Helper()
+	block, _ := pem.Decode(certPEM)
+	require.NotNil(t, block)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	return cert
+}
+
+func rootPool(bundlePEM []byte) *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(bundlePEM)
+	return pool
+}
+
+func TestCertificateAuthority_CertLifetime(t *testing.T) {
+	ca, err := NewCertificateAuthority(t.TempDir(), "")
+	require.NoError(t, err)
+	ca.SetCertLifetime(2 * time.Hour)
+
+	certPEM, _, err := ca.GeneratePeerCert("laptop", "", "10.42.0.5")
+	require.NoError(t, err)
+	cert := parseCertPEM(t, certPEM)
+	assert.WithinDuration(t, time.Now().Add(2*time.Hour), cert.NotAfter, time.Minute)
+
+	issued := ca.IssuedCerts()
+	require.Contains(t, issued, cert.SerialNumber.Text(16))
+	assert.Equal(t, "laptop", issued[cert.SerialNumber.Text(16)].Peer)
+}
+
+func TestCertificateAuthority_SignPeerCSR(t *testing.T) {
+	ca, err := NewCertificateAuthority(t.TempDir(), "")
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{}, key)
+	require.NoError(t, err)
+
+	certPEM, err := ca.SignPeerCSR("laptop", "10.42.0.5", csrDER)
+	require.NoError(t, err)
+	cert := parseCertPEM(t, certPEM)
+	assert.True(t, key.PublicKey.Equal(cert.PublicKey), "certificate is for the requested key")
+
+	_, err = cert.Verify(x509.VerifyOptions{DNSName: "laptop.tunnelmesh", Roots: rootPool(ca.TrustBundlePEM())})
+	assert.NoError(t, err)
+
+	_, err = ca.SignPeerCSR("laptop", "10.42.0.5", []byte("not a csr"))
+	assert.Error(t, err)
+}
+
+func TestCertificateAuthority_RevokeAndCRL(t *testing.T) {
+	ca, err := NewCertificateAuthority(t.TempDir(), "")
+	require.NoError(t, err)
+
+	laptopPEM, _, err := ca.GeneratePeerCert("laptop", "", "10.42.0.5")
+	require.NoError(t, err)
+	serverPEM, _, err := ca.GeneratePeerCert("server", "", "10.42.0.6")
+	require.NoError(t, err)
+	laptop, server := parseCertPEM(t, laptopPEM), parseCertPEM(t, serverPEM)
+
+	// Accepts colon-separated serials as printed by openssl
+	serial := laptop.SerialNumber.Text(16)
+	var colons string
+	for i := 0; i < len(serial); i += 2 {
+		if i > 0 {
+			colons += ":"
+		}
+		colons += serial[i:min(i+2, len(serial))]
+	}
+	revoked, ok := ca.RevokeCert(colons)
+	require.True(t, ok, "revoke %s", colons)
+	assert.Equal(t, "laptop", revoked.Peer)
+	assert.False(t, revoked.RevokedAt.IsZero())
+	assert.True(t, ca.IsRevoked(laptop.SerialNumber))
+	assert.False(t, ca.IsRevoked(server.SerialNumber))
+
+	_, ok = ca.RevokeCert("deadbeef")
+	assert.False(t, ok)
+
+	crlDER, err := ca.CRL()
+	require.NoError(t, err)
+	crl, err := x509.ParseRevocationList(crlDER)
+	require.NoError(t, err)
+	require.NoError(t, crl.CheckSignatureFrom(parseCertPEM(t, ca.CACertPEM())))
+	require.Len(t, crl.RevokedCertificateEntries, 1)
+	assert.Equal(t, 0, crl.RevokedCertificateEntries[0].SerialNumber.Cmp(laptop.SerialNumber))
+
+	assert.Equal(t, 1, ca.RevokePeerCerts("server"))
+	assert.Equal(t, 0, ca.RevokePeerCerts("server"), "already revoked")
+	assert.True(t, ca.IsRevoked(server.SerialNumber))
+}
+
+func TestCertificateAuthority_RestoreIssuedCerts(t *testing.T) {
+	ca, err := NewCertificateAuthority(t.TempDir(), "")
+	require.NoError(t, err)
+
+	now := time.Now()
+	ca.RestoreIssuedCerts(map[string]proto.IssuedCert{
+		"ab": {Serial: "ab", Peer: "laptop", NotAfter: now.Add(time.Hour), RevokedAt: now},
+		"cd": {Serial: "cd", Peer: "old", NotAfter: now.Add(-time.Hour), RevokedAt: now},
+	})
+
+	assert.True(t, ca.IsRevoked(big.NewInt(0xab)))
+	assert.NotContains(t, ca.IssuedCerts(), "cd", "expired certificates are dropped")
+}
+
+func TestCertificateAuthority_Rollover(t *testing.T) {
+	dir := t.TempDir()
+	ca, err := NewCertificateAuthority(dir, "")
+	require.NoError(t, err)
+	oldRoot := parseCertPEM(t, ca.CACertPEM())
+	oldPEM, _, err := ca.GeneratePeerCert("laptop", "", "10.42.0.5")
+	require.NoError(t, err)
+	oldCert := parseCertPEM(t, oldPEM)
+
+	assert.Error(t, ca.Promote(time.Hour), "nothing staged")
+	require.NoError(t, ca.Stage())
+	assert.Error(t, ca.Stage(), "already staged")
+
+	// Staged: trusted, but the old root still signs
+	status := ca.Status()
+	require.Len(t, status.Roots, 2)
+	assert.Equal(t, proto.CARootSigning, status.Roots[0].State)
+	assert.Equal(t, proto.CARootStaged, status.Roots[1].State)
+	stagedPEM, _, err := ca.GeneratePeerCert("laptop", "", "10.42.0.5")
+	require.NoError(t, err)
+	require.NoError(t, parseCertPEM(t, stagedPEM).CheckSignatureFrom(oldRoot))
+
+	// The staged root survives a restart
+	reloaded, err := NewCertificateAuthority(dir, "")
+	require.NoError(t, err)
+	assert.Len(t, reloaded.Status().Roots, 2)
+
+	require.NoError(t, ca.Promote(time.Hour))
+	newRoot := parseCertPEM(t, ca.CACertPEM())
+	assert.NotEqual(t, oldRoot.Raw, newRoot.Raw)
+	newPEM, _, err := ca.GeneratePeerCert("laptop", "", "10.42.0.5")
+	require.NoError(t, err)
+	require.NoError(t, parseCertPEM(t, newPEM).CheckSignatureFrom(newRoot))
+
+	// Both old and new certificates verify against the bundle in the window
+	pool := rootPool(ca.TrustBundlePEM())
+	_, err = oldCert.Verify(x509.VerifyOptions{DNSName: "laptop.tunnelmesh", Roots: pool})
+	assert.NoError(t, err)
+	_, err = parseCertPEM(t, newPEM).Verify(x509.VerifyOptions{DNSName: "laptop.tunnelmesh", Roots: pool})
+	assert.NoError(t, err)
+
+	status = ca.Status()
+	require.Len(t, status.Roots, 2)
+	assert.Equal(t, proto.CARootRetired, status.Roots[1].State)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), status.Roots[1].TrustedUntil, time.Minute)
+
+	// No new rollover while the retired root is still trusted
+	require.NoError(t, ca.Stage())
+	assert.Error(t, ca.Promote(time.Hour))
+
+	// The retired root and its window survive a restart
+	reloaded, err = NewCertificateAuthority(dir, "")
+	require.NoError(t, err)
+	status = reloaded.Status()
+	require.Len(t, status.Roots, 3)
+	assert.Equal(t, certFingerprint(newRoot), status.Roots[0].Fingerprint)
+	assert.Equal(t, proto.CARootRetired, status.Roots[2].State)
+}
+
+func TestCertificateAuthority_ServiceCertsUseIntermediate(t *testing.T) {
+	dir := t.TempDir()
+	ca, err := NewCertificateAuthority(dir, "")
+	require.NoError(t, err)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	chainPEM, err := ca.IssueServiceCert("laptop", []string{"bob.tunnelmesh"}, nil, &key.PublicKey)
+	require.NoError(t, err)
+	block, rest := pem.Decode(chainPEM)
+	require.NotNil(t, block)
+	leaf, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	intermediate := parseCertPEM(t, rest)
+	assert.True(t, intermediate.IsCA)
+	assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, intermediate.ExtKeyUsage)
+	require.NoError(t, intermediate.CheckSignatureFrom(parseCertPEM(t, ca.CACertPEM())))
+
+	// Verifies as a server certificate through the intermediate...
+	intermediates := x509.NewCertPool()
+	intermediates.AddCert(intermediate)
+	roots := rootPool(ca.TrustBundlePEM())
+	_, err = leaf.Verify(x509.VerifyOptions{DNSName: "bob.tunnelmesh", Roots: roots, Intermediates: intermediates})
+	require.NoError(t, err)
+
+	// ...but never the way mesh transports verify peers: signed directly by
+	// a root, for client authentication
+	_, err = leaf.Verify(x509.VerifyOptions{DNSName: "bob.tunnelmesh", Roots: roots})
+	assert.Error(t, err)
+	_, err = leaf.Verify(x509.VerifyOptions{
+		DNSName:       "bob.tunnelmesh",
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	assert.Error(t, err)
+	peerPEM, _, err := ca.GeneratePeerCert("bob", "", "10.42.0.6")
+	require.NoError(t, err)
+	_, err = parseCertPEM(t, peerPEM).Verify(x509.VerifyOptions{
+		DNSName:   "bob.tunnelmesh",
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	require.NoError(t, err)
+
+	// The intermediate is kept across restarts and replaced with the root
+	reloaded, err := NewCertificateAuthority(dir, "")
+	require.NoError(t, err)
+	chainPEM, err = reloaded.IssueServiceCert("laptop", []string{"web.tunnelmesh"}, nil, &key.PublicKey)
+	require.NoError(t, err)
+	_, rest = pem.Decode(chainPEM)
+	assert.Equal(t, intermediate.Raw, parseCertPEM(t, rest).Raw)
+
+	require.NoError(t, reloaded.Stage())
+	require.NoError(t, reloaded.Promote(time.Hour))
+	chainPEM, err = reloaded.IssueServiceCert("laptop", []string{"web.tunnelmesh"}, nil, &key.PublicKey)
+	require.NoError(t, err)
+	_, rest = pem.Decode(chainPEM)
+	rotated := parseCertPEM(t, rest)
+	assert.NotEqual(t, intermediate.Raw, rotated.Raw)
+	require.NoError(t, rotated.CheckSignatureFrom(parseCertPEM(t, reloaded.CACertPEM())))
+}
@@ -8,8 +8,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/rs/zerolog/log"
 	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
 )
@@ -21,10 +23,19 @@ var ErrPeerNotFound = errors.New("peer not found")
 type Client struct {
 	baseURL   string
 	authToken string
-	jwtToken  string // JWT token for relay authentication
 	client    *http.Client
+
+	mu           sync.Mutex
+	jwtToken     string    // JWT token for relay and API authentication
+	jwtExpiry    time.Time // Expiry of the JWT token
+	registration []byte    // Last registration request, sent again to renew the JWT
+
+	renewMu sync.Mutex // Serializes token renewals
 }
 
+// tokenRenewBefore is how long before its expiry the JWT token is renewed.
+const tokenRenewBefore = TokenExpiry / 2
+
 // NewClient creates a new coordination client.
 func NewClient(baseURL, authToken string) *Client {
 	return &Client{
@@ -37,36 +48,43 @@ func NewClient(baseURL, authToken string) *Client {
 }
 
 // Register registers this peer with the coordination server.
-// The location parameter is optional and can be nil.
-func (c *Client) Register(name, publicKey string, publicIPs, privateIPs []string, sshPort, udpPort int, behindNAT bool, version string, location *proto.GeoLocation, exitNode string, allowsExitTraffic bool, aliases []string, isCoordinator bool, hasMonitoring bool) (*proto.RegisterResponse, error) {
-	req := proto.RegisterRequest{
-		Name:              name,
-		PublicKey:         publicKey,
-		PublicIPs:         publicIPs,
-		PrivateIPs:        privateIPs,
-		SSHPort:           sshPort,
-		UDPPort:           udpPort,
-		BehindNAT:         behindNAT,
-		Version:           version,
-		Location:          location,
-		ExitPeer:          exitNode,
-		AllowsExitTraffic: allowsExitTraffic,
-		Aliases:           aliases,
-		IsCoordinator:     isCoordinator,
-		HasMonitoring:     hasMonitoring,
-	}
-
+func (c *Client) Register(req proto.RegisterRequest) (*proto.RegisterResponse, error) {
 	body, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("marshal request: %w", err)
 	}
 
-	resp, err := c.doRequest(http.MethodPost, "/api/v1/register", body)
+	return c.register(body)
+}
+
+// register sends a registration request. Once registered, the request is
+// authorized with the JWT token, falling back to the mesh token or join key
+// if the JWT token was rejected, as it is once expired.
+func (c *Client) register(body []byte) (*proto.RegisterResponse, error) {
+	if token := c.JWTToken(); token != "" {
+		result, err := c.registerWith(body, token)
+		if !errors.Is(err, errTokenRejected) {
+			return result, err
+		}
+	}
+	return c.registerWith(body, c.authToken)
+}
+
+// errTokenRejected is returned when the server rejects a request's token.
+var errTokenRejected = errors.New("token rejected")
+
+// registerWith sends a registration request authorized with the given token,
+// and stores the JWT token of the response.
+func (c *Client) registerWith(body []byte, token string) (*proto.RegisterResponse, error) {
+	resp, err := c.send(http.MethodPost, "/api/v1/register", body, token)
 	if err != nil {
 		return nil, err
 	}
 	defer func() { _ = resp.Body.Close() }()
 
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, fmt.Errorf("%w: %w", errTokenRejected, c.parseError(resp))
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, c.parseError(resp)
 	}
@@ -76,12 +94,26 @@ func (c *Client) Register(name, publicKey string, publicIPs, privateIPs []string
 		return nil, fmt.Errorf("decode response: %w", err)
 	}
 
-	// Store JWT token for relay authentication
+	// Store JWT token for relay and API authentication
+	c.mu.Lock()
 	c.jwtToken = result.Token
+	c.jwtExpiry = tokenExpiry(result.Token)
+	c.registration = body
+	c.mu.Unlock()
 
 	return &result, nil
 }
 
+// tokenExpiry returns the expiry of a JWT token, or the zero time if it has
+// none. The token is not verified: the client only uses it to renew it.
+func tokenExpiry(token string) time.Time {
+	var claims jwt.RegisteredClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(token, &claims); err != nil || claims.ExpiresAt == nil {
+		return time.Time{}
+	}
+	return claims.ExpiresAt.Time
+}
+
 // RetryConfig configures the retry behavior for registration.
 type RetryConfig struct {
 	MaxRetries     int           // Maximum number of retry attempts (default: 10)
@@ -100,8 +132,7 @@ func DefaultRetryConfig() RetryConfig {
 
 // RegisterWithRetry registers this peer with exponential backoff retry.
 // It will retry up to MaxRetries times if registration fails.
-// The location parameter is optional and can be nil.
-func (c *Client) RegisterWithRetry(ctx context.Context, name, publicKey string, publicIPs, privateIPs []string, sshPort, udpPort int, behindNAT bool, version string, location *proto.GeoLocation, exitNode string, allowsExitTraffic bool, aliases []string, isCoordinator bool, hasMonitoring bool, cfg RetryConfig) (*proto.RegisterResponse, error) {
+func (c *Client) RegisterWithRetry(ctx context.Context, req proto.RegisterRequest, cfg RetryConfig) (*proto.RegisterResponse, error) {
 	if cfg.MaxRetries == 0 {
 		cfg = DefaultRetryConfig()
 	}
@@ -110,7 +141,7 @@ func (c *Client) RegisterWithRetry(ctx context.Context, name, publicKey string,
 	var lastErr error
 
 	for attempt := 1; attempt <= cfg.MaxRetries; attempt++ {
-		resp, err := c.Register(name, publicKey, publicIPs, privateIPs, sshPort, udpPort, behindNAT, version, location, exitNode, allowsExitTraffic, aliases, isCoordinator, hasMonitoring)
+		resp, err := c.Register(req)
 		if err == nil {
 			return resp, nil
 		}
@@ -181,6 +212,15 @@ func (c *Client) Deregister(name string) error {
 
 // GetDNSRecords returns the current DNS records.
 func (c *Client) GetDNSRecords() ([]proto.DNSRecord, error) {
+	update, err := c.GetDNS()
+	if err != nil {
+		return nil, err
+	}
+	return update.Records, nil
+}
+
+// GetDNS returns the current DNS records together with the custom zone records.
+func (c *Client) GetDNS() (*proto.DNSUpdateNotification, error) {
 	resp, err := c.doRequest(http.MethodGet, "/api/v1/dns", nil)
 	if err != nil {
 		return nil, err
@@ -196,10 +236,37 @@ func (c *Client) GetDNSRecords() ([]proto.DNSRecord, error) {
 		return nil, fmt.Errorf("decode response: %w", err)
 	}
 
-	return result.Records, nil
+	return &result, nil
 }
 
+// doRequest makes an HTTP request authorized with APIToken, renewing the JWT
+// token first if it is about to expire.
 func (c *Client) doRequest(method, path string, body []byte) (*http.Response, error) {
+	if err := c.RenewToken(); err != nil {
+		log.Debug().Err(err).Msg("failed to renew coordination server token")
+	}
+	return c.send(method, path, body, c.APIToken())
+}
+
+// RenewToken registers again with the JWT token for a new one, if it expires
+// within tokenRenewBefore. The mesh token or join key is not used, so a
+// revoked peer or key can't get new tokens.
+func (c *Client) RenewToken() error {
+	c.renewMu.Lock()
+	defer c.renewMu.Unlock()
+
+	c.mu.Lock()
+	token, expiry, registration := c.jwtToken, c.jwtExpiry, c.registration
+	c.mu.Unlock()
+	if token == "" || registration == nil || expiry.IsZero() || time.Until(expiry) > tokenRenewBefore {
+		return nil
+	}
+	_, err := c.registerWith(registration, token)
+	return err
+}
+
+// send makes an HTTP request authorized with the given bearer token.
+func (c *Client) send(method, path string, body []byte, token string) (*http.Response, error) {
 	var bodyReader io.Reader
 	if body != nil {
 		bodyReader = bytes.NewReader(body)
@@ -210,7 +277,7 @@ func (c *Client) doRequest(method, path string, body []byte) (*http.Response, er
 		return nil, fmt.Errorf("create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.authToken)
+	req.Header.Set("Authorization", "Bearer "+token)
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
@@ -232,9 +299,23 @@ func (c *Client) parseError(resp *http.Response) error {
 // JWTToken returns the JWT token received during registration.
 // This token is used for relay authentication.
 func (c *Client) JWTToken() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.jwtToken
 }
 
+// APIToken returns the token peer API requests are authorized with: the JWT
+// token once registered, otherwise the mesh token or join key. Join keys are
+// only accepted for registration.
+func (c *Client) APIToken() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.jwtToken != "" {
+		return c.jwtToken
+	}
+	return c.authToken
+}
+
 // BaseURL returns the base URL of the coordination server.
 func (c *Client) BaseURL() string {
 	return c.baseURL
@@ -249,7 +330,7 @@ func (c *Client) CloseIdleConnections() {
 // CheckRelayRequests checks if any peers are waiting on relay for us.
 // Returns nil if the server doesn't support this endpoint (404).
 func (c *Client) CheckRelayRequests() ([]string, error) {
-	if c.jwtToken == "" {
+	if c.JWTToken() == "" {
 		return nil, nil // No JWT token yet, can't check relay status
 	}
 
@@ -285,7 +366,7 @@ func (c *Client) doRequestWithJWT(method, path string, body []byte) (*http.Respo
 		return nil, fmt.Errorf("create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.jwtToken)
+	req.Header.Set("Authorization", "Bearer "+c.JWTToken())
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
@@ -29,7 +29,7 @@ func TestClient_Register(t *testing.T) {
 	client := NewClient(ts.URL, "test-token")
 
 	// Register
-	resp, err := client.Register("mynode", "SHA256:abc123", []string{"1.2.3.4"}, []string{"192.168.1.1"}, 2222, 0, false, "v1.0.0", nil, "", false, nil, false, false)
+	resp, err := client.Register(proto.RegisterRequest{Name: "mynode", PublicKey: "SHA256:abc123", PublicIPs: []string{"1.2.3.4"}, PrivateIPs: []string{"192.168.1.1"}, SSHPort: 2222, Version: "v1.0.0"})
 	require.NoError(t, err)
 
 	assert.Contains(t, resp.MeshIP, "10.42.") // IP is hash-based, just check it's in mesh range
@@ -59,7 +59,7 @@ func TestClient_RegisterWithLocation(t *testing.T) {
 		Longitude: -0.1278,
 		Source:    "manual",
 	}
-	resp, err := client.Register("geonode", "SHA256:abc123", []string{"1.2.3.4"}, []string{"192.168.1.1"}, 2222, 0, false, "v1.0.0", location, "", false, nil, false, false)
+	resp, err := client.Register(proto.RegisterRequest{Name: "geonode", PublicKey: "SHA256:abc123", PublicIPs: []string{"1.2.3.4"}, PrivateIPs: []string{"192.168.1.1"}, SSHPort: 2222, Version: "v1.0.0", Location: location})
 	require.NoError(t, err)
 
 	assert.Contains(t, resp.MeshIP, "10.42.")
@@ -88,7 +88,7 @@ func TestClient_ListPeers(t *testing.T) {
 	client := NewClient(ts.URL, "test-token")
 
 	// Register a peer
-	_, err = client.Register("node1", "SHA256:key1", nil, nil, 2222, 0, false, "v1.0.0", nil, "", false, nil, false, false)
+	_, err = client.Register(proto.RegisterRequest{Name: "node1", PublicKey: "SHA256:key1", SSHPort: 2222, Version: "v1.0.0"})
 	require.NoError(t, err)
 
 	// List peers
@@ -99,6 +99,47 @@ func TestClient_ListPeers(t *testing.T) {
 	assert.Equal(t, "node1", peers[0].Name)
 }
 
+func TestClient_RenewsToken(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.Coordinator.Enabled = true
+
+	srv, err := NewServer(context.Background(), cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { cleanupServer(t, srv) })
+
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	pubKey, _ := generateTestSSHPubKey(t)
+	client := NewClient(ts.URL, "test-token")
+	_, err = client.Register(proto.RegisterRequest{Name: "node1", PublicKey: pubKey, SSHPort: 2222, Version: "v1.0.0"})
+	require.NoError(t, err)
+	assert.Equal(t, client.JWTToken(), client.APIToken())
+
+	// A token about to expire is renewed with itself
+	client.mu.Lock()
+	client.jwtExpiry = time.Now().Add(time.Minute)
+	client.authToken = "unused"
+	client.mu.Unlock()
+	peers, err := client.ListPeers()
+	require.NoError(t, err)
+	assert.Len(t, peers, 1)
+	client.mu.Lock()
+	assert.WithinDuration(t, time.Now().Add(TokenExpiry), client.jwtExpiry, time.Minute)
+	client.mu.Unlock()
+	_, err = srv.ValidateToken(client.JWTToken())
+	assert.NoError(t, err)
+
+	// A rejected token is not replaced using the mesh token or join key
+	client.mu.Lock()
+	client.jwtToken = "expired"
+	client.authToken = "test-token"
+	client.mu.Unlock()
+	_, err = client.ListPeers()
+	assert.Error(t, err)
+	assert.Equal(t, "expired", client.JWTToken())
+}
+
 // Note: TestClient_Heartbeat and TestClient_HeartbeatNotFound removed.
 // Heartbeats are now sent via WebSocket using PersistentRelay.SendHeartbeat().
 // See internal/tunnel/persistent_relay_test.go for WebSocket heartbeat tests.
@@ -117,7 +158,7 @@ func TestClient_Deregister(t *testing.T) {
 	client := NewClient(ts.URL, "test-token")
 
 	// Register first
-	_, err = client.Register("mynode", "SHA256:key", nil, nil, 2222, 0, false, "v1.0.0", nil, "", false, nil, false, false)
+	_, err = client.Register(proto.RegisterRequest{Name: "mynode", PublicKey: "SHA256:key", SSHPort: 2222, Version: "v1.0.0"})
 	require.NoError(t, err)
 
 	// Verify registered
@@ -147,9 +188,9 @@ func TestClient_GetDNSRecords(t *testing.T) {
 	client := NewClient(ts.URL, "test-token")
 
 	// Register peers
-	_, err = client.Register("node1", "SHA256:key1", nil, nil, 2222, 0, false, "v1.0.0", nil, "", false, nil, false, false)
+	_, err = client.Register(proto.RegisterRequest{Name: "node1", PublicKey: "SHA256:key1", SSHPort: 2222, Version: "v1.0.0"})
 	require.NoError(t, err)
-	_, err = client.Register("node2", "SHA256:key2", nil, nil, 2222, 0, false, "v1.0.0", nil, "", false, nil, false, false)
+	_, err = client.Register(proto.RegisterRequest{Name: "node2", PublicKey: "SHA256:key2", SSHPort: 2222, Version: "v1.0.0"})
 	require.NoError(t, err)
 
 	// Get DNS records
@@ -179,7 +220,7 @@ func TestClient_RegisterWithRetry_SuccessOnFirstTry(t *testing.T) {
 		MaxBackoff:     100 * time.Millisecond,
 	}
 
-	resp, err := client.RegisterWithRetry(ctx, "mynode", "SHA256:abc123", []string{"1.2.3.4"}, []string{}, 2222, 2223, false, "v1.0.0", nil, "", false, []string{}, false, false, retryCfg)
+	resp, err := client.RegisterWithRetry(ctx, proto.RegisterRequest{Name: "mynode", PublicKey: "SHA256:abc123", PublicIPs: []string{"1.2.3.4"}, SSHPort: 2222, UDPPort: 2223, Version: "v1.0.0"}, retryCfg)
 	require.NoError(t, err)
 
 	assert.Contains(t, resp.MeshIP, "10.42.")
@@ -218,7 +259,7 @@ func TestClient_RegisterWithRetry_SuccessAfterFailures(t *testing.T) {
 		MaxBackoff:     50 * time.Millisecond,
 	}
 
-	resp, err := client.RegisterWithRetry(ctx, "mynode", "SHA256:abc123", []string{}, []string{}, 2222, 2223, false, "v1.0.0", nil, "", false, []string{}, false, false, retryCfg)
+	resp, err := client.RegisterWithRetry(ctx, proto.RegisterRequest{Name: "mynode", PublicKey: "SHA256:abc123", SSHPort: 2222, UDPPort: 2223, Version: "v1.0.0"}, retryCfg)
 	require.NoError(t, err)
 
 	assert.Equal(t, int32(3), attempts.Load(), "should have taken 3 attempts")
@@ -245,7 +286,7 @@ func TestClient_RegisterWithRetry_MaxRetriesExceeded(t *testing.T) {
 		MaxBackoff:     50 * time.Millisecond,
 	}
 
-	_, err := client.RegisterWithRetry(ctx, "mynode", "SHA256:abc123", []string{}, []string{}, 2222, 2223, false, "v1.0.0", nil, "", false, []string{}, false, false, retryCfg)
+	_, err := client.RegisterWithRetry(ctx, proto.RegisterRequest{Name: "mynode", PublicKey: "SHA256:abc123", SSHPort: 2222, UDPPort: 2223, Version: "v1.0.0"}, retryCfg)
 	require.Error(t, err)
 
 	assert.Equal(t, int32(3), attempts.Load(), "should have made exactly 3 attempts")
@@ -278,7 +319,7 @@ func TestClient_RegisterWithRetry_ContextCancelled(t *testing.T) {
 		cancel()
 	}()
 
-	_, err := client.RegisterWithRetry(ctx, "mynode", "SHA256:abc123", []string{}, []string{}, 2222, 2223, false, "v1.0.0", nil, "", false, []string{}, false, false, retryCfg)
+	_, err := client.RegisterWithRetry(ctx, proto.RegisterRequest{Name: "mynode", PublicKey: "SHA256:abc123", SSHPort: 2222, UDPPort: 2223, Version: "v1.0.0"}, retryCfg)
 	require.Error(t, err)
 
 	assert.ErrorIs(t, err, context.Canceled, "error should wrap context.Canceled")
@@ -296,7 +337,7 @@ func TestClient_RegisterWithRetry_ConnectionRefused(t *testing.T) {
 		MaxBackoff:     50 * time.Millisecond,
 	}
 
-	_, err := client.RegisterWithRetry(ctx, "mynode", "SHA256:abc123", []string{}, []string{}, 2222, 2223, false, "v1.0.0", nil, "", false, []string{}, false, false, retryCfg)
+	_, err := client.RegisterWithRetry(ctx, proto.RegisterRequest{Name: "mynode", PublicKey: "SHA256:abc123", SSHPort: 2222, UDPPort: 2223, Version: "v1.0.0"}, retryCfg)
 	require.Error(t, err)
 
 	assert.Contains(t, err.Error(), "after 2 attempts")
@@ -343,7 +384,7 @@ func TestServer_GeolocationOnlyOnNewOrChangedIP(t *testing.T) {
 	client := NewClient(ts.URL, "test-token")
 
 	// Test 1: First registration should trigger geolocation lookup
-	_, err = client.Register("node1", "SHA256:key1", []string{"1.2.3.4"}, nil, 2222, 0, false, "v1.0.0", nil, "", false, nil, false, false)
+	_, err = client.Register(proto.RegisterRequest{Name: "node1", PublicKey: "SHA256:key1", PublicIPs: []string{"1.2.3.4"}, SSHPort: 2222, Version: "v1.0.0"})
 	require.NoError(t, err)
 
 	// Wait for background geolocation to complete
@@ -360,7 +401,7 @@ func TestServer_GeolocationOnlyOnNewOrChangedIP(t *testing.T) {
 	assert.Equal(t, "ip", peers[0].Location.Source)
 
 	// Test 2: Re-registration with SAME IP should NOT trigger new lookup
-	_, err = client.Register("node1", "SHA256:key1", []string{"1.2.3.4"}, nil, 2222, 0, false, "v1.0.0", nil, "", false, nil, false, false)
+	_, err = client.Register(proto.RegisterRequest{Name: "node1", PublicKey: "SHA256:key1", PublicIPs: []string{"1.2.3.4"}, SSHPort: 2222, Version: "v1.0.0"})
 	require.NoError(t, err)
 
 	time.Sleep(100 * time.Millisecond)
@@ -368,7 +409,7 @@ func TestServer_GeolocationOnlyOnNewOrChangedIP(t *testing.T) {
 	assert.Equal(t, initialCount, geoLookupCount.Load(), "re-registration with same IP should not trigger new lookup")
 
 	// Test 3: Re-registration with DIFFERENT IP should trigger new lookup
-	_, err = client.Register("node1", "SHA256:key1", []string{"5.6.7.8"}, nil, 2222, 0, false, "v1.0.0", nil, "", false, nil, false, false)
+	_, err = client.Register(proto.RegisterRequest{Name: "node1", PublicKey: "SHA256:key1", PublicIPs: []string{"5.6.7.8"}, SSHPort: 2222, Version: "v1.0.0"})
 	require.NoError(t, err)
 
 	time.Sleep(100 * time.Millisecond)
@@ -398,7 +439,7 @@ func TestServer_ManualLocationPreservedOnIPChange(t *testing.T) {
 		Source:    "manual",
 		City:      "New York",
 	}
-	_, err = client.Register("node1", "SHA256:key1", []string{"1.2.3.4"}, nil, 2222, 0, false, "v1.0.0", manualLoc, "", false, nil, false, false)
+	_, err = client.Register(proto.RegisterRequest{Name: "node1", PublicKey: "SHA256:key1", PublicIPs: []string{"1.2.3.4"}, SSHPort: 2222, Version: "v1.0.0", Location: manualLoc})
 	require.NoError(t, err)
 
 	// Verify location
@@ -410,7 +451,7 @@ func TestServer_ManualLocationPreservedOnIPChange(t *testing.T) {
 	assert.Equal(t, 40.7128, peers[0].Location.Latitude)
 
 	// Re-register with different IP but NO location (simulating reconnect)
-	_, err = client.Register("node1", "SHA256:key1", []string{"5.6.7.8"}, nil, 2222, 0, false, "v1.0.0", nil, "", false, nil, false, false)
+	_, err = client.Register(proto.RegisterRequest{Name: "node1", PublicKey: "SHA256:key1", PublicIPs: []string{"5.6.7.8"}, SSHPort: 2222, Version: "v1.0.0"})
 	require.NoError(t, err)
 
 	// Manual location should be preserved
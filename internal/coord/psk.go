@@ -0,0 +1,275 @@
+package coord
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tunnelmesh/tunnelmesh/internal/config"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
+	"golang.org/x/crypto/hkdf"
+)
+
+// DefaultPSKRotationInterval is how often the handshake pre-shared keys are
+// rotated unless configured otherwise.
+const DefaultPSKRotationInterval = 24 * time.Hour
+
+// pskSecretReloadInterval is how often coordinators reload the PSK secret
+// from the system store, to pick up a secret replaced by another
+// coordinator when it revoked a peer.
+const pskSecretReloadInterval = time.Minute
+
+// pskKeys derives the pre-shared keys peers mix into UDP handshakes from a
+// secret shared by all coordinators, so every coordinator hands out the same
+// keys. Keys change every rotation interval (an epoch). The mesh-wide key
+// is used between any two peers unless they are a configured pair, whose
+// key no other peer is sent. The secret is replaced when a peer is revoked,
+// so the keys it was sent stop working before their epochs end, and other
+// coordinators reload it from the system store.
+type pskKeys struct {
+	interval time.Duration
+	pairs    map[string][]string // Peer name -> peers it shares a pair key with
+
+	mu     sync.RWMutex
+	secret []byte
+}
+
+// newPSKKeys validates the PSK configuration and returns the keys derived
+// from secret.
+func newPSKKeys(secret []byte, cfg config.PSKConfig) (*pskKeys, error) {
+	k := &pskKeys{
+		interval: DefaultPSKRotationInterval,
+		pairs:    make(map[string][]string),
+		secret:   secret,
+	}
+	if cfg.RotationInterval != "" {
+		interval, err := time.ParseDuration(cfg.RotationInterval)
+		if err != nil || interval < 10*time.Minute {
+			return nil, fmt.Errorf("invalid psk rotation_interval %q: must be a duration of at least 10m", cfg.RotationInterval)
+		}
+		k.interval = interval.Truncate(time.Second)
+	}
+	for _, pair := range cfg.Pairs {
+		if len(pair) != 2 || pair[0] == "" || pair[1] == "" || pair[0] == pair[1] {
+			return nil, fmt.Errorf("invalid psk pair %q: must be two different peer names", pair)
+		}
+		if !slices.Contains(k.pairs[pair[0]], pair[1]) {
+			k.pairs[pair[0]] = append(k.pairs[pair[0]], pair[1])
+			k.pairs[pair[1]] = append(k.pairs[pair[1]], pair[0])
+		}
+	}
+	return k, nil
+}
+
+// epoch returns the rotation epoch at t.
+func (k *pskKeys) epoch(t time.Time) uint32 {
+	return uint32(t.Unix() / int64(k.interval/time.Second))
+}
+
+// epochStart returns when an epoch begins.
+func (k *pskKeys) epochStart(epoch uint32) time.Time {
+	return time.Unix(int64(epoch)*int64(k.interval/time.Second), 0)
+}
+
+// setSecret replaces the secret keys are derived from, reporting whether it
+// changed.
+func (k *pskKeys) setSecret(secret []byte) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if bytes.Equal(k.secret, secret) {
+		return false
+	}
+	k.secret = secret
+	return true
+}
+
+// derive returns the key of an epoch for the given purpose.
+func (k *pskKeys) derive(epoch uint32, info string) []byte {
+	k.mu.RLock()
+	secret := k.secret
+	k.mu.RUnlock()
+	salt := binary.BigEndian.AppendUint32(nil, epoch)
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(info)), key); err != nil {
+		panic(err) // Can't happen for 32 bytes of SHA-256 HKDF output
+	}
+	return key
+}
+
+// pairKey returns the key two peers share in an epoch.
+func (k *pskKeys) pairKey(epoch uint32, a, b string) []byte {
+	if a > b {
+		a, b = b, a
+	}
+	return k.derive(epoch, "tunnelmesh psk pair\x00"+a+"\x00"+b)
+}
+
+// update returns the keys sent to a peer: those of the previous, current
+// and next epochs, so handshakes keep working while peers rotate at
+// slightly different times.
+func (k *pskKeys) update(peerName string, now time.Time) proto.PSKUpdate {
+	current := k.epoch(now)
+	update := proto.PSKUpdate{Current: current}
+	for epoch := current - 1; epoch != current+2; epoch++ {
+		set := proto.PSKSet{Epoch: epoch, Mesh: k.derive(epoch, "tunnelmesh psk mesh")}
+		for _, other := range k.pairs[peerName] {
+			if set.Pairs == nil {
+				set.Pairs = make(map[string][]byte)
+			}
+			set.Pairs[other] = k.pairKey(epoch, peerName, other)
+		}
+		update.Keys = append(update.Keys, set)
+	}
+	return update
+}
+
+// initPSK loads the PSK secret, creating it on the first start with PSKs
+// enabled, and sets up key derivation.
+func (s *Server) initPSK(ctx context.Context) error {
+	cfg := s.cfg.Coordinator.PSK
+	if !cfg.Enabled {
+		return nil
+	}
+	var secret []byte
+	if s.s3SystemStore != nil {
+		loaded, err := s.s3SystemStore.LoadPSKSecret(ctx)
+		if err != nil {
+			return fmt.Errorf("load PSK secret: %w", err)
+		}
+		secret = loaded
+	}
+	if len(secret) == 0 {
+		var err error
+		if secret, err = s.newPSKSecret(ctx); err != nil {
+			return err
+		}
+		log.Info().Msg("generated new PSK secret")
+	}
+	keys, err := newPSKKeys(secret, cfg)
+	if err != nil {
+		return err
+	}
+	s.psk = keys
+	return nil
+}
+
+// newPSKSecret generates and saves a new PSK secret.
+func (s *Server) newPSKSecret(ctx context.Context) ([]byte, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("generate PSK secret: %w", err)
+	}
+	if s.s3SystemStore != nil {
+		if err := s.s3SystemStore.SavePSKSecret(ctx, secret); err != nil {
+			return nil, fmt.Errorf("save PSK secret: %w", err)
+		}
+	}
+	return secret, nil
+}
+
+// rotatePSKSecret replaces the PSK secret and sends every connected peer its
+// new keys. Used when a peer is revoked, since the keys of the next epoch it
+// was sent would otherwise keep working for up to two rotation intervals.
+func (s *Server) rotatePSKSecret(ctx context.Context) error {
+	if s.psk == nil {
+		return nil
+	}
+	secret, err := s.newPSKSecret(ctx)
+	if err != nil {
+		return err
+	}
+	s.psk.setSecret(secret)
+	log.Info().Msg("replaced PSK secret")
+	s.pushPSKUpdates()
+	return nil
+}
+
+// reloadPSKSecret loads the PSK secret from the system store and, if another
+// coordinator replaced it, sends every connected peer its new keys.
+func (s *Server) reloadPSKSecret(ctx context.Context) {
+	if s.psk == nil || s.s3SystemStore == nil {
+		return
+	}
+	secret, err := s.s3SystemStore.LoadPSKSecret(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to reload PSK secret")
+		return
+	}
+	if len(secret) == 0 || !s.psk.setSecret(secret) {
+		return
+	}
+	log.Info().Msg("loaded PSK secret replaced by another coordinator")
+	s.pushPSKUpdates()
+}
+
+// pskPayload returns the PSK update for a peer. With PSKs disabled it holds
+// no keys, which makes peers drop keys from an earlier configuration.
+func (s *Server) pskPayload(peerName string) ([]byte, error) {
+	var update proto.PSKUpdate
+	if s.psk != nil {
+		update = s.psk.update(peerName, time.Now())
+	}
+	return json.Marshal(update)
+}
+
+// pushPSKUpdates sends every connected peer its handshake pre-shared keys.
+func (s *Server) pushPSKUpdates() {
+	if s.relay == nil {
+		return
+	}
+	for _, peerName := range s.relay.GetConnectedPeerNames() {
+		s.pushPSKUpdate(peerName)
+	}
+}
+
+// pushPSKUpdate sends the handshake pre-shared keys to a connected peer.
+func (s *Server) pushPSKUpdate(peerName string) {
+	if s.relay == nil {
+		return
+	}
+	payload, err := s.pskPayload(peerName)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to build PSK update")
+		return
+	}
+	s.relay.PushPSKUpdate(peerName, payload)
+}
+
+// StartPSKRotation starts a goroutine sending every connected peer its new
+// keys at the start of each epoch, and reloading the PSK secret from the
+// system store. The goroutine stops when the context is cancelled.
+func (s *Server) StartPSKRotation(ctx context.Context) {
+	if s.psk == nil {
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		reload := time.NewTicker(pskSecretReloadInterval)
+		defer reload.Stop()
+		for {
+			next := s.psk.epochStart(s.psk.epoch(time.Now()) + 1)
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-reload.C:
+				timer.Stop()
+				s.reloadPSKSecret(ctx)
+			case <-timer.C:
+				log.Info().Uint32("epoch", s.psk.epoch(time.Now())).Msg("rotating PSKs")
+				s.pushPSKUpdates()
+			}
+		}
+	}()
+}
@@ -0,0 +1,66 @@
+package coord
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tunnelmesh/tunnelmesh/internal/config"
+)
+
+func TestNewPSKKeys_Validation(t *testing.T) {
+	secret := bytes.Repeat([]byte{1}, 32)
+
+	keys, err := newPSKKeys(secret, config.PSKConfig{Enabled: true})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultPSKRotationInterval, keys.interval)
+
+	for _, cfg := range []config.PSKConfig{
+		{RotationInterval: "5m"},
+		{RotationInterval: "daily"},
+		{Pairs: [][]string{{"alice"}}},
+		{Pairs: [][]string{{"alice", "alice"}}},
+		{Pairs: [][]string{{"alice", "bob", "carol"}}},
+	} {
+		_, err := newPSKKeys(secret, cfg)
+		assert.Error(t, err, "config %+v", cfg)
+	}
+}
+
+func TestPSKKeys_Update(t *testing.T) {
+	secret := bytes.Repeat([]byte{1}, 32)
+	keys, err := newPSKKeys(secret, config.PSKConfig{
+		Enabled:          true,
+		RotationInterval: "1h",
+		Pairs:            [][]string{{"alice", "bob"}},
+	})
+	require.NoError(t, err)
+
+	now := time.Unix(100*3600+42, 0)
+	alice := keys.update("alice", now)
+	bob := keys.update("bob", now)
+	carol := keys.update("carol", now)
+
+	assert.Equal(t, uint32(100), alice.Current)
+	require.Len(t, alice.Keys, 3)
+	for i, set := range alice.Keys {
+		assert.Equal(t, uint32(99+i), set.Epoch)
+		assert.Len(t, set.Mesh, 32)
+
+		// Every peer gets the same mesh key, but only the pair gets its key
+		assert.Equal(t, set.Mesh, carol.Keys[i].Mesh)
+		assert.Equal(t, set.Pairs["bob"], bob.Keys[i].Pairs["alice"])
+		assert.NotEqual(t, set.Mesh, set.Pairs["bob"])
+		assert.Empty(t, carol.Keys[i].Pairs)
+	}
+	assert.NotEqual(t, alice.Keys[0].Mesh, alice.Keys[1].Mesh, "keys must change every epoch")
+
+	// Another secret derives other keys
+	other, err := newPSKKeys(bytes.Repeat([]byte{2}, 32), config.PSKConfig{RotationInterval: "1h"})
+	require.NoError(t, err)
+	assert.NotEqual(t, alice.Keys[1].Mesh, other.update("alice", now).Keys[1].Mesh)
+
+	assert.Equal(t, time.Unix(101*3600, 0), keys.epochStart(keys.epoch(now)+1))
+}
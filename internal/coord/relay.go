@@ -89,6 +89,18 @@ const (
 
 	// Coordinator discovery message types
 	MsgTypeCoordListUpdate byte = 0x36 // Server -> Client: updated coordinator IP list
+
+	// Connection tracking message types
+	MsgTypeFilterFlowsQuery byte = 0x37 // Server -> Client: request the connection table
+	MsgTypeFilterFlowsReply byte = 0x38 // Client -> Server: response with tracked flows
+
+	// Certificate lifecycle message types
+	MsgTypeCertRenew   byte = 0x40 // Client -> Server: certificate signing request
+	MsgTypeCertIssued  byte = 0x41 // Server -> Client: renewed certificate with trust bundle and CRL
+	MsgTypeTrustUpdate byte = 0x42 // Server -> Client: trust bundle and CRL
+
+	// Handshake pre-shared key message types
+	MsgTypePSKUpdate byte = 0x43 // Server -> Client: JSON proto.PSKUpdate
 )
 
 var upgrader = websocket.Upgrader{
@@ -275,6 +287,18 @@ func (r *relayManager) handleAPIResponse(data []byte) {
 // QueryFilterRules sends a filter rules query to a peer and waits for response.
 // Returns the JSON-encoded filter rules or error if timeout/peer not connected.
 func (r *relayManager) QueryFilterRules(ctx context.Context, peerName string, timeout time.Duration) ([]byte, error) {
+	return r.queryPeer(ctx, peerName, MsgTypeFilterRulesQuery, "filter rules", timeout)
+}
+
+// QueryFilterFlows sends a connection table query to a peer and waits for response.
+// Returns the JSON-encoded flows or error if timeout/peer not connected.
+func (r *relayManager) QueryFilterFlows(ctx context.Context, peerName string, timeout time.Duration) ([]byte, error) {
+	return r.queryPeer(ctx, peerName, MsgTypeFilterFlowsQuery, "filter flows", timeout)
+}
+
+// queryPeer sends a query message to a peer and waits for the reply body,
+// routed back by handleFilterRulesReply.
+func (r *relayManager) queryPeer(ctx context.Context, peerName string, msgType byte, what string, timeout time.Duration) ([]byte, error) {
 	r.mu.Lock()
 	pc, ok := r.persistent[peerName]
 	r.mu.Unlock()
@@ -297,9 +321,9 @@ func (r *relayManager) QueryFilterRules(ctx context.Context, peerName string, ti
 		r.apiRequestsMu.Unlock()
 	}()
 
-	// Build request: [MsgTypeFilterRulesQuery][reqID:4]
+	// Build request: [msgType][reqID:4]
 	msg := make([]byte, 5)
-	msg[0] = MsgTypeFilterRulesQuery
+	msg[0] = msgType
 	msg[1] = byte(reqID >> 24)
 	msg[2] = byte(reqID >> 16)
 	msg[3] = byte(reqID >> 8)
@@ -310,7 +334,7 @@ func (r *relayManager) QueryFilterRules(ctx context.Context, peerName string, ti
 	case pc.writeChan <- msg:
 		// Message queued
 	default:
-		return nil, fmt.Errorf("query filter rules: write channel full")
+		return nil, fmt.Errorf("query %s: write channel full", what)
 	}
 
 	// Combine parent context with timeout
@@ -322,11 +346,11 @@ func (r *relayManager) QueryFilterRules(ctx context.Context, peerName string, ti
 	case resp := <-respChan:
 		return resp, nil
 	case <-ctx.Done():
-		return nil, fmt.Errorf("query filter rules from %s: %w", peerName, ctx.Err())
+		return nil, fmt.Errorf("query %s from %s: %w", what, peerName, ctx.Err())
 	}
 }
 
-// handleFilterRulesReply processes a filter rules response from a peer.
+// handleFilterRulesReply processes a filter rules or flows response from a peer.
 func (r *relayManager) handleFilterRulesReply(data []byte) {
 	if len(data) < 5 {
 		log.Debug().Msg("filter rules reply too short")
@@ -640,10 +664,15 @@ func (r *relayManager) NotifyHolePunch(peerName string, requestingPeers []string
 
 // FilterRuleWire is the wire format for a filter rule.
 type FilterRuleWire struct {
-	Port       uint16 `json:"port"`
-	Protocol   string `json:"protocol"`    // "tcp" or "udp"
-	Action     string `json:"action"`      // "allow" or "deny"
-	SourcePeer string `json:"source_peer"` // Source peer (empty = any peer)
+	Port        uint16 `json:"port"`
+	PortEnd     uint16 `json:"port_end,omitempty"`     // Last port of a range (0 = port only)
+	Protocol    string `json:"protocol"`               // "tcp", "udp", "icmp" or "icmpv6"
+	ICMPType    uint8  `json:"icmp_type,omitempty"`    // ICMP message type (0 = any)
+	Action      string `json:"action"`                 // "allow" or "deny"
+	SourcePeer  string `json:"source_peer"`            // Source peer (empty = any peer)
+	SourceCIDR  string `json:"source_cidr,omitempty"`  // Source address prefix (empty = any)
+	SourceGroup string `json:"source_group,omitempty"` // RBAC group of the source peer
+	DestGroup   string `json:"dest_group,omitempty"`   // RBAC group of the receiving peer
 }
 
 // PushFilterRules sends the full set of coordinator filter rules to a peer.
@@ -662,10 +691,15 @@ func (r *relayManager) PushFilterRules(peerName string, rules []config.FilterRul
 	wireRules := make([]FilterRuleWire, len(rules))
 	for i, r := range rules {
 		wireRules[i] = FilterRuleWire{
-			Port:       r.Port,
-			Protocol:   r.Protocol,
-			Action:     r.Action,
-			SourcePeer: r.SourcePeer,
+			Port:        r.Port,
+			PortEnd:     r.PortEnd,
+			Protocol:    r.Protocol,
+			ICMPType:    r.ICMPType,
+			Action:      r.Action,
+			SourcePeer:  r.SourcePeer,
+			SourceCIDR:  r.SourceCIDR,
+			SourceGroup: r.SourceGroup,
+			DestGroup:   r.DestGroup,
 		}
 	}
 
@@ -698,7 +732,7 @@ func (r *relayManager) PushFilterRules(peerName string, rules []config.FilterRul
 
 // PushFilterRuleAdd sends a single filter rule add to a peer.
 // Used when admin panel adds a temporary rule.
-func (r *relayManager) PushFilterRuleAdd(peerName string, port uint16, protocol, action, sourcePeer string) {
+func (r *relayManager) PushFilterRuleAdd(peerName string, rule FilterRuleWire) {
 	r.mu.Lock()
 	pc, ok := r.persistent[peerName]
 	r.mu.Unlock()
@@ -708,13 +742,6 @@ func (r *relayManager) PushFilterRuleAdd(peerName string, port uint16, protocol,
 		return
 	}
 
-	rule := FilterRuleWire{
-		Port:       port,
-		Protocol:   protocol,
-		Action:     action,
-		SourcePeer: sourcePeer,
-	}
-
 	ruleJSON, err := json.Marshal(rule)
 	if err != nil {
 		log.Error().Err(err).Str("peer", peerName).Msg("failed to marshal filter rule")
@@ -732,10 +759,10 @@ func (r *relayManager) PushFilterRuleAdd(peerName string, port uint16, protocol,
 	case pc.writeChan <- msg:
 		log.Debug().
 			Str("peer", peerName).
-			Uint16("port", port).
-			Str("protocol", protocol).
-			Str("action", action).
-			Str("source_peer", sourcePeer).
+			Uint16("port", rule.Port).
+			Str("protocol", rule.Protocol).
+			Str("action", rule.Action).
+			Str("source_peer", rule.SourcePeer).
 			Msg("pushed filter rule add to peer")
 	default:
 		log.Debug().
@@ -745,7 +772,9 @@ func (r *relayManager) PushFilterRuleAdd(peerName string, port uint16, protocol,
 }
 
 // PushFilterRuleRemove sends a filter rule removal to a peer.
-func (r *relayManager) PushFilterRuleRemove(peerName string, port uint16, protocol, sourcePeer string) {
+// The full rule follows the legacy fields as JSON, so peers can remove
+// rules selected by port range, CIDR, group or ICMP type.
+func (r *relayManager) PushFilterRuleRemove(peerName string, rule FilterRuleWire) {
 	r.mu.Lock()
 	pc, ok := r.persistent[peerName]
 	r.mu.Unlock()
@@ -755,21 +784,33 @@ func (r *relayManager) PushFilterRuleRemove(peerName string, port uint16, protoc
 		return
 	}
 
-	// Build message: [MsgTypeFilterRuleRemove][port:2][protocol_len:1][protocol][source_peer_len:1][source_peer]
-	msg := make([]byte, 5+len(protocol)+len(sourcePeer))
+	ruleJSON, err := json.Marshal(rule)
+	if err != nil {
+		log.Error().Err(err).Str("peer", peerName).Msg("failed to marshal filter rule")
+		return
+	}
+
+	// Build message: [MsgTypeFilterRuleRemove][port:2][protocol_len:1][protocol][source_peer_len:1][source_peer][rule_len:2][rule JSON]
+	protocol, sourcePeer := rule.Protocol, rule.SourcePeer
+	msg := make([]byte, 7+len(protocol)+len(sourcePeer)+len(ruleJSON))
 	msg[0] = MsgTypeFilterRuleRemove
-	msg[1] = byte(port >> 8)
-	msg[2] = byte(port)
+	msg[1] = byte(rule.Port >> 8)
+	msg[2] = byte(rule.Port)
 	msg[3] = byte(len(protocol))
 	copy(msg[4:4+len(protocol)], protocol)
-	msg[4+len(protocol)] = byte(len(sourcePeer))
-	copy(msg[5+len(protocol):], sourcePeer)
+	offset := 4 + len(protocol)
+	msg[offset] = byte(len(sourcePeer))
+	copy(msg[offset+1:], sourcePeer)
+	offset += 1 + len(sourcePeer)
+	msg[offset] = byte(len(ruleJSON) >> 8)
+	msg[offset+1] = byte(len(ruleJSON))
+	copy(msg[offset+2:], ruleJSON)
 
 	select {
 	case pc.writeChan <- msg:
 		log.Debug().
 			Str("peer", peerName).
-			Uint16("port", port).
+			Uint16("port", rule.Port).
 			Str("protocol", protocol).
 			Str("source_peer", sourcePeer).
 			Msg("pushed filter rule remove to peer")
@@ -825,6 +866,71 @@ func (r *relayManager) BroadcastCoordListUpdate(ips []string) {
 	}
 }
 
+// PushTrustUpdate sends the CA trust bundle and CRL to a peer.
+// Format: [MsgTypeTrustUpdate][bundle_len:2][bundle PEM][CRL DER]
+func (r *relayManager) PushTrustUpdate(peerName string, payload []byte) {
+	r.mu.Lock()
+	pc, ok := r.persistent[peerName]
+	r.mu.Unlock()
+
+	if !ok {
+		log.Debug().Str("peer", peerName).Msg("cannot push trust update: peer not connected")
+		return
+	}
+	pc.sendTrustUpdate(payload)
+}
+
+// BroadcastTrustUpdate sends the CA trust bundle and CRL to all connected peers.
+func (r *relayManager) BroadcastTrustUpdate(payload []byte) {
+	r.mu.Lock()
+	peers := make([]*persistentConn, 0, len(r.persistent))
+	for _, pc := range r.persistent {
+		peers = append(peers, pc)
+	}
+	r.mu.Unlock()
+
+	for _, pc := range peers {
+		pc.sendTrustUpdate(payload)
+	}
+}
+
+// PushPSKUpdate sends the handshake pre-shared keys to a peer.
+// Format: [MsgTypePSKUpdate][JSON proto.PSKUpdate]
+func (r *relayManager) PushPSKUpdate(peerName string, payload []byte) {
+	r.mu.Lock()
+	pc, ok := r.persistent[peerName]
+	r.mu.Unlock()
+
+	if !ok {
+		log.Debug().Str("peer", peerName).Msg("cannot push PSK update: peer not connected")
+		return
+	}
+
+	msg := make([]byte, 1+len(payload))
+	msg[0] = MsgTypePSKUpdate
+	copy(msg[1:], payload)
+
+	select {
+	case pc.writeChan <- msg:
+		log.Debug().Str("peer", peerName).Msg("pushed PSK update to peer")
+	default:
+		log.Debug().Str("peer", peerName).Msg("failed to push PSK update: channel full")
+	}
+}
+
+func (pc *persistentConn) sendTrustUpdate(payload []byte) {
+	msg := make([]byte, 1+len(payload))
+	msg[0] = MsgTypeTrustUpdate
+	copy(msg[1:], payload)
+
+	select {
+	case pc.writeChan <- msg:
+		log.Debug().Str("peer", pc.peerName).Msg("pushed trust update to peer")
+	default:
+		log.Debug().Str("peer", pc.peerName).Msg("failed to push trust update: channel full")
+	}
+}
+
 // PushServicePorts sends coordinator service port announcements to a peer.
 // This tells the peer which ports the coordinator exposes (admin, metrics, etc).
 func (r *relayManager) PushServicePorts(peerName string, ports []uint16) {
@@ -947,6 +1053,12 @@ func (s *Server) handlePersistentRelay(w http.ResponseWriter, r *http.Request) {
 		go s.relay.PushServicePorts(peerName, servicePorts)
 	}
 
+	// Push the CA trust bundle and CRL (peers may have missed a rollover or revocation)
+	go s.pushTrustUpdate(peerName)
+
+	// Push the handshake pre-shared keys (or none, if PSKs are disabled)
+	go s.pushPSKUpdate(peerName)
+
 	// Set up ping/pong handlers for keepalive
 	conn.SetPongHandler(func(string) error {
 		_ = conn.SetReadDeadline(time.Now().Add(90 * time.Second))
@@ -1011,6 +1123,21 @@ func (s *Server) handlePersistentRelayMessage(sourcePeer string, data []byte) {
 			if s.cfg.Coordinator.Locations && stats.Location != nil && stats.Location.IsSet() {
 				peer.peer.Location = stats.Location
 			}
+			// Exit peer in use, which changes on failover
+			if len(stats.ExitPeers) > 0 || len(stats.ExitRoutes) > 0 {
+				peer.peer.ExitPeer = stats.ExitPeer
+				peer.peer.ExitPeers = stats.ExitPeers
+				peer.peer.ExitRoutes = stats.ExitRoutes
+			}
+			// Peers reachable through this peer, which changes as tunnels come and go
+			peer.peer.RelayTo = stats.RelayTo
+			// Exit permissions and WireGuard client tags, which change with their config
+			peer.peer.ExitAllowTags = stats.ExitAllowTags
+			peer.peer.ClientTags = stats.ClientTags
+			// Optional transport ports, which are only known once the transports are listening
+			peer.peer.QUICPort = stats.QUICPort
+			peer.peer.TLSPort = stats.TLSPort
+			peer.peer.TLSServerName = stats.TLSServerName
 			// Store reported latency metrics (only update if peer reported a value)
 			if stats.CoordinatorRTTMs > 0 {
 				peer.coordinatorRTT = stats.CoordinatorRTTMs
@@ -1109,10 +1236,14 @@ func (s *Server) handlePersistentRelayMessage(sourcePeer string, data []byte) {
 		// API response from concentrator
 		s.relay.handleAPIResponse(data)
 
-	case MsgTypeFilterRulesReply:
-		// Filter rules response from peer
+	case MsgTypeFilterRulesReply, MsgTypeFilterFlowsReply:
+		// Filter rules or connection table response from peer
 		s.relay.handleFilterRulesReply(data)
 
+	case MsgTypeCertRenew:
+		// Format: [MsgTypeCertRenew][CSR DER]
+		go s.handleCertRenewal(sourcePeer, data[1:])
+
 	default:
 		log.Debug().Str("peer", sourcePeer).Uint8("type", msgType).Msg("unknown persistent relay message type")
 	}
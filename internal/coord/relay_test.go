@@ -32,13 +32,16 @@ func connectRelay(t *testing.T, serverURL, peerName, jwtToken string) *websocket
 	conn, _, err := dialer.Dial(wsURL, headers)
 	require.NoError(t, err, "failed to connect to relay")
 
-	// Drain the automatic service port notification message (sent because S3 is always enabled)
-	// This prevents tests from reading it when they expect other messages
+	// Drain the automatic service port notification (sent because S3 is always enabled),
+	// CA trust update (0x42) and PSK update (0x43), which arrive in any order.
+	// This prevents tests from reading them when they expect other messages
 	_ = conn.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
-	_, data, err := conn.ReadMessage()
-	// Ignore read errors - the notification might not arrive immediately
-	_ = err
-	_ = data // May or may not receive service port notification (0x33)
+	for range 3 {
+		// Ignore read errors - the notifications might not arrive immediately
+		if _, _, err := conn.ReadMessage(); err != nil {
+			break
+		}
+	}
 	// Reset deadline for test use
 	_ = conn.SetReadDeadline(time.Time{})
 
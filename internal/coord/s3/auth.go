@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/tunnelmesh/tunnelmesh/internal/auth"
@@ -19,7 +20,9 @@ type CredentialStore struct {
 	accessKeyToUser map[string]string
 	// userSecrets maps user ID -> secret key
 	userSecrets map[string]string
-	mu          sync.RWMutex
+	// userAccessKeys maps user ID -> access key
+	userAccessKeys map[string]string
+	mu             sync.RWMutex
 }
 
 // NewCredentialStore creates a new credential store.
@@ -27,6 +30,7 @@ func NewCredentialStore() *CredentialStore {
 	return &CredentialStore{
 		accessKeyToUser: make(map[string]string),
 		userSecrets:     make(map[string]string),
+		userAccessKeys:  make(map[string]string),
 	}
 }
 
@@ -43,6 +47,7 @@ func (cs *CredentialStore) RegisterUser(userID, publicKey string) (accessKey, se
 
 	cs.accessKeyToUser[accessKey] = userID
 	cs.userSecrets[userID] = secretKey
+	cs.userAccessKeys[userID] = accessKey
 
 	return accessKey, secretKey, nil
 }
@@ -72,6 +77,20 @@ func (cs *CredentialStore) GetSecret(userID string) (secretKey string, ok bool)
 	return
 }
 
+// GetCredentials returns the access and secret key registered for a user ID.
+// Used to sign presigned URLs on the user's behalf.
+func (cs *CredentialStore) GetCredentials(userID string) (accessKey, secretKey string, ok bool) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	accessKey, ok = cs.userAccessKeys[userID]
+	if !ok {
+		return "", "", false
+	}
+	secretKey, ok = cs.userSecrets[userID]
+	return accessKey, secretKey, ok
+}
+
 // DeriveS3Credentials derives S3 access and secret keys from a public key.
 // Access key: 20 character hex string from HKDF(pubkey, "s3-access-key")
 // Secret key: 40 character hex string from HKDF(pubkey, "s3-secret-key")
@@ -101,8 +120,13 @@ func DeriveS3Credentials(publicKey string) (accessKey, secretKey string, err err
 type RBACAuthorizer struct {
 	credentials *CredentialStore
 	authorizer  *auth.Authorizer
+	objectTags  ObjectTagLookup
 }
 
+// ObjectTagLookup returns the current tags of an object, or nil if the object
+// does not exist or is untagged.
+type ObjectTagLookup func(bucket, key string) map[string]string
+
 // NewRBACAuthorizer creates a new RBAC-based authorizer.
 func NewRBACAuthorizer(credentials *CredentialStore, authorizer *auth.Authorizer) *RBACAuthorizer {
 	return &RBACAuthorizer{
@@ -111,9 +135,87 @@ func NewRBACAuthorizer(credentials *CredentialStore, authorizer *auth.Authorizer
 	}
 }
 
+// SetObjectTagLookup enables object tag conditions on role bindings by
+// supplying the object's current tags to each authorization check.
+func (a *RBACAuthorizer) SetObjectTagLookup(lookup ObjectTagLookup) {
+	a.objectTags = lookup
+}
+
 // AuthorizeRequest authenticates and authorizes an S3 request.
-// It extracts credentials from the Authorization header and checks RBAC permissions.
+// SigV4 requests (Authorization header or presigned query) are fully verified;
+// other clients may use Basic auth, Bearer tokens or the legacy simple signature.
+// The authenticated user is then checked against RBAC permissions.
 func (a *RBACAuthorizer) AuthorizeRequest(r *http.Request, verb, resource, bucket, objectKey string) (userID string, err error) {
+	if isSigV4Request(r) {
+		userID, err = a.authenticateSigV4(r)
+	} else {
+		userID, err = a.authenticateLegacy(r)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// Check RBAC permissions
+	if !a.authorize(userID, verb, resource, bucket, objectKey) {
+		log.Info().Str("user_id", userID).Str("verb", verb).Str("resource", resource).Str("bucket", bucket).Str("object", objectKey).Msg("S3 access denied: permission denied")
+		return "", ErrAccessDenied
+	}
+
+	return userID, nil
+}
+
+// authorize checks RBAC permissions, including object tag conditions when a
+// tag lookup is configured.
+func (a *RBACAuthorizer) authorize(userID, verb, resource, bucket, objectKey string) bool {
+	var tags map[string]string
+	if a.objectTags != nil && objectKey != "" {
+		tags = a.objectTags(bucket, objectKey)
+	}
+	return a.authorizer.AuthorizeWithTags(userID, verb, resource, bucket, objectKey, tags)
+}
+
+// AuthorizeTags checks an authenticated user against the tags a write leaves
+// on an object, like the s3:RequestObjectTag condition key. Tag conditions
+// on bindings then hold for the tags written as well as the current ones, so
+// a write can't move an object into or out of a tag-scoped binding.
+func (a *RBACAuthorizer) AuthorizeTags(userID, verb, resource, bucket, objectKey string, tags map[string]string) bool {
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	return a.authorizer.AuthorizeWithTags(userID, verb, resource, bucket, objectKey, tags)
+}
+
+// authenticateSigV4 verifies an AWS Signature V4 request and returns the signer's user ID.
+func (a *RBACAuthorizer) authenticateSigV4(r *http.Request) (string, error) {
+	sr, err := parseSigV4Request(r)
+	if err != nil {
+		log.Info().Err(err).Str("method", r.Method).Str("path", r.URL.Path).Msg("S3 access denied: malformed SigV4 request")
+		return "", err
+	}
+
+	userID, ok := a.credentials.LookupUser(sr.accessKey)
+	if !ok {
+		log.Info().Str("access_key", sr.accessKey[:min(8, len(sr.accessKey))]).Msg("S3 access denied: unknown access key")
+		return "", ErrAccessDenied
+	}
+
+	secretKey, ok := a.credentials.GetSecret(userID)
+	if !ok {
+		log.Info().Str("user_id", userID).Msg("S3 access denied: no secret key")
+		return "", ErrAccessDenied
+	}
+
+	if err := verifySigV4(r, sr, secretKey, time.Now()); err != nil {
+		log.Info().Err(err).Str("user_id", userID).Bool("presigned", sr.presigned).Msg("S3 access denied: SigV4 verification failed")
+		return "", err
+	}
+
+	return userID, nil
+}
+
+// authenticateLegacy handles Basic auth, Bearer tokens and the legacy
+// "AWS ACCESS_KEY:SIGNATURE" scheme.
+func (a *RBACAuthorizer) authenticateLegacy(r *http.Request) (string, error) {
 	var accessKey, secret string
 	var isBasicAuth bool
 
@@ -160,12 +262,6 @@ func (a *RBACAuthorizer) AuthorizeRequest(r *http.Request, verb, resource, bucke
 		}
 	}
 
-	// Check RBAC permissions
-	if !a.authorizer.Authorize(userID, verb, resource, bucket, objectKey) {
-		log.Info().Str("user_id", userID).Str("verb", verb).Str("resource", resource).Str("bucket", bucket).Str("object", objectKey).Msg("S3 access denied: permission denied")
-		return "", ErrAccessDenied
-	}
-
 	return userID, nil
 }
 
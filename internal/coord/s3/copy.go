@@ -0,0 +1,95 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+// CopyObjectOptions controls how CopyObject builds the destination object.
+type CopyObjectOptions struct {
+	SourceVersionID string            // Copy this version instead of the current one
+	ReplaceMetadata bool              // Use ContentType/Metadata instead of the source's (x-amz-metadata-directive: REPLACE)
+	ContentType     string            // Destination content type when ReplaceMetadata is set
+	Metadata        map[string]string // Destination user metadata when ReplaceMetadata is set
+	ReplaceTags     bool              // Use Tags instead of the source's (x-amz-tagging-directive: REPLACE)
+	Tags            map[string]string // Destination tags when ReplaceTags is set
+}
+
+// CopyObject copies an object to a new bucket/key without reading its content.
+// The store is content-addressed, so the destination simply references the
+// source's CAS chunks (and erasure coding shards); no chunk data is written.
+// The destination gets a new version, and counts toward the destination
+// bucket's size and quota like any other write.
+func (s *Store) CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string, opts CopyObjectOptions) (*ObjectMeta, error) {
+	// Validate names (defense in depth)
+	for _, name := range []string{srcBucket, dstBucket} {
+		if err := validateName(name); err != nil {
+			return nil, fmt.Errorf("invalid bucket name: %w", err)
+		}
+	}
+	for _, name := range []string{srcKey, dstKey} {
+		if err := validateName(name); err != nil {
+			return nil, fmt.Errorf("invalid key: %w", err)
+		}
+	}
+	if err := validateVersionID(opts.SourceVersionID); err != nil {
+		return nil, err
+	}
+	if opts.ReplaceTags {
+		if err := validateTags(opts.Tags); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.getBucketMeta(srcBucket); err != nil {
+		return nil, err
+	}
+
+	src, err := s.getObjectVersionMeta(srcBucket, srcKey, opts.SourceVersionID)
+	if err != nil {
+		return nil, err
+	}
+
+	dst := &ObjectMeta{
+		Key:           dstKey,
+		Size:          src.Size,
+		ContentType:   src.ContentType,
+		ETag:          src.ETag,
+		LastModified:  time.Now().UTC(),
+		Metadata:      maps.Clone(src.Metadata),
+		Chunks:        slices.Clone(src.Chunks),
+		ChunkMetadata: maps.Clone(src.ChunkMetadata),
+		VersionVector: maps.Clone(src.VersionVector),
+		ErasureCoding: src.ErasureCoding,
+		PartSizes:     slices.Clone(src.PartSizes),
+		Tags:          maps.Clone(src.Tags),
+	}
+	if opts.ReplaceMetadata {
+		dst.ContentType = opts.ContentType
+		if dst.ContentType == "" {
+			dst.ContentType = "application/octet-stream"
+		}
+		dst.Metadata = maps.Clone(opts.Metadata)
+	}
+	if opts.ReplaceTags {
+		dst.Tags = normalizeTags(opts.Tags)
+	}
+
+	if err := s.commitObjectMeta(ctx, dstBucket, dstKey, dst); err != nil {
+		return nil, err
+	}
+
+	s.logger.Debug().
+		Str("src", srcBucket+"/"+srcKey).
+		Str("dst", dstBucket+"/"+dstKey).
+		Int("chunks", len(dst.Chunks)).
+		Msg("copied object by reference")
+
+	return dst, nil
+}
@@ -0,0 +1,437 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyObject_ReferencesSourceChunks(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "src", "alice", 2, nil))
+	require.NoError(t, store.CreateBucket(ctx, "dst", "alice", 2, nil))
+
+	data := makePartData(256*1024, 7)
+	src, err := store.PutObject(ctx, "src", "a.bin", bytes.NewReader(data), int64(len(data)), "application/x-test", map[string]string{"X-Amz-Meta-Owner": "alice"})
+	require.NoError(t, err)
+	require.NoError(t, store.PutObjectTagging(ctx, "src", "a.bin", map[string]string{"env": "prod"}))
+
+	chunksBefore := store.statsChunkCount.Load()
+
+	dst, err := store.CopyObject(ctx, "src", "a.bin", "dst", "copy/a.bin", CopyObjectOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, chunksBefore, store.statsChunkCount.Load(), "copy must not write chunks")
+	assert.Equal(t, src.Chunks, dst.Chunks)
+	assert.Equal(t, src.ETag, dst.ETag)
+	assert.Equal(t, "application/x-test", dst.ContentType)
+	assert.Equal(t, "alice", dst.Metadata["X-Amz-Meta-Owner"])
+	assert.Equal(t, map[string]string{"env": "prod"}, dst.Tags)
+	assert.NotEqual(t, src.VersionID, dst.VersionID)
+
+	reader, _, err := store.GetObject(ctx, "dst", "copy/a.bin")
+	require.NoError(t, err)
+	got, err := io.ReadAll(reader)
+	_ = reader.Close()
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+
+	// Deleting the source leaves the copy readable
+	require.NoError(t, store.DeleteObject(ctx, "src", "a.bin"))
+	reader, _, err = store.GetObject(ctx, "dst", "copy/a.bin")
+	require.NoError(t, err)
+	got, err = io.ReadAll(reader)
+	_ = reader.Close()
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+}
+
+func TestCopyObject_Directives(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+
+	_, err := store.PutObject(ctx, "bucket", "a.txt", strings.NewReader("hello"), 5, "text/plain", map[string]string{"X-Amz-Meta-A": "1"})
+	require.NoError(t, err)
+	require.NoError(t, store.PutObjectTagging(ctx, "bucket", "a.txt", map[string]string{"k": "v"}))
+
+	dst, err := store.CopyObject(ctx, "bucket", "a.txt", "bucket", "b.txt", CopyObjectOptions{
+		ReplaceMetadata: true,
+		ContentType:     "text/markdown",
+		Metadata:        map[string]string{"X-Amz-Meta-B": "2"},
+		ReplaceTags:     true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "text/markdown", dst.ContentType)
+	assert.Equal(t, map[string]string{"X-Amz-Meta-B": "2"}, dst.Metadata)
+	assert.Nil(t, dst.Tags)
+
+	// Invalid replacement tags are rejected before anything is written
+	tooMany := make(map[string]string)
+	for i := range MaxObjectTags + 1 {
+		tooMany[string(rune('a'+i))] = "x"
+	}
+	_, err = store.CopyObject(ctx, "bucket", "a.txt", "bucket", "c.txt", CopyObjectOptions{ReplaceTags: true, Tags: tooMany})
+	assert.ErrorIs(t, err, ErrInvalidTag)
+
+	_, err = store.CopyObject(ctx, "bucket", "missing", "bucket", "c.txt", CopyObjectOptions{})
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+	_, err = store.CopyObject(ctx, "nope", "a.txt", "bucket", "c.txt", CopyObjectOptions{})
+	assert.ErrorIs(t, err, ErrBucketNotFound)
+	_, err = store.CopyObject(ctx, "bucket", "a.txt", "nope", "c.txt", CopyObjectOptions{})
+	assert.ErrorIs(t, err, ErrBucketNotFound)
+}
+
+func TestCopyObject_SourceVersion(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+
+	v1, err := store.PutObject(ctx, "bucket", "doc", strings.NewReader("first"), 5, "text/plain", nil)
+	require.NoError(t, err)
+	_, err = store.PutObject(ctx, "bucket", "doc", strings.NewReader("second"), 6, "text/plain", nil)
+	require.NoError(t, err)
+
+	_, err = store.CopyObject(ctx, "bucket", "doc", "bucket", "old", CopyObjectOptions{SourceVersionID: v1.VersionID})
+	require.NoError(t, err)
+
+	reader, _, err := store.GetObject(ctx, "bucket", "old")
+	require.NoError(t, err)
+	got, _ := io.ReadAll(reader)
+	_ = reader.Close()
+	assert.Equal(t, "first", string(got))
+
+	_, err = store.CopyObject(ctx, "bucket", "doc", "bucket", "x", CopyObjectOptions{SourceVersionID: "no-such-version"})
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+}
+
+func TestCopyObject_ErasureCoded(t *testing.T) {
+	store := newTestStoreWithErasureCoding(t, 4, 2)
+	ctx := context.Background()
+
+	data := makePartData(300*1024, 3)
+	_, err := store.PutObject(ctx, "ec-bucket", "ec.bin", bytes.NewReader(data), int64(len(data)), "application/octet-stream", nil)
+	require.NoError(t, err)
+
+	dst, err := store.CopyObject(ctx, "ec-bucket", "ec.bin", "ec-bucket", "ec-copy.bin", CopyObjectOptions{})
+	require.NoError(t, err)
+	require.NotNil(t, dst.ErasureCoding)
+
+	assert.Equal(t, data, readRange(t, store, "ec-bucket", "ec-copy.bin", 0, int64(len(data))))
+}
+
+func TestCopyObject_QuotaEnforced(t *testing.T) {
+	masterKey := [32]byte{1, 2, 3}
+	store, err := NewStoreWithCAS(t.TempDir(), NewQuotaManager(8*1024), masterKey)
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+
+	data := makePartData(5*1024, 1)
+	_, err = store.PutObject(ctx, "bucket", "a", bytes.NewReader(data), int64(len(data)), "", nil)
+	require.NoError(t, err)
+
+	_, err = store.CopyObject(ctx, "bucket", "a", "bucket", "b", CopyObjectOptions{})
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+}
+
+func TestParseCopySource(t *testing.T) {
+	tests := []struct {
+		header                 string
+		bucket, key, versionID string
+		ok                     bool
+	}{
+		{"/bucket/key", "bucket", "key", "", true},
+		{"bucket/dir/key.txt", "bucket", "dir/key.txt", "", true},
+		{"/bucket/my%20file.txt", "bucket", "my file.txt", "", true},
+		{"/bucket/key?versionId=abc", "bucket", "key", "abc", true},
+		{"/bucket", "", "", "", false},
+		{"/bucket/", "", "", "", false},
+		{"", "", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			bucket, key, versionID, ok := parseCopySource(tt.header)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.bucket, bucket)
+			assert.Equal(t, tt.key, key)
+			assert.Equal(t, tt.versionID, versionID)
+		})
+	}
+}
+
+func TestServer_CopyObject(t *testing.T) {
+	server, store := newTestServer(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+	src, err := store.PutObject(ctx, "bucket", "src.txt", strings.NewReader("payload"), 7, "text/plain", nil)
+	require.NoError(t, err)
+
+	queue := &recordingReplicationQueue{}
+	server.SetReplicationQueue(queue)
+
+	req := httptest.NewRequest(http.MethodPut, "/bucket/dst.txt", nil)
+	req.Header.Set("x-amz-copy-source", "/bucket/src.txt")
+	req.Header.Set("x-amz-tagging-directive", "REPLACE")
+	req.Header.Set("x-amz-tagging", "team=ops")
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var result CopyObjectResult
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, src.ETag, result.ETag)
+	assert.Equal(t, []string{"bucket/dst.txt:put"}, queue.ops)
+
+	tags, err := store.GetObjectTagging(ctx, "bucket", "dst.txt")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "ops"}, tags)
+
+	// Copying an object onto itself without changes is rejected
+	req = httptest.NewRequest(http.MethodPut, "/bucket/src.txt", nil)
+	req.Header.Set("x-amz-copy-source", "bucket/src.txt")
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "InvalidRequest")
+
+	// Failed copy-source condition
+	req = httptest.NewRequest(http.MethodPut, "/bucket/other.txt", nil)
+	req.Header.Set("x-amz-copy-source", "/bucket/src.txt")
+	req.Header.Set("x-amz-copy-source-if-match", `"not-the-etag"`)
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	// Missing source
+	req = httptest.NewRequest(http.MethodPut, "/bucket/other.txt", nil)
+	req.Header.Set("x-amz-copy-source", "/bucket/missing.txt")
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "NoSuchKey")
+}
+
+func TestServer_CopyObjectChecksSourcePermission(t *testing.T) {
+	store := newTestStoreWithCASForServer(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+	_, err := store.PutObject(ctx, "bucket", "src.txt", strings.NewReader("payload"), 7, "text/plain", nil)
+	require.NoError(t, err)
+
+	server := NewServer(store, &mockAuthorizer{userID: "bob", allowVerb: map[string]bool{"put": true}}, nil)
+
+	req := httptest.NewRequest(http.MethodPut, "/bucket/dst.txt", nil)
+	req.Header.Set("x-amz-copy-source", "/bucket/src.txt")
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	_, err = store.HeadObject(ctx, "bucket", "dst.txt")
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+}
+
+func TestServer_DeleteObjects(t *testing.T) {
+	server, store := newTestServer(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+	for _, key := range []string{"a", "b", "dir/c"} {
+		_, err := store.PutObject(ctx, "bucket", key, strings.NewReader(key), int64(len(key)), "", nil)
+		require.NoError(t, err)
+	}
+
+	queue := &recordingReplicationQueue{}
+	server.SetReplicationQueue(queue)
+
+	body := `<Delete>
+  <Object><Key>a</Key></Object>
+  <Object><Key>dir/c</Key></Object>
+  <Object><Key>never-existed</Key></Object>
+</Delete>`
+	req := httptest.NewRequest(http.MethodPost, "/bucket?delete", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var result DeleteResult
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &result))
+	assert.Len(t, result.Deleted, 3, "deleting a missing key succeeds")
+	assert.Empty(t, result.Errors)
+	assert.Equal(t, []string{"bucket/a:delete", "bucket/dir/c:delete", "bucket/never-existed:delete"}, queue.ops)
+
+	_, err := store.HeadObject(ctx, "bucket", "a")
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+	_, err = store.HeadObject(ctx, "bucket", "b")
+	assert.NoError(t, err)
+
+	// Quiet mode only reports errors
+	req = httptest.NewRequest(http.MethodPost, "/bucket?delete", strings.NewReader(`<Delete><Quiet>true</Quiet><Object><Key>b</Key></Object></Delete>`))
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	result = DeleteResult{}
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &result))
+	assert.Empty(t, result.Deleted)
+	assert.Empty(t, result.Errors)
+
+	// Bad Content-MD5
+	req = httptest.NewRequest(http.MethodPost, "/bucket?delete", strings.NewReader(body))
+	req.Header.Set("Content-MD5", "AAAAAAAAAAAAAAAAAAAAAA==")
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "BadDigest")
+
+	// Too many keys
+	var many strings.Builder
+	many.WriteString("<Delete>")
+	for range MaxDeleteObjects + 1 {
+		many.WriteString("<Object><Key>k</Key></Object>")
+	}
+	many.WriteString("</Delete>")
+	req = httptest.NewRequest(http.MethodPost, "/bucket?delete", strings.NewReader(many.String()))
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "MalformedXML")
+}
+
+func TestServer_DeleteObjectsPerKeyPermissions(t *testing.T) {
+	store := newTestStoreWithCASForServer(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+	for _, key := range []string{"team/a", "other/b"} {
+		_, err := store.PutObject(ctx, "bucket", key, strings.NewReader("x"), 1, "", nil)
+		require.NoError(t, err)
+	}
+
+	server := NewServer(store, &prefixAuthorizer{userID: "bob", prefix: "team/"}, nil)
+
+	body := `<Delete><Object><Key>team/a</Key></Object><Object><Key>other/b</Key></Object></Delete>`
+	req := httptest.NewRequest(http.MethodPost, "/bucket?delete", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var result DeleteResult
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &result))
+	require.Len(t, result.Deleted, 1)
+	assert.Equal(t, "team/a", result.Deleted[0].Key)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "other/b", result.Errors[0].Key)
+	assert.Equal(t, "AccessDenied", result.Errors[0].Code)
+
+	_, err := store.HeadObject(ctx, "bucket", "other/b")
+	assert.NoError(t, err)
+}
+
+func TestServer_DeleteObjectsForwardsToPrimary(t *testing.T) {
+	ctx := context.Background()
+	server1, store1 := newTestServer(t)
+	server2, store2 := newTestServer(t)
+	for _, store := range []*Store{store1, store2} {
+		require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+	}
+	_, err := store1.PutObject(ctx, "bucket", "one/a", strings.NewReader("a"), 1, "", nil)
+	require.NoError(t, err)
+	_, err = store2.PutObject(ctx, "bucket", "two/b", strings.NewReader("b"), 1, "", nil)
+	require.NoError(t, err)
+
+	// Keys under one/ belong to coordinator 1 and two/ to coordinator 2. The
+	// coordinators disagree on split/, each thinking the other owns it.
+	owner := func(key string) string {
+		if strings.HasPrefix(key, "two/") {
+			return "coord2"
+		}
+		return "coord1"
+	}
+	peers := map[string]*Server{"coord1": server1, "coord2": server2}
+	server1.SetRequestForwarder(&fakeForwarder{self: "coord1", owner: func(key string) string {
+		if strings.HasPrefix(key, "split/") {
+			return "coord2"
+		}
+		return owner(key)
+	}, peers: peers})
+	server2.SetRequestForwarder(&fakeForwarder{self: "coord2", owner: owner, peers: peers})
+
+	body := `<Delete><Object><Key>one/a</Key></Object><Object><Key>two/b</Key></Object><Object><Key>split/c</Key></Object></Delete>`
+	req := httptest.NewRequest(http.MethodPost, "/bucket?delete", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	server1.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var result DeleteResult
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &result))
+	var deleted []string
+	for _, d := range result.Deleted {
+		deleted = append(deleted, d.Key)
+	}
+	assert.ElementsMatch(t, []string{"one/a", "two/b"}, deleted)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "split/c", result.Errors[0].Key)
+
+	// Each key was deleted on its primary only
+	_, err = store1.HeadObject(ctx, "bucket", "one/a")
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+	_, err = store2.HeadObject(ctx, "bucket", "two/b")
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+
+	// Quiet mode hides forwarded successes too
+	req = httptest.NewRequest(http.MethodPost, "/bucket?delete", strings.NewReader(`<Delete><Quiet>true</Quiet><Object><Key>two/b</Key></Object></Delete>`))
+	w = httptest.NewRecorder()
+	server1.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	result = DeleteResult{}
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &result))
+	assert.Empty(t, result.Deleted)
+	assert.Empty(t, result.Errors)
+}
+
+// fakeForwarder routes requests between in-process servers by key owner.
+type fakeForwarder struct {
+	self  string
+	owner func(key string) string
+	peers map[string]*Server
+}
+
+func (f *fakeForwarder) PrimaryCoordinator(bucket, key string) string {
+	if owner := f.owner(key); owner != f.self {
+		return owner
+	}
+	return ""
+}
+
+func (f *fakeForwarder) ForwardS3Request(w http.ResponseWriter, r *http.Request, bucket, key, port string) bool {
+	target := f.PrimaryCoordinator(bucket, key)
+	if r.Header.Get("X-TunnelMesh-Forwarded") != "" || target == "" {
+		return false
+	}
+	r.Header.Set("X-TunnelMesh-Forwarded", "true")
+	f.peers[target].Handler().ServeHTTP(w, r)
+	return true
+}
+
+// prefixAuthorizer allows bucket-level requests and object requests under prefix.
+type prefixAuthorizer struct {
+	userID string
+	prefix string
+}
+
+func (p *prefixAuthorizer) AuthorizeRequest(r *http.Request, verb, resource, bucket, objectKey string) (string, error) {
+	if objectKey == "" || strings.HasPrefix(objectKey, p.prefix) {
+		return p.userID, nil
+	}
+	return "", ErrAccessDenied
+}
+
+func (p *prefixAuthorizer) GetAllowedPrefixes(userID, bucket string) []string {
+	return []string{p.prefix}
+}
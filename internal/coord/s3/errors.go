@@ -4,11 +4,23 @@ import "errors"
 
 // S3 error types.
 var (
-	ErrBucketExists   = errors.New("bucket already exists")
-	ErrBucketNotFound = errors.New("bucket not found")
-	ErrBucketNotEmpty = errors.New("bucket not empty")
-	ErrObjectNotFound = errors.New("object not found")
-	ErrAccessDenied   = errors.New("access denied")
-	ErrInvalidRequest = errors.New("invalid request")
-	ErrQuotaExceeded  = errors.New("storage quota exceeded")
+	ErrBucketExists     = errors.New("bucket already exists")
+	ErrBucketNotFound   = errors.New("bucket not found")
+	ErrBucketNotEmpty   = errors.New("bucket not empty")
+	ErrObjectNotFound   = errors.New("object not found")
+	ErrAccessDenied     = errors.New("access denied")
+	ErrInvalidRequest   = errors.New("invalid request")
+	ErrQuotaExceeded    = errors.New("storage quota exceeded")
+	ErrNoSuchUpload     = errors.New("multipart upload not found")
+	ErrInvalidPart      = errors.New("invalid part")
+	ErrInvalidPartOrder = errors.New("parts not in ascending order")
+	ErrEntityTooSmall   = errors.New("part smaller than minimum allowed size")
+	ErrInvalidRange     = errors.New("requested range not satisfiable")
+	ErrInvalidTag       = errors.New("invalid object tag")
+	ErrDeleteMarker     = errors.New("object version is a delete marker")
+
+	ErrSignatureMismatch     = errors.New("request signature does not match")
+	ErrRequestTimeTooSkewed  = errors.New("request time too skewed")
+	ErrRequestExpired        = errors.New("presigned request has expired")
+	ErrContentSHA256Mismatch = errors.New("payload does not match x-amz-content-sha256")
 )
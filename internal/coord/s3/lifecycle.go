@@ -0,0 +1,310 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Lifecycle configuration limits (matching AWS S3).
+const (
+	MaxLifecycleRules      = 1000
+	MaxLifecycleRuleIDSize = 255
+)
+
+// LifecycleRule is a per-bucket expiry policy for keys under a prefix,
+// optionally only for objects carrying some tags. Rules are applied by garbage
+// collection; when several enabled rules match a key, the shortest period for
+// each action wins.
+type LifecycleRule struct {
+	ID      string            `json:"id,omitempty"`
+	Prefix  string            `json:"prefix,omitempty"` // Empty matches every key
+	Tags    map[string]string `json:"tags,omitempty"`   // Objects must carry all of these tags
+	Enabled bool              `json:"enabled"`
+
+	// ExpirationDays expires current objects this many days after they were
+	// last modified. Expiry behaves like a plain delete: a delete marker with
+	// versioning Enabled, otherwise the object goes to the recycle bin.
+	ExpirationDays int `json:"expiration_days,omitempty"`
+	// ExpiredObjectDeleteMarker removes delete markers that no longer hide
+	// any version.
+	ExpiredObjectDeleteMarker bool `json:"expired_object_delete_marker,omitempty"`
+	// NoncurrentVersionExpirationDays removes archived versions this many
+	// days after a newer version replaced them.
+	NoncurrentVersionExpirationDays int `json:"noncurrent_version_expiration_days,omitempty"`
+	// AbortIncompleteUploadDays aborts multipart uploads this many days after
+	// they were initiated.
+	AbortIncompleteUploadDays int `json:"abort_incomplete_upload_days,omitempty"`
+	// SkipRecycleBin purges deleted and expired objects permanently instead
+	// of keeping them for the recycle bin retention period.
+	SkipRecycleBin bool `json:"skip_recycle_bin,omitempty"`
+}
+
+// matches reports whether an enabled rule applies to key, an object or
+// version with the given tags.
+func (r LifecycleRule) matches(key string, tags map[string]string) bool {
+	if !r.Enabled || !strings.HasPrefix(key, r.Prefix) {
+		return false
+	}
+	for k, v := range r.Tags {
+		if got, ok := tags[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+// validateLifecycleRules checks a lifecycle configuration against the S3 limits.
+func validateLifecycleRules(rules []LifecycleRule) error {
+	if len(rules) > MaxLifecycleRules {
+		return fmt.Errorf("%w: lifecycle configuration cannot have more than %d rules", ErrInvalidRequest, MaxLifecycleRules)
+	}
+
+	ids := make(map[string]struct{}, len(rules))
+	for _, rule := range rules {
+		if len(rule.ID) > MaxLifecycleRuleIDSize {
+			return fmt.Errorf("%w: rule ID cannot be longer than %d characters", ErrInvalidRequest, MaxLifecycleRuleIDSize)
+		}
+		if rule.ID != "" {
+			if _, dup := ids[rule.ID]; dup {
+				return fmt.Errorf("%w: rule ID %q is not unique", ErrInvalidRequest, rule.ID)
+			}
+			ids[rule.ID] = struct{}{}
+		}
+		if err := validateTags(rule.Tags); err != nil {
+			return err
+		}
+		// Uploads and delete markers have no tags to filter on, as in S3
+		if len(rule.Tags) > 0 && (rule.AbortIncompleteUploadDays > 0 || rule.ExpiredObjectDeleteMarker) {
+			return fmt.Errorf("%w: rule %q cannot filter on tags with AbortIncompleteMultipartUpload or ExpiredObjectDeleteMarker", ErrInvalidRequest, rule.ID)
+		}
+		if rule.ExpirationDays < 0 || rule.NoncurrentVersionExpirationDays < 0 || rule.AbortIncompleteUploadDays < 0 {
+			return fmt.Errorf("%w: lifecycle days must be positive", ErrInvalidRequest)
+		}
+		if rule.ExpirationDays == 0 && !rule.ExpiredObjectDeleteMarker && rule.NoncurrentVersionExpirationDays == 0 &&
+			rule.AbortIncompleteUploadDays == 0 && !rule.SkipRecycleBin {
+			return fmt.Errorf("%w: rule %q must specify at least one action", ErrInvalidRequest, rule.ID)
+		}
+	}
+	return nil
+}
+
+// lifecycleDays returns the shortest positive period that the enabled rules
+// matching key and tags set for an action, or 0 if none does.
+func lifecycleDays(rules []LifecycleRule, key string, tags map[string]string, days func(LifecycleRule) int) int {
+	shortest := 0
+	for _, rule := range rules {
+		if d := days(rule); d > 0 && rule.matches(key, tags) && (shortest == 0 || d < shortest) {
+			shortest = d
+		}
+	}
+	return shortest
+}
+
+// lifecycleFlag reports whether any enabled rule matching key and tags sets a
+// flag.
+func lifecycleFlag(rules []LifecycleRule, key string, tags map[string]string, flag func(LifecycleRule) bool) bool {
+	for _, rule := range rules {
+		if flag(rule) && rule.matches(key, tags) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetBucketLifecycle replaces a bucket's lifecycle rules. Passing no rules
+// removes the configuration.
+func (s *Store) SetBucketLifecycle(ctx context.Context, bucket string, rules []LifecycleRule) error {
+	if err := validateLifecycleRules(rules); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	meta, err := s.getBucketMeta(bucket)
+	if err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		rules = nil
+	}
+	meta.Lifecycle = rules
+	return s.writeBucketMeta(bucket, meta)
+}
+
+// GetBucketLifecycle returns a bucket's lifecycle rules (nil if unconfigured).
+func (s *Store) GetBucketLifecycle(ctx context.Context, bucket string) ([]LifecycleRule, error) {
+	meta, err := s.HeadBucket(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	return meta.Lifecycle, nil
+}
+
+// applyLifecycleRules expires current objects and noncurrent versions
+// according to each bucket's lifecycle rules, as of now.
+// Returns chunks from removed versions that may now be unreferenced; the
+// caller is responsible for deleting the unreferenced ones.
+func (s *Store) applyLifecycleRules(ctx context.Context, now time.Time, stats *GCStats) []string {
+	buckets, err := s.ListBuckets(ctx)
+	if err != nil {
+		return nil
+	}
+
+	var allChunksToCheck []string
+	for _, bucket := range buckets {
+		if ctx.Err() != nil {
+			break
+		}
+		if len(bucket.Lifecycle) == 0 || bucket.Name == SystemBucket {
+			continue
+		}
+
+		s.mu.RLock()
+		keys, err := s.versionedKeys(bucket.Name, "")
+		s.mu.RUnlock()
+		if err != nil {
+			s.logger.Warn().Err(err).Str("bucket", bucket.Name).Msg("failed to list keys for lifecycle rules")
+			continue
+		}
+
+		for _, key := range keys {
+			if ctx.Err() != nil {
+				break
+			}
+			if s.expireObject(ctx, &bucket, key, now) {
+				stats.ObjectsExpired++
+				continue
+			}
+
+			s.mu.Lock()
+			pruned, chunksToCheck := s.expireNoncurrentVersions(bucket.Name, key, bucket.Lifecycle, now)
+			s.mu.Unlock()
+
+			stats.VersionsPruned += pruned
+			allChunksToCheck = append(allChunksToCheck, chunksToCheck...)
+		}
+	}
+
+	return allChunksToCheck
+}
+
+// expireObject deletes the current version of key if a lifecycle rule says it
+// has expired. The rules and object are re-read and the delete made under one
+// hold of the lock, so an object overwritten or reconfigured since the bucket
+// was listed is judged as it is now. Returns true if the object was expired.
+func (s *Store) expireObject(ctx context.Context, bucket *BucketMeta, key string, now time.Time) bool {
+	s.mu.RLock()
+	live := s.expiredObject(bucket, key, now)
+	s.mu.RUnlock()
+	if live == nil {
+		return false
+	}
+
+	s.mu.Lock()
+	meta, err := s.getBucketMeta(bucket.Name)
+	if err == nil {
+		live = s.expiredObject(meta, key, now)
+	}
+	if live == nil {
+		s.mu.Unlock()
+		return false
+	}
+
+	var chunksToCheck []string
+	switch {
+	case meta.Versioning == VersioningEnabled:
+		_, err = s.addDeleteMarker(ctx, bucket.Name, key)
+	case lifecycleFlag(meta.Lifecycle, key, live.Tags, func(r LifecycleRule) bool { return r.SkipRecycleBin }):
+		chunksToCheck, err = s.purgeObjectMeta(bucket.Name, key)
+	default:
+		err = s.recycleObject(bucket.Name, key)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		s.logger.Warn().Err(err).Str("bucket", bucket.Name).Str("key", key).Msg("failed to expire object")
+		return false
+	}
+
+	s.DeleteUnreferencedChunks(ctx, chunksToCheck)
+	return true
+}
+
+// expiredObject returns the current version of key if the bucket's lifecycle
+// rules have expired it, or nil (caller must hold lock).
+func (s *Store) expiredObject(bucket *BucketMeta, key string, now time.Time) *ObjectMeta {
+	if !slices.ContainsFunc(bucket.Lifecycle, func(r LifecycleRule) bool { return r.ExpirationDays > 0 }) {
+		return nil
+	}
+	live, err := s.getObjectMeta(bucket.Name, key)
+	if err != nil {
+		return nil
+	}
+	days := lifecycleDays(bucket.Lifecycle, key, live.Tags, func(r LifecycleRule) int { return r.ExpirationDays })
+	if days == 0 || !live.LastModified.AddDate(0, 0, days).Before(now) {
+		return nil
+	}
+	return live
+}
+
+// expireNoncurrentVersions removes archived versions of key that have been
+// noncurrent for longer than the matching lifecycle rules allow, and a delete
+// marker left with nothing to hide if a rule asks for that (caller must hold
+// lock). Keys without a current version or delete marker are in the recycle
+// bin and left alone.
+// Returns the number of versions removed and their chunks.
+func (s *Store) expireNoncurrentVersions(bucket, key string, rules []LifecycleRule, now time.Time) (int, []string) {
+	expireVersions := slices.ContainsFunc(rules, func(r LifecycleRule) bool { return r.NoncurrentVersionExpirationDays > 0 })
+	removeMarker := lifecycleFlag(rules, key, nil, func(r LifecycleRule) bool { return r.ExpiredObjectDeleteMarker })
+	if !expireVersions && !removeMarker {
+		return 0, nil
+	}
+
+	archived := s.listArchivedVersions(bucket, key)
+	live, err := s.getObjectMeta(bucket, key)
+	var newer ObjectMeta
+	switch {
+	case err == nil:
+		newer = *live
+	case len(archived) > 0 && archived[0].DeleteMarker:
+		newer, archived = archived[0], archived[1:]
+	default:
+		return 0, nil
+	}
+	marker := newer
+
+	pruned := 0
+	var chunksToCheck []string
+	remaining := len(archived)
+	for _, v := range archived {
+		// A version becomes noncurrent when the next newer one is written
+		noncurrentSince := newer.LastModified
+		newer = v
+		days := lifecycleDays(rules, key, v.Tags, func(r LifecycleRule) int { return r.NoncurrentVersionExpirationDays })
+		if days == 0 || !noncurrentSince.AddDate(0, 0, days).Before(now) {
+			continue
+		}
+		if err := os.Remove(s.versionMetaPath(bucket, key, v.VersionID)); err != nil {
+			s.logger.Warn().Err(err).Str("bucket", bucket).Str("key", key).Str("version", v.VersionID).Msg("failed to expire noncurrent version")
+			continue
+		}
+		s.statsVersionCount.Add(-1)
+		s.statsVersionBytes.Add(-v.Size)
+		chunksToCheck = append(chunksToCheck, v.Chunks...)
+		pruned++
+		remaining--
+	}
+
+	if removeMarker && live == nil && remaining == 0 {
+		if err := os.Remove(s.versionMetaPath(bucket, key, marker.VersionID)); err == nil {
+			s.statsVersionCount.Add(-1)
+			pruned++
+		}
+	}
+
+	return pruned, chunksToCheck
+}
@@ -0,0 +1,369 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLifecycle_ExpiresCurrentObjectsByPrefix(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+	require.NoError(t, store.SetBucketLifecycle(ctx, "bucket", []LifecycleRule{
+		{ID: "logs", Prefix: "logs/", Enabled: true, ExpirationDays: 30},
+		{ID: "tmp", Prefix: "tmp/", Enabled: true, ExpirationDays: 1, SkipRecycleBin: true},
+		{ID: "off", Enabled: false, ExpirationDays: 1},
+	}))
+
+	for _, key := range []string{"logs/a", "tmp/b", "keep"} {
+		_, err := store.PutObject(ctx, "bucket", key, strings.NewReader("data"), 4, "", nil)
+		require.NoError(t, err)
+	}
+
+	// Nothing is old enough yet
+	stats := GCStats{}
+	store.applyLifecycleRules(ctx, time.Now().UTC(), &stats)
+	assert.Equal(t, 0, stats.ObjectsExpired)
+
+	stats = GCStats{}
+	store.applyLifecycleRules(ctx, time.Now().UTC().AddDate(0, 0, 31), &stats)
+	assert.Equal(t, 2, stats.ObjectsExpired)
+
+	for _, key := range []string{"logs/a", "tmp/b"} {
+		_, err := store.HeadObject(ctx, "bucket", key)
+		assert.ErrorIs(t, err, ErrObjectNotFound, key)
+	}
+	_, err := store.HeadObject(ctx, "bucket", "keep")
+	assert.NoError(t, err)
+
+	// Only the rule without SkipRecycleBin leaves the object recoverable
+	recycled, err := store.ListRecycledObjects(ctx, "bucket")
+	require.NoError(t, err)
+	require.Len(t, recycled, 1)
+	assert.Equal(t, "logs/a", recycled[0].OriginalKey)
+}
+
+func TestLifecycle_VersionedBucketCreatesDeleteMarker(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+	require.NoError(t, store.SetBucketVersioning(ctx, "bucket", VersioningEnabled))
+	require.NoError(t, store.SetBucketLifecycle(ctx, "bucket", []LifecycleRule{
+		{Enabled: true, ExpirationDays: 7},
+	}))
+
+	_, err := store.PutObject(ctx, "bucket", "doc", strings.NewReader("data"), 4, "", nil)
+	require.NoError(t, err)
+
+	stats := GCStats{}
+	store.applyLifecycleRules(ctx, time.Now().UTC().AddDate(0, 0, 8), &stats)
+	assert.Equal(t, 1, stats.ObjectsExpired)
+
+	_, ok := store.LatestDeleteMarker(ctx, "bucket", "doc")
+	assert.True(t, ok)
+	versions, err := store.ListVersions(ctx, "bucket", "doc")
+	require.NoError(t, err)
+	assert.Len(t, versions, 2)
+}
+
+func TestLifecycle_TagFilters(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+	require.NoError(t, store.SetBucketLifecycle(ctx, "bucket", []LifecycleRule{
+		{ID: "scratch", Prefix: "data/", Tags: map[string]string{"class": "scratch", "team": "ml"}, Enabled: true, ExpirationDays: 1},
+	}))
+
+	objects := map[string]map[string]string{
+		"data/match":    {"class": "scratch", "team": "ml", "extra": "x"},
+		"data/partial":  {"class": "scratch"},
+		"data/other":    {"class": "scratch", "team": "web"},
+		"data/untagged": nil,
+		"logs/match":    {"class": "scratch", "team": "ml"},
+	}
+	for key, tags := range objects {
+		_, err := store.PutObject(ctx, "bucket", key, strings.NewReader("data"), 4, "", nil)
+		require.NoError(t, err)
+		if tags != nil {
+			require.NoError(t, store.PutObjectTagging(ctx, "bucket", key, tags))
+		}
+	}
+
+	stats := GCStats{}
+	store.applyLifecycleRules(ctx, time.Now().UTC().AddDate(0, 0, 2), &stats)
+	assert.Equal(t, 1, stats.ObjectsExpired)
+	for key := range objects {
+		_, err := store.HeadObject(ctx, "bucket", key)
+		if key == "data/match" {
+			assert.ErrorIs(t, err, ErrObjectNotFound, key)
+		} else {
+			assert.NoError(t, err, key)
+		}
+	}
+
+	// Uploads and delete markers have no tags to filter on
+	for _, rule := range []LifecycleRule{
+		{Tags: map[string]string{"a": "b"}, Enabled: true, AbortIncompleteUploadDays: 1},
+		{Tags: map[string]string{"a": "b"}, Enabled: true, ExpiredObjectDeleteMarker: true},
+	} {
+		assert.ErrorIs(t, store.SetBucketLifecycle(ctx, "bucket", []LifecycleRule{rule}), ErrInvalidRequest)
+	}
+	err := store.SetBucketLifecycle(ctx, "bucket", []LifecycleRule{{Tags: map[string]string{"": "b"}, Enabled: true, ExpirationDays: 1}})
+	assert.ErrorIs(t, err, ErrInvalidTag)
+}
+
+func TestLifecycle_ExpiryRechecksObjectAndRules(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+	require.NoError(t, store.SetBucketVersioning(ctx, "bucket", VersioningEnabled))
+	require.NoError(t, store.SetBucketLifecycle(ctx, "bucket", []LifecycleRule{
+		{Enabled: true, ExpirationDays: 7},
+	}))
+	gone, err := store.PutObject(ctx, "bucket", "gone", strings.NewReader("data"), 4, "", nil)
+	require.NoError(t, err)
+	_, err = store.PutObject(ctx, "bucket", "kept", strings.NewReader("data"), 4, "", nil)
+	require.NoError(t, err)
+
+	// The bucket as GC listed it, before the changes below
+	snapshot, err := store.HeadBucket(ctx, "bucket")
+	require.NoError(t, err)
+	later := time.Now().UTC().AddDate(0, 0, 8)
+
+	// An object removed since then gets no delete marker
+	_, err = store.DeleteObjectVersion(ctx, "bucket", "gone", gone.VersionID)
+	require.NoError(t, err)
+	assert.False(t, store.expireObject(ctx, snapshot, "gone", later))
+	_, ok := store.LatestDeleteMarker(ctx, "bucket", "gone")
+	assert.False(t, ok)
+
+	// Nor is an object expired by a rule removed since then
+	require.NoError(t, store.SetBucketLifecycle(ctx, "bucket", nil))
+	assert.False(t, store.expireObject(ctx, snapshot, "kept", later))
+	_, err = store.HeadObject(ctx, "bucket", "kept")
+	assert.NoError(t, err)
+}
+
+func TestLifecycle_ExpiresNoncurrentVersions(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+	require.NoError(t, store.SetBucketVersioning(ctx, "bucket", VersioningEnabled))
+	require.NoError(t, store.SetBucketLifecycle(ctx, "bucket", []LifecycleRule{
+		{Enabled: true, NoncurrentVersionExpirationDays: 10, ExpiredObjectDeleteMarker: true},
+	}))
+
+	for _, body := range []string{"one", "two", "three"} {
+		_, err := store.PutObject(ctx, "bucket", "doc", strings.NewReader(body), int64(len(body)), "", nil)
+		require.NoError(t, err)
+	}
+	current, err := store.HeadObject(ctx, "bucket", "doc")
+	require.NoError(t, err)
+
+	stats := GCStats{}
+	chunks := store.applyLifecycleRules(ctx, time.Now().UTC().AddDate(0, 0, 11), &stats)
+	assert.Equal(t, 2, stats.VersionsPruned)
+	assert.NotEmpty(t, chunks)
+
+	versions, err := store.ListVersions(ctx, "bucket", "doc")
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	assert.Equal(t, current.VersionID, versions[0].VersionID)
+
+	// Once the hidden version expires, the lone delete marker goes too
+	_, err = store.DeleteObjectVersion(ctx, "bucket", "doc", "")
+	require.NoError(t, err)
+	stats = GCStats{}
+	store.applyLifecycleRules(ctx, time.Now().UTC().AddDate(0, 0, 11), &stats)
+	assert.Equal(t, 2, stats.VersionsPruned)
+
+	keys, truncated, _, _, err := store.ListObjectVersions(ctx, "bucket", "", "", "", 100)
+	require.NoError(t, err)
+	assert.False(t, truncated)
+	assert.Empty(t, keys)
+}
+
+func TestLifecycle_AbortsIncompleteUploads(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+	require.NoError(t, store.SetBucketLifecycle(ctx, "bucket", []LifecycleRule{
+		{Prefix: "uploads/", Enabled: true, AbortIncompleteUploadDays: 1},
+	}))
+
+	// Backdate two uploads; only the one under the rule's prefix is aborted
+	for _, key := range []string{"uploads/big", "other/big"} {
+		upload, err := store.CreateMultipartUpload(ctx, "bucket", key, "", nil, "alice")
+		require.NoError(t, err)
+		_, err = store.UploadPart(ctx, "bucket", key, upload.UploadID, 1, bytes.NewReader(makePartData(1024, 1)))
+		require.NoError(t, err)
+
+		upload.Initiated = upload.Initiated.AddDate(0, 0, -2)
+		data, err := json.Marshal(upload)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(store.uploadMetaPath("bucket", upload.UploadID), data, 0644))
+	}
+
+	stats := store.RunGarbageCollectionForce(ctx)
+	assert.Equal(t, 1, stats.UploadsAborted)
+
+	uploads, err := store.ListMultipartUploads(ctx, "bucket", "")
+	require.NoError(t, err)
+	require.Len(t, uploads, 1)
+	assert.Equal(t, "other/big", uploads[0].Key)
+}
+
+func TestLifecycle_SkipRecycleBinPurgesDeletes(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+	store.SetRecycleBinRetentionDays(30)
+	require.NoError(t, store.SetBucketLifecycle(ctx, "bucket", []LifecycleRule{
+		{Prefix: "scratch/", Enabled: true, SkipRecycleBin: true},
+	}))
+
+	for _, key := range []string{"scratch/a", "docs/b"} {
+		_, err := store.PutObject(ctx, "bucket", key, strings.NewReader("data"), 4, "", nil)
+		require.NoError(t, err)
+		require.NoError(t, store.DeleteObject(ctx, "bucket", key))
+	}
+
+	assert.Equal(t, 1, store.PurgeRecycleBin(ctx))
+
+	recycled, err := store.ListRecycledObjects(ctx, "bucket")
+	require.NoError(t, err)
+	require.Len(t, recycled, 1)
+	assert.Equal(t, "docs/b", recycled[0].OriginalKey)
+}
+
+func TestSetBucketLifecycle_Validation(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+
+	err := store.SetBucketLifecycle(ctx, "bucket", []LifecycleRule{{ID: "noop", Enabled: true}})
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+	err = store.SetBucketLifecycle(ctx, "bucket", []LifecycleRule{
+		{ID: "dup", Enabled: true, ExpirationDays: 1},
+		{ID: "dup", Enabled: true, ExpirationDays: 2},
+	})
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+	err = store.SetBucketLifecycle(ctx, "missing", []LifecycleRule{{Enabled: true, ExpirationDays: 1}})
+	assert.ErrorIs(t, err, ErrBucketNotFound)
+
+	require.NoError(t, store.SetBucketLifecycle(ctx, "bucket", []LifecycleRule{{Enabled: true, ExpirationDays: 1}}))
+	require.NoError(t, store.SetBucketLifecycle(ctx, "bucket", nil))
+	rules, err := store.GetBucketLifecycle(ctx, "bucket")
+	require.NoError(t, err)
+	assert.Nil(t, rules)
+}
+
+func TestServer_BucketLifecycle(t *testing.T) {
+	server, store := newTestServer(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodGet, "/bucket?lifecycle", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "NoSuchLifecycleConfiguration")
+
+	w = do(http.MethodPut, "/bucket?lifecycle", `<LifecycleConfiguration>
+  <Rule>
+    <ID>logs</ID>
+    <Filter><Prefix>logs/</Prefix></Filter>
+    <Status>Enabled</Status>
+    <Expiration><Days>30</Days></Expiration>
+    <NoncurrentVersionExpiration><NoncurrentDays>7</NoncurrentDays></NoncurrentVersionExpiration>
+    <AbortIncompleteMultipartUpload><DaysAfterInitiation>2</DaysAfterInitiation></AbortIncompleteMultipartUpload>
+  </Rule>
+  <Rule>
+    <Prefix>tmp/</Prefix>
+    <Status>Disabled</Status>
+    <SkipRecycleBin>true</SkipRecycleBin>
+  </Rule>
+</LifecycleConfiguration>`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	rules, err := store.GetBucketLifecycle(ctx, "bucket")
+	require.NoError(t, err)
+	assert.Equal(t, []LifecycleRule{
+		{ID: "logs", Prefix: "logs/", Enabled: true, ExpirationDays: 30, NoncurrentVersionExpirationDays: 7, AbortIncompleteUploadDays: 2},
+		{Prefix: "tmp/", SkipRecycleBin: true},
+	}, rules)
+
+	w = do(http.MethodGet, "/bucket?lifecycle", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var cfg LifecycleConfiguration
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &cfg))
+	got, err := cfg.lifecycleRules()
+	require.NoError(t, err)
+	assert.Equal(t, rules, got)
+
+	// Tag filters, alone or combined with And
+	w = do(http.MethodPut, "/bucket?lifecycle", `<LifecycleConfiguration>
+  <Rule>
+    <ID>tag</ID>
+    <Filter><Tag><Key>class</Key><Value>scratch</Value></Tag></Filter>
+    <Status>Enabled</Status>
+    <Expiration><Days>1</Days></Expiration>
+  </Rule>
+  <Rule>
+    <ID>and</ID>
+    <Filter><And><Prefix>data/</Prefix><Tag><Key>team</Key><Value>ml</Value></Tag><Tag><Key>class</Key><Value>raw</Value></Tag></And></Filter>
+    <Status>Enabled</Status>
+    <NoncurrentVersionExpiration><NoncurrentDays>3</NoncurrentDays></NoncurrentVersionExpiration>
+  </Rule>
+</LifecycleConfiguration>`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	rules, err = store.GetBucketLifecycle(ctx, "bucket")
+	require.NoError(t, err)
+	assert.Equal(t, []LifecycleRule{
+		{ID: "tag", Tags: map[string]string{"class": "scratch"}, Enabled: true, ExpirationDays: 1},
+		{ID: "and", Prefix: "data/", Tags: map[string]string{"class": "raw", "team": "ml"}, Enabled: true, NoncurrentVersionExpirationDays: 3},
+	}, rules)
+
+	w = do(http.MethodGet, "/bucket?lifecycle", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "<Filter><Tag><Key>class</Key><Value>scratch</Value></Tag></Filter>")
+	cfg = LifecycleConfiguration{}
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &cfg))
+	got, err = cfg.lifecycleRules()
+	require.NoError(t, err)
+	assert.Equal(t, rules, got)
+
+	// Unsupported or invalid rules are rejected
+	w = do(http.MethodPut, "/bucket?lifecycle", `<LifecycleConfiguration><Rule><Filter><Prefix>a/</Prefix><Tag><Key>a</Key><Value>b</Value></Tag></Filter><Status>Enabled</Status><Expiration><Days>1</Days></Expiration></Rule></LifecycleConfiguration>`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = do(http.MethodPut, "/bucket?lifecycle", `<LifecycleConfiguration><Rule><Filter><And><Tag><Key>a</Key><Value>b</Value></Tag><Tag><Key>a</Key><Value>c</Value></Tag></And></Filter><Status>Enabled</Status><Expiration><Days>1</Days></Expiration></Rule></LifecycleConfiguration>`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "InvalidTag")
+	w = do(http.MethodPut, "/bucket?lifecycle", `<LifecycleConfiguration><Rule><Filter><Tag><Key>a</Key><Value>b</Value></Tag></Filter><Status>Enabled</Status><AbortIncompleteMultipartUpload><DaysAfterInitiation>1</DaysAfterInitiation></AbortIncompleteMultipartUpload></Rule></LifecycleConfiguration>`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = do(http.MethodPut, "/bucket?lifecycle", `<LifecycleConfiguration><Rule><Status>On</Status><Expiration><Days>1</Days></Expiration></Rule></LifecycleConfiguration>`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = do(http.MethodPut, "/bucket?lifecycle", `<LifecycleConfiguration></LifecycleConfiguration>`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = do(http.MethodDelete, "/bucket?lifecycle", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = do(http.MethodGet, "/bucket?lifecycle", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,569 @@
+package s3
+
+import (
+	"context"
+	"crypto/md5"
+	cryptorand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MinPartSize is the minimum size of every part except the last one,
+// matching the S3 limit enforced by CompleteMultipartUpload.
+const MinPartSize = 5 * 1024 * 1024 // 5 MiB
+
+// MaxPartNumber is the highest part number a client may upload.
+const MaxPartNumber = 10000
+
+// DefaultMultipartUploadExpiry is how long an incomplete multipart upload is
+// kept before the GC loop aborts it and releases its chunks.
+const DefaultMultipartUploadExpiry = 7 * 24 * time.Hour
+
+// MultipartUpload describes an in-progress multipart upload.
+type MultipartUpload struct {
+	UploadID    string            `json:"upload_id"`
+	Bucket      string            `json:"bucket"`
+	Key         string            `json:"key"`
+	ContentType string            `json:"content_type"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Initiator   string            `json:"initiator,omitempty"` // User ID that created the upload
+	Initiated   time.Time         `json:"initiated"`
+}
+
+// PartMeta describes one uploaded part. Parts are chunked into CAS exactly like
+// PutObject bodies, so identical content dedupes against existing objects.
+type PartMeta struct {
+	PartNumber    int                       `json:"part_number"`
+	Size          int64                     `json:"size"`
+	ETag          string                    `json:"etag"` // MD5 of part content
+	LastModified  time.Time                 `json:"last_modified"`
+	Chunks        []string                  `json:"chunks,omitempty"`
+	ChunkMetadata map[string]*ChunkMetadata `json:"chunk_metadata,omitempty"`
+}
+
+// CompletedPart identifies a part to include when completing an upload.
+type CompletedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+// SetMultipartUploadExpiry sets how long incomplete multipart uploads are kept
+// before GC aborts them. A value <= 0 restores DefaultMultipartUploadExpiry.
+func (s *Store) SetMultipartUploadExpiry(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.multipartUploadExpiry = d
+}
+
+// uploadsPath returns the directory holding a bucket's in-progress uploads.
+func (s *Store) uploadsPath(bucket string) string {
+	return filepath.Join(s.bucketPath(bucket), "uploads")
+}
+
+// uploadDir returns the directory for a single multipart upload.
+func (s *Store) uploadDir(bucket, uploadID string) string {
+	return filepath.Join(s.uploadsPath(bucket), uploadID)
+}
+
+// uploadMetaPath returns the path to a multipart upload's metadata file.
+func (s *Store) uploadMetaPath(bucket, uploadID string) string {
+	return filepath.Join(s.uploadDir(bucket, uploadID), "upload.json")
+}
+
+// partMetaPath returns the path to a part's metadata file.
+func (s *Store) partMetaPath(bucket, uploadID string, partNumber int) string {
+	return filepath.Join(s.uploadDir(bucket, uploadID), "parts", fmt.Sprintf("%05d.json", partNumber))
+}
+
+// validateUploadID ensures an upload ID is one we generated (hex only), which
+// also rules out path traversal through the uploadId query parameter.
+func validateUploadID(uploadID string) error {
+	if len(uploadID) != 32 {
+		return ErrNoSuchUpload
+	}
+	if _, err := hex.DecodeString(uploadID); err != nil {
+		return ErrNoSuchUpload
+	}
+	return nil
+}
+
+// CreateMultipartUpload starts a new multipart upload for bucket/key.
+func (s *Store) CreateMultipartUpload(ctx context.Context, bucket, key, contentType string, metadata map[string]string, initiator string) (*MultipartUpload, error) {
+	if err := validateName(bucket); err != nil {
+		return nil, fmt.Errorf("invalid bucket name: %w", err)
+	}
+	if err := validateName(key); err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+	if s.cas == nil {
+		return nil, fmt.Errorf("CAS not initialized - call InitCAS first")
+	}
+
+	var idBytes [16]byte
+	if _, err := cryptorand.Read(idBytes[:]); err != nil {
+		return nil, fmt.Errorf("generate upload ID: %w", err)
+	}
+
+	upload := MultipartUpload{
+		UploadID:    hex.EncodeToString(idBytes[:]),
+		Bucket:      bucket,
+		Key:         key,
+		ContentType: contentType,
+		Metadata:    metadata,
+		Initiator:   initiator,
+		Initiated:   time.Now().UTC(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.getBucketMeta(bucket); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Join(s.uploadDir(bucket, upload.UploadID), "parts"), 0755); err != nil {
+		return nil, fmt.Errorf("create upload dir: %w", err)
+	}
+
+	data, err := json.MarshalIndent(upload, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal upload meta: %w", err)
+	}
+	if err := syncedWriteFile(s.uploadMetaPath(bucket, upload.UploadID), data, 0644); err != nil {
+		return nil, fmt.Errorf("write upload meta: %w", err)
+	}
+
+	return &upload, nil
+}
+
+// getUpload reads multipart upload metadata and checks it belongs to key
+// (caller must hold lock).
+func (s *Store) getUpload(bucket, key, uploadID string) (*MultipartUpload, error) {
+	if err := validateUploadID(uploadID); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(s.uploadMetaPath(bucket, uploadID))
+	if os.IsNotExist(err) {
+		return nil, ErrNoSuchUpload
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read upload meta: %w", err)
+	}
+
+	var upload MultipartUpload
+	if err := json.Unmarshal(data, &upload); err != nil {
+		return nil, fmt.Errorf("unmarshal upload meta: %w", err)
+	}
+	if upload.Key != key {
+		return nil, ErrNoSuchUpload
+	}
+	return &upload, nil
+}
+
+// getPart reads part metadata (caller must hold lock).
+func (s *Store) getPart(bucket, uploadID string, partNumber int) (*PartMeta, error) {
+	data, err := os.ReadFile(s.partMetaPath(bucket, uploadID, partNumber))
+	if os.IsNotExist(err) {
+		return nil, ErrInvalidPart
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read part meta: %w", err)
+	}
+
+	var part PartMeta
+	if err := json.Unmarshal(data, &part); err != nil {
+		return nil, fmt.Errorf("unmarshal part meta: %w", err)
+	}
+	return &part, nil
+}
+
+// uploadedBytes sums the sizes of all parts uploaded so far, skipping
+// excludePart (caller must hold lock).
+func (s *Store) uploadedBytes(bucket, uploadID string, excludePart int) int64 {
+	parts, err := s.listPartsUnsafe(bucket, uploadID)
+	if err != nil {
+		return 0
+	}
+	var total int64
+	for _, p := range parts {
+		if p.PartNumber != excludePart {
+			total += p.Size
+		}
+	}
+	return total
+}
+
+// UploadPart streams one part of a multipart upload into CAS. Re-uploading a
+// part number replaces the previous part; its chunks are left for GC.
+func (s *Store) UploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int, reader io.Reader) (*PartMeta, error) {
+	if err := validateName(bucket); err != nil {
+		return nil, fmt.Errorf("invalid bucket name: %w", err)
+	}
+	if err := validateName(key); err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+	if partNumber < 1 || partNumber > MaxPartNumber {
+		return nil, fmt.Errorf("part number must be 1-%d, got %d: %w", MaxPartNumber, partNumber, ErrInvalidRequest)
+	}
+	if s.cas == nil {
+		return nil, fmt.Errorf("CAS not initialized - call InitCAS first")
+	}
+
+	// Phase 1: Validate upload and read bucket settings under RLock
+	s.mu.RLock()
+	bucketMeta, err := s.getBucketMeta(bucket)
+	if err != nil {
+		s.mu.RUnlock()
+		return nil, err
+	}
+	if _, err := s.getUpload(bucket, key, uploadID); err != nil {
+		s.mu.RUnlock()
+		return nil, err
+	}
+	s.mu.RUnlock()
+
+	// Phase 2: Stream part into CAS without holding the global lock
+	streamed, err := s.writeStreamToCAS(ctx, reader, bucketMeta.ReplicationFactor)
+	if err != nil {
+		return nil, err
+	}
+
+	part := PartMeta{
+		PartNumber:    partNumber,
+		Size:          streamed.size,
+		ETag:          fmt.Sprintf("\"%s\"", hex.EncodeToString(streamed.md5)),
+		LastModified:  streamed.now,
+		Chunks:        streamed.chunks,
+		ChunkMetadata: streamed.chunkMetadata,
+	}
+
+	// Phase 3: Record the part under lock
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Upload may have been aborted or completed while streaming
+	if _, err := s.getUpload(bucket, key, uploadID); err != nil {
+		return nil, err
+	}
+
+	// Fail early if the assembled object could never fit in the quota.
+	// Actual allocation happens once, in CompleteMultipartUpload.
+	if s.quota != nil {
+		pending := s.uploadedBytes(bucket, uploadID, partNumber) + part.Size
+		if !s.quota.CanAllocate(pending) {
+			return nil, ErrQuotaExceeded
+		}
+	}
+
+	data, err := json.MarshalIndent(part, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal part meta: %w", err)
+	}
+	if err := syncedWriteFile(s.partMetaPath(bucket, uploadID, partNumber), data, 0644); err != nil {
+		return nil, fmt.Errorf("write part meta: %w", err)
+	}
+
+	return &part, nil
+}
+
+// CompleteMultipartUpload assembles the listed parts into the live object.
+// The object's chunk list is the concatenation of the parts' chunk lists, so no
+// data is copied. The ETag follows S3's multipart form: MD5 of the part MD5s
+// followed by "-N".
+//
+// Multipart objects always use chunk replication, even in buckets with erasure
+// coding enabled (the same as objects above MaxErasureCodingFileSize).
+func (s *Store) CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []CompletedPart) (*ObjectMeta, error) {
+	if err := validateName(bucket); err != nil {
+		return nil, fmt.Errorf("invalid bucket name: %w", err)
+	}
+	if err := validateName(key); err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("no parts specified: %w", ErrInvalidPart)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.getBucketMeta(bucket); err != nil {
+		return nil, err
+	}
+
+	upload, err := s.getUpload(bucket, key, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	var chunks []string
+	chunkMetadata := make(map[string]*ChunkMetadata)
+	var size int64
+	partSizes := make([]int64, 0, len(parts))
+	etagHasher := md5.New()
+
+	for i := 1; i < len(parts); i++ {
+		if parts[i].PartNumber <= parts[i-1].PartNumber {
+			return nil, ErrInvalidPartOrder
+		}
+	}
+
+	for i, cp := range parts {
+		part, err := s.getPart(bucket, uploadID, cp.PartNumber)
+		if err != nil {
+			return nil, err
+		}
+		if strings.Trim(cp.ETag, "\"") != strings.Trim(part.ETag, "\"") {
+			return nil, fmt.Errorf("part %d etag mismatch: %w", cp.PartNumber, ErrInvalidPart)
+		}
+		if i < len(parts)-1 && part.Size < MinPartSize {
+			return nil, fmt.Errorf("part %d is %d bytes: %w", cp.PartNumber, part.Size, ErrEntityTooSmall)
+		}
+
+		partMD5, err := hex.DecodeString(strings.Trim(part.ETag, "\""))
+		if err != nil {
+			return nil, fmt.Errorf("part %d has malformed etag: %w", cp.PartNumber, ErrInvalidPart)
+		}
+		etagHasher.Write(partMD5)
+
+		chunks = append(chunks, part.Chunks...)
+		for h, cm := range part.ChunkMetadata {
+			chunkMetadata[h] = cm
+		}
+		size += part.Size
+		partSizes = append(partSizes, part.Size)
+	}
+
+	fileVersionVector := make(map[string]uint64)
+	if s.coordinatorID != "" {
+		fileVersionVector[s.coordinatorID] = 1
+	}
+
+	objMeta := ObjectMeta{
+		Key:           key,
+		Size:          size,
+		ContentType:   upload.ContentType,
+		ETag:          fmt.Sprintf("\"%s-%d\"", hex.EncodeToString(etagHasher.Sum(nil)), len(parts)),
+		LastModified:  time.Now().UTC(),
+		Metadata:      upload.Metadata,
+		Chunks:        chunks,
+		ChunkMetadata: chunkMetadata,
+		VersionVector: fileVersionVector,
+		PartSizes:     partSizes,
+	}
+
+	if err := s.commitObjectMeta(ctx, bucket, key, &objMeta); err != nil {
+		return nil, err
+	}
+
+	// Parts not included in the completed object become orphaned chunks;
+	// the periodic GC pass reclaims them.
+	if err := os.RemoveAll(s.uploadDir(bucket, uploadID)); err != nil {
+		s.logger.Warn().Err(err).Str("bucket", bucket).Str("upload_id", uploadID).Msg("failed to remove completed upload dir")
+	}
+
+	return &objMeta, nil
+}
+
+// AbortMultipartUpload discards an upload and deletes any of its chunks that
+// are not referenced elsewhere.
+func (s *Store) AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error {
+	s.mu.Lock()
+
+	if _, err := s.getBucketMeta(bucket); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	if _, err := s.getUpload(bucket, key, uploadID); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+
+	var chunksToCheck []string
+	if parts, err := s.listPartsUnsafe(bucket, uploadID); err == nil {
+		for _, p := range parts {
+			chunksToCheck = append(chunksToCheck, p.Chunks...)
+		}
+	}
+
+	err := os.RemoveAll(s.uploadDir(bucket, uploadID))
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("remove upload dir: %w", err)
+	}
+
+	if len(chunksToCheck) > 0 {
+		s.DeleteUnreferencedChunks(ctx, chunksToCheck)
+	}
+	return nil
+}
+
+// ListParts returns the parts uploaded so far, ordered by part number.
+func (s *Store) ListParts(ctx context.Context, bucket, key, uploadID string) (*MultipartUpload, []PartMeta, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, err := s.getBucketMeta(bucket); err != nil {
+		return nil, nil, err
+	}
+	upload, err := s.getUpload(bucket, key, uploadID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parts, err := s.listPartsUnsafe(bucket, uploadID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return upload, parts, nil
+}
+
+// listPartsUnsafe reads all part metadata for an upload (caller must hold lock).
+func (s *Store) listPartsUnsafe(bucket, uploadID string) ([]PartMeta, error) {
+	partsDir := filepath.Join(s.uploadDir(bucket, uploadID), "parts")
+	entries, err := os.ReadDir(partsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read parts dir: %w", err)
+	}
+
+	parts := make([]PartMeta, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(partsDir, e.Name()))
+		if err != nil {
+			continue
+		}
+		var part PartMeta
+		if err := json.Unmarshal(data, &part); err != nil {
+			continue
+		}
+		parts = append(parts, part)
+	}
+
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+	return parts, nil
+}
+
+// ListMultipartUploads returns in-progress uploads in a bucket whose keys start
+// with prefix, ordered by key and then upload ID, the order listings page in.
+func (s *Store) ListMultipartUploads(ctx context.Context, bucket, prefix string) ([]MultipartUpload, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, err := s.getBucketMeta(bucket); err != nil {
+		return nil, err
+	}
+
+	uploads := s.listUploadsUnsafe(bucket)
+	result := make([]MultipartUpload, 0, len(uploads))
+	for _, u := range uploads {
+		if strings.HasPrefix(u.Key, prefix) {
+			result = append(result, u)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Key != result[j].Key {
+			return result[i].Key < result[j].Key
+		}
+		return result[i].UploadID < result[j].UploadID
+	})
+	return result, nil
+}
+
+// listUploadsUnsafe reads all upload metadata in a bucket. Unreadable entries
+// are skipped. Only reads immutable files, so callers may hold no lock.
+func (s *Store) listUploadsUnsafe(bucket string) []MultipartUpload {
+	entries, err := os.ReadDir(s.uploadsPath(bucket))
+	if err != nil {
+		return nil
+	}
+
+	var uploads []MultipartUpload
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(s.uploadMetaPath(bucket, e.Name()))
+		if err != nil {
+			continue
+		}
+		var upload MultipartUpload
+		if err := json.Unmarshal(data, &upload); err != nil {
+			continue
+		}
+		uploads = append(uploads, upload)
+	}
+	return uploads
+}
+
+// abortStaleMultipartUploads removes uploads older than the configured expiry,
+// or than a bucket lifecycle rule allows if that is shorter.
+// Their chunks are left for the orphan scan in the same GC pass.
+func (s *Store) abortStaleMultipartUploads(ctx context.Context, stats *GCStats) {
+	s.mu.RLock()
+	expiry := s.multipartUploadExpiry
+	s.mu.RUnlock()
+	if expiry <= 0 {
+		expiry = DefaultMultipartUploadExpiry
+	}
+	now := time.Now().UTC()
+	cutoff := now.Add(-expiry)
+
+	bucketEntries, err := os.ReadDir(filepath.Join(s.dataDir, "buckets"))
+	if err != nil {
+		return
+	}
+
+	for _, bucketEntry := range bucketEntries {
+		if ctx.Err() != nil {
+			return
+		}
+		if !bucketEntry.IsDir() {
+			continue
+		}
+		bucket := bucketEntry.Name()
+
+		s.mu.RLock()
+		bucketMeta, err := s.getBucketMeta(bucket)
+		s.mu.RUnlock()
+		var rules []LifecycleRule
+		if err == nil {
+			rules = bucketMeta.Lifecycle
+		}
+
+		for _, upload := range s.listUploadsUnsafe(bucket) {
+			uploadCutoff := cutoff
+			if days := lifecycleDays(rules, upload.Key, nil, func(r LifecycleRule) int { return r.AbortIncompleteUploadDays }); days > 0 {
+				if ruleCutoff := now.AddDate(0, 0, -days); ruleCutoff.After(uploadCutoff) {
+					uploadCutoff = ruleCutoff
+				}
+			}
+			if !upload.Initiated.Before(uploadCutoff) {
+				continue
+			}
+
+			s.mu.Lock()
+			err := os.RemoveAll(s.uploadDir(bucket, upload.UploadID))
+			s.mu.Unlock()
+			if err != nil {
+				s.logger.Warn().Err(err).Str("bucket", bucket).Str("upload_id", upload.UploadID).Msg("failed to abort stale multipart upload")
+				continue
+			}
+			stats.UploadsAborted++
+		}
+	}
+}
@@ -0,0 +1,384 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// makePartData returns deterministic pseudo-random data so CDC produces many chunks.
+func makePartData(size int, seed byte) []byte {
+	data := make([]byte, size)
+	x := uint32(seed) + 1
+	for i := range data {
+		x = x*1664525 + 1013904223
+		data[i] = byte(x >> 24)
+	}
+	return data
+}
+
+func TestMultipart_CompleteAssemblesParts(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+
+	upload, err := store.CreateMultipartUpload(ctx, "bucket", "big.bin", "application/zip", map[string]string{"X-Amz-Meta-Build": "42"}, "alice")
+	require.NoError(t, err)
+	assert.Len(t, upload.UploadID, 32)
+
+	part1 := makePartData(MinPartSize, 1)
+	part2 := makePartData(1024, 2)
+
+	p1, err := store.UploadPart(ctx, "bucket", "big.bin", upload.UploadID, 1, bytes.NewReader(part1))
+	require.NoError(t, err)
+	p2, err := store.UploadPart(ctx, "bucket", "big.bin", upload.UploadID, 2, bytes.NewReader(part2))
+	require.NoError(t, err)
+
+	meta, err := store.CompleteMultipartUpload(ctx, "bucket", "big.bin", upload.UploadID, []CompletedPart{
+		{PartNumber: 1, ETag: p1.ETag},
+		{PartNumber: 2, ETag: p2.ETag},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(part1)+len(part2)), meta.Size)
+	assert.Equal(t, "application/zip", meta.ContentType)
+	assert.Equal(t, "42", meta.Metadata["X-Amz-Meta-Build"])
+
+	// ETag is MD5 of the concatenated part MD5s, suffixed with the part count
+	sum1 := md5.Sum(part1)
+	sum2 := md5.Sum(part2)
+	combined := md5.Sum(append(sum1[:], sum2[:]...))
+	assert.Equal(t, fmt.Sprintf("\"%s-2\"", hex.EncodeToString(combined[:])), meta.ETag)
+
+	reader, _, err := store.GetObject(ctx, "bucket", "big.bin")
+	require.NoError(t, err)
+	defer func() { _ = reader.Close() }()
+	got, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(append(part1, part2...), got))
+
+	// Upload state is removed on completion
+	_, err = os.Stat(store.uploadDir("bucket", upload.UploadID))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestMultipart_PartsDedupeWithExistingObjects(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+
+	data := makePartData(256*1024, 7)
+	_, err := store.PutObject(ctx, "bucket", "existing.bin", bytes.NewReader(data), int64(len(data)), "", nil)
+	require.NoError(t, err)
+	chunksBefore := store.GetCASStats().ChunkCount
+
+	upload, err := store.CreateMultipartUpload(ctx, "bucket", "copy.bin", "", nil, "alice")
+	require.NoError(t, err)
+	_, err = store.UploadPart(ctx, "bucket", "copy.bin", upload.UploadID, 1, bytes.NewReader(data))
+	require.NoError(t, err)
+
+	assert.Equal(t, chunksBefore, store.GetCASStats().ChunkCount, "identical part content should reuse CAS chunks")
+}
+
+func TestMultipart_CompleteValidation(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+
+	upload, err := store.CreateMultipartUpload(ctx, "bucket", "file", "", nil, "alice")
+	require.NoError(t, err)
+	p1, err := store.UploadPart(ctx, "bucket", "file", upload.UploadID, 1, strings.NewReader("small"))
+	require.NoError(t, err)
+	p2, err := store.UploadPart(ctx, "bucket", "file", upload.UploadID, 2, strings.NewReader("tail"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		parts   []CompletedPart
+		wantErr error
+	}{
+		{"no parts", nil, ErrInvalidPart},
+		{"unknown part", []CompletedPart{{PartNumber: 3, ETag: p1.ETag}}, ErrInvalidPart},
+		{"etag mismatch", []CompletedPart{{PartNumber: 1, ETag: p2.ETag}}, ErrInvalidPart},
+		{"out of order", []CompletedPart{{PartNumber: 2, ETag: p2.ETag}, {PartNumber: 1, ETag: p1.ETag}}, ErrInvalidPartOrder},
+		{"non-final part too small", []CompletedPart{{PartNumber: 1, ETag: p1.ETag}, {PartNumber: 2, ETag: p2.ETag}}, ErrEntityTooSmall},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := store.CompleteMultipartUpload(ctx, "bucket", "file", upload.UploadID, tt.parts)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+
+	// A single small part is fine (it is the last part)
+	_, err = store.CompleteMultipartUpload(ctx, "bucket", "file", upload.UploadID, []CompletedPart{{PartNumber: 1, ETag: p1.ETag}})
+	require.NoError(t, err)
+}
+
+func TestMultipart_UnknownUpload(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+
+	upload, err := store.CreateMultipartUpload(ctx, "bucket", "file", "", nil, "alice")
+	require.NoError(t, err)
+
+	_, err = store.UploadPart(ctx, "bucket", "file", "../../etc", 1, strings.NewReader("x"))
+	assert.ErrorIs(t, err, ErrNoSuchUpload)
+
+	// Upload IDs are bound to their key
+	_, err = store.UploadPart(ctx, "bucket", "other", upload.UploadID, 1, strings.NewReader("x"))
+	assert.ErrorIs(t, err, ErrNoSuchUpload)
+
+	_, err = store.UploadPart(ctx, "bucket", "file", upload.UploadID, MaxPartNumber+1, strings.NewReader("x"))
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+}
+
+func TestMultipart_AbortDeletesUnreferencedChunks(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+
+	upload, err := store.CreateMultipartUpload(ctx, "bucket", "file", "", nil, "alice")
+	require.NoError(t, err)
+	part, err := store.UploadPart(ctx, "bucket", "file", upload.UploadID, 1, bytes.NewReader(makePartData(64*1024, 3)))
+	require.NoError(t, err)
+	require.NotEmpty(t, part.Chunks)
+
+	require.NoError(t, store.AbortMultipartUpload(ctx, "bucket", "file", upload.UploadID))
+
+	for _, h := range part.Chunks {
+		assert.False(t, store.ChunkExists(h), "chunk %s should be deleted", truncHash(h))
+	}
+
+	_, _, err = store.ListParts(ctx, "bucket", "file", upload.UploadID)
+	assert.ErrorIs(t, err, ErrNoSuchUpload)
+}
+
+func TestMultipart_GCKeepsInProgressPartsAndAbortsStale(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+
+	upload, err := store.CreateMultipartUpload(ctx, "bucket", "file", "", nil, "alice")
+	require.NoError(t, err)
+	part, err := store.UploadPart(ctx, "bucket", "file", upload.UploadID, 1, bytes.NewReader(makePartData(64*1024, 4)))
+	require.NoError(t, err)
+
+	// In-progress parts are referenced and survive a forced GC
+	stats := store.RunGarbageCollectionForce(ctx)
+	assert.Equal(t, 0, stats.UploadsAborted)
+	for _, h := range part.Chunks {
+		assert.True(t, store.ChunkExists(h))
+	}
+
+	// Once the upload is older than the expiry, GC aborts it and reclaims chunks
+	store.SetMultipartUploadExpiry(time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	stats = store.RunGarbageCollectionForce(ctx)
+	assert.Equal(t, 1, stats.UploadsAborted)
+	for _, h := range part.Chunks {
+		assert.False(t, store.ChunkExists(h))
+	}
+
+	uploads, err := store.ListMultipartUploads(ctx, "bucket", "")
+	require.NoError(t, err)
+	assert.Empty(t, uploads)
+}
+
+func TestMultipart_QuotaEnforced(t *testing.T) {
+	masterKey := [32]byte{1, 2, 3}
+	store, err := NewStoreWithCAS(t.TempDir(), NewQuotaManager(1024), masterKey)
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+
+	upload, err := store.CreateMultipartUpload(ctx, "bucket", "file", "", nil, "alice")
+	require.NoError(t, err)
+
+	_, err = store.UploadPart(ctx, "bucket", "file", upload.UploadID, 1, bytes.NewReader(make([]byte, 600)))
+	require.NoError(t, err)
+	_, err = store.UploadPart(ctx, "bucket", "file", upload.UploadID, 2, bytes.NewReader(make([]byte, 600)))
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+}
+
+func TestMultipart_ServerLifecycle(t *testing.T) {
+	server, store := newTestServer(t)
+	require.NoError(t, store.CreateBucket(context.Background(), "bucket", "alice", 2, nil))
+
+	queue := &recordingReplicationQueue{}
+	server.SetReplicationQueue(queue)
+
+	// CreateMultipartUpload
+	req := httptest.NewRequest(http.MethodPost, "/bucket/dir/file.bin?uploads", nil)
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var initResp InitiateMultipartUploadResult
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &initResp))
+	uploadID := initResp.UploadID
+	require.NotEmpty(t, uploadID)
+
+	// UploadPart x2
+	part1 := makePartData(MinPartSize, 5)
+	part2 := []byte("final part")
+	var etags []string
+	for i, body := range [][]byte{part1, part2} {
+		req = httptest.NewRequest(http.MethodPut, fmt.Sprintf("/bucket/dir/file.bin?partNumber=%d&uploadId=%s", i+1, uploadID), bytes.NewReader(body))
+		w = httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		etags = append(etags, w.Header().Get("ETag"))
+	}
+
+	// ListMultipartUploads
+	req = httptest.NewRequest(http.MethodGet, "/bucket?uploads", nil)
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var listUploads ListMultipartUploadsResult
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &listUploads))
+	require.Len(t, listUploads.Uploads, 1)
+	assert.Equal(t, "dir/file.bin", listUploads.Uploads[0].Key)
+
+	// ListParts
+	req = httptest.NewRequest(http.MethodGet, "/bucket/dir/file.bin?uploadId="+uploadID, nil)
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var listParts ListPartsResult
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &listParts))
+	require.Len(t, listParts.Parts, 2)
+	assert.Equal(t, int64(len(part1)), listParts.Parts[0].Size)
+
+	// CompleteMultipartUpload
+	completeBody := fmt.Sprintf(`<CompleteMultipartUpload>
+  <Part><PartNumber>1</PartNumber><ETag>%s</ETag></Part>
+  <Part><PartNumber>2</PartNumber><ETag>%s</ETag></Part>
+</CompleteMultipartUpload>`, etags[0], etags[1])
+	req = httptest.NewRequest(http.MethodPost, "/bucket/dir/file.bin?uploadId="+uploadID, strings.NewReader(completeBody))
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var completeResp CompleteMultipartUploadResult
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &completeResp))
+	assert.True(t, strings.HasSuffix(completeResp.ETag, "-2\""))
+	assert.Equal(t, []string{"bucket/dir/file.bin:put"}, queue.ops)
+
+	// Object is readable through the normal GET path
+	req = httptest.NewRequest(http.MethodGet, "/bucket/dir/file.bin", nil)
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, len(part1)+len(part2), w.Body.Len())
+}
+
+func TestMultipart_ListUploadsPaging(t *testing.T) {
+	server, store := newTestServer(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+
+	var want []string
+	for _, key := range []string{"a", "a", "a", "b"} {
+		upload, err := store.CreateMultipartUpload(ctx, "bucket", key, "", nil, "alice")
+		require.NoError(t, err)
+		want = append(want, key+"/"+upload.UploadID)
+	}
+	sort.Strings(want)
+
+	// Pages of two split the uploads of "a"
+	var got []string
+	target := "/bucket?uploads&max-uploads=2"
+	for range 3 {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var page ListMultipartUploadsResult
+		require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &page))
+		for _, u := range page.Uploads {
+			got = append(got, u.Key+"/"+u.UploadID)
+		}
+		if !page.IsTruncated {
+			break
+		}
+		target = "/bucket?uploads&max-uploads=2&key-marker=" + page.NextKeyMarker + "&upload-id-marker=" + page.NextUploadIDMarker
+	}
+	assert.Equal(t, want, got)
+
+	// A key marker alone skips every upload of that key
+	req := httptest.NewRequest(http.MethodGet, "/bucket?uploads&key-marker=a", nil)
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var page ListMultipartUploadsResult
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &page))
+	require.Len(t, page.Uploads, 1)
+	assert.Equal(t, "b", page.Uploads[0].Key)
+}
+
+func TestMultipart_ServerAbortAndErrors(t *testing.T) {
+	server, store := newTestServer(t)
+	require.NoError(t, store.CreateBucket(context.Background(), "bucket", "alice", 2, nil))
+
+	upload, err := store.CreateMultipartUpload(context.Background(), "bucket", "file", "", nil, "alice")
+	require.NoError(t, err)
+
+	// Invalid part number
+	req := httptest.NewRequest(http.MethodPut, "/bucket/file?partNumber=0&uploadId="+upload.UploadID, strings.NewReader("x"))
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Malformed complete body
+	req = httptest.NewRequest(http.MethodPost, "/bucket/file?uploadId="+upload.UploadID, strings.NewReader("not xml"))
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "MalformedXML")
+
+	// Oversized complete body
+	body := "<CompleteMultipartUpload>" + strings.Repeat(" ", maxCompleteMultipartBodySize) + "</CompleteMultipartUpload>"
+	req = httptest.NewRequest(http.MethodPost, "/bucket/file?uploadId="+upload.UploadID, strings.NewReader(body))
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "MalformedXML")
+
+	// Abort
+	req = httptest.NewRequest(http.MethodDelete, "/bucket/file?uploadId="+upload.UploadID, nil)
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	// Upload is gone
+	req = httptest.NewRequest(http.MethodGet, "/bucket/file?uploadId="+upload.UploadID, nil)
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "NoSuchUpload")
+}
+
+// recordingReplicationQueue records enqueued replication operations.
+type recordingReplicationQueue struct {
+	ops []string
+}
+
+func (q *recordingReplicationQueue) EnqueueReplication(bucket, key, op string) {
+	q.ops = append(q.ops, bucket+"/"+key+":"+op)
+}
@@ -0,0 +1,150 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strings"
+)
+
+// Bucket event names, following the S3 event notification types.
+// ObjectRestored:RecycleBin is a TunnelMesh addition for undeleted objects.
+const (
+	EventObjectCreatedPut                     = "s3:ObjectCreated:Put"
+	EventObjectCreatedCopy                    = "s3:ObjectCreated:Copy"
+	EventObjectCreatedCompleteMultipartUpload = "s3:ObjectCreated:CompleteMultipartUpload"
+	EventObjectRemovedDelete                  = "s3:ObjectRemoved:Delete"
+	EventObjectRemovedDeleteMarkerCreated     = "s3:ObjectRemoved:DeleteMarkerCreated"
+	EventObjectRestoredRecycleBin             = "s3:ObjectRestored:RecycleBin"
+)
+
+// bucketEventNames lists the event names and wildcards a notification rule may
+// subscribe to.
+var bucketEventNames = map[string]bool{
+	"s3:ObjectCreated:*":                      true,
+	EventObjectCreatedPut:                     true,
+	EventObjectCreatedCopy:                    true,
+	EventObjectCreatedCompleteMultipartUpload: true,
+	"s3:ObjectRemoved:*":                      true,
+	EventObjectRemovedDelete:                  true,
+	EventObjectRemovedDeleteMarkerCreated:     true,
+	"s3:ObjectRestored:*":                     true,
+	EventObjectRestoredRecycleBin:             true,
+}
+
+// WebhookResolver resolves the host of a webhook endpoint to the addresses
+// deliveries may go to, failing for hosts that aren't mesh peers.
+type WebhookResolver func(host string) ([]netip.Addr, error)
+
+// MaxNotificationRules bounds the notification rules on one bucket.
+const MaxNotificationRules = 100
+
+// NotificationRule subscribes to events on keys matching a prefix and suffix.
+// Matching events are published on the coordinator's event stream and, if
+// Endpoint is set, POSTed to that webhook.
+type NotificationRule struct {
+	ID       string   `json:"id,omitempty"`
+	Events   []string `json:"events"`             // Event names, "s3:ObjectCreated:*" style wildcards allowed
+	Prefix   string   `json:"prefix,omitempty"`   // Key prefix filter
+	Suffix   string   `json:"suffix,omitempty"`   // Key suffix filter
+	Endpoint string   `json:"endpoint,omitempty"` // Webhook URL (http or https)
+}
+
+// matches reports whether the rule subscribes to event on key.
+func (r NotificationRule) matches(event, key string) bool {
+	if !strings.HasPrefix(key, r.Prefix) || !strings.HasSuffix(key, r.Suffix) {
+		return false
+	}
+	for _, name := range r.Events {
+		if name == event || (strings.HasSuffix(name, ":*") && strings.HasPrefix(event, strings.TrimSuffix(name, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateNotificationRules checks a notification configuration. Webhook
+// endpoints must resolve to mesh peers; with no resolver, none are accepted.
+func validateNotificationRules(rules []NotificationRule, resolve WebhookResolver) error {
+	if len(rules) > MaxNotificationRules {
+		return fmt.Errorf("%w: notification configuration cannot have more than %d rules", ErrInvalidRequest, MaxNotificationRules)
+	}
+
+	ids := make(map[string]struct{}, len(rules))
+	for _, rule := range rules {
+		if rule.ID != "" {
+			if _, dup := ids[rule.ID]; dup {
+				return fmt.Errorf("%w: notification ID %q is not unique", ErrInvalidRequest, rule.ID)
+			}
+			ids[rule.ID] = struct{}{}
+		}
+		if len(rule.Events) == 0 {
+			return fmt.Errorf("%w: notification %q must specify at least one event", ErrInvalidRequest, rule.ID)
+		}
+		for _, name := range rule.Events {
+			if !bucketEventNames[name] {
+				return fmt.Errorf("%w: unsupported event %q", ErrInvalidRequest, name)
+			}
+		}
+		if rule.Endpoint != "" {
+			u, err := url.Parse(rule.Endpoint)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("%w: webhook endpoint must be an http or https URL", ErrInvalidRequest)
+			}
+			if resolve == nil {
+				return fmt.Errorf("%w: webhook endpoints are not supported", ErrInvalidRequest)
+			}
+			if _, err := resolve(u.Hostname()); err != nil {
+				return fmt.Errorf("%w: webhook endpoint %s: %v", ErrInvalidRequest, u.Host, err)
+			}
+		}
+	}
+	return nil
+}
+
+// SetBucketNotifications replaces a bucket's notification rules. Passing no
+// rules disables notifications.
+func (s *Store) SetBucketNotifications(ctx context.Context, bucket string, rules []NotificationRule) error {
+	if err := validateNotificationRules(rules, s.getWebhookResolver()); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	meta, err := s.getBucketMeta(bucket)
+	if err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		rules = nil
+	}
+	meta.Notifications = rules
+	return s.writeBucketMeta(bucket, meta)
+}
+
+// SetWebhookResolver sets how webhook endpoint hosts are checked and
+// resolved, when rules are set and again on every delivery.
+func (s *Store) SetWebhookResolver(resolve WebhookResolver) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.webhookResolver = resolve
+}
+
+// getWebhookResolver returns the webhook resolver, nil if webhooks are not
+// supported.
+func (s *Store) getWebhookResolver() WebhookResolver {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.webhookResolver
+}
+
+// GetBucketNotifications returns a bucket's notification rules (nil if none).
+func (s *Store) GetBucketNotifications(ctx context.Context, bucket string) ([]NotificationRule, error) {
+	meta, err := s.HeadBucket(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	return meta.Notifications, nil
+}
@@ -0,0 +1,312 @@
+package s3
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingNotifier collects bucket events sent by the server.
+type recordingNotifier struct {
+	mu     sync.Mutex
+	events []BucketEvent
+}
+
+func (n *recordingNotifier) NotifyEvent(event BucketEvent) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.events = append(n.events, event)
+}
+
+func (n *recordingNotifier) names() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	names := make([]string, 0, len(n.events))
+	for _, e := range n.events {
+		names = append(names, e.Name)
+	}
+	return names
+}
+
+// allowWebhookHosts returns a webhook resolver that accepts only the given
+// IP hosts, resolving each to itself.
+func allowWebhookHosts(hosts ...string) WebhookResolver {
+	return func(host string) ([]netip.Addr, error) {
+		for _, h := range hosts {
+			if h == host {
+				return []netip.Addr{netip.MustParseAddr(host)}, nil
+			}
+		}
+		return nil, errors.New("not a mesh peer")
+	}
+}
+
+func TestNotificationRule_Matches(t *testing.T) {
+	rule := NotificationRule{Events: []string{"s3:ObjectCreated:*", EventObjectRemovedDelete}, Prefix: "images/", Suffix: ".jpg"}
+
+	assert.True(t, rule.matches(EventObjectCreatedPut, "images/a.jpg"))
+	assert.True(t, rule.matches(EventObjectCreatedCompleteMultipartUpload, "images/a.jpg"))
+	assert.True(t, rule.matches(EventObjectRemovedDelete, "images/a.jpg"))
+	assert.False(t, rule.matches(EventObjectRemovedDeleteMarkerCreated, "images/a.jpg"))
+	assert.False(t, rule.matches(EventObjectCreatedPut, "docs/a.jpg"))
+	assert.False(t, rule.matches(EventObjectCreatedPut, "images/a.png"))
+}
+
+func TestSetBucketNotifications_Validation(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+
+	tests := []struct {
+		name  string
+		rules []NotificationRule
+	}{
+		{"no events", []NotificationRule{{ID: "a"}}},
+		{"unknown event", []NotificationRule{{Events: []string{"s3:ObjectAccessed:Get"}}}},
+		{"duplicate id", []NotificationRule{{ID: "a", Events: []string{EventObjectCreatedPut}}, {ID: "a", Events: []string{EventObjectCreatedPut}}}},
+		{"bad endpoint scheme", []NotificationRule{{Events: []string{EventObjectCreatedPut}, Endpoint: "ftp://peer/hook"}}},
+		{"relative endpoint", []NotificationRule{{Events: []string{EventObjectCreatedPut}, Endpoint: "/hook"}}},
+		{"webhooks unsupported", []NotificationRule{{Events: []string{EventObjectCreatedPut}, Endpoint: "http://10.42.0.5:8080/hook"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := store.SetBucketNotifications(ctx, "bucket", tt.rules)
+			assert.ErrorIs(t, err, ErrInvalidRequest)
+		})
+	}
+
+	err := store.SetBucketNotifications(ctx, "missing", []NotificationRule{{Events: []string{EventObjectCreatedPut}}})
+	assert.ErrorIs(t, err, ErrBucketNotFound)
+
+	// Only mesh peers can receive webhooks
+	store.SetWebhookResolver(allowWebhookHosts("10.42.0.5"))
+	for _, endpoint := range []string{"http://127.0.0.1:8080/hook", "http://169.254.169.254/latest", "https://example.com/hook"} {
+		err = store.SetBucketNotifications(ctx, "bucket", []NotificationRule{{Events: []string{EventObjectCreatedPut}, Endpoint: endpoint}})
+		assert.ErrorIs(t, err, ErrInvalidRequest, endpoint)
+	}
+
+	rules := []NotificationRule{{ID: "hook", Events: []string{"s3:ObjectCreated:*"}, Prefix: "in/", Endpoint: "http://10.42.0.5:8080/hook"}}
+	require.NoError(t, store.SetBucketNotifications(ctx, "bucket", rules))
+	got, err := store.GetBucketNotifications(ctx, "bucket")
+	require.NoError(t, err)
+	assert.Equal(t, rules, got)
+
+	require.NoError(t, store.SetBucketNotifications(ctx, "bucket", nil))
+	got, err = store.GetBucketNotifications(ctx, "bucket")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestNotifier_PublishesMatchingEvents(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+	require.NoError(t, store.SetBucketNotifications(ctx, "bucket", []NotificationRule{
+		{ID: "created", Events: []string{"s3:ObjectCreated:*"}, Prefix: "in/"},
+	}))
+
+	n := NewNotifier(store)
+	defer n.Close()
+	var published [][]byte
+	n.SetPublisher(func(_ BucketEvent, payload []byte) { published = append(published, payload) })
+
+	n.NotifyEvent(BucketEvent{Name: EventObjectCreatedPut, Bucket: "bucket", Key: "in/a.txt", Size: 4, ETag: `"abc"`, UserID: "alice"})
+	n.NotifyEvent(BucketEvent{Name: EventObjectCreatedPut, Bucket: "bucket", Key: "out/a.txt"})
+	n.NotifyEvent(BucketEvent{Name: EventObjectRemovedDelete, Bucket: "bucket", Key: "in/a.txt"})
+	n.NotifyEvent(BucketEvent{Name: EventObjectCreatedPut, Bucket: "other", Key: "in/a.txt"})
+	require.Len(t, published, 1)
+
+	var msg EventMessage
+	require.NoError(t, json.Unmarshal(published[0], &msg))
+	require.Len(t, msg.Records, 1)
+	rec := msg.Records[0]
+	assert.Equal(t, "ObjectCreated:Put", rec.EventName)
+	assert.Equal(t, "created", rec.S3.ConfigurationID)
+	assert.Equal(t, "bucket", rec.S3.Bucket.Name)
+	assert.Equal(t, "in/a.txt", rec.S3.Object.Key)
+	assert.Equal(t, int64(4), rec.S3.Object.Size)
+	assert.Equal(t, "abc", rec.S3.Object.ETag)
+	assert.Equal(t, "alice", rec.UserIdentity.PrincipalID)
+}
+
+func TestNotifier_WebhookRetriesUntilSuccess(t *testing.T) {
+	var attempts atomic.Int32
+	received := make(chan []byte, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		assert.Equal(t, "ObjectRemoved:Delete", r.Header.Get("X-TunnelMesh-Event"))
+		body, _ := io.ReadAll(r.Body)
+		received <- body
+	}))
+	defer hook.Close()
+
+	store := newTestStoreWithCAS(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+	store.SetWebhookResolver(allowWebhookHosts("127.0.0.1"))
+	require.NoError(t, store.SetBucketNotifications(ctx, "bucket", []NotificationRule{
+		{ID: "hook", Events: []string{"s3:ObjectRemoved:*"}, Endpoint: hook.URL},
+	}))
+
+	n := NewNotifier(store)
+	n.retryDelay = time.Millisecond
+	defer n.Close()
+
+	n.NotifyEvent(BucketEvent{Name: EventObjectRemovedDelete, Bucket: "bucket", Key: "a.txt"})
+
+	select {
+	case body := <-received:
+		var msg EventMessage
+		require.NoError(t, json.Unmarshal(body, &msg))
+		require.Len(t, msg.Records, 1)
+		assert.Equal(t, "a.txt", msg.Records[0].S3.Object.Key)
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+	assert.Equal(t, int32(3), attempts.Load())
+}
+
+func TestNotifier_WebhookRechecksHostOnDelivery(t *testing.T) {
+	var hits atomic.Int32
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer hook.Close()
+
+	store := newTestStoreWithCAS(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+	store.SetWebhookResolver(allowWebhookHosts("127.0.0.1"))
+	require.NoError(t, store.SetBucketNotifications(ctx, "bucket", []NotificationRule{
+		{ID: "hook", Events: []string{"s3:ObjectRemoved:*"}, Endpoint: hook.URL},
+	}))
+
+	// The peer behind the endpoint has since left the mesh
+	var checks atomic.Int32
+	store.SetWebhookResolver(func(host string) ([]netip.Addr, error) {
+		checks.Add(1)
+		return nil, errors.New("not a mesh peer")
+	})
+
+	n := NewNotifier(store)
+	n.retryDelay = time.Millisecond
+	defer n.Close()
+
+	n.NotifyEvent(BucketEvent{Name: EventObjectRemovedDelete, Bucket: "bucket", Key: "a.txt"})
+
+	require.Eventually(t, func() bool { return checks.Load() == webhookMaxAttempts }, 5*time.Second, 10*time.Millisecond)
+	assert.Zero(t, hits.Load())
+}
+
+func TestServer_BucketNotificationConfiguration(t *testing.T) {
+	server, store := newTestServer(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+	store.SetWebhookResolver(allowWebhookHosts("10.42.0.5"))
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodGet, "/bucket?notification", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "WebhookConfiguration")
+
+	w = do(http.MethodPut, "/bucket?notification", `<NotificationConfiguration>
+  <WebhookConfiguration>
+    <Id>uploads</Id>
+    <Endpoint>http://10.42.0.5:8080/hook</Endpoint>
+    <Event>s3:ObjectCreated:*</Event>
+    <Event>s3:ObjectRestored:RecycleBin</Event>
+    <Filter><S3Key><FilterRule><Name>prefix</Name><Value>in/</Value></FilterRule><FilterRule><Name>suffix</Name><Value>.csv</Value></FilterRule></S3Key></Filter>
+  </WebhookConfiguration>
+</NotificationConfiguration>`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	rules, err := store.GetBucketNotifications(ctx, "bucket")
+	require.NoError(t, err)
+	assert.Equal(t, []NotificationRule{{
+		ID:       "uploads",
+		Events:   []string{"s3:ObjectCreated:*", EventObjectRestoredRecycleBin},
+		Prefix:   "in/",
+		Suffix:   ".csv",
+		Endpoint: "http://10.42.0.5:8080/hook",
+	}}, rules)
+
+	w = do(http.MethodGet, "/bucket?notification", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var cfg NotificationConfiguration
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &cfg))
+	got, err := cfg.notificationRules()
+	require.NoError(t, err)
+	assert.Equal(t, rules, got)
+
+	// AWS destination types are not supported
+	w = do(http.MethodPut, "/bucket?notification", `<NotificationConfiguration><TopicConfiguration><Topic>arn:aws:sns:x</Topic><Event>s3:ObjectCreated:*</Event></TopicConfiguration></NotificationConfiguration>`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// An empty configuration disables notifications
+	w = do(http.MethodPut, "/bucket?notification", `<NotificationConfiguration></NotificationConfiguration>`)
+	require.Equal(t, http.StatusOK, w.Code)
+	rules, err = store.GetBucketNotifications(ctx, "bucket")
+	require.NoError(t, err)
+	assert.Nil(t, rules)
+}
+
+func TestServer_EmitsBucketEvents(t *testing.T) {
+	server, store := newTestServer(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+	notifier := &recordingNotifier{}
+	server.SetEventNotifier(notifier)
+
+	do := func(method, target, body string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPut, "/bucket/a.txt", "data", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = do(http.MethodPut, "/bucket/b.txt", "", http.Header{"X-Amz-Copy-Source": {"/bucket/a.txt"}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = do(http.MethodDelete, "/bucket/a.txt", "", nil)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	w = do(http.MethodPost, "/bucket?delete", `<Delete><Object><Key>b.txt</Key></Object></Delete>`, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	assert.Equal(t, []string{
+		EventObjectCreatedPut,
+		EventObjectCreatedCopy,
+		EventObjectRemovedDelete,
+		EventObjectRemovedDelete,
+	}, notifier.names())
+
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+	assert.Equal(t, "alice", notifier.events[0].UserID)
+	assert.Equal(t, int64(4), notifier.events[0].Size)
+}
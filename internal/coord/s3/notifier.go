@@ -0,0 +1,273 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Webhook delivery defaults.
+const (
+	webhookMaxAttempts       = 5
+	webhookInitialRetryDelay = time.Second
+	webhookTimeout           = 10 * time.Second
+	webhookMaxInFlight       = 64
+)
+
+// BucketEvent describes a change to an object, before it is matched against
+// the bucket's notification rules.
+type BucketEvent struct {
+	Name      string // Event name, e.g. EventObjectCreatedPut
+	Bucket    string
+	Key       string
+	Size      int64
+	ETag      string
+	VersionID string
+	UserID    string // Principal that made the change, if known
+	Time      time.Time
+}
+
+// EventNotifier receives bucket events for S3 operations.
+type EventNotifier interface {
+	NotifyEvent(event BucketEvent)
+}
+
+// EventMessage is the JSON body of a notification, in the S3 event message
+// format.
+type EventMessage struct {
+	Records []EventRecord `json:"Records"`
+}
+
+// EventRecord is a single event in an EventMessage.
+type EventRecord struct {
+	EventVersion string            `json:"eventVersion"`
+	EventSource  string            `json:"eventSource"`
+	EventTime    string            `json:"eventTime"`
+	EventName    string            `json:"eventName"` // Without the "s3:" prefix, as in S3
+	UserIdentity EventUserIdentity `json:"userIdentity"`
+	S3           EventS3Entity     `json:"s3"`
+}
+
+// EventUserIdentity identifies who caused an event.
+type EventUserIdentity struct {
+	PrincipalID string `json:"principalId"`
+}
+
+// EventS3Entity identifies the notification rule, bucket and object of an event.
+type EventS3Entity struct {
+	ConfigurationID string        `json:"configurationId"`
+	Bucket          EventS3Bucket `json:"bucket"`
+	Object          EventS3Object `json:"object"`
+}
+
+// EventS3Bucket is the bucket an event occurred in.
+type EventS3Bucket struct {
+	Name string `json:"name"`
+}
+
+// EventS3Object is the object an event occurred on.
+type EventS3Object struct {
+	Key       string `json:"key"`
+	Size      int64  `json:"size,omitempty"`
+	ETag      string `json:"eTag,omitempty"`
+	VersionID string `json:"versionId,omitempty"`
+	Sequencer string `json:"sequencer"`
+}
+
+// Notifier matches bucket events against each bucket's notification rules,
+// publishes matches on the coordinator's event stream and delivers them to
+// webhooks with retries.
+type Notifier struct {
+	store   *Store
+	client  *http.Client
+	publish func(event BucketEvent, payload []byte)
+
+	retryDelay time.Duration
+	inFlight   chan struct{} // Bounds concurrent webhook deliveries
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewNotifier creates a notifier for buckets in store.
+func NewNotifier(store *Store) *Notifier {
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &Notifier{
+		store:      store,
+		retryDelay: webhookInitialRetryDelay,
+		inFlight:   make(chan struct{}, webhookMaxInFlight),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+	n.client = &http.Client{
+		Timeout:   webhookTimeout,
+		Transport: &http.Transport{DialContext: n.dialWebhook},
+	}
+	return n
+}
+
+// dialWebhook connects to a webhook host through the store's webhook
+// resolver, so every delivery, redirects included, only reaches addresses
+// of hosts that are mesh peers at the time.
+func (n *Notifier) dialWebhook(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	resolve := n.store.getWebhookResolver()
+	if resolve == nil {
+		return nil, errors.New("webhook endpoints are not supported")
+	}
+	addrs, err := resolve(host)
+	if err != nil {
+		return nil, fmt.Errorf("webhook endpoint %s: %w", host, err)
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("webhook endpoint %s has no addresses", host)
+	}
+
+	var (
+		d    net.Dialer
+		errs []error
+	)
+	for _, ip := range addrs {
+		conn, err := d.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// SetPublisher sets the function that publishes matched events (with their
+// EventMessage JSON payload) on the coordinator's event stream.
+func (n *Notifier) SetPublisher(publish func(event BucketEvent, payload []byte)) {
+	n.publish = publish
+}
+
+// Close abandons pending webhook retries and waits for deliveries in flight.
+func (n *Notifier) Close() {
+	n.cancel()
+	n.wg.Wait()
+}
+
+// NotifyEvent publishes event once per matching notification rule and
+// schedules webhook delivery for rules with an endpoint.
+func (n *Notifier) NotifyEvent(event BucketEvent) {
+	rules, err := n.store.GetBucketNotifications(context.Background(), event.Bucket)
+	if err != nil || len(rules) == 0 {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+
+	for _, rule := range rules {
+		if !rule.matches(event.Name, event.Key) {
+			continue
+		}
+
+		payload, err := json.Marshal(EventMessage{Records: []EventRecord{newEventRecord(event, rule.ID)}})
+		if err != nil {
+			log.Warn().Err(err).Str("bucket", event.Bucket).Msg("failed to marshal bucket event")
+			return
+		}
+
+		if n.publish != nil {
+			n.publish(event, payload)
+		}
+		if rule.Endpoint != "" {
+			n.deliver(rule.Endpoint, event, payload)
+		}
+	}
+}
+
+// newEventRecord builds the S3 event record for event under a rule.
+func newEventRecord(event BucketEvent, configurationID string) EventRecord {
+	return EventRecord{
+		EventVersion: "2.1",
+		EventSource:  "tunnelmesh:s3",
+		EventTime:    event.Time.UTC().Format(time.RFC3339Nano),
+		EventName:    strings.TrimPrefix(event.Name, "s3:"),
+		UserIdentity: EventUserIdentity{PrincipalID: event.UserID},
+		S3: EventS3Entity{
+			ConfigurationID: configurationID,
+			Bucket:          EventS3Bucket{Name: event.Bucket},
+			Object: EventS3Object{
+				Key:       event.Key,
+				Size:      event.Size,
+				ETag:      strings.Trim(event.ETag, `"`),
+				VersionID: event.VersionID,
+				Sequencer: fmt.Sprintf("%016X", event.Time.UnixNano()),
+			},
+		},
+	}
+}
+
+// deliver POSTs payload to a webhook in the background, retrying with
+// exponential backoff. Events are dropped if too many deliveries are pending.
+func (n *Notifier) deliver(endpoint string, event BucketEvent, payload []byte) {
+	select {
+	case n.inFlight <- struct{}{}:
+	default:
+		log.Warn().Str("bucket", event.Bucket).Str("endpoint", endpoint).Msg("too many pending webhook deliveries, dropping bucket event")
+		return
+	}
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		defer func() { <-n.inFlight }()
+
+		delay := n.retryDelay
+		for attempt := 1; ; attempt++ {
+			err := n.post(endpoint, event.Name, payload)
+			if err == nil {
+				return
+			}
+			if attempt == webhookMaxAttempts {
+				log.Warn().Err(err).Str("bucket", event.Bucket).Str("key", event.Key).Str("endpoint", endpoint).
+					Int("attempts", attempt).Msg("giving up on webhook delivery")
+				return
+			}
+
+			select {
+			case <-n.ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+	}()
+}
+
+// post makes one webhook delivery attempt. Any 2xx response is success.
+func (n *Notifier) post(endpoint, eventName string, payload []byte) error {
+	req, err := http.NewRequestWithContext(n.ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-TunnelMesh-Event", strings.TrimPrefix(eventName, "s3:"))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,329 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readRange(t *testing.T, store *Store, bucket, key string, offset, length int64) []byte {
+	t.Helper()
+	meta, err := store.HeadObjectVersion(context.Background(), bucket, key, "")
+	require.NoError(t, err)
+	reader, err := store.GetObjectContent(context.Background(), bucket, key, meta, offset, length)
+	require.NoError(t, err)
+	defer func() { _ = reader.Close() }()
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return data
+}
+
+func TestGetObjectContentRange(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 1, nil))
+
+	data := makePartData(300*1024, 7)
+	_, err := store.PutObject(ctx, "bucket", "big.bin", bytes.NewReader(data), int64(len(data)), "application/octet-stream", nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		offset, count int64
+	}{
+		{"head", 0, 10},
+		{"middle spanning chunks", 100000, 150000},
+		{"tail", int64(len(data)) - 5, 5},
+		{"whole", 0, int64(len(data))},
+		{"empty", 42, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := readRange(t, store, "bucket", "big.bin", tt.offset, tt.count)
+			assert.Equal(t, data[tt.offset:tt.offset+tt.count], got)
+		})
+	}
+
+	meta, err := store.HeadObjectVersion(ctx, "bucket", "big.bin", "")
+	require.NoError(t, err)
+	_, err = store.GetObjectContent(ctx, "bucket", "big.bin", meta, int64(len(data)), 1)
+	assert.ErrorIs(t, err, ErrInvalidRange)
+}
+
+func TestGetObjectContentRange_ReadsOnlyCoveringChunks(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 1, nil))
+
+	data := makePartData(300*1024, 3)
+	meta, err := store.PutObject(ctx, "bucket", "big.bin", bytes.NewReader(data), int64(len(data)), "application/octet-stream", nil)
+	require.NoError(t, err)
+	require.Greater(t, len(meta.Chunks), 2)
+
+	// Remove the last chunk: a range within the first chunk must not touch it.
+	last := meta.Chunks[len(meta.Chunks)-1]
+	_, err = store.cas.DeleteChunk(ctx, last)
+	require.NoError(t, err)
+
+	first := meta.ChunkMetadata[meta.Chunks[0]].Size
+	got := readRange(t, store, "bucket", "big.bin", 1, first-1)
+	assert.Equal(t, data[1:first], got)
+}
+
+func TestGetObjectContentRange_WithoutChunkMetadata(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 1, nil))
+
+	data := makePartData(200*1024, 5)
+	_, err := store.PutObject(ctx, "bucket", "legacy.bin", bytes.NewReader(data), int64(len(data)), "application/octet-stream", nil)
+	require.NoError(t, err)
+
+	// Objects written before per-chunk sizes were recorded fall back to skipping.
+	store.mu.Lock()
+	meta, err := store.getObjectMeta("bucket", "legacy.bin")
+	require.NoError(t, err)
+	meta.ChunkMetadata = nil
+	metaJSON, err := json.Marshal(meta)
+	require.NoError(t, err)
+	require.NoError(t, syncedWriteFile(store.objectMetaPath("bucket", "legacy.bin"), metaJSON, 0644))
+	store.mu.Unlock()
+
+	got := readRange(t, store, "bucket", "legacy.bin", 150000, 1000)
+	assert.Equal(t, data[150000:151000], got)
+}
+
+func TestGetObjectContentRange_ErasureCoded(t *testing.T) {
+	store := newTestStoreWithErasureCoding(t, 4, 2)
+	ctx := context.Background()
+
+	data := makePartData(256*1024+17, 9)
+	meta, err := store.PutObject(ctx, "ec-bucket", "ec.bin", bytes.NewReader(data), int64(len(data)), "application/octet-stream", nil)
+	require.NoError(t, err)
+	shardSize := meta.ErasureCoding.ShardSize
+
+	// Window crossing a shard boundary
+	got := readRange(t, store, "ec-bucket", "ec.bin", shardSize-100, 200)
+	assert.Equal(t, data[shardSize-100:shardSize+100], got)
+
+	// Final bytes, excluding RS padding
+	got = readRange(t, store, "ec-bucket", "ec.bin", int64(len(data))-10, 10)
+	assert.Equal(t, data[len(data)-10:], got)
+
+	// Missing data chunk in the window: reconstructs from parity
+	_, err = store.cas.DeleteChunk(ctx, meta.ErasureCoding.DataHashes[0])
+	require.NoError(t, err)
+	got = readRange(t, store, "ec-bucket", "ec.bin", 5, 50)
+	assert.Equal(t, data[5:55], got)
+
+	// Wait for background caching goroutine
+	store.WaitBackground()
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		header    string
+		size      int64
+		wantStart int64
+		wantLen   int64
+		wantNil   bool
+		wantErr   bool
+	}{
+		{header: "bytes=0-9", size: 100, wantStart: 0, wantLen: 10},
+		{header: "bytes=90-", size: 100, wantStart: 90, wantLen: 10},
+		{header: "bytes=-20", size: 100, wantStart: 80, wantLen: 20},
+		{header: "bytes=-500", size: 100, wantStart: 0, wantLen: 100},
+		{header: "bytes=50-1000", size: 100, wantStart: 50, wantLen: 50},
+		{header: "bytes=100-", size: 100, wantErr: true},
+		{header: "bytes=-0", size: 100, wantErr: true},
+		{header: "bytes=0-0", size: 0, wantErr: true},
+		{header: "bytes=9-1", size: 100, wantNil: true},
+		{header: "bytes=0-1,5-6", size: 100, wantNil: true},
+		{header: "items=0-1", size: 100, wantNil: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			br, err := parseRange(tt.header, tt.size)
+			switch {
+			case tt.wantErr:
+				assert.ErrorIs(t, err, ErrInvalidRange)
+			case tt.wantNil:
+				assert.NoError(t, err)
+				assert.Nil(t, br)
+			default:
+				require.NoError(t, err)
+				require.NotNil(t, br)
+				assert.Equal(t, tt.wantStart, br.start)
+				assert.Equal(t, tt.wantLen, br.length)
+			}
+		})
+	}
+}
+
+func TestGetObject_RangeRequest(t *testing.T) {
+	server, store := newTestServer(t)
+	require.NoError(t, store.CreateBucket(context.Background(), "my-bucket", "alice", 2, nil))
+	content := []byte("0123456789abcdefghij")
+	_, err := store.PutObject(context.Background(), "my-bucket", "data.txt", bytes.NewReader(content), int64(len(content)), "text/plain", nil)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/my-bucket/data.txt", nil)
+	req.Header.Set("Range", "bytes=5-9")
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "bytes 5-9/20", w.Header().Get("Content-Range"))
+	assert.Equal(t, "5", w.Header().Get("Content-Length"))
+	assert.Equal(t, "bytes", w.Header().Get("Accept-Ranges"))
+	assert.Equal(t, "56789", w.Body.String())
+
+	// HEAD reports the same window without a body
+	req = httptest.NewRequest(http.MethodHead, "/my-bucket/data.txt", nil)
+	req.Header.Set("Range", "bytes=-3")
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "bytes 17-19/20", w.Header().Get("Content-Range"))
+	assert.Equal(t, "3", w.Header().Get("Content-Length"))
+
+	// Unsatisfiable
+	req = httptest.NewRequest(http.MethodGet, "/my-bucket/data.txt", nil)
+	req.Header.Set("Range", "bytes=50-60")
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, w.Code)
+	assert.Equal(t, "bytes */20", w.Header().Get("Content-Range"))
+	var resp ErrorResponse
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "InvalidRange", resp.Code)
+}
+
+func TestGetObject_ConditionalRequests(t *testing.T) {
+	server, store := newTestServer(t)
+	require.NoError(t, store.CreateBucket(context.Background(), "my-bucket", "alice", 2, nil))
+	content := []byte("conditional content")
+	meta, err := store.PutObject(context.Background(), "my-bucket", "doc.txt", bytes.NewReader(content), int64(len(content)), "text/plain", nil)
+	require.NoError(t, err)
+
+	past := meta.LastModified.Add(-time.Hour).Format(http.TimeFormat)
+	future := meta.LastModified.Add(time.Hour).Format(http.TimeFormat)
+
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		want    int
+	}{
+		{"if-match hit", http.MethodGet, map[string]string{"If-Match": meta.ETag}, http.StatusOK},
+		{"if-match star", http.MethodGet, map[string]string{"If-Match": "*"}, http.StatusOK},
+		{"if-match miss", http.MethodGet, map[string]string{"If-Match": `"nope"`}, http.StatusPreconditionFailed},
+		{"if-none-match hit", http.MethodGet, map[string]string{"If-None-Match": `"other", ` + meta.ETag}, http.StatusNotModified},
+		{"if-none-match head", http.MethodHead, map[string]string{"If-None-Match": meta.ETag}, http.StatusNotModified},
+		{"if-none-match miss", http.MethodGet, map[string]string{"If-None-Match": `"other"`}, http.StatusOK},
+		{"if-modified-since not modified", http.MethodGet, map[string]string{"If-Modified-Since": future}, http.StatusNotModified},
+		{"if-modified-since modified", http.MethodGet, map[string]string{"If-Modified-Since": past}, http.StatusOK},
+		{"if-unmodified-since fails", http.MethodGet, map[string]string{"If-Unmodified-Since": past}, http.StatusPreconditionFailed},
+		{"if-match overrides if-unmodified-since", http.MethodGet, map[string]string{"If-Match": meta.ETag, "If-Unmodified-Since": past}, http.StatusOK},
+		{"if-none-match overrides if-modified-since", http.MethodGet, map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": future}, http.StatusOK},
+		{"if-range stale ignores range", http.MethodGet, map[string]string{"Range": "bytes=0-3", "If-Range": `"stale"`}, http.StatusOK},
+		{"if-range current honours range", http.MethodGet, map[string]string{"Range": "bytes=0-3", "If-Range": meta.ETag}, http.StatusPartialContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/my-bucket/doc.txt", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			server.Handler().ServeHTTP(w, req)
+			assert.Equal(t, tt.want, w.Code)
+			if tt.want == http.StatusNotModified {
+				assert.Empty(t, w.Body.Bytes())
+				assert.Equal(t, meta.ETag, w.Header().Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestGetObject_PartNumber(t *testing.T) {
+	server, store := newTestServer(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "my-bucket", "alice", 2, nil))
+
+	upload, err := store.CreateMultipartUpload(ctx, "my-bucket", "video.bin", "video/mp4", nil, "alice")
+	require.NoError(t, err)
+	part1 := makePartData(int(MinPartSize), 1)
+	part2 := makePartData(1000, 2)
+	var completed []CompletedPart
+	for i, data := range [][]byte{part1, part2} {
+		p, err := store.UploadPart(ctx, "my-bucket", "video.bin", upload.UploadID, i+1, bytes.NewReader(data))
+		require.NoError(t, err)
+		completed = append(completed, CompletedPart{PartNumber: i + 1, ETag: p.ETag})
+	}
+	_, err = store.CompleteMultipartUpload(ctx, "my-bucket", "video.bin", upload.UploadID, completed)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/my-bucket/video.bin?partNumber=2", nil)
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "2", w.Header().Get("x-amz-mp-parts-count"))
+	assert.Equal(t, "bytes "+strconv.Itoa(len(part1))+"-"+strconv.Itoa(len(part1)+len(part2)-1)+"/"+strconv.Itoa(len(part1)+len(part2)),
+		w.Header().Get("Content-Range"))
+	assert.Equal(t, part2, w.Body.Bytes())
+
+	req = httptest.NewRequest(http.MethodGet, "/my-bucket/video.bin?partNumber=3", nil)
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, w.Code)
+	assert.True(t, strings.Contains(w.Body.String(), "InvalidPartNumber"))
+}
+
+func TestPartRange_EmptyPart(t *testing.T) {
+	meta := &ObjectMeta{Size: 5, PartSizes: []int64{5, 0}}
+	br, ok := partRange(meta, 1)
+	require.True(t, ok)
+	assert.Equal(t, "bytes 0-4/5", br.contentRange(meta.Size))
+
+	_, ok = partRange(meta, 2)
+	assert.False(t, ok, "empty part has no satisfiable range")
+}
+
+func TestGetObjectContent_ReadsResolvedVersion(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 1, nil))
+
+	v1 := []byte("first version of the object")
+	_, err := store.PutObject(ctx, "bucket", "key", bytes.NewReader(v1), int64(len(v1)), "text/plain", nil)
+	require.NoError(t, err)
+	meta, err := store.HeadObjectVersion(ctx, "bucket", "key", "")
+	require.NoError(t, err)
+
+	// Overwritten between resolving the request and reading it
+	v2 := []byte("second, longer version of the object")
+	_, err = store.PutObject(ctx, "bucket", "key", bytes.NewReader(v2), int64(len(v2)), "text/plain", nil)
+	require.NoError(t, err)
+
+	for _, window := range [][2]int64{{0, int64(len(v1))}, {6, 7}} {
+		reader, err := store.GetObjectContent(ctx, "bucket", "key", meta, window[0], window[1])
+		require.NoError(t, err)
+		got, err := io.ReadAll(reader)
+		_ = reader.Close()
+		require.NoError(t, err)
+		assert.Equal(t, v1[window[0]:window[0]+window[1]], got)
+	}
+}
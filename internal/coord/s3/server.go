@@ -101,6 +101,10 @@ type RequestForwarder interface {
 	// remote coordinator (e.g. "9000" for S3 API, "" for default HTTPS 443).
 	// Returns true if the request was forwarded.
 	ForwardS3Request(w http.ResponseWriter, r *http.Request, bucket, key, port string) (forwarded bool)
+
+	// PrimaryCoordinator returns the mesh IP of the coordinator that owns the
+	// given bucket/key, or "" if this coordinator does.
+	PrimaryCoordinator(bucket, key string) string
 }
 
 // Server provides an S3-compatible HTTP interface.
@@ -110,6 +114,8 @@ type Server struct {
 	metrics    atomic.Pointer[S3Metrics]
 	recoverer  BucketRecoverer
 	forwarder  RequestForwarder
+	replicator ReplicationQueue
+	notifier   EventNotifier
 }
 
 // Authorizer is the interface for checking S3 permissions.
@@ -125,6 +131,19 @@ type Authorizer interface {
 	GetAllowedPrefixes(userID, bucket string) []string
 }
 
+// TagAuthorizer is implemented by authorizers that evaluate object tag
+// conditions, to check writes against the tags they leave on an object.
+type TagAuthorizer interface {
+	// AuthorizeTags reports whether the user may act on an object carrying tags.
+	AuthorizeTags(userID, verb, resource, bucket, objectKey string, tags map[string]string) bool
+}
+
+// ReplicationQueue queues object changes for background replication to other coordinators.
+type ReplicationQueue interface {
+	// EnqueueReplication schedules bucket/key for replication. op is "put" or "delete".
+	EnqueueReplication(bucket, key, op string)
+}
+
 // BucketRecoverer can recreate missing buckets for existing shares.
 type BucketRecoverer interface {
 	EnsureBucketForShare(ctx context.Context, bucketName string) error
@@ -140,6 +159,23 @@ func (s *Server) SetRequestForwarder(f RequestForwarder) {
 	s.forwarder = f
 }
 
+// SetReplicationQueue sets the queue used to replicate objects written through the S3 API.
+func (s *Server) SetReplicationQueue(q ReplicationQueue) {
+	s.replicator = q
+}
+
+// enqueueReplication schedules replication of bucket/key if a queue is configured.
+func (s *Server) enqueueReplication(bucket, key, op string) {
+	if s.replicator != nil {
+		s.replicator.EnqueueReplication(bucket, key, op)
+	}
+}
+
+// SetEventNotifier sets the notifier that receives bucket events for S3 operations.
+func (s *Server) SetEventNotifier(n EventNotifier) {
+	s.notifier = n
+}
+
 // SetMetrics atomically sets or replaces the metrics instance on the server.
 // Safe to call while HTTP handlers are running.
 func (s *Server) SetMetrics(m *S3Metrics) {
@@ -244,8 +280,32 @@ func (s *Server) handleService(w http.ResponseWriter, r *http.Request) {
 
 // handleBucket handles bucket-level operations.
 func (s *Server) handleBucket(w http.ResponseWriter, r *http.Request, bucket string) {
+	_, isVersioning := r.URL.Query()["versioning"]
+	_, isLifecycle := r.URL.Query()["lifecycle"]
+	_, isNotification := r.URL.Query()["notification"]
+
 	switch r.Method {
 	case http.MethodGet:
+		if _, ok := r.URL.Query()["uploads"]; ok {
+			s.listMultipartUploads(w, r, bucket)
+			return
+		}
+		if _, ok := r.URL.Query()["versions"]; ok {
+			s.listObjectVersions(w, r, bucket)
+			return
+		}
+		if isVersioning {
+			s.getBucketVersioning(w, r, bucket)
+			return
+		}
+		if isLifecycle {
+			s.getBucketLifecycle(w, r, bucket)
+			return
+		}
+		if isNotification {
+			s.getBucketNotification(w, r, bucket)
+			return
+		}
 		// Check for list-type query param (ListObjectsV2)
 		if r.URL.Query().Get("list-type") == "2" {
 			s.listObjectsV2(w, r, bucket)
@@ -254,8 +314,30 @@ func (s *Server) handleBucket(w http.ResponseWriter, r *http.Request, bucket str
 		// Otherwise list objects (V1)
 		s.listObjects(w, r, bucket)
 	case http.MethodPut:
+		if isVersioning {
+			s.putBucketVersioning(w, r, bucket)
+			return
+		}
+		if isLifecycle {
+			s.putBucketLifecycle(w, r, bucket)
+			return
+		}
+		if isNotification {
+			s.putBucketNotification(w, r, bucket)
+			return
+		}
 		s.createBucket(w, r, bucket)
+	case http.MethodPost:
+		if _, ok := r.URL.Query()["delete"]; ok {
+			s.deleteObjects(w, r, bucket)
+			return
+		}
+		s.writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "Method not allowed")
 	case http.MethodDelete:
+		if isLifecycle {
+			s.deleteBucketLifecycle(w, r, bucket)
+			return
+		}
 		s.deleteBucket(w, r, bucket)
 	case http.MethodHead:
 		s.headBucket(w, r, bucket)
@@ -266,9 +348,18 @@ func (s *Server) handleBucket(w http.ResponseWriter, r *http.Request, bucket str
 
 // handleObject handles object-level operations.
 func (s *Server) handleObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	query := r.URL.Query()
+	_, isUploads := query["uploads"]
+	_, isTagging := query["tagging"]
+	uploadID := query.Get("uploadId")
+	isCopy := r.Header.Get("x-amz-copy-source") != ""
+
 	// Forward writes and deletes proactively to primary coordinator.
+	// Multipart state lives on a single coordinator, so every multipart
+	// request (including ListParts) goes to the primary as well.
 	// Reads try local first, then forward on miss (inside getObject/headObject).
-	if (r.Method == http.MethodPut || r.Method == http.MethodDelete) && s.forwarder != nil {
+	isWrite := r.Method == http.MethodPut || r.Method == http.MethodDelete || r.Method == http.MethodPost
+	if (isWrite || uploadID != "") && s.forwarder != nil {
 		if s.forwarder.ForwardS3Request(w, r, bucket, key, "9000") {
 			return
 		}
@@ -276,11 +367,45 @@ func (s *Server) handleObject(w http.ResponseWriter, r *http.Request, bucket, ke
 
 	switch r.Method {
 	case http.MethodGet:
-		s.getObject(w, r, bucket, key)
+		switch {
+		case uploadID != "":
+			s.listParts(w, r, bucket, key, uploadID)
+		case isTagging:
+			s.getObjectTagging(w, r, bucket, key)
+		default:
+			s.getObject(w, r, bucket, key)
+		}
 	case http.MethodPut:
-		s.putObject(w, r, bucket, key)
+		switch {
+		case uploadID != "" && isCopy:
+			s.writeError(w, http.StatusNotImplemented, "NotImplemented", "UploadPartCopy is not supported")
+		case uploadID != "":
+			s.uploadPart(w, r, bucket, key, uploadID)
+		case isTagging:
+			s.putObjectTagging(w, r, bucket, key)
+		case isCopy:
+			s.copyObject(w, r, bucket, key)
+		default:
+			s.putObject(w, r, bucket, key)
+		}
+	case http.MethodPost:
+		switch {
+		case isUploads:
+			s.createMultipartUpload(w, r, bucket, key)
+		case uploadID != "":
+			s.completeMultipartUpload(w, r, bucket, key, uploadID)
+		default:
+			s.writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "Method not allowed")
+		}
 	case http.MethodDelete:
-		s.deleteObject(w, r, bucket, key)
+		switch {
+		case uploadID != "":
+			s.abortMultipartUpload(w, r, bucket, key, uploadID)
+		case isTagging:
+			s.deleteObjectTagging(w, r, bucket, key)
+		default:
+			s.deleteObject(w, r, bucket, key)
+		}
 	case http.MethodHead:
 		s.headObject(w, r, bucket, key)
 	default:
@@ -507,8 +632,13 @@ func (s *Server) getObject(w http.ResponseWriter, r *http.Request, bucket, key s
 		return
 	}
 
-	reader, meta, err := s.store.GetObject(r.Context(), bucket, key)
+	versionID := r.URL.Query().Get("versionId")
+	meta, err := s.store.HeadObjectVersion(r.Context(), bucket, key, versionID)
 	if err != nil {
+		if s.writeDeleteMarkerResponse(rec, r, bucket, key, meta, err, true) {
+			storeErr = err
+			return
+		}
 		// Try forwarding to primary if not found locally
 		if (errors.Is(err, ErrObjectNotFound) || errors.Is(err, ErrBucketNotFound)) && s.forwarder != nil {
 			if s.forwarder.ForwardS3Request(w, r, bucket, key, "9000") {
@@ -517,29 +647,51 @@ func (s *Server) getObject(w http.ResponseWriter, r *http.Request, bucket, key s
 			}
 		}
 		storeErr = err // Capture for metrics
-		switch {
-		case errors.Is(err, ErrBucketNotFound):
-			s.writeError(rec, http.StatusNotFound, "NoSuchBucket", "Bucket not found")
-		case errors.Is(err, ErrObjectNotFound):
-			s.writeError(rec, http.StatusNotFound, "NoSuchKey", "Object not found")
-		default:
-			s.writeError(rec, http.StatusInternalServerError, "InternalError", err.Error())
-		}
+		s.writeGetObjectError(rec, err)
+		return
+	}
+
+	br, done := s.resolveObjectRead(rec, r, meta)
+	if done {
+		return
+	}
+
+	// Read the version resolved above, not whatever is current by now
+	offset, length := int64(0), meta.Size
+	if br != nil {
+		offset, length = br.start, br.length
+	}
+	reader, err := s.store.GetObjectContent(r.Context(), bucket, key, meta, offset, length)
+	if err != nil {
+		storeErr = err // Capture for metrics
+		s.writeGetObjectError(rec, err)
 		return
 	}
 	defer func() { _ = reader.Close() }()
 
 	// Set response headers
 	rec.Header().Set("Content-Type", meta.ContentType)
-	rec.Header().Set("Content-Length", fmt.Sprintf("%d", meta.Size))
 	rec.Header().Set("ETag", meta.ETag)
 	rec.Header().Set("Last-Modified", meta.LastModified.Format(http.TimeFormat))
+	rec.Header().Set("Accept-Ranges", "bytes")
+	setVersionIDHeader(rec.Header(), meta.VersionID)
+	if len(meta.Tags) > 0 {
+		rec.Header().Set("x-amz-tagging-count", strconv.Itoa(len(meta.Tags)))
+	}
 
 	// Copy user metadata
 	for k, v := range meta.Metadata {
 		rec.Header().Set(k, v)
 	}
 
+	if br != nil {
+		rec.Header().Set("Content-Range", br.contentRange(meta.Size))
+		rec.Header().Set("Content-Length", fmt.Sprintf("%d", br.length))
+		rec.WriteHeader(http.StatusPartialContent)
+	} else {
+		rec.Header().Set("Content-Length", fmt.Sprintf("%d", meta.Size))
+	}
+
 	n, err := io.Copy(rec, reader)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to stream object")
@@ -549,6 +701,22 @@ func (s *Server) getObject(w http.ResponseWriter, r *http.Request, bucket, key s
 	}
 }
 
+// writeGetObjectError writes the S3 error response for a failed object read.
+func (s *Server) writeGetObjectError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrBucketNotFound):
+		s.writeError(w, http.StatusNotFound, "NoSuchBucket", "Bucket not found")
+	case errors.Is(err, ErrObjectNotFound):
+		s.writeError(w, http.StatusNotFound, "NoSuchKey", "Object not found")
+	case errors.Is(err, ErrInvalidRange):
+		s.writeError(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange", "The requested range is not satisfiable")
+	case errors.Is(err, ErrInvalidRequest):
+		s.writeError(w, http.StatusBadRequest, "InvalidArgument", err.Error())
+	default:
+		s.writeError(w, http.StatusInternalServerError, "InternalError", err.Error())
+	}
+}
+
 // putObject handles PUT /{bucket}/{key}.
 func (s *Server) putObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
 	m := s.metrics.Load()
@@ -563,7 +731,7 @@ func (s *Server) putObject(w http.ResponseWriter, r *http.Request, bucket, key s
 		}
 	}()
 
-	_, err := s.authorizer.AuthorizeRequest(r, "put", "objects", bucket, key)
+	userID, err := s.authorizer.AuthorizeRequest(r, "put", "objects", bucket, key)
 	if err != nil {
 		s.handleAuthError(rec, err)
 		return
@@ -575,19 +743,29 @@ func (s *Server) putObject(w http.ResponseWriter, r *http.Request, bucket, key s
 	}
 
 	// Extract user metadata from headers
-	metadata := make(map[string]string)
-	for k, v := range r.Header {
-		if strings.HasPrefix(strings.ToLower(k), "x-amz-meta-") && len(v) > 0 {
-			metadata[k] = v[0]
+	metadata := userMetadataFromHeaders(r.Header)
+
+	var tags map[string]string
+	if header := r.Header.Get("x-amz-tagging"); header != "" {
+		tags, err = parseTaggingHeader(header)
+		if err == nil {
+			err = validateTags(tags)
+		}
+		if err != nil {
+			s.writeError(rec, http.StatusBadRequest, "InvalidTag", err.Error())
+			return
 		}
 	}
+	if !s.authorizeTagWrite(rec, userID, bucket, key, tags) {
+		return
+	}
 
 	// Attempt to recover missing bucket for share (no-op if bucket exists)
 	if s.recoverer != nil {
 		_ = s.recoverer.EnsureBucketForShare(r.Context(), bucket)
 	}
 
-	meta, err := s.store.PutObject(r.Context(), bucket, key, r.Body, r.ContentLength, contentType, metadata)
+	meta, err := s.store.PutObjectWithTags(r.Context(), bucket, key, r.Body, r.ContentLength, contentType, metadata, tags)
 	if err != nil {
 		storeErr = err // Capture for metrics
 		switch {
@@ -595,6 +773,12 @@ func (s *Server) putObject(w http.ResponseWriter, r *http.Request, bucket, key s
 			s.writeError(rec, http.StatusNotFound, "NoSuchBucket", "Bucket not found")
 		case errors.Is(err, ErrQuotaExceeded):
 			s.writeError(rec, http.StatusForbidden, "QuotaExceeded", "Storage quota exceeded")
+		case errors.Is(err, ErrContentSHA256Mismatch):
+			s.writeError(rec, http.StatusBadRequest, "XAmzContentSHA256Mismatch", "The provided x-amz-content-sha256 header does not match what was computed")
+		case errors.Is(err, ErrSignatureMismatch):
+			s.writeError(rec, http.StatusForbidden, "SignatureDoesNotMatch", "Chunk signature does not match")
+		case errors.Is(err, ErrInvalidRequest):
+			s.writeError(rec, http.StatusBadRequest, "InvalidRequest", err.Error())
 		default:
 			s.writeError(rec, http.StatusInternalServerError, "InternalError", err.Error())
 		}
@@ -602,8 +786,12 @@ func (s *Server) putObject(w http.ResponseWriter, r *http.Request, bucket, key s
 	}
 
 	rec.Header().Set("ETag", meta.ETag)
+	setVersionIDHeader(rec.Header(), meta.VersionID)
 	rec.WriteHeader(http.StatusOK)
 
+	s.enqueueReplication(bucket, key, "put")
+	s.notify(EventObjectCreatedPut, bucket, key, userID, meta)
+
 	if m != nil && meta.Size > 0 {
 		m.RecordUpload(meta.Size)
 	}
@@ -612,25 +800,37 @@ func (s *Server) putObject(w http.ResponseWriter, r *http.Request, bucket, key s
 // deleteObject handles DELETE /{bucket}/{key}.
 func (s *Server) deleteObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
 	s.withMetrics(w, "DeleteObject", func(rec http.ResponseWriter) {
-		_, err := s.authorizer.AuthorizeRequest(r, "delete", "objects", bucket, key)
+		userID, err := s.authorizer.AuthorizeRequest(r, "delete", "objects", bucket, key)
 		if err != nil {
 			s.handleAuthError(rec, err)
 			return
 		}
 
-		if err := s.store.DeleteObject(r.Context(), bucket, key); err != nil {
+		versionID := r.URL.Query().Get("versionId")
+		outcome, err := s.store.DeleteObjectVersion(r.Context(), bucket, key, versionID)
+		if err != nil {
 			switch {
 			case errors.Is(err, ErrBucketNotFound):
 				s.writeError(rec, http.StatusNotFound, "NoSuchBucket", "Bucket not found")
 			case errors.Is(err, ErrObjectNotFound):
 				rec.WriteHeader(http.StatusNoContent)
 				return
+			case errors.Is(err, ErrInvalidRequest):
+				s.writeError(rec, http.StatusBadRequest, "InvalidArgument", err.Error())
 			default:
 				s.writeError(rec, http.StatusInternalServerError, "InternalError", err.Error())
 			}
 			return
 		}
 
+		s.replicateDelete(r, bucket, key, versionID)
+		s.notify(deleteEventName(versionID, outcome), bucket, key, userID, &ObjectMeta{VersionID: outcome.VersionID})
+		if outcome.VersionID != "" {
+			rec.Header().Set("x-amz-version-id", outcome.VersionID)
+		}
+		if outcome.DeleteMarker {
+			rec.Header().Set("x-amz-delete-marker", "true")
+		}
 		rec.WriteHeader(http.StatusNoContent)
 	})
 }
@@ -655,8 +855,11 @@ func (s *Server) headObject(w http.ResponseWriter, r *http.Request, bucket, key
 		return
 	}
 
-	meta, err := s.store.HeadObject(r.Context(), bucket, key)
+	meta, err := s.store.HeadObjectVersion(r.Context(), bucket, key, r.URL.Query().Get("versionId"))
 	if err != nil {
+		if s.writeDeleteMarkerResponse(rec, r, bucket, key, meta, err, false) {
+			return
+		}
 		// Try forwarding to primary if not found locally
 		if (errors.Is(err, ErrObjectNotFound) || errors.Is(err, ErrBucketNotFound)) && s.forwarder != nil {
 			if s.forwarder.ForwardS3Request(w, r, bucket, key, "9000") {
@@ -669,31 +872,96 @@ func (s *Server) headObject(w http.ResponseWriter, r *http.Request, bucket, key
 			rec.WriteHeader(http.StatusNotFound)
 		case errors.Is(err, ErrObjectNotFound):
 			rec.WriteHeader(http.StatusNotFound)
+		case errors.Is(err, ErrInvalidRequest):
+			rec.WriteHeader(http.StatusBadRequest)
 		default:
 			rec.WriteHeader(http.StatusInternalServerError)
 		}
 		return
 	}
 
+	br, done := s.resolveObjectRead(rec, r, meta)
+	if done {
+		return
+	}
+
 	rec.Header().Set("Content-Type", meta.ContentType)
-	rec.Header().Set("Content-Length", fmt.Sprintf("%d", meta.Size))
 	rec.Header().Set("ETag", meta.ETag)
 	rec.Header().Set("Last-Modified", meta.LastModified.Format(http.TimeFormat))
+	rec.Header().Set("Accept-Ranges", "bytes")
+	setVersionIDHeader(rec.Header(), meta.VersionID)
+	if len(meta.Tags) > 0 {
+		rec.Header().Set("x-amz-tagging-count", strconv.Itoa(len(meta.Tags)))
+	}
 
 	for k, v := range meta.Metadata {
 		rec.Header().Set(k, v)
 	}
 
+	if br != nil {
+		rec.Header().Set("Content-Range", br.contentRange(meta.Size))
+		rec.Header().Set("Content-Length", fmt.Sprintf("%d", br.length))
+		rec.WriteHeader(http.StatusPartialContent)
+		return
+	}
+
+	rec.Header().Set("Content-Length", fmt.Sprintf("%d", meta.Size))
 	rec.WriteHeader(http.StatusOK)
 }
 
+// readBody reads a request body of at most limit bytes, writing the error
+// response and returning false if it can't. The whole body is read so a
+// SigV4 payload hash is verified before the body is used.
+func (s *Server) readBody(w http.ResponseWriter, r *http.Request, limit int64) ([]byte, bool) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrContentSHA256Mismatch):
+			s.writeError(w, http.StatusBadRequest, "XAmzContentSHA256Mismatch", "The provided x-amz-content-sha256 header does not match what was computed")
+		case errors.Is(err, ErrSignatureMismatch):
+			s.writeError(w, http.StatusForbidden, "SignatureDoesNotMatch", "Chunk signature does not match")
+		default:
+			s.writeError(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+		}
+		return nil, false
+	}
+	if int64(len(body)) > limit {
+		s.writeError(w, http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed")
+		return nil, false
+	}
+	return body, true
+}
+
+// decodeXMLBody reads a request body of at most limit bytes and decodes it
+// into v, writing the error response and returning false if it can't.
+func (s *Server) decodeXMLBody(w http.ResponseWriter, r *http.Request, limit int64, v any) bool {
+	body, ok := s.readBody(w, r, limit)
+	if !ok {
+		return false
+	}
+	if err := xml.Unmarshal(body, v); err != nil {
+		s.writeError(w, http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed")
+		return false
+	}
+	return true
+}
+
 // handleAuthError writes the appropriate error response for auth errors.
 func (s *Server) handleAuthError(w http.ResponseWriter, err error) {
-	if errors.Is(err, ErrAccessDenied) {
+	switch {
+	case errors.Is(err, ErrSignatureMismatch):
+		s.writeError(w, http.StatusForbidden, "SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided")
+	case errors.Is(err, ErrRequestTimeTooSkewed):
+		s.writeError(w, http.StatusForbidden, "RequestTimeTooSkewed", "The difference between the request time and the server's time is too large")
+	case errors.Is(err, ErrRequestExpired):
+		s.writeError(w, http.StatusForbidden, "AccessDenied", "Request has expired")
+	case errors.Is(err, ErrInvalidRequest):
+		s.writeError(w, http.StatusBadRequest, "InvalidRequest", err.Error())
+	case errors.Is(err, ErrAccessDenied):
 		s.writeError(w, http.StatusForbidden, "AccessDenied", "Access denied")
-		return
+	default:
+		s.writeError(w, http.StatusUnauthorized, "InvalidAccessKeyId", "Authentication failed")
 	}
-	s.writeError(w, http.StatusUnauthorized, "InvalidAccessKeyId", "Authentication failed")
 }
 
 // writeError writes an S3-style XML error response.
@@ -0,0 +1,196 @@
+package s3
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// copyObject handles PUT /{bucket}/{key} with an x-amz-copy-source header.
+// The copy is metadata-only: the destination references the source's chunks.
+func (s *Server) copyObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	s.withMetrics(w, "CopyObject", func(rec http.ResponseWriter) {
+		srcBucket, srcKey, srcVersionID, ok := parseCopySource(r.Header.Get("x-amz-copy-source"))
+		if !ok {
+			s.writeError(rec, http.StatusBadRequest, "InvalidArgument", "Copy Source must mention the source bucket and key: sourcebucket/sourcekey")
+			return
+		}
+
+		// Writing the destination and reading the source are checked separately
+		userID, err := s.authorizer.AuthorizeRequest(r, "put", "objects", bucket, key)
+		if err != nil {
+			s.handleAuthError(rec, err)
+			return
+		}
+		if _, err := s.authorizer.AuthorizeRequest(r, "get", "objects", srcBucket, srcKey); err != nil {
+			s.handleAuthError(rec, err)
+			return
+		}
+
+		opts := CopyObjectOptions{SourceVersionID: srcVersionID}
+
+		switch directive := r.Header.Get("x-amz-metadata-directive"); directive {
+		case "", "COPY":
+		case "REPLACE":
+			opts.ReplaceMetadata = true
+			opts.ContentType = r.Header.Get("Content-Type")
+			opts.Metadata = userMetadataFromHeaders(r.Header)
+		default:
+			s.writeError(rec, http.StatusBadRequest, "InvalidArgument", "Unknown metadata directive: "+directive)
+			return
+		}
+
+		switch directive := r.Header.Get("x-amz-tagging-directive"); directive {
+		case "", "COPY":
+		case "REPLACE":
+			tags, err := parseTaggingHeader(r.Header.Get("x-amz-tagging"))
+			if err != nil {
+				s.writeError(rec, http.StatusBadRequest, "InvalidArgument", "The header 'x-amz-tagging' shall be encoded as UTF-8 then URLEncoded URL query parameters without tag name duplicates.")
+				return
+			}
+			opts.ReplaceTags = true
+			opts.Tags = tags
+		default:
+			s.writeError(rec, http.StatusBadRequest, "InvalidArgument", "Unknown tagging directive: "+directive)
+			return
+		}
+
+		destTags := opts.Tags
+		if !opts.ReplaceTags {
+			if src, err := s.store.HeadObjectVersion(r.Context(), srcBucket, srcKey, srcVersionID); err == nil {
+				destTags = src.Tags
+			}
+		}
+		if !s.authorizeTagWrite(rec, userID, bucket, key, destTags) {
+			return
+		}
+
+		if srcBucket == bucket && srcKey == key && srcVersionID == "" && !opts.ReplaceMetadata && !opts.ReplaceTags {
+			s.writeError(rec, http.StatusBadRequest, "InvalidRequest", "This copy request is illegal because it is trying to copy an object to itself without changing the object's metadata or tags.")
+			return
+		}
+
+		if hasCopySourceConditions(r.Header) {
+			src, err := s.store.HeadObjectVersion(r.Context(), srcBucket, srcKey, srcVersionID)
+			if err != nil {
+				s.writeCopyObjectError(rec, err)
+				return
+			}
+			if checkPreconditions(copySourceConditions(r.Header), src) != 0 {
+				s.writeError(rec, http.StatusPreconditionFailed, "PreconditionFailed", "At least one of the pre-conditions you specified did not hold")
+				return
+			}
+		}
+
+		meta, err := s.store.CopyObject(r.Context(), srcBucket, srcKey, bucket, key, opts)
+		if err != nil {
+			s.writeCopyObjectError(rec, err)
+			return
+		}
+
+		if srcVersionID != "" {
+			rec.Header().Set("x-amz-copy-source-version-id", srcVersionID)
+		}
+		setVersionIDHeader(rec.Header(), meta.VersionID)
+		s.writeXML(rec, http.StatusOK, CopyObjectResult{
+			ETag:         meta.ETag,
+			LastModified: meta.LastModified.Format(time.RFC3339),
+		})
+
+		s.enqueueReplication(bucket, key, "put")
+		s.notify(EventObjectCreatedCopy, bucket, key, userID, meta)
+	})
+}
+
+func (s *Server) writeCopyObjectError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrBucketNotFound):
+		s.writeError(w, http.StatusNotFound, "NoSuchBucket", "Bucket not found")
+	case errors.Is(err, ErrObjectNotFound):
+		s.writeError(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist")
+	case errors.Is(err, ErrQuotaExceeded):
+		s.writeError(w, http.StatusForbidden, "QuotaExceeded", "Storage quota exceeded")
+	case errors.Is(err, ErrInvalidTag):
+		s.writeError(w, http.StatusBadRequest, "InvalidTag", err.Error())
+	case errors.Is(err, ErrDeleteMarker):
+		s.writeError(w, http.StatusBadRequest, "InvalidRequest", "The source of a copy request may not specifically refer to a delete marker by version id.")
+	case errors.Is(err, ErrInvalidRequest):
+		s.writeError(w, http.StatusBadRequest, "InvalidArgument", err.Error())
+	default:
+		s.writeError(w, http.StatusInternalServerError, "InternalError", err.Error())
+	}
+}
+
+// parseCopySource parses an x-amz-copy-source header value of the form
+// [/]bucket/key[?versionId=id], where the bucket and key are URL-encoded.
+func parseCopySource(header string) (bucket, key, versionID string, ok bool) {
+	path, query, _ := strings.Cut(strings.TrimPrefix(header, "/"), "?")
+	if query != "" {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			return "", "", "", false
+		}
+		versionID = values.Get("versionId")
+	}
+	path, err := url.PathUnescape(path)
+	if err != nil {
+		return "", "", "", false
+	}
+	bucket, key, found := strings.Cut(path, "/")
+	if !found || bucket == "" || key == "" {
+		return "", "", "", false
+	}
+	return bucket, key, versionID, true
+}
+
+// copySourceConditionHeaders maps x-amz-copy-source-if-* headers to the
+// conditional request headers evaluated by checkPreconditions.
+var copySourceConditionHeaders = map[string]string{
+	"x-amz-copy-source-if-match":            "If-Match",
+	"x-amz-copy-source-if-none-match":       "If-None-Match",
+	"x-amz-copy-source-if-modified-since":   "If-Modified-Since",
+	"x-amz-copy-source-if-unmodified-since": "If-Unmodified-Since",
+}
+
+func hasCopySourceConditions(h http.Header) bool {
+	for name := range copySourceConditionHeaders {
+		if h.Get(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// copySourceConditions returns a request carrying the copy source conditions
+// as plain conditional headers. Any failed condition (including what would be
+// a 304 on GET) fails the copy with 412.
+func copySourceConditions(h http.Header) *http.Request {
+	cond := &http.Request{Header: make(http.Header)}
+	for name, as := range copySourceConditionHeaders {
+		if v := h.Get(name); v != "" {
+			cond.Header.Set(as, v)
+		}
+	}
+	return cond
+}
+
+// userMetadataFromHeaders extracts x-amz-meta-* headers as user metadata.
+func userMetadataFromHeaders(h http.Header) map[string]string {
+	metadata := make(map[string]string)
+	for k, v := range h {
+		if strings.HasPrefix(strings.ToLower(k), "x-amz-meta-") && len(v) > 0 {
+			metadata[k] = v[0]
+		}
+	}
+	return metadata
+}
+
+// CopyObjectResult is the response for CopyObject.
+type CopyObjectResult struct {
+	XMLName      xml.Name `xml:"CopyObjectResult"`
+	ETag         string   `xml:"ETag"`
+	LastModified string   `xml:"LastModified"`
+}
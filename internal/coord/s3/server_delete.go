@@ -0,0 +1,247 @@
+package s3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+)
+
+// MaxDeleteObjects is the maximum number of keys in one DeleteObjects request.
+const MaxDeleteObjects = 1000
+
+// maxDeleteBodySize bounds DeleteObjects request bodies (1000 keys of up to
+// 1 KiB each plus XML overhead).
+const maxDeleteBodySize = 2 * 1024 * 1024
+
+// deleteObjects handles POST /{bucket}?delete.
+// Each key is authorized and deleted individually; failures are reported per
+// key in the response rather than failing the whole request. Like single
+// DeleteObject calls, each key is deleted on its primary coordinator: keys
+// owned by other coordinators are forwarded to them in one request per
+// coordinator, and their results merged into the response.
+func (s *Server) deleteObjects(w http.ResponseWriter, r *http.Request, bucket string) {
+	s.withMetrics(w, "DeleteObjects", func(rec http.ResponseWriter) {
+		// Authenticate up front; per-key permissions are checked below.
+		userID, err := s.authorizer.AuthorizeRequest(r, "delete", "objects", bucket, "")
+		if err != nil {
+			s.handleAuthError(rec, err)
+			return
+		}
+
+		body, ok := s.readBody(rec, r, maxDeleteBodySize)
+		if !ok {
+			return
+		}
+		if md5Header := r.Header.Get("Content-MD5"); md5Header != "" {
+			sum := md5.Sum(body)
+			if md5Header != base64.StdEncoding.EncodeToString(sum[:]) {
+				s.writeError(rec, http.StatusBadRequest, "BadDigest", "The Content-MD5 you specified did not match what we received")
+				return
+			}
+		}
+
+		var req DeleteRequest
+		if err := xml.NewDecoder(bytes.NewReader(body)).Decode(&req); err != nil || len(req.Objects) == 0 {
+			s.writeError(rec, http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed")
+			return
+		}
+		if len(req.Objects) > MaxDeleteObjects {
+			s.writeError(rec, http.StatusBadRequest, "MalformedXML", "The request must contain no more than 1000 keys")
+			return
+		}
+
+		// Group keys by primary coordinator. A forwarded request only deletes
+		// the keys this coordinator owns; the forwarding one handles the rest.
+		forwarded := r.Header.Get("X-TunnelMesh-Forwarded") != ""
+		local := req.Objects
+		remote := make(map[string][]ObjectIdentifier)
+		if s.forwarder != nil {
+			local = nil
+			for _, obj := range req.Objects {
+				primary := s.forwarder.PrimaryCoordinator(bucket, obj.Key)
+				switch {
+				case primary == "":
+					local = append(local, obj)
+				case !forwarded:
+					remote[primary] = append(remote[primary], obj)
+				}
+			}
+		}
+
+		if len(local) > 0 {
+			if _, err := s.store.HeadBucket(r.Context(), bucket); err != nil {
+				if errors.Is(err, ErrBucketNotFound) {
+					s.writeError(rec, http.StatusNotFound, "NoSuchBucket", "Bucket not found")
+					return
+				}
+				s.writeError(rec, http.StatusInternalServerError, "InternalError", err.Error())
+				return
+			}
+		}
+
+		result := DeleteResult{}
+		for _, primary := range slices.Sorted(maps.Keys(remote)) {
+			objs := remote[primary]
+			fwd := r.Clone(r.Context())
+			fwd.Body = io.NopCloser(bytes.NewReader(body))
+			resp := &bufferedResponse{header: make(http.Header)}
+			if !s.forwarder.ForwardS3Request(resp, fwd, bucket, objs[0].Key, "9000") {
+				// Primary changed since grouping; this coordinator owns the keys now
+				local = append(local, objs...)
+				continue
+			}
+			s.mergeForwardedDeletes(&result, resp, objs, req.Quiet)
+		}
+
+		for _, obj := range local {
+			deleted, delErr := s.deleteObjectsKey(r, bucket, userID, obj)
+			switch {
+			case delErr != nil:
+				result.Errors = append(result.Errors, *delErr)
+			case !req.Quiet || forwarded:
+				// Forwarded requests report every key so the forwarding
+				// coordinator can tell which ones were handled.
+				result.Deleted = append(result.Deleted, *deleted)
+			}
+		}
+
+		s.writeXML(rec, http.StatusOK, result)
+	})
+}
+
+// deleteObjectsKey deletes one key of a DeleteObjects request on this
+// coordinator, returning what was deleted or the error to report for it.
+func (s *Server) deleteObjectsKey(r *http.Request, bucket, userID string, obj ObjectIdentifier) (*DeletedObject, *DeleteError) {
+	if _, err := s.authorizer.AuthorizeRequest(r, "delete", "objects", bucket, obj.Key); err != nil {
+		return nil, &DeleteError{Key: obj.Key, VersionID: obj.VersionID, Code: "AccessDenied", Message: "Access Denied"}
+	}
+	outcome, err := s.store.DeleteObjectVersion(r.Context(), bucket, obj.Key, obj.VersionID)
+	if err != nil && !errors.Is(err, ErrObjectNotFound) {
+		code := "InternalError"
+		if errors.Is(err, ErrInvalidRequest) {
+			code = "InvalidArgument"
+		}
+		return nil, &DeleteError{Key: obj.Key, VersionID: obj.VersionID, Code: code, Message: err.Error()}
+	}
+	s.replicateDelete(r, bucket, obj.Key, obj.VersionID)
+	if err == nil {
+		s.notify(deleteEventName(obj.VersionID, outcome), bucket, obj.Key, userID, &ObjectMeta{VersionID: outcome.VersionID})
+	}
+	deleted := &DeletedObject{Key: obj.Key, VersionID: obj.VersionID, DeleteMarker: outcome.DeleteMarker}
+	if outcome.DeleteMarker && obj.VersionID == "" {
+		deleted.DeleteMarkerVersionID = outcome.VersionID
+	}
+	return deleted, nil
+}
+
+// mergeForwardedDeletes adds the results of a DeleteObjects request forwarded
+// to another coordinator for objs. Keys the response doesn't mention, because
+// the coordinators disagreed on who owns them, are reported as errors.
+func (s *Server) mergeForwardedDeletes(result *DeleteResult, resp *bufferedResponse, objs []ObjectIdentifier, quiet bool) {
+	var fwd DeleteResult
+	if resp.status != http.StatusOK || xml.Unmarshal(resp.body.Bytes(), &fwd) != nil {
+		errResp := ErrorResponse{Code: "InternalError", Message: fmt.Sprintf("primary coordinator returned status %d", resp.status)}
+		_ = xml.Unmarshal(resp.body.Bytes(), &errResp)
+		for _, obj := range objs {
+			result.Errors = append(result.Errors, DeleteError{Key: obj.Key, VersionID: obj.VersionID, Code: errResp.Code, Message: errResp.Message})
+		}
+		return
+	}
+
+	pending := make(map[ObjectIdentifier]int)
+	for _, obj := range objs {
+		pending[obj]++
+	}
+	for _, d := range fwd.Deleted {
+		id := ObjectIdentifier{Key: d.Key, VersionID: d.VersionID}
+		if pending[id] == 0 {
+			continue
+		}
+		pending[id]--
+		if !quiet {
+			result.Deleted = append(result.Deleted, d)
+		}
+	}
+	for _, e := range fwd.Errors {
+		id := ObjectIdentifier{Key: e.Key, VersionID: e.VersionID}
+		if pending[id] == 0 {
+			continue
+		}
+		pending[id]--
+		result.Errors = append(result.Errors, e)
+	}
+	for _, obj := range objs {
+		if pending[obj] > 0 {
+			pending[obj]--
+			result.Errors = append(result.Errors, DeleteError{Key: obj.Key, VersionID: obj.VersionID, Code: "InternalError", Message: "The primary coordinator did not delete the key"})
+		}
+	}
+}
+
+// bufferedResponse captures the response to a request forwarded to another
+// coordinator, for requests whose results are combined before replying.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header { return b.header }
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+func (b *bufferedResponse) WriteHeader(code int) {
+	if b.status == 0 {
+		b.status = code
+	}
+}
+
+// DeleteObjects XML types
+
+// DeleteRequest is the request body for DeleteObjects.
+type DeleteRequest struct {
+	XMLName xml.Name           `xml:"Delete"`
+	Quiet   bool               `xml:"Quiet"`
+	Objects []ObjectIdentifier `xml:"Object"`
+}
+
+// ObjectIdentifier names an object to delete.
+type ObjectIdentifier struct {
+	Key       string `xml:"Key"`
+	VersionID string `xml:"VersionId,omitempty"`
+}
+
+// DeleteResult is the response for DeleteObjects.
+type DeleteResult struct {
+	XMLName xml.Name        `xml:"DeleteResult"`
+	Deleted []DeletedObject `xml:"Deleted"`
+	Errors  []DeleteError   `xml:"Error"`
+}
+
+// DeletedObject reports a successfully deleted key.
+type DeletedObject struct {
+	Key                   string `xml:"Key"`
+	VersionID             string `xml:"VersionId,omitempty"`
+	DeleteMarker          bool   `xml:"DeleteMarker,omitempty"`
+	DeleteMarkerVersionID string `xml:"DeleteMarkerVersionId,omitempty"`
+}
+
+// DeleteError reports a key that could not be deleted.
+type DeleteError struct {
+	Key       string `xml:"Key"`
+	VersionID string `xml:"VersionId,omitempty"`
+	Code      string `xml:"Code"`
+	Message   string `xml:"Message"`
+}
@@ -0,0 +1,264 @@
+package s3
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// maxLifecycleBodySize bounds PutBucketLifecycleConfiguration request bodies.
+const maxLifecycleBodySize = 1024 * 1024
+
+// Lifecycle rule status values.
+const (
+	lifecycleStatusEnabled  = "Enabled"
+	lifecycleStatusDisabled = "Disabled"
+)
+
+// getBucketLifecycle handles GET /{bucket}?lifecycle.
+func (s *Server) getBucketLifecycle(w http.ResponseWriter, r *http.Request, bucket string) {
+	s.withMetrics(w, "GetBucketLifecycleConfiguration", func(rec http.ResponseWriter) {
+		if _, err := s.authorizer.AuthorizeRequest(r, "get", "buckets", bucket, ""); err != nil {
+			s.handleAuthError(rec, err)
+			return
+		}
+
+		rules, err := s.store.GetBucketLifecycle(r.Context(), bucket)
+		if err != nil {
+			s.writeLifecycleError(rec, err)
+			return
+		}
+		if len(rules) == 0 {
+			s.writeError(rec, http.StatusNotFound, "NoSuchLifecycleConfiguration", "The lifecycle configuration does not exist")
+			return
+		}
+
+		s.writeXML(rec, http.StatusOK, newLifecycleConfiguration(rules))
+	})
+}
+
+// putBucketLifecycle handles PUT /{bucket}?lifecycle.
+func (s *Server) putBucketLifecycle(w http.ResponseWriter, r *http.Request, bucket string) {
+	s.withMetrics(w, "PutBucketLifecycleConfiguration", func(rec http.ResponseWriter) {
+		if _, err := s.authorizer.AuthorizeRequest(r, "put", "buckets", bucket, ""); err != nil {
+			s.handleAuthError(rec, err)
+			return
+		}
+
+		var req LifecycleConfiguration
+		if !s.decodeXMLBody(rec, r, maxLifecycleBodySize, &req) {
+			return
+		}
+		rules, err := req.lifecycleRules()
+		if err != nil {
+			s.writeLifecycleError(rec, err)
+			return
+		}
+		if len(rules) == 0 {
+			s.writeError(rec, http.StatusBadRequest, "MalformedXML", "The lifecycle configuration must contain at least one rule")
+			return
+		}
+
+		if err := s.store.SetBucketLifecycle(r.Context(), bucket, rules); err != nil {
+			s.writeLifecycleError(rec, err)
+			return
+		}
+
+		rec.WriteHeader(http.StatusOK)
+	})
+}
+
+// deleteBucketLifecycle handles DELETE /{bucket}?lifecycle.
+func (s *Server) deleteBucketLifecycle(w http.ResponseWriter, r *http.Request, bucket string) {
+	s.withMetrics(w, "DeleteBucketLifecycle", func(rec http.ResponseWriter) {
+		if _, err := s.authorizer.AuthorizeRequest(r, "put", "buckets", bucket, ""); err != nil {
+			s.handleAuthError(rec, err)
+			return
+		}
+
+		if err := s.store.SetBucketLifecycle(r.Context(), bucket, nil); err != nil {
+			s.writeLifecycleError(rec, err)
+			return
+		}
+
+		rec.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// writeLifecycleError maps lifecycle store errors to S3 error responses.
+func (s *Server) writeLifecycleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrBucketNotFound):
+		s.writeError(w, http.StatusNotFound, "NoSuchBucket", "Bucket not found")
+	case errors.Is(err, ErrInvalidRequest):
+		s.writeError(w, http.StatusBadRequest, "InvalidArgument", err.Error())
+	case errors.Is(err, ErrInvalidTag):
+		s.writeError(w, http.StatusBadRequest, "InvalidTag", err.Error())
+	default:
+		s.writeError(w, http.StatusInternalServerError, "InternalError", err.Error())
+	}
+}
+
+// Lifecycle XML types
+
+// LifecycleConfiguration is the request and response body for
+// Get/PutBucketLifecycleConfiguration.
+type LifecycleConfiguration struct {
+	XMLName xml.Name                     `xml:"LifecycleConfiguration"`
+	Rules   []LifecycleConfigurationRule `xml:"Rule"`
+}
+
+// LifecycleConfigurationRule is a single lifecycle rule. SkipRecycleBin is a
+// TunnelMesh extension; the other elements follow S3.
+type LifecycleConfigurationRule struct {
+	ID                             string                          `xml:"ID,omitempty"`
+	Prefix                         *string                         `xml:"Prefix"` // Deprecated S3 form of Filter
+	Filter                         *LifecycleFilter                `xml:"Filter"`
+	Status                         string                          `xml:"Status"`
+	Expiration                     *LifecycleExpiration            `xml:"Expiration"`
+	NoncurrentVersionExpiration    *NoncurrentVersionExpiration    `xml:"NoncurrentVersionExpiration"`
+	AbortIncompleteMultipartUpload *AbortIncompleteMultipartUpload `xml:"AbortIncompleteMultipartUpload"`
+	SkipRecycleBin                 bool                            `xml:"SkipRecycleBin,omitempty"`
+}
+
+// LifecycleFilter selects the objects a rule applies to by key prefix or by
+// one tag, or by both or several tags combined with And.
+type LifecycleFilter struct {
+	Prefix string        `xml:"Prefix,omitempty"`
+	Tag    *Tag          `xml:"Tag"`
+	And    *LifecycleAnd `xml:"And"`
+}
+
+// LifecycleAnd combines filter conditions.
+type LifecycleAnd struct {
+	Prefix string `xml:"Prefix,omitempty"`
+	Tags   []Tag  `xml:"Tag"`
+}
+
+// LifecycleExpiration expires current objects.
+type LifecycleExpiration struct {
+	Days                      int    `xml:"Days,omitempty"`
+	Date                      string `xml:"Date,omitempty"`
+	ExpiredObjectDeleteMarker bool   `xml:"ExpiredObjectDeleteMarker,omitempty"`
+}
+
+// NoncurrentVersionExpiration expires noncurrent object versions.
+type NoncurrentVersionExpiration struct {
+	NoncurrentDays int `xml:"NoncurrentDays"`
+}
+
+// AbortIncompleteMultipartUpload aborts stale multipart uploads.
+type AbortIncompleteMultipartUpload struct {
+	DaysAfterInitiation int `xml:"DaysAfterInitiation"`
+}
+
+// newLifecycleConfiguration builds the XML form of lifecycle rules.
+func newLifecycleConfiguration(rules []LifecycleRule) LifecycleConfiguration {
+	var cfg LifecycleConfiguration
+	for _, rule := range rules {
+		x := LifecycleConfigurationRule{
+			ID:             rule.ID,
+			Filter:         newLifecycleFilter(rule.Prefix, rule.Tags),
+			Status:         lifecycleStatusDisabled,
+			SkipRecycleBin: rule.SkipRecycleBin,
+		}
+		if rule.Enabled {
+			x.Status = lifecycleStatusEnabled
+		}
+		if rule.ExpirationDays > 0 || rule.ExpiredObjectDeleteMarker {
+			x.Expiration = &LifecycleExpiration{Days: rule.ExpirationDays, ExpiredObjectDeleteMarker: rule.ExpiredObjectDeleteMarker}
+		}
+		if rule.NoncurrentVersionExpirationDays > 0 {
+			x.NoncurrentVersionExpiration = &NoncurrentVersionExpiration{NoncurrentDays: rule.NoncurrentVersionExpirationDays}
+		}
+		if rule.AbortIncompleteUploadDays > 0 {
+			x.AbortIncompleteMultipartUpload = &AbortIncompleteMultipartUpload{DaysAfterInitiation: rule.AbortIncompleteUploadDays}
+		}
+		cfg.Rules = append(cfg.Rules, x)
+	}
+	return cfg
+}
+
+// newLifecycleFilter builds the XML filter for a prefix and tags.
+func newLifecycleFilter(prefix string, tags map[string]string) *LifecycleFilter {
+	sorted := newTagging(tags).TagSet.Tags
+	switch {
+	case len(tags) == 0:
+		return &LifecycleFilter{Prefix: prefix}
+	case len(tags) == 1 && prefix == "":
+		return &LifecycleFilter{Tag: &sorted[0]}
+	default:
+		return &LifecycleFilter{And: &LifecycleAnd{Prefix: prefix, Tags: sorted}}
+	}
+}
+
+// lifecycleRules converts the XML form to lifecycle rules, rejecting elements
+// the store does not support. Limits are checked by the store.
+func (c *LifecycleConfiguration) lifecycleRules() ([]LifecycleRule, error) {
+	rules := make([]LifecycleRule, 0, len(c.Rules))
+	for _, x := range c.Rules {
+		rule := LifecycleRule{ID: x.ID, SkipRecycleBin: x.SkipRecycleBin}
+
+		switch x.Status {
+		case lifecycleStatusEnabled:
+			rule.Enabled = true
+		case lifecycleStatusDisabled:
+		default:
+			return nil, fmt.Errorf("%w: rule status must be %s or %s", ErrInvalidRequest, lifecycleStatusEnabled, lifecycleStatusDisabled)
+		}
+
+		switch {
+		case x.Filter != nil && x.Prefix != nil:
+			return nil, fmt.Errorf("%w: rule cannot have both Prefix and Filter", ErrInvalidRequest)
+		case x.Prefix != nil:
+			rule.Prefix = *x.Prefix
+		case x.Filter != nil:
+			f := x.Filter
+			switch {
+			case f.And != nil:
+				if f.Prefix != "" || f.Tag != nil {
+					return nil, fmt.Errorf("%w: filter conditions must all be inside And", ErrInvalidRequest)
+				}
+				tags, err := tagMap(f.And.Tags)
+				if err != nil {
+					return nil, err
+				}
+				rule.Prefix, rule.Tags = f.And.Prefix, normalizeTags(tags)
+			case f.Tag != nil:
+				if f.Prefix != "" {
+					return nil, fmt.Errorf("%w: filter conditions must all be inside And", ErrInvalidRequest)
+				}
+				rule.Tags = map[string]string{f.Tag.Key: f.Tag.Value}
+			default:
+				rule.Prefix = f.Prefix
+			}
+		}
+
+		if e := x.Expiration; e != nil {
+			if e.Date != "" {
+				return nil, fmt.Errorf("%w: expiration dates are not supported, use Days", ErrInvalidRequest)
+			}
+			if e.Days < 0 || (e.Days == 0 && !e.ExpiredObjectDeleteMarker) {
+				return nil, fmt.Errorf("%w: expiration days must be a positive integer", ErrInvalidRequest)
+			}
+			rule.ExpirationDays = e.Days
+			rule.ExpiredObjectDeleteMarker = e.ExpiredObjectDeleteMarker
+		}
+		if n := x.NoncurrentVersionExpiration; n != nil {
+			if n.NoncurrentDays <= 0 {
+				return nil, fmt.Errorf("%w: noncurrent days must be a positive integer", ErrInvalidRequest)
+			}
+			rule.NoncurrentVersionExpirationDays = n.NoncurrentDays
+		}
+		if a := x.AbortIncompleteMultipartUpload; a != nil {
+			if a.DaysAfterInitiation <= 0 {
+				return nil, fmt.Errorf("%w: days after initiation must be a positive integer", ErrInvalidRequest)
+			}
+			rule.AbortIncompleteUploadDays = a.DaysAfterInitiation
+		}
+
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
@@ -0,0 +1,376 @@
+package s3
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxCompleteMultipartBodySize bounds a CompleteMultipartUpload body, room
+// for MaxPartNumber parts.
+const maxCompleteMultipartBodySize = 2 * 1024 * 1024
+
+// createMultipartUpload handles POST /{bucket}/{key}?uploads.
+func (s *Server) createMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	s.withMetrics(w, "CreateMultipartUpload", func(rec http.ResponseWriter) {
+		userID, err := s.authorizer.AuthorizeRequest(r, "put", "objects", bucket, key)
+		if err != nil {
+			s.handleAuthError(rec, err)
+			return
+		}
+
+		contentType := r.Header.Get("Content-Type")
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		metadata := userMetadataFromHeaders(r.Header)
+
+		if s.recoverer != nil {
+			_ = s.recoverer.EnsureBucketForShare(r.Context(), bucket)
+		}
+
+		upload, err := s.store.CreateMultipartUpload(r.Context(), bucket, key, contentType, metadata, userID)
+		if err != nil {
+			s.writeMultipartError(rec, err)
+			return
+		}
+
+		s.writeXML(rec, http.StatusOK, InitiateMultipartUploadResult{
+			Bucket:   bucket,
+			Key:      key,
+			UploadID: upload.UploadID,
+		})
+	})
+}
+
+// uploadPart handles PUT /{bucket}/{key}?partNumber=N&uploadId=ID.
+func (s *Server) uploadPart(w http.ResponseWriter, r *http.Request, bucket, key, uploadID string) {
+	s.withMetrics(w, "UploadPart", func(rec http.ResponseWriter) {
+		_, err := s.authorizer.AuthorizeRequest(r, "put", "objects", bucket, key)
+		if err != nil {
+			s.handleAuthError(rec, err)
+			return
+		}
+
+		partNumber, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+		if err != nil || partNumber < 1 || partNumber > MaxPartNumber {
+			s.writeError(rec, http.StatusBadRequest, "InvalidArgument", "Part number must be an integer between 1 and 10000")
+			return
+		}
+
+		part, err := s.store.UploadPart(r.Context(), bucket, key, uploadID, partNumber, r.Body)
+		if err != nil {
+			s.writeMultipartError(rec, err)
+			return
+		}
+
+		rec.Header().Set("ETag", part.ETag)
+		rec.WriteHeader(http.StatusOK)
+
+		if m := s.metrics.Load(); m != nil && part.Size > 0 {
+			m.RecordUpload(part.Size)
+		}
+	})
+}
+
+// completeMultipartUpload handles POST /{bucket}/{key}?uploadId=ID.
+func (s *Server) completeMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, key, uploadID string) {
+	s.withMetrics(w, "CompleteMultipartUpload", func(rec http.ResponseWriter) {
+		userID, err := s.authorizer.AuthorizeRequest(r, "put", "objects", bucket, key)
+		if err != nil {
+			s.handleAuthError(rec, err)
+			return
+		}
+
+		// The completed object is untagged
+		if !s.authorizeTagWrite(rec, userID, bucket, key, nil) {
+			return
+		}
+
+		var req CompleteMultipartUploadRequest
+		if !s.decodeXMLBody(rec, r, maxCompleteMultipartBodySize, &req) {
+			return
+		}
+
+		parts := make([]CompletedPart, 0, len(req.Parts))
+		for _, p := range req.Parts {
+			parts = append(parts, CompletedPart{PartNumber: p.PartNumber, ETag: p.ETag})
+		}
+
+		meta, err := s.store.CompleteMultipartUpload(r.Context(), bucket, key, uploadID, parts)
+		if err != nil {
+			s.writeMultipartError(rec, err)
+			return
+		}
+
+		s.enqueueReplication(bucket, key, "put")
+		s.notify(EventObjectCreatedCompleteMultipartUpload, bucket, key, userID, meta)
+
+		setVersionIDHeader(rec.Header(), meta.VersionID)
+		s.writeXML(rec, http.StatusOK, CompleteMultipartUploadResult{
+			Location: "/" + bucket + "/" + key,
+			Bucket:   bucket,
+			Key:      key,
+			ETag:     meta.ETag,
+		})
+	})
+}
+
+// abortMultipartUpload handles DELETE /{bucket}/{key}?uploadId=ID.
+func (s *Server) abortMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, key, uploadID string) {
+	s.withMetrics(w, "AbortMultipartUpload", func(rec http.ResponseWriter) {
+		_, err := s.authorizer.AuthorizeRequest(r, "delete", "objects", bucket, key)
+		if err != nil {
+			s.handleAuthError(rec, err)
+			return
+		}
+
+		if err := s.store.AbortMultipartUpload(r.Context(), bucket, key, uploadID); err != nil {
+			s.writeMultipartError(rec, err)
+			return
+		}
+
+		rec.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// listParts handles GET /{bucket}/{key}?uploadId=ID.
+func (s *Server) listParts(w http.ResponseWriter, r *http.Request, bucket, key, uploadID string) {
+	s.withMetrics(w, "ListParts", func(rec http.ResponseWriter) {
+		userID, err := s.authorizer.AuthorizeRequest(r, "list", "objects", bucket, key)
+		if err != nil {
+			s.handleAuthError(rec, err)
+			return
+		}
+
+		marker := 0
+		if pm := r.URL.Query().Get("part-number-marker"); pm != "" {
+			if parsed, err := strconv.Atoi(pm); err == nil && parsed > 0 {
+				marker = parsed
+			}
+		}
+		maxParts := 1000 // default
+		if mp := r.URL.Query().Get("max-parts"); mp != "" {
+			if parsed, err := strconv.Atoi(mp); err == nil && parsed > 0 && parsed <= 1000 {
+				maxParts = parsed
+			}
+		}
+
+		upload, parts, err := s.store.ListParts(r.Context(), bucket, key, uploadID)
+		if err != nil {
+			s.writeMultipartError(rec, err)
+			return
+		}
+
+		resp := ListPartsResult{
+			Bucket:           bucket,
+			Key:              key,
+			UploadID:         uploadID,
+			Initiator:        Owner{ID: upload.Initiator, DisplayName: upload.Initiator},
+			Owner:            Owner{ID: userID, DisplayName: userID},
+			PartNumberMarker: marker,
+			MaxParts:         maxParts,
+		}
+
+		for _, p := range parts {
+			if p.PartNumber <= marker {
+				continue
+			}
+			if len(resp.Parts) == maxParts {
+				resp.IsTruncated = true
+				break
+			}
+			resp.Parts = append(resp.Parts, PartInfo{
+				PartNumber:   p.PartNumber,
+				LastModified: p.LastModified.Format(time.RFC3339),
+				ETag:         p.ETag,
+				Size:         p.Size,
+			})
+			resp.NextPartNumberMarker = p.PartNumber
+		}
+
+		s.writeXML(rec, http.StatusOK, resp)
+	})
+}
+
+// listMultipartUploads handles GET /{bucket}?uploads.
+// Listing resumes after (key-marker, upload-id-marker); a key-marker without
+// an upload-id-marker resumes after all uploads of that key.
+func (s *Server) listMultipartUploads(w http.ResponseWriter, r *http.Request, bucket string) {
+	s.withMetrics(w, "ListMultipartUploads", func(rec http.ResponseWriter) {
+		userID, err := s.authorizer.AuthorizeRequest(r, "list", "objects", bucket, "")
+		if err != nil {
+			s.handleAuthError(rec, err)
+			return
+		}
+
+		prefix := r.URL.Query().Get("prefix")
+		keyMarker := r.URL.Query().Get("key-marker")
+		var uploadIDMarker string
+		if keyMarker != "" {
+			// As in S3, the upload ID marker is ignored without a key marker
+			uploadIDMarker = r.URL.Query().Get("upload-id-marker")
+		}
+		maxUploads := 1000 // default
+		if mu := r.URL.Query().Get("max-uploads"); mu != "" {
+			if parsed, err := strconv.Atoi(mu); err == nil && parsed > 0 && parsed <= 1000 {
+				maxUploads = parsed
+			}
+		}
+
+		uploads, err := s.store.ListMultipartUploads(r.Context(), bucket, prefix)
+		if err != nil {
+			s.writeMultipartError(rec, err)
+			return
+		}
+
+		allowedPrefixes := s.authorizer.GetAllowedPrefixes(userID, bucket)
+
+		resp := ListMultipartUploadsResult{
+			Bucket:         bucket,
+			Prefix:         prefix,
+			KeyMarker:      keyMarker,
+			UploadIDMarker: uploadIDMarker,
+			MaxUploads:     maxUploads,
+		}
+
+		for _, u := range uploads {
+			if u.Key < keyMarker || (u.Key == keyMarker && (uploadIDMarker == "" || u.UploadID <= uploadIDMarker)) {
+				continue
+			}
+			if allowedPrefixes != nil && !hasAnyPrefix(u.Key, allowedPrefixes) {
+				continue
+			}
+			if len(resp.Uploads) == maxUploads {
+				resp.IsTruncated = true
+				break
+			}
+			resp.Uploads = append(resp.Uploads, UploadInfo{
+				Key:       u.Key,
+				UploadID:  u.UploadID,
+				Initiator: Owner{ID: u.Initiator, DisplayName: u.Initiator},
+				Initiated: u.Initiated.Format(time.RFC3339),
+			})
+			resp.NextKeyMarker = u.Key
+			resp.NextUploadIDMarker = u.UploadID
+		}
+
+		s.writeXML(rec, http.StatusOK, resp)
+	})
+}
+
+// writeMultipartError maps store errors from multipart operations to S3 error responses.
+func (s *Server) writeMultipartError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrBucketNotFound):
+		s.writeError(w, http.StatusNotFound, "NoSuchBucket", "Bucket not found")
+	case errors.Is(err, ErrNoSuchUpload):
+		s.writeError(w, http.StatusNotFound, "NoSuchUpload", "The specified multipart upload does not exist")
+	case errors.Is(err, ErrInvalidPartOrder):
+		s.writeError(w, http.StatusBadRequest, "InvalidPartOrder", "The list of parts was not in ascending order")
+	case errors.Is(err, ErrInvalidPart):
+		s.writeError(w, http.StatusBadRequest, "InvalidPart", err.Error())
+	case errors.Is(err, ErrEntityTooSmall):
+		s.writeError(w, http.StatusBadRequest, "EntityTooSmall", err.Error())
+	case errors.Is(err, ErrInvalidRequest):
+		s.writeError(w, http.StatusBadRequest, "InvalidArgument", err.Error())
+	case errors.Is(err, ErrQuotaExceeded):
+		s.writeError(w, http.StatusForbidden, "QuotaExceeded", "Storage quota exceeded")
+	case errors.Is(err, ErrContentSHA256Mismatch):
+		s.writeError(w, http.StatusBadRequest, "XAmzContentSHA256Mismatch", "The provided x-amz-content-sha256 header does not match what was computed")
+	case errors.Is(err, ErrSignatureMismatch):
+		s.writeError(w, http.StatusForbidden, "SignatureDoesNotMatch", "Chunk signature does not match")
+	default:
+		s.writeError(w, http.StatusInternalServerError, "InternalError", err.Error())
+	}
+}
+
+// hasAnyPrefix reports whether key starts with any of prefixes.
+func hasAnyPrefix(key string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Multipart XML types
+
+// InitiateMultipartUploadResult is the response for CreateMultipartUpload.
+type InitiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+// CompleteMultipartUploadRequest is the request body for CompleteMultipartUpload.
+type CompleteMultipartUploadRequest struct {
+	XMLName xml.Name            `xml:"CompleteMultipartUpload"`
+	Parts   []CompletedPartInfo `xml:"Part"`
+}
+
+// CompletedPartInfo identifies a part in a CompleteMultipartUpload request.
+type CompletedPartInfo struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// CompleteMultipartUploadResult is the response for CompleteMultipartUpload.
+type CompleteMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+}
+
+// ListPartsResult is the response for ListParts.
+type ListPartsResult struct {
+	XMLName              xml.Name   `xml:"ListPartsResult"`
+	Bucket               string     `xml:"Bucket"`
+	Key                  string     `xml:"Key"`
+	UploadID             string     `xml:"UploadId"`
+	Initiator            Owner      `xml:"Initiator"`
+	Owner                Owner      `xml:"Owner"`
+	PartNumberMarker     int        `xml:"PartNumberMarker"`
+	NextPartNumberMarker int        `xml:"NextPartNumberMarker"`
+	MaxParts             int        `xml:"MaxParts"`
+	IsTruncated          bool       `xml:"IsTruncated"`
+	Parts                []PartInfo `xml:"Part"`
+}
+
+// PartInfo represents a part in a ListParts response.
+type PartInfo struct {
+	PartNumber   int    `xml:"PartNumber"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+}
+
+// ListMultipartUploadsResult is the response for ListMultipartUploads.
+type ListMultipartUploadsResult struct {
+	XMLName            xml.Name     `xml:"ListMultipartUploadsResult"`
+	Bucket             string       `xml:"Bucket"`
+	Prefix             string       `xml:"Prefix"`
+	KeyMarker          string       `xml:"KeyMarker"`
+	UploadIDMarker     string       `xml:"UploadIdMarker"`
+	NextKeyMarker      string       `xml:"NextKeyMarker,omitempty"`
+	NextUploadIDMarker string       `xml:"NextUploadIdMarker,omitempty"`
+	MaxUploads         int          `xml:"MaxUploads"`
+	IsTruncated        bool         `xml:"IsTruncated"`
+	Uploads            []UploadInfo `xml:"Upload"`
+}
+
+// UploadInfo represents an in-progress upload in a ListMultipartUploads response.
+type UploadInfo struct {
+	Key       string `xml:"Key"`
+	UploadID  string `xml:"UploadId"`
+	Initiator Owner  `xml:"Initiator"`
+	Initiated string `xml:"Initiated"`
+}
@@ -0,0 +1,178 @@
+package s3
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// maxNotificationBodySize bounds PutBucketNotificationConfiguration request bodies.
+const maxNotificationBodySize = 256 * 1024
+
+// getBucketNotification handles GET /{bucket}?notification.
+func (s *Server) getBucketNotification(w http.ResponseWriter, r *http.Request, bucket string) {
+	s.withMetrics(w, "GetBucketNotificationConfiguration", func(rec http.ResponseWriter) {
+		if _, err := s.authorizer.AuthorizeRequest(r, "get", "buckets", bucket, ""); err != nil {
+			s.handleAuthError(rec, err)
+			return
+		}
+
+		rules, err := s.store.GetBucketNotifications(r.Context(), bucket)
+		if err != nil {
+			s.writeNotificationError(rec, err)
+			return
+		}
+
+		s.writeXML(rec, http.StatusOK, newNotificationConfiguration(rules))
+	})
+}
+
+// putBucketNotification handles PUT /{bucket}?notification. An empty
+// configuration disables notifications.
+func (s *Server) putBucketNotification(w http.ResponseWriter, r *http.Request, bucket string) {
+	s.withMetrics(w, "PutBucketNotificationConfiguration", func(rec http.ResponseWriter) {
+		if _, err := s.authorizer.AuthorizeRequest(r, "put", "buckets", bucket, ""); err != nil {
+			s.handleAuthError(rec, err)
+			return
+		}
+
+		var req NotificationConfiguration
+		if !s.decodeXMLBody(rec, r, maxNotificationBodySize, &req) {
+			return
+		}
+		rules, err := req.notificationRules()
+		if err != nil {
+			s.writeNotificationError(rec, err)
+			return
+		}
+
+		if err := s.store.SetBucketNotifications(r.Context(), bucket, rules); err != nil {
+			s.writeNotificationError(rec, err)
+			return
+		}
+
+		rec.WriteHeader(http.StatusOK)
+	})
+}
+
+// writeNotificationError maps notification store errors to S3 error responses.
+func (s *Server) writeNotificationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrBucketNotFound):
+		s.writeError(w, http.StatusNotFound, "NoSuchBucket", "Bucket not found")
+	case errors.Is(err, ErrInvalidRequest):
+		s.writeError(w, http.StatusBadRequest, "InvalidArgument", err.Error())
+	default:
+		s.writeError(w, http.StatusInternalServerError, "InternalError", err.Error())
+	}
+}
+
+// notify sends a bucket event if a notifier is configured.
+func (s *Server) notify(name, bucket, key, userID string, meta *ObjectMeta) {
+	if s.notifier == nil {
+		return
+	}
+	event := BucketEvent{Name: name, Bucket: bucket, Key: key, UserID: userID}
+	if meta != nil {
+		event.Size = meta.Size
+		event.ETag = meta.ETag
+		event.VersionID = meta.VersionID
+	}
+	s.notifier.NotifyEvent(event)
+}
+
+// deleteEventName returns the event for a delete: removing a specific version
+// is a permanent delete, otherwise a delete marker may have been created.
+func deleteEventName(versionID string, outcome DeleteOutcome) string {
+	if versionID == "" && outcome.DeleteMarker {
+		return EventObjectRemovedDeleteMarkerCreated
+	}
+	return EventObjectRemovedDelete
+}
+
+// Notification XML types
+
+// NotificationConfiguration is the request and response body for
+// Get/PutBucketNotificationConfiguration. WebhookConfiguration is a TunnelMesh
+// extension; the AWS destination types are not supported.
+type NotificationConfiguration struct {
+	XMLName   xml.Name               `xml:"NotificationConfiguration"`
+	Webhooks  []WebhookConfiguration `xml:"WebhookConfiguration"`
+	Topics    []struct{}             `xml:"TopicConfiguration"`
+	Queues    []struct{}             `xml:"QueueConfiguration"`
+	Functions []struct{}             `xml:"CloudFunctionConfiguration"`
+}
+
+// WebhookConfiguration subscribes to bucket events. Without an Endpoint,
+// events are only published on the coordinator's event stream.
+type WebhookConfiguration struct {
+	ID       string              `xml:"Id,omitempty"`
+	Endpoint string              `xml:"Endpoint,omitempty"`
+	Events   []string            `xml:"Event"`
+	Filter   *NotificationFilter `xml:"Filter,omitempty"`
+}
+
+// NotificationFilter restricts a notification to matching keys.
+type NotificationFilter struct {
+	S3Key NotificationKeyFilter `xml:"S3Key"`
+}
+
+// NotificationKeyFilter holds the prefix and suffix filter rules.
+type NotificationKeyFilter struct {
+	FilterRules []FilterRule `xml:"FilterRule"`
+}
+
+// FilterRule is a prefix or suffix key filter.
+type FilterRule struct {
+	Name  string `xml:"Name"`
+	Value string `xml:"Value"`
+}
+
+// newNotificationConfiguration builds the XML form of notification rules.
+func newNotificationConfiguration(rules []NotificationRule) NotificationConfiguration {
+	var cfg NotificationConfiguration
+	for _, rule := range rules {
+		x := WebhookConfiguration{ID: rule.ID, Endpoint: rule.Endpoint, Events: rule.Events}
+		var filters []FilterRule
+		if rule.Prefix != "" {
+			filters = append(filters, FilterRule{Name: "prefix", Value: rule.Prefix})
+		}
+		if rule.Suffix != "" {
+			filters = append(filters, FilterRule{Name: "suffix", Value: rule.Suffix})
+		}
+		if len(filters) > 0 {
+			x.Filter = &NotificationFilter{S3Key: NotificationKeyFilter{FilterRules: filters}}
+		}
+		cfg.Webhooks = append(cfg.Webhooks, x)
+	}
+	return cfg
+}
+
+// notificationRules converts the XML form to notification rules. Limits are
+// checked by the store.
+func (c *NotificationConfiguration) notificationRules() ([]NotificationRule, error) {
+	if len(c.Topics) > 0 || len(c.Queues) > 0 || len(c.Functions) > 0 {
+		return nil, fmt.Errorf("%w: only WebhookConfiguration destinations are supported", ErrInvalidRequest)
+	}
+
+	rules := make([]NotificationRule, 0, len(c.Webhooks))
+	for _, x := range c.Webhooks {
+		rule := NotificationRule{ID: x.ID, Events: x.Events, Endpoint: x.Endpoint}
+		if x.Filter != nil {
+			for _, f := range x.Filter.S3Key.FilterRules {
+				switch strings.ToLower(f.Name) {
+				case "prefix":
+					rule.Prefix = f.Value
+				case "suffix":
+					rule.Suffix = f.Value
+				default:
+					return nil, fmt.Errorf("%w: filter rule name must be prefix or suffix", ErrInvalidRequest)
+				}
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
@@ -0,0 +1,193 @@
+package s3
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// byteRange is a resolved, satisfiable byte window of an object.
+type byteRange struct {
+	start  int64
+	length int64
+}
+
+// contentRange formats the Content-Range header value for the window.
+func (br *byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", br.start, br.start+br.length-1, size)
+}
+
+// resolveObjectRead evaluates conditional headers, Range and partNumber for a
+// GET or HEAD of meta. If the request is fully answered (304, 412, 416 or a
+// bad argument) it writes the response and returns done. Otherwise it returns
+// the window to serve, or nil for the whole object.
+func (s *Server) resolveObjectRead(w http.ResponseWriter, r *http.Request, meta *ObjectMeta) (br *byteRange, done bool) {
+	switch checkPreconditions(r, meta) {
+	case http.StatusPreconditionFailed:
+		s.writeError(w, http.StatusPreconditionFailed, "PreconditionFailed", "At least one of the pre-conditions you specified did not hold")
+		return nil, true
+	case http.StatusNotModified:
+		w.Header().Set("ETag", meta.ETag)
+		w.Header().Set("Last-Modified", meta.LastModified.Format(http.TimeFormat))
+		w.WriteHeader(http.StatusNotModified)
+		return nil, true
+	}
+
+	rangeHeader := r.Header.Get("Range")
+	if pn := r.URL.Query().Get("partNumber"); pn != "" {
+		if rangeHeader != "" {
+			s.writeError(w, http.StatusBadRequest, "InvalidRequest", "Cannot specify both Range header and partNumber")
+			return nil, true
+		}
+		partNumber, err := strconv.Atoi(pn)
+		if err != nil || partNumber < 1 || partNumber > MaxPartNumber {
+			s.writeError(w, http.StatusBadRequest, "InvalidArgument", "Part number must be an integer between 1 and 10000")
+			return nil, true
+		}
+		br, ok := partRange(meta, partNumber)
+		if !ok {
+			s.writeError(w, http.StatusRequestedRangeNotSatisfiable, "InvalidPartNumber", "The requested partnumber is not satisfiable")
+			return nil, true
+		}
+		if len(meta.PartSizes) > 0 {
+			w.Header().Set("x-amz-mp-parts-count", strconv.Itoa(len(meta.PartSizes)))
+		}
+		return br, false
+	}
+
+	if rangeHeader == "" || !ifRangeMatches(r, meta) {
+		return nil, false
+	}
+	br, err := parseRange(rangeHeader, meta.Size)
+	if err != nil {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", meta.Size))
+		s.writeError(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange", "The requested range is not satisfiable")
+		return nil, true
+	}
+	return br, false
+}
+
+// checkPreconditions evaluates If-Match, If-Unmodified-Since, If-None-Match and
+// If-Modified-Since in RFC 9110 order. Returns 0 if the request should proceed,
+// or http.StatusPreconditionFailed / http.StatusNotModified.
+func checkPreconditions(r *http.Request, meta *ObjectMeta) int {
+	lastModified := meta.LastModified.Truncate(time.Second)
+
+	if im := r.Header.Get("If-Match"); im != "" {
+		if !etagListMatches(im, meta.ETag) {
+			return http.StatusPreconditionFailed
+		}
+	} else if ius := r.Header.Get("If-Unmodified-Since"); ius != "" {
+		if t, err := http.ParseTime(ius); err == nil && lastModified.After(t) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if etagListMatches(inm, meta.ETag) {
+			return http.StatusNotModified
+		}
+	} else if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		if t, err := http.ParseTime(ims); err == nil && !lastModified.After(t) {
+			return http.StatusNotModified
+		}
+	}
+
+	return 0
+}
+
+// ifRangeMatches reports whether a Range header should be honoured given
+// If-Range. A missing If-Range always matches.
+func ifRangeMatches(r *http.Request, meta *ObjectMeta) bool {
+	ir := r.Header.Get("If-Range")
+	if ir == "" {
+		return true
+	}
+	if t, err := http.ParseTime(ir); err == nil {
+		return !meta.LastModified.Truncate(time.Second).After(t)
+	}
+	return normalizeETag(ir) == normalizeETag(meta.ETag)
+}
+
+// etagListMatches reports whether a comma-separated If-Match/If-None-Match
+// value matches etag. Weak validators compare equal to strong ones.
+func etagListMatches(list, etag string) bool {
+	want := normalizeETag(etag)
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || normalizeETag(candidate) == want {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeETag(etag string) string {
+	return strings.Trim(strings.TrimPrefix(strings.TrimSpace(etag), "W/"), "\"")
+}
+
+// parseRange resolves a single "bytes=" Range header against an object size.
+// Malformed or multi-range headers return (nil, nil) so the whole object is
+// served; syntactically valid but unsatisfiable ranges return ErrInvalidRange.
+func parseRange(header string, size int64) (*byteRange, error) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return nil, nil
+	}
+	startStr, endStr, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return nil, nil
+	}
+
+	if startStr == "" {
+		// Suffix range: last N bytes
+		n, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || n < 0 {
+			return nil, nil
+		}
+		if n == 0 || size == 0 {
+			return nil, ErrInvalidRange
+		}
+		n = min(n, size)
+		return &byteRange{start: size - n, length: n}, nil
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 {
+		return nil, nil
+	}
+	end := size - 1
+	if endStr != "" {
+		end, err = strconv.ParseInt(endStr, 10, 64)
+		if err != nil || end < start {
+			return nil, nil
+		}
+		end = min(end, size-1)
+	}
+	if start >= size {
+		return nil, ErrInvalidRange
+	}
+	return &byteRange{start: start, length: end - start + 1}, nil
+}
+
+// partRange returns the byte window of part partNumber of a multipart object.
+// Objects not created by multipart upload are treated as a single part. Empty
+// parts, which have no byte window, are not satisfiable.
+func partRange(meta *ObjectMeta, partNumber int) (*byteRange, bool) {
+	if len(meta.PartSizes) == 0 {
+		if partNumber != 1 || meta.Size == 0 {
+			return nil, false
+		}
+		return &byteRange{start: 0, length: meta.Size}, true
+	}
+	if partNumber > len(meta.PartSizes) || meta.PartSizes[partNumber-1] == 0 {
+		return nil, false
+	}
+	var start int64
+	for _, sz := range meta.PartSizes[:partNumber-1] {
+		start += sz
+	}
+	return &byteRange{start: start, length: meta.PartSizes[partNumber-1]}, true
+}
@@ -0,0 +1,194 @@
+package s3
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// maxTaggingBodySize bounds PutObjectTagging request bodies. Ten maximum-length
+// tags fit comfortably.
+const maxTaggingBodySize = 64 * 1024
+
+// getObjectTagging handles GET /{bucket}/{key}?tagging.
+func (s *Server) getObjectTagging(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	s.withMetrics(w, "GetObjectTagging", func(rec http.ResponseWriter) {
+		if _, err := s.authorizer.AuthorizeRequest(r, "get", "objects", bucket, key); err != nil {
+			s.handleAuthError(rec, err)
+			return
+		}
+
+		tags, err := s.store.GetObjectTagging(r.Context(), bucket, key)
+		if err != nil {
+			if (errors.Is(err, ErrObjectNotFound) || errors.Is(err, ErrBucketNotFound)) && s.forwarder != nil {
+				if s.forwarder.ForwardS3Request(w, r, bucket, key, "9000") {
+					return
+				}
+			}
+			s.writeTaggingError(rec, err)
+			return
+		}
+
+		s.writeXML(rec, http.StatusOK, newTagging(tags))
+	})
+}
+
+// putObjectTagging handles PUT /{bucket}/{key}?tagging.
+func (s *Server) putObjectTagging(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	s.withMetrics(w, "PutObjectTagging", func(rec http.ResponseWriter) {
+		userID, ok := s.authorizeRetag(rec, r, bucket, key)
+		if !ok {
+			return
+		}
+
+		var req Tagging
+		if !s.decodeXMLBody(rec, r, maxTaggingBodySize, &req) {
+			return
+		}
+		tags, err := req.tags()
+		if err != nil {
+			s.writeTaggingError(rec, err)
+			return
+		}
+		if !s.authorizeTagWrite(rec, userID, bucket, key, tags) {
+			return
+		}
+
+		if err := s.store.PutObjectTagging(r.Context(), bucket, key, tags); err != nil {
+			s.writeTaggingError(rec, err)
+			return
+		}
+
+		rec.WriteHeader(http.StatusOK)
+		s.enqueueReplication(bucket, key, "put")
+	})
+}
+
+// deleteObjectTagging handles DELETE /{bucket}/{key}?tagging.
+func (s *Server) deleteObjectTagging(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	s.withMetrics(w, "DeleteObjectTagging", func(rec http.ResponseWriter) {
+		userID, ok := s.authorizeRetag(rec, r, bucket, key)
+		if !ok || !s.authorizeTagWrite(rec, userID, bucket, key, nil) {
+			return
+		}
+
+		if err := s.store.DeleteObjectTagging(r.Context(), bucket, key); err != nil {
+			s.writeTaggingError(rec, err)
+			return
+		}
+
+		rec.WriteHeader(http.StatusNoContent)
+		s.enqueueReplication(bucket, key, "put")
+	})
+}
+
+// authorizeRetag authorizes changing the tags of an object, writing an error
+// response if not allowed. Retagging keeps the object's content, so besides
+// writing the object the user must be able to read it as it is: otherwise
+// retagging could move content they can't read into a binding that lets them.
+func (s *Server) authorizeRetag(w http.ResponseWriter, r *http.Request, bucket, key string) (string, bool) {
+	userID, err := s.authorizer.AuthorizeRequest(r, "put", "objects", bucket, key)
+	if err == nil {
+		_, err = s.authorizer.AuthorizeRequest(r, "get", "objects", bucket, key)
+	}
+	if err != nil {
+		s.handleAuthError(w, err)
+		return "", false
+	}
+	return userID, true
+}
+
+// authorizeTagWrite checks that the user may write an object carrying the
+// tags a request leaves on it, writing an error response if not.
+func (s *Server) authorizeTagWrite(w http.ResponseWriter, userID, bucket, key string, tags map[string]string) bool {
+	ta, ok := s.authorizer.(TagAuthorizer)
+	if !ok || ta.AuthorizeTags(userID, "put", "objects", bucket, key, tags) {
+		return true
+	}
+	log.Info().Str("user_id", userID).Str("bucket", bucket).Str("object", key).Msg("S3 access denied: requested tags not permitted")
+	s.handleAuthError(w, ErrAccessDenied)
+	return false
+}
+
+func (s *Server) writeTaggingError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrBucketNotFound):
+		s.writeError(w, http.StatusNotFound, "NoSuchBucket", "Bucket not found")
+	case errors.Is(err, ErrObjectNotFound):
+		s.writeError(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist")
+	case errors.Is(err, ErrInvalidTag):
+		s.writeError(w, http.StatusBadRequest, "InvalidTag", err.Error())
+	default:
+		s.writeError(w, http.StatusInternalServerError, "InternalError", err.Error())
+	}
+}
+
+// parseTaggingHeader parses an x-amz-tagging header, which carries tags as
+// URL query parameters (e.g. "env=prod&team=ops").
+func parseTaggingHeader(header string) (map[string]string, error) {
+	values, err := url.ParseQuery(header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTag, err)
+	}
+	tags := make(map[string]string, len(values))
+	for k, v := range values {
+		if len(v) != 1 {
+			return nil, fmt.Errorf("%w: duplicate tag key %q", ErrInvalidTag, k)
+		}
+		tags[k] = v[0]
+	}
+	return tags, nil
+}
+
+// Tagging XML types
+
+// Tagging is the request and response body for the object tagging API.
+type Tagging struct {
+	XMLName xml.Name `xml:"Tagging"`
+	TagSet  TagSet   `xml:"TagSet"`
+}
+
+// TagSet holds the tags of a Tagging document. It is always encoded, even
+// when empty.
+type TagSet struct {
+	Tags []Tag `xml:"Tag"`
+}
+
+// Tag is a single object tag.
+type Tag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+// newTagging builds a Tagging document with tags sorted by key.
+func newTagging(tags map[string]string) Tagging {
+	var t Tagging
+	for k, v := range tags {
+		t.TagSet.Tags = append(t.TagSet.Tags, Tag{Key: k, Value: v})
+	}
+	slices.SortFunc(t.TagSet.Tags, func(a, b Tag) int { return strings.Compare(a.Key, b.Key) })
+	return t
+}
+
+// tags converts the tag set to a map, rejecting duplicate keys.
+func (t *Tagging) tags() (map[string]string, error) {
+	return tagMap(t.TagSet.Tags)
+}
+
+// tagMap converts XML tags to a map, rejecting duplicate keys.
+func tagMap(list []Tag) (map[string]string, error) {
+	tags := make(map[string]string, len(list))
+	for _, tag := range list {
+		if _, dup := tags[tag.Key]; dup {
+			return nil, fmt.Errorf("%w: duplicate tag key %q", ErrInvalidTag, tag.Key)
+		}
+		tags[tag.Key] = tag.Value
+	}
+	return tags, nil
+}
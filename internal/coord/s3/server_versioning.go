@@ -0,0 +1,229 @@
+package s3
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxVersioningBodySize bounds PutBucketVersioning request bodies.
+const maxVersioningBodySize = 64 * 1024
+
+// setVersionIDHeader sets x-amz-version-id for objects that have a version ID.
+func setVersionIDHeader(h http.Header, versionID string) {
+	if versionID != "" {
+		h.Set("x-amz-version-id", versionID)
+	}
+}
+
+// writeDeleteMarkerResponse writes the S3 response for a read that resolved to
+// a delete marker: 405 when the marker was requested by version ID, or 404 when
+// the latest version of the key is a marker. Both carry x-amz-delete-marker.
+// Returns false if err is unrelated to delete markers and nothing was written.
+// Delete markers are authoritative, so these reads are never forwarded.
+func (s *Server) writeDeleteMarkerResponse(w http.ResponseWriter, r *http.Request, bucket, key string, meta *ObjectMeta, err error, withBody bool) bool {
+	var status int
+	var code, message string
+
+	switch {
+	case errors.Is(err, ErrDeleteMarker):
+		setVersionIDHeader(w.Header(), meta.VersionID)
+		w.Header().Set("Last-Modified", meta.LastModified.Format(http.TimeFormat))
+		status, code, message = http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource."
+	case errors.Is(err, ErrObjectNotFound) && r.URL.Query().Get("versionId") == "":
+		markerID, ok := s.store.LatestDeleteMarker(r.Context(), bucket, key)
+		if !ok {
+			return false
+		}
+		setVersionIDHeader(w.Header(), markerID)
+		status, code, message = http.StatusNotFound, "NoSuchKey", "Object not found"
+	default:
+		return false
+	}
+
+	w.Header().Set("x-amz-delete-marker", "true")
+	if withBody {
+		s.writeError(w, status, code, message)
+	} else {
+		w.WriteHeader(status)
+	}
+	return true
+}
+
+// replicateDelete replicates the state of a key after a delete. Deleting a
+// specific version can promote an older version to current, so the resulting
+// current version is replicated as a put; otherwise the key is deleted.
+func (s *Server) replicateDelete(r *http.Request, bucket, key, versionID string) {
+	if versionID != "" {
+		if _, err := s.store.HeadObject(r.Context(), bucket, key); err == nil {
+			s.enqueueReplication(bucket, key, "put")
+			return
+		}
+	}
+	s.enqueueReplication(bucket, key, "delete")
+}
+
+// getBucketVersioning handles GET /{bucket}?versioning.
+func (s *Server) getBucketVersioning(w http.ResponseWriter, r *http.Request, bucket string) {
+	s.withMetrics(w, "GetBucketVersioning", func(rec http.ResponseWriter) {
+		if _, err := s.authorizer.AuthorizeRequest(r, "get", "buckets", bucket, ""); err != nil {
+			s.handleAuthError(rec, err)
+			return
+		}
+
+		meta, err := s.store.HeadBucket(r.Context(), bucket)
+		if err != nil {
+			if errors.Is(err, ErrBucketNotFound) {
+				s.writeError(rec, http.StatusNotFound, "NoSuchBucket", "Bucket not found")
+				return
+			}
+			s.writeError(rec, http.StatusInternalServerError, "InternalError", err.Error())
+			return
+		}
+
+		s.writeXML(rec, http.StatusOK, VersioningConfiguration{Status: meta.Versioning})
+	})
+}
+
+// putBucketVersioning handles PUT /{bucket}?versioning.
+func (s *Server) putBucketVersioning(w http.ResponseWriter, r *http.Request, bucket string) {
+	s.withMetrics(w, "PutBucketVersioning", func(rec http.ResponseWriter) {
+		if _, err := s.authorizer.AuthorizeRequest(r, "put", "buckets", bucket, ""); err != nil {
+			s.handleAuthError(rec, err)
+			return
+		}
+
+		var req VersioningConfiguration
+		if !s.decodeXMLBody(rec, r, maxVersioningBodySize, &req) {
+			return
+		}
+
+		if err := s.store.SetBucketVersioning(r.Context(), bucket, req.Status); err != nil {
+			switch {
+			case errors.Is(err, ErrBucketNotFound):
+				s.writeError(rec, http.StatusNotFound, "NoSuchBucket", "Bucket not found")
+			case errors.Is(err, ErrInvalidRequest):
+				s.writeError(rec, http.StatusBadRequest, "MalformedXML", err.Error())
+			default:
+				s.writeError(rec, http.StatusInternalServerError, "InternalError", err.Error())
+			}
+			return
+		}
+
+		rec.WriteHeader(http.StatusOK)
+	})
+}
+
+// listObjectVersions handles GET /{bucket}?versions.
+func (s *Server) listObjectVersions(w http.ResponseWriter, r *http.Request, bucket string) {
+	s.withMetrics(w, "ListObjectVersions", func(rec http.ResponseWriter) {
+		userID, err := s.authorizer.AuthorizeRequest(r, "list", "objects", bucket, "")
+		if err != nil {
+			s.handleAuthError(rec, err)
+			return
+		}
+
+		query := r.URL.Query()
+		prefix := query.Get("prefix")
+		keyMarker := query.Get("key-marker")
+		versionIDMarker := query.Get("version-id-marker")
+		maxKeys := 1000 // default
+		if mk := query.Get("max-keys"); mk != "" {
+			if parsed, err := strconv.Atoi(mk); err == nil && parsed > 0 && parsed <= 1000 {
+				maxKeys = parsed
+			}
+		}
+
+		versions, isTruncated, nextKeyMarker, nextVersionIDMarker, err := s.store.ListObjectVersions(r.Context(), bucket, prefix, keyMarker, versionIDMarker, maxKeys)
+		if err != nil {
+			if errors.Is(err, ErrBucketNotFound) {
+				s.writeError(rec, http.StatusNotFound, "NoSuchBucket", "Bucket not found")
+				return
+			}
+			s.writeError(rec, http.StatusInternalServerError, "InternalError", err.Error())
+			return
+		}
+
+		resp := ListVersionsResult{
+			Name:                bucket,
+			Prefix:              prefix,
+			KeyMarker:           keyMarker,
+			VersionIDMarker:     versionIDMarker,
+			MaxKeys:             maxKeys,
+			IsTruncated:         isTruncated,
+			NextKeyMarker:       nextKeyMarker,
+			NextVersionIDMarker: nextVersionIDMarker,
+		}
+
+		// Filter versions by allowed prefixes
+		allowedPrefixes := s.authorizer.GetAllowedPrefixes(userID, bucket)
+		for _, v := range versions {
+			if allowedPrefixes != nil && !hasAnyPrefix(v.Key, allowedPrefixes) {
+				continue
+			}
+			if v.DeleteMarker {
+				resp.DeleteMarkers = append(resp.DeleteMarkers, DeleteMarkerEntry{
+					Key:          v.Key,
+					VersionID:    v.VersionID,
+					IsLatest:     v.IsCurrent,
+					LastModified: v.LastModified.Format(time.RFC3339),
+				})
+				continue
+			}
+			resp.Versions = append(resp.Versions, ObjectVersion{
+				Key:          v.Key,
+				VersionID:    v.VersionID,
+				IsLatest:     v.IsCurrent,
+				LastModified: v.LastModified.Format(time.RFC3339),
+				ETag:         v.ETag,
+				Size:         v.Size,
+			})
+		}
+
+		s.writeXML(rec, http.StatusOK, resp)
+	})
+}
+
+// Versioning XML types
+
+// VersioningConfiguration is the request and response body for
+// Get/PutBucketVersioning.
+type VersioningConfiguration struct {
+	XMLName xml.Name `xml:"VersioningConfiguration"`
+	Status  string   `xml:"Status,omitempty"`
+}
+
+// ListVersionsResult is the response for ListObjectVersions.
+type ListVersionsResult struct {
+	XMLName             xml.Name            `xml:"ListVersionsResult"`
+	Name                string              `xml:"Name"`
+	Prefix              string              `xml:"Prefix"`
+	KeyMarker           string              `xml:"KeyMarker"`
+	VersionIDMarker     string              `xml:"VersionIdMarker"`
+	MaxKeys             int                 `xml:"MaxKeys"`
+	IsTruncated         bool                `xml:"IsTruncated"`
+	NextKeyMarker       string              `xml:"NextKeyMarker,omitempty"`
+	NextVersionIDMarker string              `xml:"NextVersionIdMarker,omitempty"`
+	Versions            []ObjectVersion     `xml:"Version"`
+	DeleteMarkers       []DeleteMarkerEntry `xml:"DeleteMarker"`
+}
+
+// ObjectVersion is an object version in a ListObjectVersions response.
+type ObjectVersion struct {
+	Key          string `xml:"Key"`
+	VersionID    string `xml:"VersionId"`
+	IsLatest     bool   `xml:"IsLatest"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+}
+
+// DeleteMarkerEntry is a delete marker in a ListObjectVersions response.
+type DeleteMarkerEntry struct {
+	Key          string `xml:"Key"`
+	VersionID    string `xml:"VersionId"`
+	IsLatest     bool   `xml:"IsLatest"`
+	LastModified string `xml:"LastModified"`
+}
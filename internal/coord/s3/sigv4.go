@@ -0,0 +1,565 @@
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AWS Signature Version 4 constants.
+const (
+	sigV4Algorithm = "AWS4-HMAC-SHA256"
+	sigV4Service   = "s3"
+	sigV4Terminal  = "aws4_request"
+	sigV4TimeFmt   = "20060102T150405Z"
+	sigV4DateFmt   = "20060102"
+
+	// DefaultRegion is the region used in presigned URLs when none is given.
+	// The gateway accepts any region in the credential scope.
+	DefaultRegion = "us-east-1"
+
+	// MaxClockSkew is how far X-Amz-Date may drift from the server clock.
+	MaxClockSkew = 15 * time.Minute
+
+	// MaxPresignExpiry is the longest validity a presigned URL can have (matches AWS).
+	MaxPresignExpiry = 7 * 24 * time.Hour
+
+	unsignedPayload        = "UNSIGNED-PAYLOAD"
+	streamingSignedPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	streamingUnsignedTrail = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+	emptySHA256            = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	// maxStreamingChunkSize bounds a single aws-chunked chunk, which must be
+	// buffered in full before its signature can be checked.
+	maxStreamingChunkSize = 16 << 20
+)
+
+// sigV4Request holds the parsed authentication parameters of a SigV4 request,
+// from either the Authorization header or presigned query parameters.
+type sigV4Request struct {
+	accessKey     string
+	date          string // YYYYMMDD from the credential scope
+	region        string
+	signedHeaders []string
+	signature     string
+	amzDate       time.Time
+	expires       time.Duration // Zero for header auth
+	presigned     bool
+}
+
+// scope returns the credential scope string.
+func (sr *sigV4Request) scope() string {
+	return sr.date + "/" + sr.region + "/" + sigV4Service + "/" + sigV4Terminal
+}
+
+// isSigV4Request reports whether r carries SigV4 header or query authentication.
+func isSigV4Request(r *http.Request) bool {
+	if strings.HasPrefix(r.Header.Get("Authorization"), sigV4Algorithm+" ") {
+		return true
+	}
+	return r.URL.Query().Get("X-Amz-Algorithm") == sigV4Algorithm
+}
+
+// parseSigV4Request extracts SigV4 parameters from the Authorization header or,
+// for presigned URLs, from the query string.
+func parseSigV4Request(r *http.Request) (*sigV4Request, error) {
+	var (
+		sr         sigV4Request
+		credential string
+		signed     string
+		amzDate    string
+	)
+
+	if authz := r.Header.Get("Authorization"); strings.HasPrefix(authz, sigV4Algorithm+" ") {
+		for _, field := range strings.Split(strings.TrimPrefix(authz, sigV4Algorithm+" "), ",") {
+			k, v, ok := strings.Cut(strings.TrimSpace(field), "=")
+			if !ok {
+				continue
+			}
+			switch k {
+			case "Credential":
+				credential = v
+			case "SignedHeaders":
+				signed = v
+			case "Signature":
+				sr.signature = v
+			}
+		}
+		amzDate = r.Header.Get("X-Amz-Date")
+		if amzDate == "" {
+			// SigV4 allows the standard Date header when X-Amz-Date is absent.
+			if t, err := http.ParseTime(r.Header.Get("Date")); err == nil {
+				amzDate = t.UTC().Format(sigV4TimeFmt)
+			}
+		}
+	} else {
+		q := r.URL.Query()
+		if q.Get("X-Amz-Algorithm") != sigV4Algorithm {
+			return nil, fmt.Errorf("%w: unsupported algorithm", ErrAccessDenied)
+		}
+		sr.presigned = true
+		credential = q.Get("X-Amz-Credential")
+		signed = q.Get("X-Amz-SignedHeaders")
+		sr.signature = q.Get("X-Amz-Signature")
+		amzDate = q.Get("X-Amz-Date")
+
+		secs, err := strconv.ParseInt(q.Get("X-Amz-Expires"), 10, 64)
+		if err != nil || secs < 1 || time.Duration(secs)*time.Second > MaxPresignExpiry {
+			return nil, fmt.Errorf("%w: X-Amz-Expires must be between 1 and %d seconds", ErrAccessDenied, int(MaxPresignExpiry.Seconds()))
+		}
+		sr.expires = time.Duration(secs) * time.Second
+	}
+
+	// Credential=AKID/20240101/us-east-1/s3/aws4_request
+	credParts := strings.Split(credential, "/")
+	if len(credParts) != 5 || credParts[0] == "" || credParts[3] != sigV4Service || credParts[4] != sigV4Terminal {
+		return nil, fmt.Errorf("%w: malformed credential scope", ErrAccessDenied)
+	}
+	sr.accessKey, sr.date, sr.region = credParts[0], credParts[1], credParts[2]
+
+	if signed == "" || sr.signature == "" {
+		return nil, fmt.Errorf("%w: missing SignedHeaders or Signature", ErrAccessDenied)
+	}
+	sr.signedHeaders = strings.Split(signed, ";")
+	if !containsString(sr.signedHeaders, "host") {
+		return nil, fmt.Errorf("%w: host must be a signed header", ErrAccessDenied)
+	}
+
+	t, err := time.Parse(sigV4TimeFmt, amzDate)
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing or malformed X-Amz-Date", ErrAccessDenied)
+	}
+	if t.Format(sigV4DateFmt) != sr.date {
+		return nil, fmt.Errorf("%w: credential date does not match X-Amz-Date", ErrAccessDenied)
+	}
+	sr.amzDate = t
+
+	return &sr, nil
+}
+
+// checkTime validates the request timestamp against now. Header-signed requests
+// must be within MaxClockSkew; presigned URLs are valid from their signing time
+// (allowing for skew) until the signing time plus X-Amz-Expires.
+func (sr *sigV4Request) checkTime(now time.Time) error {
+	if sr.amzDate.After(now.Add(MaxClockSkew)) {
+		return ErrRequestTimeTooSkewed
+	}
+	if sr.presigned {
+		if now.After(sr.amzDate.Add(sr.expires)) {
+			return ErrRequestExpired
+		}
+		return nil
+	}
+	if now.Sub(sr.amzDate) > MaxClockSkew {
+		return ErrRequestTimeTooSkewed
+	}
+	return nil
+}
+
+// verifySigV4 verifies the SigV4 signature on r using secretKey. On success,
+// it arranges for the request body to be checked against the signed payload
+// hash: hex hashes are verified when the body is fully read, and aws-chunked
+// bodies are replaced by a decoder that verifies each chunk signature.
+func verifySigV4(r *http.Request, sr *sigV4Request, secretKey string, now time.Time) error {
+	if err := sr.checkTime(now); err != nil {
+		return err
+	}
+
+	payloadHash := unsignedPayload
+	if !sr.presigned {
+		payloadHash = r.Header.Get("X-Amz-Content-Sha256")
+		if payloadHash == "" {
+			return fmt.Errorf("%w: missing x-amz-content-sha256", ErrAccessDenied)
+		}
+	}
+
+	canonical := canonicalRequest(r, sr, payloadHash)
+	key := sigV4SigningKey(secretKey, sr.date, sr.region)
+	expected := hex.EncodeToString(hmacSHA256(key, sigV4StringToSign(sr.amzDate, sr.scope(), canonical)))
+	if !hmac.Equal([]byte(expected), []byte(sr.signature)) {
+		return ErrSignatureMismatch
+	}
+
+	switch r.Body.(type) {
+	case *awsChunkedReader, *payloadHashReader:
+		// Body already wrapped by an earlier authorization of this request.
+		return nil
+	}
+
+	switch payloadHash {
+	case unsignedPayload:
+	case streamingSignedPayload, streamingUnsignedTrail:
+		decoded, err := strconv.ParseInt(r.Header.Get("X-Amz-Decoded-Content-Length"), 10, 64)
+		if err != nil || decoded < 0 {
+			return fmt.Errorf("%w: missing x-amz-decoded-content-length", ErrInvalidRequest)
+		}
+		cr := &awsChunkedReader{
+			br:       bufio.NewReader(r.Body),
+			closer:   r.Body,
+			trailer:  payloadHash == streamingUnsignedTrail,
+			expected: decoded,
+		}
+		if payloadHash == streamingSignedPayload {
+			cr.signingKey = key
+			cr.amzDate = sr.amzDate
+			cr.scope = sr.scope()
+			cr.prevSig = sr.signature
+		}
+		r.Body = cr
+		r.ContentLength = decoded
+	default:
+		want, err := hex.DecodeString(payloadHash)
+		if err != nil || len(want) != sha256.Size {
+			return fmt.Errorf("%w: malformed x-amz-content-sha256", ErrInvalidRequest)
+		}
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = &payloadHashReader{rc: r.Body, h: sha256.New(), want: want}
+		}
+	}
+	return nil
+}
+
+// canonicalRequest builds the SigV4 canonical request for r.
+func canonicalRequest(r *http.Request, sr *sigV4Request, payloadHash string) string {
+	var b strings.Builder
+	b.WriteString(r.Method)
+	b.WriteByte('\n')
+	b.WriteString(canonicalURI(r.URL.Path))
+	b.WriteByte('\n')
+	b.WriteString(canonicalQuery(r.URL.Query(), sr.presigned))
+	b.WriteByte('\n')
+	for _, name := range sr.signedHeaders {
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(canonicalHeaderValue(r, name))
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+	b.WriteString(strings.Join(sr.signedHeaders, ";"))
+	b.WriteByte('\n')
+	b.WriteString(payloadHash)
+	return b.String()
+}
+
+// canonicalURI encodes each path segment per SigV4 rules. S3 does not
+// double-encode the path, so the decoded path is encoded exactly once.
+func canonicalURI(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return sigV4Escape(path, false)
+}
+
+// canonicalQuery returns the sorted, encoded query string. For presigned
+// requests the signature parameter itself is excluded.
+func canonicalQuery(q url.Values, presigned bool) string {
+	pairs := make([]string, 0, len(q))
+	for k, vs := range q {
+		if presigned && k == "X-Amz-Signature" {
+			continue
+		}
+		ek := sigV4Escape(k, true)
+		for _, v := range vs {
+			pairs = append(pairs, ek+"="+sigV4Escape(v, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// canonicalHeaderValue returns the trimmed, whitespace-collapsed value of a
+// signed header. Host and Content-Length are not kept in r.Header by net/http,
+// so they are reconstructed from the request.
+func canonicalHeaderValue(r *http.Request, name string) string {
+	var values []string
+	switch name {
+	case "host":
+		host := r.Host
+		// Requests forwarded between coordinators have Host rewritten to the
+		// target address; the original is carried alongside. The S3 listener
+		// strips these headers from requests not sent by a coordinator.
+		if orig := r.Header.Get("X-TunnelMesh-Original-Host"); orig != "" && r.Header.Get("X-TunnelMesh-Forwarded") != "" {
+			host = orig
+		}
+		values = []string{host}
+	case "content-length":
+		values = r.Header.Values("Content-Length")
+		if len(values) == 0 && r.ContentLength >= 0 {
+			values = []string{strconv.FormatInt(r.ContentLength, 10)}
+		}
+	default:
+		values = r.Header.Values(name)
+	}
+	for i, v := range values {
+		values[i] = strings.Join(strings.Fields(v), " ")
+	}
+	return strings.Join(values, ",")
+}
+
+// sigV4Escape percent-encodes s per RFC 3986, leaving only unreserved
+// characters. Slashes are preserved unless encodeSlash is set.
+func sigV4Escape(s string, encodeSlash bool) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&0x0f])
+		}
+	}
+	return b.String()
+}
+
+// sigV4StringToSign builds the string to sign for a canonical request.
+func sigV4StringToSign(t time.Time, scope, canonical string) string {
+	sum := sha256.Sum256([]byte(canonical))
+	return sigV4Algorithm + "\n" + t.UTC().Format(sigV4TimeFmt) + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+}
+
+// sigV4SigningKey derives the signing key for a date and region.
+func sigV4SigningKey(secretKey, date, region string) []byte {
+	k := hmacSHA256([]byte("AWS4"+secretKey), date)
+	k = hmacSHA256(k, region)
+	k = hmacSHA256(k, sigV4Service)
+	return hmacSHA256(k, sigV4Terminal)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// payloadHashReader verifies the SHA-256 of a body against the signed
+// x-amz-content-sha256 value once the body has been fully read.
+type payloadHashReader struct {
+	rc   io.ReadCloser
+	h    hash.Hash
+	want []byte
+}
+
+func (p *payloadHashReader) Read(b []byte) (int, error) {
+	n, err := p.rc.Read(b)
+	p.h.Write(b[:n])
+	if errors.Is(err, io.EOF) && !bytes.Equal(p.h.Sum(nil), p.want) {
+		return n, ErrContentSHA256Mismatch
+	}
+	return n, err
+}
+
+func (p *payloadHashReader) Close() error {
+	return p.rc.Close()
+}
+
+// awsChunkedReader decodes an aws-chunked request body. When signingKey is
+// set, each chunk's signature is verified against the previous signature in
+// the chain before any of its data is returned.
+type awsChunkedReader struct {
+	br      *bufio.Reader
+	closer  io.Closer
+	trailer bool
+
+	signingKey []byte
+	amzDate    time.Time
+	scope      string
+	prevSig    string
+
+	buf      []byte // Verified data not yet returned
+	expected int64  // Declared decoded length
+	total    int64
+	done     bool
+	err      error
+}
+
+func (c *awsChunkedReader) Read(p []byte) (int, error) {
+	for len(c.buf) == 0 {
+		if c.err != nil {
+			return 0, c.err
+		}
+		if c.done {
+			return 0, io.EOF
+		}
+		c.err = c.nextChunk()
+	}
+	n := copy(p, c.buf)
+	c.buf = c.buf[n:]
+	return n, nil
+}
+
+func (c *awsChunkedReader) Close() error {
+	return c.closer.Close()
+}
+
+// nextChunk reads and verifies one chunk into c.buf.
+func (c *awsChunkedReader) nextChunk() error {
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	sizeStr, ext, _ := strings.Cut(line, ";")
+	size, err := strconv.ParseInt(strings.TrimSpace(sizeStr), 16, 64)
+	if err != nil || size < 0 || size > maxStreamingChunkSize {
+		return fmt.Errorf("%w: malformed aws-chunked chunk header", ErrInvalidRequest)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(c.br, data); err != nil {
+		return fmt.Errorf("%w: truncated aws-chunked body", ErrInvalidRequest)
+	}
+
+	if c.signingKey != nil {
+		sig, ok := strings.CutPrefix(ext, "chunk-signature=")
+		if !ok {
+			return fmt.Errorf("%w: missing chunk signature", ErrInvalidRequest)
+		}
+		sum := sha256.Sum256(data)
+		sts := "AWS4-HMAC-SHA256-PAYLOAD\n" + c.amzDate.UTC().Format(sigV4TimeFmt) + "\n" + c.scope + "\n" +
+			c.prevSig + "\n" + emptySHA256 + "\n" + hex.EncodeToString(sum[:])
+		expected := hex.EncodeToString(hmacSHA256(c.signingKey, sts))
+		if !hmac.Equal([]byte(expected), []byte(sig)) {
+			return ErrSignatureMismatch
+		}
+		c.prevSig = sig
+	}
+
+	if size == 0 {
+		if c.trailer {
+			// Trailing headers (e.g. checksums) end with an empty line.
+			for {
+				l, err := c.readLine()
+				if err != nil {
+					return err
+				}
+				if l == "" {
+					break
+				}
+			}
+		} else if _, err := c.readLine(); err != nil {
+			return err
+		}
+		if c.total != c.expected {
+			return fmt.Errorf("%w: decoded length %d does not match x-amz-decoded-content-length %d", ErrInvalidRequest, c.total, c.expected)
+		}
+		c.done = true
+		return nil
+	}
+
+	if l, err := c.readLine(); err != nil || l != "" {
+		return fmt.Errorf("%w: malformed aws-chunked chunk terminator", ErrInvalidRequest)
+	}
+	c.total += size
+	if c.total > c.expected {
+		return fmt.Errorf("%w: body exceeds x-amz-decoded-content-length", ErrInvalidRequest)
+	}
+	c.buf = data
+	return nil
+}
+
+// readLine reads a CRLF-terminated line without the terminator.
+func (c *awsChunkedReader) readLine() (string, error) {
+	line, err := c.br.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("%w: truncated aws-chunked body", ErrInvalidRequest)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// PresignOptions configures PresignURL.
+type PresignOptions struct {
+	Endpoint  string // Scheme and host of the S3 API, e.g. "https://10.42.0.1:9000"
+	Method    string // HTTP method the URL is valid for (GET, PUT, HEAD)
+	Bucket    string
+	Key       string
+	AccessKey string
+	SecretKey string
+	Region    string        // Defaults to DefaultRegion
+	Expires   time.Duration // Between one second and MaxPresignExpiry
+	Now       time.Time     // Signing time; defaults to time.Now
+}
+
+// PresignURL returns a SigV4 query-authenticated URL granting the holder
+// Method access to Bucket/Key until Expires has elapsed. The payload is
+// unsigned, so presigned PUT URLs accept any body.
+func PresignURL(opts PresignOptions) (string, error) {
+	if opts.Bucket == "" || opts.Key == "" {
+		return "", fmt.Errorf("%w: bucket and key are required", ErrInvalidRequest)
+	}
+	if opts.AccessKey == "" || opts.SecretKey == "" {
+		return "", fmt.Errorf("%w: credentials are required", ErrInvalidRequest)
+	}
+	if opts.Expires < time.Second || opts.Expires > MaxPresignExpiry {
+		return "", fmt.Errorf("%w: expiry must be between 1s and %s", ErrInvalidRequest, MaxPresignExpiry)
+	}
+	method := strings.ToUpper(opts.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	region := opts.Region
+	if region == "" {
+		region = DefaultRegion
+	}
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	now = now.UTC()
+
+	u, err := url.Parse(opts.Endpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("%w: invalid endpoint %q", ErrInvalidRequest, opts.Endpoint)
+	}
+	path := "/" + opts.Bucket + "/" + opts.Key
+
+	sr := &sigV4Request{
+		accessKey:     opts.AccessKey,
+		date:          now.Format(sigV4DateFmt),
+		region:        region,
+		signedHeaders: []string{"host"},
+		amzDate:       now,
+		expires:       opts.Expires,
+		presigned:     true,
+	}
+
+	q := url.Values{}
+	q.Set("X-Amz-Algorithm", sigV4Algorithm)
+	q.Set("X-Amz-Credential", sr.accessKey+"/"+sr.scope())
+	q.Set("X-Amz-Date", now.Format(sigV4TimeFmt))
+	q.Set("X-Amz-Expires", strconv.FormatInt(int64(opts.Expires/time.Second), 10))
+	q.Set("X-Amz-SignedHeaders", "host")
+
+	req := &http.Request{Method: method, URL: &url.URL{Path: path, RawQuery: q.Encode()}, Host: u.Host, Header: http.Header{}}
+	canonical := canonicalRequest(req, sr, unsignedPayload)
+	key := sigV4SigningKey(opts.SecretKey, sr.date, region)
+	q.Set("X-Amz-Signature", hex.EncodeToString(hmacSHA256(key, sigV4StringToSign(now, sr.scope(), canonical))))
+
+	return u.Scheme + "://" + u.Host + canonicalURI(path) + "?" + canonicalQuery(q, false), nil
+}
@@ -85,6 +85,9 @@ type BucketMeta struct {
 	SizeBytes         int64                `json:"size_bytes"`               // Total size of live objects (updated incrementally)
 	ReplicationFactor int                  `json:"replication_factor"`       // Number of replicas (1-3)
 	ErasureCoding     *ErasureCodingPolicy `json:"erasure_coding,omitempty"` // Erasure coding policy for new objects
+	Versioning        string               `json:"versioning,omitempty"`     // S3 versioning state: "", Enabled or Suspended
+	Lifecycle         []LifecycleRule      `json:"lifecycle,omitempty"`      // Lifecycle rules applied by GC
+	Notifications     []NotificationRule   `json:"notifications,omitempty"`  // Event notification rules
 }
 
 // BucketMetadataUpdate contains mutable bucket metadata fields (admin-only).
@@ -132,15 +135,20 @@ type ObjectMeta struct {
 	ChunkMetadata map[string]*ChunkMetadata `json:"chunk_metadata,omitempty"` // Per-chunk metadata with version vectors
 	VersionVector map[string]uint64         `json:"version_vector,omitempty"` // File-level version vector
 	ErasureCoding *ErasureCodingInfo        `json:"erasure_coding,omitempty"` // Erasure coding info (if enabled)
+	PartSizes     []int64                   `json:"part_sizes,omitempty"`     // Part sizes in order (multipart uploads only)
+	Tags          map[string]string         `json:"tags,omitempty"`           // Object tags (S3 tagging API)
+	DeleteMarker  bool                      `json:"delete_marker,omitempty"`  // Version is a delete marker (no content)
 }
 
 // VersionInfo contains version information for listing.
 type VersionInfo struct {
+	Key          string    `json:"key,omitempty"`
 	VersionID    string    `json:"version_id"`
 	Size         int64     `json:"size"`
 	ETag         string    `json:"etag"`
 	LastModified time.Time `json:"last_modified"`
 	IsCurrent    bool      `json:"is_current"`
+	DeleteMarker bool      `json:"delete_marker,omitempty"`
 }
 
 // RecycledEntry represents a deleted object in the recycle bin.
@@ -165,6 +173,11 @@ type RecycledEntry struct {
 //	      versions/
 //	        {key}/
 //	          {versionID}.json # version metadata
+//	      uploads/
+//	        {uploadID}/
+//	          upload.json     # in-progress multipart upload
+//	          parts/
+//	            {n}.json      # uploaded part (chunk list)
 //
 // VersionRetentionPolicy configures smart tiered version retention.
 type VersionRetentionPolicy struct {
@@ -217,8 +230,10 @@ type Store struct {
 	versionRetentionDays    int                    // Days to retain object versions (0 = forever)
 	maxVersionsPerObject    int                    // Max versions to keep per object (0 = unlimited)
 	versionRetentionPolicy  VersionRetentionPolicy
-	erasureCodingSemaphore  chan struct{}  // Limits concurrent erasure coding operations (memory safety)
-	bgWg                    sync.WaitGroup // Tracks background goroutines (e.g., shard caching)
+	multipartUploadExpiry   time.Duration   // Age after which incomplete multipart uploads are aborted (0 = default)
+	webhookResolver         WebhookResolver // Checks webhook endpoint hosts (nil = no webhooks)
+	erasureCodingSemaphore  chan struct{}   // Limits concurrent erasure coding operations (memory safety)
+	bgWg                    sync.WaitGroup  // Tracks background goroutines (e.g., shard caching)
 	mu                      sync.RWMutex
 
 	// Incremental CAS stats — atomic for lock-free metrics reads.
@@ -1268,7 +1283,7 @@ func (s *Store) getObjectContentWithErasureCoding(ctx context.Context, bucket, k
 // NOTE: This is Phase 1 implementation with full buffering. Streaming encoder will be added in Phase 6.
 //
 //nolint:gocyclo // Complexity will be reduced when streaming encoder is added (Phase 6)
-func (s *Store) putObjectWithErasureCoding(ctx context.Context, bucket, key string, reader io.Reader, size int64, contentType string, metadata map[string]string, bucketMeta *BucketMeta) (*ObjectMeta, error) {
+func (s *Store) putObjectWithErasureCoding(ctx context.Context, bucket, key string, reader io.Reader, size int64, contentType string, metadata, tags map[string]string, bucketMeta *BucketMeta) (*ObjectMeta, error) {
 	// Lock strategy: global lock is NOT held during the expensive read/encode/CAS-write
 	// phases. It is acquired only for the brief metadata operations at the end.
 
@@ -1526,6 +1541,7 @@ func (s *Store) putObjectWithErasureCoding(ctx context.Context, bucket, key stri
 		ETag:          etag,
 		LastModified:  now,
 		Metadata:      metadata,
+		Tags:          normalizeTags(tags),
 		VersionID:     versionID,
 		Chunks:        chunks,
 		ChunkMetadata: chunkMetadata,
@@ -1601,9 +1617,15 @@ func (s *Store) putObjectWithErasureCoding(ctx context.Context, bucket, key stri
 //
 // Context cancellation: If ctx is canceled mid-upload, the function returns immediately
 // but chunks already written to CAS will remain until GC cleanup.
+func (s *Store) PutObject(ctx context.Context, bucket, key string, reader io.Reader, size int64, contentType string, metadata map[string]string) (*ObjectMeta, error) {
+	return s.PutObjectWithTags(ctx, bucket, key, reader, size, contentType, metadata, nil)
+}
+
+// PutObjectWithTags is PutObject for an object carrying tags. The tags are
+// part of the committed metadata, so the object is never visible without them.
 //
 //nolint:gocyclo // Complexity inherited from streaming refactor - will be addressed in future refactoring
-func (s *Store) PutObject(ctx context.Context, bucket, key string, reader io.Reader, size int64, contentType string, metadata map[string]string) (*ObjectMeta, error) {
+func (s *Store) PutObjectWithTags(ctx context.Context, bucket, key string, reader io.Reader, size int64, contentType string, metadata, tags map[string]string) (*ObjectMeta, error) {
 	// Validate names (defense in depth)
 	if err := validateName(bucket); err != nil {
 		return nil, fmt.Errorf("invalid bucket name: %w", err)
@@ -1611,6 +1633,9 @@ func (s *Store) PutObject(ctx context.Context, bucket, key string, reader io.Rea
 	if err := validateName(key); err != nil {
 		return nil, fmt.Errorf("invalid key: %w", err)
 	}
+	if err := validateTags(tags); err != nil {
+		return nil, err
+	}
 
 	// CAS must be initialized
 	if s.cas == nil {
@@ -1632,19 +1657,69 @@ func (s *Store) PutObject(ctx context.Context, bucket, key string, reader io.Rea
 	s.mu.RUnlock()
 
 	if useErasureCoding {
-		return s.putObjectWithErasureCoding(ctx, bucket, key, reader, size, contentType, metadata, bucketMeta)
+		return s.putObjectWithErasureCoding(ctx, bucket, key, reader, size, contentType, metadata, tags, bucketMeta)
 	}
 
 	// Phase 2: Stream data through CDC chunker without holding the global lock.
 	// CAS writes are safe for concurrent access (content-addressed, atomic rename).
-	metaPath := s.objectMetaPath(bucket, key)
+	streamed, err := s.writeStreamToCAS(ctx, reader, replicationFactor)
+	if err != nil {
+		return nil, err
+	}
+
+	etag := fmt.Sprintf("\"%s\"", hex.EncodeToString(streamed.md5))
+
+	fileVersionVector := make(map[string]uint64)
+	// coordinatorID is set once at init and never changes — safe to read without lock
+	if s.coordinatorID != "" {
+		fileVersionVector[s.coordinatorID] = 1
+	}
+
+	objMeta := ObjectMeta{
+		Key:           key,
+		Size:          streamed.size,
+		ContentType:   contentType,
+		ETag:          etag,
+		LastModified:  streamed.now,
+		Metadata:      metadata,
+		Tags:          normalizeTags(tags),
+		Chunks:        streamed.chunks,
+		ChunkMetadata: streamed.chunkMetadata,
+		VersionVector: fileVersionVector,
+	}
+
+	// Phase 3: Acquire global lock for the brief metadata write only.
+	// This section is fast (microseconds) — no streaming I/O.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.commitObjectMeta(ctx, bucket, key, &objMeta); err != nil {
+		return nil, err
+	}
+
+	return &objMeta, nil
+}
+
+// streamedChunks is the result of streaming an upload body into CAS.
+type streamedChunks struct {
+	chunks        []string                  // Ordered chunk hashes
+	chunkMetadata map[string]*ChunkMetadata // Per-chunk metadata keyed by hash
+	size          int64                     // Total bytes read
+	md5           []byte                    // MD5 of the full stream (for ETag)
+	now           time.Time                 // Timestamp used for chunk metadata
+}
+
+// writeStreamToCAS runs reader through the CDC chunker, writing each chunk to
+// CAS and registering it with the chunk registry as it goes. No Store lock is
+// held, so concurrent reads proceed while large bodies stream in.
+func (s *Store) writeStreamToCAS(ctx context.Context, reader io.Reader, replicationFactor int) (*streamedChunks, error) {
 	streamChunker := NewStreamingChunker(reader)
 
-	var chunks []string
-	chunkMetadata := make(map[string]*ChunkMetadata)
-	var written int64
+	result := &streamedChunks{
+		chunkMetadata: make(map[string]*ChunkMetadata),
+		now:           time.Now().UTC(),
+	}
 	md5Hasher := md5.New()
-	now := time.Now().UTC()
 
 	// coordinatorID is set once at init and never changes — safe to read without lock
 	coordID := s.coordinatorID
@@ -1703,38 +1778,37 @@ func (s *Store) PutObject(ctx context.Context, bucket, key string, reader io.Rea
 			owners = []string{coordID}
 		}
 
-		chunkMetadata[chunkHash] = &ChunkMetadata{
+		result.chunkMetadata[chunkHash] = &ChunkMetadata{
 			Hash:           chunkHash,
 			Size:           int64(len(chunk)),
 			CompressedSize: chunkOnDiskSize,
 			VersionVector:  versionVector,
 			Owners:         owners,
-			FirstSeen:      now,
-			LastModified:   now,
+			FirstSeen:      result.now,
+			LastModified:   result.now,
 		}
 
-		chunks = append(chunks, chunkHash)
-		written += int64(len(chunk))
+		result.chunks = append(result.chunks, chunkHash)
+		result.size += int64(len(chunk))
 	}
 
-	hash := md5Hasher.Sum(nil)
-	etag := fmt.Sprintf("\"%s\"", hex.EncodeToString(hash))
-
-	fileVersionVector := make(map[string]uint64)
-	if coordID != "" {
-		fileVersionVector[coordID] = 1
-	}
-
-	// Phase 3: Acquire global lock for the brief metadata write only.
-	// This section is fast (microseconds) — no streaming I/O.
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	result.md5 = md5Hasher.Sum(nil)
+	return result, nil
+}
 
+// commitObjectMeta makes objMeta the live version of bucket/key. It archives the
+// previous version, enforces and updates quota, assigns a fresh version ID and
+// default expiry, and updates stats and the cached bucket size.
+// Caller must hold s.mu (write lock).
+func (s *Store) commitObjectMeta(ctx context.Context, bucket, key string, objMeta *ObjectMeta) error {
 	// Re-check bucket exists (could have been deleted during streaming)
 	if _, err := s.getBucketMeta(bucket); err != nil {
-		return nil, err
+		return err
 	}
 
+	written := objMeta.Size
+	metaPath := s.objectMetaPath(bucket, key)
+
 	// Check if object already exists (for quota update calculation and versioning)
 	var oldSize int64
 	var oldLogicalBytes int64
@@ -1749,18 +1823,18 @@ func (s *Store) PutObject(ctx context.Context, bucket, key string, reader io.Rea
 	if s.quota != nil && written > oldSize {
 		delta := written - oldSize
 		if !s.quota.CanAllocate(delta) {
-			return nil, ErrQuotaExceeded
+			return ErrQuotaExceeded
 		}
 	}
 
 	// Archive current version for version history
 	if err := s.archiveCurrentVersion(bucket, key); err != nil {
-		return nil, fmt.Errorf("archive current version: %w", err)
+		return fmt.Errorf("archive current version: %w", err)
 	}
 
 	// Create parent directories for metadata
 	if err := os.MkdirAll(filepath.Dir(metaPath), 0755); err != nil {
-		return nil, fmt.Errorf("create meta dir: %w", err)
+		return fmt.Errorf("create meta dir: %w", err)
 	}
 
 	// Update quota tracking
@@ -1774,21 +1848,9 @@ func (s *Store) PutObject(ctx context.Context, bucket, key string, reader io.Rea
 		quotaUpdated = true
 	}
 
-	objMeta := ObjectMeta{
-		Key:           key,
-		Size:          written,
-		ContentType:   contentType,
-		ETag:          etag,
-		LastModified:  now,
-		Metadata:      metadata,
-		VersionID:     generateVersionID(),
-		Chunks:        chunks,
-		ChunkMetadata: chunkMetadata,
-		VersionVector: fileVersionVector,
-	}
-
+	objMeta.VersionID = generateVersionID()
 	if s.defaultObjectExpiryDays > 0 && bucket != SystemBucket {
-		expiry := now.AddDate(0, 0, s.defaultObjectExpiryDays)
+		expiry := objMeta.LastModified.AddDate(0, 0, s.defaultObjectExpiryDays)
 		objMeta.Expires = &expiry
 	}
 
@@ -1801,7 +1863,7 @@ func (s *Store) PutObject(ctx context.Context, bucket, key string, reader io.Rea
 				s.quota.Release(bucket, written)
 			}
 		}
-		return nil, fmt.Errorf("marshal object meta: %w", err)
+		return fmt.Errorf("marshal object meta: %w", err)
 	}
 
 	if err := syncedWriteFile(metaPath, metaData, 0644); err != nil {
@@ -1812,7 +1874,7 @@ func (s *Store) PutObject(ctx context.Context, bucket, key string, reader io.Rea
 				s.quota.Release(bucket, written)
 			}
 		}
-		return nil, fmt.Errorf("write object meta: %w", err)
+		return fmt.Errorf("write object meta: %w", err)
 	}
 
 	if isNewObject {
@@ -1829,7 +1891,7 @@ func (s *Store) PutObject(ctx context.Context, bucket, key string, reader io.Rea
 		}
 	}
 
-	return &objMeta, nil
+	return nil
 }
 
 // GetObject retrieves an object from a bucket.
@@ -1895,16 +1957,10 @@ func (s *Store) getObjectMeta(bucket, key string) (*ObjectMeta, error) {
 // DeleteObject moves an object to the recycle bin.
 // The object is removed from the live path but its metadata is preserved in
 // recyclebin/{uuid}.json for recovery. Chunks and versions remain intact.
+// In a bucket with versioning Enabled a delete marker is created instead
+// (see DeleteObjectVersion).
 func (s *Store) DeleteObject(ctx context.Context, bucket, key string) error {
-	// Validate names (defense in depth)
-	if err := validateName(bucket); err != nil {
-		return fmt.Errorf("invalid bucket name: %w", err)
-	}
-	if err := validateName(key); err != nil {
-		return fmt.Errorf("invalid key: %w", err)
-	}
-
-	err := s.RecycleObject(ctx, bucket, key)
+	_, err := s.DeleteObjectVersion(ctx, bucket, key, "")
 	// S3 semantics: delete is idempotent — deleting a non-existent object succeeds
 	if errors.Is(err, ErrObjectNotFound) {
 		return nil
@@ -1923,7 +1979,11 @@ func (s *Store) recyclebinPath(bucket string) string {
 func (s *Store) RecycleObject(ctx context.Context, bucket, key string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	return s.recycleObject(bucket, key)
+}
 
+// recycleObject implements RecycleObject (caller must hold lock).
+func (s *Store) recycleObject(bucket, key string) error {
 	// Check bucket exists
 	if _, err := s.getBucketMeta(bucket); err != nil {
 		return err
@@ -1980,21 +2040,8 @@ func (s *Store) RecycleObject(ctx context.Context, bucket, key string) error {
 		return fmt.Errorf("remove live object meta: %w", err)
 	}
 
-	// Update bucket size
-	if meta.Size > 0 {
-		_ = s.updateBucketSize(bucket, -meta.Size)
-	}
-
-	// Release quota
-	if s.quota != nil && meta.Size > 0 {
-		s.quota.Release(bucket, meta.Size)
-	}
-
-	// Update stats
-	s.statsObjectCount.Add(-1)
-	if meta.Size > 0 {
-		s.statsLogicalBytes.Add(-meta.Size)
-	}
+	// Update bucket size, quota and stats
+	s.releaseLiveObject(bucket, meta)
 	s.statsRecycledBytes.Add(meta.Size)
 
 	return nil
@@ -2202,24 +2249,39 @@ func (s *Store) ListRecycledObjects(ctx context.Context, bucket string) ([]Recyc
 func (s *Store) PurgeObject(ctx context.Context, bucket, key string) error {
 	// Phase 1: Hold lock briefly to collect chunk info and remove metadata
 	s.mu.Lock()
+	chunksToCheck, err := s.purgeObjectMeta(bucket, key)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// Phase 2: Delete unreferenced chunks WITHOUT holding the lock.
+	// This is best-effort: the object is already purged (metadata removed in Phase 1).
+	// If context is cancelled, orphaned chunks will be cleaned by the next GC cycle.
+	s.DeleteUnreferencedChunks(ctx, chunksToCheck)
+
+	return nil
+}
 
+// purgeObjectMeta removes an object's metadata and versions, releasing its
+// quota, and returns the chunks that may now be unreferenced (caller must
+// hold lock).
+func (s *Store) purgeObjectMeta(bucket, key string) ([]string, error) {
 	// Check bucket exists — if bucket is missing, the object can't exist either
 	if _, err := s.getBucketMeta(bucket); err != nil {
-		s.mu.Unlock()
 		if errors.Is(err, ErrBucketNotFound) {
-			return nil // Idempotent: bucket doesn't exist, nothing to purge
+			return nil, nil // Idempotent: bucket doesn't exist, nothing to purge
 		}
-		return err
+		return nil, err
 	}
 
 	// Get current object metadata for quota release and chunk cleanup
 	meta, err := s.getObjectMeta(bucket, key)
 	if err != nil {
-		s.mu.Unlock()
 		if errors.Is(err, ErrObjectNotFound) {
-			return nil // Idempotent: object doesn't exist, nothing to purge
+			return nil, nil // Idempotent: object doesn't exist, nothing to purge
 		}
-		return err
+		return nil, err
 	}
 
 	// Collect version chunks and remove version files
@@ -2266,14 +2328,7 @@ func (s *Store) PurgeObject(ctx context.Context, bucket, key string) error {
 		s.statsLogicalBytes.Add(-meta.Size)
 	}
 
-	s.mu.Unlock()
-
-	// Phase 2: Delete unreferenced chunks WITHOUT holding the lock.
-	// This is best-effort: the object is already purged (metadata removed in Phase 1).
-	// If context is cancelled, orphaned chunks will be cleaned by the next GC cycle.
-	s.DeleteUnreferencedChunks(ctx, chunksToCheck)
-
-	return nil
+	return chunksToCheck, nil
 }
 
 // SetRecycleBinRetentionDays sets the number of days to retain recycled objects before purging.
@@ -2290,7 +2345,8 @@ func (s *Store) RecycleBinRetentionDays() int {
 	return s.recyclebinRetentionDays
 }
 
-// PurgeRecycleBin removes all recycled objects older than the retention period.
+// PurgeRecycleBin removes all recycled objects older than the retention period,
+// and those that a bucket lifecycle rule keeps out of the recycle bin.
 // For each: delete recyclebin entry, delete versions, delete unreferenced chunks.
 // Returns the number of entries purged.
 func (s *Store) PurgeRecycleBin(ctx context.Context) int {
@@ -2298,11 +2354,11 @@ func (s *Store) PurgeRecycleBin(ctx context.Context) int {
 	retentionDays := s.recyclebinRetentionDays
 	s.mu.RUnlock()
 
-	if retentionDays <= 0 {
-		return 0 // Disabled
+	// Retention disabled: the zero cutoff only lets lifecycle rules purge
+	var cutoff time.Time
+	if retentionDays > 0 {
+		cutoff = time.Now().UTC().AddDate(0, 0, -retentionDays)
 	}
-
-	cutoff := time.Now().UTC().AddDate(0, 0, -retentionDays)
 	return s.purgeRecycledEntries(ctx, &cutoff)
 }
 
@@ -2373,7 +2429,8 @@ func (s *Store) PurgeAllRecycledInBucket(ctx context.Context, bucket string) err
 }
 
 // purgeRecycledEntries scans all buckets' recyclebin dirs and purges entries.
-// If cutoff is non-nil, only entries older than cutoff are purged.
+// If cutoff is non-nil, only entries older than cutoff, or under a lifecycle
+// rule with SkipRecycleBin, are purged.
 func (s *Store) purgeRecycledEntries(ctx context.Context, cutoff *time.Time) int {
 	purgedCount := 0
 	var allChunksToCheck []string
@@ -2416,7 +2473,8 @@ func (s *Store) purgeRecycledEntries(ctx context.Context, cutoff *time.Time) int
 			}
 
 			// Check retention cutoff
-			if cutoff != nil && !entry.DeletedAt.Before(*cutoff) {
+			if cutoff != nil && !entry.DeletedAt.Before(*cutoff) &&
+				!lifecycleFlag(bucket.Lifecycle, entry.OriginalKey, entry.Meta.Tags, func(r LifecycleRule) bool { return r.SkipRecycleBin }) {
 				continue
 			}
 
@@ -3136,7 +3194,7 @@ func (s *Store) isChunkReferencedGlobally(ctx context.Context, hash string) bool
 		bucket := bucketEntry.Name()
 		bucketDir := filepath.Join(bucketsDir, bucket)
 
-		// Check live objects, versions, and recycle bin entries
+		// Check live objects, versions, recycle bin entries, and upload parts
 		if dirContainsChunkRef(filepath.Join(bucketDir, "meta"), hash, extractChunksFromObjectMeta) {
 			return true
 		}
@@ -3146,6 +3204,9 @@ func (s *Store) isChunkReferencedGlobally(ctx context.Context, hash string) bool
 		if dirContainsChunkRef(s.recyclebinPath(bucket), hash, extractChunksFromRecycledEntry) {
 			return true
 		}
+		if dirContainsChunkRef(s.uploadsPath(bucket), hash, extractChunksFromObjectMeta) {
+			return true
+		}
 	}
 
 	return false
@@ -3344,10 +3405,13 @@ type GCStats struct {
 	ObjectsScanned           int   // Number of objects scanned
 	BucketsProcessed         int   // Number of buckets processed
 	ChunksSkippedGracePeriod int   // Chunks skipped due to grace period (Phase 6)
+	UploadsAborted           int   // Stale multipart uploads aborted
+	ObjectsExpired           int   // Current objects expired by lifecycle rules
 }
 
 // RunGarbageCollection performs a full garbage collection pass.
 // This should be called periodically (e.g., hourly) to clean up:
+//   - Objects, versions and uploads expired by bucket lifecycle rules
 //   - Expired versions according to retention policy
 //   - Orphaned chunks not referenced by any object
 //
@@ -3377,9 +3441,15 @@ func (s *Store) runGC(ctx context.Context, skipGracePeriod bool) GCStats {
 	// Record start time to avoid deleting chunks created during GC
 	gcStartTime := time.Now()
 
-	// Phase 1: Prune expired versions across all buckets.
-	// Returns chunks from pruned versions that may now be unreferenced.
-	chunksToCheck := s.pruneAllExpiredVersionsSimple(ctx, &stats)
+	// Phase 0: Abort stale multipart uploads so their parts' chunks are
+	// excluded from the reference set built below.
+	s.abortStaleMultipartUploads(ctx, &stats)
+
+	// Phase 1: Apply bucket lifecycle rules, then prune expired versions
+	// across all buckets.
+	// Both return chunks from removed versions that may now be unreferenced.
+	chunksToCheck := s.applyLifecycleRules(ctx, time.Now().UTC(), &stats)
+	chunksToCheck = append(chunksToCheck, s.pruneAllExpiredVersionsSimple(ctx, &stats)...)
 
 	// Phase 2: Build reference set ONCE after pruning (lock-free filesystem scan).
 	// This single scan serves both pruned-version chunk cleanup and orphan detection,
@@ -3507,6 +3577,27 @@ func (s *Store) buildChunkReferenceSet(ctx context.Context) map[string]struct{}
 			}
 			return nil
 		})
+
+		// Scan parts of in-progress multipart uploads
+		uploadsDir := s.uploadsPath(bucket)
+		_ = filepath.Walk(uploadsDir, func(path string, info os.FileInfo, err error) error {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+			if err != nil || info.IsDir() || filepath.Ext(path) != ".json" {
+				return nil
+			}
+			data, _ := os.ReadFile(path)
+			var part PartMeta
+			if json.Unmarshal(data, &part) == nil {
+				for _, h := range part.Chunks {
+					referencedChunks[h] = struct{}{}
+				}
+			}
+			return nil
+		})
 	}
 
 	return referencedChunks
@@ -3695,6 +3786,7 @@ func (s *Store) ListVersions(ctx context.Context, bucket, key string) ([]Version
 			ETag:         meta.ETag,
 			LastModified: meta.LastModified,
 			IsCurrent:    false,
+			DeleteMarker: meta.DeleteMarker,
 		})
 	}
 
@@ -3905,21 +3997,35 @@ func (s *Store) GetObjectVersion(ctx context.Context, bucket, key, versionID str
 	}
 
 	// Look for archived version
+	meta, err := s.getArchivedVersionMeta(bucket, key, versionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if meta.DeleteMarker {
+		return nil, nil, ErrDeleteMarker
+	}
+
+	return s.getObjectContent(ctx, bucket, key, meta)
+}
+
+// getArchivedVersionMeta reads the metadata of an archived (non-current)
+// object version (caller must hold lock).
+func (s *Store) getArchivedVersionMeta(bucket, key, versionID string) (*ObjectMeta, error) {
 	versionPath := s.versionMetaPath(bucket, key, versionID)
 	data, err := os.ReadFile(versionPath)
 	if os.IsNotExist(err) {
-		return nil, nil, ErrObjectNotFound
+		return nil, ErrObjectNotFound
 	}
 	if err != nil {
-		return nil, nil, fmt.Errorf("read version meta: %w", err)
+		return nil, fmt.Errorf("read version meta: %w", err)
 	}
 
 	var meta ObjectMeta
 	if err := json.Unmarshal(data, &meta); err != nil {
-		return nil, nil, fmt.Errorf("unmarshal version meta: %w", err)
+		return nil, fmt.Errorf("unmarshal version meta: %w", err)
 	}
 
-	return s.getObjectContent(ctx, bucket, key, &meta)
+	return &meta, nil
 }
 
 // chunkReader implements io.ReadCloser for streaming chunk reads.
@@ -4011,26 +4117,7 @@ func (s *Store) getObjectContent(ctx context.Context, bucket, key string, meta *
 
 	// Use distributed chunk reader if replicator is configured (Phase 5)
 	// This enables fetching missing chunks from remote coordinators
-	// Note: Reading these pointers doesn't require locking as they're only
-	// set once during initialization and never modified after that
-	if s.replicator != nil && s.chunkRegistry != nil {
-		// Distributed reads: can fetch chunks from remote peers
-		reader := NewDistributedChunkReader(ctx, DistributedChunkReaderConfig{
-			Chunks:          meta.Chunks,
-			LocalCAS:        s.cas,
-			Registry:        s.chunkRegistry,
-			Replicator:      s.replicator,
-			Logger:          s.logger,
-			TotalSize:       meta.Size,
-			Prefetch:        PrefetchConfig{WindowSize: 8, Parallelism: 4},
-			StatsChunkCount: &s.statsChunkCount,
-			StatsChunkBytes: &s.statsChunkBytes,
-		})
-		return reader, meta, nil
-	}
-
-	// Local-only reads: all chunks must be local
-	return newChunkReader(ctx, s.cas, meta.Chunks), meta, nil
+	return s.openChunks(ctx, meta.Chunks, meta.Size), meta, nil
 }
 
 // RestoreVersion makes a previous version the current version.
@@ -4066,6 +4153,9 @@ func (s *Store) RestoreVersion(ctx context.Context, bucket, key, versionID strin
 	if err := json.Unmarshal(data, &oldMeta); err != nil {
 		return nil, fmt.Errorf("unmarshal version meta: %w", err)
 	}
+	if oldMeta.DeleteMarker {
+		return nil, ErrDeleteMarker
+	}
 
 	// Archive current version
 	if err := s.archiveCurrentVersion(bucket, key); err != nil {
@@ -4083,6 +4173,7 @@ func (s *Store) RestoreVersion(ctx context.Context, bucket, key, versionID strin
 		Metadata:     oldMeta.Metadata,
 		VersionID:    generateVersionID(),
 		Chunks:       oldMeta.Chunks, // Reuse same chunks (no duplication)
+		PartSizes:    oldMeta.PartSizes,
 	}
 
 	// Set expiry if configured
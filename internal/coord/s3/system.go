@@ -14,6 +14,7 @@ import (
 
 	"github.com/rs/zerolog/log"
 	"github.com/tunnelmesh/tunnelmesh/internal/auth"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
 )
 
 // SystemBucket is an alias for auth.SystemBucket for convenience.
@@ -54,6 +55,12 @@ const (
 	GroupBindingsPath = "auth/group_bindings.json"
 	FileSharesPath    = "auth/file_shares.json"
 	PanelsPath        = "auth/panels.json"
+	JoinKeysPath      = "auth/join_keys.json"
+	RevokedPeersPath  = "auth/revoked_peers.json"
+	IssuedCertsPath   = "auth/issued_certs.json"
+	ACMEAccountsPath  = "auth/acme_accounts.json"
+	PeerTagsPath      = "auth/peer_tags.json"
+	PSKSecretPath     = "auth/psk_secret.json"
 )
 
 // WireGuard paths
@@ -66,6 +73,7 @@ const (
 const (
 	DNSCachePath = "dns/cache.json"
 	DNSAliasPath = "dns/aliases.json"
+	DNSZonePath  = "dns/zone.json"
 )
 
 // Filter paths
@@ -73,13 +81,23 @@ const (
 	FilterRulesPath = "filter/rules.json"
 )
 
+// Routing paths
+const (
+	RouteApprovalsPath = "routing/approvals.json"
+)
+
 // FilterRulePersisted represents a filter rule for persistence.
 type FilterRulePersisted struct {
-	Port       uint16 `json:"port"`
-	Protocol   string `json:"protocol"`    // "tcp" or "udp"
-	Action     string `json:"action"`      // "allow" or "deny"
-	Expires    int64  `json:"expires"`     // Unix timestamp (0 = no expiry)
-	SourcePeer string `json:"source_peer"` // Empty = any peer
+	Port        uint16 `json:"port"`
+	PortEnd     uint16 `json:"port_end,omitempty"`     // Last port of a range (0 = port only)
+	Protocol    string `json:"protocol"`               // "tcp", "udp", "icmp" or "icmpv6"
+	ICMPType    uint8  `json:"icmp_type,omitempty"`    // ICMP message type (0 = any)
+	Action      string `json:"action"`                 // "allow" or "deny"
+	Expires     int64  `json:"expires"`                // Unix timestamp (0 = no expiry)
+	SourcePeer  string `json:"source_peer"`            // Empty = any peer
+	SourceCIDR  string `json:"source_cidr,omitempty"`  // Empty = any address
+	SourceGroup string `json:"source_group,omitempty"` // RBAC group of the source peer
+	DestGroup   string `json:"dest_group,omitempty"`   // RBAC group of the receiving peer
 }
 
 // FilterRulesData stores temporary filter rules.
@@ -385,6 +403,22 @@ func (ss *SystemStore) LoadDNSAliases(ctx context.Context) (peerAliases map[stri
 	return peerAliases, aliasOwner, nil
 }
 
+// --- DNS Zone ---
+
+// SaveDNSZone saves the custom DNS records managed through the coordinator to S3.
+func (ss *SystemStore) SaveDNSZone(ctx context.Context, records []proto.DNSZoneRecord) error {
+	return ss.saveJSONWithChecksum(ctx, DNSZonePath, records)
+}
+
+// LoadDNSZone loads the custom DNS records from S3.
+func (ss *SystemStore) LoadDNSZone(ctx context.Context) ([]proto.DNSZoneRecord, error) {
+	var records []proto.DNSZoneRecord
+	if err := ss.loadJSONWithChecksum(ctx, DNSZonePath, &records, 3); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
 // --- Filter Rules ---
 
 // SaveFilterRules saves filter rules to S3 with checksum validation.
@@ -401,6 +435,124 @@ func (ss *SystemStore) LoadFilterRules(ctx context.Context) (*FilterRulesData, e
 	return &data, nil
 }
 
+// --- Subnet Routes ---
+
+// SaveRouteApprovals saves the subnet routes approved for each peer.
+func (ss *SystemStore) SaveRouteApprovals(ctx context.Context, approvals map[string][]string) error {
+	return ss.saveJSONWithChecksum(ctx, RouteApprovalsPath, approvals)
+}
+
+// LoadRouteApprovals loads the subnet routes approved for each peer.
+func (ss *SystemStore) LoadRouteApprovals(ctx context.Context) (map[string][]string, error) {
+	var approvals map[string][]string
+	if err := ss.loadJSONWithChecksum(ctx, RouteApprovalsPath, &approvals, 3); err != nil {
+		return nil, err
+	}
+	return approvals, nil
+}
+
+// --- Peer Tags ---
+
+// SavePeerTags saves the tags assigned to each peer, keyed by peer name.
+func (ss *SystemStore) SavePeerTags(ctx context.Context, tags map[string][]string) error {
+	return ss.saveJSONWithChecksum(ctx, PeerTagsPath, tags)
+}
+
+// LoadPeerTags loads the tags assigned to each peer, keyed by peer name.
+func (ss *SystemStore) LoadPeerTags(ctx context.Context) (map[string][]string, error) {
+	var tags map[string][]string
+	if err := ss.loadJSONWithChecksum(ctx, PeerTagsPath, &tags, 3); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// SavePSKSecret saves the secret the handshake pre-shared keys are derived
+// from, shared by all coordinators.
+func (ss *SystemStore) SavePSKSecret(ctx context.Context, secret []byte) error {
+	return ss.saveJSONWithChecksum(ctx, PSKSecretPath, secret)
+}
+
+// LoadPSKSecret loads the secret the handshake pre-shared keys are derived from.
+func (ss *SystemStore) LoadPSKSecret(ctx context.Context) ([]byte, error) {
+	var secret []byte
+	if err := ss.loadJSONWithChecksum(ctx, PSKSecretPath, &secret, 3); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// --- Join Keys ---
+
+// SaveJoinKeys saves the join keys issued by the coordinator, keyed by the
+// hash of the key.
+func (ss *SystemStore) SaveJoinKeys(ctx context.Context, keys map[string]proto.JoinKey) error {
+	return ss.saveJSONWithChecksum(ctx, JoinKeysPath, keys)
+}
+
+// LoadJoinKeys loads the join keys, keyed by the hash of the key.
+func (ss *SystemStore) LoadJoinKeys(ctx context.Context) (map[string]proto.JoinKey, error) {
+	var keys map[string]proto.JoinKey
+	if err := ss.loadJSONWithChecksum(ctx, JoinKeysPath, &keys, 3); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// SaveRevokedPeers saves the peers whose access was revoked.
+func (ss *SystemStore) SaveRevokedPeers(ctx context.Context, peers []proto.RevokedPeer) error {
+	return ss.saveJSONWithChecksum(ctx, RevokedPeersPath, peers)
+}
+
+// LoadRevokedPeers loads the peers whose access was revoked.
+func (ss *SystemStore) LoadRevokedPeers(ctx context.Context) ([]proto.RevokedPeer, error) {
+	var peers []proto.RevokedPeer
+	if err := ss.loadJSONWithChecksum(ctx, RevokedPeersPath, &peers, 3); err != nil {
+		return nil, err
+	}
+	return peers, nil
+}
+
+// SaveIssuedCerts saves the certificates issued by the mesh CA, keyed by
+// serial number.
+func (ss *SystemStore) SaveIssuedCerts(ctx context.Context, certs map[string]proto.IssuedCert) error {
+	return ss.saveJSONWithChecksum(ctx, IssuedCertsPath, certs)
+}
+
+// LoadIssuedCerts loads the certificates issued by the mesh CA.
+func (ss *SystemStore) LoadIssuedCerts(ctx context.Context) (map[string]proto.IssuedCert, error) {
+	var certs map[string]proto.IssuedCert
+	if err := ss.loadJSONWithChecksum(ctx, IssuedCertsPath, &certs, 3); err != nil {
+		return nil, err
+	}
+	return certs, nil
+}
+
+// --- ACME Accounts ---
+
+// ACMEAccount is an account registered with the coordinator's ACME endpoint.
+type ACMEAccount struct {
+	JWK         json.RawMessage `json:"jwk"`  // Account public key
+	Peer        string          `json:"peer"` // Peer that registered the account
+	Contact     []string        `json:"contact,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	Deactivated bool            `json:"deactivated,omitempty"`
+}
+
+// SaveACMEAccounts saves the ACME accounts, keyed by account ID.
+func (ss *SystemStore) SaveACMEAccounts(ctx context.Context, accounts map[string]ACMEAccount) error {
+	return ss.saveJSONWithChecksum(ctx, ACMEAccountsPath, accounts)
+}
+
+// LoadACMEAccounts loads the ACME accounts.
+func (ss *SystemStore) LoadACMEAccounts(ctx context.Context) (map[string]ACMEAccount, error) {
+	var accounts map[string]ACMEAccount
+	if err := ss.loadJSONWithChecksum(ctx, ACMEAccountsPath, &accounts, 3); err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
 // --- IP Allocations ---
 
 // SaveIPAllocations saves IP allocator state to S3 with checksum validation.
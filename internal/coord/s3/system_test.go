@@ -2,12 +2,14 @@ package s3
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/tunnelmesh/tunnelmesh/internal/auth"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
 )
 
 func TestNewSystemStore(t *testing.T) {
@@ -209,6 +211,113 @@ func TestSystemStoreLoadFilterRulesNotFound(t *testing.T) {
 	assert.Nil(t, loaded.Temporary)
 }
 
+func TestSystemStoreSaveLoadDNSZone(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ss, err := NewSystemStore(store, "svc:coordinator")
+	require.NoError(t, err)
+
+	// Nothing saved yet
+	loaded, err := ss.LoadDNSZone(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, loaded)
+
+	records := []proto.DNSZoneRecord{
+		{Name: "grafana.ops", Type: "A", Values: []string{"10.42.0.5"}},
+		{Name: "web", Type: "A", Values: []string{"10.42.0.1", "10.42.0.2", "10.42.0.3"}, TTL: 30},
+		{Name: "_http._tcp.web", Type: "SRV", Values: []string{"10 5 8080 web"}},
+	}
+	require.NoError(t, ss.SaveDNSZone(context.Background(), records))
+
+	loaded, err = ss.LoadDNSZone(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, records, loaded)
+}
+
+func TestSystemStoreSaveLoadRouteApprovals(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ss, err := NewSystemStore(store, "svc:coordinator")
+	require.NoError(t, err)
+
+	loaded, err := ss.LoadRouteApprovals(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, loaded)
+
+	approvals := map[string][]string{
+		"office": {"192.168.10.0/24", "192.168.20.0/24"},
+		"lab":    {"172.17.0.0/16"},
+	}
+	require.NoError(t, ss.SaveRouteApprovals(context.Background(), approvals))
+
+	loaded, err = ss.LoadRouteApprovals(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, approvals, loaded)
+}
+
+func TestSystemStoreSaveLoadJoinKeys(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ss, err := NewSystemStore(store, "svc:coordinator")
+	require.NoError(t, err)
+
+	loaded, err := ss.LoadJoinKeys(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, loaded)
+
+	created := time.Now().UTC().Truncate(time.Second)
+	keys := map[string]proto.JoinKey{
+		"hash1": {ID: "hash1", Reusable: true, Groups: []string{"ops"}, CreatedAt: created, ExpiresAt: created.Add(time.Hour)},
+		"hash2": {ID: "hash2", Name: "ci", CreatedAt: created, PeerIDs: []string{"abc"}},
+	}
+	require.NoError(t, ss.SaveJoinKeys(context.Background(), keys))
+
+	loaded, err = ss.LoadJoinKeys(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, keys, loaded)
+
+	revoked := []proto.RevokedPeer{{PeerID: "abc", Name: "ci", RevokedAt: created}}
+	require.NoError(t, ss.SaveRevokedPeers(context.Background(), revoked))
+	loadedRevoked, err := ss.LoadRevokedPeers(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, revoked, loadedRevoked)
+}
+
+func TestSystemStoreSaveLoadIssuedCerts(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ss, err := NewSystemStore(store, "svc:coordinator")
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	certs := map[string]proto.IssuedCert{
+		"0a1b": {Serial: "0a1b", Peer: "laptop", NotAfter: now.Add(24 * time.Hour)},
+		"2c3d": {Serial: "2c3d", Peer: "lost", NotAfter: now.Add(time.Hour), RevokedAt: now},
+	}
+	require.NoError(t, ss.SaveIssuedCerts(context.Background(), certs))
+
+	loaded, err := ss.LoadIssuedCerts(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, certs, loaded)
+}
+
+func TestSystemStoreSaveLoadACMEAccounts(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ss, err := NewSystemStore(store, "svc:coordinator")
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	accounts := map[string]ACMEAccount{
+		"k3Yq": {
+			JWK:       json.RawMessage(`{"crv":"P-256","kty":"EC","x":"AQ","y":"Ag"}`),
+			Peer:      "web",
+			Contact:   []string{"mailto:ops@example.com"},
+			CreatedAt: now,
+		},
+	}
+	require.NoError(t, ss.SaveACMEAccounts(context.Background(), accounts))
+
+	loaded, err := ss.LoadACMEAccounts(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, accounts, loaded)
+}
+
 func TestSystemStoreFilterRulesWithExpiry(t *testing.T) {
 	store := newTestStoreWithCAS(t)
 	ss, err := NewSystemStore(store, "svc:coordinator")
@@ -0,0 +1,101 @@
+package s3
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"unicode/utf8"
+)
+
+// Object tag limits (matching AWS S3).
+const (
+	MaxObjectTags     = 10
+	MaxTagKeyLength   = 128
+	MaxTagValueLength = 256
+)
+
+// validateTags checks a tag set against the S3 tagging limits.
+func validateTags(tags map[string]string) error {
+	if len(tags) > MaxObjectTags {
+		return fmt.Errorf("%w: object tags cannot be greater than %d", ErrInvalidTag, MaxObjectTags)
+	}
+	for k, v := range tags {
+		if k == "" || utf8.RuneCountInString(k) > MaxTagKeyLength {
+			return fmt.Errorf("%w: tag key must be 1-%d characters", ErrInvalidTag, MaxTagKeyLength)
+		}
+		if utf8.RuneCountInString(v) > MaxTagValueLength {
+			return fmt.Errorf("%w: tag value for %q exceeds %d characters", ErrInvalidTag, k, MaxTagValueLength)
+		}
+	}
+	return nil
+}
+
+// normalizeTags returns a copy of tags, or nil for an empty set so that
+// untagged objects omit the field from their metadata.
+func normalizeTags(tags map[string]string) map[string]string {
+	if len(tags) == 0 {
+		return nil
+	}
+	return maps.Clone(tags)
+}
+
+// GetObjectTagging returns the tags of the current version of an object.
+func (s *Store) GetObjectTagging(ctx context.Context, bucket, key string) (map[string]string, error) {
+	// Validate names (defense in depth)
+	if err := validateName(bucket); err != nil {
+		return nil, fmt.Errorf("invalid bucket name: %w", err)
+	}
+	if err := validateName(key); err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+
+	meta, err := s.HeadObject(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	return meta.Tags, nil
+}
+
+// PutObjectTagging replaces the tags of the current version of an object.
+// Tags are updated in place: no new version is created and LastModified is
+// unchanged. An empty tag set removes all tags.
+func (s *Store) PutObjectTagging(ctx context.Context, bucket, key string, tags map[string]string) error {
+	// Validate names (defense in depth)
+	if err := validateName(bucket); err != nil {
+		return fmt.Errorf("invalid bucket name: %w", err)
+	}
+	if err := validateName(key); err != nil {
+		return fmt.Errorf("invalid key: %w", err)
+	}
+	if err := validateTags(tags); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.getBucketMeta(bucket); err != nil {
+		return err
+	}
+
+	meta, err := s.getObjectMeta(bucket, key)
+	if err != nil {
+		return err
+	}
+	meta.Tags = normalizeTags(tags)
+
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal object meta: %w", err)
+	}
+	if err := syncedWriteFile(s.objectMetaPath(bucket, key), data, 0644); err != nil {
+		return fmt.Errorf("write object meta: %w", err)
+	}
+	return nil
+}
+
+// DeleteObjectTagging removes all tags from the current version of an object.
+func (s *Store) DeleteObjectTagging(ctx context.Context, bucket, key string) error {
+	return s.PutObjectTagging(ctx, bucket, key, nil)
+}
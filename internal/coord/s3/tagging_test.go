@@ -0,0 +1,269 @@
+package s3
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tunnelmesh/tunnelmesh/internal/auth"
+)
+
+func TestObjectTagging(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+
+	meta, err := store.PutObject(ctx, "bucket", "doc", strings.NewReader("hello"), 5, "text/plain", nil)
+	require.NoError(t, err)
+
+	tags, err := store.GetObjectTagging(ctx, "bucket", "doc")
+	require.NoError(t, err)
+	assert.Empty(t, tags)
+
+	require.NoError(t, store.PutObjectTagging(ctx, "bucket", "doc", map[string]string{"env": "prod", "team": "ops"}))
+	tags, err = store.GetObjectTagging(ctx, "bucket", "doc")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"env": "prod", "team": "ops"}, tags)
+
+	// Tagging does not create a new version
+	after, err := store.HeadObject(ctx, "bucket", "doc")
+	require.NoError(t, err)
+	assert.Equal(t, meta.VersionID, after.VersionID)
+	assert.True(t, meta.LastModified.Equal(after.LastModified))
+
+	require.NoError(t, store.DeleteObjectTagging(ctx, "bucket", "doc"))
+	tags, err = store.GetObjectTagging(ctx, "bucket", "doc")
+	require.NoError(t, err)
+	assert.Empty(t, tags)
+
+	assert.ErrorIs(t, store.PutObjectTagging(ctx, "bucket", "missing", map[string]string{"a": "b"}), ErrObjectNotFound)
+	assert.ErrorIs(t, store.PutObjectTagging(ctx, "bucket", "doc", map[string]string{"": "b"}), ErrInvalidTag)
+	assert.ErrorIs(t, store.PutObjectTagging(ctx, "bucket", "doc", map[string]string{"a": strings.Repeat("v", MaxTagValueLength+1)}), ErrInvalidTag)
+}
+
+func TestPutObjectWithTags(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+	require.NoError(t, store.CreateBucket(ctx, "ec-bucket", "alice", 2, &ErasureCodingPolicy{Enabled: true, DataShards: 2, ParityShards: 1}))
+
+	for _, bucket := range []string{"bucket", "ec-bucket"} {
+		tags := map[string]string{"env": "prod"}
+		meta, err := store.PutObjectWithTags(ctx, bucket, "doc", strings.NewReader("hello"), 5, "text/plain", nil, tags)
+		require.NoError(t, err, bucket)
+		assert.Equal(t, tags, meta.Tags, bucket)
+		got, err := store.GetObjectTagging(ctx, bucket, "doc")
+		require.NoError(t, err, bucket)
+		assert.Equal(t, tags, got, bucket)
+
+		// Overwriting without tags drops the old ones
+		_, err = store.PutObject(ctx, bucket, "doc", strings.NewReader("hello"), 5, "text/plain", nil)
+		require.NoError(t, err, bucket)
+		got, err = store.GetObjectTagging(ctx, bucket, "doc")
+		require.NoError(t, err, bucket)
+		assert.Empty(t, got, bucket)
+	}
+
+	_, err := store.PutObjectWithTags(ctx, "bucket", "bad", strings.NewReader("hello"), 5, "text/plain", nil, map[string]string{"": "v"})
+	assert.ErrorIs(t, err, ErrInvalidTag)
+	_, err = store.HeadObject(ctx, "bucket", "bad")
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+}
+
+func TestServer_ObjectTagging(t *testing.T) {
+	server, store := newTestServer(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+
+	// Tags supplied on upload via x-amz-tagging
+	req := httptest.NewRequest(http.MethodPut, "/bucket/doc", strings.NewReader("hello"))
+	req.Header.Set("x-amz-tagging", "env=prod&owner=alice%20smith")
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/bucket/doc?tagging", nil)
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var tagging Tagging
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &tagging))
+	assert.Equal(t, []Tag{{Key: "env", Value: "prod"}, {Key: "owner", Value: "alice smith"}}, tagging.TagSet.Tags)
+
+	// HEAD reports the tag count
+	req = httptest.NewRequest(http.MethodHead, "/bucket/doc", nil)
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	assert.Equal(t, "2", w.Header().Get("x-amz-tagging-count"))
+
+	// Replace tags
+	req = httptest.NewRequest(http.MethodPut, "/bucket/doc?tagging", strings.NewReader(`<Tagging><TagSet><Tag><Key>k</Key><Value>v</Value></Tag></TagSet></Tagging>`))
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	tags, err := store.GetObjectTagging(ctx, "bucket", "doc")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"k": "v"}, tags)
+
+	// The object content is untouched by tagging requests
+	req = httptest.NewRequest(http.MethodGet, "/bucket/doc", nil)
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	assert.Equal(t, "hello", w.Body.String())
+
+	// Duplicate keys are rejected
+	req = httptest.NewRequest(http.MethodPut, "/bucket/doc?tagging", strings.NewReader(`<Tagging><TagSet><Tag><Key>k</Key><Value>1</Value></Tag><Tag><Key>k</Key><Value>2</Value></Tag></TagSet></Tagging>`))
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "InvalidTag")
+
+	// Delete tags; empty tag sets still encode a TagSet element
+	req = httptest.NewRequest(http.MethodDelete, "/bucket/doc?tagging", nil)
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	_, err = store.HeadObject(ctx, "bucket", "doc")
+	require.NoError(t, err, "deleting tags must not delete the object")
+
+	req = httptest.NewRequest(http.MethodGet, "/bucket/doc?tagging", nil)
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "<TagSet></TagSet>")
+
+	// Unknown object
+	req = httptest.NewRequest(http.MethodGet, "/bucket/missing?tagging", nil)
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRBACAuthorizer_ObjectTagConditions(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "admin", 2, nil))
+	for _, key := range []string{"public.txt", "secret.txt"} {
+		_, err := store.PutObject(ctx, "bucket", key, strings.NewReader("x"), 1, "", nil)
+		require.NoError(t, err)
+	}
+	require.NoError(t, store.PutObjectTagging(ctx, "bucket", "public.txt", map[string]string{"classification": "public"}))
+
+	cs := NewCredentialStore()
+	accessKey, secretKey, err := cs.RegisterUser("bob", "bob-public-key")
+	require.NoError(t, err)
+	authz := auth.NewAuthorizer()
+	binding := auth.NewRoleBinding("bob", auth.RoleBucketRead, "bucket")
+	binding.ObjectTags = map[string]string{"classification": "public"}
+	authz.Bindings.Add(binding)
+
+	rbac := NewRBACAuthorizer(cs, authz)
+	rbac.SetObjectTagLookup(func(bucket, key string) map[string]string {
+		tags, _ := store.GetObjectTagging(context.Background(), bucket, key)
+		return tags
+	})
+
+	authorize := func(key string) error {
+		req := httptest.NewRequest(http.MethodGet, "/bucket/"+key, nil)
+		req.SetBasicAuth(accessKey, secretKey)
+		_, err := rbac.AuthorizeRequest(req, "get", "objects", "bucket", key)
+		return err
+	}
+
+	assert.NoError(t, authorize("public.txt"))
+	assert.ErrorIs(t, authorize("secret.txt"), ErrAccessDenied)
+	assert.ErrorIs(t, authorize("missing.txt"), ErrAccessDenied)
+}
+
+// newTagRBACServer returns a server authorizing bob through RBAC with the
+// given bindings, evaluated against object tags, and a function making
+// requests to it as bob.
+func newTagRBACServer(t *testing.T, authz *auth.Authorizer) (*Store, func(method, target, body string, header map[string]string) int) {
+	t.Helper()
+	store := newTestStoreWithCASForServer(t)
+	require.NoError(t, store.CreateBucket(context.Background(), "bucket", "admin", 2, nil))
+
+	cs := NewCredentialStore()
+	accessKey, secretKey, err := cs.RegisterUser("bob", "bob-public-key")
+	require.NoError(t, err)
+	rbac := NewRBACAuthorizer(cs, authz)
+	rbac.SetObjectTagLookup(func(bucket, key string) map[string]string {
+		tags, _ := store.GetObjectTagging(context.Background(), bucket, key)
+		return tags
+	})
+	server := NewServer(store, rbac, nil)
+
+	return store, func(method, target, body string, header map[string]string) int {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.SetBasicAuth(accessKey, secretKey)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, req)
+		return w.Code
+	}
+}
+
+const publicTagging = `<Tagging><TagSet><Tag><Key>classification</Key><Value>public</Value></Tag></TagSet></Tagging>`
+
+func TestServer_TaggingCannotGrantRead(t *testing.T) {
+	authz := auth.NewAuthorizer()
+	authz.AddRole(auth.Role{Name: "uploader", Rules: []auth.Rule{{Verbs: []string{"put"}, Resources: []string{"objects"}}}})
+	authz.Bindings.Add(auth.NewRoleBinding("bob", "uploader", "bucket"))
+	read := auth.NewRoleBinding("bob", auth.RoleBucketRead, "bucket")
+	read.ObjectTags = map[string]string{"classification": "public"}
+	authz.Bindings.Add(read)
+	store, do := newTagRBACServer(t, authz)
+
+	ctx := context.Background()
+	_, err := store.PutObject(ctx, "bucket", "secret.txt", strings.NewReader("secret"), 6, "", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusForbidden, do(http.MethodGet, "/bucket/secret.txt", "", nil))
+
+	// Retagging the object into the read binding is refused
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPut, "/bucket/secret.txt?tagging", publicTagging, nil))
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPut, "/bucket/secret.txt", "", map[string]string{
+		"x-amz-copy-source":       "/bucket/secret.txt",
+		"x-amz-tagging-directive": "REPLACE",
+		"x-amz-tagging":           "classification=public",
+	}))
+	tags, err := store.GetObjectTagging(ctx, "bucket", "secret.txt")
+	require.NoError(t, err)
+	assert.Empty(t, tags)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/bucket/secret.txt", "", nil))
+
+	// Bob's own content can carry the tag
+	assert.Equal(t, http.StatusOK, do(http.MethodPut, "/bucket/mine.txt", "mine", map[string]string{"x-amz-tagging": "classification=public"}))
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/bucket/mine.txt", "", nil))
+}
+
+func TestServer_TaggingCannotLeaveTagScope(t *testing.T) {
+	authz := auth.NewAuthorizer()
+	write := auth.NewRoleBinding("bob", auth.RoleBucketWrite, "bucket")
+	write.ObjectTags = map[string]string{"team": "a"}
+	authz.Bindings.Add(write)
+	store, do := newTagRBACServer(t, authz)
+
+	ctx := context.Background()
+	_, err := store.PutObject(ctx, "bucket", "doc.txt", strings.NewReader("doc"), 3, "", nil)
+	require.NoError(t, err)
+	require.NoError(t, store.PutObjectTagging(ctx, "bucket", "doc.txt", map[string]string{"team": "a"}))
+
+	// Dropping or changing the tag the binding requires is refused
+	assert.Equal(t, http.StatusForbidden, do(http.MethodDelete, "/bucket/doc.txt?tagging", "", nil))
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPut, "/bucket/doc.txt?tagging", `<Tagging><TagSet><Tag><Key>team</Key><Value>b</Value></Tag></TagSet></Tagging>`, nil))
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPut, "/bucket/doc.txt", "untagged", nil))
+	tags, err := store.GetObjectTagging(ctx, "bucket", "doc.txt")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "a"}, tags)
+
+	// Writes keeping it are allowed
+	assert.Equal(t, http.StatusOK, do(http.MethodPut, "/bucket/doc.txt?tagging", `<Tagging><TagSet><Tag><Key>team</Key><Value>a</Value></Tag><Tag><Key>stage</Key><Value>final</Value></Tag></TagSet></Tagging>`, nil))
+	assert.Equal(t, http.StatusOK, do(http.MethodPut, "/bucket/doc.txt", "v2", map[string]string{"x-amz-tagging": "team=a"}))
+}
@@ -0,0 +1,478 @@
+package s3
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Bucket versioning states (S3 PutBucketVersioning). A bucket that has never
+// been configured has an empty state and behaves like Suspended.
+const (
+	VersioningEnabled   = "Enabled"
+	VersioningSuspended = "Suspended"
+)
+
+// DeleteOutcome describes what a delete did to an object's version history.
+type DeleteOutcome struct {
+	VersionID    string // Version permanently removed, or the delete marker created
+	DeleteMarker bool   // A delete marker was created or removed
+}
+
+// SetBucketVersioning enables or suspends S3 versioning semantics on a bucket.
+// The store keeps version history regardless; with versioning Enabled, deleting
+// an object creates a delete marker in that history instead of moving the
+// object to the recycle bin.
+func (s *Store) SetBucketVersioning(ctx context.Context, bucket, status string) error {
+	if status != VersioningEnabled && status != VersioningSuspended {
+		return fmt.Errorf("%w: versioning status must be %s or %s", ErrInvalidRequest, VersioningEnabled, VersioningSuspended)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	meta, err := s.getBucketMeta(bucket)
+	if err != nil {
+		return err
+	}
+	meta.Versioning = status
+	return s.writeBucketMeta(bucket, meta)
+}
+
+// HeadObjectVersion returns the metadata of a specific object version. An
+// empty versionID means the current version. Returns ErrDeleteMarker (with the
+// marker's metadata) if the version is a delete marker.
+func (s *Store) HeadObjectVersion(ctx context.Context, bucket, key, versionID string) (*ObjectMeta, error) {
+	if err := validateVersionID(versionID); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, err := s.getBucketMeta(bucket); err != nil {
+		return nil, err
+	}
+	return s.getObjectVersionMeta(bucket, key, versionID)
+}
+
+// LatestDeleteMarker reports whether the latest version of a key is a delete
+// marker, returning the marker's version ID.
+func (s *Store) LatestDeleteMarker(ctx context.Context, bucket, key string) (string, bool) {
+	if validateName(bucket) != nil || validateName(key) != nil {
+		return "", false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, err := s.getObjectMeta(bucket, key); err == nil {
+		return "", false
+	}
+	archived := s.listArchivedVersions(bucket, key)
+	if len(archived) == 0 || !archived[0].DeleteMarker {
+		return "", false
+	}
+	return archived[0].VersionID, true
+}
+
+// DeleteObjectVersion deletes an object or one of its versions.
+//
+// With an empty versionID the current version is deleted: in a bucket with
+// versioning Enabled a delete marker is created and the object's history is
+// kept; otherwise the object is moved to the recycle bin as by DeleteObject.
+//
+// With a versionID that version is permanently removed from the history. If
+// it was the current version, or the delete marker hiding the object, the next
+// newest version becomes current. Chunks no longer referenced are reclaimed by
+// garbage collection.
+func (s *Store) DeleteObjectVersion(ctx context.Context, bucket, key, versionID string) (DeleteOutcome, error) {
+	// Validate names (defense in depth)
+	if err := validateName(bucket); err != nil {
+		return DeleteOutcome{}, fmt.Errorf("invalid bucket name: %w", err)
+	}
+	if err := validateName(key); err != nil {
+		return DeleteOutcome{}, fmt.Errorf("invalid key: %w", err)
+	}
+	if err := validateVersionID(versionID); err != nil {
+		return DeleteOutcome{}, err
+	}
+
+	if versionID == "" {
+		s.mu.RLock()
+		bucketMeta, err := s.getBucketMeta(bucket)
+		s.mu.RUnlock()
+		if err != nil {
+			return DeleteOutcome{}, err
+		}
+		if bucketMeta.Versioning != VersioningEnabled {
+			return DeleteOutcome{}, s.RecycleObject(ctx, bucket, key)
+		}
+		return s.createDeleteMarker(ctx, bucket, key)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.getBucketMeta(bucket); err != nil {
+		return DeleteOutcome{}, err
+	}
+
+	// Deleting the current version
+	if live, err := s.getObjectMeta(bucket, key); err == nil && live.VersionID == versionID {
+		if err := os.Remove(s.objectMetaPath(bucket, key)); err != nil {
+			return DeleteOutcome{}, fmt.Errorf("remove live object meta: %w", err)
+		}
+		s.releaseLiveObject(bucket, live)
+		if err := s.promoteLatestVersion(bucket, key); err != nil {
+			return DeleteOutcome{}, err
+		}
+		return DeleteOutcome{VersionID: versionID}, nil
+	} else if err == nil {
+		// A live object exists, so no archived version can be hiding it
+		return s.removeArchivedVersion(bucket, key, versionID, false)
+	} else if !errors.Is(err, ErrObjectNotFound) {
+		return DeleteOutcome{}, err
+	}
+
+	// No live object: removing the latest delete marker reveals the next version
+	archived := s.listArchivedVersions(bucket, key)
+	promote := len(archived) > 0 && archived[0].VersionID == versionID && archived[0].DeleteMarker
+	return s.removeArchivedVersion(bucket, key, versionID, promote)
+}
+
+// createDeleteMarker archives the current version of an object, if any, and
+// hides it behind a new delete marker. As in S3, a key with no current version
+// still gets a marker.
+func (s *Store) createDeleteMarker(ctx context.Context, bucket, key string) (DeleteOutcome, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addDeleteMarker(ctx, bucket, key)
+}
+
+// addDeleteMarker implements createDeleteMarker (caller must hold lock).
+func (s *Store) addDeleteMarker(ctx context.Context, bucket, key string) (DeleteOutcome, error) {
+	live, err := s.getObjectMeta(bucket, key)
+	if err != nil && !errors.Is(err, ErrObjectNotFound) {
+		return DeleteOutcome{}, err
+	}
+	hidden := live
+	if live != nil {
+		if err := s.archiveCurrentVersion(bucket, key); err != nil {
+			return DeleteOutcome{}, fmt.Errorf("archive current version: %w", err)
+		}
+	} else {
+		if archived := s.listArchivedVersions(bucket, key); len(archived) > 0 {
+			hidden = &archived[0]
+		}
+		if err := os.MkdirAll(s.versionDir(bucket, key), 0755); err != nil {
+			return DeleteOutcome{}, fmt.Errorf("create version dir: %w", err)
+		}
+	}
+
+	marker := ObjectMeta{
+		Key:          key,
+		LastModified: time.Now().UTC(),
+		VersionID:    generateVersionID(),
+		DeleteMarker: true,
+	}
+	// Never order the marker before the version it hides
+	if hidden != nil && !marker.LastModified.After(hidden.LastModified) {
+		marker.LastModified = hidden.LastModified.Add(time.Millisecond)
+	}
+	data, err := json.MarshalIndent(marker, "", "  ")
+	if err != nil {
+		return DeleteOutcome{}, fmt.Errorf("marshal delete marker: %w", err)
+	}
+	if err := syncedWriteFile(s.versionMetaPath(bucket, key, marker.VersionID), data, 0644); err != nil {
+		return DeleteOutcome{}, fmt.Errorf("write delete marker: %w", err)
+	}
+	s.statsVersionCount.Add(1)
+
+	if live != nil {
+		if err := os.Remove(s.objectMetaPath(bucket, key)); err != nil && !os.IsNotExist(err) {
+			return DeleteOutcome{}, fmt.Errorf("remove live object meta: %w", err)
+		}
+		s.releaseLiveObject(bucket, live)
+	}
+
+	s.pruneExpiredVersions(ctx, bucket, key)
+
+	return DeleteOutcome{VersionID: marker.VersionID, DeleteMarker: true}, nil
+}
+
+// removeArchivedVersion deletes one archived version file, then optionally
+// promotes the newest remaining version to current (caller must hold lock).
+func (s *Store) removeArchivedVersion(bucket, key, versionID string, promote bool) (DeleteOutcome, error) {
+	meta, err := s.getArchivedVersionMeta(bucket, key, versionID)
+	if err != nil {
+		return DeleteOutcome{}, err
+	}
+	if err := os.Remove(s.versionMetaPath(bucket, key, versionID)); err != nil {
+		return DeleteOutcome{}, fmt.Errorf("remove version meta: %w", err)
+	}
+	s.statsVersionCount.Add(-1)
+	s.statsVersionBytes.Add(-meta.Size)
+
+	if promote {
+		if err := s.promoteLatestVersion(bucket, key); err != nil {
+			return DeleteOutcome{}, err
+		}
+	}
+	return DeleteOutcome{VersionID: versionID, DeleteMarker: meta.DeleteMarker}, nil
+}
+
+// promoteLatestVersion makes the newest archived version of a key current,
+// unless there is none or it is a delete marker (caller must hold lock).
+// The promoted version keeps its version ID.
+func (s *Store) promoteLatestVersion(bucket, key string) error {
+	archived := s.listArchivedVersions(bucket, key)
+	if len(archived) == 0 || archived[0].DeleteMarker {
+		return nil
+	}
+	meta := archived[0]
+
+	metaPath := s.objectMetaPath(bucket, key)
+	if err := os.MkdirAll(filepath.Dir(metaPath), 0755); err != nil {
+		return fmt.Errorf("create meta dir: %w", err)
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal object meta: %w", err)
+	}
+	if err := syncedWriteFile(metaPath, data, 0644); err != nil {
+		return fmt.Errorf("write object meta: %w", err)
+	}
+	if err := os.Remove(s.versionMetaPath(bucket, key, meta.VersionID)); err != nil && !os.IsNotExist(err) {
+		s.logger.Warn().Err(err).Str("bucket", bucket).Str("key", key).Msg("failed to remove promoted version file")
+	} else {
+		s.statsVersionCount.Add(-1)
+		s.statsVersionBytes.Add(-meta.Size)
+	}
+
+	if meta.Size > 0 {
+		_ = s.updateBucketSize(bucket, meta.Size)
+		if s.quota != nil {
+			s.quota.Allocate(bucket, meta.Size)
+		}
+		s.statsLogicalBytes.Add(meta.Size)
+	}
+	s.statsObjectCount.Add(1)
+	return nil
+}
+
+// releaseLiveObject updates bucket size, quota and stats after an object's
+// live metadata has been removed (caller must hold lock).
+func (s *Store) releaseLiveObject(bucket string, meta *ObjectMeta) {
+	if meta.Size > 0 {
+		_ = s.updateBucketSize(bucket, -meta.Size)
+		if s.quota != nil {
+			s.quota.Release(bucket, meta.Size)
+		}
+		s.statsLogicalBytes.Add(-meta.Size)
+	}
+	s.statsObjectCount.Add(-1)
+}
+
+// getObjectVersionMeta returns the current version if versionID is empty or
+// matches it, otherwise the archived version (caller must hold lock).
+func (s *Store) getObjectVersionMeta(bucket, key, versionID string) (*ObjectMeta, error) {
+	live, err := s.getObjectMeta(bucket, key)
+	if versionID == "" {
+		return live, err
+	}
+	if err != nil && !errors.Is(err, ErrObjectNotFound) {
+		return nil, err
+	}
+	if live != nil && live.VersionID == versionID {
+		return live, nil
+	}
+
+	meta, err := s.getArchivedVersionMeta(bucket, key, versionID)
+	if err != nil {
+		return nil, err
+	}
+	if meta.DeleteMarker {
+		return meta, ErrDeleteMarker
+	}
+	return meta, nil
+}
+
+// listArchivedVersions returns the archived versions of a key, newest first
+// (caller must hold lock).
+func (s *Store) listArchivedVersions(bucket, key string) []ObjectMeta {
+	versionDir := s.versionDir(bucket, key)
+	entries, err := os.ReadDir(versionDir)
+	if err != nil {
+		return nil
+	}
+
+	var versions []ObjectMeta
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(versionDir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		var meta ObjectMeta
+		if err := json.Unmarshal(data, &meta); err != nil {
+			continue
+		}
+		versions = append(versions, meta)
+	}
+
+	sortVersionsNewestFirst(versions)
+	return versions
+}
+
+func sortVersionsNewestFirst(versions []ObjectMeta) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		if !versions[i].LastModified.Equal(versions[j].LastModified) {
+			return versions[i].LastModified.After(versions[j].LastModified)
+		}
+		return versions[i].VersionID > versions[j].VersionID
+	})
+}
+
+// ListObjectVersions lists every version and delete marker in a bucket, in
+// key order and newest first within a key, S3 ListObjectVersions style.
+// Listing resumes after (keyMarker, versionIDMarker); a keyMarker without a
+// versionIDMarker resumes after all versions of that key.
+// Returns (versions, isTruncated, nextKeyMarker, nextVersionIDMarker, error).
+func (s *Store) ListObjectVersions(ctx context.Context, bucket, prefix, keyMarker, versionIDMarker string, maxKeys int) ([]VersionInfo, bool, string, string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, err := s.getBucketMeta(bucket); err != nil {
+		return nil, false, "", "", err
+	}
+
+	keys, err := s.versionedKeys(bucket, prefix)
+	if err != nil {
+		return nil, false, "", "", err
+	}
+
+	var result []VersionInfo
+	for _, key := range keys {
+		if key < keyMarker || (key == keyMarker && versionIDMarker == "") {
+			continue
+		}
+
+		var versions []ObjectMeta
+		live, err := s.getObjectMeta(bucket, key)
+		if err == nil {
+			versions = append(versions, *live)
+		}
+		versions = append(versions, s.listArchivedVersions(bucket, key)...)
+		if live != nil {
+			// Keep the live version first even if an archived one sorts later
+			sortVersionsNewestFirst(versions[1:])
+		}
+
+		skipping := key == keyMarker
+		for i, v := range versions {
+			if skipping {
+				if v.VersionID == versionIDMarker {
+					skipping = false
+				}
+				continue
+			}
+			result = append(result, VersionInfo{
+				Key:          key,
+				VersionID:    v.VersionID,
+				Size:         v.Size,
+				ETag:         v.ETag,
+				LastModified: v.LastModified,
+				IsCurrent:    i == 0 && (live != nil || v.DeleteMarker),
+				DeleteMarker: v.DeleteMarker,
+			})
+			if maxKeys > 0 && len(result) > maxKeys {
+				last := result[maxKeys-1]
+				return result[:maxKeys], true, last.Key, last.VersionID, nil
+			}
+		}
+	}
+
+	return result, false, "", "", nil
+}
+
+// versionedKeys returns, sorted, every key in a bucket with a live object or
+// version history (caller must hold lock).
+func (s *Store) versionedKeys(bucket, prefix string) ([]string, error) {
+	seen := make(map[string]struct{})
+
+	metaDir := filepath.Join(s.bucketPath(bucket), "meta")
+	err := filepath.WalkDir(metaDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		rel, err := filepath.Rel(metaDir, path)
+		if err != nil {
+			return nil
+		}
+		seen[filepath.ToSlash(strings.TrimSuffix(rel, ".json"))] = struct{}{}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk meta dir: %w", err)
+	}
+
+	// A version directory is any directory holding version files; keys may
+	// contain slashes, so version directories can also nest other keys.
+	versionsDir := filepath.Join(s.bucketPath(bucket), "versions")
+	err = filepath.WalkDir(versionsDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		rel, err := filepath.Rel(versionsDir, filepath.Dir(path))
+		if err != nil || rel == "." {
+			return nil
+		}
+		seen[filepath.ToSlash(rel)] = struct{}{}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk versions dir: %w", err)
+	}
+
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// validateVersionID rejects version IDs that could escape the versions
+// directory. An empty ID is valid and means the current version.
+func validateVersionID(versionID string) error {
+	if versionID == "" {
+		return nil
+	}
+	if err := validateName(versionID); err != nil || strings.ContainsAny(versionID, `/\`) {
+		return fmt.Errorf("%w: invalid version ID", ErrInvalidRequest)
+	}
+	return nil
+}
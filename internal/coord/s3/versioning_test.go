@@ -0,0 +1,271 @@
+package s3
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteObjectVersion_DeleteMarkers(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+	require.NoError(t, store.SetBucketVersioning(ctx, "bucket", VersioningEnabled))
+
+	v1, err := store.PutObject(ctx, "bucket", "doc", strings.NewReader("one"), 3, "text/plain", nil)
+	require.NoError(t, err)
+	v2, err := store.PutObject(ctx, "bucket", "doc", strings.NewReader("two!"), 4, "text/plain", nil)
+	require.NoError(t, err)
+
+	// A plain delete hides the object behind a marker instead of recycling it
+	outcome, err := store.DeleteObjectVersion(ctx, "bucket", "doc", "")
+	require.NoError(t, err)
+	assert.True(t, outcome.DeleteMarker)
+	markerID := outcome.VersionID
+
+	_, err = store.HeadObject(ctx, "bucket", "doc")
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+	latest, ok := store.LatestDeleteMarker(ctx, "bucket", "doc")
+	assert.True(t, ok)
+	assert.Equal(t, markerID, latest)
+
+	recycled, err := store.ListRecycledObjects(ctx, "bucket")
+	require.NoError(t, err)
+	assert.Empty(t, recycled)
+
+	bucketMeta, err := store.HeadBucket(ctx, "bucket")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), bucketMeta.SizeBytes)
+
+	// Older versions stay readable; the marker itself is not
+	rc, _, err := store.GetObjectVersion(ctx, "bucket", "doc", v2.VersionID)
+	require.NoError(t, err)
+	data, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	assert.Equal(t, "two!", string(data))
+	_, err = store.HeadObjectVersion(ctx, "bucket", "doc", markerID)
+	assert.ErrorIs(t, err, ErrDeleteMarker)
+	_, err = store.RestoreVersion(ctx, "bucket", "doc", markerID)
+	assert.ErrorIs(t, err, ErrDeleteMarker)
+
+	// Removing the marker brings back the newest version, keeping its ID
+	outcome, err = store.DeleteObjectVersion(ctx, "bucket", "doc", markerID)
+	require.NoError(t, err)
+	assert.Equal(t, DeleteOutcome{VersionID: markerID, DeleteMarker: true}, outcome)
+
+	current, err := store.HeadObject(ctx, "bucket", "doc")
+	require.NoError(t, err)
+	assert.Equal(t, v2.VersionID, current.VersionID)
+	bucketMeta, err = store.HeadBucket(ctx, "bucket")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), bucketMeta.SizeBytes)
+
+	// Permanently deleting the current version promotes the previous one
+	_, err = store.DeleteObjectVersion(ctx, "bucket", "doc", v2.VersionID)
+	require.NoError(t, err)
+	current, err = store.HeadObject(ctx, "bucket", "doc")
+	require.NoError(t, err)
+	assert.Equal(t, v1.VersionID, current.VersionID)
+
+	versions, err := store.ListVersions(ctx, "bucket", "doc")
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	assert.True(t, versions[0].IsCurrent)
+
+	_, err = store.DeleteObjectVersion(ctx, "bucket", "doc", "missing-version")
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+	_, err = store.DeleteObjectVersion(ctx, "bucket", "doc", "../doc")
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+}
+
+func TestDeleteObjectVersion_MissingKeyCreatesMarker(t *testing.T) {
+	server, store := newTestServer(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+	require.NoError(t, store.SetBucketVersioning(ctx, "bucket", VersioningEnabled))
+
+	outcome, err := store.DeleteObjectVersion(ctx, "bucket", "ghost", "")
+	require.NoError(t, err)
+	assert.True(t, outcome.DeleteMarker)
+	require.NotEmpty(t, outcome.VersionID)
+	latest, ok := store.LatestDeleteMarker(ctx, "bucket", "ghost")
+	assert.True(t, ok)
+	assert.Equal(t, outcome.VersionID, latest)
+
+	// Deleting again stacks a newer marker, also through the API
+	req := httptest.NewRequest(http.MethodDelete, "/bucket/ghost", nil)
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "true", w.Header().Get("x-amz-delete-marker"))
+	second := w.Header().Get("x-amz-version-id")
+	require.NotEmpty(t, second)
+	assert.NotEqual(t, outcome.VersionID, second)
+
+	versions, _, _, _, err := store.ListObjectVersions(ctx, "bucket", "", "", "", 0)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, second, versions[0].VersionID)
+	assert.True(t, versions[0].IsCurrent)
+	assert.Equal(t, outcome.VersionID, versions[1].VersionID)
+	assert.True(t, versions[1].DeleteMarker)
+}
+
+func TestDeleteObjectVersion_UnversionedBucketRecycles(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+
+	_, err := store.PutObject(ctx, "bucket", "doc", strings.NewReader("one"), 3, "text/plain", nil)
+	require.NoError(t, err)
+
+	outcome, err := store.DeleteObjectVersion(ctx, "bucket", "doc", "")
+	require.NoError(t, err)
+	assert.False(t, outcome.DeleteMarker)
+
+	recycled, err := store.ListRecycledObjects(ctx, "bucket")
+	require.NoError(t, err)
+	assert.Len(t, recycled, 1)
+	_, ok := store.LatestDeleteMarker(ctx, "bucket", "doc")
+	assert.False(t, ok)
+
+	assert.ErrorIs(t, store.SetBucketVersioning(ctx, "bucket", "On"), ErrInvalidRequest)
+	assert.ErrorIs(t, store.SetBucketVersioning(ctx, "missing", VersioningEnabled), ErrBucketNotFound)
+}
+
+func TestListObjectVersions(t *testing.T) {
+	store := newTestStoreWithCAS(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+	require.NoError(t, store.SetBucketVersioning(ctx, "bucket", VersioningEnabled))
+
+	for _, body := range []string{"a1", "a2"} {
+		_, err := store.PutObject(ctx, "bucket", "a", strings.NewReader(body), 2, "", nil)
+		require.NoError(t, err)
+	}
+	_, err := store.PutObject(ctx, "bucket", "dir/b", strings.NewReader("b1"), 2, "", nil)
+	require.NoError(t, err)
+	_, err = store.DeleteObjectVersion(ctx, "bucket", "dir/b", "")
+	require.NoError(t, err)
+
+	versions, truncated, _, _, err := store.ListObjectVersions(ctx, "bucket", "", "", "", 100)
+	require.NoError(t, err)
+	assert.False(t, truncated)
+	require.Len(t, versions, 4)
+
+	assert.Equal(t, "a", versions[0].Key)
+	assert.True(t, versions[0].IsCurrent)
+	assert.Equal(t, "a", versions[1].Key)
+	assert.False(t, versions[1].IsCurrent)
+	assert.Equal(t, "dir/b", versions[2].Key)
+	assert.True(t, versions[2].DeleteMarker)
+	assert.True(t, versions[2].IsCurrent)
+	assert.Equal(t, "dir/b", versions[3].Key)
+	assert.False(t, versions[3].DeleteMarker)
+	assert.False(t, versions[3].IsCurrent)
+
+	// Paging resumes after the returned markers
+	page, truncated, nextKey, nextVersion, err := store.ListObjectVersions(ctx, "bucket", "", "", "", 3)
+	require.NoError(t, err)
+	assert.True(t, truncated)
+	assert.Equal(t, versions[:3], page)
+	page, truncated, _, _, err = store.ListObjectVersions(ctx, "bucket", "", nextKey, nextVersion, 3)
+	require.NoError(t, err)
+	assert.False(t, truncated)
+	assert.Equal(t, versions[3:], page)
+
+	page, _, _, _, err = store.ListObjectVersions(ctx, "bucket", "dir/", "", "", 100)
+	require.NoError(t, err)
+	assert.Len(t, page, 2)
+}
+
+func TestServer_Versioning(t *testing.T) {
+	server, store := newTestServer(t)
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx, "bucket", "alice", 2, nil))
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, req)
+		return w
+	}
+
+	// Unconfigured buckets report no status
+	w := do(http.MethodGet, "/bucket?versioning", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "<Status>")
+
+	w = do(http.MethodPut, "/bucket?versioning", `<VersioningConfiguration><Status>Enabled</Status></VersioningConfiguration>`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = do(http.MethodGet, "/bucket?versioning", "")
+	assert.Contains(t, w.Body.String(), "<Status>Enabled</Status>")
+	w = do(http.MethodPut, "/bucket?versioning", `<VersioningConfiguration><Status>Bogus</Status></VersioningConfiguration>`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = do(http.MethodPut, "/bucket/doc", "one")
+	require.Equal(t, http.StatusOK, w.Code)
+	v1 := w.Header().Get("x-amz-version-id")
+	require.NotEmpty(t, v1)
+	w = do(http.MethodPut, "/bucket/doc", "two")
+	require.Equal(t, http.StatusOK, w.Code)
+	v2 := w.Header().Get("x-amz-version-id")
+
+	w = do(http.MethodGet, "/bucket/doc?versionId="+v1, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "one", w.Body.String())
+	assert.Equal(t, v1, w.Header().Get("x-amz-version-id"))
+
+	w = do(http.MethodHead, "/bucket/doc", "")
+	assert.Equal(t, v2, w.Header().Get("x-amz-version-id"))
+
+	// Delete creates a marker
+	w = do(http.MethodDelete, "/bucket/doc", "")
+	require.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "true", w.Header().Get("x-amz-delete-marker"))
+	markerID := w.Header().Get("x-amz-version-id")
+	require.NotEmpty(t, markerID)
+
+	w = do(http.MethodGet, "/bucket/doc", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "true", w.Header().Get("x-amz-delete-marker"))
+	assert.Equal(t, markerID, w.Header().Get("x-amz-version-id"))
+
+	w = do(http.MethodHead, "/bucket/doc?versionId="+markerID, "")
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "true", w.Header().Get("x-amz-delete-marker"))
+
+	w = do(http.MethodGet, "/bucket?versions", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var list ListVersionsResult
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.DeleteMarkers, 1)
+	assert.True(t, list.DeleteMarkers[0].IsLatest)
+	require.Len(t, list.Versions, 2)
+	assert.Equal(t, v2, list.Versions[0].VersionID)
+
+	// Deleting the marker by version restores the object
+	w = do(http.MethodDelete, "/bucket/doc?versionId="+markerID, "")
+	require.Equal(t, http.StatusNoContent, w.Code)
+	w = do(http.MethodGet, "/bucket/doc", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "two", w.Body.String())
+
+	// DeleteObjects accepts version IDs
+	w = do(http.MethodPost, "/bucket?delete", `<Delete><Object><Key>doc</Key><VersionId>`+v1+`</VersionId></Object></Delete>`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var result DeleteResult
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &result))
+	require.Len(t, result.Deleted, 1)
+	assert.Equal(t, v1, result.Deleted[0].VersionID)
+	assert.Empty(t, result.Errors)
+	_, err := store.HeadObjectVersion(ctx, "bucket", "doc", v1)
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+}
@@ -15,6 +15,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -45,9 +46,11 @@ type peerInfo struct {
 	registeredAt   time.Time
 	lastStatsTime  time.Time
 	prevStatsTime  time.Time
-	aliases        []string // DNS aliases registered by this peer
-	peerID         string   // Peer ID derived from peer's public key (SHA256[:8] hex)
-	hasMonitoring  bool     // True if coordinator has monitoring (Prometheus/Grafana) configured
+	aliases        []string        // DNS aliases registered by this peer
+	services       []proto.Service // Services advertised as DNS SRV records
+	routes         []string        // Subnet routes advertised by this peer (canonical CIDR)
+	peerID         string          // Peer ID derived from peer's public key (SHA256[:8] hex)
+	hasMonitoring  bool            // True if coordinator has monitoring (Prometheus/Grafana) configured
 
 	// Latency metrics reported by peer
 	coordinatorRTT int64            // Peer's reported RTT to coordinator (ms)
@@ -79,27 +82,40 @@ type Server struct {
 	coordinators       map[string]*peerInfo // Subset of peers that are coordinators, for O(1) lookups
 	peersMu            sync.RWMutex
 	ipAlloc            *ipAllocator
-	dnsCache           map[string]string // hostname -> mesh IP
-	aliasOwner         map[string]string // alias -> peer name (reverse lookup for ownership)
+	dnsCache           map[string]string            // hostname -> mesh IP
+	aliasOwner         map[string]string            // alias -> peer name (reverse lookup for ownership)
+	dnsZone            []proto.DNSZoneRecord        // Custom DNS records, sorted by name and type
+	routeApprovals     map[string][]string          // peer name -> approved subnet routes
+	peerTags           map[string][]string          // peer name -> tags assigned by an admin or join key
+	joinKeys           map[string]*proto.JoinKey    // key hash -> join key
+	revokedPeers       map[string]proto.RevokedPeer // peer ID -> revocation
+	keysMu             sync.RWMutex                 // Protects joinKeys and revokedPeers
 	serverStats        serverStats
 	relay              *relayManager
 	holePunch          *holePunchManager
-	wgStore            *wireguard.Store             // WireGuard client storage
-	ca                 *CertificateAuthority        // Internal CA for mesh TLS certs
-	version            string                       // Server version for admin display
-	sseHub             *sseHub                      // SSE hub for real-time dashboard updates
-	ipGeoCache         *IPGeoCache                  // IP geolocation cache for location fallback
-	coordIPs           atomic.Pointer[coordIPSet]   // Coordinator mesh IPs (original + sorted) for DNS and write forwarding
-	s3ForwardTransport *http.Transport              // Shared transport for S3 write forwarding (reuses connections)
-	peerListings       atomic.Pointer[peerListings] // Pre-merged peer listing data (from system store)
-	localListingIndex  atomic.Pointer[listingIndex] // This coordinator's own listing index (in-memory)
-	listingIndexDirty  atomic.Bool                  // Whether local index needs persisting
-	listingIndexNotify chan struct{}                // Signal for immediate persist+load cycle
-	coordMetrics       *CoordMetrics                // Prometheus metrics for coordinator
-	metricsRegistry    prometheus.Registerer        // Prometheus registry for metrics (shared with peer metrics)
+	wgStore            *wireguard.Store                // WireGuard client storage
+	ca                 *CertificateAuthority           // Internal CA for mesh TLS certs
+	certsSaveMu        sync.Mutex                      // Serializes saving the CA's issued certificates
+	acme               *acmeState                      // ACME accounts, orders and certificates
+	psk                *pskKeys                        // Handshake pre-shared keys (nil if disabled)
+	tlsCert            atomic.Pointer[tls.Certificate] // Mesh TLS certificate served by admin, S3 and NFS
+	version            string                          // Server version for admin display
+	sseHub             *sseHub                         // SSE hub for real-time dashboard updates
+	ipGeoCache         *IPGeoCache                     // IP geolocation cache for location fallback
+	coordIPs           atomic.Pointer[coordIPSet]      // Coordinator mesh IPs (original + sorted) for DNS and write forwarding
+	s3ForwardTransport *http.Transport                 // Shared transport for S3 write forwarding (reuses connections)
+	meshTLS            atomic.Pointer[tls.Config]      // Mesh TLS client config, also used to verify coordinators' client certs
+	s3Port             atomic.Int32                    // Port of the S3 API listener, 0 until started
+	peerListings       atomic.Pointer[peerListings]    // Pre-merged peer listing data (from system store)
+	localListingIndex  atomic.Pointer[listingIndex]    // This coordinator's own listing index (in-memory)
+	listingIndexDirty  atomic.Bool                     // Whether local index needs persisting
+	listingIndexNotify chan struct{}                   // Signal for immediate persist+load cycle
+	coordMetrics       *CoordMetrics                   // Prometheus metrics for coordinator
+	metricsRegistry    prometheus.Registerer           // Prometheus registry for metrics (shared with peer metrics)
 	// S3 storage
 	s3Store             *s3.Store            // S3 file-based storage
 	s3Server            *s3.Server           // S3 HTTP server
+	s3Notifier          *s3.Notifier         // S3 bucket event notifier
 	s3Authorizer        *auth.Authorizer     // RBAC authorizer for S3
 	s3Credentials       *s3.CredentialStore  // S3 credential store
 	s3SystemStore       *s3.SystemStore      // System bucket accessor
@@ -310,13 +326,17 @@ func NewServer(ctx context.Context, cfg *config.PeerConfig) (*Server, error) {
 	ctx, cancel := context.WithCancel(ctx)
 
 	srv := &Server{
-		cancel:       cancel,
-		cfg:          cfg,
-		mux:          http.NewServeMux(),
-		peers:        make(map[string]*peerInfo),
-		coordinators: make(map[string]*peerInfo),
-		dnsCache:     make(map[string]string),
-		aliasOwner:   make(map[string]string),
+		cancel:         cancel,
+		cfg:            cfg,
+		mux:            http.NewServeMux(),
+		peers:          make(map[string]*peerInfo),
+		coordinators:   make(map[string]*peerInfo),
+		dnsCache:       make(map[string]string),
+		aliasOwner:     make(map[string]string),
+		routeApprovals: make(map[string][]string),
+		peerTags:       make(map[string][]string),
+		joinKeys:       make(map[string]*proto.JoinKey),
+		revokedPeers:   make(map[string]proto.RevokedPeer),
 		serverStats: serverStats{
 			startTime: time.Now(),
 		},
@@ -359,6 +379,29 @@ func NewServer(ctx context.Context, cfg *config.PeerConfig) (*Server, error) {
 		return nil, fmt.Errorf("initialize CA: %w", err)
 	}
 	srv.ca = ca
+	if cfg.Coordinator.CertLifetime != "" {
+		lifetime, err := time.ParseDuration(cfg.Coordinator.CertLifetime)
+		if err != nil || lifetime < 10*time.Minute {
+			return nil, fmt.Errorf("invalid cert_lifetime %q: must be a duration of at least 10m", cfg.Coordinator.CertLifetime)
+		}
+		ca.SetCertLifetime(lifetime)
+	}
+	if srv.s3SystemStore != nil {
+		if certs, err := srv.s3SystemStore.LoadIssuedCerts(ctx); err == nil && len(certs) > 0 {
+			log.Info().Int("certs", len(certs)).Msg("recovering issued certificates")
+			ca.RestoreIssuedCerts(certs)
+		}
+	}
+	if err := srv.initPSK(ctx); err != nil {
+		return nil, fmt.Errorf("initialize PSK: %w", err)
+	}
+	srv.acme = newACMEState()
+	if srv.s3SystemStore != nil {
+		if accounts, err := srv.s3SystemStore.LoadACMEAccounts(ctx); err == nil && len(accounts) > 0 {
+			log.Info().Int("accounts", len(accounts)).Msg("recovering ACME accounts")
+			srv.acme.accounts = accounts
+		}
+	}
 
 	// Shared transport for forwarding S3 writes to other coordinators.
 	// TLS config is set later by SetMeshTLS() with the registration CA.
@@ -372,15 +415,21 @@ func NewServer(ctx context.Context, cfg *config.PeerConfig) (*Server, error) {
 
 	// Initialize packet filter
 	srv.filter = routing.NewPacketFilter(cfg.Coordinator.Filter.IsDefaultDeny())
+	srv.filter.SetICMPDefaultDeny(cfg.Coordinator.Filter.ICMPDefaultDeny)
 
 	// Load coordinator config rules
 	coordRules := make([]routing.FilterRule, len(cfg.Coordinator.Filter.Rules))
 	for i, r := range cfg.Coordinator.Filter.Rules {
 		coordRules[i] = routing.FilterRule{
-			Port:       r.Port,
-			Protocol:   r.ProtocolNumber(),
-			Action:     routing.ParseFilterAction(r.Action),
-			SourcePeer: r.SourcePeer,
+			Port:        r.Port,
+			PortEnd:     r.PortEnd,
+			Protocol:    r.ProtocolNumber(),
+			ICMPType:    r.ICMPType,
+			Action:      routing.ParseFilterAction(r.Action),
+			SourcePeer:  r.SourcePeer,
+			SourceCIDR:  r.SourceCIDR,
+			SourceGroup: r.SourceGroup,
+			DestGroup:   r.DestGroup,
 		}
 	}
 	srv.filter.SetCoordinatorRules(coordRules)
@@ -444,6 +493,9 @@ func NewServer(ctx context.Context, cfg *config.PeerConfig) (*Server, error) {
 		// Wire replicator into S3 store for distributed reads (fetching remote chunks)
 		srv.s3Store.SetReplicator(srv.replicator)
 
+		// Replicate objects written through the S3 API (PutObject, multipart uploads)
+		srv.s3Server.SetReplicationQueue(srv.replicator)
+
 		// Initialize rebalancer for automatic data redistribution on topology changes
 		rebalancer := replication.NewRebalancer(srv.replicator, s3Adapter, chunkRegistry, log.Logger)
 		rebalancer.OnCycleComplete = func(stats replication.RebalancerStats) {
@@ -470,6 +522,7 @@ func NewServer(ctx context.Context, cfg *config.PeerConfig) (*Server, error) {
 // coordinator's own certificate for client authentication.
 // Must be called before any replication traffic is sent.
 func (s *Server) SetMeshTLS(cfg *tls.Config) {
+	s.meshTLS.Store(cfg)
 	if s.meshTransport != nil {
 		s.meshTransport.SetTLSConfig(cfg)
 	}
@@ -565,11 +618,16 @@ func (s *Server) recoverFilterRules(ctx context.Context) error {
 			continue
 		}
 		tempRules = append(tempRules, routing.FilterRule{
-			Port:       r.Port,
-			Protocol:   routing.ProtocolFromString(r.Protocol),
-			Action:     routing.ParseFilterAction(r.Action),
-			Expires:    r.Expires,
-			SourcePeer: r.SourcePeer,
+			Port:        r.Port,
+			PortEnd:     r.PortEnd,
+			Protocol:    routing.ProtocolFromString(r.Protocol),
+			ICMPType:    r.ICMPType,
+			Action:      routing.ParseFilterAction(r.Action),
+			Expires:     r.Expires,
+			SourcePeer:  r.SourcePeer,
+			SourceCIDR:  r.SourceCIDR,
+			SourceGroup: r.SourceGroup,
+			DestGroup:   r.DestGroup,
 		})
 	}
 
@@ -610,11 +668,16 @@ func (s *Server) SaveFilterRules(ctx context.Context) error {
 			}
 
 			persisted := s3.FilterRulePersisted{
-				Port:       r.Rule.Port,
-				Protocol:   routing.ProtocolToString(r.Rule.Protocol),
-				Action:     r.Rule.Action.String(),
-				Expires:    r.Rule.Expires,
-				SourcePeer: r.Rule.SourcePeer,
+				Port:        r.Rule.Port,
+				PortEnd:     r.Rule.PortEnd,
+				Protocol:    routing.ProtocolToString(r.Rule.Protocol),
+				ICMPType:    r.Rule.ICMPType,
+				Action:      r.Rule.Action.String(),
+				Expires:     r.Rule.Expires,
+				SourcePeer:  r.Rule.SourcePeer,
+				SourceCIDR:  r.Rule.SourceCIDR,
+				SourceGroup: r.Rule.SourceGroup,
+				DestGroup:   r.Rule.DestGroup,
 			}
 			data.Temporary = append(data.Temporary, persisted)
 		}
@@ -698,6 +761,11 @@ func (s *Server) Shutdown(ctx context.Context) error {
 		}
 	}
 
+	// Stop S3 event notifier, abandoning pending webhook retries
+	if s.s3Notifier != nil {
+		s.s3Notifier.Close()
+	}
+
 	// Stop replicator if running
 	if s.replicator != nil {
 		log.Info().Msg("stopping replicator")
@@ -823,6 +891,7 @@ func (s *Server) StartPeriodicCleanup(ctx context.Context) {
 			log.Info().
 				Str("coordinator", s.cfg.Name).
 				Int("versions_pruned", gcStats.VersionsPruned).
+				Int("objects_expired", gcStats.ObjectsExpired).
 				Int("chunks_deleted", gcStats.ChunksDeleted).
 				Int64("bytes_reclaimed", gcStats.BytesReclaimed).
 				Int("objects_scanned", gcStats.ObjectsScanned).
@@ -1069,6 +1138,7 @@ func (s *Server) SetVersion(version string) {
 func (s *Server) setupRoutes(ctx context.Context) {
 	s.mux.HandleFunc("/health", s.handleHealth)
 	s.mux.HandleFunc("/ca.crt", s.handleCACert) // CA cert for mesh TLS (no auth)
+	s.mux.HandleFunc("/ca.crl", s.handleCACRL)  // CA revocation list (no auth)
 	s.mux.HandleFunc("/api/v1/register", s.withAuth(s.handleRegister))
 	s.mux.HandleFunc("/api/v1/peers", s.withAuth(s.handlePeers))
 	s.mux.HandleFunc("/api/v1/peers/", s.withAuth(s.handlePeerByName))
@@ -1187,6 +1257,9 @@ func (s *Server) initS3Storage(ctx context.Context, cfg *config.PeerConfig) erro
 	// Set recycle bin retention config
 	store.SetRecycleBinRetentionDays(cfg.Coordinator.S3.RecycleBinRetentionDays)
 
+	// Set incomplete multipart upload expiry
+	store.SetMultipartUploadExpiry(time.Duration(cfg.Coordinator.S3.MultipartUploadExpiryDays) * 24 * time.Hour)
+
 	// Set version retention config
 	store.SetVersionRetentionDays(cfg.Coordinator.S3.VersionRetentionDays)
 	store.SetMaxVersionsPerObject(cfg.Coordinator.S3.MaxVersionsPerObject)
@@ -1204,11 +1277,21 @@ func (s *Server) initS3Storage(ctx context.Context, cfg *config.PeerConfig) erro
 
 	// Create RBAC authorizer for S3
 	rbacAuth := s3.NewRBACAuthorizer(s.s3Credentials, s.s3Authorizer)
+	rbacAuth.SetObjectTagLookup(func(bucket, key string) map[string]string {
+		tags, _ := store.GetObjectTagging(context.Background(), bucket, key)
+		return tags
+	})
 
 	// Create S3 server (metrics are initialized later in SetMetricsRegistry
 	// when the correct Prometheus registry is available)
 	s.s3Server = s3.NewServer(store, rbacAuth, nil)
 
+	// Deliver bucket event notifications to webhooks and the SSE stream
+	store.SetWebhookResolver(s.resolveWebhookHost)
+	s.s3Notifier = s3.NewNotifier(store)
+	s.s3Notifier.SetPublisher(s.notifyS3Event)
+	s.s3Server.SetEventNotifier(s.s3Notifier)
+
 	// Create system store for internal coordinator data
 	// Use a service peer ID for the coordinator
 	servicePeerID := auth.ServicePeerPrefix + "coordinator"
@@ -1291,7 +1374,7 @@ func (s *Server) initS3Storage(ctx context.Context, cfg *config.PeerConfig) erro
 }
 
 // recoverCoordinatorState recovers ephemeral coordinator state from S3.
-// This includes WG concentrator assignment and DNS cache/aliases.
+// This includes WG concentrator assignment, DNS cache/aliases and custom DNS records.
 func (s *Server) recoverCoordinatorState(ctx context.Context, cfg *config.PeerConfig, systemStore *s3.SystemStore) {
 	// Recover WireGuard concentrator assignment
 	if concentrator, err := systemStore.LoadWGConcentrator(ctx); err == nil && concentrator != "" {
@@ -1325,6 +1408,49 @@ func (s *Server) recoverCoordinatorState(ctx context.Context, cfg *config.PeerCo
 		s.peersMu.Unlock()
 	}
 
+	// Recover custom DNS records
+	if zone, err := systemStore.LoadDNSZone(ctx); err == nil && len(zone) > 0 {
+		log.Info().Int("records", len(zone)).Msg("recovering DNS zone")
+		s.peersMu.Lock()
+		s.dnsZone = zone
+		sortZoneRecords(s.dnsZone)
+		s.peersMu.Unlock()
+	}
+
+	// Recover approved subnet routes
+	if approvals, err := systemStore.LoadRouteApprovals(ctx); err == nil && len(approvals) > 0 {
+		log.Info().Int("peers", len(approvals)).Msg("recovering subnet route approvals")
+		s.peersMu.Lock()
+		s.routeApprovals = approvals
+		s.peersMu.Unlock()
+	}
+
+	// Recover peer tags
+	if tags, err := systemStore.LoadPeerTags(ctx); err == nil && len(tags) > 0 {
+		log.Info().Int("peers", len(tags)).Msg("recovering peer tags")
+		s.peersMu.Lock()
+		s.peerTags = tags
+		s.peersMu.Unlock()
+	}
+
+	// Recover join keys and revoked peers
+	if keys, err := systemStore.LoadJoinKeys(ctx); err == nil && len(keys) > 0 {
+		log.Info().Int("keys", len(keys)).Msg("recovering join keys")
+		s.keysMu.Lock()
+		for hash, key := range keys {
+			s.joinKeys[hash] = &key
+		}
+		s.keysMu.Unlock()
+	}
+	if revoked, err := systemStore.LoadRevokedPeers(ctx); err == nil && len(revoked) > 0 {
+		log.Info().Int("peers", len(revoked)).Msg("recovering revoked peers")
+		s.keysMu.Lock()
+		for _, rp := range revoked {
+			s.revokedPeers[rp.PeerID] = rp
+		}
+		s.keysMu.Unlock()
+	}
+
 	// Recover coordinator IPs so full list is available before all coordinators re-register
 	if coordIPs, err := systemStore.LoadCoordinatorIPs(ctx); err == nil && len(coordIPs) > 0 {
 		log.Info().Strs("ips", coordIPs).Msg("recovering coordinator IPs")
@@ -1428,14 +1554,22 @@ func (s *Server) StartS3Server(addr string, tlsCert *tls.Certificate) error {
 		return fmt.Errorf("listen on %s: %w", addr, err)
 	}
 
+	if tcpAddr, ok := ln.Addr().(*net.TCPAddr); ok {
+		s.s3Port.Store(int32(tcpAddr.Port))
+	}
+
 	server := &http.Server{
-		Handler: s.s3Server.Handler(),
+		Handler: s.stripForwardingHeaders(s.s3Server.Handler()),
 	}
 
 	if tlsCert != nil {
+		s.tlsCert.Store(tlsCert)
 		server.TLSConfig = &tls.Config{
-			Certificates: []tls.Certificate{*tlsCert},
-			MinVersion:   tls.VersionTLS12,
+			GetCertificate: s.getTLSCertificate,
+			MinVersion:     tls.VersionTLS12,
+			// Coordinators forwarding requests authenticate with their mesh certificate
+			ClientAuth:            tls.RequestClientCert,
+			VerifyPeerCertificate: s.verifyClientCert,
 		}
 		log.Info().Str("addr", addr).Msg("starting S3 server (HTTPS)")
 		s.wg.Add(1)
@@ -1472,9 +1606,10 @@ func (s *Server) StartNFSServer(addr string, tlsCert *tls.Certificate) error {
 	// Create TLS config if certificate provided
 	var tlsConfig *tls.Config
 	if tlsCert != nil {
+		s.tlsCert.Store(tlsCert)
 		tlsConfig = &tls.Config{
-			Certificates: []tls.Certificate{*tlsCert},
-			MinVersion:   tls.VersionTLS12,
+			GetCertificate: s.getTLSCertificate,
+			MinVersion:     tls.VersionTLS12,
 		}
 	}
 
@@ -1543,7 +1678,7 @@ func (s *Server) withAuth(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		if parts[1] != s.cfg.AuthToken {
+		if !s.tokenAuthorizes(r, parts[1]) {
 			s.jsonError(w, "invalid token", http.StatusUnauthorized)
 			return
 		}
@@ -1574,12 +1709,57 @@ func (s *Server) handleTrace(w http.ResponseWriter, _ *http.Request) {
 	}
 }
 
+// validateServices checks the services a peer advertises for DNS SRV records.
+func validateServices(services []proto.Service) error {
+	if len(services) > proto.MaxServices {
+		return fmt.Errorf("too many services (max %d)", proto.MaxServices)
+	}
+	seen := make(map[string]bool, len(services))
+	for i := range services {
+		if err := services[i].Validate(); err != nil {
+			return err
+		}
+		key := services[i].Name + "/" + services[i].Protocol
+		if seen[key] {
+			return fmt.Errorf("duplicate service %q", key)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// validateRoutes checks the subnet routes a peer advertises and returns them
+// in canonical form, without duplicates. Routes may not overlap the mesh.
+func validateRoutes(routes []string) ([]string, error) {
+	if len(routes) > proto.MaxRoutes {
+		return nil, fmt.Errorf("too many routes (max %d)", proto.MaxRoutes)
+	}
+	_, meshNet, _ := net.ParseCIDR(mesh.CIDR)
+	canonical := make([]string, 0, len(routes))
+	for _, route := range routes {
+		ipNet, err := proto.ParseRoute(route)
+		if err != nil {
+			return nil, err
+		}
+		if ipNet.Contains(meshNet.IP) || meshNet.Contains(ipNet.IP) {
+			return nil, fmt.Errorf("route %q overlaps the mesh network %s", route, mesh.CIDR)
+		}
+		if prefix := ipNet.String(); !slices.Contains(canonical, prefix) {
+			canonical = append(canonical, prefix)
+		}
+	}
+	return canonical, nil
+}
+
 // validateAliases checks if all aliases are valid and available for the requesting peer.
-// Returns an error if any alias conflicts. Must be called with peersMu held.
-func (s *Server) validateAliases(aliases []string, requestingPeer string) error {
+// Aliases can't take the name of a peer, including peers that are offline or
+// haven't joined yet under a name a join key assigns, since certificates for
+// an alias are issued under the mesh domain. Returns an error if any alias
+// conflicts. Must be called with peersMu held.
+func (s *Server) validateAliases(aliases []string, requestingPeer string, peerNames map[string]bool) error {
 	for _, alias := range aliases {
 		// Can't use another peer's name
-		if _, exists := s.peers[alias]; exists && alias != requestingPeer {
+		if _, exists := s.peers[alias]; (exists || peerNames[alias]) && alias != requestingPeer {
 			return fmt.Errorf("alias %q conflicts with existing peer name", alias)
 		}
 
@@ -1591,6 +1771,23 @@ func (s *Server) validateAliases(aliases []string, requestingPeer string) error
 	return nil
 }
 
+// knownPeerNames returns the names of persisted peers and the names join keys
+// assign to the peers that will join with them.
+func (s *Server) knownPeerNames(persistedPeers []*auth.Peer) map[string]bool {
+	names := make(map[string]bool, len(persistedPeers))
+	for _, peer := range persistedPeers {
+		names[peer.Name] = true
+	}
+	s.keysMu.RLock()
+	defer s.keysMu.RUnlock()
+	for _, key := range s.joinKeys {
+		if key.Name != "" && !key.Revoked {
+			names[key.Name] = true
+		}
+	}
+	return names
+}
+
 // reservedPeerNames contains names that cannot be used for peer registration.
 var reservedPeerNames = map[string]bool{
 	"admin":         true,
@@ -1624,6 +1821,41 @@ func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Peers joining with a join key take the name it assigns. Joined peers
+	// renew their token by registering with it.
+	joinKey, joinKeyHash := s.requestJoinKey(r)
+	if joinKey != nil && joinKey.Name != "" {
+		req.Name = joinKey.Name
+	}
+	var claims *JWTClaims
+	if token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); joinKey == nil && token != s.cfg.AuthToken {
+		claims, _ = s.ValidateToken(token)
+	}
+
+	// Revoked peers are cut off whatever token they use, and join keys
+	// are tracked by peer ID
+	requestPeerID := peerIDFromPublicKey(req.PublicKey)
+	if s.isPeerRevoked(requestPeerID) {
+		s.jsonError(w, "peer access has been revoked", http.StatusForbidden)
+		return
+	}
+	if joinKey != nil && requestPeerID == "" {
+		s.jsonError(w, "joining with a join key requires an ED25519 public key", http.StatusBadRequest)
+		return
+	}
+	switch {
+	case claims != nil:
+		// A token only renews the registration of the peer it was issued to
+		if claims.PeerID == "" || claims.PeerID != requestPeerID {
+			s.jsonError(w, "token was issued to another peer", http.StatusUnauthorized)
+			return
+		}
+		req.Name = claims.PeerName
+	case joinKey == nil && s.cfg.Coordinator.RequireJoinKeys && !req.IsCoordinator:
+		s.jsonError(w, "the mesh token only registers coordinators, join with a join key", http.StatusForbidden)
+		return
+	}
+
 	// Reject reserved peer names
 	if isReservedPeerName(req.Name) {
 		s.jsonError(w, fmt.Sprintf("peer name %q is reserved", req.Name), http.StatusForbidden)
@@ -1634,6 +1866,7 @@ func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
 	// during S3 I/O. Peer registration is idempotent, so a slightly stale read
 	// is safe — worst case is a missed name collision caught on next registration.
 	persistedPeers, _ := s.s3SystemStore.LoadPeers(context.Background())
+	peerNames := s.knownPeerNames(persistedPeers)
 
 	s.peersMu.Lock()
 	defer s.peersMu.Unlock()
@@ -1651,6 +1884,11 @@ func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Names another peer holds as an alias are taken too
+	if owner, exists := s.aliasOwner[req.Name]; exists && owner != req.Name {
+		needsRename = true
+	}
+
 	// Also check persisted peers in S3 (critical for security)
 	if !needsRename && persistedPeers != nil {
 		for _, peer := range persistedPeers {
@@ -1676,6 +1914,9 @@ func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
 			if _, exists := s.peers[candidateName]; exists {
 				activeTaken = true
 			}
+			if _, exists := s.aliasOwner[candidateName]; exists {
+				activeTaken = true
+			}
 			persistedTaken := false
 			if !activeTaken && persistedPeers != nil {
 				for _, peer := range persistedPeers {
@@ -1698,11 +1939,36 @@ func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
 
 	// Validate aliases first (before any state changes)
 	if len(req.Aliases) > 0 {
-		if err := s.validateAliases(req.Aliases, req.Name); err != nil {
+		if err := s.validateAliases(req.Aliases, req.Name, peerNames); err != nil {
 			s.jsonError(w, err.Error(), http.StatusConflict)
 			return
 		}
 	}
+	if err := validateServices(req.Services); err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	routes, err := validateRoutes(req.Routes)
+	if err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Use up the join key once the request is known to be valid
+	var joinedWithKey bool
+	if joinKey != nil {
+		if joinedWithKey, err = s.claimJoinKey(joinKeyHash, requestPeerID); err != nil {
+			s.jsonError(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if joinedWithKey {
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.saveJoinKeys(context.Background())
+			}()
+		}
+	}
 
 	// Allocate IP deterministically based on peer name
 	// This ensures the same peer always gets the same IP
@@ -1772,7 +2038,18 @@ func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
 		AllowsExitTraffic: req.AllowsExitTraffic,
 		ExitPeer:          req.ExitPeer,
 		IsCoordinator:     req.IsCoordinator,
+		Routes:            s.approvedRoutes(req.Name, routes),
+	}
+	// Peers joining with a join key get its tags, kept once they rejoin
+	// with the mesh token
+	if joinedWithKey && s.addPeerTags(req.Name, joinKey.Tags) {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.savePeerTags(context.Background())
+		}()
 	}
+	peer.Tags = slices.Clone(s.peerTags[req.Name])
 
 	// Preserve registeredAt for existing peers
 	registeredAt := time.Now()
@@ -1805,10 +2082,19 @@ func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// The IPv6 address is derived from the peer ID, falling back to the name
+	if peerID != "" {
+		peer.MeshIPv6 = mesh.IPv6Address(peerID)
+	} else {
+		peer.MeshIPv6 = mesh.IPv6Address(req.Name)
+	}
+
 	info := &peerInfo{
 		peer:          peer,
 		registeredAt:  registeredAt,
 		aliases:       req.Aliases,
+		services:      req.Services,
+		routes:        routes,
 		peerID:        peerID,
 		hasMonitoring: req.HasMonitoring,
 	}
@@ -1901,6 +2187,21 @@ func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
+		// Add peers joining with a join key to the groups it assigns
+		if joinedWithKey && len(joinKey.Groups) > 0 {
+			for _, group := range joinKey.Groups {
+				if err := s.s3Authorizer.Groups.AddMember(group, peerID); err != nil {
+					log.Warn().Err(err).Str("peer", req.Name).Str("group", group).Msg("failed to add peer to join key group")
+				}
+			}
+			if err := s.s3SystemStore.SaveGroups(context.Background(), s.s3Authorizer.Groups.List()); err != nil {
+				log.Error().Err(err).Msg("failed to persist groups after peer registration")
+			}
+		}
+
+		// Tags make the peer a member of the groups listing them
+		s.s3Authorizer.Groups.SetPeerTags(peerID, peer.Tags)
+
 		// Create or update peer record in peer store
 		s.updatePeerRecord(peerID, req.Name, req.PublicKey, isNewPeer)
 
@@ -1921,7 +2222,7 @@ func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Generate JWT token for relay authentication
-	token, err := s.GenerateToken(req.Name, meshIP)
+	token, err := s.GenerateToken(req.Name, peerID, meshIP)
 	if err != nil {
 		s.jsonError(w, "failed to generate token: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -1933,6 +2234,9 @@ func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
 	if len(req.Aliases) > 0 {
 		logEvent = logEvent.Strs("aliases", req.Aliases)
 	}
+	if joinKey != nil {
+		logEvent = logEvent.Str("join_key", joinKey.ID)
+	}
 	logEvent.Msg("peer registered")
 
 	// Trigger IP geolocation only for new peers or when IP has changed (if locations enabled)
@@ -1959,6 +2263,8 @@ func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
 	resp := proto.RegisterResponse{
 		MeshIP:        meshIP,
 		MeshCIDR:      mesh.CIDR,
+		MeshIPv6:      peer.MeshIPv6,
+		MeshCIDRv6:    mesh.IPv6CIDR,
 		Domain:        mesh.DomainSuffix,
 		Token:         token,
 		CoordMeshIPs:  coordIPs, // All coordinator IPs for DNS round-robin
@@ -1977,6 +2283,11 @@ func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
 		} else {
 			resp.TLSCert = string(certPEM)
 			resp.TLSKey = string(keyPEM)
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.saveIssuedCerts(context.Background())
+			}()
 		}
 	}
 
@@ -1995,7 +2306,11 @@ func (s *Server) handlePeers(w http.ResponseWriter, r *http.Request) {
 
 	peers := make([]proto.Peer, 0, len(s.peers))
 	for _, info := range s.peers {
-		peers = append(peers, *info.peer)
+		peer := *info.peer
+		if s.s3Authorizer != nil {
+			peer.Groups = s.s3Authorizer.Groups.GetGroupsForPeer(info.peerID)
+		}
+		peers = append(peers, peer)
 	}
 
 	resp := proto.PeerListResponse{Peers: peers}
@@ -2003,6 +2318,47 @@ func (s *Server) handlePeers(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(resp)
 }
 
+// removePeer removes a registered peer, releasing its mesh IP, DNS names and
+// UDP endpoint. Returns false if the peer is not registered.
+func (s *Server) removePeer(name string) bool {
+	s.peersMu.Lock()
+	info, exists := s.peers[name]
+	if exists {
+		s.ipAlloc.release(info.peer.MeshIP)
+		// Clean up aliases
+		for _, alias := range info.aliases {
+			delete(s.aliasOwner, alias)
+			delete(s.dnsCache, alias)
+		}
+		delete(s.peers, name)
+		delete(s.dnsCache, name)
+
+		// Remove from coordinators index
+		wasCoordinator := info.peer.IsCoordinator
+		if wasCoordinator {
+			delete(s.coordinators, name)
+		}
+
+		// Remove from replicator if this was a coordinator
+		if wasCoordinator && s.replicator != nil {
+			s.replicator.RemovePeer(info.peer.MeshIP)
+			log.Debug().Str("peer", name).Str("mesh_ip", info.peer.MeshIP).Msg("removed coordinator from replication targets")
+		}
+
+		// Broadcast updated coordinator list if a coordinator was removed
+		if wasCoordinator {
+			go s.broadcastCoordinatorList()
+		}
+	}
+	s.peersMu.Unlock()
+
+	// Also remove UDP endpoint
+	if s.holePunch != nil {
+		s.holePunch.RemoveEndpoint(name)
+	}
+	return exists
+}
+
 func (s *Server) handlePeerByName(w http.ResponseWriter, r *http.Request) {
 	// Extract peer name from path
 	name := strings.TrimPrefix(r.URL.Path, "/api/v1/peers/")
@@ -2026,43 +2382,7 @@ func (s *Server) handlePeerByName(w http.ResponseWriter, r *http.Request) {
 		_ = json.NewEncoder(w).Encode(info.peer)
 
 	case http.MethodDelete:
-		s.peersMu.Lock()
-		info, exists := s.peers[name]
-		if exists {
-			s.ipAlloc.release(info.peer.MeshIP)
-			// Clean up aliases
-			for _, alias := range info.aliases {
-				delete(s.aliasOwner, alias)
-				delete(s.dnsCache, alias)
-			}
-			delete(s.peers, name)
-			delete(s.dnsCache, name)
-
-			// Remove from coordinators index
-			wasCoordinator := info.peer.IsCoordinator
-			if wasCoordinator {
-				delete(s.coordinators, name)
-			}
-
-			// Remove from replicator if this was a coordinator
-			if wasCoordinator && s.replicator != nil {
-				s.replicator.RemovePeer(info.peer.MeshIP)
-				log.Debug().Str("peer", name).Str("mesh_ip", info.peer.MeshIP).Msg("removed coordinator from replication targets")
-			}
-
-			// Broadcast updated coordinator list if a coordinator was removed
-			if wasCoordinator {
-				go s.broadcastCoordinatorList()
-			}
-		}
-		s.peersMu.Unlock()
-
-		// Also remove UDP endpoint
-		if s.holePunch != nil {
-			s.holePunch.RemoveEndpoint(name)
-		}
-
-		if !exists {
+		if !s.removePeer(name) {
 			s.jsonError(w, "peer not found", http.StatusNotFound)
 			return
 		}
@@ -2085,15 +2405,31 @@ func (s *Server) handleDNS(w http.ResponseWriter, r *http.Request) {
 	s.peersMu.RLock()
 	defer s.peersMu.RUnlock()
 
+	clientTags := s.clientTags()
 	records := make([]proto.DNSRecord, 0, len(s.dnsCache))
 	for hostname, ip := range s.dnsCache {
-		records = append(records, proto.DNSRecord{
+		record := proto.DNSRecord{
 			Hostname: hostname,
 			MeshIP:   ip,
-		})
+		}
+		// Aliases only resolve to the IPs; peer names also carry metadata
+		if owner, ok := s.peers[s.aliasOwner[hostname]]; ok {
+			record.MeshIPv6 = owner.peer.MeshIPv6
+		}
+		if info, ok := s.peers[hostname]; ok {
+			record.MeshIPv6 = info.peer.MeshIPv6
+			record.PeerID = info.peerID
+			record.Version = info.peer.Version
+			record.Services = info.services
+			record.Tags = info.peer.Tags
+		} else if _, isAlias := s.aliasOwner[hostname]; !isAlias {
+			// WireGuard clients
+			record.Tags = clientTags[ip]
+		}
+		records = append(records, record)
 	}
 
-	resp := proto.DNSUpdateNotification{Records: records}
+	resp := proto.DNSUpdateNotification{Records: records, Zone: slices.Clone(s.dnsZone)}
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(resp)
 }
@@ -2475,12 +2811,15 @@ func (s *Server) StartAdminServer(addr string, tlsCert *tls.Certificate) error {
 	}
 
 	if tlsCert != nil {
+		s.tlsCert.Store(tlsCert)
 		s.adminServer.TLSConfig = &tls.Config{
-			Certificates: []tls.Certificate{*tlsCert},
-			MinVersion:   tls.VersionTLS12,
+			GetCertificate: s.getTLSCertificate,
+			MinVersion:     tls.VersionTLS12,
 			// Request client certs for user identification, but don't require them
 			// This allows getRequestOwner() to identify users for operations like share creation
 			ClientAuth: tls.RequestClientCert,
+			// Certificates of revoked peers are rejected
+			VerifyPeerCertificate: s.verifyClientCert,
 		}
 		log.Info().Str("addr", addr).Msg("starting admin server (HTTPS)")
 		s.wg.Add(1)
@@ -6,6 +6,7 @@ import (
 	"crypto/ed25519"
 	"crypto/rand"
 	"encoding/json"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"runtime"
@@ -18,6 +19,7 @@ import (
 	"github.com/tunnelmesh/tunnelmesh/internal/auth"
 	"github.com/tunnelmesh/tunnelmesh/internal/config"
 	s3 "github.com/tunnelmesh/tunnelmesh/internal/coord/s3"
+	"github.com/tunnelmesh/tunnelmesh/internal/mesh"
 	"github.com/tunnelmesh/tunnelmesh/internal/routing"
 	"github.com/tunnelmesh/tunnelmesh/pkg/bytesize"
 	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
@@ -36,7 +38,8 @@ func newTestConfig(t *testing.T) *config.PeerConfig {
 			MTU: 1400,
 		},
 		Coordinator: config.CoordinatorConfig{
-			Listen: ":0",
+			Listen:  ":0",
+			DataDir: tmpDir,
 			S3: config.S3Config{
 				DataDir: tmpDir,
 				MaxSize: bytesize.Size(1 << 30), // 1Gi for tests
@@ -245,6 +248,37 @@ func TestServer_Peers(t *testing.T) {
 	assert.Equal(t, "node1", resp.Peers[0].Name)
 }
 
+func TestServer_PeersIncludeGroups(t *testing.T) {
+	srv := newTestServerWithS3(t)
+	require.NotNil(t, srv.s3Authorizer)
+
+	body, _ := json.Marshal(proto.RegisterRequest{Name: "node1", PublicKey: "SHA256:abc123", SSHPort: 2222})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/register", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-token")
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	srv.peersMu.RLock()
+	peerID := srv.peers["node1"].peerID
+	srv.peersMu.RUnlock()
+	_, err := srv.s3Authorizer.Groups.Create("ops", "")
+	require.NoError(t, err)
+	require.NoError(t, srv.s3Authorizer.Groups.AddMember("ops", peerID))
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/peers", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp proto.PeerListResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Peers, 1)
+	assert.Contains(t, resp.Peers[0].Groups, "ops")
+}
+
 // Note: TestServer_Heartbeat removed - HTTP heartbeat endpoint replaced by WebSocket
 // See relay_test.go for WebSocket heartbeat tests
 
@@ -755,6 +789,105 @@ func TestServer_Register_WithAliases(t *testing.T) {
 	assert.True(t, foundAPI, "api alias should be in DNS")
 }
 
+func TestServer_Register_WithServices(t *testing.T) {
+	srv := newTestServer(t)
+
+	register := func(services []proto.Service) *httptest.ResponseRecorder {
+		regReq := proto.RegisterRequest{
+			Name:      "web",
+			PublicKey: "SHA256:abc123",
+			SSHPort:   2222,
+			Version:   "v1.2.3",
+			Aliases:   []string{"www"},
+			Services:  services,
+		}
+		body, _ := json.Marshal(regReq)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/register", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-token")
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+
+	w := register([]proto.Service{{Name: "http", Protocol: "sctp", Port: 80}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = register([]proto.Service{{Name: "http", Protocol: "tcp", Port: 80}, {Name: "http", Protocol: "tcp", Port: 8080}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	services := []proto.Service{{Name: "http", Protocol: "tcp", Port: 8080}}
+	w = register(services)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/dns", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	var dnsResp proto.DNSUpdateNotification
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &dnsResp))
+	require.Len(t, dnsResp.Records, 2)
+	for _, record := range dnsResp.Records {
+		switch record.Hostname {
+		case "web":
+			assert.Equal(t, "v1.2.3", record.Version)
+			assert.Equal(t, services, record.Services)
+		case "www":
+			// Aliases carry no peer metadata
+			assert.Empty(t, record.Version)
+			assert.Empty(t, record.Services)
+		default:
+			t.Errorf("unexpected record %q", record.Hostname)
+		}
+	}
+}
+
+func TestServer_Register_IPv6Address(t *testing.T) {
+	srv := newTestServer(t)
+	pubKey, edPub := generateTestSSHPubKey(t)
+
+	regReq := proto.RegisterRequest{
+		Name:      "web",
+		PublicKey: pubKey,
+		SSHPort:   2222,
+		Aliases:   []string{"www"},
+	}
+	body, _ := json.Marshal(regReq)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/register", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-token")
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp proto.RegisterResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	// The interface ID is the peer ID
+	assert.Equal(t, auth.ComputePeerID(edPub), resp.PeerID)
+	assert.Equal(t, mesh.IPv6Address(resp.PeerID), resp.MeshIPv6)
+	assert.Equal(t, mesh.IPv6CIDR, resp.MeshCIDRv6)
+	_, prefix, _ := net.ParseCIDR(mesh.IPv6CIDR)
+	assert.True(t, prefix.Contains(net.ParseIP(resp.MeshIPv6)))
+
+	// Peer names and their aliases resolve to it
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/dns", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	var dnsResp proto.DNSUpdateNotification
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &dnsResp))
+	require.Len(t, dnsResp.Records, 2)
+	for _, record := range dnsResp.Records {
+		assert.Equal(t, resp.MeshIPv6, record.MeshIPv6, record.Hostname)
+	}
+
+	srv.peersMu.RLock()
+	assert.Equal(t, resp.MeshIPv6, srv.peers["web"].peer.MeshIPv6)
+	srv.peersMu.RUnlock()
+}
+
 func TestServer_Register_AliasConflictWithPeerName(t *testing.T) {
 	srv := newTestServer(t)
 
@@ -1002,6 +1135,12 @@ func TestServer_ValidateAliases(t *testing.T) {
 			requestingPeer: "newpeer",
 			wantErr:        true,
 		},
+		{
+			name:           "conflict with offline peer name",
+			aliases:        []string{"offlinepeer"},
+			requestingPeer: "newpeer",
+			wantErr:        true,
+		},
 		{
 			name:           "conflict with other peer's alias",
 			aliases:        []string{"taken-alias"},
@@ -1025,7 +1164,7 @@ func TestServer_ValidateAliases(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			srv.peersMu.Lock()
-			err := srv.validateAliases(tt.aliases, tt.requestingPeer)
+			err := srv.validateAliases(tt.aliases, tt.requestingPeer, map[string]bool{"offlinepeer": true})
 			srv.peersMu.Unlock()
 
 			if tt.wantErr {
@@ -1049,7 +1188,8 @@ func TestServer_S3UserRecoveryOnRestart(t *testing.T) {
 			MTU: 1400,
 		},
 		Coordinator: config.CoordinatorConfig{
-			Listen: ":0",
+			Listen:  ":0",
+			DataDir: tempDir,
 			S3: config.S3Config{
 				DataDir: tempDir + "/s3",
 				MaxSize: 1 * 1024 * 1024 * 1024, // 1Gi - Required for quota enforcement
@@ -1125,6 +1265,7 @@ func newTestServerWithS3(t *testing.T) *Server {
 		Coordinator: config.CoordinatorConfig{
 			Enabled: true,
 			Listen:  ":0",
+			DataDir: tempDir,
 			S3: config.S3Config{
 				DataDir: tempDir + "/s3",
 				MaxSize: 1 * 1024 * 1024 * 1024, // 1Gi - Required for quota enforcement
@@ -1339,7 +1480,8 @@ func TestServer_FilterRulesRecoveryFiltersExpired(t *testing.T) {
 			MTU: 1400,
 		},
 		Coordinator: config.CoordinatorConfig{
-			Listen: ":0",
+			Listen:  ":0",
+			DataDir: tempDir,
 			S3: config.S3Config{
 				DataDir: tempDir + "/s3",
 				MaxSize: 1 * 1024 * 1024 * 1024,
@@ -6,12 +6,14 @@ import (
 	"sync"
 
 	"github.com/rs/zerolog/log"
+	"github.com/tunnelmesh/tunnelmesh/internal/coord/s3"
 )
 
 // sseClient represents a connected SSE client.
 type sseClient struct {
 	events chan string
 	done   chan struct{}
+	userID string // Peer ID of the client, empty if unknown
 }
 
 // sseHub manages SSE client connections and broadcasts.
@@ -48,10 +50,19 @@ func (h *sseHub) unregister(client *sseClient) {
 
 // broadcast sends an event to all connected clients.
 func (h *sseHub) broadcast(event string) {
+	h.broadcastIf(event, nil)
+}
+
+// broadcastIf sends an event to the connected clients allow accepts, or to
+// all of them if allow is nil.
+func (h *sseHub) broadcastIf(event string, allow func(*sseClient) bool) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
 	for client := range h.clients {
+		if allow != nil && !allow(client) {
+			continue
+		}
 		select {
 		case client.events <- event:
 		default:
@@ -87,6 +98,7 @@ func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request) {
 	client := &sseClient{
 		events: make(chan string, 10), // Buffer up to 10 events
 		done:   make(chan struct{}),
+		userID: s.getRequestOwner(r),
 	}
 
 	// Register client
@@ -121,3 +133,15 @@ func (s *Server) notifyHeartbeat(peerName string) {
 	event := fmt.Sprintf("event: heartbeat\ndata: {\"peer\":\"%s\"}", peerName)
 	s.sseHub.broadcast(event)
 }
+
+// notifyS3Event sends an S3 bucket event (an s3.EventMessage JSON payload)
+// to the SSE clients allowed to list the object it occurred on.
+func (s *Server) notifyS3Event(event s3.BucketEvent, payload []byte) {
+	if s.sseHub == nil || s.s3Authorizer == nil {
+		return
+	}
+
+	s.sseHub.broadcastIf("event: s3\ndata: "+string(payload), func(c *sseClient) bool {
+		return c.userID != "" && s.s3Authorizer.Authorize(c.userID, "list", "objects", event.Bucket, event.Key)
+	})
+}
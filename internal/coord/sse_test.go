@@ -7,6 +7,9 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/tunnelmesh/tunnelmesh/internal/auth"
+	"github.com/tunnelmesh/tunnelmesh/internal/coord/s3"
 )
 
 func TestSSEHub_RegisterUnregister(t *testing.T) {
@@ -216,3 +219,42 @@ func TestSSEEventFormat(t *testing.T) {
 
 	hub.unregister(client)
 }
+
+func TestNotifyS3Event_OnlyToClientsWithAccess(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.Coordinator.Enabled = true
+
+	srv, err := NewServer(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	srv.s3Authorizer.Bindings.Add(auth.NewRoleBinding("alice-id", auth.RoleBucketRead, "test-bucket"))
+
+	hub := newSSEHub()
+	srv.sseHub = hub
+	alice := &sseClient{events: make(chan string, 10), done: make(chan struct{}), userID: "alice-id"}
+	bob := &sseClient{events: make(chan string, 10), done: make(chan struct{}), userID: "bob-id"}
+	anonymous := &sseClient{events: make(chan string, 10), done: make(chan struct{})}
+	for _, c := range []*sseClient{alice, bob, anonymous} {
+		hub.register(c)
+		defer hub.unregister(c)
+	}
+
+	srv.notifyS3Event(s3.BucketEvent{Name: s3.EventObjectCreatedPut, Bucket: "test-bucket", Key: "a.txt"}, []byte(`{}`))
+
+	select {
+	case event := <-alice.events:
+		if !strings.HasPrefix(event, "event: s3\n") {
+			t.Errorf("unexpected event: %s", event)
+		}
+	case <-time.After(time.Second):
+		t.Error("client with access did not receive the event")
+	}
+	for name, c := range map[string]*sseClient{"bob": bob, "anonymous": anonymous} {
+		select {
+		case event := <-c.events:
+			t.Errorf("%s received %q without access to the bucket", name, event)
+		default:
+		}
+	}
+}
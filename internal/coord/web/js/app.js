@@ -12,7 +12,7 @@ const { createModalController } = TM.modal;
 // Panel refresh lists - defines which panels to refresh for each tab
 const _PANELS_MESH_TAB = ['peers', 'wg-clients', 'logs', 'alerts', 'filter']; // Mesh tab panels (visualizer/map loaded via fetchData)
 const PANELS_APP_TAB = ['s3', 'shares', 'docker']; // App tab panels
-const PANELS_DATA_TAB = ['peers-mgmt', 'groups', 'bindings', 'dns']; // Data tab panels
+const PANELS_DATA_TAB = ['peers-mgmt', 'keys', 'groups', 'bindings', 'dns']; // Data tab panels
 
 // Toggle collapsible section
 function toggleSection(header) {
@@ -66,9 +66,12 @@ const state = {
     currentPeers: [], // Store current peers data for pagination
     currentDnsRecords: [], // Store current DNS records for pagination
     currentPeersMgmt: [],
+    currentJoinKeys: [],
+    currentRevokedPeers: [],
     currentGroups: [],
     currentShares: [],
     currentBindings: [],
+    currentFilterRules: [], // Filter rules as rendered, for removal by index
     // Alerts state
     alertsEnabled: false,
     peerAlerts: {}, // { peerName: { warning: count, critical: count, page: count } }
@@ -471,6 +474,25 @@ const filterModal = createModalController('filter-modal', {
     },
 });
 
+// Exit peer in use, with the failover order and policy exits in the tooltip
+function formatExitVia(peer) {
+    const exitPeers = peer.exit_peers || [];
+    const policies = Object.entries(peer.exit_routes || {});
+    if (!peer.exit_node && !exitPeers.length && !policies.length) return '';
+
+    const details = [];
+    if (exitPeers.length > 1) details.push(`failover: ${exitPeers.join(' → ')}`);
+    for (const [policy, exit] of policies) details.push(`${policy}: ${exit || 'none healthy'}`);
+    const title = details.length ? ` title="${escapeHtml(details.join('\n')).replace(/"/g, '&quot;')}"` : '';
+
+    if (!peer.exit_node && exitPeers.length) {
+        return `<span class="exit-via exit-down"${title}>no exit</span>`;
+    }
+    const label = peer.exit_node ? `via ${escapeHtml(peer.exit_node)}` : 'via policy';
+    const more = policies.length ? ` +${policies.length}` : '';
+    return `<span class="exit-via"${title}>${label}${more}</span>`;
+}
+
 function renderPeersTable() {
     const peers = state.currentPeers;
     const uiState = peersPagination.getUIState();
@@ -502,13 +524,16 @@ function renderPeersTable() {
                 ? `<span class="status-badge alert-icon" title="${alertSeverity}">⚠</span>`
                 : '';
             const exitBadge = peer.allows_exit_traffic ? '<span class="status-badge exit">EXIT</span>' : '';
-            const exitVia = peer.exit_node ? `<span class="exit-via">via ${escapeHtml(peer.exit_node)}</span>` : '';
+            const exitVia = formatExitVia(peer);
+            const tags = (peer.tags || [])
+                .map((tag) => `<span class="peer-tag">${escapeHtml(tag.replace(/^tag:/, ''))}</span>`)
+                .join('');
             // Tunnel count from connections map
             const tunnelCount = peer.connections ? Object.keys(peer.connections).length : 0;
             const tunnelSuffix = tunnelCount > 0 ? ` <span class="tunnel-count">(${tunnelCount})</span>` : '';
             return `
         <tr>
-            <td><strong>${peerNameEscaped}</strong>${alertBadge}${exitBadge}${exitVia}</td>
+            <td><strong>${peerNameEscaped}</strong>${alertBadge}${exitBadge}${exitVia}${tags}</td>
             <td><code>${peer.mesh_ip}</code>${tunnelSuffix}</td>
             <td>${formatLatency(peer.coordinator_rtt_ms)}</td>
             <td class="sparkline-cell">
@@ -1189,17 +1214,18 @@ function renderFilterRules(data) {
         return a.port - b.port;
     });
 
+    state.currentFilterRules = sortedRules;
     dom.filterRulesBody.innerHTML = sortedRules
-        .map((rule) => {
-            const sourcePeerDisplay = rule.source_peer ? rule.source_peer : '<span class="text-muted">Any</span>';
-            const sourcePeerEscaped = rule.source_peer || '';
+        .map((rule, index) => {
+            const sourceDesc = formatFilterSource(rule);
+            const sourcePeerDisplay = sourceDesc ? escapeHtml(sourceDesc) : '<span class="text-muted">Any</span>';
             const expiresDisplay =
                 rule.expires === 0
                     ? '<span class="text-muted">Permanent</span>'
                     : TM.format.formatExpiry(new Date(rule.expires * 1000));
             return `
         <tr>
-            <td>${rule.port}</td>
+            <td>${formatFilterPorts(rule)}</td>
             <td>${rule.protocol.toUpperCase()}</td>
             <td><span class="action-badge ${rule.action}">${rule.action}</span></td>
             <td>${sourcePeerDisplay}</td>
@@ -1208,7 +1234,7 @@ function renderFilterRules(data) {
             <td>
                 ${
                     rule.source === 'temporary'
-                        ? `<button class="btn-danger" onclick="removeFilterRule('${data.peer}', ${rule.port}, '${rule.protocol}', '${sourcePeerEscaped}')">Remove</button>`
+                        ? `<button class="btn-danger" onclick="removeFilterRule('${data.peer}', ${index})">Remove</button>`
                         : '<span class="text-muted">-</span>'
                 }
             </td>
@@ -1218,6 +1244,26 @@ function renderFilterRules(data) {
         .join('');
 }
 
+// Format the port column of a filter rule: a port or range for TCP/UDP,
+// the message type for ICMP
+function formatFilterPorts(rule) {
+    if (rule.protocol === 'icmp' || rule.protocol === 'icmpv6') {
+        return rule.icmp_type ? `type ${rule.icmp_type}` : '*';
+    }
+    return rule.port_end > rule.port ? `${rule.port}-${rule.port_end}` : `${rule.port}`;
+}
+
+// Format the source and destination selectors of a filter rule ('' = any)
+function formatFilterSource(rule) {
+    const parts = [];
+    if (rule.source_peer) parts.push(rule.source_peer);
+    if (rule.source_cidr) parts.push(rule.source_cidr);
+    if (rule.source_group) parts.push(`group:${rule.source_group}`);
+    let desc = parts.join(', ');
+    if (rule.dest_group) desc += `${desc ? ' ' : ''}(to group:${rule.dest_group})`;
+    return desc;
+}
+
 // Populate the source peer dropdown in the filter modal
 function populateSourcePeerSelect() {
     const select = document.getElementById('filter-rule-source-peer');
@@ -1331,10 +1377,13 @@ async function addFilterRule() {
 }
 window.addFilterRule = addFilterRule;
 
-// Remove a filter rule
-async function removeFilterRule(peerName, port, protocol, sourcePeer) {
-    const sourceDesc = sourcePeer ? ` from ${sourcePeer}` : '';
-    if (!confirm(`Remove filter rule for port ${port}/${protocol.toUpperCase()}${sourceDesc}?`)) {
+// Remove a filter rule, by its index in the rendered table
+async function removeFilterRule(peerName, index) {
+    const rule = state.currentFilterRules[index];
+    if (!rule) return;
+    const source = formatFilterSource(rule);
+    const sourceDesc = source ? ` from ${source}` : '';
+    if (!confirm(`Remove filter rule for ${formatFilterPorts(rule)}/${rule.protocol.toUpperCase()}${sourceDesc}?`)) {
         return;
     }
 
@@ -1342,7 +1391,17 @@ async function removeFilterRule(peerName, port, protocol, sourcePeer) {
         const resp = await fetch('/api/filter/rules', {
             method: 'DELETE',
             headers: { 'Content-Type': 'application/json' },
-            body: JSON.stringify({ peer: peerName, port, protocol, source_peer: sourcePeer || '' }),
+            body: JSON.stringify({
+                peer: peerName,
+                port: rule.port,
+                port_end: rule.port_end || 0,
+                protocol: rule.protocol,
+                icmp_type: rule.icmp_type || 0,
+                source_peer: rule.source_peer || '',
+                source_cidr: rule.source_cidr || '',
+                source_group: rule.source_group || '',
+                dest_group: rule.dest_group || '',
+            }),
         });
 
         if (!resp.ok) {
@@ -1528,6 +1587,15 @@ function registerBuiltinPanels() {
             category: 'admin',
             sortOrder: 30,
         },
+        {
+            id: 'keys',
+            sectionId: 'keys-section',
+            tab: 'data',
+            title: 'Join Keys',
+            category: 'admin',
+            hasActionButton: true,
+            sortOrder: 35,
+        },
         {
             id: 'groups',
             sectionId: 'groups-section',
@@ -1824,6 +1892,9 @@ function renderPeersMgmtTable() {
             <td>${p.last_seen ? formatLastSeen(p.last_seen) : '-'}</td>
             <td>${p.is_service ? 'Never' : p.expires_at ? formatExpiry(p.expires_at) : '-'}</td>
             <td><span class="status-badge ${p.expired ? 'expired' : 'active'}">${p.expired ? 'Expired' : 'Active'}</span></td>
+            <td>
+                ${p.is_service ? '-' : `<button class="btn-small btn-danger" onclick="revokePeer('${escapeHtml(p.id)}', '${escapeHtml(p.name)}')">Revoke</button>`}
+            </td>
         </tr>
     `,
         )
@@ -1951,6 +2022,203 @@ async function deleteGroup(name) {
 }
 window.deleteGroup = deleteGroup;
 
+async function fetchJoinKeys() {
+    try {
+        const [keysResp, revokedResp] = await Promise.all([fetch('/api/keys'), fetch('/api/revoked-peers')]);
+        if (keysResp.ok) {
+            state.currentJoinKeys = (await keysResp.json()) || [];
+        }
+        if (revokedResp.ok) {
+            state.currentRevokedPeers = (await revokedResp.json()) || [];
+        }
+        renderJoinKeysTable();
+    } catch (err) {
+        console.error('Failed to fetch join keys:', err);
+    }
+}
+
+function joinKeyStatus(k) {
+    if (k.revoked) return 'revoked';
+    if (k.expires_at && new Date(k.expires_at) <= new Date()) return 'expired';
+    if (!k.reusable && k.peer_ids && k.peer_ids.length > 0) return 'used';
+    return 'active';
+}
+
+function renderJoinKeysTable() {
+    const tbody = document.getElementById('keys-body');
+    const noKeys = document.getElementById('no-keys');
+    const keys = state.currentJoinKeys;
+
+    if (!keys || keys.length === 0) {
+        tbody.innerHTML = '';
+        noKeys.style.display = 'block';
+    } else {
+        noKeys.style.display = 'none';
+        tbody.innerHTML = keys
+            .map((k) => {
+                const status = joinKeyStatus(k);
+                return `
+        <tr>
+            <td><code>${escapeHtml(k.id)}</code></td>
+            <td>${k.reusable ? 'reusable' : 'single-use'}</td>
+            <td>${status}</td>
+            <td>${k.peer_ids ? k.peer_ids.length : 0}</td>
+            <td>${escapeHtml(k.name || '-')}</td>
+            <td>${k.groups && k.groups.length > 0 ? k.groups.map((g) => escapeHtml(g)).join(', ') : '-'}</td>
+            <td>${k.tags && k.tags.length > 0 ? k.tags.map((t) => escapeHtml(t)).join(', ') : '-'}</td>
+            <td>${k.expires_at ? formatExpiry(k.expires_at) : 'Never'}</td>
+            <td>${escapeHtml(k.description || '-')}</td>
+            <td>
+                ${k.revoked ? '-' : `<button class="btn-small btn-danger" onclick="revokeJoinKey('${escapeHtml(k.id)}')">Revoke</button>`}
+            </td>
+        </tr>
+    `;
+            })
+            .join('');
+    }
+
+    const revokedTable = document.getElementById('revoked-peers');
+    const revoked = state.currentRevokedPeers;
+    if (!revoked || revoked.length === 0) {
+        revokedTable.style.display = 'none';
+        return;
+    }
+    revokedTable.style.display = '';
+    document.getElementById('revoked-peers-body').innerHTML = revoked
+        .map(
+            (rp) => `
+        <tr>
+            <td><strong>${escapeHtml(rp.name)}</strong></td>
+            <td><code>${escapeHtml(rp.peer_id)}</code></td>
+            <td>${TM.format.formatRelativeTime(rp.revoked_at)}</td>
+            <td>
+                <button class="btn-small" onclick="restorePeer('${escapeHtml(rp.peer_id)}', '${escapeHtml(rp.name)}')">Restore</button>
+            </td>
+        </tr>
+    `,
+        )
+        .join('');
+}
+
+function openKeyModal() {
+    document.getElementById('key-modal').style.display = 'flex';
+    document.getElementById('key-create-form').style.display = 'block';
+    document.getElementById('key-created-display').style.display = 'none';
+    document.getElementById('key-description').value = '';
+    document.getElementById('key-ttl').value = '86400';
+    document.getElementById('key-reusable').checked = false;
+    document.getElementById('key-name').value = '';
+    document.getElementById('key-groups').value = '';
+    document.getElementById('key-tags').value = '';
+    document.getElementById('key-description').focus();
+}
+window.openKeyModal = openKeyModal;
+
+function closeKeyModal() {
+    document.getElementById('key-modal').style.display = 'none';
+    document.getElementById('key-created-text').value = '';
+}
+window.closeKeyModal = closeKeyModal;
+
+function splitList(value) {
+    return value
+        .split(',')
+        .map((v) => v.trim())
+        .filter((v) => v);
+}
+
+async function createJoinKey() {
+    const body = {
+        description: document.getElementById('key-description').value.trim(),
+        ttl: parseInt(document.getElementById('key-ttl').value, 10),
+        reusable: document.getElementById('key-reusable').checked,
+        name: document.getElementById('key-name').value.trim(),
+        groups: splitList(document.getElementById('key-groups').value),
+        tags: splitList(document.getElementById('key-tags').value),
+    };
+
+    try {
+        const resp = await fetch('/api/keys', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(body),
+        });
+
+        if (resp.ok) {
+            const key = await resp.json();
+            showToast(`Join key ${key.id} created`, 'success');
+            document.getElementById('key-create-form').style.display = 'none';
+            document.getElementById('key-created-display').style.display = 'block';
+            document.getElementById('key-created-text').value = key.key;
+            TM.refresh.trigger('keys');
+        } else {
+            const data = await resp.json();
+            showToast(data.error || 'Failed to create join key', 'error');
+        }
+    } catch (err) {
+        showToast(`Failed to create join key: ${err.message}`, 'error');
+    }
+}
+window.createJoinKey = createJoinKey;
+
+async function revokeJoinKey(id) {
+    if (!confirm(`Revoke join key ${id}? Peers that already joined keep their access.`)) return;
+
+    try {
+        const resp = await fetch(`/api/keys/${encodeURIComponent(id)}`, { method: 'DELETE' });
+        if (resp.ok) {
+            showToast(`Join key ${id} revoked`, 'success');
+            TM.refresh.trigger('keys');
+        } else {
+            const data = await resp.json();
+            showToast(data.error || 'Failed to revoke join key', 'error');
+        }
+    } catch (err) {
+        showToast(`Failed to revoke join key: ${err.message}`, 'error');
+    }
+}
+window.revokeJoinKey = revokeJoinKey;
+
+async function revokePeer(peerID, name) {
+    if (!confirm(`Revoke peer "${name}"? It is removed from the mesh and cannot rejoin until restored.`)) return;
+
+    try {
+        const resp = await fetch('/api/revoked-peers', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ peer: peerID }),
+        });
+        if (resp.ok) {
+            showToast(`Peer "${name}" revoked`, 'success');
+            TM.refresh.trigger('keys');
+        } else {
+            const data = await resp.json();
+            showToast(data.error || 'Failed to revoke peer', 'error');
+        }
+    } catch (err) {
+        showToast(`Failed to revoke peer: ${err.message}`, 'error');
+    }
+}
+window.revokePeer = revokePeer;
+
+async function restorePeer(peerID, name) {
+    if (!confirm(`Restore peer "${name}"?`)) return;
+
+    try {
+        const resp = await fetch(`/api/revoked-peers/${encodeURIComponent(peerID)}`, { method: 'DELETE' });
+        if (resp.ok) {
+            showToast(`Peer "${name}" restored`, 'success');
+            TM.refresh.trigger('keys');
+        } else {
+            const data = await resp.json();
+            showToast(data.error || 'Failed to restore peer', 'error');
+        }
+    } catch (err) {
+        showToast(`Failed to restore peer: ${err.message}`, 'error');
+    }
+}
+window.restorePeer = restorePeer;
+
 async function fetchShares() {
     try {
         const resp = await fetch('/api/shares');
@@ -2111,6 +2379,7 @@ function initRefreshCoordinator() {
     TM.refresh.register('alerts', fetchAlerts);
     TM.refresh.register('filter', loadFilterRules);
     TM.refresh.register('peers-mgmt', fetchPeersMgmt);
+    TM.refresh.register('keys', fetchJoinKeys);
     TM.refresh.register('groups', fetchGroups);
     TM.refresh.register('shares', fetchShares);
     TM.refresh.register('bindings', fetchBindings);
@@ -2142,6 +2411,9 @@ function initRefreshCoordinator() {
     // When peers-mgmt (users) changes, refresh groups and bindings (user changes affect group/role assignments)
     // Note: Removed groups → peers-mgmt to avoid circular dependency
     TM.refresh.addDependency('peers-mgmt', ['groups', 'bindings']);
+
+    // Revoking or restoring a peer changes the peer list
+    TM.refresh.addDependency('keys', ['peers-mgmt']);
 }
 
 // Tab Navigation
@@ -2577,6 +2849,11 @@ function s3Download() {
 }
 window.s3Download = s3Download;
 
+function s3ShareLink() {
+    if (TM.s3explorer) TM.s3explorer.shareLink();
+}
+window.s3ShareLink = s3ShareLink;
+
 function s3Delete() {
     if (TM.s3explorer) TM.s3explorer.deleteFile();
 }
@@ -892,6 +892,22 @@
         return true;
     }
 
+    async function presignObject(bucket, key, method, expiresIn) {
+        const resp = await fetch(
+            `/api/s3/buckets/${encodeURIComponent(bucket)}/objects/${encodeURIComponent(key)}/presign`,
+            {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ method, expires_in: expiresIn }),
+            },
+        );
+        if (!resp.ok) {
+            const err = await resp.json().catch(() => ({ error: `HTTP ${resp.status}` }));
+            throw new Error(err.error || `Failed to create link: ${resp.status}`);
+        }
+        return resp.json();
+    }
+
     async function fetchVersions(bucket, key) {
         try {
             const resp = await fetch(
@@ -2145,6 +2161,24 @@
         document.body.removeChild(a);
     }
 
+    // Mint a presigned download URL (valid 24h) and copy it to the clipboard
+    async function shareLink() {
+        if (!state.currentFile) return;
+
+        try {
+            const result = await presignObject(state.currentFile.bucket, state.currentFile.key, 'GET', 24 * 3600);
+            try {
+                await navigator.clipboard.writeText(result.url);
+                showToast('Download link copied (valid 24h)', 'success');
+            } catch {
+                // Clipboard API requires a secure context; fall back to a prompt
+                prompt('Download link (valid 24h):', result.url);
+            }
+        } catch (err) {
+            showToast(`Failed to create link: ${err.message}`, 'error');
+        }
+    }
+
     async function deleteFile() {
         if (!state.currentFile) return;
 
@@ -2879,6 +2913,7 @@
         closeFile,
         saveFile,
         downloadFile,
+        shareLink,
         deleteFile,
         undeleteFile,
         openNewModal,
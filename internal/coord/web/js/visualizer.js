@@ -106,6 +106,7 @@ class VisualizerNode {
 
         // Connection types (peer -> transport type)
         this.connections = peer.connections || {};
+        this.pathMTUs = peer.path_mtus || {}; // peer -> discovered path MTU (UDP only)
 
         // Layout positions
         this.x = 0;
@@ -355,6 +356,7 @@ class NodeVisualizer {
                 node.exitClients = peer.exit_clients || [];
                 // Connection types
                 node.connections = peer.connections || {};
+                node.pathMTUs = peer.path_mtus || {};
             } else {
                 // Add new node
                 const node = new VisualizerNode(peer, this.domainSuffix, nodeType);
@@ -1000,12 +1002,13 @@ class NodeVisualizer {
             // or from selected node's connections to this node
             const transportType = node.connections[selectedNode.name] || selectedNode.connections[node.name];
             if (transportType) {
-                this.drawTransportBadge(ctx, x + CARD_WIDTH - 10, contentY + lineHeight * 2 + 8, transportType);
+                const pathMTU = node.pathMTUs[selectedNode.name] || selectedNode.pathMTUs[node.name];
+                this.drawTransportBadge(ctx, x + CARD_WIDTH - 10, contentY + lineHeight * 2 + 8, transportType, pathMTU);
             }
         }
     }
 
-    drawTransportBadge(ctx, x, y, transportType) {
+    drawTransportBadge(ctx, x, y, transportType, pathMTU) {
         const type = transportType.toLowerCase();
         let color;
         switch (type) {
@@ -1025,7 +1028,9 @@ class NodeVisualizer {
         ctx.font = 'bold 9px monospace';
         ctx.textAlign = 'right';
         ctx.fillStyle = color;
-        ctx.fillText(type.toUpperCase(), x, y);
+        // Discovered path MTU, once known
+        const label = pathMTU ? `${type.toUpperCase()} ${pathMTU}` : type.toUpperCase();
+        ctx.fillText(label, x, y);
     }
 
     drawExitBadge(ctx, x, y) {
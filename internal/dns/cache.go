@@ -0,0 +1,155 @@
+package dns
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Upstream response cache limits.
+const (
+	maxCacheEntries = 10000
+	maxCacheTTL     = time.Hour
+)
+
+// cacheEntry is a cached upstream response.
+type cacheEntry struct {
+	msg     *dns.Msg
+	stored  time.Time
+	expires time.Time
+}
+
+// responseCache caches upstream responses for the lifetime of their records.
+type responseCache struct {
+	entries map[string]cacheEntry
+	mu      sync.Mutex
+	now     func() time.Time
+}
+
+func newResponseCache() *responseCache {
+	return &responseCache{
+		entries: make(map[string]cacheEntry),
+		now:     time.Now,
+	}
+}
+
+// cacheKey identifies a single-question query.
+func cacheKey(req *dns.Msg) (string, bool) {
+	if len(req.Question) != 1 {
+		return "", false
+	}
+	q := req.Question[0]
+	return strings.ToLower(q.Name) + "/" + strconv.Itoa(int(q.Qtype)) + "/" + strconv.Itoa(int(q.Qclass)), true
+}
+
+// get returns a copy of the cached response for req with TTLs reduced by the
+// time spent in the cache.
+func (c *responseCache) get(req *dns.Msg) (*dns.Msg, bool) {
+	key, ok := cacheKey(req)
+	if !ok {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	now := c.now()
+	if ok && !now.Before(entry.expires) {
+		delete(c.entries, key)
+		ok = false
+	}
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	resp := entry.msg.Copy()
+	resp.Id = req.Id
+	elapsed := uint32(now.Sub(entry.stored) / time.Second)
+	for _, section := range [][]dns.RR{resp.Answer, resp.Ns, resp.Extra} {
+		for _, rr := range section {
+			if hdr := rr.Header(); hdr.Rrtype != dns.TypeOPT {
+				if hdr.Ttl > elapsed {
+					hdr.Ttl -= elapsed
+				} else {
+					hdr.Ttl = 0
+				}
+			}
+		}
+	}
+	return resp, true
+}
+
+// put caches resp for req. Only successful and NXDOMAIN answers with a TTL are
+// cached; negative answers use their SOA minimum (RFC 2308).
+func (c *responseCache) put(req, resp *dns.Msg) {
+	key, ok := cacheKey(req)
+	if !ok || resp.Truncated || (resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError) {
+		return
+	}
+	ttl, ok := responseTTL(resp)
+	if !ok || ttl == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= maxCacheEntries {
+		c.evictLocked()
+	}
+	now := c.now()
+	c.entries[key] = cacheEntry{msg: resp.Copy(), stored: now, expires: now.Add(ttl)}
+}
+
+// evictLocked removes expired entries, or an arbitrary tenth of the cache if
+// none have expired. Caller must hold c.mu.
+func (c *responseCache) evictLocked() {
+	now := c.now()
+	for key, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+	for key := range c.entries {
+		if len(c.entries) < maxCacheEntries*9/10 {
+			break
+		}
+		delete(c.entries, key)
+	}
+}
+
+// responseTTL returns how long a response may be cached.
+func responseTTL(resp *dns.Msg) (time.Duration, bool) {
+	var minTTL uint32
+	found := false
+	consider := func(ttl uint32) {
+		if !found || ttl < minTTL {
+			minTTL, found = ttl, true
+		}
+	}
+
+	if len(resp.Answer) > 0 {
+		for _, rr := range resp.Answer {
+			consider(rr.Header().Ttl)
+		}
+	} else {
+		for _, rr := range resp.Ns {
+			if soa, ok := rr.(*dns.SOA); ok {
+				consider(soa.Hdr.Ttl)
+				consider(soa.Minttl)
+			}
+		}
+	}
+	if !found {
+		return 0, false
+	}
+
+	ttl := time.Duration(minTTL) * time.Second
+	if ttl > maxCacheTTL {
+		ttl = maxCacheTTL
+	}
+	return ttl, true
+}
@@ -0,0 +1,188 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/rs/zerolog/log"
+)
+
+// forwardTimeout bounds the time spent answering one forwarded query, across
+// all upstreams tried.
+const forwardTimeout = 5 * time.Second
+
+// ForwardRule sends queries for a domain and its subdomains to specific
+// upstream servers instead of the default ones (split-horizon DNS).
+type ForwardRule struct {
+	Domain    string   // e.g. "corp.example.com"
+	Upstreams []string // Upstream addresses, see ParseUpstream
+}
+
+// forwardRoute is a parsed ForwardRule.
+type forwardRoute struct {
+	domain    string // Lowercase FQDN
+	upstreams []Upstream
+}
+
+// AnswerObserver is told the addresses a name outside the mesh resolved to,
+// e.g. to route traffic to them by domain.
+type AnswerObserver func(name string, ips []net.IP, ttl time.Duration)
+
+// Forwarder answers queries outside the mesh domains from upstream servers,
+// caching the responses.
+type Forwarder struct {
+	upstreams []Upstream
+	routes    []forwardRoute // Most specific domain first
+	cache     *responseCache
+	observer  AnswerObserver
+}
+
+// NewForwarder creates a forwarder with default upstreams and per-domain rules.
+// Queries matching no rule go to the default upstreams; with none, they fail.
+func NewForwarder(upstreams []string, rules []ForwardRule) (*Forwarder, error) {
+	f := &Forwarder{cache: newResponseCache()}
+
+	var err error
+	if f.upstreams, err = parseUpstreams(upstreams); err != nil {
+		return nil, err
+	}
+
+	for _, rule := range rules {
+		domain := strings.ToLower(dns.Fqdn(strings.TrimSpace(rule.Domain)))
+		if _, ok := dns.IsDomainName(domain); !ok || domain == "." {
+			return nil, fmt.Errorf("forward rule: invalid domain %q", rule.Domain)
+		}
+		if len(rule.Upstreams) == 0 {
+			return nil, fmt.Errorf("forward rule %q: no upstreams", rule.Domain)
+		}
+		routeUpstreams, err := parseUpstreams(rule.Upstreams)
+		if err != nil {
+			return nil, fmt.Errorf("forward rule %q: %w", rule.Domain, err)
+		}
+		f.routes = append(f.routes, forwardRoute{domain: domain, upstreams: routeUpstreams})
+	}
+	sort.SliceStable(f.routes, func(i, j int) bool {
+		return dns.CountLabel(f.routes[i].domain) > dns.CountLabel(f.routes[j].domain)
+	})
+
+	return f, nil
+}
+
+// SetObserver sets a callback told the addresses in each answer served.
+// Must be called before the forwarder serves queries.
+func (f *Forwarder) SetObserver(observer AnswerObserver) {
+	f.observer = observer
+}
+
+// observe reports the A and AAAA records of a response to the observer.
+func (f *Forwarder) observe(resp *dns.Msg) {
+	if f.observer == nil || len(resp.Question) == 0 {
+		return
+	}
+	var ips []net.IP
+	var ttl uint32
+	for _, rr := range resp.Answer {
+		switch rr := rr.(type) {
+		case *dns.A:
+			ips = append(ips, rr.A)
+		case *dns.AAAA:
+			ips = append(ips, rr.AAAA)
+		default:
+			continue
+		}
+		if len(ips) == 1 || rr.Header().Ttl < ttl {
+			ttl = rr.Header().Ttl
+		}
+	}
+	if len(ips) > 0 {
+		f.observer(resp.Question[0].Name, ips, time.Duration(ttl)*time.Second)
+	}
+}
+
+func parseUpstreams(addrs []string) ([]Upstream, error) {
+	upstreams := make([]Upstream, 0, len(addrs))
+	for _, addr := range addrs {
+		u, err := ParseUpstream(strings.TrimSpace(addr))
+		if err != nil {
+			return nil, err
+		}
+		upstreams = append(upstreams, u)
+	}
+	return upstreams, nil
+}
+
+// upstreamsFor returns the upstreams responsible for a query name.
+func (f *Forwarder) upstreamsFor(name string) []Upstream {
+	name = strings.ToLower(dns.Fqdn(name))
+	for _, route := range f.routes {
+		if dns.IsSubDomain(route.domain, name) {
+			return route.upstreams
+		}
+	}
+	return f.upstreams
+}
+
+// Exchange answers req from the cache or the responsible upstreams, trying
+// each in turn until one responds.
+func (f *Forwarder) Exchange(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
+	if len(req.Question) == 0 {
+		return nil, errors.New("query has no question")
+	}
+	if resp, ok := f.cache.get(req); ok {
+		return resp, nil
+	}
+
+	upstreams := f.upstreamsFor(req.Question[0].Name)
+	if len(upstreams) == 0 {
+		return nil, errors.New("no upstream servers configured")
+	}
+
+	var lastErr error
+	for _, u := range upstreams {
+		resp, err := u.Exchange(ctx, req)
+		if err == nil && resp.Rcode != dns.RcodeServerFailure {
+			f.cache.put(req, resp)
+			return resp, nil
+		}
+		if err == nil {
+			err = fmt.Errorf("%s", dns.RcodeToString[resp.Rcode])
+		}
+		lastErr = fmt.Errorf("%s: %w", u, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+// ServeDNS implements dns.Handler for queries outside the mesh domains.
+func (f *Forwarder) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	ctx, cancel := context.WithTimeout(context.Background(), forwardTimeout)
+	defer cancel()
+
+	resp, err := f.Exchange(ctx, req)
+	if err != nil {
+		log.Debug().Err(err).Msg("DNS forwarding failed")
+		resp = new(dns.Msg)
+		resp.SetRcode(req, dns.RcodeServerFailure)
+		_ = w.WriteMsg(resp)
+		return
+	}
+
+	f.observe(resp)
+	resp.Id = req.Id
+	if w.LocalAddr().Network() == "udp" {
+		size := dns.MinMsgSize
+		if opt := req.IsEdns0(); opt != nil {
+			size = int(opt.UDPSize())
+		}
+		resp.Truncate(size)
+	}
+	_ = w.WriteMsg(resp)
+}
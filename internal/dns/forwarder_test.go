@@ -0,0 +1,293 @@
+package dns
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tunnelmesh/tunnelmesh/testutil"
+)
+
+// fakeUpstream is a UDP DNS server answering every A query with ip.
+type fakeUpstream struct {
+	addr    string
+	queries atomic.Int32
+}
+
+func startFakeUpstream(t *testing.T, ip string, rcode int) *fakeUpstream {
+	t.Helper()
+	u := &fakeUpstream{addr: "127.0.0.1:" + strconv.Itoa(testutil.FreePort(t))}
+
+	mux := dns.NewServeMux()
+	mux.HandleFunc(".", func(w dns.ResponseWriter, req *dns.Msg) {
+		u.queries.Add(1)
+		resp := new(dns.Msg)
+		resp.SetRcode(req, rcode)
+		if rcode == dns.RcodeSuccess && req.Question[0].Qtype == dns.TypeA {
+			resp.Answer = append(resp.Answer, &dns.A{
+				Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 120},
+				A:   net.ParseIP(ip),
+			})
+		}
+		_ = w.WriteMsg(resp)
+	})
+
+	started := make(chan struct{})
+	server := &dns.Server{Addr: u.addr, Net: "udp", Handler: mux, NotifyStartedFunc: func() { close(started) }}
+	go func() { _ = server.ListenAndServe() }()
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("fake upstream did not start")
+	}
+	t.Cleanup(func() { _ = server.Shutdown() })
+	return u
+}
+
+func queryA(name string) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetQuestion(name, dns.TypeA)
+	return m
+}
+
+func answerIP(t *testing.T, resp *dns.Msg) string {
+	t.Helper()
+	require.NotNil(t, resp)
+	require.Len(t, resp.Answer, 1)
+	a, ok := resp.Answer[0].(*dns.A)
+	require.True(t, ok)
+	return a.A.String()
+}
+
+func TestParseUpstream(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"1.1.1.1", "1.1.1.1:53"},
+		{"1.1.1.1:5353", "1.1.1.1:5353"},
+		{"2606:4700::1111", "[2606:4700::1111]:53"},
+		{"udp://9.9.9.9", "9.9.9.9:53"},
+		{"tcp://9.9.9.9", "tcp://9.9.9.9:53"},
+		{"tls://1.1.1.1", "tls://1.1.1.1:853"},
+		{"tls://dns.example.com:8853", "tls://dns.example.com:8853"},
+		{"https://1.1.1.1/dns-query", "https://1.1.1.1/dns-query"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			u, err := ParseUpstream(tt.in)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, u.String())
+		})
+	}
+
+	_, err := ParseUpstream("quic://1.1.1.1")
+	assert.Error(t, err)
+	_, err = ParseUpstream("")
+	assert.Error(t, err)
+}
+
+func TestForwarder_SplitHorizon(t *testing.T) {
+	public := startFakeUpstream(t, "93.184.216.34", dns.RcodeSuccess)
+	corp := startFakeUpstream(t, "10.1.2.3", dns.RcodeSuccess)
+
+	f, err := NewForwarder([]string{public.addr}, []ForwardRule{
+		{Domain: "corp.example.com", Upstreams: []string{corp.addr}},
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	resp, err := f.Exchange(ctx, queryA("www.example.com."))
+	require.NoError(t, err)
+	assert.Equal(t, "93.184.216.34", answerIP(t, resp))
+
+	resp, err = f.Exchange(ctx, queryA("intranet.CORP.example.com."))
+	require.NoError(t, err)
+	assert.Equal(t, "10.1.2.3", answerIP(t, resp))
+
+	// The rule covers the domain itself but not lookalike names
+	resp, err = f.Exchange(ctx, queryA("corp.example.com."))
+	require.NoError(t, err)
+	assert.Equal(t, "10.1.2.3", answerIP(t, resp))
+	resp, err = f.Exchange(ctx, queryA("notcorp.example.com."))
+	require.NoError(t, err)
+	assert.Equal(t, "93.184.216.34", answerIP(t, resp))
+}
+
+func TestForwarder_CachesAndFailsOver(t *testing.T) {
+	broken := startFakeUpstream(t, "", dns.RcodeServerFailure)
+	working := startFakeUpstream(t, "192.0.2.1", dns.RcodeSuccess)
+
+	f, err := NewForwarder([]string{broken.addr, working.addr}, nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	req := queryA("example.org.")
+	resp, err := f.Exchange(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, "192.0.2.1", answerIP(t, resp))
+	assert.Equal(t, int32(1), broken.queries.Load())
+	assert.Equal(t, int32(1), working.queries.Load())
+
+	// Second query is answered from the cache, with the caller's ID
+	req = queryA("example.org.")
+	resp, err = f.Exchange(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, "192.0.2.1", answerIP(t, resp))
+	assert.Equal(t, req.Id, resp.Id)
+	assert.Equal(t, int32(1), working.queries.Load())
+}
+
+func TestForwarder_NoUpstreams(t *testing.T) {
+	f, err := NewForwarder(nil, []ForwardRule{{Domain: "corp.example.com", Upstreams: []string{"127.0.0.1:1"}}})
+	require.NoError(t, err)
+
+	_, err = f.Exchange(context.Background(), queryA("example.org."))
+	assert.Error(t, err)
+
+	_, err = NewForwarder(nil, []ForwardRule{{Domain: "corp.example.com"}})
+	assert.Error(t, err)
+	_, err = NewForwarder(nil, []ForwardRule{{Domain: "bad..domain", Upstreams: []string{"1.1.1.1"}}})
+	assert.Error(t, err)
+}
+
+func TestDoHUpstream(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/dns-message", r.Header.Get("Content-Type"))
+		body, _ := io.ReadAll(r.Body)
+		req := new(dns.Msg)
+		require.NoError(t, req.Unpack(body))
+		assert.Equal(t, uint16(0), req.Id)
+
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		resp.Answer = append(resp.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+			A:   net.ParseIP("198.51.100.7"),
+		})
+		packed, _ := resp.Pack()
+		w.Header().Set("Content-Type", "application/dns-message")
+		_, _ = w.Write(packed)
+	}))
+	defer srv.Close()
+
+	u, err := ParseUpstream(srv.URL + "/dns-query")
+	require.NoError(t, err)
+	u.(*dohUpstream).client = srv.Client()
+
+	req := queryA("example.net.")
+	resp, err := u.Exchange(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, req.Id, resp.Id)
+	assert.Equal(t, "198.51.100.7", answerIP(t, resp))
+}
+
+func TestResponseCache_TTL(t *testing.T) {
+	c := newResponseCache()
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	req := queryA("example.com.")
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	resp.Answer = append(resp.Answer, &dns.A{
+		Hdr: dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 100},
+		A:   net.ParseIP("192.0.2.1"),
+	})
+	c.put(req, resp)
+
+	now = now.Add(40 * time.Second)
+	cached, ok := c.get(req)
+	require.True(t, ok)
+	assert.Equal(t, uint32(60), cached.Answer[0].Header().Ttl)
+
+	now = now.Add(60 * time.Second)
+	_, ok = c.get(req)
+	assert.False(t, ok)
+
+	// Negative answers are cached for the SOA minimum
+	nx := new(dns.Msg)
+	nx.SetRcode(req, dns.RcodeNameError)
+	nx.Ns = append(nx.Ns, &dns.SOA{Hdr: dns.RR_Header{Name: "com.", Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 900}, Minttl: 30})
+	c.put(req, nx)
+	now = now.Add(29 * time.Second)
+	cached, ok = c.get(req)
+	require.True(t, ok)
+	assert.Equal(t, dns.RcodeNameError, cached.Rcode)
+	now = now.Add(time.Second)
+	_, ok = c.get(req)
+	assert.False(t, ok)
+
+	// Server failures are never cached
+	fail := new(dns.Msg)
+	fail.SetRcode(req, dns.RcodeServerFailure)
+	c.put(req, fail)
+	_, ok = c.get(req)
+	assert.False(t, ok)
+}
+
+func TestResolver_DNSServer_Forwarding(t *testing.T) {
+	upstream := startFakeUpstream(t, "93.184.216.34", dns.RcodeSuccess)
+	f, err := NewForwarder([]string{upstream.addr}, nil)
+	require.NoError(t, err)
+
+	r := newSyncedResolver()
+	r.SetForwarder(f)
+	query := startTestResolver(t, r)
+
+	resp := query("www.example.com.", dns.TypeA)
+	assert.Equal(t, "93.184.216.34", answerIP(t, resp))
+
+	// Mesh names are still answered locally, including NXDOMAIN
+	resp = query("web.tunnelmesh.", dns.TypeA)
+	assert.Equal(t, "10.42.0.5", answerIP(t, resp))
+	resp = query("missing.tunnelmesh.", dns.TypeA)
+	assert.Equal(t, dns.RcodeNameError, resp.Rcode)
+	assert.Equal(t, int32(1), upstream.queries.Load())
+}
+
+func TestForwarder_Observer(t *testing.T) {
+	upstream := startFakeUpstream(t, "93.184.216.34", dns.RcodeSuccess)
+	f, err := NewForwarder([]string{upstream.addr}, nil)
+	require.NoError(t, err)
+
+	type observed struct {
+		name string
+		ips  []net.IP
+		ttl  time.Duration
+	}
+	answers := make(chan observed, 4)
+	f.SetObserver(func(name string, ips []net.IP, ttl time.Duration) {
+		answers <- observed{name, ips, ttl}
+	})
+
+	r := newSyncedResolver()
+	r.SetForwarder(f)
+	query := startTestResolver(t, r)
+
+	// Cached answers are observed too
+	for range 2 {
+		resp := query("www.example.com.", dns.TypeA)
+		assert.Equal(t, "93.184.216.34", answerIP(t, resp))
+		got := <-answers
+		assert.Equal(t, "www.example.com.", got.name)
+		require.Len(t, got.ips, 1)
+		assert.Equal(t, "93.184.216.34", got.ips[0].String())
+		assert.LessOrEqual(t, got.ttl, 120*time.Second)
+	}
+
+	// Mesh names and answers without addresses are not
+	query("web.tunnelmesh.", dns.TypeA)
+	query("www.example.com.", dns.TypeMX)
+	assert.Empty(t, answers)
+}
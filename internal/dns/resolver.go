@@ -2,32 +2,59 @@
 package dns
 
 import (
+	"encoding/hex"
 	"net"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/miekg/dns"
 	"github.com/rs/zerolog/log"
 	"github.com/tunnelmesh/tunnelmesh/internal/mesh"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
 )
 
 // Resolver is a local DNS server that resolves mesh hostnames.
 type Resolver struct {
-	ttl          uint32            // TTL for DNS responses
-	records      map[string]string // hostname (without suffix) -> IP
-	coordMeshIPs []string          // All coordinator mesh IPs for "this.tunnelmesh" round-robin
+	ttl          uint32              // TTL for DNS responses
+	records      map[string]string   // hostname (without suffix) -> IP
+	records6     map[string]string   // hostname (without suffix) -> IPv6, for names that have one
+	peers        map[string]peerMeta // peer name -> metadata for TXT and SRV answers
+	tags         map[string][]string // tag (without "tag:") -> hostnames carrying it, for "<tag>.tag" names
+	coordMeshIPs []string            // All coordinator mesh IPs for "this.tunnelmesh" round-robin
+	reverseZone  string              // in-addr.arpa zone for the mesh CIDR, e.g. "42.10.in-addr.arpa."
+	reverseZone6 string              // ip6.arpa zone for the mesh IPv6 prefix
+	forwarder    *Forwarder          // Answers queries outside the mesh domains (nil: not served)
+	zone         map[string][]dns.RR // Custom records by hostname (without suffix), see SyncZone
+	zoneNodes    map[string]bool     // Parents of custom record names, which exist without records
+	rotation     atomic.Uint32       // Round-robin offset for custom address records
 	mu           sync.RWMutex
 	server       *dns.Server
+	tcpServer    *dns.Server
 	shutdown     chan struct{}
 }
 
+// peerMeta is the metadata a peer name carries besides its IP.
+type peerMeta struct {
+	peerID   string
+	version  string
+	tags     []string
+	services []proto.Service
+}
+
 // NewResolver creates a new DNS resolver.
 // The suffix parameter is ignored - all supported suffixes (.tunnelmesh, .tm, .mesh) are handled.
 func NewResolver(_ string, ttl int) *Resolver {
 	return &Resolver{
-		ttl:      uint32(ttl),
-		records:  make(map[string]string),
-		shutdown: make(chan struct{}),
+		ttl:          uint32(ttl),
+		records:      make(map[string]string),
+		records6:     make(map[string]string),
+		peers:        make(map[string]peerMeta),
+		reverseZone:  reverseZone(mesh.CIDR),
+		reverseZone6: reverseZone(mesh.IPv6CIDR),
+		shutdown:     make(chan struct{}),
 	}
 }
 
@@ -53,18 +80,23 @@ func (r *Resolver) RemoveRecord(hostname string) {
 
 	hostname = r.stripSuffix(hostname)
 	delete(r.records, hostname)
+	delete(r.records6, hostname)
+	delete(r.peers, hostname)
 
 	log.Debug().
 		Str("hostname", hostname).
 		Msg("DNS record removed")
 }
 
-// UpdateRecords replaces all records with a new set.
+// UpdateRecords replaces all records with a new set, without peer metadata.
 func (r *Resolver) UpdateRecords(records map[string]string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	r.records = make(map[string]string, len(records))
+	r.records6 = make(map[string]string)
+	r.peers = make(map[string]peerMeta)
+	r.tags = nil
 	for hostname, ip := range records {
 		hostname = r.stripSuffix(hostname)
 		r.records[hostname] = ip
@@ -79,6 +111,42 @@ func (r *Resolver) UpdateRecords(records map[string]string) {
 		Msg("DNS records updated")
 }
 
+// SyncRecords replaces all records with those from the coordinator, including
+// the mesh IPv6 addresses served as AAAA records, the peer metadata served as
+// TXT records and the services served as SRV records. Tagged hosts are also
+// served under "<tag>.tag" names.
+func (r *Resolver) SyncRecords(records []proto.DNSRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.records = make(map[string]string, len(records))
+	r.records6 = make(map[string]string)
+	r.peers = make(map[string]peerMeta)
+	r.tags = make(map[string][]string)
+	for _, rec := range records {
+		hostname := r.stripSuffix(rec.Hostname)
+		r.records[hostname] = rec.MeshIP
+		if rec.MeshIPv6 != "" {
+			r.records6[hostname] = rec.MeshIPv6
+		}
+		if rec.PeerID != "" || rec.Version != "" || len(rec.Tags) > 0 || len(rec.Services) > 0 {
+			r.peers[hostname] = peerMeta{peerID: rec.PeerID, version: rec.Version, tags: rec.Tags, services: rec.Services}
+		}
+		for _, tag := range rec.Tags {
+			tag = strings.ToLower(strings.TrimPrefix(tag, "tag:"))
+			r.tags[tag] = append(r.tags[tag], hostname)
+		}
+	}
+	for _, hostnames := range r.tags {
+		slices.Sort(hostnames)
+	}
+
+	log.Debug().
+		Int("count", len(records)).
+		Int("peers", len(r.peers)).
+		Msg("DNS records synced")
+}
+
 // SetCoordMeshIPs sets the coordinator mesh IPs for "this.tunnelmesh" round-robin resolution.
 func (r *Resolver) SetCoordMeshIPs(ips []string) {
 	r.mu.Lock()
@@ -100,7 +168,10 @@ func (r *Resolver) Resolve(hostname string) (string, bool) {
 
 // ResolveAll looks up a hostname and returns all matching IPs.
 // For "this.tunnelmesh", returns all coordinator mesh IPs (round-robin).
-// For regular hostnames, returns a single IP.
+// For "<tag>.tag.tunnelmesh", returns the mesh IPs of all hosts carrying the
+// tag, then their mesh IPv6 addresses.
+// For regular hostnames, returns the mesh IP followed by the mesh IPv6
+// address, if the name has one.
 func (r *Resolver) ResolveAll(hostname string) ([]string, bool) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -115,11 +186,57 @@ func (r *Resolver) ResolveAll(hostname string) ([]string, bool) {
 		return nil, false
 	}
 
+	if tag, ok := strings.CutSuffix(hostname, ".tag"); ok {
+		hostnames, ok := r.tags[tag]
+		if !ok {
+			return nil, false
+		}
+		ips := make([]string, 0, 2*len(hostnames))
+		for _, h := range hostnames {
+			ips = append(ips, r.records[h])
+		}
+		for _, h := range hostnames {
+			if ip6, ok := r.records6[h]; ok {
+				ips = append(ips, ip6)
+			}
+		}
+		return ips, true
+	}
+
 	ip, ok := r.records[hostname]
 	if !ok {
 		return nil, false
 	}
-	return []string{ip}, ok
+	if ip6, ok := r.records6[hostname]; ok {
+		return []string{ip, ip6}, true
+	}
+	return []string{ip}, true
+}
+
+// ReverseLookup returns the hostname for a mesh IP. When several names share
+// the IP (a peer and its aliases), the peer name is preferred.
+func (r *Resolver) ReverseLookup(ip string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	records := r.records
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+		records = r.records6
+	}
+
+	var best string
+	var bestIsPeer bool
+	for hostname, recordIP := range records {
+		if recordIP != ip {
+			continue
+		}
+		_, isPeer := r.peers[hostname]
+		// Deterministic choice: peer names first, then alphabetical
+		if best == "" || (isPeer && !bestIsPeer) || (isPeer == bestIsPeer && hostname < best) {
+			best, bestIsPeer = hostname, isPeer
+		}
+	}
+	return best, best != ""
 }
 
 // ListRecords returns a copy of all records.
@@ -134,76 +251,337 @@ func (r *Resolver) ListRecords() map[string]string {
 	return result
 }
 
-// ListenAndServe starts the DNS server.
-func (r *Resolver) ListenAndServe(addr string) error {
-	r.server = &dns.Server{
-		Addr: addr,
-		Net:  "udp",
-	}
+// SetForwarder makes the resolver answer queries outside the mesh domains
+// through f, so it can serve as the system's only resolver. Must be called
+// before ListenAndServe.
+func (r *Resolver) SetForwarder(f *Forwarder) {
+	r.forwarder = f
+}
 
-	// Register handlers for all supported domain suffixes
+// ListenAndServe starts the DNS server. With a forwarder set, it also listens
+// on TCP so clients can retry truncated answers.
+func (r *Resolver) ListenAndServe(addr string) error {
+	// Register handlers for all supported domain suffixes and reverse lookups
+	mux := dns.NewServeMux()
 	for _, suffix := range mesh.AllSuffixes() {
 		zone := strings.TrimPrefix(suffix, ".")
-		dns.HandleFunc(zone, r.handleDNS)
+		mux.HandleFunc(zone, r.handleDNS)
+	}
+	for _, zone := range []string{r.reverseZone, r.reverseZone6} {
+		if zone != "" {
+			mux.HandleFunc(zone, r.handleDNS)
+		}
+	}
+
+	r.mu.Lock()
+	r.server = &dns.Server{
+		Addr:    addr,
+		Net:     "udp",
+		Handler: mux,
 	}
+	if r.forwarder != nil {
+		// Everything else goes upstream; mesh zones are more specific and win
+		mux.Handle(".", r.forwarder)
+		r.tcpServer = &dns.Server{
+			Addr:    addr,
+			Net:     "tcp",
+			Handler: mux,
+		}
+	}
+	server, tcpServer := r.server, r.tcpServer
+	r.mu.Unlock()
 
 	log.Info().
 		Str("addr", addr).
 		Strs("suffixes", mesh.AllSuffixes()).
+		Bool("forwarding", r.forwarder != nil).
 		Msg("starting DNS server")
 
-	return r.server.ListenAndServe()
+	if tcpServer != nil {
+		go func() {
+			if err := tcpServer.ListenAndServe(); err != nil {
+				log.Error().Err(err).Msg("DNS TCP server error")
+			}
+		}()
+	}
+	return server.ListenAndServe()
 }
 
 // Shutdown stops the DNS server.
 func (r *Resolver) Shutdown() error {
-	if r.server != nil {
-		return r.server.Shutdown()
+	r.mu.RLock()
+	server, tcpServer := r.server, r.tcpServer
+	r.mu.RUnlock()
+
+	if tcpServer != nil {
+		_ = tcpServer.Shutdown()
+	}
+	if server != nil {
+		return server.Shutdown()
 	}
 	return nil
 }
 
+// handleDNS answers A, AAAA, TXT and SRV queries for mesh names and custom
+// records, and PTR queries for mesh IPs. Names that exist but have no records
+// of the queried type get an empty NOERROR (NODATA) answer rather than
+// NXDOMAIN.
 func (r *Resolver) handleDNS(w dns.ResponseWriter, req *dns.Msg) {
 	resp := new(dns.Msg)
 	resp.SetReply(req)
 	resp.Authoritative = true
 
 	for _, q := range req.Question {
-		if q.Qtype != dns.TypeA && q.Qtype != dns.TypeAAAA {
-			continue
+		answer, extra, exists := r.answer(q, 0)
+		if !exists {
+			resp.Rcode = dns.RcodeNameError
 		}
+		resp.Answer = append(resp.Answer, answer...)
+		resp.Extra = append(resp.Extra, extra...)
+	}
 
-		// Extract hostname from FQDN
-		hostname := strings.TrimSuffix(q.Name, ".")
-		hostname = r.stripSuffix(hostname)
+	// Negative answers carry the zone's SOA so resolvers can cache them
+	if len(req.Question) > 0 && len(resp.Answer) == 0 {
+		if soa := r.soa(req.Question[0].Name); soa != nil {
+			resp.Ns = append(resp.Ns, soa)
+		}
+	}
 
-		ips, ok := r.ResolveAll(hostname)
-		if !ok {
-			resp.Rcode = dns.RcodeNameError
-			continue
+	_ = w.WriteMsg(resp)
+}
+
+// answer returns the records for one question and whether its name exists.
+// depth counts the CNAMEs followed so far.
+func (r *Resolver) answer(q dns.Question, depth int) (answer, extra []dns.RR, exists bool) {
+	name := strings.ToLower(strings.TrimSuffix(q.Name, "."))
+	if r.reverseZone != "" && dns.IsSubDomain(r.reverseZone, name+".") {
+		return r.answerPTR(q, name)
+	}
+	if r.reverseZone6 != "" && dns.IsSubDomain(r.reverseZone6, name+".") {
+		return r.answerPTR6(q, name)
+	}
+
+	hostname := r.stripSuffix(name)
+	if strings.HasPrefix(hostname, "_") {
+		if answer, extra, ok := r.answerSRV(q, hostname); ok {
+			return answer, extra, true
 		}
+		return r.answerZone(q, hostname, depth)
+	}
 
-		if q.Qtype == dns.TypeA {
-			for _, ip := range ips {
-				rr := &dns.A{
-					Hdr: dns.RR_Header{
-						Name:   q.Name,
-						Rrtype: dns.TypeA,
-						Class:  dns.ClassINET,
-						Ttl:    r.ttl,
-					},
-					A: net.ParseIP(ip),
-				}
-				resp.Answer = append(resp.Answer, rr)
+	ips, ok := r.ResolveAll(hostname)
+	if !ok {
+		return r.answerZone(q, hostname, depth)
+	}
+
+	switch q.Qtype {
+	case dns.TypeA, dns.TypeAAAA:
+		answer = r.addressRecords(q.Name, q.Qtype, ips)
+	case dns.TypeTXT:
+		if meta, ok := r.peerMeta(hostname); ok {
+			var txt []string
+			if meta.peerID != "" {
+				txt = append(txt, "peer_id="+meta.peerID)
+			}
+			if meta.version != "" {
+				txt = append(txt, "version="+meta.version)
+			}
+			if len(meta.tags) > 0 {
+				txt = append(txt, "tags="+strings.Join(meta.tags, ","))
+			}
+			if len(txt) > 0 {
+				answer = append(answer, &dns.TXT{Hdr: r.header(q.Name, dns.TypeTXT), Txt: txt})
 			}
 		}
 	}
+	return answer, nil, true
+}
 
-	if len(req.Question) > 0 && len(resp.Answer) == 0 {
-		resp.Rcode = dns.RcodeNameError
+// answerPTR answers a question in the mesh reverse zone.
+func (r *Resolver) answerPTR(q dns.Question, name string) (answer, extra []dns.RR, exists bool) {
+	labels := strings.Split(strings.TrimSuffix(name, ".in-addr.arpa"), ".")
+	if len(labels) < 4 {
+		// Zone apex or a /24 within it: exists, but holds no PTR records
+		return nil, nil, true
 	}
+	if len(labels) > 4 {
+		return nil, nil, false
+	}
+	slices.Reverse(labels)
+	return r.answerReverse(q, net.ParseIP(strings.Join(labels, ".")))
+}
 
-	_ = w.WriteMsg(resp)
+// answerPTR6 answers a question in the mesh IPv6 reverse zone, whose names
+// are the 32 nibbles of the address in reverse order.
+func (r *Resolver) answerPTR6(q dns.Question, name string) (answer, extra []dns.RR, exists bool) {
+	nibbles := strings.Split(strings.TrimSuffix(name, ".ip6.arpa"), ".")
+	if len(nibbles) < 2*net.IPv6len {
+		// Within the prefix: exists, but holds no PTR records
+		return nil, nil, true
+	}
+	if len(nibbles) > 2*net.IPv6len {
+		return nil, nil, false
+	}
+	slices.Reverse(nibbles)
+	raw, err := hex.DecodeString(strings.Join(nibbles, ""))
+	if err != nil || len(raw) != net.IPv6len {
+		// Labels must be single hex digits
+		return nil, nil, false
+	}
+	return r.answerReverse(q, net.IP(raw))
+}
+
+// answerReverse answers a reverse lookup of a mesh IP.
+func (r *Resolver) answerReverse(q dns.Question, ip net.IP) (answer, extra []dns.RR, exists bool) {
+	if ip == nil {
+		return nil, nil, false
+	}
+
+	hostname, ok := r.ReverseLookup(ip.String())
+	if !ok {
+		return nil, nil, false
+	}
+	if q.Qtype == dns.TypePTR {
+		answer = append(answer, &dns.PTR{Hdr: r.header(q.Name, dns.TypePTR), Ptr: fqdn(hostname)})
+	}
+	return answer, nil, true
+}
+
+// answerSRV answers a _service._protocol.peer question from the peer's
+// advertised services, with the peer's address as additional data.
+func (r *Resolver) answerSRV(q dns.Question, hostname string) (answer, extra []dns.RR, exists bool) {
+	parts := strings.SplitN(hostname, ".", 3)
+	if len(parts) != 3 || !strings.HasPrefix(parts[1], "_") {
+		return nil, nil, false
+	}
+	service, protocol, peerName := strings.TrimPrefix(parts[0], "_"), strings.TrimPrefix(parts[1], "_"), parts[2]
+
+	meta, ok := r.peerMeta(peerName)
+	if !ok {
+		return nil, nil, false
+	}
+	var srv *proto.Service
+	for i := range meta.services {
+		if meta.services[i].Name == service && meta.services[i].Protocol == protocol {
+			srv = &meta.services[i]
+			break
+		}
+	}
+	if srv == nil {
+		return nil, nil, false
+	}
+
+	if q.Qtype == dns.TypeSRV {
+		target := fqdn(peerName)
+		answer = append(answer, &dns.SRV{
+			Hdr:    r.header(q.Name, dns.TypeSRV),
+			Port:   uint16(srv.Port),
+			Target: target,
+		})
+		if ips, ok := r.ResolveAll(peerName); ok {
+			extra = r.addressRecords(target, dns.TypeA, ips)
+			extra = append(extra, r.addressRecords(target, dns.TypeAAAA, ips)...)
+		}
+	}
+	return answer, extra, true
+}
+
+// addressRecords returns A or AAAA records for the IPs of matching family.
+func (r *Resolver) addressRecords(name string, qtype uint16, ips []string) []dns.RR {
+	var rrs []dns.RR
+	for _, s := range ips {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			continue
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			if qtype == dns.TypeA {
+				rrs = append(rrs, &dns.A{Hdr: r.header(name, dns.TypeA), A: ip4})
+			}
+		} else if qtype == dns.TypeAAAA {
+			rrs = append(rrs, &dns.AAAA{Hdr: r.header(name, dns.TypeAAAA), AAAA: ip})
+		}
+	}
+	return rrs
+}
+
+// peerMeta returns the metadata for a peer name.
+func (r *Resolver) peerMeta(hostname string) (peerMeta, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	meta, ok := r.peers[hostname]
+	return meta, ok
+}
+
+// header returns a resource record header with the resolver's TTL.
+func (r *Resolver) header(name string, rrtype uint16) dns.RR_Header {
+	return dns.RR_Header{Name: name, Rrtype: rrtype, Class: dns.ClassINET, Ttl: r.ttl}
+}
+
+// soa returns the SOA record of the zone containing name, or nil if the
+// resolver is not authoritative for it.
+func (r *Resolver) soa(name string) dns.RR {
+	name = strings.ToLower(dns.Fqdn(name))
+	zones := []string{r.reverseZone, r.reverseZone6}
+	for _, suffix := range mesh.AllSuffixes() {
+		zones = append(zones, strings.TrimPrefix(suffix, ".")+".")
+	}
+	for _, zone := range zones {
+		if zone == "" || !dns.IsSubDomain(zone, name) {
+			continue
+		}
+		return &dns.SOA{
+			Hdr:     r.header(zone, dns.TypeSOA),
+			Ns:      "ns." + zone,
+			Mbox:    "hostmaster." + zone,
+			Serial:  1,
+			Refresh: 3600,
+			Retry:   600,
+			Expire:  86400,
+			Minttl:  r.ttl,
+		}
+	}
+	return nil
+}
+
+// fqdn returns the canonical fully qualified mesh name for a hostname.
+func fqdn(hostname string) string {
+	return hostname + mesh.DomainSuffix + "."
+}
+
+// reverseZone returns the in-addr.arpa zone covering an IPv4 CIDR whose
+// prefix length is a multiple of 8, or the ip6.arpa zone covering an IPv6
+// CIDR whose prefix length is a multiple of 4, or "" otherwise.
+func reverseZone(cidr string) string {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return ""
+	}
+	ones, _ := network.Mask.Size()
+	if ones == 0 {
+		return ""
+	}
+
+	if ip := network.IP.To4(); ip != nil {
+		if ones%8 != 0 {
+			return ""
+		}
+		labels := make([]string, 0, ones/8+2)
+		for i := ones/8 - 1; i >= 0; i-- {
+			labels = append(labels, strconv.Itoa(int(ip[i])))
+		}
+		return strings.Join(append(labels, "in-addr", "arpa"), ".") + "."
+	}
+
+	if ones%4 != 0 {
+		return ""
+	}
+	digits := hex.EncodeToString(network.IP.To16())[:ones/4]
+	labels := make([]string, 0, len(digits)+2)
+	for i := len(digits) - 1; i >= 0; i-- {
+		labels = append(labels, digits[i:i+1])
+	}
+	return strings.Join(append(labels, "ip6", "arpa"), ".") + "."
 }
 
 func (r *Resolver) stripSuffix(hostname string) string {
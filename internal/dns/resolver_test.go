@@ -9,6 +9,7 @@ import (
 	"github.com/miekg/dns"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
 	"github.com/tunnelmesh/tunnelmesh/testutil"
 )
 
@@ -277,3 +278,224 @@ func TestResolver_ListRecords(t *testing.T) {
 	assert.Equal(t, "10.42.0.1", records["node1"])
 	assert.Equal(t, "10.42.0.2", records["node2"])
 }
+
+// startTestResolver starts r on a free local port and returns a query function.
+func startTestResolver(t *testing.T, r *Resolver) func(name string, qtype uint16) *dns.Msg {
+	t.Helper()
+	addr := "127.0.0.1:" + strconv.Itoa(testutil.FreePort(t))
+
+	go func() {
+		_ = r.ListenAndServe(addr)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	t.Cleanup(func() { _ = r.Shutdown() })
+
+	return func(name string, qtype uint16) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetQuestion(name, qtype)
+		resp, _, err := new(dns.Client).Exchange(m, addr)
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		return resp
+	}
+}
+
+func newSyncedResolver() *Resolver {
+	r := NewResolver(".tunnelmesh", 60)
+	r.SyncRecords([]proto.DNSRecord{
+		{
+			Hostname: "web",
+			MeshIP:   "10.42.0.5",
+			PeerID:   "0123456789abcdef",
+			Version:  "v1.2.3",
+			Services: []proto.Service{{Name: "http", Protocol: "tcp", Port: 8080}},
+		},
+		{Hostname: "www", MeshIP: "10.42.0.5"},
+		{Hostname: "db", MeshIP: "10.42.0.6"},
+	})
+	return r
+}
+
+func TestResolver_ReverseLookup(t *testing.T) {
+	r := newSyncedResolver()
+
+	// The peer name wins over its alias
+	name, ok := r.ReverseLookup("10.42.0.5")
+	assert.True(t, ok)
+	assert.Equal(t, "web", name)
+
+	name, ok = r.ReverseLookup("10.42.0.6")
+	assert.True(t, ok)
+	assert.Equal(t, "db", name)
+
+	_, ok = r.ReverseLookup("10.42.0.7")
+	assert.False(t, ok)
+}
+
+func TestResolver_SyncRecords_UpdateRecordsDropsMetadata(t *testing.T) {
+	r := newSyncedResolver()
+	_, ok := r.peerMeta("web")
+	assert.True(t, ok)
+
+	r.UpdateRecords(map[string]string{"web": "10.42.0.5"})
+	_, ok = r.peerMeta("web")
+	assert.False(t, ok)
+}
+
+func TestResolver_DNSServer_PTR(t *testing.T) {
+	query := startTestResolver(t, newSyncedResolver())
+
+	resp := query("5.0.42.10.in-addr.arpa.", dns.TypePTR)
+	assert.Equal(t, dns.RcodeSuccess, resp.Rcode)
+	require.Len(t, resp.Answer, 1)
+	ptr, ok := resp.Answer[0].(*dns.PTR)
+	require.True(t, ok)
+	assert.Equal(t, "web.tunnelmesh.", ptr.Ptr)
+
+	// Unassigned mesh IP
+	resp = query("9.0.42.10.in-addr.arpa.", dns.TypePTR)
+	assert.Equal(t, dns.RcodeNameError, resp.Rcode)
+	require.Len(t, resp.Ns, 1)
+	assert.Equal(t, "42.10.in-addr.arpa.", resp.Ns[0].Header().Name)
+}
+
+func TestResolver_DNSServer_SRV(t *testing.T) {
+	query := startTestResolver(t, newSyncedResolver())
+
+	resp := query("_http._tcp.web.tunnelmesh.", dns.TypeSRV)
+	assert.Equal(t, dns.RcodeSuccess, resp.Rcode)
+	require.Len(t, resp.Answer, 1)
+	srv, ok := resp.Answer[0].(*dns.SRV)
+	require.True(t, ok)
+	assert.Equal(t, uint16(8080), srv.Port)
+	assert.Equal(t, "web.tunnelmesh.", srv.Target)
+
+	// Target address is included as additional data
+	require.Len(t, resp.Extra, 1)
+	a, ok := resp.Extra[0].(*dns.A)
+	require.True(t, ok)
+	assert.Equal(t, net.ParseIP("10.42.0.5").To4(), a.A.To4())
+
+	// Unadvertised service or protocol
+	resp = query("_ssh._tcp.web.tunnelmesh.", dns.TypeSRV)
+	assert.Equal(t, dns.RcodeNameError, resp.Rcode)
+	resp = query("_http._udp.web.tm.", dns.TypeSRV)
+	assert.Equal(t, dns.RcodeNameError, resp.Rcode)
+}
+
+func TestResolver_DNSServer_TXT(t *testing.T) {
+	query := startTestResolver(t, newSyncedResolver())
+
+	resp := query("web.tunnelmesh.", dns.TypeTXT)
+	assert.Equal(t, dns.RcodeSuccess, resp.Rcode)
+	require.Len(t, resp.Answer, 1)
+	txt, ok := resp.Answer[0].(*dns.TXT)
+	require.True(t, ok)
+	assert.Equal(t, []string{"peer_id=0123456789abcdef", "version=v1.2.3"}, txt.Txt)
+
+	// Aliases have no metadata: NODATA
+	resp = query("www.tunnelmesh.", dns.TypeTXT)
+	assert.Equal(t, dns.RcodeSuccess, resp.Rcode)
+	assert.Empty(t, resp.Answer)
+}
+
+func TestResolver_DNSServer_Tags(t *testing.T) {
+	r := NewResolver(".tunnelmesh", 60)
+	r.SyncRecords([]proto.DNSRecord{
+		{Hostname: "web", MeshIP: "10.42.0.5", MeshIPv6: "fd00:42::5", Tags: []string{"tag:prod"}},
+		{Hostname: "db", MeshIP: "10.42.0.6", Tags: []string{"tag:db", "tag:prod"}},
+		{Hostname: "alice-phone", MeshIP: "10.42.100.2", Tags: []string{"tag:laptop"}},
+		{Hostname: "dev", MeshIP: "10.42.0.7"},
+	})
+
+	ips, ok := r.ResolveAll("prod.tag.tunnelmesh")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"10.42.0.6", "10.42.0.5", "fd00:42::5"}, ips)
+	ips, ok = r.ResolveAll("laptop.tag")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"10.42.100.2"}, ips)
+	_, ok = r.ResolveAll("dev.tag")
+	assert.False(t, ok)
+
+	query := startTestResolver(t, r)
+	resp := query("prod.tag.tunnelmesh.", dns.TypeA)
+	assert.Equal(t, dns.RcodeSuccess, resp.Rcode)
+	assert.Len(t, resp.Answer, 2)
+
+	resp = query("db.tunnelmesh.", dns.TypeTXT)
+	require.Len(t, resp.Answer, 1)
+	txt, ok := resp.Answer[0].(*dns.TXT)
+	require.True(t, ok)
+	assert.Equal(t, []string{"tags=tag:db,tag:prod"}, txt.Txt)
+}
+
+func TestResolver_DNSServer_NODATA(t *testing.T) {
+	query := startTestResolver(t, newSyncedResolver())
+
+	// Existing IPv4-only name: AAAA is NODATA, not NXDOMAIN
+	resp := query("web.tunnelmesh.", dns.TypeAAAA)
+	assert.Equal(t, dns.RcodeSuccess, resp.Rcode)
+	assert.Empty(t, resp.Answer)
+	require.Len(t, resp.Ns, 1)
+	soa, ok := resp.Ns[0].(*dns.SOA)
+	require.True(t, ok)
+	assert.Equal(t, "tunnelmesh.", soa.Hdr.Name)
+
+	resp = query("db.mesh.", dns.TypeMX)
+	assert.Equal(t, dns.RcodeSuccess, resp.Rcode)
+	assert.Empty(t, resp.Answer)
+
+	resp = query("missing.tunnelmesh.", dns.TypeAAAA)
+	assert.Equal(t, dns.RcodeNameError, resp.Rcode)
+}
+
+func TestResolver_DNSServer_IPv6(t *testing.T) {
+	r := NewResolver(".tunnelmesh", 60)
+	r.SyncRecords([]proto.DNSRecord{
+		{Hostname: "web", MeshIP: "10.42.0.5", MeshIPv6: "fd42:6d65:7368:0:123:4567:89ab:cdef", PeerID: "0123456789abcdef"},
+		{Hostname: "www", MeshIP: "10.42.0.5", MeshIPv6: "fd42:6d65:7368:0:123:4567:89ab:cdef"},
+	})
+	query := startTestResolver(t, r)
+
+	ips, ok := r.ResolveAll("web")
+	require.True(t, ok)
+	assert.Equal(t, []string{"10.42.0.5", "fd42:6d65:7368:0:123:4567:89ab:cdef"}, ips)
+
+	resp := query("web.tunnelmesh.", dns.TypeAAAA)
+	assert.Equal(t, dns.RcodeSuccess, resp.Rcode)
+	require.Len(t, resp.Answer, 1)
+	aaaa, ok := resp.Answer[0].(*dns.AAAA)
+	require.True(t, ok)
+	assert.Equal(t, net.ParseIP("fd42:6d65:7368:0:123:4567:89ab:cdef"), aaaa.AAAA)
+
+	// A queries still answer the IPv4 address only
+	resp = query("web.tunnelmesh.", dns.TypeA)
+	require.Len(t, resp.Answer, 1)
+	_, ok = resp.Answer[0].(*dns.A)
+	assert.True(t, ok)
+
+	// Reverse lookup in ip6.arpa prefers the peer name over its alias
+	ptrName, err := dns.ReverseAddr("fd42:6d65:7368:0:123:4567:89ab:cdef")
+	require.NoError(t, err)
+	resp = query(ptrName, dns.TypePTR)
+	assert.Equal(t, dns.RcodeSuccess, resp.Rcode)
+	require.Len(t, resp.Answer, 1)
+	ptr, ok := resp.Answer[0].(*dns.PTR)
+	require.True(t, ok)
+	assert.Equal(t, "web.tunnelmesh.", ptr.Ptr)
+
+	// Unassigned address in the prefix
+	ptrName, err = dns.ReverseAddr("fd42:6d65:7368::9")
+	require.NoError(t, err)
+	resp = query(ptrName, dns.TypePTR)
+	assert.Equal(t, dns.RcodeNameError, resp.Rcode)
+	require.Len(t, resp.Ns, 1)
+	assert.Equal(t, "0.0.0.0.8.6.3.7.5.6.d.6.2.4.d.f.ip6.arpa.", resp.Ns[0].Header().Name)
+}
+
+func TestReverseZone(t *testing.T) {
+	assert.Equal(t, "42.10.in-addr.arpa.", reverseZone("10.42.0.0/16"))
+	assert.Equal(t, "0.0.0.0.8.6.3.7.5.6.d.6.2.4.d.f.ip6.arpa.", reverseZone("fd42:6d65:7368::/64"))
+	assert.Equal(t, "", reverseZone("10.42.0.0/12"))
+	assert.Equal(t, "", reverseZone("fd42::/18"))
+}
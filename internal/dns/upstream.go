@@ -0,0 +1,157 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// upstreamTimeout bounds a single exchange with an upstream server.
+const upstreamTimeout = 3 * time.Second
+
+// maxDoHResponseSize bounds DNS-over-HTTPS response bodies.
+const maxDoHResponseSize = 64 * 1024
+
+// Upstream is a DNS server that queries outside the mesh are forwarded to.
+type Upstream interface {
+	Exchange(ctx context.Context, req *dns.Msg) (*dns.Msg, error)
+	String() string
+}
+
+// ParseUpstream parses an upstream server address:
+//
+//	1.1.1.1, 1.1.1.1:53, udp://1.1.1.1  plain DNS over UDP, retried over TCP if truncated
+//	tcp://1.1.1.1:53                     plain DNS over TCP
+//	tls://1.1.1.1:853                    DNS over TLS (port 853 by default)
+//	https://1.1.1.1/dns-query            DNS over HTTPS
+//
+// TLS certificates are verified against the host, so DoT and DoH servers given
+// by IP must have that IP in their certificate (as 1.1.1.1 and 8.8.8.8 do).
+func ParseUpstream(s string) (Upstream, error) {
+	if s == "" {
+		return nil, fmt.Errorf("empty upstream")
+	}
+
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		// Bare address without a scheme
+		return &plainUpstream{addr: withDefaultPort(s, "53"), net: "udp"}, nil
+	}
+
+	switch u.Scheme {
+	case "udp", "tcp":
+		return &plainUpstream{addr: withDefaultPort(u.Host, "53"), net: u.Scheme}, nil
+	case "tls":
+		return &plainUpstream{
+			addr: withDefaultPort(u.Host, "853"),
+			net:  "tcp-tls",
+			tls:  &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12},
+		}, nil
+	case "https":
+		return &dohUpstream{
+			url:    u.String(),
+			client: &http.Client{Timeout: upstreamTimeout},
+		}, nil
+	default:
+		return nil, fmt.Errorf("upstream %q: unsupported scheme %q (use udp, tcp, tls or https)", s, u.Scheme)
+	}
+}
+
+// withDefaultPort appends port to host unless it already has one.
+func withDefaultPort(host, port string) string {
+	if net.ParseIP(host) != nil {
+		return net.JoinHostPort(host, port)
+	}
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// plainUpstream speaks DNS over UDP, TCP or TLS.
+type plainUpstream struct {
+	addr string
+	net  string // "udp", "tcp" or "tcp-tls"
+	tls  *tls.Config
+}
+
+func (u *plainUpstream) Exchange(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
+	client := &dns.Client{Net: u.net, TLSConfig: u.tls, Timeout: upstreamTimeout}
+	resp, _, err := client.ExchangeContext(ctx, req, u.addr)
+	if err != nil {
+		return nil, err
+	}
+	// Truncated UDP answers are retried over TCP
+	if u.net == "udp" && resp.Truncated {
+		client.Net = "tcp"
+		resp, _, err = client.ExchangeContext(ctx, req, u.addr)
+	}
+	return resp, err
+}
+
+func (u *plainUpstream) String() string {
+	switch u.net {
+	case "tcp-tls":
+		return "tls://" + u.addr
+	case "tcp":
+		return "tcp://" + u.addr
+	default:
+		return u.addr
+	}
+}
+
+// dohUpstream speaks DNS over HTTPS (RFC 8484) using POST requests.
+type dohUpstream struct {
+	url    string
+	client *http.Client
+}
+
+func (u *dohUpstream) Exchange(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
+	// RFC 8484 recommends ID 0 so responses are cacheable by HTTP caches
+	query := req.Copy()
+	query.Id = 0
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("pack query: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, u.url, bytes.NewReader(packed))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/dns-message")
+	httpReq.Header.Set("Accept", "application/dns-message")
+
+	httpResp, err := u.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = httpResp.Body.Close() }()
+
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DoH server returned %s", httpResp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(httpResp.Body, maxDoHResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("read DoH response: %w", err)
+	}
+
+	resp := new(dns.Msg)
+	if err := resp.Unpack(body); err != nil {
+		return nil, fmt.Errorf("unpack DoH response: %w", err)
+	}
+	resp.Id = req.Id
+	return resp, nil
+}
+
+func (u *dohUpstream) String() string {
+	return u.url
+}
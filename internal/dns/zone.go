@@ -0,0 +1,177 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/rs/zerolog/log"
+	"github.com/tunnelmesh/tunnelmesh/internal/mesh"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
+)
+
+// maxCNAMEChain bounds how many CNAMEs are followed while answering a query.
+const maxCNAMEChain = 8
+
+// SyncZone replaces the custom records managed through the coordinator.
+// Invalid records are skipped. Peer names and aliases take precedence over
+// custom records of the same name.
+func (r *Resolver) SyncZone(records []proto.DNSZoneRecord) {
+	zone := make(map[string][]dns.RR, len(records))
+	nodes := make(map[string]bool)
+	for i := range records {
+		rec := records[i]
+		if err := rec.Validate(); err != nil {
+			log.Warn().Err(err).Msg("skipping invalid DNS zone record")
+			continue
+		}
+		name := r.stripSuffix(strings.ToLower(rec.Name))
+		rrs, err := zoneRRs(name, &rec)
+		if err != nil {
+			log.Warn().Err(err).Msg("skipping invalid DNS zone record")
+			continue
+		}
+		zone[name] = append(zone[name], rrs...)
+
+		// Parent names exist (with no records) so they answer NODATA
+		for parent := name; strings.Contains(parent, "."); {
+			parent = parent[strings.Index(parent, ".")+1:]
+			nodes[parent] = true
+		}
+	}
+
+	r.mu.Lock()
+	r.zone = zone
+	r.zoneNodes = nodes
+	r.mu.Unlock()
+
+	log.Debug().
+		Int("records", len(records)).
+		Int("names", len(zone)).
+		Msg("DNS zone synced")
+}
+
+// zoneRRs converts a record set into resource records. TTLs are left at zero
+// when unset so the resolver's TTL applies.
+func zoneRRs(name string, rec *proto.DNSZoneRecord) ([]dns.RR, error) {
+	rrs := make([]dns.RR, 0, len(rec.Values))
+	for _, v := range rec.Values {
+		hdr := dns.RR_Header{Name: fqdn(name), Class: dns.ClassINET, Ttl: rec.TTL}
+		switch rec.Type {
+		case "A":
+			hdr.Rrtype = dns.TypeA
+			rrs = append(rrs, &dns.A{Hdr: hdr, A: net.ParseIP(v).To4()})
+		case "AAAA":
+			hdr.Rrtype = dns.TypeAAAA
+			rrs = append(rrs, &dns.AAAA{Hdr: hdr, AAAA: net.ParseIP(v)})
+		case "CNAME":
+			hdr.Rrtype = dns.TypeCNAME
+			rrs = append(rrs, &dns.CNAME{Hdr: hdr, Target: zoneTarget(v)})
+		case "SRV":
+			priority, weight, port, target, err := proto.ParseSRVValue(v)
+			if err != nil {
+				return nil, err
+			}
+			hdr.Rrtype = dns.TypeSRV
+			rrs = append(rrs, &dns.SRV{Hdr: hdr, Priority: priority, Weight: weight, Port: port, Target: zoneTarget(target)})
+		case "TXT":
+			hdr.Rrtype = dns.TypeTXT
+			rrs = append(rrs, &dns.TXT{Hdr: hdr, Txt: []string{v}})
+		}
+	}
+	return rrs, nil
+}
+
+// zoneTarget returns the fully qualified form of a CNAME or SRV target.
+// Names ending in "." are absolute; others are mesh names.
+func zoneTarget(target string) string {
+	target = strings.ToLower(target)
+	if strings.HasSuffix(target, ".") {
+		return target
+	}
+	for _, suffix := range mesh.AllSuffixes() {
+		if strings.HasSuffix(target, suffix) {
+			return fqdn(strings.TrimSuffix(target, suffix))
+		}
+	}
+	return fqdn(target)
+}
+
+// answerZone answers a question from the custom records. CNAMEs are followed
+// within the mesh zones, or through the forwarder for names outside them.
+func (r *Resolver) answerZone(q dns.Question, hostname string, depth int) (answer, extra []dns.RR, exists bool) {
+	r.mu.RLock()
+	rrs, ok := r.zone[hostname]
+	isNode := r.zoneNodes[hostname]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, nil, isNode
+	}
+
+	for _, rr := range rrs {
+		if cname, ok := rr.(*dns.CNAME); ok && q.Qtype != dns.TypeCNAME {
+			answer = append(answer, r.zoneRR(rr, q.Name))
+			chased, chasedExtra := r.chase(dns.Question{Name: cname.Target, Qtype: q.Qtype, Qclass: q.Qclass}, depth+1)
+			return append(answer, chased...), chasedExtra, true
+		}
+		if rr.Header().Rrtype == q.Qtype {
+			answer = append(answer, r.zoneRR(rr, q.Name))
+		}
+	}
+
+	switch q.Qtype {
+	case dns.TypeA, dns.TypeAAAA:
+		// Rotate so clients taking the first address spread over all of them
+		if n := len(answer); n > 1 {
+			k := int(r.rotation.Add(1) % uint32(n))
+			answer = append(answer[k:], answer[:k]...)
+		}
+	case dns.TypeSRV:
+		for _, rr := range answer {
+			target := rr.(*dns.SRV).Target
+			for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+				addrs, _ := r.chase(dns.Question{Name: target, Qtype: qtype, Qclass: dns.ClassINET}, maxCNAMEChain)
+				extra = append(extra, addrs...)
+			}
+		}
+	}
+	return answer, extra, true
+}
+
+// chase answers a question for a CNAME or SRV target. Mesh names are answered
+// locally; other names go through the forwarder, if any.
+func (r *Resolver) chase(q dns.Question, depth int) (answer, extra []dns.RR) {
+	if depth > maxCNAMEChain {
+		return nil, nil
+	}
+	if r.soa(q.Name) != nil {
+		answer, extra, _ = r.answer(q, depth)
+		return answer, extra
+	}
+	if r.forwarder == nil || depth >= maxCNAMEChain {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), forwardTimeout)
+	defer cancel()
+	req := new(dns.Msg)
+	req.SetQuestion(q.Name, q.Qtype)
+	resp, err := r.forwarder.Exchange(ctx, req)
+	if err != nil {
+		log.Debug().Err(err).Str("name", q.Name).Msg("DNS CNAME target lookup failed")
+		return nil, nil
+	}
+	return resp.Answer, nil
+}
+
+// zoneRR returns a copy of a custom record owned by name.
+func (r *Resolver) zoneRR(rr dns.RR, name string) dns.RR {
+	rr = dns.Copy(rr)
+	hdr := rr.Header()
+	hdr.Name = name
+	if hdr.Ttl == 0 {
+		hdr.Ttl = r.ttl
+	}
+	return rr
+}
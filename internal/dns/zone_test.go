@@ -0,0 +1,133 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
+)
+
+func newZoneResolver() *Resolver {
+	r := newSyncedResolver()
+	r.SyncZone([]proto.DNSZoneRecord{
+		{Name: "grafana.ops", Type: "A", Values: []string{"10.42.0.7"}, TTL: 30},
+		{Name: "pool", Type: "A", Values: []string{"10.42.0.5", "10.42.0.6", "10.42.0.7"}},
+		{Name: "dashboard", Type: "CNAME", Values: []string{"grafana.ops"}},
+		{Name: "app", Type: "CNAME", Values: []string{"web.tm"}},
+		{Name: "_metrics._tcp.ops", Type: "SRV", Values: []string{"10 5 9090 grafana.ops"}},
+		{Name: "ops", Type: "TXT", Values: []string{"owner=platform team"}},
+		{Name: "web", Type: "A", Values: []string{"10.42.0.99"}},   // Shadowed by the peer
+		{Name: "broken", Type: "A", Values: []string{"not-an-ip"}}, // Skipped
+		{Name: "external", Type: "CNAME", Values: []string{"example.com."}},
+	})
+	return r
+}
+
+func TestResolver_Zone_Address(t *testing.T) {
+	query := startTestResolver(t, newZoneResolver())
+
+	resp := query("grafana.ops.tunnelmesh.", dns.TypeA)
+	assert.Equal(t, "10.42.0.7", answerIP(t, resp))
+	assert.Equal(t, uint32(30), resp.Answer[0].Header().Ttl)
+
+	// Other suffixes resolve too, with the queried name as owner
+	resp = query("grafana.ops.tm.", dns.TypeA)
+	assert.Equal(t, "10.42.0.7", answerIP(t, resp))
+	assert.Equal(t, "grafana.ops.tm.", resp.Answer[0].Header().Name)
+
+	// Peer names win over custom records
+	resp = query("web.tunnelmesh.", dns.TypeA)
+	assert.Equal(t, "10.42.0.5", answerIP(t, resp))
+
+	resp = query("broken.tunnelmesh.", dns.TypeA)
+	assert.Equal(t, dns.RcodeNameError, resp.Rcode)
+
+	// A parent of a custom name exists without address records
+	resp = query("ops.tunnelmesh.", dns.TypeA)
+	assert.Equal(t, dns.RcodeSuccess, resp.Rcode)
+	assert.Empty(t, resp.Answer)
+}
+
+func TestResolver_Zone_RoundRobin(t *testing.T) {
+	query := startTestResolver(t, newZoneResolver())
+
+	firsts := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		resp := query("pool.tunnelmesh.", dns.TypeA)
+		require.Len(t, resp.Answer, 3)
+		firsts[resp.Answer[0].(*dns.A).A.String()] = true
+	}
+	assert.Len(t, firsts, 3)
+}
+
+func TestResolver_Zone_CNAME(t *testing.T) {
+	query := startTestResolver(t, newZoneResolver())
+
+	resp := query("dashboard.tunnelmesh.", dns.TypeA)
+	require.Len(t, resp.Answer, 2)
+	cname, ok := resp.Answer[0].(*dns.CNAME)
+	require.True(t, ok)
+	assert.Equal(t, "grafana.ops.tunnelmesh.", cname.Target)
+	assert.Equal(t, "10.42.0.7", resp.Answer[1].(*dns.A).A.String())
+
+	// Targets can be peer names under any mesh suffix
+	resp = query("app.tunnelmesh.", dns.TypeA)
+	require.Len(t, resp.Answer, 2)
+	assert.Equal(t, "web.tunnelmesh.", resp.Answer[0].(*dns.CNAME).Target)
+	assert.Equal(t, "10.42.0.5", resp.Answer[1].(*dns.A).A.String())
+
+	// Without a forwarder, external targets are left to the client
+	resp = query("external.tunnelmesh.", dns.TypeA)
+	require.Len(t, resp.Answer, 1)
+	assert.Equal(t, "example.com.", resp.Answer[0].(*dns.CNAME).Target)
+
+	resp = query("dashboard.tunnelmesh.", dns.TypeCNAME)
+	require.Len(t, resp.Answer, 1)
+}
+
+func TestResolver_Zone_CNAMEThroughForwarder(t *testing.T) {
+	upstream := startFakeUpstream(t, "93.184.216.34", dns.RcodeSuccess)
+	f, err := NewForwarder([]string{upstream.addr}, nil)
+	require.NoError(t, err)
+
+	r := newZoneResolver()
+	r.SetForwarder(f)
+	query := startTestResolver(t, r)
+
+	resp := query("external.tunnelmesh.", dns.TypeA)
+	require.Len(t, resp.Answer, 2)
+	assert.Equal(t, "93.184.216.34", resp.Answer[1].(*dns.A).A.String())
+}
+
+func TestResolver_Zone_SRVAndTXT(t *testing.T) {
+	query := startTestResolver(t, newZoneResolver())
+
+	resp := query("_metrics._tcp.ops.tunnelmesh.", dns.TypeSRV)
+	require.Len(t, resp.Answer, 1)
+	srv := resp.Answer[0].(*dns.SRV)
+	assert.Equal(t, uint16(10), srv.Priority)
+	assert.Equal(t, uint16(5), srv.Weight)
+	assert.Equal(t, uint16(9090), srv.Port)
+	assert.Equal(t, "grafana.ops.tunnelmesh.", srv.Target)
+	require.Len(t, resp.Extra, 1)
+	assert.Equal(t, "10.42.0.7", resp.Extra[0].(*dns.A).A.String())
+
+	// Peer services are still served
+	resp = query("_http._tcp.web.tunnelmesh.", dns.TypeSRV)
+	require.Len(t, resp.Answer, 1)
+
+	resp = query("ops.tunnelmesh.", dns.TypeTXT)
+	require.Len(t, resp.Answer, 1)
+	assert.Equal(t, []string{"owner=platform team"}, resp.Answer[0].(*dns.TXT).Txt)
+}
+
+func TestResolver_SyncZone_Replaces(t *testing.T) {
+	r := newZoneResolver()
+	r.SyncZone(nil)
+	query := startTestResolver(t, r)
+
+	resp := query("grafana.ops.tunnelmesh.", dns.TypeA)
+	assert.Equal(t, dns.RcodeNameError, resp.Rcode)
+}
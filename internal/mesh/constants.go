@@ -10,7 +10,8 @@ const (
 	AliasMesh = ".mesh" // Alternative alias
 
 	// Network configuration
-	CIDR = "10.42.0.0/16" // Mesh network CIDR - all peers get IPs from this range
+	CIDR     = "10.42.0.0/16"        // Mesh network CIDR - all peers get IPs from this range
+	IPv6CIDR = "fd42:6d65:7368::/64" // Mesh network IPv6 prefix (ULA) - see IPv6Address
 )
 
 // AllSuffixes returns all supported domain suffixes (canonical first).
@@ -0,0 +1,30 @@
+package mesh
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+)
+
+// ipv6Prefix is the network part of IPv6CIDR.
+var ipv6Prefix = net.IP{0xfd, 0x42, 0x6d, 0x65, 0x73, 0x68, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+
+// IPv6Address returns the mesh IPv6 address for a peer. The interface ID is
+// the peer ID (16 hex digits, the first 8 bytes of SHA256 of the peer's key),
+// so the address is stable for as long as the key is and needs no allocation.
+// Any other id (such as a peer name, for peers without a peer ID) is hashed.
+func IPv6Address(id string) string {
+	iid, err := hex.DecodeString(id)
+	if err != nil || len(iid) != 8 {
+		sum := sha256.Sum256([]byte(id))
+		iid = sum[:8]
+	}
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, ipv6Prefix[:8])
+	copy(ip[8:], iid)
+	// Keep clear of the all-zeros Subnet-Router anycast address
+	if ip.Equal(ipv6Prefix) {
+		ip[15] = 1
+	}
+	return ip.String()
+}
@@ -0,0 +1,25 @@
+package mesh
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIPv6Address(t *testing.T) {
+	_, prefix, err := net.ParseCIDR(IPv6CIDR)
+	assert.NoError(t, err)
+
+	// Peer IDs become the interface ID
+	assert.Equal(t, "fd42:6d65:7368:0:123:4567:89ab:cdef", IPv6Address("0123456789abcdef"))
+
+	// Anything else is hashed, deterministically
+	byName := IPv6Address("laptop")
+	assert.True(t, prefix.Contains(net.ParseIP(byName)))
+	assert.Equal(t, byName, IPv6Address("laptop"))
+	assert.NotEqual(t, byName, IPv6Address("server"))
+
+	// Never the Subnet-Router anycast address
+	assert.Equal(t, "fd42:6d65:7368::1", IPv6Address("0000000000000000"))
+}
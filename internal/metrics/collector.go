@@ -7,6 +7,7 @@ import (
 	"github.com/tunnelmesh/tunnelmesh/internal/peer"
 	"github.com/tunnelmesh/tunnelmesh/internal/peer/connection"
 	"github.com/tunnelmesh/tunnelmesh/internal/routing"
+	"github.com/tunnelmesh/tunnelmesh/internal/transport/udp"
 	"github.com/tunnelmesh/tunnelmesh/internal/tunnel"
 )
 
@@ -42,9 +43,11 @@ type Collector struct {
 	wgEnabled           bool
 	wgConcentrator      WGConcentrator
 	filter              FilterStatus
+	handshakes          HandshakeStatsProvider
 
-	// Last snapshot for delta calculation
-	lastForwarder ForwarderSnapshot
+	// Last snapshots for delta calculation
+	lastForwarder  ForwarderSnapshot
+	lastHandshakes udp.HandshakeStats
 }
 
 // ForwarderStats interface for getting forwarder statistics.
@@ -91,6 +94,12 @@ type FilterStatus interface {
 	RuleCountBySource() routing.RuleCounts
 }
 
+// HandshakeStatsProvider interface for getting UDP handshake DoS protection
+// statistics.
+type HandshakeStatsProvider interface {
+	HandshakeStats() udp.HandshakeStats
+}
+
 // CollectorConfig holds configuration for the collector.
 type CollectorConfig struct {
 	Forwarder           ForwarderStats
@@ -104,6 +113,7 @@ type CollectorConfig struct {
 	WGEnabled           bool
 	WGConcentrator      WGConcentrator
 	Filter              FilterStatus
+	Handshakes          HandshakeStatsProvider
 }
 
 // NewCollector creates a new metrics collector.
@@ -122,6 +132,7 @@ func NewCollector(m *PeerMetrics, cfg CollectorConfig) *Collector {
 		wgEnabled:           cfg.WGEnabled,
 		wgConcentrator:      cfg.WGConcentrator,
 		filter:              cfg.Filter,
+		handshakes:          cfg.Handshakes,
 	}
 }
 
@@ -136,6 +147,7 @@ func (c *Collector) Collect() {
 	c.collectWireGuardStats()
 	c.collectGeolocationStats()
 	c.collectFilterStats()
+	c.collectHandshakeStats()
 }
 
 func (c *Collector) collectForwarderStats() {
@@ -198,6 +210,24 @@ func (c *Collector) collectForwarderStats() {
 	}
 }
 
+func (c *Collector) collectHandshakeStats() {
+	if c.handshakes == nil {
+		return
+	}
+
+	stats := c.handshakes.HandshakeStats()
+	if stats.CookieReplies > c.lastHandshakes.CookieReplies {
+		c.metrics.CookieReplies.Add(float64(stats.CookieReplies - c.lastHandshakes.CookieReplies))
+	}
+	if stats.DroppedMAC1 > c.lastHandshakes.DroppedMAC1 {
+		c.metrics.HandshakesDropped.WithLabelValues("invalid_mac1").Add(float64(stats.DroppedMAC1 - c.lastHandshakes.DroppedMAC1))
+	}
+	if stats.DroppedUnderLoad > c.lastHandshakes.DroppedUnderLoad {
+		c.metrics.HandshakesDropped.WithLabelValues("under_load").Add(float64(stats.DroppedUnderLoad - c.lastHandshakes.DroppedUnderLoad))
+	}
+	c.lastHandshakes = stats
+}
+
 func (c *Collector) collectTunnelStats() {
 	if c.tunnelMgr == nil {
 		return
@@ -268,7 +298,9 @@ func (c *Collector) collectExitPeerStats() {
 		return
 	}
 
+	// The exit peer in use changes on failover
 	exitNode := c.forwarder.ExitPeer()
+	c.metrics.ExitPeerInfo.Reset()
 	if exitNode != "" {
 		c.metrics.ExitPeerConfigured.Set(1)
 		c.metrics.ExitPeerInfo.WithLabelValues(exitNode).Set(1)
@@ -435,6 +467,18 @@ func (c *Collector) FilterDropCallback() func(protocol uint8, sourcePeer string)
 	return c.TrackFilterDrop
 }
 
+// TrackPSKFailure increments the PSK handshake failure counter for the given
+// peer and udp.PSKFailure reason.
+func (c *Collector) TrackPSKFailure(targetPeer, reason string) {
+	c.metrics.PSKHandshakeFailures.WithLabelValues(targetPeer, reason).Inc()
+}
+
+// PSKFailureCallback returns a callback function for the UDP transport to call
+// on PSK handshake failures.
+func (c *Collector) PSKFailureCallback() func(targetPeer, reason string) {
+	return c.TrackPSKFailure
+}
+
 // ReconnectObserver returns a connection observer that tracks reconnects.
 func (c *Collector) ReconnectObserver() connection.Observer {
 	return connection.ObserverFunc(func(t connection.Transition) {
@@ -13,6 +13,7 @@ import (
 	"github.com/tunnelmesh/tunnelmesh/internal/peer"
 	"github.com/tunnelmesh/tunnelmesh/internal/peer/connection"
 	"github.com/tunnelmesh/tunnelmesh/internal/routing"
+	"github.com/tunnelmesh/tunnelmesh/internal/transport/udp"
 	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
 )
 
@@ -856,3 +857,73 @@ func TestCollector_CollectFilteredDrops(t *testing.T) {
 		}
 	}
 }
+
+func TestCollector_PSKFailureCallback(t *testing.T) {
+	Registry = prometheus.NewRegistry()
+
+	m := InitMetrics("test-peer", "10.42.0.1", "1.0.0")
+	c := NewCollector(m, CollectorConfig{})
+
+	track := c.PSKFailureCallback()
+	track("peer-a", "mismatch")
+	track("peer-a", "mismatch")
+	track("peer-b", "unknown_epoch")
+
+	mfs, err := Registry.Gather()
+	require.NoError(t, err)
+
+	got := make(map[string]float64)
+	for _, mf := range mfs {
+		if mf.GetName() != "tunnelmesh_psk_handshake_failures_total" {
+			continue
+		}
+		for _, metric := range mf.GetMetric() {
+			labels := make(map[string]string)
+			for _, l := range metric.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			got[labels["target_peer"]+"/"+labels["reason"]] = metric.GetCounter().GetValue()
+		}
+	}
+	assert.Equal(t, map[string]float64{"peer-a/mismatch": 2, "peer-b/unknown_epoch": 1}, got)
+}
+
+type mockHandshakeStats struct {
+	stats udp.HandshakeStats
+}
+
+func (m *mockHandshakeStats) HandshakeStats() udp.HandshakeStats {
+	return m.stats
+}
+
+func TestCollector_HandshakeStats(t *testing.T) {
+	Registry = prometheus.NewRegistry()
+
+	m := InitMetrics("test-peer", "10.42.0.1", "1.0.0")
+	handshakes := &mockHandshakeStats{stats: udp.HandshakeStats{CookieReplies: 3, DroppedMAC1: 5}}
+	c := NewCollector(m, CollectorConfig{Handshakes: handshakes})
+
+	c.Collect()
+	handshakes.stats = udp.HandshakeStats{CookieReplies: 4, DroppedMAC1: 5, DroppedUnderLoad: 2}
+	c.Collect()
+
+	mfs, err := Registry.Gather()
+	require.NoError(t, err)
+
+	got := make(map[string]float64)
+	for _, mf := range mfs {
+		switch mf.GetName() {
+		case "tunnelmesh_udp_cookie_replies_total":
+			got["cookie_replies"] = mf.GetMetric()[0].GetCounter().GetValue()
+		case "tunnelmesh_udp_handshakes_dropped_total":
+			for _, metric := range mf.GetMetric() {
+				for _, l := range metric.GetLabel() {
+					if l.GetName() == "reason" {
+						got[l.GetValue()] = metric.GetCounter().GetValue()
+					}
+				}
+			}
+		}
+	}
+	assert.Equal(t, map[string]float64{"cookie_replies": 4, "invalid_mac1": 5, "under_load": 2}, got)
+}
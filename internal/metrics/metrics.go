@@ -39,6 +39,13 @@ type PeerMetrics struct {
 	HealthyTunnels  prometheus.Gauge
 	ReconnectCount  *prometheus.CounterVec // per target peer
 
+	// UDP handshakes failing because of the pre-shared key
+	PSKHandshakeFailures *prometheus.CounterVec // labels: target_peer, reason
+
+	// UDP handshake DoS protection
+	CookieReplies     prometheus.Counter
+	HandshakesDropped *prometheus.CounterVec // labels: reason
+
 	// Relay metrics
 	RelayConnected prometheus.Gauge // 1 if connected, 0 if not
 
@@ -174,6 +181,23 @@ func InitMetrics(peerName, meshIP, version string) *PeerMetrics {
 			Help:        "Total reconnection attempts per peer",
 			ConstLabels: constLabels,
 		}, []string{"target_peer"}),
+		PSKHandshakeFailures: promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+			Name:        "tunnelmesh_psk_handshake_failures_total",
+			Help:        "UDP handshakes failing because of the pre-shared key (missing, unexpected, unknown_epoch, mismatch)",
+			ConstLabels: constLabels,
+		}, []string{"target_peer", "reason"}),
+
+		// UDP handshake DoS protection
+		CookieReplies: promauto.With(Registry).NewCounter(prometheus.CounterOpts{
+			Name:        "tunnelmesh_udp_cookie_replies_total",
+			Help:        "Cookie replies sent to UDP handshake initiators while under load",
+			ConstLabels: constLabels,
+		}),
+		HandshakesDropped: promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+			Name:        "tunnelmesh_udp_handshakes_dropped_total",
+			Help:        "UDP handshake initiations dropped before any Diffie-Hellman (invalid_mac1, under_load)",
+			ConstLabels: constLabels,
+		}, []string{"reason"}),
 
 		// Relay metrics
 		RelayConnected: promauto.With(Registry).NewGauge(prometheus.GaugeOpts{
@@ -0,0 +1,177 @@
+package peer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// certRenewCheckInterval is how often the certificate is checked for renewal.
+	certRenewCheckInterval = 10 * time.Minute
+	// certRenewRetry is how long to wait for a requested certificate before
+	// asking again.
+	certRenewRetry = time.Minute
+)
+
+// certRequester sends a certificate signing request to the coordination
+// server. Implemented by tunnel.PersistentRelay.
+type certRequester interface {
+	RequestCertRenewal(csrDER []byte) error
+}
+
+// CertRenewer keeps the peer's mesh TLS certificate fresh. When the
+// certificate nears expiry or is no longer signed by the CA's signing root,
+// it generates a new key and sends a signing request over the persistent
+// relay; the private key never leaves the peer.
+type CertRenewer struct {
+	tls       *TLSManager
+	onRenewed func(*tls.Certificate)
+
+	mu          sync.Mutex
+	pendingKey  *ecdsa.PrivateKey
+	requestedAt time.Time
+}
+
+// NewCertRenewer creates a certificate renewer storing through the given TLS manager.
+func NewCertRenewer(tlsMgr *TLSManager) *CertRenewer {
+	return &CertRenewer{tls: tlsMgr}
+}
+
+// OnRenewed sets a callback run after a renewed certificate is stored.
+// Must be called before the renewer is used.
+func (r *CertRenewer) OnRenewed(fn func(*tls.Certificate)) {
+	r.onRenewed = fn
+}
+
+// Check requests a new certificate if the current one needs renewal and no
+// request is already in flight.
+func (r *CertRenewer) Check(relay certRequester) error {
+	if !r.tls.NeedsRenewal(time.Now()) {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pendingKey != nil && time.Since(r.requestedAt) < certRenewRetry {
+		return nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("generate key: %w", err)
+	}
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{}, key)
+	if err != nil {
+		return fmt.Errorf("create csr: %w", err)
+	}
+	if err := relay.RequestCertRenewal(csrDER); err != nil {
+		return err
+	}
+
+	r.pendingKey = key
+	r.requestedAt = time.Now()
+	log.Info().Msg("requested TLS certificate renewal")
+	return nil
+}
+
+// HandleCertIssued stores a certificate sent in reply to a renewal request,
+// along with the trust bundle and CRL that came with it.
+func (r *CertRenewer) HandleCertIssued(certPEM, bundlePEM, crlDER []byte) {
+	if err := r.store(certPEM, bundlePEM, crlDER); err != nil {
+		log.Warn().Err(err).Msg("failed to store renewed TLS certificate")
+	}
+}
+
+func (r *CertRenewer) store(certPEM, bundlePEM, crlDER []byte) error {
+	r.mu.Lock()
+	key := r.pendingKey
+	r.mu.Unlock()
+	if key == nil {
+		return errors.New("no renewal pending")
+	}
+
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return errors.New("invalid certificate PEM")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("parse certificate: %w", err)
+	}
+	if !key.PublicKey.Equal(cert.PublicKey) {
+		return errors.New("certificate does not match the pending key")
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("marshal key: %w", err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	// Trust first, so the new certificate verifies against the roots in use
+	if err := r.tls.StoreTrust(bundlePEM, crlDER); err != nil {
+		return err
+	}
+	if err := r.tls.StoreCert(certPEM, keyPEM); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	if r.pendingKey == key {
+		r.pendingKey = nil
+	}
+	r.mu.Unlock()
+
+	log.Info().
+		Str("serial", cert.SerialNumber.Text(16)).
+		Time("expires", cert.NotAfter).
+		Msg("TLS certificate renewed")
+
+	if r.onRenewed != nil {
+		if tlsCert, err := r.tls.Certificate(); err == nil {
+			r.onRenewed(tlsCert)
+		}
+	}
+	return nil
+}
+
+// HandleTrustUpdate stores a trust bundle and CRL pushed by the coordination server.
+func (r *CertRenewer) HandleTrustUpdate(bundlePEM, crlDER []byte) {
+	if err := r.tls.StoreTrust(bundlePEM, crlDER); err != nil {
+		log.Warn().Err(err).Msg("failed to store CA trust update")
+	}
+}
+
+// RunCertRenewal periodically renews the mesh TLS certificate over the
+// persistent relay. Does nothing if the node has no certificate renewer.
+func (m *MeshNode) RunCertRenewal(ctx context.Context) {
+	if m.Certs == nil {
+		return
+	}
+
+	ticker := time.NewTicker(certRenewCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if relay := m.PersistentRelay; relay != nil {
+				if err := m.Certs.Check(relay); err != nil {
+					log.Debug().Err(err).Msg("TLS certificate renewal check failed")
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package peer
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tunnelmesh/tunnelmesh/internal/coord"
+)
+
+// fakeCertRequester records the signing requests sent to the coordinator.
+type fakeCertRequester struct {
+	csrs [][]byte
+}
+
+func (f *fakeCertRequester) RequestCertRenewal(csrDER []byte) error {
+	f.csrs = append(f.csrs, csrDER)
+	return nil
+}
+
+// newTestTLSManager returns a TLS manager holding a certificate and trust
+// bundle from the given CA.
+func newTestTLSManager(t *testing.T, ca *coord.CertificateAuthority) *TLSManager {
+	t.Helper()
+	mgr := NewTLSManager(t.TempDir())
+	certPEM, keyPEM, err := ca.GeneratePeerCert("laptop", "", "10.42.0.5")
+	require.NoError(t, err)
+	require.NoError(t, mgr.StoreCert(certPEM, keyPEM))
+	storeTestTrust(t, mgr, ca)
+	return mgr
+}
+
+func storeTestTrust(t *testing.T, mgr *TLSManager, ca *coord.CertificateAuthority) {
+	t.Helper()
+	crl, err := ca.CRL()
+	require.NoError(t, err)
+	require.NoError(t, mgr.StoreTrust(ca.TrustBundlePEM(), crl))
+}
+
+func TestTLSManager_NeedsRenewal(t *testing.T) {
+	ca, err := coord.NewCertificateAuthority(t.TempDir(), "")
+	require.NoError(t, err)
+	ca.SetCertLifetime(time.Hour)
+	mgr := newTestTLSManager(t, ca)
+
+	assert.False(t, mgr.NeedsRenewal(time.Now()))
+	assert.True(t, mgr.NeedsRenewal(time.Now().Add(45*time.Minute)), "less than a third of the lifetime left")
+
+	cert, err := mgr.Certificate()
+	require.NoError(t, err)
+	_, ok := ca.RevokeCert(cert.Leaf.SerialNumber.Text(16))
+	require.True(t, ok)
+	storeTestTrust(t, mgr, ca)
+	assert.True(t, mgr.NeedsRenewal(time.Now()), "revoked")
+}
+
+func TestTLSManager_VerifyConnection(t *testing.T) {
+	ca, err := coord.NewCertificateAuthority(t.TempDir(), "")
+	require.NoError(t, err)
+	mgr := newTestTLSManager(t, ca)
+
+	serverPEM, _, err := ca.GeneratePeerCert("server", "", "10.42.0.6")
+	require.NoError(t, err)
+	server := parseTestCert(t, serverPEM)
+	state := tls.ConnectionState{PeerCertificates: []*x509.Certificate{server}, ServerName: "server.tunnelmesh"}
+	require.NoError(t, mgr.VerifyConnection(state))
+
+	wrongName := state
+	wrongName.ServerName = "other.tunnelmesh"
+	assert.Error(t, mgr.VerifyConnection(wrongName))
+
+	_, ok := ca.RevokeCert(server.SerialNumber.Text(16))
+	require.True(t, ok)
+	storeTestTrust(t, mgr, ca)
+	assert.Error(t, mgr.VerifyConnection(state))
+
+	// The CRL survives a restart
+	reloaded := NewTLSManager(mgr.dataDir)
+	assert.Error(t, reloaded.VerifyConnection(state))
+
+	// A certificate from another CA is rejected
+	other, err := coord.NewCertificateAuthority(t.TempDir(), "")
+	require.NoError(t, err)
+	otherPEM, _, err := other.GeneratePeerCert("server", "", "10.42.0.6")
+	require.NoError(t, err)
+	assert.Error(t, mgr.VerifyConnection(tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{parseTestCert(t, otherPEM)},
+		ServerName:       "server.tunnelmesh",
+	}))
+}
+
+func TestTLSManager_StoreTrust_RejectsForeignCRL(t *testing.T) {
+	ca, err := coord.NewCertificateAuthority(t.TempDir(), "")
+	require.NoError(t, err)
+	other, err := coord.NewCertificateAuthority(t.TempDir(), "")
+	require.NoError(t, err)
+	mgr := NewTLSManager(t.TempDir())
+
+	crl, err := other.CRL()
+	require.NoError(t, err)
+	assert.Error(t, mgr.StoreTrust(ca.TrustBundlePEM(), crl))
+	assert.Error(t, mgr.StoreTrust([]byte("not pem"), crl))
+}
+
+func TestTLSManager_CRLAcrossRollover(t *testing.T) {
+	ca, err := coord.NewCertificateAuthority(t.TempDir(), "")
+	require.NoError(t, err)
+	mgr := newTestTLSManager(t, ca)
+	require.NoError(t, ca.Stage())
+	storeTestTrust(t, mgr, ca)
+	staleBundle := ca.TrustBundlePEM()
+
+	serverPEM, _, err := ca.GeneratePeerCert("server", "", "10.42.0.6")
+	require.NoError(t, err)
+	server := parseTestCert(t, serverPEM)
+	state := tls.ConnectionState{PeerCertificates: []*x509.Certificate{server}, ServerName: "server.tunnelmesh"}
+	require.NoError(t, mgr.VerifyConnection(state))
+
+	// After the promotion, the CRL is signed by the root that was staged,
+	// the second root of the bundle the peer still holds
+	require.NoError(t, ca.Promote(time.Hour))
+	_, ok := ca.RevokeCert(server.SerialNumber.Text(16))
+	require.True(t, ok)
+	crl, err := ca.CRL()
+	require.NoError(t, err)
+	require.NoError(t, mgr.StoreTrust(staleBundle, crl))
+	assert.Error(t, mgr.VerifyConnection(state))
+	assert.Error(t, NewTLSManager(mgr.dataDir).VerifyConnection(state), "revoked after a restart")
+}
+
+func TestTLSManager_InvalidCRLFailsClosed(t *testing.T) {
+	ca, err := coord.NewCertificateAuthority(t.TempDir(), "")
+	require.NoError(t, err)
+	mgr := newTestTLSManager(t, ca)
+
+	serverPEM, _, err := ca.GeneratePeerCert("server", "", "10.42.0.6")
+	require.NoError(t, err)
+	state := tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{parseTestCert(t, serverPEM)},
+		ServerName:       "server.tunnelmesh",
+	}
+	require.NoError(t, mgr.VerifyConnection(state))
+
+	// A CRL on disk that doesn't verify against the bundle no longer
+	// revokes nothing: connections are refused until a valid one arrives
+	other, err := coord.NewCertificateAuthority(t.TempDir(), "")
+	require.NoError(t, err)
+	foreign, err := other.CRL()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(mgr.CRLPath(), foreign, 0644))
+	reloaded := NewTLSManager(mgr.dataDir)
+	assert.Error(t, reloaded.VerifyConnection(state))
+
+	storeTestTrust(t, reloaded, ca)
+	assert.NoError(t, reloaded.VerifyConnection(state))
+}
+
+func TestCertRenewer_RenewsAfterRollover(t *testing.T) {
+	ca, err := coord.NewCertificateAuthority(t.TempDir(), "")
+	require.NoError(t, err)
+	mgr := newTestTLSManager(t, ca)
+	renewer := NewCertRenewer(mgr)
+	var renewed *tls.Certificate
+	renewer.OnRenewed(func(cert *tls.Certificate) { renewed = cert })
+	relay := &fakeCertRequester{}
+
+	// Nothing to do with a fresh certificate
+	require.NoError(t, renewer.Check(relay))
+	assert.Empty(t, relay.csrs)
+
+	// A certificate issued without a request is ignored
+	unsolicited, _, err := ca.GeneratePeerCert("laptop", "", "10.42.0.5")
+	require.NoError(t, err)
+	crl, err := ca.CRL()
+	require.NoError(t, err)
+	renewer.HandleCertIssued(unsolicited, ca.TrustBundlePEM(), crl)
+	assert.Nil(t, renewed)
+
+	// After a rollover the certificate is no longer signed by the signing root
+	require.NoError(t, ca.Stage())
+	require.NoError(t, ca.Promote(time.Hour))
+	crl, err = ca.CRL()
+	require.NoError(t, err)
+	renewer.HandleTrustUpdate(ca.TrustBundlePEM(), crl)
+	require.NoError(t, renewer.Check(relay))
+	require.Len(t, relay.csrs, 1)
+	require.NoError(t, renewer.Check(relay))
+	assert.Len(t, relay.csrs, 1, "one request in flight at a time")
+
+	certPEM, err := ca.SignPeerCSR("laptop", "10.42.0.5", relay.csrs[0])
+	require.NoError(t, err)
+	renewer.HandleCertIssued(certPEM, ca.TrustBundlePEM(), crl)
+
+	require.NotNil(t, renewed)
+	assert.Equal(t, parseTestCert(t, certPEM).SerialNumber, renewed.Leaf.SerialNumber)
+	assert.False(t, mgr.NeedsRenewal(time.Now()))
+
+	// Stored on disk with its new key
+	loaded, err := NewTLSManager(mgr.dataDir).LoadCert()
+	require.NoError(t, err)
+	assert.Equal(t, renewed.Leaf.SerialNumber, loaded.Leaf.SerialNumber)
+}
+
+func parseTestCert(t *testing.T, certPEM []byte) *x509.Certificate {
+	t.Helper()
+	certs := parseCertsPEM(certPEM)
+	require.Len(t, certs, 1)
+	return certs[0]
+}
@@ -99,8 +99,11 @@ func (m *MeshNode) DiscoverAndConnectPeers(ctx context.Context) {
 			}
 		}
 
-		// Collect route for atomic update
+		// Collect routes for atomic update
 		routes[peer.MeshIP] = peer.Name
+		if peer.MeshIPv6 != "" {
+			routes[peer.MeshIPv6] = peer.Name
+		}
 		// Cache full peer info for use when coord server is unreachable
 		m.CachePeer(peer)
 
@@ -121,6 +124,11 @@ func (m *MeshNode) DiscoverAndConnectPeers(ctx context.Context) {
 
 	// Atomically update all routes - this adds new peers and removes stale ones
 	m.router.UpdateRoutes(routes)
+	m.updateSubnetRoutes(peers)
+	m.updateFilterGroups(peers)
+	m.updateRelayPaths(peers)
+	m.updateTags(peers)
+	m.checkExitPeers()
 }
 
 // shouldInitiateConnection determines if we should be the initiator for a connection
@@ -267,6 +275,9 @@ func (m *MeshNode) buildTransportPeerInfo(peer proto.Peer) *transport.PeerInfo {
 		PrivateIPs:       peer.PrivateIPs,
 		SSHPort:          peer.SSHPort,
 		UDPPort:          peer.UDPPort,
+		QUICPort:         peer.QUICPort,
+		TLSPort:          peer.TLSPort,
+		TLSServerName:    peer.TLSServerName,
 		Connectable:      peer.Connectable,
 		BehindNAT:        !peer.Connectable,
 		PublicKey:        peer.PublicKey,
@@ -352,10 +363,15 @@ func (m *MeshNode) RefreshAuthorizedKeys() {
 			}
 		}
 		routes[peer.MeshIP] = peer.Name
+		if peer.MeshIPv6 != "" {
+			routes[peer.MeshIPv6] = peer.Name
+		}
 		m.CachePeer(peer)
 	}
 
 	// Atomically update all routes
 	m.router.UpdateRoutes(routes)
+	m.updateSubnetRoutes(peers)
+	m.updateFilterGroups(peers)
 	log.Debug().Int("peers", len(peers)).Msg("refreshed authorized keys and routes")
 }
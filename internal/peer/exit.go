@@ -0,0 +1,82 @@
+package peer
+
+import (
+	"context"
+	"time"
+
+	"github.com/tunnelmesh/tunnelmesh/internal/routing"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
+)
+
+const (
+	// exitHealthCheckInterval is how often the exit peers' health is checked.
+	exitHealthCheckInterval = 10 * time.Second
+	// exitPeerOnlineThreshold is how recently a peer must have sent a
+	// heartbeat to the coordinator to be used as exit peer.
+	exitPeerOnlineThreshold = 2 * time.Minute
+)
+
+// RunExitHealthCheck periodically checks which peers can serve as exit
+// peers, so external traffic fails over when the exit peer in use goes away.
+func (m *MeshNode) RunExitHealthCheck(ctx context.Context) {
+	if m.Forwarder == nil || !m.Forwarder.Exits().Configured() {
+		return
+	}
+
+	ticker := time.NewTicker(exitHealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.checkExitPeers()
+		}
+	}
+}
+
+// checkExitPeers hands the forwarder's exit selector what is known about
+// the mesh peers: whether they accept exit traffic from this peer's tags,
+// whether they are online and reachable over a tunnel or the relay, and
+// where they are located.
+func (m *MeshNode) checkExitPeers() {
+	if m.Forwarder == nil || !m.Forwarder.Exits().Configured() {
+		return
+	}
+
+	relayUp := m.PersistentRelay != nil && m.PersistentRelay.IsConnected()
+	tags := m.Tags()
+	now := time.Now()
+
+	m.peerCacheMu.RLock()
+	candidates := make(map[string]routing.ExitCandidate, len(m.peerCache))
+	for name, peer := range m.peerCache {
+		_, hasTunnel := m.tunnelMgr.Get(name)
+		candidate := routing.ExitCandidate{
+			AllowsExit: peer.AllowsExitTraffic && (len(peer.ExitAllowTags) == 0 || proto.HasAnyTag(tags, peer.ExitAllowTags)),
+			Reachable:  now.Sub(peer.LastSeen) < exitPeerOnlineThreshold && (hasTunnel || relayUp),
+		}
+		if peer.Location != nil {
+			candidate.Country = peer.Location.Country
+		}
+		candidates[name] = candidate
+	}
+	m.peerCacheMu.RUnlock()
+
+	m.Forwarder.Exits().UpdateCandidates(candidates)
+}
+
+// addExitStats reports the exit peers in use in a heartbeat, when exit
+// peers or policies are configured.
+func (m *MeshNode) addExitStats(stats *proto.PeerStats) {
+	if m.Forwarder == nil || !m.Forwarder.Exits().Configured() {
+		return
+	}
+	status := m.Forwarder.Exits().Status()
+	stats.ExitPeer = status.Active
+	for _, peer := range status.Peers {
+		stats.ExitPeers = append(stats.ExitPeers, peer.Name)
+	}
+	stats.ExitRoutes = status.Policies
+}
@@ -0,0 +1,47 @@
+package peer
+
+import (
+	"net/netip"
+
+	"github.com/tunnelmesh/tunnelmesh/internal/routing"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
+)
+
+// updateFilterGroups hands the RBAC groups served with the peer list to the
+// packet filter, for rules selecting source or destination peers by group.
+// Tags count as groups, so rules can also select peers and WireGuard clients
+// by tag.
+func (m *MeshNode) updateFilterGroups(peers []proto.Peer) {
+	if m.Forwarder == nil {
+		return
+	}
+	filter := m.Forwarder.Filter()
+	if filter == nil {
+		return
+	}
+
+	var local []string
+	groups := make(map[string][]string, len(peers))
+	clients := make(map[netip.Addr]routing.ClientGroups)
+	for _, peer := range peers {
+		for ip, tags := range peer.ClientTags {
+			if addr, err := netip.ParseAddr(ip); err == nil && len(tags) > 0 {
+				clients[addr] = routing.ClientGroups{Peer: peer.Name, Groups: tags}
+			}
+		}
+
+		peerGroups := peer.Groups
+		if len(peer.Tags) > 0 {
+			peerGroups = append(append([]string(nil), peer.Groups...), peer.Tags...)
+		}
+		if peer.Name == m.identity.Name {
+			local = peerGroups
+			continue
+		}
+		if len(peerGroups) > 0 {
+			groups[peer.Name] = peerGroups
+		}
+	}
+	filter.SetGroups(groups, local)
+	filter.SetClientGroups(clients)
+}
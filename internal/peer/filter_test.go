@@ -0,0 +1,82 @@
+package peer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tunnelmesh/tunnelmesh/internal/config"
+	"github.com/tunnelmesh/tunnelmesh/internal/coord"
+	"github.com/tunnelmesh/tunnelmesh/internal/routing"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
+)
+
+func TestMeshNode_updateFilterGroups(t *testing.T) {
+	identity := &PeerIdentity{
+		Name:   "test-node",
+		Config: &config.PeerConfig{Name: "test-node"},
+	}
+	node := NewMeshNode(identity, coord.NewClient("http://localhost:8080", "test-token"))
+	node.Forwarder = routing.NewForwarder(node.router, nil)
+
+	// No filter set yet
+	node.updateFilterGroups(nil)
+
+	filter := routing.NewPacketFilter(true)
+	filter.SetPeerConfigRules([]routing.FilterRule{
+		{Port: 22, Protocol: routing.ProtoTCP, Action: routing.ActionAllow, SourceGroup: "ops", DestGroup: "prod"},
+	})
+	node.Forwarder.SetFilter(filter)
+
+	node.updateFilterGroups([]proto.Peer{
+		{Name: "alice", Groups: []string{"ops"}},
+		{Name: "bob", Groups: []string{"dev"}},
+		{Name: "test-node", Groups: []string{"prod"}}, // Self
+	})
+
+	// IPv4/TCP SYN to port 22
+	packet := make([]byte, 40)
+	packet[0] = 0x45
+	packet[9] = routing.ProtoTCP
+	packet[22], packet[23] = 0, 22
+	packet[33] = 0x02
+
+	assert.False(t, filter.ShouldDropFromPeer(packet, "alice"))
+	assert.True(t, filter.ShouldDropFromPeer(packet, "bob"))
+
+	// Leaving group prod disables the rule
+	node.updateFilterGroups([]proto.Peer{{Name: "alice", Groups: []string{"ops"}}})
+	assert.True(t, filter.ShouldDropFromPeer(packet, "alice"))
+}
+
+func TestMeshNode_updateFilterGroupsTags(t *testing.T) {
+	identity := &PeerIdentity{
+		Name:   "test-node",
+		Config: &config.PeerConfig{Name: "test-node"},
+	}
+	node := NewMeshNode(identity, coord.NewClient("http://localhost:8080", "test-token"))
+	node.Forwarder = routing.NewForwarder(node.router, nil)
+
+	filter := routing.NewPacketFilter(true)
+	filter.SetPeerConfigRules([]routing.FilterRule{
+		{Port: 22, Protocol: routing.ProtoTCP, Action: routing.ActionAllow, SourceGroup: "tag:ops", DestGroup: "tag:prod"},
+	})
+	node.Forwarder.SetFilter(filter)
+
+	node.updateFilterGroups([]proto.Peer{
+		{Name: "alice", Tags: []string{"tag:ops"}},
+		{Name: "concentrator", ClientTags: map[string][]string{"10.42.100.2": {"tag:ops"}}},
+		{Name: "test-node", Tags: []string{"tag:prod"}}, // Self
+	})
+
+	// IPv4/TCP SYN to port 22 from 10.42.100.2
+	packet := make([]byte, 40)
+	packet[0] = 0x45
+	packet[9] = routing.ProtoTCP
+	copy(packet[12:16], []byte{10, 42, 100, 2})
+	packet[22], packet[23] = 0, 22
+	packet[33] = 0x02
+
+	assert.False(t, filter.ShouldDropFromPeer(packet, "alice"))
+	assert.False(t, filter.ShouldDropFromPeer(packet, "concentrator"), "tagged WireGuard client")
+	assert.True(t, filter.ShouldDropFromPeer(packet, "bob"))
+}
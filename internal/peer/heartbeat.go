@@ -56,14 +56,7 @@ func (m *MeshNode) PerformHeartbeat(ctx context.Context) {
 			m.HandleIPChange(publicIPs, privateIPs, behindNAT)
 
 			// Re-register with server (pass nil location to retain existing)
-			hasMonitoring := m.identity.Config.Coordinator.Enabled &&
-				(m.identity.Config.Coordinator.Monitoring.PrometheusURL != "" || m.identity.Config.Coordinator.Monitoring.GrafanaURL != "")
-			if _, err := m.client.Register(
-				m.identity.Name, m.identity.PubKeyEncoded,
-				publicIPs, privateIPs, m.identity.SSHPort, m.identity.UDPPort, behindNAT, m.identity.Version, nil,
-				m.identity.Config.ExitPeer, m.identity.Config.AllowExitTraffic, m.identity.Config.DNS.Aliases,
-				m.identity.Config.Coordinator.Enabled, hasMonitoring,
-			); err != nil {
+			if _, err := m.client.Register(m.identity.RegisterRequest(publicIPs, privateIPs, behindNAT)); err != nil {
 				log.Error().Err(err).Msg("failed to re-register after IP change")
 			} else {
 				log.Info().Msg("re-registered with new IP addresses")
@@ -74,6 +67,11 @@ func (m *MeshNode) PerformHeartbeat(ctx context.Context) {
 		}
 	}
 
+	// Renew the coordination server token before it expires
+	if err := m.client.RenewToken(); err != nil {
+		log.Warn().Err(err).Msg("failed to renew coordination server token")
+	}
+
 	// Collect stats
 	stats := m.CollectStats()
 
@@ -144,6 +142,9 @@ func (m *MeshNode) HandleRelayRequests(ctx context.Context, relayRequests []stri
 		// Ensure route exists for this peer so forwarder can route packets via relay
 		if peer, ok := m.GetCachedPeer(peerName); ok && peer.MeshIP != "" {
 			m.router.AddRoute(peer.MeshIP, peerName)
+			if peer.MeshIPv6 != "" {
+				m.router.AddRoute(peer.MeshIPv6, peerName)
+			}
 		}
 	}
 }
@@ -154,18 +155,14 @@ func (m *MeshNode) syncDNS() {
 		return
 	}
 
-	records, err := m.client.GetDNSRecords()
+	update, err := m.client.GetDNS()
 	if err != nil {
 		log.Warn().Err(err).Msg("DNS sync failed")
 		return
 	}
 
-	recordMap := make(map[string]string, len(records))
-	for _, r := range records {
-		recordMap[r.Hostname] = r.MeshIP
-	}
-
-	m.Resolver.UpdateRecords(recordMap)
+	m.Resolver.SyncRecords(update.Records)
+	m.Resolver.SyncZone(update.Zone)
 }
 
 // CheckAndHandleRelayRequests polls for relay requests and handles them.
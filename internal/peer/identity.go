@@ -16,6 +16,7 @@ type PeerIdentity struct {
 	UDPPort       int
 	MeshCIDR      string
 	MeshIP        string
+	MeshIPv6      string // Empty if the coordinator does not assign IPv6 addresses
 	Domain        string
 	Version       string
 	Config        *config.PeerConfig
@@ -37,6 +38,7 @@ func NewPeerIdentity(cfg *config.PeerConfig, pubKeyEncoded string, udpPort int,
 		UDPPort:       udpPort,
 		MeshCIDR:      resp.MeshCIDR,
 		MeshIP:        resp.MeshIP,
+		MeshIPv6:      resp.MeshIPv6,
 		Domain:        resp.Domain,
 		Version:       version,
 		Config:        cfg,
@@ -47,3 +49,48 @@ func NewPeerIdentity(cfg *config.PeerConfig, pubKeyEncoded string, udpPort int,
 func (p *PeerIdentity) GetLocalIPs() (publicIPs, privateIPs []string, behindNAT bool) {
 	return proto.GetLocalIPsExcluding(p.MeshCIDR)
 }
+
+// RegisterRequest builds the request to register this peer again with the
+// given local addresses. The location is left out to retain the existing one.
+func (p *PeerIdentity) RegisterRequest(publicIPs, privateIPs []string, behindNAT bool) proto.RegisterRequest {
+	cfg := p.Config
+	return proto.RegisterRequest{
+		Name:              p.Name,
+		PublicKey:         p.PubKeyEncoded,
+		PublicIPs:         publicIPs,
+		PrivateIPs:        privateIPs,
+		SSHPort:           p.SSHPort,
+		UDPPort:           p.UDPPort,
+		BehindNAT:         behindNAT,
+		Version:           p.Version,
+		ExitPeer:          cfg.ExitPeer,
+		AllowsExitTraffic: cfg.AllowExitTraffic,
+		Aliases:           cfg.DNS.Aliases,
+		Services:          p.Services(),
+		Routes:            cfg.AdvertiseRoutes,
+		IsCoordinator:     cfg.Coordinator.Enabled,
+		HasMonitoring: cfg.Coordinator.Enabled &&
+			(cfg.Coordinator.Monitoring.PrometheusURL != "" || cfg.Coordinator.Monitoring.GrafanaURL != ""),
+	}
+}
+
+// Services returns the services this peer advertises as DNS SRV records.
+func (p *PeerIdentity) Services() []proto.Service {
+	return AdvertisedServices(p.Config)
+}
+
+// AdvertisedServices converts the configured DNS services to their protocol form.
+func AdvertisedServices(cfg *config.PeerConfig) []proto.Service {
+	if cfg == nil || len(cfg.DNS.Services) == 0 {
+		return nil
+	}
+	services := make([]proto.Service, 0, len(cfg.DNS.Services))
+	for _, svc := range cfg.DNS.Services {
+		protocol := svc.Protocol
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		services = append(services, proto.Service{Name: svc.Name, Protocol: protocol, Port: svc.Port})
+	}
+	return services
+}
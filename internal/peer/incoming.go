@@ -39,16 +39,19 @@ func (m *MeshNode) handleIncomingConnection(ctx context.Context, conn transport.
 	}
 
 	// Get cached mesh IP for FSM tracking and routing
-	var meshIP string
+	var meshIP, meshIPv6 string
 	if peer, ok := m.GetCachedPeer(peerName); ok {
-		meshIP = peer.MeshIP
+		meshIP, meshIPv6 = peer.MeshIP, peer.MeshIPv6
 	}
 
-	// Add route immediately for bidirectional traffic
+	// Add routes immediately for bidirectional traffic
 	// Discovery will refresh/validate routes on next cycle
 	if meshIP != "" {
 		m.router.AddRoute(meshIP, peerName)
 	}
+	if meshIPv6 != "" {
+		m.router.AddRoute(meshIPv6, peerName)
+	}
 
 	// Wrap connection as a tunnel
 	tun := tunnel.NewTunnelFromTransport(conn)
@@ -49,14 +49,7 @@ func (m *MeshNode) HandleNetworkChange(event netmon.Event) {
 	m.client.CloseIdleConnections()
 
 	// Re-register with coordination server (pass nil location to retain existing)
-	hasMonitoring := m.identity.Config.Coordinator.Enabled &&
-		(m.identity.Config.Coordinator.Monitoring.PrometheusURL != "" || m.identity.Config.Coordinator.Monitoring.GrafanaURL != "")
-	resp, err := m.client.Register(
-		m.identity.Name, m.identity.PubKeyEncoded,
-		publicIPs, privateIPs, m.identity.SSHPort, m.identity.UDPPort, behindNAT, m.identity.Version, nil,
-		m.identity.Config.ExitPeer, m.identity.Config.AllowExitTraffic, m.identity.Config.DNS.Aliases,
-		m.identity.Config.Coordinator.Enabled, hasMonitoring,
-	)
+	resp, err := m.client.Register(m.identity.RegisterRequest(publicIPs, privateIPs, behindNAT))
 	if err != nil {
 		log.Error().Err(err).Msg("failed to re-register after network change")
 		// Still disconnect all peers and record change even if re-register fails
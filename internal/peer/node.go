@@ -43,6 +43,9 @@ type MeshNode struct {
 	TransportNegotiator *transport.Negotiator
 	SSHTransport        *sshtransport.Transport // For incoming SSH and key management
 	UDPTransport        *udptransport.Transport // For crossing handshake pre-registration
+	QUICPort            int                     // Port the QUIC transport listens on (0 if disabled)
+	TLSPort             int                     // Port the TLS transport listens on (0 if disabled)
+	TLSServerName       string                  // Web site the TLS transport port serves
 
 	// Persistent relay for DERP-like instant connectivity
 	PersistentRelay *tunnel.PersistentRelay
@@ -52,6 +55,12 @@ type MeshNode struct {
 	router    *routing.Router
 	Forwarder *routing.Forwarder
 
+	// Subnet routes advertised by other peers, installed in the OS routing
+	// table on TUNName (empty leaves the OS routing table alone)
+	TUNName         string
+	subnetRoutesMu  sync.Mutex
+	installedRoutes map[string]bool
+
 	// Connection lifecycle management (FSM-based)
 	Connections *connection.LifecycleManager
 
@@ -77,6 +86,21 @@ type MeshNode struct {
 
 	// Latency measurement
 	LatencyProber *LatencyProber
+
+	// Mesh TLS certificate renewal (nil without a mesh certificate)
+	Certs *CertRenewer
+
+	// Tags of this peer, as served by the coordinator
+	tagsMu sync.RWMutex
+	tags   []string
+
+	// Handshake pre-shared keys last pushed by the coordinator
+	pskMu     sync.Mutex
+	pskUpdate *proto.PSKUpdate
+
+	// ClientTags returns the tags of the WireGuard clients served by this
+	// peer by mesh IP (nil without a WireGuard concentrator)
+	ClientTags func() map[string][]string
 }
 
 // NewMeshNode creates a new MeshNode with the given identity and client.
@@ -91,6 +115,7 @@ func NewMeshNode(identity *PeerIdentity, client *coord.Client) *MeshNode {
 		router:           router,
 		triggerDiscovery: make(chan struct{}, 1),
 		peerCache:        make(map[string]proto.Peer),
+		installedRoutes:  make(map[string]bool),
 	}
 
 	// Initialize connection lifecycle manager with tunnel integration
@@ -243,6 +268,17 @@ func (m *MeshNode) CollectStats() *proto.PeerStats {
 		stats.DroppedNoTunnel = fwdStats.DroppedNoTunnel
 		stats.Errors = fwdStats.Errors
 	}
+	m.addExitStats(stats)
+	m.addRelayStats(stats)
+	m.addTagStats(stats)
+	stats.QUICPort = m.QUICPort
+	stats.TLSPort = m.TLSPort
+	stats.TLSServerName = m.TLSServerName
+	if m.UDPTransport != nil {
+		if mtus := m.UDPTransport.PathMTUs(); len(mtus) > 0 {
+			stats.PathMTUs = mtus
+		}
+	}
 
 	// Include coordinator RTT from last heartbeat ack
 	if m.PersistentRelay != nil {
@@ -435,14 +471,7 @@ func (m *MeshNode) setupRelayHandlers(relay *tunnel.PersistentRelay) {
 		if errors.Is(err, coord.ErrPeerNotFound) {
 			log.Info().Msg("peer not registered on server, re-registering...")
 			publicIPs, privateIPs, behindNAT := m.identity.GetLocalIPs()
-			hasMonitoring := m.identity.Config.Coordinator.Enabled &&
-				(m.identity.Config.Coordinator.Monitoring.PrometheusURL != "" || m.identity.Config.Coordinator.Monitoring.GrafanaURL != "")
-			if _, regErr := m.client.Register(
-				m.identity.Name, m.identity.PubKeyEncoded,
-				publicIPs, privateIPs, m.identity.SSHPort, m.identity.UDPPort, behindNAT, m.identity.Version, nil,
-				m.identity.Config.ExitPeer, m.identity.Config.AllowExitTraffic, m.identity.Config.DNS.Aliases,
-				m.identity.Config.Coordinator.Enabled, hasMonitoring,
-			); regErr != nil {
+			if _, regErr := m.client.Register(m.identity.RegisterRequest(publicIPs, privateIPs, behindNAT)); regErr != nil {
 				log.Error().Err(regErr).Msg("failed to re-register after peer not found")
 			} else {
 				log.Info().Msg("re-registered with coordination server")
@@ -451,6 +480,22 @@ func (m *MeshNode) setupRelayHandlers(relay *tunnel.PersistentRelay) {
 		}
 	})
 
+	// Certificate renewals and CA trust updates arrive over the relay.
+	// The server pushes the trust bundle on connect, which also triggers
+	// renewal after a CA rollover.
+	if m.Certs != nil {
+		relay.SetCertIssuedHandler(m.Certs.HandleCertIssued)
+		relay.SetTrustUpdateHandler(func(bundlePEM, crlDER []byte) {
+			m.Certs.HandleTrustUpdate(bundlePEM, crlDER)
+			if err := m.Certs.Check(relay); err != nil {
+				log.Debug().Err(err).Msg("TLS certificate renewal check failed")
+			}
+		})
+	}
+
+	// UDP handshake pre-shared keys are pushed on connect and at every rotation
+	relay.SetPSKUpdateHandler(m.HandlePSKUpdate)
+
 	// Set up push notification handlers for relay and hole-punch requests.
 	// These must be set here (not just in RunHeartbeat) to ensure they're
 	// re-registered after relay reconnection.
@@ -0,0 +1,54 @@
+package peer
+
+import (
+	"github.com/rs/zerolog/log"
+	udptransport "github.com/tunnelmesh/tunnelmesh/internal/transport/udp"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
+)
+
+// HandlePSKUpdate installs the UDP handshake pre-shared keys pushed by the
+// coordination server. Keys arriving before the UDP transport is set up are
+// installed once it is.
+func (m *MeshNode) HandlePSKUpdate(update proto.PSKUpdate) {
+	m.pskMu.Lock()
+	m.pskUpdate = &update
+	m.pskMu.Unlock()
+	m.applyPSKs()
+}
+
+// applyPSKs installs the last pushed pre-shared keys in the UDP transport.
+func (m *MeshNode) applyPSKs() {
+	m.pskMu.Lock()
+	defer m.pskMu.Unlock()
+	if m.pskUpdate == nil || m.UDPTransport == nil {
+		return
+	}
+	sets := make([]udptransport.PSKSet, 0, len(m.pskUpdate.Keys))
+	for _, keys := range m.pskUpdate.Keys {
+		set := udptransport.PSKSet{Epoch: keys.Epoch}
+		if len(keys.Mesh) != len(set.Mesh) {
+			log.Warn().Uint32("epoch", keys.Epoch).Msg("ignoring PSK update with an invalid mesh key")
+			return
+		}
+		copy(set.Mesh[:], keys.Mesh)
+		for peerName, key := range keys.Pairs {
+			var pair [32]byte
+			if len(key) != len(pair) {
+				log.Warn().Uint32("epoch", keys.Epoch).Str("peer", peerName).Msg("ignoring PSK update with an invalid pair key")
+				return
+			}
+			copy(pair[:], key)
+			if set.Pairs == nil {
+				set.Pairs = make(map[string][32]byte)
+			}
+			set.Pairs[peerName] = pair
+		}
+		sets = append(sets, set)
+	}
+	m.UDPTransport.SetPSKs(m.pskUpdate.Current, sets)
+	if len(sets) == 0 {
+		log.Debug().Msg("UDP handshake PSKs disabled")
+	} else {
+		log.Debug().Uint32("epoch", m.pskUpdate.Current).Msg("UDP handshake PSKs updated")
+	}
+}
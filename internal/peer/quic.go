@@ -0,0 +1,24 @@
+package peer
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tunnelmesh/tunnelmesh/internal/transport"
+)
+
+// HandleIncomingQUIC accepts incoming QUIC connections from the listener.
+func (m *MeshNode) HandleIncomingQUIC(ctx context.Context, listener transport.Listener) {
+	for {
+		conn, err := listener.Accept(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Error().Err(err).Msg("QUIC accept error")
+			continue
+		}
+
+		go m.handleIncomingConnection(ctx, conn, "QUIC")
+	}
+}
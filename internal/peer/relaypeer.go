@@ -0,0 +1,44 @@
+package peer
+
+import (
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
+)
+
+// addRelayStats reports the peers this peer has tunnels to, and its RTT to
+// them, when it allows relaying. Other peers use them to pick relay peers.
+func (m *MeshNode) addRelayStats(stats *proto.PeerStats) {
+	if !m.identity.Config.AllowRelay {
+		return
+	}
+
+	var latencies map[string]int64
+	if m.LatencyProber != nil {
+		latencies = m.LatencyProber.GetLatencies()
+	}
+	healthy := m.tunnelMgr.ListHealthy()
+	stats.RelayTo = make(map[string]int64, len(healthy))
+	for _, name := range healthy {
+		stats.RelayTo[name] = latencies[name]
+	}
+}
+
+// updateRelayPaths recomputes the relay peers used to reach peers there is
+// no direct tunnel to, from the peers the relay peers report tunnels to.
+func (m *MeshNode) updateRelayPaths(peers []proto.Peer) {
+	if m.Forwarder == nil {
+		return
+	}
+
+	relays := make(map[string]map[string]int64)
+	for _, peer := range peers {
+		if len(peer.RelayTo) > 0 {
+			relays[peer.Name] = peer.RelayTo
+		}
+	}
+
+	var local map[string]int64
+	if m.LatencyProber != nil {
+		local = m.LatencyProber.GetLatencies()
+	}
+	m.Forwarder.RelayPaths().Update(m.identity.Name, relays, local)
+}
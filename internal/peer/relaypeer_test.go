@@ -0,0 +1,44 @@
+package peer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tunnelmesh/tunnelmesh/internal/config"
+	"github.com/tunnelmesh/tunnelmesh/internal/coord"
+	"github.com/tunnelmesh/tunnelmesh/internal/routing"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
+)
+
+func TestMeshNode_addRelayStats(t *testing.T) {
+	identity := &PeerIdentity{
+		Name:   "test-node",
+		Config: &config.PeerConfig{Name: "test-node"},
+	}
+	node := NewMeshNode(identity, coord.NewClient("http://localhost:8080", "test-token"))
+	node.tunnelMgr.Add("peer1", &mockTunnel{})
+
+	stats := &proto.PeerStats{}
+	node.addRelayStats(stats)
+	assert.Nil(t, stats.RelayTo, "not a relay peer")
+
+	identity.Config.AllowRelay = true
+	node.addRelayStats(stats)
+	assert.Equal(t, map[string]int64{"peer1": 0}, stats.RelayTo)
+}
+
+func TestMeshNode_updateRelayPaths(t *testing.T) {
+	identity := &PeerIdentity{
+		Name:   "test-node",
+		Config: &config.PeerConfig{Name: "test-node"},
+	}
+	node := NewMeshNode(identity, coord.NewClient("http://localhost:8080", "test-token"))
+	node.Forwarder = routing.NewForwarder(node.router, node.tunnelMgr)
+
+	node.updateRelayPaths([]proto.Peer{
+		{Name: "relay", RelayTo: map[string]int64{"peer1": 1_000, "test-node": 1_000}},
+		{Name: "peer1"},
+	})
+	assert.Equal(t, []string{"relay"}, node.Forwarder.RelayPaths().Candidates("peer1"))
+	assert.Empty(t, node.Forwarder.RelayPaths().Candidates("test-node"))
+}
@@ -0,0 +1,112 @@
+package peer
+
+import (
+	"net"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tunnelmesh/tunnelmesh/internal/tun"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
+)
+
+// updateSubnetRoutes routes the approved subnets advertised by other peers
+// through them, in the router and, when TUNName is set, in the OS routing
+// table. Prefixes containing one of our own addresses are skipped, so a peer
+// sitting on an advertised LAN keeps reaching it directly.
+func (m *MeshNode) updateSubnetRoutes(peers []proto.Peer) {
+	local := localIPv4Addrs()
+
+	routes := make(map[string]string)
+	for _, peer := range peers {
+		if peer.Name == m.identity.Name {
+			continue
+		}
+		for _, route := range peer.Routes {
+			ipNet, err := proto.ParseRoute(route)
+			if err != nil {
+				log.Debug().Err(err).Str("peer", peer.Name).Msg("ignoring invalid subnet route")
+				continue
+			}
+			if containsAny(ipNet, local) {
+				log.Debug().Str("peer", peer.Name).Str("route", route).Msg("ignoring subnet route to a local network")
+				continue
+			}
+			// The coordinator approves a prefix for one peer only; break ties
+			// deterministically anyway
+			prefix := ipNet.String()
+			if owner, ok := routes[prefix]; ok && owner < peer.Name {
+				continue
+			}
+			routes[prefix] = peer.Name
+		}
+	}
+
+	m.router.UpdateSubnetRoutes(routes)
+	m.syncOSSubnetRoutes(routes)
+}
+
+// syncOSSubnetRoutes points the subnet routes at the TUN device in the OS
+// routing table, removing routes no longer advertised.
+func (m *MeshNode) syncOSSubnetRoutes(routes map[string]string) {
+	if m.TUNName == "" {
+		return
+	}
+
+	m.subnetRoutesMu.Lock()
+	defer m.subnetRoutesMu.Unlock()
+
+	for prefix := range routes {
+		if m.installedRoutes[prefix] {
+			continue
+		}
+		_, ipNet, _ := net.ParseCIDR(prefix)
+		if err := tun.AddRoute(ipNet, nil, m.TUNName); err != nil {
+			log.Warn().Err(err).Str("route", prefix).Msg("failed to add subnet route")
+			continue
+		}
+		m.installedRoutes[prefix] = true
+		log.Info().Str("route", prefix).Str("peer", routes[prefix]).Msg("subnet route added")
+	}
+
+	for prefix := range m.installedRoutes {
+		if _, ok := routes[prefix]; ok {
+			continue
+		}
+		_, ipNet, _ := net.ParseCIDR(prefix)
+		if err := tun.RemoveRoute(ipNet, m.TUNName); err != nil {
+			log.Debug().Err(err).Str("route", prefix).Msg("failed to remove subnet route (may not exist)")
+		}
+		delete(m.installedRoutes, prefix)
+		log.Info().Str("route", prefix).Msg("subnet route removed")
+	}
+}
+
+// RemoveSubnetRoutes removes the subnet routes added to the OS routing table.
+// Called on shutdown.
+func (m *MeshNode) RemoveSubnetRoutes() {
+	m.syncOSSubnetRoutes(nil)
+}
+
+// localIPv4Addrs returns the IPv4 addresses of this host's interfaces.
+func localIPv4Addrs() []net.IP {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil
+	}
+	var ips []net.IP
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			ips = append(ips, ipNet.IP)
+		}
+	}
+	return ips
+}
+
+// containsAny reports whether ipNet contains any of ips.
+func containsAny(ipNet *net.IPNet, ips []net.IP) bool {
+	for _, ip := range ips {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package peer
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tunnelmesh/tunnelmesh/internal/config"
+	"github.com/tunnelmesh/tunnelmesh/internal/coord"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
+)
+
+func TestMeshNode_updateSubnetRoutes(t *testing.T) {
+	identity := &PeerIdentity{
+		Name:   "test-node",
+		Config: &config.PeerConfig{Name: "test-node"},
+	}
+	node := NewMeshNode(identity, coord.NewClient("http://localhost:8080", "test-token"))
+
+	node.updateSubnetRoutes([]proto.Peer{
+		{Name: "office", Routes: []string{"198.51.100.0/24", "not-a-prefix"}},
+		{Name: "lab", Routes: []string{"203.0.113.0/24"}},
+		{Name: "loop", Routes: []string{"127.0.0.0/8"}},
+		{Name: "test-node", Routes: []string{"10.10.0.0/16"}}, // Self
+	})
+
+	assert.Equal(t, map[string]string{
+		"198.51.100.0/24": "office",
+		"203.0.113.0/24":  "lab",
+	}, node.router.ListSubnetRoutes())
+
+	peer, ok := node.router.Lookup(net.ParseIP("198.51.100.20"))
+	require.True(t, ok)
+	assert.Equal(t, "office", peer)
+
+	// Without a TUN name the OS routing table is left alone
+	assert.Empty(t, node.installedRoutes)
+
+	node.updateSubnetRoutes(nil)
+	assert.Empty(t, node.router.ListSubnetRoutes())
+}
+
+func TestContainsAny(t *testing.T) {
+	_, ipNet, _ := net.ParseCIDR("192.168.10.0/24")
+	assert.True(t, containsAny(ipNet, []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("192.168.10.7")}))
+	assert.False(t, containsAny(ipNet, []net.IP{net.ParseIP("192.168.11.7")}))
+	assert.False(t, containsAny(ipNet, nil))
+}
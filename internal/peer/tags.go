@@ -0,0 +1,51 @@
+package peer
+
+import (
+	"slices"
+
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
+)
+
+// updateTags records this peer's tags from the peer list, which decide the
+// exit peers it may use, and restricts the peers that may use this peer as
+// exit peer to those carrying one of its exit_allow_tags.
+func (m *MeshNode) updateTags(peers []proto.Peer) {
+	cfg := m.identity.Config
+	var allowed []string
+	for _, peer := range peers {
+		if peer.Name == m.identity.Name {
+			m.tagsMu.Lock()
+			m.tags = slices.Clone(peer.Tags)
+			m.tagsMu.Unlock()
+			continue
+		}
+		if proto.HasAnyTag(peer.Tags, cfg.ExitAllowTags) {
+			allowed = append(allowed, peer.Name)
+		}
+	}
+
+	if m.Forwarder != nil && cfg.AllowExitTraffic && len(cfg.ExitAllowTags) > 0 {
+		if allowed == nil {
+			allowed = []string{} // No peer rather than every peer
+		}
+		m.Forwarder.SetExitClients(allowed)
+	}
+}
+
+// Tags returns this peer's tags, as last served by the coordinator.
+func (m *MeshNode) Tags() []string {
+	m.tagsMu.RLock()
+	defer m.tagsMu.RUnlock()
+	return slices.Clone(m.tags)
+}
+
+// addTagStats reports the tags a peer needs to use this peer as exit peer,
+// and the tags of the WireGuard clients it serves.
+func (m *MeshNode) addTagStats(stats *proto.PeerStats) {
+	if m.identity.Config.AllowExitTraffic {
+		stats.ExitAllowTags = m.identity.Config.ExitAllowTags
+	}
+	if m.ClientTags != nil {
+		stats.ClientTags = m.ClientTags()
+	}
+}
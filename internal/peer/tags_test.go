@@ -0,0 +1,63 @@
+package peer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tunnelmesh/tunnelmesh/internal/config"
+	"github.com/tunnelmesh/tunnelmesh/internal/coord"
+	"github.com/tunnelmesh/tunnelmesh/internal/routing"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
+)
+
+func TestMeshNode_exitPeerTags(t *testing.T) {
+	identity := &PeerIdentity{
+		Name:   "test-node",
+		Config: &config.PeerConfig{Name: "test-node"},
+	}
+	node := NewMeshNode(identity, coord.NewClient("http://localhost:8080", "test-token"))
+	node.Forwarder = routing.NewForwarder(node.router, node.tunnelMgr)
+	node.Forwarder.SetExitPeers([]string{"exit-staff", "exit-all"})
+
+	peers := []proto.Peer{
+		{Name: "exit-staff", AllowsExitTraffic: true, ExitAllowTags: []string{"tag:staff"}, LastSeen: time.Now()},
+		{Name: "exit-all", AllowsExitTraffic: true, LastSeen: time.Now()},
+		{Name: "test-node"},
+	}
+	for _, peer := range peers[:2] {
+		node.CachePeer(peer)
+		node.tunnelMgr.Add(peer.Name, &mockTunnel{})
+	}
+
+	node.updateTags(peers)
+	node.checkExitPeers()
+	assert.Equal(t, "exit-all", node.Forwarder.Exits().Active(), "untagged peers may not use exit-staff")
+
+	peers[2].Tags = []string{"tag:staff"}
+	node.updateTags(peers)
+	node.checkExitPeers()
+	assert.Equal(t, []string{"tag:staff"}, node.Tags())
+	assert.Equal(t, "exit-staff", node.Forwarder.Exits().Active())
+}
+
+func TestMeshNode_addTagStats(t *testing.T) {
+	identity := &PeerIdentity{
+		Name:   "test-node",
+		Config: &config.PeerConfig{Name: "test-node", ExitAllowTags: []string{"tag:staff"}},
+	}
+	node := NewMeshNode(identity, coord.NewClient("http://localhost:8080", "test-token"))
+
+	stats := &proto.PeerStats{}
+	node.addTagStats(stats)
+	assert.Nil(t, stats.ExitAllowTags, "not an exit peer")
+	assert.Nil(t, stats.ClientTags)
+
+	identity.Config.AllowExitTraffic = true
+	node.ClientTags = func() map[string][]string {
+		return map[string][]string{"10.42.100.2": {"tag:laptop"}}
+	}
+	node.addTagStats(stats)
+	assert.Equal(t, []string{"tag:staff"}, stats.ExitAllowTags)
+	assert.Equal(t, map[string][]string{"10.42.100.2": {"tag:laptop"}}, stats.ClientTags)
+}
@@ -2,16 +2,30 @@ package peer
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/rs/zerolog/log"
 )
 
 // TLSManager handles TLS certificate storage and loading for a peer.
+// The certificate, trust bundle and CRL are cached in memory after first use
+// and replaced when a renewed certificate or trust update is stored, so TLS
+// configs built on its callbacks pick up changes without a restart.
 type TLSManager struct {
 	dataDir string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate    // nil until loaded
+	roots   []*x509.Certificate // Trust bundle, signing root first; nil until loaded
+	revoked map[string]bool     // Hex serials on the CRL
 }
 
 // NewTLSManager creates a new TLS manager with the given data directory.
@@ -41,6 +55,11 @@ func (m *TLSManager) CAPath() string {
 	return filepath.Join(m.tlsDir(), "ca.pem")
 }
 
+// CRLPath returns the path to the certificate revocation list file.
+func (m *TLSManager) CRLPath() string {
+	return filepath.Join(m.tlsDir(), "ca.crl")
+}
+
 // StoreCert saves the certificate and private key to disk.
 func (m *TLSManager) StoreCert(certPEM, keyPEM []byte) error {
 	// Ensure directory exists
@@ -58,6 +77,13 @@ func (m *TLSManager) StoreCert(certPEM, keyPEM []byte) error {
 		return fmt.Errorf("write key: %w", err)
 	}
 
+	// Serve the new certificate from now on
+	if cert, err := tls.X509KeyPair(certPEM, keyPEM); err == nil {
+		m.mu.Lock()
+		m.cert = &cert
+		m.mu.Unlock()
+	}
+
 	log.Debug().
 		Str("cert", m.CertPath()).
 		Str("key", m.KeyPath()).
@@ -78,10 +104,221 @@ func (m *TLSManager) StoreCA(caPEM []byte) error {
 		return fmt.Errorf("write ca: %w", err)
 	}
 
+	// Reparsed from disk on next use
+	m.mu.Lock()
+	m.roots = nil
+	m.mu.Unlock()
+
 	log.Debug().Str("ca", m.CAPath()).Msg("stored CA certificate")
 	return nil
 }
 
+// StoreTrust saves a CA trust bundle and the CRL signed by one of its roots,
+// replacing the ones in use. The CRL is checked against the bundle first.
+func (m *TLSManager) StoreTrust(bundlePEM, crlDER []byte) error {
+	roots := parseCertsPEM(bundlePEM)
+	if len(roots) == 0 {
+		return errors.New("trust bundle has no certificates")
+	}
+	revoked, err := parseCRL(crlDER, roots)
+	if err != nil {
+		return err
+	}
+
+	if err := m.StoreCA(bundlePEM); err != nil {
+		return err
+	}
+	if err := os.WriteFile(m.CRLPath(), crlDER, 0644); err != nil {
+		return fmt.Errorf("write crl: %w", err)
+	}
+
+	m.mu.Lock()
+	m.roots = roots
+	m.revoked = revoked
+	m.mu.Unlock()
+
+	log.Debug().Int("roots", len(roots)).Int("revoked", len(revoked)).Msg("stored CA trust bundle and CRL")
+	return nil
+}
+
+// Certificate returns the current certificate, loading it from disk on first use.
+func (m *TLSManager) Certificate() (*tls.Certificate, error) {
+	m.mu.RLock()
+	cert := m.cert
+	m.mu.RUnlock()
+	if cert != nil {
+		return cert, nil
+	}
+
+	cert, err := m.LoadCert()
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	if m.cert == nil {
+		m.cert = cert
+	}
+	cert = m.cert
+	m.mu.Unlock()
+	return cert, nil
+}
+
+// GetCertificate serves the current certificate, for tls.Config.GetCertificate.
+func (m *TLSManager) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return m.Certificate()
+}
+
+// GetClientCertificate serves the current certificate, for
+// tls.Config.GetClientCertificate.
+func (m *TLSManager) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return m.Certificate()
+}
+
+// trust returns the trust bundle and revoked serials, loading them from disk
+// on first use. A missing CRL revokes nothing, as before the coordinator
+// first sends one; a CRL that doesn't verify against the bundle fails
+// closed, rather than treating every certificate as valid.
+func (m *TLSManager) trust() ([]*x509.Certificate, map[string]bool, error) {
+	m.mu.RLock()
+	roots, revoked := m.roots, m.revoked
+	m.mu.RUnlock()
+	if roots != nil {
+		return roots, revoked, nil
+	}
+
+	bundlePEM, err := os.ReadFile(m.CAPath())
+	if err != nil {
+		return nil, nil, fmt.Errorf("read ca: %w", err)
+	}
+	roots = parseCertsPEM(bundlePEM)
+	if len(roots) == 0 {
+		return nil, nil, errors.New("trust bundle has no certificates")
+	}
+	if crlDER, err := os.ReadFile(m.CRLPath()); err == nil {
+		if revoked, err = parseCRL(crlDER, roots); err != nil {
+			return nil, nil, err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("read crl: %w", err)
+	}
+
+	m.mu.Lock()
+	m.roots, m.revoked = roots, revoked
+	m.mu.Unlock()
+	return roots, revoked, nil
+}
+
+// VerifyConnection checks the certificate presented by the other side of a
+// mesh TLS connection against the current trust bundle and CRL. Peer
+// certificates are signed directly by a root and allow client
+// authentication, so service certificates the coordinator issues over ACME,
+// which are signed by an intermediate for server authentication only, are
+// never taken for a peer's identity.
+func (m *TLSManager) VerifyConnection(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("no certificate presented")
+	}
+	roots, revoked, err := m.trust()
+	if err != nil {
+		return err
+	}
+
+	pool := x509.NewCertPool()
+	for _, root := range roots {
+		pool.AddCert(root)
+	}
+	leaf := cs.PeerCertificates[0]
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		DNSName:   cs.ServerName,
+		Roots:     pool,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		return err
+	}
+	if revoked[leaf.SerialNumber.Text(16)] {
+		return fmt.Errorf("certificate %s has been revoked", leaf.SerialNumber.Text(16))
+	}
+	return nil
+}
+
+// ClientTLSConfig returns a TLS config for connecting to other mesh nodes.
+// It presents the current certificate and verifies the server against the
+// current trust bundle and CRL, so renewals and CA rollovers apply to new
+// connections straight away.
+func (m *TLSManager) ClientTLSConfig() *tls.Config {
+	return &tls.Config{
+		GetClientCertificate: m.GetClientCertificate,
+		// Verification is done by VerifyConnection, against the trust bundle
+		// in use at connection time rather than a fixed pool.
+		InsecureSkipVerify: true, //nolint:gosec // verified in VerifyConnection
+		VerifyConnection:   m.VerifyConnection,
+		MinVersion:         tls.VersionTLS12,
+	}
+}
+
+// NeedsRenewal reports whether the certificate should be renewed: when less
+// than a third of its lifetime remains, when it is not signed by the signing
+// root of the trust bundle (after a CA rollover), or when it has been revoked.
+func (m *TLSManager) NeedsRenewal(now time.Time) bool {
+	cert, err := m.Certificate()
+	if err != nil || cert.Leaf == nil {
+		return false
+	}
+	leaf := cert.Leaf
+	lifetime := leaf.NotAfter.Sub(leaf.NotBefore)
+	if leaf.NotAfter.Sub(now) < lifetime/3 {
+		return true
+	}
+
+	roots, revoked, err := m.trust()
+	if err != nil {
+		return false
+	}
+	if leaf.CheckSignatureFrom(roots[0]) != nil {
+		return true
+	}
+	return revoked[leaf.SerialNumber.Text(16)]
+}
+
+// parseCertsPEM parses all certificates in a PEM bundle, skipping invalid ones.
+func parseCertsPEM(data []byte) []*x509.Certificate {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return certs
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+			certs = append(certs, cert)
+		}
+	}
+}
+
+// parseCRL parses a DER-encoded CRL signed by one of the trusted roots and
+// returns its revoked serials in hex. Any root of the bundle is accepted, as
+// during a CA rollover the CRL may be signed by a root that is not yet, or
+// no longer, the first one in the bundle a peer holds.
+func parseCRL(crlDER []byte, roots []*x509.Certificate) (map[string]bool, error) {
+	crl, err := x509.ParseRevocationList(crlDER)
+	if err != nil {
+		return nil, fmt.Errorf("parse crl: %w", err)
+	}
+	if !slices.ContainsFunc(roots, func(root *x509.Certificate) bool {
+		return crl.CheckSignatureFrom(root) == nil
+	}) {
+		return nil, errors.New("verify crl: not signed by a trusted root")
+	}
+	revoked := make(map[string]bool, len(crl.RevokedCertificateEntries))
+	for _, entry := range crl.RevokedCertificateEntries {
+		revoked[entry.SerialNumber.Text(16)] = true
+	}
+	return revoked, nil
+}
+
 // LoadCert loads the certificate and private key from disk.
 func (m *TLSManager) LoadCert() (*tls.Certificate, error) {
 	cert, err := tls.LoadX509KeyPair(m.CertPath(), m.KeyPath())
@@ -1,9 +1,18 @@
 package peer
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestTLSManager_CAPath(t *testing.T) {
@@ -73,3 +82,85 @@ func TestTLSManager_StoreCA_CreatesDirectory(t *testing.T) {
 		t.Errorf("CA file not created")
 	}
 }
+
+// testCert signs a certificate for the template with the parent, or
+// self-signs it if parent is nil.
+func testCert(t *testing.T, template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Minute)
+	template.NotAfter = time.Now().Add(time.Hour)
+	template.BasicConstraintsValid = true
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func TestTLSManager_VerifyConnection_RejectsServiceCerts(t *testing.T) {
+	root, rootKey := testCert(t, &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "root"},
+		IsCA:     true,
+		KeyUsage: x509.KeyUsageCertSign,
+	}, nil, nil)
+	service, serviceKey := testCert(t, &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "service"},
+		IsCA:           true,
+		MaxPathLenZero: true,
+		KeyUsage:       x509.KeyUsageCertSign,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, root, rootKey)
+
+	mgr := NewTLSManager(t.TempDir())
+	if err := mgr.StoreCA(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: root.Raw})); err != nil {
+		t.Fatal(err)
+	}
+
+	peerCert, _ := testCert(t, &x509.Certificate{
+		DNSNames:    []string{"bob.tunnelmesh"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}, root, rootKey)
+	if err := mgr.VerifyConnection(tls.ConnectionState{
+		ServerName:       "bob.tunnelmesh",
+		PeerCertificates: []*x509.Certificate{peerCert},
+	}); err != nil {
+		t.Errorf("peer certificate: %v", err)
+	}
+
+	// A service certificate for the peer's name, through the intermediate
+	viaService, _ := testCert(t, &x509.Certificate{
+		DNSNames:    []string{"bob.tunnelmesh"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, service, serviceKey)
+	if err := mgr.VerifyConnection(tls.ConnectionState{
+		ServerName:       "bob.tunnelmesh",
+		PeerCertificates: []*x509.Certificate{viaService, service},
+	}); err == nil {
+		t.Error("service certificate accepted as a peer certificate")
+	}
+
+	// A server-only certificate signed by the root, as issued before the
+	// service intermediate
+	serverOnly, _ := testCert(t, &x509.Certificate{
+		DNSNames:    []string{"bob.tunnelmesh"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, root, rootKey)
+	if err := mgr.VerifyConnection(tls.ConnectionState{
+		ServerName:       "bob.tunnelmesh",
+		PeerCertificates: []*x509.Certificate{serverOnly},
+	}); err == nil {
+		t.Error("server-only certificate accepted as a peer certificate")
+	}
+}
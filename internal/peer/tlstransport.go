@@ -0,0 +1,24 @@
+package peer
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tunnelmesh/tunnelmesh/internal/transport"
+)
+
+// HandleIncomingTLS accepts incoming TLS connections from the listener.
+func (m *MeshNode) HandleIncomingTLS(ctx context.Context, listener transport.Listener) {
+	for {
+		conn, err := listener.Accept(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Error().Err(err).Msg("TLS accept error")
+			continue
+		}
+
+		go m.handleIncomingConnection(ctx, conn, "TLS")
+	}
+}
@@ -46,6 +46,7 @@ func (m *MeshNode) HandleUDPSessionInvalidated(peerName string) {
 func (m *MeshNode) SetupUDPSessionInvalidCallback(udpTransport *udptransport.Transport) {
 	m.UDPTransport = udpTransport
 	udpTransport.SetSessionInvalidCallback(m.HandleUDPSessionInvalidated)
+	m.applyPSKs()
 }
 
 // PreRegisterUDPOutbound pre-registers intent to connect to a peer via UDP.
@@ -9,6 +9,7 @@ import (
 
 	"github.com/rs/zerolog/log"
 	"github.com/skip2/go-qrcode"
+	"github.com/tunnelmesh/tunnelmesh/pkg/proto"
 )
 
 // APIRequest represents a parsed API request.
@@ -119,7 +120,7 @@ func (h *APIHandler) createClient(body []byte) []byte {
 		return h.errorResponse(400, err.Error())
 	}
 
-	client, privateKey, err := h.store.CreateWithPrivateKey(req.Name)
+	client, privateKey, err := h.store.CreateWithPrivateKey(req.Name, req.Tags)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to create WireGuard client")
 		return h.errorResponse(500, "failed to create client")
@@ -176,7 +177,15 @@ func (h *APIHandler) updateClient(id string, body []byte) []byte {
 		return h.errorResponse(400, "invalid request body")
 	}
 
-	client, err := h.store.Update(id, req.Enabled)
+	if req.Tags != nil {
+		tags, err := proto.NormalizeTags(*req.Tags)
+		if err != nil {
+			return h.errorResponse(400, err.Error())
+		}
+		req.Tags = &tags
+	}
+
+	client, err := h.store.Update(id, req.Enabled, req.Tags)
 	if errors.Is(err, ErrClientNotFound) {
 		return h.errorResponse(404, "client not found")
 	}
@@ -236,14 +245,20 @@ func (h *APIHandler) errorResponse(statusCode int, message string) []byte {
 
 // CreateClientRequest is the request body for creating a new WireGuard client.
 type CreateClientRequest struct {
-	Name string `json:"name"`
+	Name string   `json:"name"`
+	Tags []string `json:"tags,omitempty"`
 }
 
-// Validate validates the create client request.
+// Validate validates the create client request, normalizing its tags.
 func (r *CreateClientRequest) Validate() error {
 	if strings.TrimSpace(r.Name) == "" {
 		return fmt.Errorf("name is required")
 	}
+	tags, err := proto.NormalizeTags(r.Tags)
+	if err != nil {
+		return err
+	}
+	r.Tags = tags
 	return nil
 }
 
@@ -257,7 +272,8 @@ type CreateClientResponse struct {
 
 // UpdateClientRequest is the request body for updating a WireGuard client.
 type UpdateClientRequest struct {
-	Enabled *bool `json:"enabled,omitempty"`
+	Enabled *bool     `json:"enabled,omitempty"`
+	Tags    *[]string `json:"tags,omitempty"` // Replaces the client's tags when set
 }
 
 // ClientConfigParams holds parameters for generating a WireGuard client config.
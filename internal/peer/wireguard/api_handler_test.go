@@ -186,6 +186,46 @@ func TestAPIHandlerNilCallback(t *testing.T) {
 	}
 }
 
+func TestAPIHandlerClientTags(t *testing.T) {
+	store, err := NewClientStore("10.42.0.0/16", t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	handler := NewAPIHandler(store, "pubkey", "endpoint:51820", "10.42.0.0/16", "mesh.local")
+
+	reqBody, _ := json.Marshal(CreateClientRequest{Name: "Laptop", Tags: []string{"laptop"}})
+	var resp APIResponse
+	if err := json.Unmarshal(handler.HandleRequest("POST /clients", reqBody), &resp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if resp.StatusCode != 400 {
+		t.Errorf("expected status 400 for a tag without the tag: prefix, got %d", resp.StatusCode)
+	}
+
+	reqBody, _ = json.Marshal(CreateClientRequest{Name: "Laptop", Tags: []string{"tag:staff", "tag:laptop"}})
+	if err := json.Unmarshal(handler.HandleRequest("POST /clients", reqBody), &resp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	var createResult CreateClientResponse
+	if err := json.Unmarshal(resp.Body, &createResult); err != nil {
+		t.Fatalf("failed to unmarshal create result: %v", err)
+	}
+	client := createResult.Client
+
+	tags := store.TagsByIP()
+	if got := tags[client.MeshIP]; len(got) != 2 || got[0] != "tag:laptop" || got[1] != "tag:staff" {
+		t.Errorf("expected sorted tags for %s, got %v", client.MeshIP, tags)
+	}
+
+	// Replacing the tags
+	updateBody, _ := json.Marshal(UpdateClientRequest{Tags: &[]string{}})
+	handler.HandleRequest("PATCH /clients/"+client.ID, updateBody)
+	if tags := store.TagsByIP(); len(tags) != 0 {
+		t.Errorf("expected no tagged clients, got %v", tags)
+	}
+}
+
 func TestGenerateClientConfig(t *testing.T) {
 	params := ClientConfigParams{
 		ClientPrivateKey: "privatekey123",
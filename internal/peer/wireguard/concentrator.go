@@ -43,6 +43,7 @@ type Client struct {
 	PublicKey string    `json:"public_key"`
 	MeshIP    string    `json:"mesh_ip"`
 	DNSName   string    `json:"dns_name"`
+	Tags      []string  `json:"tags,omitempty"`
 	Enabled   bool      `json:"enabled"`
 	CreatedAt time.Time `json:"created_at"`
 	LastSeen  time.Time `json:"last_seen"`
@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -113,9 +114,9 @@ func (s *ClientStore) save() error {
 	return nil
 }
 
-// CreateWithPrivateKey creates a new WireGuard client and returns the private key.
-// The private key is only returned once at creation time.
-func (s *ClientStore) CreateWithPrivateKey(name string) (*Client, string, error) {
+// CreateWithPrivateKey creates a new WireGuard client with the given tags and
+// returns the private key. The private key is only returned once at creation time.
+func (s *ClientStore) CreateWithPrivateKey(name string, tags []string) (*Client, string, error) {
 	// Generate WireGuard key pair
 	privateKey, err := wgtypes.GeneratePrivateKey()
 	if err != nil {
@@ -141,6 +142,7 @@ func (s *ClientStore) CreateWithPrivateKey(name string) (*Client, string, error)
 		PublicKey: publicKey.String(),
 		MeshIP:    meshIP,
 		DNSName:   dnsName,
+		Tags:      tags,
 		Enabled:   true,
 		CreatedAt: time.Now(),
 		LastSeen:  time.Now(),
@@ -182,8 +184,8 @@ func (s *ClientStore) List() []Client {
 	return clients
 }
 
-// Update updates a client's settings.
-func (s *ClientStore) Update(id string, enabled *bool) (*Client, error) {
+// Update updates a client's settings. Nil settings are left unchanged.
+func (s *ClientStore) Update(id string, enabled *bool, tags *[]string) (*Client, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -195,6 +197,9 @@ func (s *ClientStore) Update(id string, enabled *bool) (*Client, error) {
 	if enabled != nil {
 		client.Enabled = *enabled
 	}
+	if tags != nil {
+		client.Tags = *tags
+	}
 
 	if err := s.save(); err != nil {
 		return nil, fmt.Errorf("save: %w", err)
@@ -204,6 +209,20 @@ func (s *ClientStore) Update(id string, enabled *bool) (*Client, error) {
 	return &c, nil
 }
 
+// TagsByIP returns the tags of the enabled clients that have any, by mesh IP.
+func (s *ClientStore) TagsByIP() map[string][]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tags := make(map[string][]string)
+	for _, c := range s.clients {
+		if c.Enabled && len(c.Tags) > 0 {
+			tags[c.MeshIP] = slices.Clone(c.Tags)
+		}
+	}
+	return tags
+}
+
 // Delete removes a client.
 func (s *ClientStore) Delete(id string) error {
 	s.mu.Lock()
@@ -0,0 +1,555 @@
+package routing
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultMaxFlows is the default capacity of the connection tracking table.
+// Once full, new flows are not tracked and are left to the filter rules.
+const DefaultMaxFlows = 65536
+
+// peerFlowShare is the inverse of the share of the table a single remote
+// peer may fill with flows it originates, so that one peer can't keep the
+// table full and leave the flows of the others untracked.
+const peerFlowShare = 16
+
+// Idle timeouts of tracked flows, by state.
+const (
+	TCPHandshakeTimeout   = 60 * time.Second
+	TCPEstablishedTimeout = 2 * time.Hour
+	TCPClosingTimeout     = 2 * time.Minute
+	TCPClosedTimeout      = 10 * time.Second
+	UDPUnrepliedTimeout   = 30 * time.Second
+	UDPRepliedTimeout     = 3 * time.Minute
+	ICMPTimeout           = 30 * time.Second
+)
+
+// conntrackSweepInterval is how often expired flows are removed from the table.
+const conntrackSweepInterval = 10 * time.Second
+
+// TCP flags used by the connection tracker.
+const (
+	tcpFlagFIN = 0x01
+	tcpFlagSYN = 0x02
+	tcpFlagRST = 0x04
+	tcpFlagACK = 0x10
+)
+
+// FlowState is the state of a tracked flow.
+type FlowState uint8
+
+const (
+	// FlowSynSent is a TCP flow whose originator sent a SYN.
+	FlowSynSent FlowState = iota
+	// FlowSynReceived is a TCP flow whose responder answered with a SYN-ACK.
+	FlowSynReceived
+	// FlowEstablished is a TCP flow that completed its handshake.
+	FlowEstablished
+	// FlowClosing is a TCP flow after one side sent a FIN.
+	FlowClosing
+	// FlowClosed is a TCP flow after a RST, or a FIN from both sides.
+	FlowClosed
+	// FlowUnreplied is a UDP or ICMP flow the responder has not answered.
+	FlowUnreplied
+	// FlowReplied is a UDP or ICMP flow the responder answered.
+	FlowReplied
+)
+
+// String returns a human-readable name for the flow state.
+func (s FlowState) String() string {
+	switch s {
+	case FlowSynSent:
+		return "SYN_SENT"
+	case FlowSynReceived:
+		return "SYN_RECV"
+	case FlowEstablished:
+		return "ESTABLISHED"
+	case FlowClosing:
+		return "CLOSING"
+	case FlowClosed:
+		return "CLOSED"
+	case FlowUnreplied:
+		return "UNREPLIED"
+	case FlowReplied:
+		return "REPLIED"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// timeout returns how long a flow in the state may stay idle.
+func (s FlowState) timeout(protocol uint8) time.Duration {
+	switch s {
+	case FlowSynSent, FlowSynReceived:
+		return TCPHandshakeTimeout
+	case FlowEstablished:
+		return TCPEstablishedTimeout
+	case FlowClosing:
+		return TCPClosingTimeout
+	case FlowClosed:
+		return TCPClosedTimeout
+	}
+	if isICMP(protocol) {
+		return ICMPTimeout
+	}
+	if s == FlowReplied {
+		return UDPRepliedTimeout
+	}
+	return UDPUnrepliedTimeout
+}
+
+// flowKey identifies a flow in the direction of its first packet. ICMP echo
+// flows use the echo identifier as both ports, so replies reverse onto them.
+type flowKey struct {
+	protocol         uint8
+	src, dst         netip.Addr
+	srcPort, dstPort uint16
+}
+
+// reverse returns the key of packets travelling the other way.
+func (k flowKey) reverse() flowKey {
+	return flowKey{protocol: k.protocol, src: k.dst, dst: k.src, srcPort: k.dstPort, dstPort: k.srcPort}
+}
+
+// flowPacket is a packet parsed for connection tracking.
+type flowPacket struct {
+	key      flowKey
+	tcpFlags uint8
+	opens    bool // Whether the packet may start a new flow
+	pickup   bool // Whether the packet may start tracking a flow mid-stream
+}
+
+// parseFlowPacket parses the flow of a TCP, UDP or ICMP echo packet whose
+// transport header starts at offset. ok is false for anything else.
+func parseFlowPacket(packet []byte, protocol uint8, offset int) (fp flowPacket, ok bool) {
+	fp.key.protocol = protocol
+	switch protocol {
+	case ProtoTCP:
+		if len(packet) < offset+14 {
+			return fp, false
+		}
+		fp.key.srcPort = binary.BigEndian.Uint16(packet[offset : offset+2])
+		fp.key.dstPort = binary.BigEndian.Uint16(packet[offset+2 : offset+4])
+		fp.tcpFlags = packet[offset+13]
+		fp.opens = fp.tcpFlags&(tcpFlagSYN|tcpFlagACK|tcpFlagRST) == tcpFlagSYN
+		// Connections predating the table are picked up as established
+		fp.pickup = !fp.opens && fp.tcpFlags&(tcpFlagRST|tcpFlagFIN) == 0
+	case ProtoUDP:
+		if len(packet) < offset+4 {
+			return fp, false
+		}
+		fp.key.srcPort = binary.BigEndian.Uint16(packet[offset : offset+2])
+		fp.key.dstPort = binary.BigEndian.Uint16(packet[offset+2 : offset+4])
+		fp.opens = true
+	case ProtoICMP, ProtoICMPv6:
+		if len(packet) < offset+6 {
+			return fp, false
+		}
+		switch icmpType := packet[offset]; {
+		case protocol == ProtoICMP && icmpType == 8, protocol == ProtoICMPv6 && icmpType == 128:
+			fp.opens = true // Echo request
+		case protocol == ProtoICMP && icmpType == 0, protocol == ProtoICMPv6 && icmpType == 129:
+			// Echo reply
+		default:
+			return fp, false
+		}
+		id := binary.BigEndian.Uint16(packet[offset+4 : offset+6])
+		fp.key.srcPort, fp.key.dstPort = id, id
+	default:
+		return fp, false
+	}
+	fp.key.src = sourceAddr(packet)
+	fp.key.dst = destAddr(packet)
+	return fp, true
+}
+
+// quotedFlowKey returns the flow key of the packet quoted in an ICMP error,
+// as that packet travelled. ok is false if the quote is too short or not of
+// a TCP, UDP or ICMP packet.
+func quotedFlowKey(quote []byte) (key flowKey, ok bool) {
+	protocol, offset, ok := TransportHeader(quote)
+	if !ok {
+		return key, false
+	}
+	key.protocol = protocol
+	switch protocol {
+	case ProtoTCP, ProtoUDP:
+		if len(quote) < offset+4 {
+			return key, false
+		}
+		key.srcPort = binary.BigEndian.Uint16(quote[offset : offset+2])
+		key.dstPort = binary.BigEndian.Uint16(quote[offset+2 : offset+4])
+	case ProtoICMP, ProtoICMPv6:
+		if len(quote) < offset+6 {
+			return key, false
+		}
+		id := binary.BigEndian.Uint16(quote[offset+4 : offset+6])
+		key.srcPort, key.dstPort = id, id
+	default:
+		return key, false
+	}
+	key.src = sourceAddr(quote)
+	key.dst = destAddr(quote)
+	return key, true
+}
+
+// destAddr returns the destination address of an IPv4 or IPv6 packet whose
+// header TransportHeader accepted.
+func destAddr(packet []byte) netip.Addr {
+	if packet[0]>>4 == 6 {
+		return netip.AddrFrom16([16]byte(packet[24:40]))
+	}
+	return netip.AddrFrom4([4]byte(packet[16:20]))
+}
+
+// flow is a tracked flow. Guarded by ConnTracker.mu.
+type flow struct {
+	state        FlowState
+	inbound      bool   // Originated by a remote peer
+	pickedUp     bool   // Tracked from mid-stream, so its originator is unknown
+	peer         string // Remote peer, once a packet was exchanged with it
+	finOrig      bool   // Originator sent a FIN
+	finReply     bool   // Responder sent a FIN
+	packetsOrig  uint64
+	packetsReply uint64
+	lastSeen     time.Time
+}
+
+func (fl *flow) expired(now time.Time, protocol uint8) bool {
+	return now.Sub(fl.lastSeen) > fl.state.timeout(protocol)
+}
+
+// update advances the flow's state for a packet in the original direction
+// (orig) or the reply direction.
+func (fl *flow) update(fp flowPacket, orig bool, now time.Time) {
+	fl.lastSeen = now
+	if orig {
+		fl.packetsOrig++
+	} else {
+		fl.packetsReply++
+	}
+
+	if fp.key.protocol != ProtoTCP {
+		if !orig {
+			fl.state = FlowReplied
+		}
+		return
+	}
+
+	flags := fp.tcpFlags
+	switch {
+	case flags&tcpFlagRST != 0:
+		fl.state = FlowClosed
+	case flags&tcpFlagFIN != 0:
+		if orig {
+			fl.finOrig = true
+		} else {
+			fl.finReply = true
+		}
+		if fl.finOrig && fl.finReply {
+			fl.state = FlowClosed
+		} else {
+			fl.state = FlowClosing
+		}
+	case fl.state == FlowSynSent && !orig && flags&(tcpFlagSYN|tcpFlagACK) == tcpFlagSYN|tcpFlagACK:
+		fl.state = FlowSynReceived
+	case fl.state == FlowSynReceived && orig && flags&tcpFlagACK != 0:
+		fl.state = FlowEstablished
+	}
+}
+
+// Flow is a snapshot of a tracked flow, for display.
+type Flow struct {
+	Protocol     uint8
+	Src          netip.AddrPort // Originator; the port is the echo identifier for ICMP
+	Dst          netip.AddrPort // Responder
+	State        FlowState
+	Inbound      bool   // Originated by a remote peer rather than this one
+	Peer         string // Remote peer, empty until a packet was exchanged with it
+	PacketsOrig  uint64 // Packets from the originator
+	PacketsReply uint64 // Packets from the responder
+	LastSeen     time.Time
+	Expires      time.Time
+}
+
+// ConnTracker tracks the flows crossing the mesh interface, so the packet
+// filter can let in replies to connections this peer initiated. TCP flows
+// follow the handshake and teardown; UDP and ICMP echo flows are
+// pseudo-flows that expire after a period of inactivity.
+type ConnTracker struct {
+	mu           sync.Mutex
+	flows        map[flowKey]*flow
+	cut          map[flowKey]time.Time // TCP connections flushed by a rule change, by last packet sent
+	peerFlows    map[string]int        // Inbound flows by originating peer
+	maxFlows     int
+	maxPeerFlows int
+	lastSweep    time.Time
+	now          func() time.Time // Replaced in tests
+}
+
+// NewConnTracker creates a connection tracker holding up to maxFlows flows.
+// Uses DefaultMaxFlows if maxFlows is not positive.
+func NewConnTracker(maxFlows int) *ConnTracker {
+	if maxFlows <= 0 {
+		maxFlows = DefaultMaxFlows
+	}
+	return &ConnTracker{
+		flows:        make(map[flowKey]*flow),
+		cut:          make(map[flowKey]time.Time),
+		peerFlows:    make(map[string]int),
+		maxFlows:     maxFlows,
+		maxPeerFlows: max(maxFlows/peerFlowShare, 1),
+		now:          time.Now,
+	}
+}
+
+// Outbound records a packet sent into the mesh through the given peer,
+// starting a flow if it opens one. Packets that are not tracked are ignored.
+// Flows this peer originated are pinned to the peer their packets were last
+// sent to; an empty peer leaves the flow unpinned.
+func (c *ConnTracker) Outbound(packet []byte, peer string) {
+	protocol, offset, ok := TransportHeader(packet)
+	if !ok {
+		return
+	}
+	fp, ok := parseFlowPacket(packet, protocol, offset)
+	if !ok {
+		return
+	}
+	if c.observe(fp, false, peer) {
+		return
+	}
+	if fp.opens || fp.pickup && !c.refreshCut(fp.key) {
+		c.open(fp, false, peer)
+	}
+}
+
+// refreshCut reports whether a packet this peer sends belongs to a TCP
+// connection cut by a rule change, and keeps the connection marked as cut
+// while this peer keeps sending. Such a connection is not picked up again,
+// so the remote peer's packets stay subject to the rules.
+func (c *ConnTracker) refreshCut(key flowKey) bool {
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, k := range []flowKey{key, key.reverse()} {
+		if last, ok := c.cut[k]; ok && now.Sub(last) <= TCPEstablishedTimeout {
+			c.cut[k] = now
+			return true
+		}
+	}
+	return false
+}
+
+// observe updates the flow a packet belongs to: for an inbound packet, a flow
+// the remote peer originated or a reply to one this peer originated, and
+// conversely for an outbound packet. Returns false if the packet belongs to
+// no live flow.
+func (c *ConnTracker) observe(fp flowPacket, inbound bool, peer string) bool {
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	orig := true
+	fl, ok := c.flows[fp.key]
+	if !ok || fl.inbound != inbound {
+		orig = false
+		fl, ok = c.flows[fp.key.reverse()]
+		ok = ok && fl.inbound != inbound
+	}
+	if !ok || fl.expired(now, fp.key.protocol) {
+		return false
+	}
+	// Once known, the remote peer of a flow is pinned
+	if inbound && fl.peer != "" && peer != fl.peer {
+		return false
+	}
+	// A new SYN on a closed flow reuses its ports for a new connection
+	if fl.state == FlowClosed && fp.opens {
+		return false
+	}
+	fl.update(fp, orig, now)
+	// Flows this peer originated follow the route of their packets, or are
+	// pinned by the first reply. The peer of an inbound flow is the one that
+	// originated it.
+	if peer != "" && !fl.inbound {
+		fl.peer = peer
+	}
+	return true
+}
+
+// related reports whether an ICMP error from peer quotes a packet of a live
+// flow, in either direction. Only the peer the flow is pinned to may send
+// errors about it, so other peers can't tear down or redirect it.
+func (c *ConnTracker) related(quote []byte, peer string) bool {
+	key, ok := quotedFlowKey(quote)
+	if !ok {
+		return false
+	}
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, k := range []flowKey{key, key.reverse()} {
+		if fl, ok := c.flows[k]; ok && !fl.expired(now, k.protocol) && fl.peer == peer {
+			return true
+		}
+	}
+	return false
+}
+
+// open starts tracking a flow with the packet as its first, or as the first
+// seen of a TCP connection picked up mid-stream. The flow is not tracked if
+// the table is full, or if it is inbound and its peer already originated its
+// share of the table.
+func (c *ConnTracker) open(fp flowPacket, inbound bool, peer string) {
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	peerFull := inbound && c.peerFlows[peer] >= c.maxPeerFlows
+	if now.Sub(c.lastSweep) >= conntrackSweepInterval || len(c.flows) >= c.maxFlows || peerFull {
+		c.sweep(now)
+	}
+	if len(c.flows) >= c.maxFlows || inbound && c.peerFlows[peer] >= c.maxPeerFlows {
+		return
+	}
+	// Drop a closed flow whose ports are reused
+	for _, k := range []flowKey{fp.key, fp.key.reverse()} {
+		if fl, ok := c.flows[k]; ok {
+			c.remove(k, fl)
+		}
+		delete(c.cut, k)
+	}
+
+	state := FlowUnreplied
+	if fp.key.protocol == ProtoTCP {
+		state = FlowSynSent
+		if !fp.opens {
+			state = FlowEstablished
+		}
+	}
+	c.flows[fp.key] = &flow{
+		state:       state,
+		inbound:     inbound,
+		pickedUp:    !fp.opens,
+		peer:        peer,
+		packetsOrig: 1,
+		lastSeen:    now,
+	}
+	if inbound {
+		c.peerFlows[peer]++
+	}
+}
+
+// remove deletes a flow from the table. Must be called with mu held.
+func (c *ConnTracker) remove(key flowKey, fl *flow) {
+	delete(c.flows, key)
+	if !fl.inbound {
+		return
+	}
+	if c.peerFlows[fl.peer]--; c.peerFlows[fl.peer] <= 0 {
+		delete(c.peerFlows, fl.peer)
+	}
+}
+
+// sweep removes expired flows. Must be called with mu held.
+func (c *ConnTracker) sweep(now time.Time) {
+	c.lastSweep = now
+	for key, fl := range c.flows {
+		if fl.expired(now, key.protocol) {
+			c.remove(key, fl)
+		}
+	}
+	for key, last := range c.cut {
+		if now.Sub(last) > TCPEstablishedTimeout {
+			delete(c.cut, key)
+		}
+	}
+}
+
+// Flows returns a snapshot of the live flows, ordered by protocol, source
+// and destination.
+func (c *ConnTracker) Flows() []Flow {
+	now := c.now()
+
+	c.mu.Lock()
+	flows := make([]Flow, 0, len(c.flows))
+	for key, fl := range c.flows {
+		if fl.expired(now, key.protocol) {
+			continue
+		}
+		flows = append(flows, Flow{
+			Protocol:     key.protocol,
+			Src:          netip.AddrPortFrom(key.src, key.srcPort),
+			Dst:          netip.AddrPortFrom(key.dst, key.dstPort),
+			State:        fl.state,
+			Inbound:      fl.inbound,
+			Peer:         fl.peer,
+			PacketsOrig:  fl.packetsOrig,
+			PacketsReply: fl.packetsReply,
+			LastSeen:     fl.lastSeen,
+			Expires:      fl.lastSeen.Add(fl.state.timeout(key.protocol)),
+		})
+	}
+	c.mu.Unlock()
+
+	sort.Slice(flows, func(i, j int) bool {
+		a, b := flows[i], flows[j]
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		if c := a.Src.Compare(b.Src); c != 0 {
+			return c < 0
+		}
+		return a.Dst.Compare(b.Dst) < 0
+	})
+	return flows
+}
+
+// Len returns the number of flows in the table, including expired flows not
+// yet swept.
+func (c *ConnTracker) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.flows)
+}
+
+// FlushInbound removes the flows remote peers originated, and those picked
+// up mid-stream whose originator is unknown, so that their packets are
+// checked against the rules again. Their TCP connections are not picked up
+// again when this peer keeps sending, which would let the remote peer's
+// packets back in as replies.
+func (c *ConnTracker) FlushInbound() {
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, fl := range c.flows {
+		if !fl.inbound && !fl.pickedUp {
+			continue
+		}
+		delete(c.flows, key)
+		if key.protocol == ProtoTCP && fl.state != FlowClosed && len(c.cut) < c.maxFlows {
+			c.cut[key] = now
+		}
+	}
+	c.peerFlows = make(map[string]int)
+}
+
+// Flush removes all flows.
+func (c *ConnTracker) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flows = make(map[flowKey]*flow)
+	c.cut = make(map[flowKey]time.Time)
+	c.peerFlows = make(map[string]int)
+}
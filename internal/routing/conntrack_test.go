@@ -0,0 +1,346 @@
+package routing
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// buildICMPEchoPacket creates an IPv4 ICMP echo request (type 8) or reply
+// (type 0) with the given identifier.
+func buildICMPEchoPacket(srcIP, dstIP net.IP, icmpType byte, id uint16) []byte {
+	packet := make([]byte, 28)
+	packet[0] = 0x45
+	packet[9] = ProtoICMP
+	copy(packet[12:16], srcIP.To4())
+	copy(packet[16:20], dstIP.To4())
+	packet[20] = icmpType
+	packet[24] = byte(id >> 8)
+	packet[25] = byte(id)
+	return packet
+}
+
+func TestConnTracker_UDPReplies(t *testing.T) {
+	local := net.ParseIP("10.42.0.1")
+	remote := net.ParseIP("10.42.0.2")
+
+	f := NewPacketFilter(true)
+	clock := time.Now()
+	f.conntrack.now = func() time.Time { return clock }
+
+	reply := buildUDPPacket(remote, local, 53, 40000)
+	if !f.ShouldDropFromPeer(reply, "dns") {
+		t.Fatal("expected unsolicited UDP to be dropped in allowlist mode")
+	}
+
+	f.TrackOutbound(buildUDPPacket(local, remote, 40000, 53), "dns")
+	if f.ShouldDropFromPeer(reply, "dns") {
+		t.Error("expected reply to an outbound query to pass")
+	}
+	// The flow is pinned to the peer the query was sent to
+	if !f.ShouldDropFromPeer(reply, "spoofer") {
+		t.Error("expected reply from another peer to be dropped")
+	}
+	// Other ports are still filtered
+	if !f.ShouldDropFromPeer(buildUDPPacket(remote, local, 53, 40001), "dns") {
+		t.Error("expected UDP to another port to be dropped")
+	}
+
+	flows := f.Flows()
+	if len(flows) != 1 {
+		t.Fatalf("expected 1 flow, got %d", len(flows))
+	}
+	fl := flows[0]
+	if fl.State != FlowReplied || fl.Inbound || fl.Peer != "dns" || fl.PacketsOrig != 1 || fl.PacketsReply != 1 {
+		t.Errorf("unexpected flow: %+v", fl)
+	}
+	if fl.Src.String() != "10.42.0.1:40000" || fl.Dst.String() != "10.42.0.2:53" {
+		t.Errorf("unexpected flow addresses: %s -> %s", fl.Src, fl.Dst)
+	}
+
+	// Idle flows expire
+	clock = clock.Add(UDPRepliedTimeout + time.Second)
+	if !f.ShouldDropFromPeer(reply, "dns") {
+		t.Error("expected reply to an expired flow to be dropped")
+	}
+	if len(f.Flows()) != 0 {
+		t.Error("expected expired flow to be hidden")
+	}
+}
+
+func TestConnTracker_TCPStates(t *testing.T) {
+	local := net.ParseIP("10.42.0.1")
+	remote := net.ParseIP("10.42.0.2")
+
+	f := NewPacketFilter(true)
+	state := func() FlowState {
+		t.Helper()
+		flows := f.Flows()
+		if len(flows) != 1 {
+			t.Fatalf("expected 1 flow, got %d", len(flows))
+		}
+		return flows[0].State
+	}
+
+	f.TrackOutbound(buildTCPPacketWithFlags(local, remote, 40000, 443, 0x02), "web")
+	if got := state(); got != FlowSynSent {
+		t.Errorf("after SYN: got %s", got)
+	}
+	if f.ShouldDropFromPeer(buildTCPPacketWithFlags(remote, local, 443, 40000, 0x12), "web") {
+		t.Error("expected SYN-ACK to pass")
+	}
+	if got := state(); got != FlowSynReceived {
+		t.Errorf("after SYN-ACK: got %s", got)
+	}
+	f.TrackOutbound(buildTCPPacketWithFlags(local, remote, 40000, 443, 0x10), "web")
+	if got := state(); got != FlowEstablished {
+		t.Errorf("after ACK: got %s", got)
+	}
+
+	// A SYN from the server side of the flow is still a new connection
+	if !f.ShouldDropFromPeer(buildTCPPacketWithFlags(remote, local, 40000, 443, 0x02), "web") {
+		t.Error("expected SYN in the originator's direction to be filtered")
+	}
+
+	f.TrackOutbound(buildTCPPacketWithFlags(local, remote, 40000, 443, 0x11), "web")
+	if got := state(); got != FlowClosing {
+		t.Errorf("after FIN: got %s", got)
+	}
+	f.ShouldDropFromPeer(buildTCPPacketWithFlags(remote, local, 443, 40000, 0x11), "web")
+	if got := state(); got != FlowClosed {
+		t.Errorf("after both FINs: got %s", got)
+	}
+
+	// Reusing the ports starts a new flow
+	f.TrackOutbound(buildTCPPacketWithFlags(local, remote, 40000, 443, 0x02), "web")
+	if got := state(); got != FlowSynSent {
+		t.Errorf("after new SYN: got %s", got)
+	}
+}
+
+func TestConnTracker_ICMPEcho(t *testing.T) {
+	local := net.ParseIP("10.42.0.1")
+	remote := net.ParseIP("10.42.0.2")
+
+	f := NewPacketFilter(true)
+	f.TrackOutbound(buildICMPEchoPacket(local, remote, 8, 7), "pinged")
+	if f.ShouldDropFromPeer(buildICMPEchoPacket(remote, local, 0, 7), "pinged") {
+		t.Error("expected echo reply to pass")
+	}
+
+	flows := f.Flows()
+	if len(flows) != 1 || flows[0].State != FlowReplied || flows[0].Protocol != ProtoICMP {
+		t.Fatalf("unexpected flows: %+v", flows)
+	}
+
+	// Inbound pings the rules allow are tracked too
+	if f.ShouldDropFromPeer(buildICMPEchoPacket(remote, local, 8, 9), "pinger") {
+		t.Error("expected echo request to pass")
+	}
+	if got := len(f.Flows()); got != 2 {
+		t.Errorf("expected 2 flows, got %d", got)
+	}
+}
+
+func TestConnTracker_InboundFlowsFollowRules(t *testing.T) {
+	local := net.ParseIP("10.42.0.1")
+	remote := net.ParseIP("10.42.0.2")
+	packet := buildUDPPacket(remote, local, 40000, 5353)
+
+	f := NewPacketFilter(true)
+	f.TrackOutbound(buildUDPPacket(local, remote, 41000, 53), "dns")
+	f.AddTemporaryRule(FilterRule{Port: 5353, Protocol: ProtoUDP, Action: ActionAllow})
+	if f.ShouldDropFromPeer(packet, "peer") {
+		t.Fatal("expected allowed UDP to pass")
+	}
+	flows := f.Flows()
+	if len(flows) != 2 {
+		t.Fatalf("expected 2 flows, got %d", len(flows))
+	}
+
+	// Removing the rule cuts the inbound flow, not the outbound one
+	f.RemoveTemporaryRule(5353, ProtoUDP)
+	if !f.ShouldDropFromPeer(packet, "peer") {
+		t.Error("expected UDP to be dropped after the rule is removed")
+	}
+	flows = f.Flows()
+	if len(flows) != 1 || flows[0].Inbound {
+		t.Errorf("expected only the outbound flow to remain: %+v", flows)
+	}
+}
+
+func TestConnTracker_RuleChangeCutsTCP(t *testing.T) {
+	local := net.ParseIP("10.42.0.1")
+	remote := net.ParseIP("10.42.0.2")
+	data := buildTCPPacketWithFlags(remote, local, 40000, 22, 0x18)
+	ack := buildTCPPacketWithFlags(local, remote, 22, 40000, 0x10)
+
+	f := NewPacketFilter(true)
+	f.AddTemporaryRule(FilterRule{Port: 22, Protocol: ProtoTCP, Action: ActionAllow})
+	if f.ShouldDropFromPeer(buildTCPPacketWithFlags(remote, local, 40000, 22, 0x02), "client") {
+		t.Fatal("expected allowed SYN to pass")
+	}
+	f.TrackOutbound(buildTCPPacketWithFlags(local, remote, 22, 40000, 0x12), "client")
+	if f.ShouldDropFromPeer(data, "client") {
+		t.Fatal("expected data of a tracked connection to pass")
+	}
+
+	// Once the rule is removed, this peer's ACKs don't pick the connection
+	// up again as outbound, which would let the client's data in as replies
+	f.RemoveTemporaryRule(22, ProtoTCP)
+	f.TrackOutbound(ack, "client")
+	if !f.ShouldDropFromPeer(data, "client") {
+		t.Error("expected data of a cut connection to be dropped")
+	}
+	if got := f.conntrack.Len(); got != 0 {
+		t.Errorf("expected no flows for the cut connection, got %d", got)
+	}
+
+	// Connections picked up mid-stream are cut as well, since their
+	// originator is unknown
+	f.TrackOutbound(buildTCPPacketWithFlags(local, remote, 2049, 40001, 0x10), "client")
+	reply := buildTCPPacketWithFlags(remote, local, 40001, 2049, 0x18)
+	if f.ShouldDropFromPeer(reply, "client") {
+		t.Fatal("expected reply of a picked up connection to pass")
+	}
+	f.ClearTemporaryRules()
+	f.TrackOutbound(buildTCPPacketWithFlags(local, remote, 2049, 40001, 0x10), "client")
+	if !f.ShouldDropFromPeer(reply, "client") {
+		t.Error("expected reply of a cut picked up connection to be dropped")
+	}
+
+	// A rule letting the client in again tracks the connection anew
+	f.AddTemporaryRule(FilterRule{Port: 22, Protocol: ProtoTCP, Action: ActionAllow})
+	if f.ShouldDropFromPeer(data, "client") {
+		t.Fatal("expected data to pass once allowed again")
+	}
+	if f.ShouldDropFromPeer(data, "client") {
+		t.Error("expected data of the tracked connection to pass")
+	}
+}
+
+func TestConnTracker_RelatedErrors(t *testing.T) {
+	local := net.ParseIP("10.42.0.1")
+	remote := net.ParseIP("10.42.0.2")
+	router := net.ParseIP("10.42.0.3")
+	unreachable := func(quote []byte) []byte {
+		return BuildIPv4Packet(router, local, ProtoICMP, append([]byte{3, 1, 0, 0, 0, 0, 0, 0}, quote...))
+	}
+
+	f := NewPacketFilter(true)
+	f.SetCoordinatorRules([]FilterRule{{Protocol: ProtoICMP, Action: ActionDeny}})
+
+	// Errors quoting untracked packets, or too short to quote one, are
+	// filtered by the rules
+	syn := buildTCPPacketWithFlags(local, remote, 40000, 443, 0x02)
+	if !f.ShouldDropFromPeer(unreachable(syn), "router") {
+		t.Error("expected error about an untracked connection to be dropped")
+	}
+	if !f.ShouldDropFromPeer(unreachable(syn[:20]), "router") {
+		t.Error("expected error with a truncated quote to be dropped")
+	}
+
+	// Once the connection is tracked, errors about it pass from the peer it
+	// is routed through, with the quote truncated to the first 8 bytes of
+	// the transport header
+	f.TrackOutbound(syn, "router")
+	if f.ShouldDropFromPeer(unreachable(syn[:28]), "router") {
+		t.Error("expected error about a tracked connection to pass")
+	}
+	// Other peers can't send errors about it
+	if !f.ShouldDropFromPeer(unreachable(syn[:28]), "spoofer") {
+		t.Error("expected error about a connection from another peer to be dropped")
+	}
+	// Nor about connections other peers originated
+	inbound := buildTCPPacketWithFlags(remote, local, 40003, 22, 0x02)
+	f.AddTemporaryRule(FilterRule{Port: 22, Protocol: ProtoTCP, Action: ActionAllow})
+	if f.ShouldDropFromPeer(inbound, "client") {
+		t.Fatal("expected allowed SYN to pass")
+	}
+	if !f.ShouldDropFromPeer(unreachable(buildTCPPacketWithFlags(local, remote, 22, 40003, 0x12)), "spoofer") {
+		t.Error("expected error about another peer's connection to be dropped")
+	}
+	if f.ShouldDropFromPeer(unreachable(buildTCPPacketWithFlags(local, remote, 22, 40003, 0x12)), "client") {
+		t.Error("expected error from the connection's peer to pass")
+	}
+
+	// Mid-stream outbound segments are picked up, so replies of connections
+	// predating the table pass once this peer sends
+	ack := buildTCPPacketWithFlags(local, remote, 40001, 443, 0x10)
+	reply := buildTCPPacketWithFlags(remote, local, 443, 40001, 0x10)
+	if !f.ShouldDropFromPeer(reply, "peer") {
+		t.Error("expected untracked reply to be dropped")
+	}
+	f.TrackOutbound(ack, "peer")
+	if f.ShouldDropFromPeer(reply, "peer") {
+		t.Error("expected reply of a picked up connection to pass")
+	}
+	// A RST is not picked up
+	f.TrackOutbound(buildTCPPacketWithFlags(local, remote, 40002, 443, 0x14), "peer")
+	if !f.ShouldDropFromPeer(buildTCPPacketWithFlags(remote, local, 443, 40002, 0x10), "peer") {
+		t.Error("expected reply after RST to be dropped")
+	}
+}
+
+func TestConnTracker_MaxFlows(t *testing.T) {
+	local := net.ParseIP("10.42.0.1")
+	remote := net.ParseIP("10.42.0.2")
+
+	c := NewConnTracker(2)
+	for port := uint16(40000); port < 40003; port++ {
+		c.Outbound(buildUDPPacket(local, remote, port, 53), "dns")
+	}
+	if got := c.Len(); got != 2 {
+		t.Errorf("expected table to hold 2 flows, got %d", got)
+	}
+
+	// Expired flows make room
+	c.now = func() time.Time { return time.Now().Add(UDPUnrepliedTimeout + time.Second) }
+	c.Outbound(buildUDPPacket(local, remote, 40003, 53), "dns")
+	if got := c.Len(); got != 1 {
+		t.Errorf("expected expired flows to be swept, got %d flows", got)
+	}
+
+	c.Flush()
+	if got := c.Len(); got != 0 {
+		t.Errorf("expected empty table after flush, got %d flows", got)
+	}
+}
+
+func TestConnTracker_MaxPeerFlows(t *testing.T) {
+	local := net.ParseIP("10.42.0.1")
+	remote := net.ParseIP("10.42.0.2")
+
+	f := NewPacketFilter(true)
+	f.conntrack = NewConnTracker(4 * peerFlowShare)
+	f.AddTemporaryRule(FilterRule{Port: 5353, Protocol: ProtoUDP, Action: ActionAllow})
+
+	// A peer's inbound flows are capped at its share of the table; the
+	// packets over it are let in by the rules without being tracked
+	for port := uint16(40000); port < 40010; port++ {
+		if f.ShouldDropFromPeer(buildUDPPacket(remote, local, port, 5353), "flooder") {
+			t.Fatalf("expected allowed UDP from port %d to pass", port)
+		}
+	}
+	if got := f.conntrack.Len(); got != 4 {
+		t.Errorf("expected the peer to hold 4 flows, got %d", got)
+	}
+
+	// Other peers and this peer's own connections still get tracked
+	if f.ShouldDropFromPeer(buildUDPPacket(remote, local, 41000, 5353), "other") {
+		t.Fatal("expected allowed UDP from another peer to pass")
+	}
+	f.TrackOutbound(buildUDPPacket(local, remote, 42000, 53), "flooder")
+	if got := f.conntrack.Len(); got != 6 {
+		t.Errorf("expected 6 flows, got %d", got)
+	}
+
+	// Flushing the inbound flows gives the peer its share back
+	f.conntrack.FlushInbound()
+	if f.ShouldDropFromPeer(buildUDPPacket(remote, local, 40010, 5353), "flooder") {
+		t.Fatal("expected allowed UDP to pass")
+	}
+	if got := f.conntrack.Len(); got != 2 {
+		t.Errorf("expected 2 flows after the flush, got %d", got)
+	}
+}
@@ -0,0 +1,313 @@
+package routing
+
+import (
+	"net"
+	"net/netip"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// ExitFailureCooldown is how long an exit peer is skipped after sending
+	// to it failed.
+	ExitFailureCooldown = 30 * time.Second
+	// exitDomainMinHold is the minimum time a destination learned from a DNS
+	// answer stays routed by its domain policy. Connections outlive DNS TTLs,
+	// and switching their exit mid-way breaks them.
+	exitDomainMinHold = 30 * time.Minute
+	// maxExitDomainIPs bounds the destinations learned from DNS answers.
+	maxExitDomainIPs = 65536
+)
+
+// ExitPolicy routes traffic for some destinations through specific exit
+// peers instead of the default ones.
+type ExitPolicy struct {
+	Name    string       // Shown in status output
+	CIDRs   []*net.IPNet // Destination prefixes
+	Domains []string     // Destination domains, including subdomains, learned from DNS answers
+	Peers   []string     // Exit peers in order of preference
+	Country string       // Then any exit peer located in this country
+}
+
+// ExitCandidate is what is known about a peer's fitness as exit peer.
+type ExitCandidate struct {
+	AllowsExit bool   // Peer accepts exit traffic
+	Reachable  bool   // Peer is online and has a tunnel or the relay to it
+	Country    string // Country the peer is located in, if known
+}
+
+// ExitPeerStatus is the state of a configured exit peer.
+type ExitPeerStatus struct {
+	Name    string
+	Healthy bool
+}
+
+// ExitStatus reports the exit peers and the ones in use.
+type ExitStatus struct {
+	Active   string            // Default exit peer in use, empty if none is healthy
+	Peers    []ExitPeerStatus  // Default exit peers in order of preference
+	Policies map[string]string // Policy name -> exit peer in use, empty if none is healthy
+}
+
+type learnedDest struct {
+	policy  int
+	expires time.Time
+}
+
+// ExitSelector picks the exit peer for external traffic: the first healthy
+// peer of the first policy matching the destination, or else the first
+// healthy default exit peer. A peer is healthy when it is reachable and
+// accepts exit traffic, and sending to it has not failed recently. Peers
+// nothing is known about yet are assumed healthy.
+type ExitSelector struct {
+	mu         sync.RWMutex
+	peers      []string
+	policies   []ExitPolicy
+	candidates map[string]ExitCandidate
+	failedAt   map[string]time.Time
+	learned    map[netip.Addr]learnedDest // Destinations learned from DNS answers
+	active     string
+	now        func() time.Time
+}
+
+// NewExitSelector creates an exit selector with no exit peers.
+func NewExitSelector() *ExitSelector {
+	return &ExitSelector{
+		candidates: make(map[string]ExitCandidate),
+		failedAt:   make(map[string]time.Time),
+		learned:    make(map[netip.Addr]learnedDest),
+		now:        time.Now,
+	}
+}
+
+// SetPeers sets the default exit peers, in order of preference.
+func (s *ExitSelector) SetPeers(peers []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.peers = slices.Clone(peers)
+	s.updateActiveLocked()
+}
+
+// SetPolicies sets the per-destination policies, checked in order.
+func (s *ExitSelector) SetPolicies(policies []ExitPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policies = make([]ExitPolicy, len(policies))
+	for i, p := range policies {
+		p.Domains = slices.Clone(p.Domains)
+		for j, d := range p.Domains {
+			p.Domains[j] = strings.TrimSuffix(strings.ToLower(d), ".")
+		}
+		s.policies[i] = p
+	}
+	clear(s.learned)
+}
+
+// Configured reports whether any exit peer or policy is set.
+func (s *ExitSelector) Configured() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.peers) > 0 || len(s.policies) > 0
+}
+
+// UpdateCandidates replaces what is known about the mesh peers.
+func (s *ExitSelector) UpdateCandidates(candidates map[string]ExitCandidate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.candidates = candidates
+	s.updateActiveLocked()
+}
+
+// MarkFailed skips an exit peer for ExitFailureCooldown after sending to it
+// failed.
+func (s *ExitSelector) MarkFailed(peer string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failedAt[peer] = s.now()
+	s.updateActiveLocked()
+}
+
+// healthyLocked reports whether peer can be used as exit peer.
+// Caller must hold s.mu.
+func (s *ExitSelector) healthyLocked(peer string, now time.Time) bool {
+	if failed, ok := s.failedAt[peer]; ok && now.Sub(failed) < ExitFailureCooldown {
+		return false
+	}
+	c, known := s.candidates[peer]
+	return !known || (c.AllowsExit && c.Reachable)
+}
+
+// pickLocked returns the first healthy peer, or "".
+// Caller must hold s.mu.
+func (s *ExitSelector) pickLocked(peers []string, now time.Time) string {
+	for _, peer := range peers {
+		if s.healthyLocked(peer, now) {
+			return peer
+		}
+	}
+	return ""
+}
+
+// pickPolicyLocked returns the exit peer for a policy, or "".
+// Caller must hold s.mu.
+func (s *ExitSelector) pickPolicyLocked(p *ExitPolicy, now time.Time) string {
+	if peer := s.pickLocked(p.Peers, now); peer != "" {
+		return peer
+	}
+	if p.Country == "" {
+		return ""
+	}
+	var located []string
+	for name, c := range s.candidates {
+		if c.AllowsExit && c.Reachable && strings.EqualFold(c.Country, p.Country) {
+			located = append(located, name)
+		}
+	}
+	sort.Strings(located)
+	return s.pickLocked(located, now)
+}
+
+// updateActiveLocked recomputes the default exit peer and logs failovers.
+// Caller must hold s.mu.
+func (s *ExitSelector) updateActiveLocked() {
+	active := s.pickLocked(s.peers, s.now())
+	if active == s.active {
+		return
+	}
+	switch {
+	case active == "":
+		log.Warn().Str("previous", s.active).Msg("no healthy exit peer, internet traffic is dropped")
+	case s.active == "":
+		log.Info().Str("exit_peer", active).Msg("exit peer selected")
+	default:
+		log.Warn().Str("previous", s.active).Str("exit_peer", active).Msg("exit peer failover")
+	}
+	s.active = active
+}
+
+// Select returns the exit peer for a destination outside the mesh, or ""
+// if no exit peer is configured or healthy.
+func (s *ExitSelector) Select(dst net.IP) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.peers) == 0 && len(s.policies) == 0 {
+		return ""
+	}
+
+	now := s.now()
+	addr, _ := netip.AddrFromSlice(dst)
+	learned, isLearned := s.learned[addr.Unmap()]
+	for i := range s.policies {
+		p := &s.policies[i]
+		matched := isLearned && learned.policy == i && now.Before(learned.expires)
+		for _, cidr := range p.CIDRs {
+			if matched {
+				break
+			}
+			matched = cidr.Contains(dst)
+		}
+		if !matched {
+			continue
+		}
+		// Fall back to the default exit peers when none of the policy's is healthy
+		if peer := s.pickPolicyLocked(p, now); peer != "" {
+			return peer
+		}
+		break
+	}
+	return s.pickLocked(s.peers, now)
+}
+
+// Active returns the default exit peer in use, or "" if none is healthy.
+func (s *ExitSelector) Active() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	// Not s.active: a failure cooldown may have ended since the last update
+	return s.pickLocked(s.peers, s.now())
+}
+
+// IsExitPeer reports whether peer may be selected as exit peer, so traffic
+// from the internet arriving from it is expected.
+func (s *ExitSelector) IsExitPeer(peer string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if slices.Contains(s.peers, peer) {
+		return true
+	}
+	c := s.candidates[peer]
+	for i := range s.policies {
+		p := &s.policies[i]
+		if slices.Contains(p.Peers, peer) ||
+			(p.Country != "" && c.AllowsExit && strings.EqualFold(c.Country, p.Country)) {
+			return true
+		}
+	}
+	return false
+}
+
+// LearnDomain routes the addresses a domain resolved to by the first
+// policy listing that domain or a parent of it. Called with the answers of
+// the local DNS resolver.
+func (s *ExitSelector) LearnDomain(name string, ips []net.IP, ttl time.Duration) {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	policy := -1
+	for i, p := range s.policies {
+		for _, domain := range p.Domains {
+			if name == domain || strings.HasSuffix(name, "."+domain) {
+				policy = i
+				break
+			}
+		}
+		if policy >= 0 {
+			break
+		}
+	}
+	if policy < 0 {
+		return
+	}
+
+	now := s.now()
+	if len(s.learned) >= maxExitDomainIPs {
+		for addr, dest := range s.learned {
+			if now.After(dest.expires) {
+				delete(s.learned, addr)
+			}
+		}
+	}
+	expires := now.Add(max(ttl, exitDomainMinHold))
+	for _, ip := range ips {
+		addr, ok := netip.AddrFromSlice(ip)
+		if !ok || (len(s.learned) >= maxExitDomainIPs && s.learned[addr.Unmap()].expires.IsZero()) {
+			continue
+		}
+		s.learned[addr.Unmap()] = learnedDest{policy: policy, expires: expires}
+	}
+}
+
+// Status returns the configured exit peers and the ones in use.
+func (s *ExitSelector) Status() ExitStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := s.now()
+	status := ExitStatus{Active: s.pickLocked(s.peers, now)}
+	for _, peer := range s.peers {
+		status.Peers = append(status.Peers, ExitPeerStatus{Name: peer, Healthy: s.healthyLocked(peer, now)})
+	}
+	if len(s.policies) > 0 {
+		status.Policies = make(map[string]string, len(s.policies))
+		for i := range s.policies {
+			status.Policies[s.policies[i].Name] = s.pickPolicyLocked(&s.policies[i], now)
+		}
+	}
+	return status
+}
@@ -0,0 +1,167 @@
+package routing
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestExitSelector(now *time.Time) *ExitSelector {
+	s := NewExitSelector()
+	s.now = func() time.Time { return *now }
+	return s
+}
+
+func TestExitSelector_Failover(t *testing.T) {
+	now := time.Now()
+	s := newTestExitSelector(&now)
+	dst := net.ParseIP("8.8.8.8")
+
+	assert.Empty(t, s.Select(dst), "no exit peer configured")
+	assert.False(t, s.Configured())
+
+	s.SetPeers([]string{"exit-a", "exit-b"})
+	assert.True(t, s.Configured())
+	assert.Equal(t, "exit-a", s.Select(dst), "unknown peers are assumed healthy")
+
+	// exit-a goes offline
+	s.UpdateCandidates(map[string]ExitCandidate{
+		"exit-a": {AllowsExit: true},
+		"exit-b": {AllowsExit: true, Reachable: true},
+	})
+	assert.Equal(t, "exit-b", s.Select(dst))
+	assert.Equal(t, "exit-b", s.Active())
+
+	// exit-a comes back
+	s.UpdateCandidates(map[string]ExitCandidate{
+		"exit-a": {AllowsExit: true, Reachable: true},
+		"exit-b": {AllowsExit: true, Reachable: true},
+	})
+	assert.Equal(t, "exit-a", s.Active())
+
+	// A peer not accepting exit traffic is skipped
+	s.UpdateCandidates(map[string]ExitCandidate{
+		"exit-a": {Reachable: true},
+		"exit-b": {AllowsExit: true, Reachable: true},
+	})
+	assert.Equal(t, "exit-b", s.Active())
+
+	// No healthy exit peer
+	s.UpdateCandidates(map[string]ExitCandidate{
+		"exit-a": {Reachable: true},
+		"exit-b": {AllowsExit: true},
+	})
+	assert.Empty(t, s.Active())
+	assert.Empty(t, s.Select(dst))
+}
+
+func TestExitSelector_MarkFailedCooldown(t *testing.T) {
+	now := time.Now()
+	s := newTestExitSelector(&now)
+	s.SetPeers([]string{"exit-a", "exit-b"})
+
+	s.MarkFailed("exit-a")
+	assert.Equal(t, "exit-b", s.Active())
+
+	now = now.Add(ExitFailureCooldown - time.Second)
+	assert.Equal(t, "exit-b", s.Active())
+
+	now = now.Add(2 * time.Second)
+	assert.Equal(t, "exit-a", s.Active(), "exit-a is retried after the cooldown")
+	assert.Equal(t, "exit-a", s.Select(net.ParseIP("1.1.1.1")))
+}
+
+func TestExitSelector_CIDRPolicy(t *testing.T) {
+	now := time.Now()
+	s := newTestExitSelector(&now)
+	_, corp, _ := net.ParseCIDR("203.0.113.0/24")
+	_, v6, _ := net.ParseCIDR("2001:db8::/32")
+	s.SetPeers([]string{"exit-home"})
+	s.SetPolicies([]ExitPolicy{
+		{Name: "corp", CIDRs: []*net.IPNet{corp, v6}, Peers: []string{"exit-office"}},
+	})
+
+	assert.Equal(t, "exit-office", s.Select(net.ParseIP("203.0.113.7")))
+	assert.Equal(t, "exit-office", s.Select(net.ParseIP("2001:db8::1")))
+	assert.Equal(t, "exit-home", s.Select(net.ParseIP("8.8.8.8")))
+
+	// Falls back to the default exit peers when the policy's are down
+	s.MarkFailed("exit-office")
+	assert.Equal(t, "exit-home", s.Select(net.ParseIP("203.0.113.7")))
+}
+
+func TestExitSelector_CountryPolicy(t *testing.T) {
+	now := time.Now()
+	s := newTestExitSelector(&now)
+	_, streaming, _ := net.ParseCIDR("198.51.100.0/24")
+	s.SetPeers([]string{"exit-home"})
+	s.SetPolicies([]ExitPolicy{
+		{Name: "uk", CIDRs: []*net.IPNet{streaming}, Country: "GB"},
+	})
+	s.UpdateCandidates(map[string]ExitCandidate{
+		"exit-home":   {AllowsExit: true, Reachable: true, Country: "US"},
+		"london-2":    {AllowsExit: true, Reachable: true, Country: "gb"},
+		"london-1":    {AllowsExit: true, Reachable: true, Country: "GB"},
+		"manchester":  {Reachable: true, Country: "GB"},
+		"edinburgh-x": {AllowsExit: true, Country: "GB"},
+	})
+
+	dst := net.ParseIP("198.51.100.10")
+	assert.Equal(t, "london-1", s.Select(dst))
+
+	s.MarkFailed("london-1")
+	assert.Equal(t, "london-2", s.Select(dst))
+
+	s.MarkFailed("london-2")
+	assert.Equal(t, "exit-home", s.Select(dst))
+}
+
+func TestExitSelector_DomainPolicy(t *testing.T) {
+	now := time.Now()
+	s := newTestExitSelector(&now)
+	s.SetPeers([]string{"exit-home"})
+	s.SetPolicies([]ExitPolicy{
+		{Name: "video", Domains: []string{"Example.COM."}, Peers: []string{"exit-video"}},
+	})
+
+	learned := net.ParseIP("192.0.2.1")
+	assert.Equal(t, "exit-home", s.Select(learned), "not learned yet")
+
+	s.LearnDomain("cdn.example.com.", []net.IP{learned, net.ParseIP("2001:db8::5")}, time.Minute)
+	s.LearnDomain("example.org.", []net.IP{net.ParseIP("192.0.2.2")}, time.Minute)
+	s.LearnDomain("notexample.com.", []net.IP{net.ParseIP("192.0.2.3")}, time.Minute)
+
+	assert.Equal(t, "exit-video", s.Select(learned))
+	assert.Equal(t, "exit-video", s.Select(learned.To4()))
+	assert.Equal(t, "exit-video", s.Select(net.ParseIP("2001:db8::5")))
+	assert.Equal(t, "exit-home", s.Select(net.ParseIP("192.0.2.2")))
+	assert.Equal(t, "exit-home", s.Select(net.ParseIP("192.0.2.3")))
+
+	// Learned destinations are kept past the TTL, then expire
+	now = now.Add(exitDomainMinHold - time.Second)
+	assert.Equal(t, "exit-video", s.Select(learned))
+	now = now.Add(2 * time.Second)
+	assert.Equal(t, "exit-home", s.Select(learned))
+}
+
+func TestExitSelector_Status(t *testing.T) {
+	now := time.Now()
+	s := newTestExitSelector(&now)
+	_, corp, _ := net.ParseCIDR("203.0.113.0/24")
+	s.SetPeers([]string{"exit-a", "exit-b"})
+	s.SetPolicies([]ExitPolicy{
+		{Name: "corp", CIDRs: []*net.IPNet{corp}, Peers: []string{"exit-office"}},
+		{Name: "uk", CIDRs: []*net.IPNet{corp}, Country: "GB"},
+	})
+	s.MarkFailed("exit-a")
+
+	status := s.Status()
+	assert.Equal(t, "exit-b", status.Active)
+	require.Len(t, status.Peers, 2)
+	assert.Equal(t, ExitPeerStatus{Name: "exit-a", Healthy: false}, status.Peers[0])
+	assert.Equal(t, ExitPeerStatus{Name: "exit-b", Healthy: true}, status.Peers[1])
+	assert.Equal(t, map[string]string{"corp": "exit-office", "uk": ""}, status.Policies)
+}
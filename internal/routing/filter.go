@@ -3,6 +3,12 @@ package routing
 
 import (
 	"encoding/binary"
+	"errors"
+	"fmt"
+	"maps"
+	"net/netip"
+	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -67,11 +73,15 @@ func ParseFilterAction(s string) FilterAction {
 
 // ProtocolFromString converts a protocol name to its number.
 func ProtocolFromString(s string) uint8 {
-	switch s {
-	case "tcp", "TCP":
+	switch strings.ToLower(s) {
+	case "tcp":
 		return ProtoTCP
-	case "udp", "UDP":
+	case "udp":
 		return ProtoUDP
+	case "icmp":
+		return ProtoICMP
+	case "icmpv6":
+		return ProtoICMPv6
 	default:
 		return 0
 	}
@@ -84,18 +94,32 @@ func ProtocolToString(proto uint8) string {
 		return "tcp"
 	case ProtoUDP:
 		return "udp"
+	case ProtoICMP:
+		return "icmp"
+	case ProtoICMPv6:
+		return "icmpv6"
 	default:
 		return "other"
 	}
 }
 
+// isICMP reports whether proto is ICMP or ICMPv6.
+func isICMP(proto uint8) bool {
+	return proto == ProtoICMP || proto == ProtoICMPv6
+}
+
 // FilterRule represents a single packet filter rule.
 type FilterRule struct {
-	Port       uint16       // Port number (1-65535)
-	Protocol   uint8        // 6=TCP, 17=UDP
-	Action     FilterAction // Allow or deny
-	Expires    int64        // Unix timestamp, 0=permanent
-	SourcePeer string       // Source peer name (empty = any peer)
+	Port        uint16       // Port number (1-65535), first of a range when PortEnd is set
+	PortEnd     uint16       // Last port of a range (0 = Port only)
+	Protocol    uint8        // 6=TCP, 17=UDP, 1=ICMP, 58=ICMPv6
+	ICMPType    uint8        // ICMP message type (0 = any type)
+	Action      FilterAction // Allow or deny
+	Expires     int64        // Unix timestamp, 0=permanent
+	SourcePeer  string       // Source peer name (empty = any peer)
+	SourceCIDR  string       // Source address prefix (empty = any address)
+	SourceGroup string       // RBAC group of the source peer (empty = any peer)
+	DestGroup   string       // RBAC group this peer must be in (empty = every peer)
 }
 
 // IsExpired returns true if the rule has expired.
@@ -103,12 +127,92 @@ func (r FilterRule) IsExpired() bool {
 	return r.Expires > 0 && time.Now().Unix() > r.Expires
 }
 
+// Key returns the map key identifying the rule: its selectors, with a
+// single-port range collapsed and the source CIDR in canonical form.
+func (r FilterRule) Key() FilterRuleKey {
+	key := FilterRuleKey{
+		Port:        r.Port,
+		PortEnd:     r.PortEnd,
+		Protocol:    r.Protocol,
+		ICMPType:    r.ICMPType,
+		SourcePeer:  r.SourcePeer,
+		SourceCIDR:  r.SourceCIDR,
+		SourceGroup: r.SourceGroup,
+		DestGroup:   r.DestGroup,
+	}
+	if key.PortEnd == key.Port {
+		key.PortEnd = 0
+	}
+	if prefix, err := ParseSourceCIDR(r.SourceCIDR); err == nil && prefix.IsValid() {
+		key.SourceCIDR = prefix.String()
+	}
+	return key
+}
+
+// Validate checks that the rule's selectors fit its protocol.
+func (r FilterRule) Validate() error {
+	switch r.Protocol {
+	case ProtoTCP, ProtoUDP:
+		if r.Port == 0 {
+			return errors.New("invalid port: must be between 1 and 65535 (port is required)")
+		}
+		if r.PortEnd != 0 && r.PortEnd < r.Port {
+			return fmt.Errorf("invalid port range %d-%d: end is below start", r.Port, r.PortEnd)
+		}
+		if r.ICMPType != 0 {
+			return errors.New("icmp type requires protocol icmp or icmpv6")
+		}
+	case ProtoICMP, ProtoICMPv6:
+		if r.Port != 0 || r.PortEnd != 0 {
+			return errors.New("ports do not apply to icmp rules")
+		}
+	default:
+		return errors.New("invalid protocol - must be one of: tcp, udp, icmp, icmpv6")
+	}
+	if _, err := ParseSourceCIDR(r.SourceCIDR); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ParseSourceCIDR parses a rule's source prefix. A bare address is taken as
+// a single-host prefix. Returns the zero Prefix for an empty string.
+func ParseSourceCIDR(s string) (netip.Prefix, error) {
+	if s == "" {
+		return netip.Prefix{}, nil
+	}
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid source CIDR %q", s)
+		}
+		return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid source CIDR %q", s)
+	}
+	return prefix.Masked(), nil
+}
+
 // FilterRuleKey is used as map key for O(1) lookup.
 // SourcePeer is included for peer-specific rules; empty means any peer (global rule).
 type FilterRuleKey struct {
-	Port       uint16
-	Protocol   uint8
-	SourcePeer string // Empty = any peer (global rule)
+	Port        uint16
+	PortEnd     uint16 // 0 = single port
+	Protocol    uint8
+	ICMPType    uint8
+	SourcePeer  string // Empty = any peer (global rule)
+	SourceCIDR  string
+	SourceGroup string
+	DestGroup   string
+}
+
+// scanned reports whether rules with this key need a linear scan: a packet
+// can only be turned into the keys of single-port rules without CIDRs or
+// groups.
+func (k FilterRuleKey) scanned() bool {
+	return k.PortEnd != 0 || k.SourceCIDR != "" || k.SourceGroup != "" || k.DestGroup != ""
 }
 
 // FilterRuleWithSource combines a rule with its source for display.
@@ -117,24 +221,88 @@ type FilterRuleWithSource struct {
 	Source RuleSource
 }
 
-// ruleMap is the type used for atomic pointer storage.
+// ruleMap holds the rules of a layer by key.
 type ruleMap map[FilterRuleKey]FilterRule
 
-// PacketFilter filters incoming packets based on port and protocol.
+// ruleLayer is the type used for atomic pointer storage. Rules matched by
+// port range, CIDR or group are also listed in scan.
+type ruleLayer struct {
+	rules ruleMap
+	scan  []scanRule
+}
+
+// scanRule is a rule matched by a linear scan, with its source CIDR parsed.
+type scanRule struct {
+	rule   FilterRule
+	prefix netip.Prefix
+}
+
+// newRuleLayer indexes rules for lookup.
+func newRuleLayer(rules ruleMap) *ruleLayer {
+	layer := &ruleLayer{rules: rules}
+	for key, rule := range rules {
+		if !key.scanned() {
+			continue
+		}
+		prefix, err := ParseSourceCIDR(key.SourceCIDR)
+		if err != nil {
+			continue // Rejected by Validate; never matches
+		}
+		layer.scan = append(layer.scan, scanRule{rule: rule, prefix: prefix})
+	}
+	return layer
+}
+
+// filterGroups holds the RBAC group memberships that group rules match on.
+type filterGroups struct {
+	peers   map[string][]string         // Peer name -> groups
+	local   []string                    // Groups of this peer
+	clients map[netip.Addr]ClientGroups // Hosts behind peers by address, see SetClientGroups
+}
+
+// ClientGroups are the groups of a host behind a peer, such as a WireGuard
+// client of a concentrator, and the peer its traffic comes through.
+type ClientGroups struct {
+	Peer   string
+	Groups []string
+}
+
+// sourceIn reports whether the packet comes from a member of a group: a
+// peer in it, or a host in it behind the peer it came through.
+func (g *filterGroups) sourceIn(pkt packetInfo, group string) bool {
+	if pkt.sourcePeer == "" {
+		return false
+	}
+	if slices.Contains(g.peers[pkt.sourcePeer], group) {
+		return true
+	}
+	client, ok := g.clients[pkt.src]
+	return ok && client.Peer == pkt.sourcePeer && slices.Contains(client.Groups, group)
+}
+
+// PacketFilter filters incoming packets based on port, protocol and source.
 // Uses a 4-layer rule system with copy-on-write for lock-free reads.
 //
+// The filter is stateful: packets of flows this peer initiated, or that the
+// rules let in, pass without rule checks (see ConnTracker).
+//
 // Rule precedence (most restrictive wins):
 //   - If ANY layer denies, the packet is denied
 //   - Allow only wins if no layer denies
 type PacketFilter struct {
 	// Separate maps for each layer - enables clean replacement
-	coordinator atomic.Pointer[ruleMap] // Global rules from server config
-	peerConfig  atomic.Pointer[ruleMap] // Local rules from peer.yaml
-	temporary   atomic.Pointer[ruleMap] // CLI / admin panel (runtime)
-	service     atomic.Pointer[ruleMap] // Auto-generated for coordinator services (read-only)
+	coordinator atomic.Pointer[ruleLayer] // Global rules from server config
+	peerConfig  atomic.Pointer[ruleLayer] // Local rules from peer.yaml
+	temporary   atomic.Pointer[ruleLayer] // CLI / admin panel (runtime)
+	service     atomic.Pointer[ruleLayer] // Auto-generated for coordinator services (read-only)
+
+	groups atomic.Pointer[filterGroups] // Group memberships for group rules
 
-	mu          sync.Mutex // Serializes writes
-	defaultDeny bool       // If true, deny by default (allowlist mode)
+	conntrack *ConnTracker // Flows whose packets pass without rule checks
+
+	mu              sync.Mutex  // Serializes writes
+	defaultDeny     bool        // If true, deny by default (allowlist mode)
+	icmpDefaultDeny atomic.Bool // If true, the default policy applies to ICMP too
 }
 
 // NewPacketFilter creates a new packet filter.
@@ -142,19 +310,65 @@ type PacketFilter struct {
 func NewPacketFilter(defaultDeny bool) *PacketFilter {
 	f := &PacketFilter{
 		defaultDeny: defaultDeny,
+		conntrack:   NewConnTracker(DefaultMaxFlows),
 	}
 	// Initialize empty maps
-	emptyCoord := make(ruleMap)
-	emptyConfig := make(ruleMap)
-	emptyTemp := make(ruleMap)
-	emptyService := make(ruleMap)
-	f.coordinator.Store(&emptyCoord)
-	f.peerConfig.Store(&emptyConfig)
-	f.temporary.Store(&emptyTemp)
-	f.service.Store(&emptyService)
+	f.coordinator.Store(newRuleLayer(make(ruleMap)))
+	f.peerConfig.Store(newRuleLayer(make(ruleMap)))
+	f.temporary.Store(newRuleLayer(make(ruleMap)))
+	f.service.Store(newRuleLayer(make(ruleMap)))
+	f.groups.Store(&filterGroups{})
 	return f
 }
 
+// SetICMPDefaultDeny sets whether ICMP not matched by any rule is subject to
+// the default policy. By default it is allowed, so diagnostics keep working
+// in allowlist mode.
+func (f *PacketFilter) SetICMPDefaultDeny(deny bool) {
+	f.icmpDefaultDeny.Store(deny)
+}
+
+// TrackOutbound records a packet sent into the mesh through the given peer
+// in the connection table, so replies to it from that peer are let in.
+func (f *PacketFilter) TrackOutbound(packet []byte, peer string) {
+	f.conntrack.Outbound(packet, peer)
+}
+
+// Flows returns the flows in the connection table.
+func (f *PacketFilter) Flows() []Flow {
+	return f.conntrack.Flows()
+}
+
+// SetGroups sets the RBAC group memberships that group rules match on: the
+// groups of each peer by name, and those of this peer for destination groups.
+func (f *PacketFilter) SetGroups(peerGroups map[string][]string, localGroups []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	old := f.groups.Load()
+	f.groups.Store(&filterGroups{peers: peerGroups, local: localGroups, clients: old.clients})
+	if !slices.Equal(old.local, localGroups) || !maps.EqualFunc(old.peers, peerGroups, slices.Equal[[]string]) {
+		f.conntrack.FlushInbound()
+	}
+}
+
+// SetClientGroups sets the groups of hosts behind peers by address, such as
+// the WireGuard clients of a concentrator. Source group rules match a
+// client's packets only when they come through its peer, which is trusted
+// to forward the client's traffic only.
+func (f *PacketFilter) SetClientGroups(clients map[netip.Addr]ClientGroups) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	old := f.groups.Load()
+	f.groups.Store(&filterGroups{peers: old.peers, local: old.local, clients: clients})
+	if !maps.EqualFunc(old.clients, clients, func(a, b ClientGroups) bool {
+		return a.Peer == b.Peer && slices.Equal(a.Groups, b.Groups)
+	}) {
+		f.conntrack.FlushInbound()
+	}
+}
+
 // SetCoordinatorRules replaces all coordinator-level rules.
 // These are global rules pushed from the server config.
 func (f *PacketFilter) SetCoordinatorRules(rules []FilterRule) {
@@ -163,10 +377,10 @@ func (f *PacketFilter) SetCoordinatorRules(rules []FilterRule) {
 
 	newMap := make(ruleMap, len(rules))
 	for _, r := range rules {
-		key := FilterRuleKey{Port: r.Port, Protocol: r.Protocol, SourcePeer: r.SourcePeer}
-		newMap[key] = r
+		newMap[r.Key()] = r
 	}
-	f.coordinator.Store(&newMap)
+	f.coordinator.Store(newRuleLayer(newMap))
+	f.conntrack.FlushInbound()
 }
 
 // SetPeerConfigRules replaces all peer config-level rules.
@@ -177,10 +391,10 @@ func (f *PacketFilter) SetPeerConfigRules(rules []FilterRule) {
 
 	newMap := make(ruleMap, len(rules))
 	for _, r := range rules {
-		key := FilterRuleKey{Port: r.Port, Protocol: r.Protocol, SourcePeer: r.SourcePeer}
-		newMap[key] = r
+		newMap[r.Key()] = r
 	}
-	f.peerConfig.Store(&newMap)
+	f.peerConfig.Store(newRuleLayer(newMap))
+	f.conntrack.FlushInbound()
 }
 
 // SetServiceRules replaces all service-level rules.
@@ -192,10 +406,10 @@ func (f *PacketFilter) SetServiceRules(rules []FilterRule) {
 
 	newMap := make(ruleMap, len(rules))
 	for _, r := range rules {
-		key := FilterRuleKey{Port: r.Port, Protocol: r.Protocol, SourcePeer: r.SourcePeer}
-		newMap[key] = r
+		newMap[r.Key()] = r
 	}
-	f.service.Store(&newMap)
+	f.service.Store(newRuleLayer(newMap))
+	f.conntrack.FlushInbound()
 }
 
 // AddTemporaryRule adds a rule to the temporary layer.
@@ -205,16 +419,15 @@ func (f *PacketFilter) AddTemporaryRule(rule FilterRule) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	key := FilterRuleKey{Port: rule.Port, Protocol: rule.Protocol, SourcePeer: rule.SourcePeer}
-
 	// Copy-on-write
-	oldMap := f.temporary.Load()
-	newMap := make(ruleMap, len(*oldMap)+1)
-	for k, v := range *oldMap {
+	oldMap := f.temporary.Load().rules
+	newMap := make(ruleMap, len(oldMap)+1)
+	for k, v := range oldMap {
 		newMap[k] = v
 	}
-	newMap[key] = rule
-	f.temporary.Store(&newMap)
+	newMap[rule.Key()] = rule
+	f.temporary.Store(newRuleLayer(newMap))
+	f.conntrack.FlushInbound()
 }
 
 // RemoveTemporaryRule removes a global rule from the temporary layer.
@@ -226,24 +439,29 @@ func (f *PacketFilter) RemoveTemporaryRule(port uint16, protocol uint8) {
 // RemoveTemporaryRuleForPeer removes a rule from the temporary layer for a specific peer.
 // Pass empty string for sourcePeer to remove global rules.
 func (f *PacketFilter) RemoveTemporaryRuleForPeer(port uint16, protocol uint8, sourcePeer string) {
+	f.RemoveTemporaryRuleKey(FilterRuleKey{Port: port, Protocol: protocol, SourcePeer: sourcePeer})
+}
+
+// RemoveTemporaryRuleKey removes the temporary rule with the given key, as
+// returned by FilterRule.Key.
+func (f *PacketFilter) RemoveTemporaryRuleKey(key FilterRuleKey) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	key := FilterRuleKey{Port: port, Protocol: protocol, SourcePeer: sourcePeer}
-
-	oldMap := f.temporary.Load()
-	if _, exists := (*oldMap)[key]; !exists {
+	oldMap := f.temporary.Load().rules
+	if _, exists := oldMap[key]; !exists {
 		return // Nothing to remove
 	}
 
 	// Copy-on-write
-	newMap := make(ruleMap, len(*oldMap))
-	for k, v := range *oldMap {
+	newMap := make(ruleMap, len(oldMap))
+	for k, v := range oldMap {
 		if k != key {
 			newMap[k] = v
 		}
 	}
-	f.temporary.Store(&newMap)
+	f.temporary.Store(newRuleLayer(newMap))
+	f.conntrack.FlushInbound()
 }
 
 // ClearTemporaryRules removes all temporary rules.
@@ -251,75 +469,160 @@ func (f *PacketFilter) ClearTemporaryRules() {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	emptyMap := make(ruleMap)
-	f.temporary.Store(&emptyMap)
+	f.temporary.Store(newRuleLayer(make(ruleMap)))
+	f.conntrack.FlushInbound()
+}
+
+// packetInfo holds the parts of a packet that filter rules match on.
+type packetInfo struct {
+	protocol   uint8
+	port       uint16 // Destination port for TCP/UDP
+	icmpType   uint8  // Message type for ICMP/ICMPv6
+	src        netip.Addr
+	sourcePeer string // Empty = match global rules only
 }
 
-// effectiveAction determines the action for a port/protocol/peer combination.
+// effectiveAction determines the action for a packet.
 // Returns (action, matched). If not matched, returns (deny/allow based on default, false).
-// The sourcePeer parameter allows for peer-specific rule matching (empty = any peer).
-func (f *PacketFilter) effectiveAction(port uint16, protocol uint8, sourcePeer string) (FilterAction, bool) {
+//
+// ICMP is not subject to the default policy unless SetICMPDefaultDeny is set,
+// so diagnostics keep working in allowlist mode, and rules for the packet's
+// ICMP type take precedence over rules for any type: "deny icmp" with "allow
+// icmp type 8" allows ping only.
+func (f *PacketFilter) effectiveAction(pkt packetInfo) (FilterAction, bool) {
 	// Load all layers once (lock-free)
-	coordRules := f.coordinator.Load()
-	configRules := f.peerConfig.Load()
-	tempRules := f.temporary.Load()
-	serviceRules := f.service.Load()
+	layers := []*ruleLayer{f.coordinator.Load(), f.peerConfig.Load(), f.temporary.Load(), f.service.Load()}
+	groups := f.groups.Load()
 
-	layers := []*ruleMap{coordRules, configRules, tempRules, serviceRules}
+	if isICMP(pkt.protocol) {
+		if pkt.icmpType != 0 {
+			if action, ok := matchAction(layers, pkt, pkt.icmpType, groups); ok {
+				return action, true
+			}
+		}
+		if action, ok := matchAction(layers, pkt, 0, groups); ok {
+			return action, true
+		}
+		if f.defaultDeny && f.icmpDefaultDeny.Load() {
+			return ActionDeny, false
+		}
+		return ActionAllow, false
+	}
 
-	// Check if ANY layer denies - deny wins (most restrictive)
-	// Check peer-specific rules first, then global rules
+	if action, ok := matchAction(layers, pkt, 0, groups); ok {
+		return action, true
+	}
+
+	// No matching rule - apply default policy
+	if f.defaultDeny {
+		return ActionDeny, false
+	}
+	return ActionAllow, false
+}
+
+// matchAction returns the action of the rules matching the packet, for ICMP
+// those of the given type (0 = any). If ANY matching rule in any layer
+// denies, the packet is denied; allow only wins if none does.
+func matchAction(layers []*ruleLayer, pkt packetInfo, icmpType uint8, groups *filterGroups) (FilterAction, bool) {
+	// Keys of single-port rules: peer-specific and global
+	keys := [2]FilterRuleKey{
+		{Port: pkt.port, Protocol: pkt.protocol, ICMPType: icmpType},
+		{Port: pkt.port, Protocol: pkt.protocol, ICMPType: icmpType, SourcePeer: pkt.sourcePeer},
+	}
+	numKeys := 1
+	if pkt.sourcePeer != "" {
+		numKeys = 2
+	}
+
+	allowed := false
 	for _, layer := range layers {
-		// First check peer-specific deny
-		if sourcePeer != "" {
-			peerKey := FilterRuleKey{Port: port, Protocol: protocol, SourcePeer: sourcePeer}
-			if rule, ok := (*layer)[peerKey]; ok {
-				if !rule.IsExpired() && rule.Action == ActionDeny {
+		for _, key := range keys[:numKeys] {
+			if rule, ok := layer.rules[key]; ok && !rule.IsExpired() {
+				if rule.Action == ActionDeny {
 					return ActionDeny, true
 				}
+				allowed = true
 			}
 		}
-		// Then check global deny
-		globalKey := FilterRuleKey{Port: port, Protocol: protocol, SourcePeer: ""}
-		if rule, ok := (*layer)[globalKey]; ok {
-			if !rule.IsExpired() && rule.Action == ActionDeny {
-				return ActionDeny, true
+		for i := range layer.scan {
+			sr := &layer.scan[i]
+			if sr.matches(pkt, icmpType, groups) && !sr.rule.IsExpired() {
+				if sr.rule.Action == ActionDeny {
+					return ActionDeny, true
+				}
+				allowed = true
 			}
 		}
 	}
 
-	// Check if any layer explicitly allows (reverse order for precedence display)
-	// Check peer-specific allow first, then global allow
-	for _, layer := range []*ruleMap{serviceRules, tempRules, configRules, coordRules} {
-		// First check peer-specific allow
-		if sourcePeer != "" {
-			peerKey := FilterRuleKey{Port: port, Protocol: protocol, SourcePeer: sourcePeer}
-			if rule, ok := (*layer)[peerKey]; ok {
-				if !rule.IsExpired() && rule.Action == ActionAllow {
-					return ActionAllow, true
-				}
-			}
-		}
-		// Then check global allow
-		globalKey := FilterRuleKey{Port: port, Protocol: protocol, SourcePeer: ""}
-		if rule, ok := (*layer)[globalKey]; ok {
-			if !rule.IsExpired() && rule.Action == ActionAllow {
-				return ActionAllow, true
-			}
+	if allowed {
+		return ActionAllow, true
+	}
+	return ActionDeny, false
+}
+
+// matches reports whether the rule applies to the packet, for ICMP to
+// messages of the given type (0 = any).
+func (sr *scanRule) matches(pkt packetInfo, icmpType uint8, groups *filterGroups) bool {
+	r := &sr.rule
+	if r.Protocol != pkt.protocol {
+		return false
+	}
+	if isICMP(r.Protocol) {
+		if r.ICMPType != icmpType {
+			return false
 		}
+	} else if pkt.port < r.Port || pkt.port > max(r.Port, r.PortEnd) {
+		return false
+	}
+	if r.SourcePeer != "" && r.SourcePeer != pkt.sourcePeer {
+		return false
+	}
+	if sr.prefix.IsValid() && !sr.prefix.Contains(pkt.src) {
+		return false
 	}
+	if r.SourceGroup != "" && !groups.sourceIn(pkt, r.SourceGroup) {
+		return false
+	}
+	if r.DestGroup != "" && !slices.Contains(groups.local, r.DestGroup) {
+		return false
+	}
+	return true
+}
 
-	// No matching rule - apply default policy
-	if f.defaultDeny {
-		return ActionDeny, false
+// isICMPErrorType reports whether an ICMP message type is an error, which
+// quotes the packet it reports on.
+func isICMPErrorType(protocol, icmpType uint8) bool {
+	if protocol == ProtoICMPv6 {
+		return icmpType < 128 // Types below 128 are errors
 	}
-	return ActionAllow, false
+	switch icmpType {
+	case 3, 4, 5, 11, 12: // Unreachable, source quench, redirect, time exceeded, parameter problem
+		return true
+	}
+	return false
+}
+
+// isNeighborDiscovery reports whether an ICMPv6 message is neighbor
+// discovery (router and neighbor solicitations and advertisements), which
+// always passes.
+func isNeighborDiscovery(protocol, icmpType uint8) bool {
+	return protocol == ProtoICMPv6 && icmpType >= 133 && icmpType <= 136
+}
+
+// sourceAddr returns the source address of an IPv4 or IPv6 packet whose
+// header TransportHeader accepted.
+func sourceAddr(packet []byte) netip.Addr {
+	if packet[0]>>4 == 6 {
+		return netip.AddrFrom16([16]byte(packet[8:24]))
+	}
+	return netip.AddrFrom4([4]byte(packet[12:16]))
 }
 
 // FilterResult contains the result of a filter check.
 type FilterResult struct {
 	Drop       bool   // Whether to drop the packet
-	Protocol   uint8  // Protocol that was filtered (6=TCP, 17=UDP, 0=not filtered)
+	Protocol   uint8  // Protocol that was filtered (6=TCP, 17=UDP, 1/58=ICMP, 0=not filtered)
 	SourcePeer string // Source peer that was checked (for metrics)
 }
 
@@ -348,48 +651,59 @@ func (f *PacketFilter) CheckPacket(packet []byte) FilterResult {
 // The sourcePeer parameter enables peer-specific rule matching.
 // Pass empty string for sourcePeer to only match global rules.
 func (f *PacketFilter) CheckPacketFromPeer(packet []byte, sourcePeer string) FilterResult {
-	// Parse IP header to get protocol
-	if len(packet) < 20 {
+	// Find the transport header, after any IPv6 extension headers
+	protocol, offset, ok := TransportHeader(packet)
+	if !ok {
 		return FilterResult{Drop: false, Protocol: 0, SourcePeer: sourcePeer}
 	}
 
-	protocol := packet[9]
-
-	// Only filter TCP (6) and UDP (17)
-	if protocol != ProtoTCP && protocol != ProtoUDP {
+	// Packets of tracked flows are established traffic. Anything else,
+	// including TCP segments other than SYNs and echo replies, is checked
+	// against the rules like a new connection, so peers can't inject
+	// packets into ports the rules deny.
+	fp, trackable := parseFlowPacket(packet, protocol, offset)
+	if trackable && f.conntrack.observe(fp, true, sourcePeer) {
 		return FilterResult{Drop: false, Protocol: 0, SourcePeer: sourcePeer}
 	}
 
-	// Get IP header length
-	ihl := int(packet[0]&0x0F) * 4
-	if len(packet) < ihl+4 {
-		return FilterResult{Drop: false, Protocol: 0, SourcePeer: sourcePeer}
-	}
+	pkt := packetInfo{protocol: protocol, sourcePeer: sourcePeer}
+	switch protocol {
+	case ProtoTCP, ProtoUDP:
+		if len(packet) < offset+4 {
+			return FilterResult{Drop: false, Protocol: 0, SourcePeer: sourcePeer}
+		}
 
-	// For TCP, only filter NEW connections (SYN without ACK).
-	// This allows response packets (SYN-ACK, ACK, etc.) to pass through,
-	// enabling outgoing connections to work even in allowlist mode.
-	if protocol == ProtoTCP {
-		// TCP header must be at least 20 bytes, flags are at offset 13
-		if len(packet) < ihl+14 {
+		// Extract destination port from TCP/UDP header (bytes 2-3)
+		pkt.port = binary.BigEndian.Uint16(packet[offset+2 : offset+4])
+
+	case ProtoICMP, ProtoICMPv6:
+		if len(packet) < offset+1 {
+			return FilterResult{Drop: false, Protocol: 0, SourcePeer: sourcePeer}
+		}
+		pkt.icmpType = packet[offset]
+		// Errors about packets of tracked flows are related traffic
+		if isICMPErrorType(protocol, pkt.icmpType) && len(packet) > offset+icmpHeaderLen &&
+			f.conntrack.related(packet[offset+icmpHeaderLen:], sourcePeer) {
 			return FilterResult{Drop: false, Protocol: 0, SourcePeer: sourcePeer}
 		}
-		tcpFlags := packet[ihl+13]
-		// SYN=0x02, ACK=0x10. Only filter pure SYN (new connection attempts)
-		isSYN := (tcpFlags & 0x02) != 0
-		isACK := (tcpFlags & 0x10) != 0
-		if !isSYN || isACK {
-			// Not a new connection attempt - allow through
-			return FilterResult{Drop: false, Protocol: protocol, SourcePeer: sourcePeer}
+		if isNeighborDiscovery(protocol, pkt.icmpType) {
+			return FilterResult{Drop: false, Protocol: 0, SourcePeer: sourcePeer}
 		}
-	}
 
-	// Extract destination port from TCP/UDP header (bytes 2-3)
-	dstPort := binary.BigEndian.Uint16(packet[ihl+2 : ihl+4])
+	default:
+		return FilterResult{Drop: false, Protocol: 0, SourcePeer: sourcePeer}
+	}
+	pkt.src = sourceAddr(packet)
 
 	// Check filter rules with peer context
-	action, _ := f.effectiveAction(dstPort, protocol, sourcePeer)
-	return FilterResult{Drop: action == ActionDeny, Protocol: protocol, SourcePeer: sourcePeer}
+	action, _ := f.effectiveAction(pkt)
+	if action == ActionDeny {
+		return FilterResult{Drop: true, Protocol: protocol, SourcePeer: sourcePeer}
+	}
+	if trackable && (fp.opens || fp.pickup) {
+		f.conntrack.open(fp, true, sourcePeer)
+	}
+	return FilterResult{Drop: false, Protocol: protocol, SourcePeer: sourcePeer}
 }
 
 // ListRules returns all rules from all layers with their sources.
@@ -402,22 +716,22 @@ func (f *PacketFilter) ListRules() []FilterRuleWithSource {
 
 	var result []FilterRuleWithSource
 
-	for _, r := range *coordRules {
+	for _, r := range coordRules.rules {
 		if !r.IsExpired() {
 			result = append(result, FilterRuleWithSource{Rule: r, Source: SourceCoordinator})
 		}
 	}
-	for _, r := range *configRules {
+	for _, r := range configRules.rules {
 		if !r.IsExpired() {
 			result = append(result, FilterRuleWithSource{Rule: r, Source: SourcePeerConfig})
 		}
 	}
-	for _, r := range *tempRules {
+	for _, r := range tempRules.rules {
 		if !r.IsExpired() {
 			result = append(result, FilterRuleWithSource{Rule: r, Source: SourceTemporary})
 		}
 	}
-	for _, r := range *serviceRules {
+	for _, r := range serviceRules.rules {
 		if !r.IsExpired() {
 			result = append(result, FilterRuleWithSource{Rule: r, Source: SourceService})
 		}
@@ -429,22 +743,22 @@ func (f *PacketFilter) ListRules() []FilterRuleWithSource {
 // RuleCount returns the number of non-expired rules across all layers.
 func (f *PacketFilter) RuleCount() int {
 	count := 0
-	for _, r := range *f.coordinator.Load() {
+	for _, r := range f.coordinator.Load().rules {
 		if !r.IsExpired() {
 			count++
 		}
 	}
-	for _, r := range *f.peerConfig.Load() {
+	for _, r := range f.peerConfig.Load().rules {
 		if !r.IsExpired() {
 			count++
 		}
 	}
-	for _, r := range *f.temporary.Load() {
+	for _, r := range f.temporary.Load().rules {
 		if !r.IsExpired() {
 			count++
 		}
 	}
-	for _, r := range *f.service.Load() {
+	for _, r := range f.service.Load().rules {
 		if !r.IsExpired() {
 			count++
 		}
@@ -464,22 +778,22 @@ type RuleCounts struct {
 func (f *PacketFilter) RuleCountBySource() RuleCounts {
 	var counts RuleCounts
 
-	for _, r := range *f.coordinator.Load() {
+	for _, r := range f.coordinator.Load().rules {
 		if !r.IsExpired() {
 			counts.Coordinator++
 		}
 	}
-	for _, r := range *f.peerConfig.Load() {
+	for _, r := range f.peerConfig.Load().rules {
 		if !r.IsExpired() {
 			counts.PeerConfig++
 		}
 	}
-	for _, r := range *f.temporary.Load() {
+	for _, r := range f.temporary.Load().rules {
 		if !r.IsExpired() {
 			counts.Temporary++
 		}
 	}
-	for _, r := range *f.service.Load() {
+	for _, r := range f.service.Load().rules {
 		if !r.IsExpired() {
 			counts.Service++
 		}
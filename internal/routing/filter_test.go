@@ -2,6 +2,7 @@ package routing
 
 import (
 	"net"
+	"net/netip"
 	"testing"
 	"time"
 )
@@ -484,7 +485,7 @@ func TestPacketFilter_CheckPacket(t *testing.T) {
 		t.Errorf("expected protocol=TCP (6), got %d", result.Protocol)
 	}
 
-	// ICMP packet should not be dropped
+	// ICMP packet should not be dropped without ICMP rules
 	icmpPacket := make([]byte, 28)
 	icmpPacket[0] = 0x45
 	icmpPacket[9] = ProtoICMP
@@ -492,8 +493,8 @@ func TestPacketFilter_CheckPacket(t *testing.T) {
 	if result.Drop {
 		t.Error("expected ICMP to not be dropped")
 	}
-	if result.Protocol != 0 {
-		t.Errorf("expected protocol=0 for non-filtered, got %d", result.Protocol)
+	if result.Protocol != ProtoICMP {
+		t.Errorf("expected protocol=ICMP (1), got %d", result.Protocol)
 	}
 }
 
@@ -767,10 +768,11 @@ func TestFilterResult_SourcePeer(t *testing.T) {
 	}
 }
 
-// TestPacketFilter_TCPResponsesAllowed verifies that TCP response packets (non-SYN)
-// are allowed through even in deny-by-default mode. This enables outgoing connections
-// to work while still filtering incoming connection attempts.
-func TestPacketFilter_TCPResponsesAllowed(t *testing.T) {
+// TestPacketFilter_TCPResponses verifies that in deny-by-default mode, TCP
+// segments other than SYNs only pass as part of a tracked connection, or
+// when the rules allow their port. Outgoing connections work while peers
+// can't inject segments into ports the rules deny.
+func TestPacketFilter_TCPResponses(t *testing.T) {
 	f := NewPacketFilter(true) // Default deny
 
 	src := net.ParseIP("10.0.0.1")
@@ -783,32 +785,21 @@ func TestPacketFilter_TCPResponsesAllowed(t *testing.T) {
 		t.Error("expected SYN packet to port 8080 to be dropped (not in allow list)")
 	}
 
-	// Test SYN-ACK packet (response to SYN) - should be allowed
-	synAckPacket := buildTCPPacketWithFlags(src, dst, 8080, 12345, 0x12) // SYN+ACK
-	result = f.CheckPacketFromPeer(synAckPacket, "peer1")
-	if result.Drop {
-		t.Error("expected SYN-ACK packet to be allowed (response traffic)")
-	}
-
-	// Test ACK packet (established connection) - should be allowed
-	ackPacket := buildTCPPacketWithFlags(src, dst, 8080, 12345, 0x10) // ACK only
-	result = f.CheckPacketFromPeer(ackPacket, "peer1")
-	if result.Drop {
-		t.Error("expected ACK packet to be allowed (established traffic)")
-	}
-
-	// Test pure data packet (PSH+ACK) - should be allowed
-	pshAckPacket := buildTCPPacketWithFlags(src, dst, 8080, 12345, 0x18) // PSH+ACK
-	result = f.CheckPacketFromPeer(pshAckPacket, "peer1")
-	if result.Drop {
-		t.Error("expected PSH+ACK packet to be allowed (data traffic)")
+	// Unsolicited SYN-ACK, ACK, PSH+ACK, FIN and RST segments are dropped
+	for _, flags := range []byte{0x12, 0x10, 0x18, 0x01, 0x04} {
+		packet := buildTCPPacketWithFlags(src, dst, 8080, 12345, flags)
+		if !f.CheckPacketFromPeer(packet, "peer1").Drop {
+			t.Errorf("expected unsolicited segment with flags %#x to be dropped", flags)
+		}
 	}
 
-	// Test FIN packet - should be allowed
-	finPacket := buildTCPPacketWithFlags(src, dst, 8080, 12345, 0x01) // FIN only
-	result = f.CheckPacketFromPeer(finPacket, "peer1")
-	if result.Drop {
-		t.Error("expected FIN packet to be allowed")
+	// Responses to a connection this peer opened pass
+	f.TrackOutbound(buildTCPPacketWithFlags(dst, src, 12345, 8080, 0x02), "peer1")
+	for _, flags := range []byte{0x12, 0x10, 0x18, 0x01} {
+		packet := buildTCPPacketWithFlags(src, dst, 8080, 12345, flags)
+		if f.CheckPacketFromPeer(packet, "peer1").Drop {
+			t.Errorf("expected response with flags %#x to be allowed", flags)
+		}
 	}
 
 	// Now add an allow rule and verify SYN to allowed port works
@@ -821,6 +812,22 @@ func TestPacketFilter_TCPResponsesAllowed(t *testing.T) {
 	if result.Drop {
 		t.Error("expected SYN to allowed port 22 to pass")
 	}
+
+	// Connections to allowed ports predating the connection table are
+	// picked up mid-stream
+	ackToAllowed := buildTCPPacketWithFlags(src, dst, 23456, 22, 0x10)
+	if f.CheckPacketFromPeer(ackToAllowed, "peer1").Drop {
+		t.Error("expected ACK to allowed port 22 to pass")
+	}
+	picked := false
+	for _, flow := range f.Flows() {
+		if flow.Src.Port() == 23456 && flow.State == FlowEstablished {
+			picked = true
+		}
+	}
+	if !picked {
+		t.Error("expected the connection to port 22 to be tracked as established")
+	}
 }
 
 // Benchmark the hot path
@@ -863,3 +870,265 @@ func BenchmarkPacketFilter_ShouldDrop_NoMatch(b *testing.B) {
 		f.ShouldDrop(packet)
 	}
 }
+
+func TestPacketFilter_ShouldDrop_IPv6(t *testing.T) {
+	f := NewPacketFilter(true)
+	f.SetPeerConfigRules([]FilterRule{{Port: 22, Protocol: ProtoTCP, Action: ActionAllow}})
+
+	src := net.ParseIP("fd42:6d65:7368::1")
+	dst := net.ParseIP("fd42:6d65:7368::2")
+
+	tcp := func(dstPort uint16, flags byte) []byte {
+		header := make([]byte, 20)
+		header[0], header[1] = 0x30, 0x39 // Source port 12345
+		header[2], header[3] = byte(dstPort>>8), byte(dstPort)
+		header[12] = 0x50
+		header[13] = flags
+		return header
+	}
+
+	if f.ShouldDrop(BuildIPv6Packet(src, dst, ProtoTCP, tcp(22, 0x02))) {
+		t.Error("expected IPv6 SYN to port 22 to be allowed")
+	}
+	if !f.ShouldDrop(BuildIPv6Packet(src, dst, ProtoTCP, tcp(80, 0x02))) {
+		t.Error("expected IPv6 SYN to port 80 to be dropped")
+	}
+	if !f.ShouldDrop(BuildIPv6Packet(src, dst, ProtoTCP, tcp(80, 0x12))) {
+		t.Error("expected unsolicited IPv6 SYN-ACK to be dropped")
+	}
+
+	// Destination options before the TCP header are skipped
+	ext := make([]byte, 8)
+	ext[0] = ProtoTCP
+	if !f.ShouldDrop(BuildIPv6Packet(src, dst, ipv6DestOpts, append(ext, tcp(80, 0x02)...))) {
+		t.Error("expected IPv6 SYN to port 80 behind an extension header to be dropped")
+	}
+
+	// ICMPv6 is not filtered
+	if f.ShouldDrop(BuildIPv6Packet(src, dst, ProtoICMPv6, make([]byte, 8))) {
+		t.Error("expected ICMPv6 to be allowed")
+	}
+}
+
+func TestPacketFilter_PortRange(t *testing.T) {
+	f := NewPacketFilter(true)
+	f.SetPeerConfigRules([]FilterRule{{Port: 8000, PortEnd: 8100, Protocol: ProtoTCP, Action: ActionAllow}})
+
+	src := net.ParseIP("10.42.0.1")
+	dst := net.ParseIP("10.42.0.2")
+	for port, drop := range map[uint16]bool{7999: true, 8000: false, 8050: false, 8100: false, 8101: true} {
+		if got := f.ShouldDrop(buildTCPPacket(src, dst, port)); got != drop {
+			t.Errorf("port %d: drop = %v, want %v", port, got, drop)
+		}
+	}
+
+	// A deny inside the range still wins
+	f.AddTemporaryRule(FilterRule{Port: 8080, Protocol: ProtoTCP, Action: ActionDeny})
+	if !f.ShouldDrop(buildTCPPacket(src, dst, 8080)) {
+		t.Error("expected deny for port 8080 to override the range allow")
+	}
+}
+
+func TestPacketFilter_ICMPTypes(t *testing.T) {
+	icmp := func(icmpType byte) []byte {
+		packet := make([]byte, 28)
+		packet[0] = 0x45
+		packet[9] = ProtoICMP
+		packet[20] = icmpType
+		return packet
+	}
+
+	f := NewPacketFilter(true)
+	if f.ShouldDrop(icmp(8)) {
+		t.Error("expected ICMP to pass without rules, even in allowlist mode")
+	}
+	// Unless the default policy is applied to ICMP too
+	f.SetICMPDefaultDeny(true)
+	if !f.ShouldDrop(icmp(13)) {
+		t.Error("expected ICMP without rules to be denied with the default policy")
+	}
+	f.SetICMPDefaultDeny(false)
+
+	// Allow ping, block the rest
+	f.SetCoordinatorRules([]FilterRule{
+		{Protocol: ProtoICMP, Action: ActionDeny},
+		{Protocol: ProtoICMP, ICMPType: 8, Action: ActionAllow},
+	})
+	if f.ShouldDrop(icmp(8)) {
+		t.Error("expected echo request to be allowed")
+	}
+	if !f.ShouldDrop(icmp(13)) {
+		t.Error("expected timestamp request to be denied")
+	}
+	for _, icmpType := range []byte{3, 11} {
+		if !f.ShouldDrop(icmp(icmpType)) {
+			t.Errorf("expected unsolicited ICMP error type %d to be denied", icmpType)
+		}
+	}
+
+	// Errors about packets of tracked flows, and echo replies to this peer's
+	// echo requests, are related traffic
+	local, remote := net.ParseIP("10.42.0.2"), net.ParseIP("10.42.0.1")
+	sent := buildUDPPacket(local, remote, 40000, 53)
+	f.TrackOutbound(sent, "")
+	unreachable := BuildIPv4Packet(remote, local, ProtoICMP, append([]byte{3, 3, 0, 0, 0, 0, 0, 0}, sent...))
+	if f.ShouldDrop(unreachable) {
+		t.Error("expected unreachable about a tracked flow to pass")
+	}
+	other := BuildIPv4Packet(remote, local, ProtoICMP,
+		append([]byte{3, 3, 0, 0, 0, 0, 0, 0}, buildUDPPacket(local, remote, 40001, 53)...))
+	if !f.ShouldDrop(other) {
+		t.Error("expected unreachable about an untracked flow to be denied")
+	}
+	f.TrackOutbound(buildICMPEchoPacket(local, remote, 8, 7), "")
+	if f.ShouldDrop(buildICMPEchoPacket(remote, local, 0, 7)) {
+		t.Error("expected echo reply to a tracked echo request to pass")
+	}
+	if !f.ShouldDrop(buildICMPEchoPacket(remote, local, 0, 8)) {
+		t.Error("expected unsolicited echo reply to be denied")
+	}
+
+	// A deny for the type wins over the allow from a specific peer
+	f.AddTemporaryRule(FilterRule{Protocol: ProtoICMP, ICMPType: 8, Action: ActionDeny, SourcePeer: "noisy"})
+	if !f.ShouldDropFromPeer(icmp(8), "noisy") {
+		t.Error("expected echo request from noisy to be denied")
+	}
+	if f.ShouldDropFromPeer(icmp(8), "other") {
+		t.Error("expected echo request from other to be allowed")
+	}
+
+	// ICMPv6 rules do not apply to ICMP
+	f.SetCoordinatorRules([]FilterRule{{Protocol: ProtoICMPv6, Action: ActionDeny}})
+	f.ClearTemporaryRules()
+	if f.ShouldDrop(icmp(8)) {
+		t.Error("expected ICMPv6 rule not to match ICMP")
+	}
+	src := net.ParseIP("fd42:6d65:7368::1")
+	dst := net.ParseIP("fd42:6d65:7368::2")
+	if !f.ShouldDrop(BuildIPv6Packet(src, dst, ProtoICMPv6, []byte{128, 0, 0, 0, 0, 0, 0, 0})) {
+		t.Error("expected ICMPv6 echo request to be denied")
+	}
+	if f.ShouldDrop(BuildIPv6Packet(src, dst, ProtoICMPv6, []byte{135, 0, 0, 0, 0, 0, 0, 0})) {
+		t.Error("expected neighbor solicitation to pass")
+	}
+}
+
+func TestPacketFilter_SourceCIDR(t *testing.T) {
+	f := NewPacketFilter(true)
+	f.SetPeerConfigRules([]FilterRule{
+		{Port: 22, Protocol: ProtoTCP, Action: ActionAllow, SourceCIDR: "10.99.0.0/16"},
+		{Port: 22, Protocol: ProtoTCP, Action: ActionDeny, SourceCIDR: "10.99.5.7"},
+	})
+
+	dst := net.ParseIP("10.42.0.2")
+	if f.ShouldDropFromPeer(buildTCPPacket(net.ParseIP("10.99.1.1"), dst, 22), "office") {
+		t.Error("expected SSH from 10.99.1.1 to be allowed")
+	}
+	if !f.ShouldDropFromPeer(buildTCPPacket(net.ParseIP("10.99.5.7"), dst, 22), "office") {
+		t.Error("expected SSH from 10.99.5.7 to be denied")
+	}
+	if !f.ShouldDropFromPeer(buildTCPPacket(net.ParseIP("10.42.0.9"), dst, 22), "office") {
+		t.Error("expected SSH from outside the CIDR to be denied by default")
+	}
+}
+
+func TestPacketFilter_Groups(t *testing.T) {
+	f := NewPacketFilter(true)
+	// Group ops may reach port 22 on peers in group prod
+	f.SetCoordinatorRules([]FilterRule{
+		{Port: 22, Protocol: ProtoTCP, Action: ActionAllow, SourceGroup: "ops", DestGroup: "prod"},
+	})
+
+	src := net.ParseIP("10.42.0.1")
+	dst := net.ParseIP("10.42.0.2")
+	packet := buildTCPPacket(src, dst, 22)
+
+	f.SetGroups(map[string][]string{"alice": {"everyone", "ops"}, "bob": {"everyone"}}, []string{"everyone"})
+	if !f.ShouldDropFromPeer(packet, "alice") {
+		t.Error("expected rule not to apply on a peer outside group prod")
+	}
+
+	f.SetGroups(map[string][]string{"alice": {"everyone", "ops"}, "bob": {"everyone"}}, []string{"everyone", "prod"})
+	if f.ShouldDropFromPeer(packet, "alice") {
+		t.Error("expected SSH from alice (ops) to be allowed")
+	}
+	if !f.ShouldDropFromPeer(packet, "bob") {
+		t.Error("expected SSH from bob to be denied")
+	}
+	if !f.ShouldDrop(packet) {
+		t.Error("expected SSH from an unknown peer to be denied")
+	}
+}
+
+func TestPacketFilter_ClientGroups(t *testing.T) {
+	f := NewPacketFilter(true)
+	f.SetCoordinatorRules([]FilterRule{
+		{Port: 22, Protocol: ProtoTCP, Action: ActionAllow, SourceGroup: "tag:laptop"},
+	})
+	f.SetGroups(map[string][]string{"concentrator": {"everyone"}}, nil)
+	f.SetClientGroups(map[netip.Addr]ClientGroups{
+		netip.MustParseAddr("10.42.100.2"): {Peer: "concentrator", Groups: []string{"tag:laptop"}},
+	})
+
+	dst := net.ParseIP("10.42.0.2")
+	laptop := buildTCPPacket(net.ParseIP("10.42.100.2"), dst, 22)
+	if f.ShouldDropFromPeer(laptop, "concentrator") {
+		t.Error("expected SSH from the tagged client to be allowed")
+	}
+	if !f.ShouldDropFromPeer(laptop, "mallory") {
+		t.Error("expected the client's address to count only through its concentrator")
+	}
+	if !f.ShouldDropFromPeer(buildTCPPacket(net.ParseIP("10.42.100.3"), dst, 22), "concentrator") {
+		t.Error("expected SSH from an untagged client to be denied")
+	}
+
+	// Peer groups are kept when client groups change, and the other way round
+	f.SetGroups(map[string][]string{"alice": {"tag:laptop"}}, nil)
+	if f.ShouldDropFromPeer(laptop, "concentrator") || f.ShouldDropFromPeer(buildTCPPacket(net.ParseIP("10.42.0.1"), dst, 22), "alice") {
+		t.Error("expected SSH from the client and alice to be allowed")
+	}
+	f.SetClientGroups(nil)
+	if !f.ShouldDropFromPeer(laptop, "concentrator") {
+		t.Error("expected SSH from the client to be denied once untagged")
+	}
+}
+
+func TestFilterRule_KeyAndValidate(t *testing.T) {
+	a := FilterRule{Port: 80, PortEnd: 80, Protocol: ProtoTCP, SourceCIDR: "10.1.2.3/8"}
+	b := FilterRule{Port: 80, Protocol: ProtoTCP, SourceCIDR: "10.0.0.0/8"}
+	if a.Key() != b.Key() {
+		t.Errorf("expected equal keys, got %+v and %+v", a.Key(), b.Key())
+	}
+
+	f := NewPacketFilter(true)
+	f.AddTemporaryRule(FilterRule{Port: 8000, PortEnd: 8100, Protocol: ProtoUDP, Action: ActionAllow})
+	f.RemoveTemporaryRuleKey(FilterRule{Port: 8000, PortEnd: 8100, Protocol: ProtoUDP}.Key())
+	if f.RuleCount() != 0 {
+		t.Errorf("expected rule to be removed, got %d rules", f.RuleCount())
+	}
+
+	valid := []FilterRule{
+		{Port: 22, Protocol: ProtoTCP},
+		{Port: 8000, PortEnd: 8100, Protocol: ProtoUDP},
+		{Protocol: ProtoICMP},
+		{Protocol: ProtoICMPv6, ICMPType: 128, SourceCIDR: "fd00::/8"},
+	}
+	for _, r := range valid {
+		if err := r.Validate(); err != nil {
+			t.Errorf("Validate(%+v) = %v, want nil", r, err)
+		}
+	}
+	invalid := []FilterRule{
+		{Protocol: ProtoTCP},
+		{Port: 8100, PortEnd: 8000, Protocol: ProtoTCP},
+		{Port: 22, Protocol: ProtoTCP, ICMPType: 8},
+		{Port: 22, Protocol: ProtoICMP},
+		{Port: 22, Protocol: 47},
+		{Port: 22, Protocol: ProtoTCP, SourceCIDR: "10.0.0.0/33"},
+	}
+	for _, r := range invalid {
+		if err := r.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want error", r)
+		}
+	}
+}
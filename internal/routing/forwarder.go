@@ -63,13 +63,18 @@ type ForwarderStats struct {
 	BytesReceived      uint64
 	DroppedNoRoute     uint64
 	DroppedNoTunnel    uint64
-	DroppedNonIPv4     uint64
+	DroppedNonIPv4     uint64 // Packets that are neither IPv4 nor IPv6
 	DroppedFiltered    uint64 // Packets dropped by packet filter (total)
 	DroppedFilteredTCP uint64 // TCP packets dropped by filter
 	DroppedFilteredUDP uint64 // UDP packets dropped by filter
 	Errors             uint64
 	ExitPacketsSent    uint64 // Packets sent through exit node
 	ExitBytesSent      uint64 // Bytes sent through exit node
+	PeerRelayedSent    uint64 // Packets sent through a relay peer
+	PacketsRelayed     uint64 // Packets relayed for other peers
+	DroppedRelay       uint64 // Relayed frames dropped (TTL, loop, spoofed origin, not a relay)
+	DroppedExitDenied  uint64 // Exit traffic from peers not allowed to use this exit peer
+	DroppedTooBig      uint64 // Packets larger than the tunnel MTU, answered with ICMP packet too big
 }
 
 // WGPacketHandler handles packets destined for WireGuard clients.
@@ -102,15 +107,25 @@ type Forwarder struct {
 	wgMu               sync.RWMutex
 	filterMu           sync.RWMutex
 	localIP            net.IP
+	localIPv6          net.IP
 	localIPMu          sync.RWMutex
 	onDeadTunnel       func(peerName string) // Callback when tunnel write fails
 	onFilterDrop       FilterDropCallback    // Callback when filter drops a packet
 	deadTunnelDebounce sync.Map              // peerName → time.Time for debouncing
 	// Exit node fields
 	meshCIDR   *net.IPNet // Mesh network CIDR for split-tunnel detection
+	meshCIDRv6 *net.IPNet // Mesh network IPv6 prefix for split-tunnel detection
 	meshCIDRMu sync.RWMutex
-	exitNode   string // Name of exit node peer for external traffic
-	exitNodeMu sync.RWMutex
+	exits      *ExitSelector // Picks the exit peer for external traffic
+	// Exit peer side: the peers whose external traffic is forwarded
+	exitClients   map[string]struct{} // nil = every peer
+	localRoutes   []*net.IPNet        // Subnet routes this peer advertises, which are not exit traffic
+	exitClientsMu sync.RWMutex
+	// Multi-hop relaying through peers
+	relayPaths   *RelayPaths // Relay peers for peers without a direct tunnel
+	localName    string      // Origin of the relayed frames we send
+	allowRelay   bool        // Relay frames for other peers
+	relayPeersMu sync.RWMutex
 }
 
 // NewForwarder creates a new packet forwarder.
@@ -121,6 +136,8 @@ func NewForwarder(router *Router, tunnels TunnelProvider) *Forwarder {
 		bufPool:      NewPacketBufferPool(MaxPacketSize),
 		zeroCopyPool: NewZeroCopyBufferPool(FrameHeaderSize, MaxPacketSize),
 		statsEnabled: 1, // Enabled by default
+		exits:        NewExitSelector(),
+		relayPaths:   NewRelayPaths(),
 		framePool: &sync.Pool{
 			New: func() interface{} {
 				// Allocate buffer for header + max packet size
@@ -176,6 +193,20 @@ func (f *Forwarder) SetLocalIP(ip net.IP) {
 	f.localIP = ip
 }
 
+// SetLocalIPv6 sets the local mesh IPv6 address.
+func (f *Forwarder) SetLocalIPv6(ip net.IP) {
+	f.localIPMu.Lock()
+	defer f.localIPMu.Unlock()
+	f.localIPv6 = ip
+}
+
+// isLocalIP reports whether ip is one of our own mesh addresses.
+func (f *Forwarder) isLocalIP(ip net.IP) bool {
+	f.localIPMu.RLock()
+	defer f.localIPMu.RUnlock()
+	return (f.localIP != nil && ip.Equal(f.localIP)) || (f.localIPv6 != nil && ip.Equal(f.localIPv6))
+}
+
 // SetRelay sets the persistent relay for fallback routing.
 func (f *Forwarder) SetRelay(relay RelayPacketSender) {
 	f.relayMu.Lock()
@@ -194,6 +225,27 @@ func (f *Forwarder) SetRelay(relay RelayPacketSender) {
 	}
 }
 
+// SetLocalName sets this peer's name, needed to send packets through relay
+// peers and to accept packets relayed to it.
+func (f *Forwarder) SetLocalName(name string) {
+	f.relayPeersMu.Lock()
+	defer f.relayPeersMu.Unlock()
+	f.localName = name
+}
+
+// SetAllowRelay sets whether this peer relays packets between other peers
+// that have no direct tunnel to each other.
+func (f *Forwarder) SetAllowRelay(allow bool) {
+	f.relayPeersMu.Lock()
+	defer f.relayPeersMu.Unlock()
+	f.allowRelay = allow
+}
+
+// RelayPaths returns the relay peers used for peers without a direct tunnel.
+func (f *Forwarder) RelayPaths() *RelayPaths {
+	return f.relayPaths
+}
+
 // SetWGHandler sets the WireGuard packet handler for local WG client routing.
 func (f *Forwarder) SetWGHandler(handler WGPacketHandler) {
 	f.wgMu.Lock()
@@ -217,6 +269,18 @@ func (f *Forwarder) Filter() *PacketFilter {
 	return f.filter
 }
 
+// trackOutbound records an outgoing packet in the packet filter's connection
+// table, so replies to it from the peer it is routed to are let in.
+func (f *Forwarder) trackOutbound(packet []byte, peer string) {
+	f.filterMu.RLock()
+	filter := f.filter
+	f.filterMu.RUnlock()
+
+	if filter != nil {
+		filter.TrackOutbound(packet, peer)
+	}
+}
+
 // SetOnDeadTunnel sets a callback that is called when a tunnel write fails.
 // This allows the caller to remove the dead tunnel and trigger reconnection.
 func (f *Forwarder) SetOnDeadTunnel(callback func(peerName string)) {
@@ -257,26 +321,96 @@ func (f *Forwarder) SetMeshCIDR(cidr *net.IPNet) {
 	f.meshCIDR = cidr
 }
 
-// SetExitPeer configures the exit node for external traffic routing.
+// SetMeshCIDRv6 sets the mesh network IPv6 prefix for split-tunnel detection
+// of IPv6 traffic.
+func (f *Forwarder) SetMeshCIDRv6(cidr *net.IPNet) {
+	f.meshCIDRMu.Lock()
+	defer f.meshCIDRMu.Unlock()
+	f.meshCIDRv6 = cidr
+}
+
+// SetExitPeer configures a single exit node for external traffic routing.
 // When set, traffic destined outside the mesh CIDR is routed through this peer.
 func (f *Forwarder) SetExitPeer(peerName string) {
-	f.exitNodeMu.Lock()
-	defer f.exitNodeMu.Unlock()
-	f.exitNode = peerName
+	if peerName == "" {
+		f.exits.SetPeers(nil)
+		return
+	}
+	f.exits.SetPeers([]string{peerName})
 }
 
-// ExitPeer returns the currently configured exit node, or empty string if none.
+// SetExitPeers configures the exit peers for external traffic routing, in
+// order of preference. Traffic fails over to the next healthy peer.
+func (f *Forwarder) SetExitPeers(peers []string) {
+	f.exits.SetPeers(peers)
+}
+
+// ExitPeer returns the exit peer currently in use for external traffic, or
+// empty string if none is configured or healthy.
 func (f *Forwarder) ExitPeer() string {
-	f.exitNodeMu.RLock()
-	defer f.exitNodeMu.RUnlock()
-	return f.exitNode
+	return f.exits.Active()
+}
+
+// Exits returns the exit selector, for setting policies and peer health.
+func (f *Forwarder) Exits() *ExitSelector {
+	return f.exits
+}
+
+// SetExitClients restricts the peers this exit peer forwards external
+// traffic for. nil allows every peer.
+func (f *Forwarder) SetExitClients(peers []string) {
+	var clients map[string]struct{}
+	if peers != nil {
+		clients = make(map[string]struct{}, len(peers))
+		for _, peer := range peers {
+			clients[peer] = struct{}{}
+		}
+	}
+	f.exitClientsMu.Lock()
+	f.exitClients = clients
+	f.exitClientsMu.Unlock()
+}
+
+// SetLocalRoutes sets the subnet routes this peer advertises. Traffic to
+// them is not exit traffic, so SetExitClients does not restrict it.
+func (f *Forwarder) SetLocalRoutes(routes []*net.IPNet) {
+	f.exitClientsMu.Lock()
+	f.localRoutes = routes
+	f.exitClientsMu.Unlock()
+}
+
+// exitDenied reports whether a packet from a peer is external traffic the
+// peer may not send through this exit peer.
+func (f *Forwarder) exitDenied(packet []byte, sourcePeer string) bool {
+	f.exitClientsMu.RLock()
+	defer f.exitClientsMu.RUnlock()
+
+	if f.exitClients == nil || sourcePeer == "" {
+		return false
+	}
+	if _, ok := f.exitClients[sourcePeer]; ok {
+		return false
+	}
+	info, err := ParsePacket(packet)
+	if err != nil || !f.IsExternalTraffic(info.DstIP) {
+		return false
+	}
+	for _, route := range f.localRoutes {
+		if route.Contains(info.DstIP) {
+			return false
+		}
+	}
+	return true
 }
 
 // IsExternalTraffic checks if the destination IP is outside the mesh network.
-// Returns false if no mesh CIDR is configured.
+// Returns false if no mesh CIDR is configured for the IP's family.
 func (f *Forwarder) IsExternalTraffic(dstIP net.IP) bool {
 	f.meshCIDRMu.RLock()
 	meshCIDR := f.meshCIDR
+	if dstIP.To4() == nil {
+		meshCIDR = f.meshCIDRv6
+	}
 	f.meshCIDRMu.RUnlock()
 
 	if meshCIDR == nil {
@@ -334,8 +468,8 @@ func (f *Forwarder) ForwardPacket(packet []byte) error {
 	}
 
 	version := packet[0] >> 4
-	if version != 4 {
-		// Silently drop non-IPv4 packets (IPv6, etc.) - the mesh only supports IPv4
+	if version != 4 && version != 6 {
+		// Silently drop non-IP packets
 		if collectStats {
 			atomic.AddUint64(&f.stats.DroppedNonIPv4, 1)
 		}
@@ -343,28 +477,28 @@ func (f *Forwarder) ForwardPacket(packet []byte) error {
 	}
 
 	// Parse the packet
-	info, err := ParseIPv4Packet(packet)
+	info, err := ParsePacket(packet)
 	if err != nil {
 		if collectStats {
 			atomic.AddUint64(&f.stats.Errors, 1)
 		}
 		return fmt.Errorf("parse packet: %w", err)
 	}
+	if isLinkScoped(info.DstIP) {
+		return nil // Neighbor discovery, MLD and the like stay on the TUN link
+	}
 
 	// Handle packets destined for our own IP (local traffic)
-	f.localIPMu.RLock()
-	localIP := f.localIP
-	f.localIPMu.RUnlock()
-	if localIP != nil && info.DstIP.Equal(localIP) {
+	if f.isLocalIP(info.DstIP) {
 		// Write back to TUN so kernel delivers it locally
 		return f.ReceivePacket(packet)
 	}
-
 	// Check if destination is a local WireGuard client
 	f.wgMu.RLock()
 	wgHandler := f.wgHandler
 	f.wgMu.RUnlock()
 	if wgHandler != nil && wgHandler.IsWGClientIP(info.DstIP.String()) {
+		f.trackOutbound(packet, "")
 		if err := wgHandler.SendPacket(packet); err != nil {
 			atomic.AddUint64(&f.stats.Errors, 1)
 			return fmt.Errorf("send to WG client: %w", err)
@@ -379,47 +513,56 @@ func (f *Forwarder) ForwardPacket(packet []byte) error {
 		return nil
 	}
 
-	// Check for exit node routing (split tunnel)
-	// If exit node is configured and destination is external (outside mesh CIDR),
-	// route through the exit node instead of normal mesh routing
-	f.exitNodeMu.RLock()
-	exitNode := f.exitNode
-	f.exitNodeMu.RUnlock()
-
-	isExternal := f.IsExternalTraffic(info.DstIP)
-	if exitNode != "" && isExternal {
-		log.Debug().
-			Str("dst", info.DstIP.String()).
-			Str("exit_node", exitNode).
-			Msg("routing external traffic to exit node")
-		return f.forwardToExitPeer(packet, info, exitNode)
-	}
-
-	// Debug: log when external traffic cannot be routed
-	if isExternal && exitNode == "" {
-		log.Debug().
-			Str("dst", info.DstIP.String()).
-			Bool("meshCIDR_set", f.meshCIDR != nil).
-			Msg("external traffic but no exit node configured")
-	}
-
-	// Look up the route
+	// Look up the route. Mesh IPs and subnet routes advertised by peers take
+	// precedence over the exit node, so advertised LANs stay reachable.
 	peerName, ok := f.router.Lookup(info.DstIP)
 	if !ok {
+		// Check for exit node routing (split tunnel)
+		// If exit node is configured and destination is external (outside mesh CIDR),
+		// route through the exit node instead of normal mesh routing
+		isExternal := f.IsExternalTraffic(info.DstIP)
+		exitNode := ""
+		if isExternal {
+			exitNode = f.exits.Select(info.DstIP)
+		}
+		if exitNode != "" {
+			log.Debug().
+				Str("dst", info.DstIP.String()).
+				Str("exit_node", exitNode).
+				Msg("routing external traffic to exit node")
+			return f.forwardToExit(packet, info, exitNode)
+		}
+
+		// Debug: log when external traffic cannot be routed
+		if isExternal && exitNode == "" {
+			log.Debug().
+				Str("dst", info.DstIP.String()).
+				Bool("meshCIDR_set", f.meshCIDR != nil).
+				Msg("external traffic but no exit node configured")
+		}
+
 		f.incStat(collectStats, &f.stats.DroppedNoRoute)
 		return fmt.Errorf("%w for %s", ErrNoRoute, info.DstIP)
 	}
 
+	f.trackOutbound(packet, peerName)
+
 	// Get the tunnel
 	tunnel, ok := f.tunnels.Get(peerName)
 	if ok {
+		if !f.fitTunnel(packet, tunnelMTU(tunnel)) {
+			return nil
+		}
+
 		// Direct tunnel exists - use it
 		if err := f.writeFrame(tunnel, packet); err != nil {
 			f.incStat(collectStats, &f.stats.Errors)
-			log.Warn().Err(err).Str("peer", peerName).Msg("tunnel write failed, marking dead and falling back to relay")
+			log.Warn().Err(err).Str("peer", peerName).Msg("tunnel write failed, falling back to relay")
 
 			// Signal dead tunnel so it can be removed and reconnection triggered
-			f.triggerDeadTunnel(peerName)
+			if !writeTooBig(err) {
+				f.triggerDeadTunnel(peerName)
+			}
 
 			// Fall through to relay fallback below
 		} else {
@@ -437,7 +580,11 @@ func (f *Forwarder) ForwardPacket(packet []byte) error {
 		}
 	}
 
-	// No direct tunnel or tunnel write failed - try persistent relay
+	// No direct tunnel or tunnel write failed - try a relay peer, then the
+	// persistent relay through the coordinator
+	if f.sendViaRelayPeer(packet, peerName) {
+		return nil
+	}
 	f.relayMu.RLock()
 	relay := f.relay
 	f.relayMu.RUnlock()
@@ -483,17 +630,47 @@ func (f *Forwarder) ForwardPacket(packet []byte) error {
 	return fmt.Errorf("%w for peer %s", ErrNoTunnel, peerName)
 }
 
+// isLinkScoped reports whether ip is an IPv6 link-local or multicast address.
+// The OS sends such packets (router solicitations, MLD reports) on every
+// interface, and they have no meaning beyond it.
+func isLinkScoped(ip net.IP) bool {
+	return ip.To4() == nil && (ip.IsLinkLocalUnicast() || ip.IsMulticast())
+}
+
+// forwardToExit forwards external traffic to an exit peer. If there is no
+// path to it, the peer is marked failed and the packet is sent to the next
+// healthy exit peer instead.
+func (f *Forwarder) forwardToExit(packet []byte, info *PacketInfo, exitNodeName string) error {
+	err := f.forwardToExitPeer(packet, info, exitNodeName)
+	if !errors.Is(err, ErrNoTunnel) {
+		return err
+	}
+	f.exits.MarkFailed(exitNodeName)
+	next := f.exits.Select(info.DstIP)
+	if next == "" || next == exitNodeName {
+		return err
+	}
+	return f.forwardToExitPeer(packet, info, next)
+}
+
 // forwardToExitPeer forwards external traffic to the configured exit node.
 // It first tries to send via direct tunnel, then falls back to relay.
 func (f *Forwarder) forwardToExitPeer(packet []byte, info *PacketInfo, exitNodeName string) error {
+	f.trackOutbound(packet, exitNodeName)
+
 	// Try direct tunnel first
 	tunnel, ok := f.tunnels.Get(exitNodeName)
 	if ok {
+		if !f.fitTunnel(packet, tunnelMTU(tunnel)) {
+			return nil
+		}
 		if err := f.writeFrame(tunnel, packet); err != nil {
 			atomic.AddUint64(&f.stats.Errors, 1)
 			log.Warn().Err(err).Str("exit_node", exitNodeName).Msg("exit tunnel write failed, falling back to relay")
 			// Fall through to relay fallback
-			f.triggerDeadTunnel(exitNodeName)
+			if !writeTooBig(err) {
+				f.triggerDeadTunnel(exitNodeName)
+			}
 		} else {
 			atomic.AddUint64(&f.stats.PacketsSent, 1)
 			atomic.AddUint64(&f.stats.BytesSent, uint64(len(packet)))
@@ -510,7 +687,12 @@ func (f *Forwarder) forwardToExitPeer(packet []byte, info *PacketInfo, exitNodeN
 		log.Debug().Str("exit_node", exitNodeName).Msg("no direct tunnel to exit node, trying relay")
 	}
 
-	// No direct tunnel or tunnel failed - try relay
+	// No direct tunnel or tunnel failed - try a relay peer, then the coordinator relay
+	if f.sendViaRelayPeer(packet, exitNodeName) {
+		atomic.AddUint64(&f.stats.ExitPacketsSent, 1)
+		atomic.AddUint64(&f.stats.ExitBytesSent, uint64(len(packet)))
+		return nil
+	}
 	f.relayMu.RLock()
 	relay := f.relay
 	f.relayMu.RUnlock()
@@ -562,6 +744,11 @@ func (f *Forwarder) ReceivePacket(packet []byte) error {
 func (f *Forwarder) ReceivePacketFromPeer(packet []byte, sourcePeer string) error {
 	collectStats := atomic.LoadUint32(&f.statsEnabled) == 1
 
+	if f.exitDenied(packet, sourcePeer) {
+		f.incStat(collectStats, &f.stats.DroppedExitDenied)
+		return nil
+	}
+
 	// Apply packet filter to incoming traffic with peer context
 	f.filterMu.RLock()
 	filter := f.filter
@@ -643,31 +830,31 @@ func (f *Forwarder) ForwardPacketZeroCopy(zcBuf *ZeroCopyBuffer, packetLen int)
 	}
 
 	version := packet[0] >> 4
-	if version != 4 {
+	if version != 4 && version != 6 {
 		atomic.AddUint64(&f.stats.DroppedNonIPv4, 1)
 		return nil
 	}
 
 	// Parse the packet
-	info, err := ParseIPv4Packet(packet)
+	info, err := ParsePacket(packet)
 	if err != nil {
 		atomic.AddUint64(&f.stats.Errors, 1)
 		return fmt.Errorf("parse packet: %w", err)
 	}
+	if isLinkScoped(info.DstIP) {
+		return nil // Neighbor discovery, MLD and the like stay on the TUN link
+	}
 
 	// Handle packets destined for our own IP
-	f.localIPMu.RLock()
-	localIP := f.localIP
-	f.localIPMu.RUnlock()
-	if localIP != nil && info.DstIP.Equal(localIP) {
+	if f.isLocalIP(info.DstIP) {
 		return f.ReceivePacket(packet)
 	}
-
 	// Check if destination is a local WireGuard client
 	f.wgMu.RLock()
 	wgHandler := f.wgHandler
 	f.wgMu.RUnlock()
 	if wgHandler != nil && wgHandler.IsWGClientIP(info.DstIP.String()) {
+		f.trackOutbound(packet, "")
 		if err := wgHandler.SendPacket(packet); err != nil {
 			atomic.AddUint64(&f.stats.Errors, 1)
 			return fmt.Errorf("send to WG client: %w", err)
@@ -682,22 +869,20 @@ func (f *Forwarder) ForwardPacketZeroCopy(zcBuf *ZeroCopyBuffer, packetLen int)
 		return nil
 	}
 
-	// Check for exit node routing (split tunnel)
-	f.exitNodeMu.RLock()
-	exitNode := f.exitNode
-	f.exitNodeMu.RUnlock()
-
-	if exitNode != "" && f.IsExternalTraffic(info.DstIP) {
-		log.Debug().
-			Str("dst", info.DstIP.String()).
-			Str("exit_node", exitNode).
-			Msg("routing external traffic to exit node")
-		return f.forwardToExitPeer(packet, info, exitNode)
-	}
-
-	// Look up the route
+	// Look up the route, falling back to the exit node (split tunnel) for
+	// external destinations no peer advertises
 	peerName, ok := f.router.Lookup(info.DstIP)
 	if !ok {
+		if f.IsExternalTraffic(info.DstIP) {
+			if exitNode := f.exits.Select(info.DstIP); exitNode != "" {
+				log.Debug().
+					Str("dst", info.DstIP.String()).
+					Str("exit_node", exitNode).
+					Msg("routing external traffic to exit node")
+				return f.forwardToExit(packet, info, exitNode)
+			}
+		}
+
 		atomic.AddUint64(&f.stats.DroppedNoRoute, 1)
 		log.Debug().
 			Str("dst", info.DstIP.String()).
@@ -705,9 +890,15 @@ func (f *Forwarder) ForwardPacketZeroCopy(zcBuf *ZeroCopyBuffer, packetLen int)
 		return fmt.Errorf("%w for %s", ErrNoRoute, info.DstIP)
 	}
 
+	f.trackOutbound(packet, peerName)
+
 	// Get the tunnel
 	tunnel, ok := f.tunnels.Get(peerName)
 	if ok {
+		if !f.fitTunnel(packet, tunnelMTU(tunnel)) {
+			return nil
+		}
+
 		log.Debug().
 			Str("src", info.SrcIP.String()).
 			Str("dst", info.DstIP.String()).
@@ -718,10 +909,12 @@ func (f *Forwarder) ForwardPacketZeroCopy(zcBuf *ZeroCopyBuffer, packetLen int)
 		// Zero-copy write: frame header is already in place
 		if err := f.writeFrameZeroCopy(tunnel, zcBuf, packetLen); err != nil {
 			atomic.AddUint64(&f.stats.Errors, 1)
-			log.Warn().Err(err).Str("peer", peerName).Msg("tunnel write failed, marking dead and falling back to relay")
+			log.Warn().Err(err).Str("peer", peerName).Msg("tunnel write failed, falling back to relay")
 
 			// Signal dead tunnel so it can be removed and reconnection triggered
-			f.triggerDeadTunnel(peerName)
+			if !writeTooBig(err) {
+				f.triggerDeadTunnel(peerName)
+			}
 
 			// Fall through to relay fallback below
 		} else {
@@ -739,7 +932,11 @@ func (f *Forwarder) ForwardPacketZeroCopy(zcBuf *ZeroCopyBuffer, packetLen int)
 		}
 	}
 
-	// No direct tunnel or tunnel write failed - try persistent relay
+	// No direct tunnel or tunnel write failed - try a relay peer, then the
+	// persistent relay through the coordinator
+	if f.sendViaRelayPeer(packet, peerName) {
+		return nil
+	}
 	f.relayMu.RLock()
 	relay := f.relay
 	f.relayMu.RUnlock()
@@ -775,31 +972,32 @@ func (f *Forwarder) ForwardPacketZeroCopy(zcBuf *ZeroCopyBuffer, packetLen int)
 }
 
 // readFrame reads a length-prefixed frame from the tunnel into the provided buffer.
-// Returns the number of payload bytes read (excluding protocol byte).
-func (f *Forwarder) readFrame(r io.Reader, buf []byte) (int, error) {
+// Returns the number of payload bytes read (excluding protocol byte) and the
+// frame type (protocol byte).
+func (f *Forwarder) readFrame(r io.Reader, buf []byte) (int, byte, error) {
 	// Use stack-allocated header to avoid allocation
 	var header [FrameHeaderSize]byte
 	if _, err := io.ReadFull(r, header[:]); err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 
 	frameLen := int(binary.BigEndian.Uint16(header[0:2]))
 	if frameLen < 1 {
-		return 0, fmt.Errorf("invalid frame length: %d", frameLen)
+		return 0, 0, fmt.Errorf("invalid frame length: %d", frameLen)
 	}
 
 	// frameLen includes the protocol byte
 	payloadLen := frameLen - 1
 	if payloadLen > len(buf) {
-		return 0, fmt.Errorf("frame too large: %d bytes", payloadLen)
+		return 0, 0, fmt.Errorf("frame too large: %d bytes", payloadLen)
 	}
 
 	// Read payload directly into buffer
 	if _, err := io.ReadFull(r, buf[:payloadLen]); err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 
-	return payloadLen, nil
+	return payloadLen, header[2], nil
 }
 
 // Stats returns a copy of the forwarding statistics.
@@ -818,6 +1016,11 @@ func (f *Forwarder) Stats() ForwarderStats {
 		Errors:             atomic.LoadUint64(&f.stats.Errors),
 		ExitPacketsSent:    atomic.LoadUint64(&f.stats.ExitPacketsSent),
 		ExitBytesSent:      atomic.LoadUint64(&f.stats.ExitBytesSent),
+		PeerRelayedSent:    atomic.LoadUint64(&f.stats.PeerRelayedSent),
+		PacketsRelayed:     atomic.LoadUint64(&f.stats.PacketsRelayed),
+		DroppedRelay:       atomic.LoadUint64(&f.stats.DroppedRelay),
+		DroppedExitDenied:  atomic.LoadUint64(&f.stats.DroppedExitDenied),
+		DroppedTooBig:      atomic.LoadUint64(&f.stats.DroppedTooBig),
 	}
 }
 
@@ -881,8 +1084,9 @@ func (f *Forwarder) HandleTunnel(ctx context.Context, peerName string, tunnel io
 
 	// Create a channel for read results to enable timeout detection
 	type readResult struct {
-		n   int
-		err error
+		n         int
+		frameType byte
+		err       error
 	}
 	resultCh := make(chan readResult, 1)
 
@@ -895,8 +1099,8 @@ func (f *Forwarder) HandleTunnel(ctx context.Context, peerName string, tunnel io
 
 		// Start a goroutine to do the blocking read
 		go func() {
-			n, err := f.readFrame(tunnel, buf.Data())
-			resultCh <- readResult{n, err}
+			n, frameType, err := f.readFrame(tunnel, buf.Data())
+			resultCh <- readResult{n, frameType, err}
 		}()
 
 		// Wait for read result with timeout
@@ -924,6 +1128,11 @@ func (f *Forwarder) HandleTunnel(ctx context.Context, peerName string, tunnel io
 				return
 			}
 
+			if result.frameType == FrameTypeRelayed {
+				f.handleRelayed(peerName, buf.Data()[:result.n])
+				continue
+			}
+
 			// Write to TUN with peer context for per-peer filtering
 			if err := f.ReceivePacketFromPeer(buf.Data()[:result.n], peerName); err != nil {
 				log.Debug().Err(err).Msg("receive packet failed")
@@ -931,3 +1140,152 @@ func (f *Forwarder) HandleTunnel(ctx context.Context, peerName string, tunnel io
 		}
 	}
 }
+
+// sendViaRelayPeer sends a packet for a peer we have no direct tunnel to
+// through the cheapest relay peer we have one to. Returns false if there is
+// no such relay peer.
+func (f *Forwarder) sendViaRelayPeer(packet []byte, target string) bool {
+	f.relayPeersMu.RLock()
+	origin := f.localName
+	f.relayPeersMu.RUnlock()
+	if origin == "" {
+		return false
+	}
+
+	relay := f.writeRelayed(relayedHeader{ttl: MaxRelayHops, origin: origin, target: target}, packet, "")
+	if relay == "" {
+		return false
+	}
+
+	atomic.AddUint64(&f.stats.PacketsSent, 1)
+	atomic.AddUint64(&f.stats.BytesSent, uint64(len(packet)))
+	atomic.AddUint64(&f.stats.PeerRelayedSent, 1)
+	log.Trace().
+		Str("peer", target).
+		Str("relay_peer", relay).
+		Int("len", len(packet)).
+		Msg("forwarded packet via relay peer")
+	return true
+}
+
+// writeRelayed writes a relayed frame to the cheapest relay peer for its
+// target that we have a tunnel to, skipping the origin and the peer the
+// frame came from. Returns the relay peer used, or "" if none could be.
+func (f *Forwarder) writeRelayed(h relayedHeader, packet []byte, from string) string {
+	var frame []byte
+	for _, relay := range f.relayPaths.Candidates(h.target) {
+		if relay == h.origin || relay == from {
+			continue
+		}
+		tunnel, ok := f.tunnels.Get(relay)
+		if !ok {
+			continue
+		}
+		if clampRelayed(packet, tunnel, h) || frame == nil {
+			var err error
+			if frame, err = encodeRelayed(h, packet); err != nil {
+				return ""
+			}
+		}
+		if _, err := tunnel.Write(frame); err != nil {
+			atomic.AddUint64(&f.stats.Errors, 1)
+			log.Debug().Err(err).Str("relay_peer", relay).Msg("relay peer tunnel write failed")
+			if !writeTooBig(err) {
+				f.triggerDeadTunnel(relay)
+			}
+			continue
+		}
+		return relay
+	}
+	return ""
+}
+
+// handleRelayed processes a relayed frame received from peer from: it is
+// delivered to the TUN if it is for us, otherwise relayed on towards its
+// target if this peer allows relaying and the TTL is not exhausted.
+func (f *Forwarder) handleRelayed(from string, payload []byte) {
+	h, packet, err := decodeRelayed(payload)
+	if err != nil {
+		atomic.AddUint64(&f.stats.DroppedRelay, 1)
+		log.Debug().Err(err).Str("peer", from).Msg("dropped relayed frame")
+		return
+	}
+
+	// Frames come from their origin, or from a relay peer with a path to
+	// it. Otherwise any peer with a tunnel to us could pass its traffic off
+	// as another peer's, here or through an honest relay peer.
+	if from != h.origin && !f.relayPaths.Reaches(from, h.origin) {
+		atomic.AddUint64(&f.stats.DroppedRelay, 1)
+		log.Debug().Str("peer", from).Str("origin", h.origin).Msg("dropped relayed frame from a peer with no path to its origin")
+		return
+	}
+
+	f.relayPeersMu.RLock()
+	self, allowRelay := f.localName, f.allowRelay
+	f.relayPeersMu.RUnlock()
+
+	if h.target == self {
+		if !f.relayedFromOrigin(h.origin, packet) {
+			atomic.AddUint64(&f.stats.DroppedRelay, 1)
+			log.Debug().Str("peer", from).Str("origin", h.origin).Msg("dropped relayed packet not sent by its origin")
+			return
+		}
+		// Filter as coming from the origin, not the relay peer
+		if err := f.ReceivePacketFromPeer(packet, h.origin); err != nil {
+			log.Debug().Err(err).Str("origin", h.origin).Str("relay_peer", from).Msg("receive relayed packet failed")
+		}
+		return
+	}
+
+	// Loop prevention: frames we sent coming back, and the hop limit
+	if !allowRelay || h.origin == self || h.ttl == 0 {
+		atomic.AddUint64(&f.stats.DroppedRelay, 1)
+		log.Debug().
+			Str("peer", from).
+			Str("origin", h.origin).
+			Str("target", h.target).
+			Bool("allow_relay", allowRelay).
+			Uint8("ttl", h.ttl).
+			Msg("dropped relayed frame")
+		return
+	}
+	h.ttl--
+
+	// Direct tunnel to the target, else the next relay peer
+	if tunnel, ok := f.tunnels.Get(h.target); ok && h.target != from {
+		clampRelayed(packet, tunnel, h)
+		frame, err := encodeRelayed(h, packet)
+		if err == nil {
+			if _, err = tunnel.Write(frame); err == nil {
+				atomic.AddUint64(&f.stats.PacketsRelayed, 1)
+				return
+			}
+			atomic.AddUint64(&f.stats.Errors, 1)
+			if !writeTooBig(err) {
+				f.triggerDeadTunnel(h.target)
+			}
+		}
+	}
+	if f.writeRelayed(h, packet, from) != "" {
+		atomic.AddUint64(&f.stats.PacketsRelayed, 1)
+		return
+	}
+
+	atomic.AddUint64(&f.stats.DroppedRelay, 1)
+	log.Debug().Str("origin", h.origin).Str("target", h.target).Msg("no path to relay frame on")
+}
+
+// relayedFromOrigin reports whether a relayed packet plausibly comes from
+// the origin peer the relay peer claims: its source address must be routed
+// to that peer, or be outside the mesh and the origin one of our exit peers.
+// This keeps relay peers from passing traffic off as another peer's.
+func (f *Forwarder) relayedFromOrigin(origin string, packet []byte) bool {
+	info, err := ParsePacket(packet)
+	if err != nil {
+		return false
+	}
+	if peer, ok := f.router.Lookup(info.SrcIP); ok {
+		return peer == origin
+	}
+	return f.IsExternalTraffic(info.SrcIP) && f.exits.IsExitPeer(origin)
+}
@@ -6,7 +6,9 @@ import (
 	"errors"
 	"io"
 	"net"
+	"os"
 	"sync"
+	"syscall"
 	"testing"
 	"time"
 
@@ -61,7 +63,8 @@ type mockTunnel struct {
 	buf       *bytes.Buffer
 	mu        sync.Mutex
 	closed    bool
-	failWrite bool // If true, Write returns an error
+	failWrite bool  // If true, Write returns an error
+	writeErr  error // Error Write fails with, io.ErrClosedPipe if nil
 }
 
 func newMockTunnel() *mockTunnel {
@@ -80,6 +83,9 @@ func (m *mockTunnel) Write(p []byte) (int, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	if m.failWrite {
+		if m.writeErr != nil {
+			return 0, m.writeErr
+		}
 		return 0, io.ErrClosedPipe
 	}
 	return m.buf.Write(p)
@@ -543,6 +549,41 @@ func TestForwarder_DeadTunnelNoRelay(t *testing.T) {
 	assert.True(t, callbackCalled)
 }
 
+func TestForwarder_TooBigWriteKeepsTunnel(t *testing.T) {
+	router := NewRouter()
+	router.AddRoute("10.42.0.2", "peer1")
+
+	tunnelMgr := NewMockTunnelManager()
+	tunnel := newMockTunnel()
+	tunnel.SetFailWrite(true)
+	tunnel.writeErr = &net.OpError{Op: "write", Net: "udp", Err: os.NewSyscallError("sendto", syscall.EMSGSIZE)}
+	tunnelMgr.Add("peer1", tunnel)
+
+	relay := newMockRelay()
+
+	fwd := NewForwarder(router, tunnelMgr)
+	fwd.SetRelay(relay)
+
+	callbackDone := make(chan struct{})
+	fwd.SetOnDeadTunnel(func(peerName string) {
+		close(callbackDone)
+	})
+
+	srcIP := net.ParseIP("10.42.0.1").To4()
+	dstIP := net.ParseIP("10.42.0.2").To4()
+	packet := BuildIPv4Packet(srcIP, dstIP, ProtoUDP, []byte("too big for the path"))
+
+	// The packet still goes through the relay, but the tunnel isn't dead
+	require.NoError(t, fwd.ForwardPacket(packet))
+	require.Len(t, relay.GetPackets(), 1)
+
+	select {
+	case <-callbackDone:
+		t.Fatal("onDeadTunnel called for a packet too big to send")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
 func TestForwarder_DeadTunnelCallbackDebounced(t *testing.T) {
 	router := NewRouter()
 	router.AddRoute("10.42.0.2", "peer1")
@@ -879,6 +920,39 @@ func TestForwarder_ExitPeer_MeshTrafficStaysDirect(t *testing.T) {
 	assert.Empty(t, exitTunnelData, "mesh traffic should NOT go to exit node")
 }
 
+func TestForwarder_SubnetRouteTakesPrecedenceOverExitPeer(t *testing.T) {
+	router := NewRouter()
+	router.AddRoute("10.42.0.5", "exit-server")
+	router.UpdateSubnetRoutes(map[string]string{"192.168.10.0/24": "office"})
+
+	tunnelMgr := NewMockTunnelManager()
+	officeTunnel := newMockTunnel()
+	exitTunnel := newMockTunnel()
+	tunnelMgr.Add("office", officeTunnel)
+	tunnelMgr.Add("exit-server", exitTunnel)
+
+	fwd := NewForwarder(router, tunnelMgr)
+	_, meshNet, _ := net.ParseCIDR("10.42.0.0/16")
+	fwd.SetMeshCIDR(meshNet)
+	fwd.SetExitPeer("exit-server")
+
+	srcIP := net.ParseIP("10.42.0.1").To4()
+
+	// Advertised LAN goes to the advertising peer
+	packet := BuildIPv4Packet(srcIP, net.ParseIP("192.168.10.20").To4(), ProtoUDP, []byte("to office LAN"))
+	require.NoError(t, fwd.ForwardPacket(packet))
+	assert.NotEmpty(t, officeTunnel.GetData(), "subnet traffic should go to the advertising peer")
+	assert.Empty(t, exitTunnel.GetData(), "subnet traffic should NOT go to exit node")
+
+	// Everything else external still uses the exit node
+	zcBuf := NewZeroCopyBuffer(FrameHeaderSize, 1500)
+	packet = BuildIPv4Packet(srcIP, net.ParseIP("8.8.8.8").To4(), ProtoUDP, []byte("to google DNS"))
+	n := copy(zcBuf.DataSlice(), packet)
+	zcBuf.SetLength(n)
+	require.NoError(t, fwd.ForwardPacketZeroCopy(zcBuf, n))
+	assert.NotEmpty(t, exitTunnel.GetData(), "other external traffic should go to exit node")
+}
+
 func TestForwarder_ExitPeer_NoExitPeerConfigured(t *testing.T) {
 	router := NewRouter()
 	// No route for 8.8.8.8
@@ -933,6 +1007,246 @@ func TestForwarder_ExitPeer_FallbackToRelay(t *testing.T) {
 	assert.Equal(t, "exit-server", relayPackets[0].target)
 }
 
+func TestForwarder_ExitPeers_Failover(t *testing.T) {
+	router := NewRouter()
+
+	tunnelMgr := NewMockTunnelManager()
+	backupTunnel := newMockTunnel()
+	tunnelMgr.Add("exit-backup", backupTunnel)
+
+	relay := newMockRelay()
+	relay.SetConnected(false)
+
+	fwd := NewForwarder(router, tunnelMgr)
+	fwd.SetRelay(relay)
+
+	_, meshNet, _ := net.ParseCIDR("10.42.0.0/16")
+	fwd.SetMeshCIDR(meshNet)
+	fwd.SetExitPeers([]string{"exit-primary", "exit-backup"})
+	assert.Equal(t, "exit-primary", fwd.ExitPeer())
+
+	srcIP := net.ParseIP("10.42.0.1").To4()
+	dstIP := net.ParseIP("8.8.8.8").To4()
+	packet := BuildIPv4Packet(srcIP, dstIP, ProtoUDP, []byte("to external"))
+
+	// exit-primary is unreachable, the packet goes to exit-backup
+	err := fwd.ForwardPacket(packet)
+	require.NoError(t, err)
+	assert.NotEmpty(t, backupTunnel.GetData())
+	assert.Equal(t, "exit-backup", fwd.ExitPeer())
+}
+
+func TestForwarder_ExitClients(t *testing.T) {
+	fwd, _, _ := newRelayTestForwarder("exit")
+	_, lan, _ := net.ParseCIDR("192.168.1.0/24")
+	fwd.SetLocalRoutes([]*net.IPNet{lan})
+
+	src := net.ParseIP("10.42.0.1").To4()
+	external := BuildIPv4Packet(src, net.ParseIP("8.8.8.8").To4(), ProtoUDP, []byte("out"))
+	toLAN := BuildIPv4Packet(src, net.ParseIP("192.168.1.10").To4(), ProtoUDP, []byte("lan"))
+	toMesh := BuildIPv4Packet(src, net.ParseIP("10.42.0.9").To4(), ProtoUDP, []byte("mesh"))
+
+	// Every peer may use the exit peer by default
+	require.NoError(t, fwd.ReceivePacketFromPeer(external, "alice"))
+	assert.Equal(t, uint64(1), fwd.Stats().PacketsReceived)
+
+	fwd.SetExitClients([]string{"bob"})
+	require.NoError(t, fwd.ReceivePacketFromPeer(external, "alice"))
+	assert.Equal(t, uint64(1), fwd.Stats().PacketsReceived, "alice may not use the exit peer")
+	assert.Equal(t, uint64(1), fwd.Stats().DroppedExitDenied)
+
+	require.NoError(t, fwd.ReceivePacketFromPeer(external, "bob"))
+	require.NoError(t, fwd.ReceivePacketFromPeer(toLAN, "alice"))
+	require.NoError(t, fwd.ReceivePacketFromPeer(toMesh, "alice"))
+	assert.Equal(t, uint64(4), fwd.Stats().PacketsReceived, "subnet route and mesh traffic is not exit traffic")
+
+	fwd.SetExitClients(nil)
+	require.NoError(t, fwd.ReceivePacketFromPeer(external, "alice"))
+	assert.Equal(t, uint64(5), fwd.Stats().PacketsReceived)
+}
+
+// Multi-hop Relay Tests
+
+// newRelayTestForwarder creates the forwarder of peer name, with mesh routes
+// for alice (10.42.0.1), relay (10.42.0.2) and bob (10.42.0.3).
+func newRelayTestForwarder(name string) (*Forwarder, *MockTunnelManager, *mockTUN) {
+	router := NewRouter()
+	router.AddRoute("10.42.0.1", "alice")
+	router.AddRoute("10.42.0.2", "relay")
+	router.AddRoute("10.42.0.3", "bob")
+	tunnelMgr := NewMockTunnelManager()
+	tun := newMockTUN()
+
+	fwd := NewForwarder(router, tunnelMgr)
+	fwd.SetTUN(tun)
+	_, meshNet, _ := net.ParseCIDR("10.42.0.0/16")
+	fwd.SetMeshCIDR(meshNet)
+	fwd.SetLocalName(name)
+	return fwd, tunnelMgr, tun
+}
+
+// relayedPayload returns the payload of a relayed frame written to a tunnel.
+func relayedPayload(t *testing.T, tunnel *mockTunnel) []byte {
+	t.Helper()
+	frame := tunnel.GetData()
+	require.Greater(t, len(frame), FrameHeaderSize)
+	require.Equal(t, byte(FrameTypeRelayed), frame[2])
+	return frame[FrameHeaderSize:]
+}
+
+func TestForwarder_RelayPeer_PreferredOverCoordinator(t *testing.T) {
+	fwd, tunnelMgr, _ := newRelayTestForwarder("alice")
+	relayTunnel := newMockTunnel()
+	tunnelMgr.Add("relay", relayTunnel)
+	coordRelay := newMockRelay()
+	fwd.SetRelay(coordRelay)
+	fwd.RelayPaths().Update("alice", map[string]map[string]int64{"relay": {"bob": 1_000}}, nil)
+
+	packet := BuildIPv4Packet(net.ParseIP("10.42.0.1").To4(), net.ParseIP("10.42.0.3").To4(), ProtoUDP, []byte("to bob"))
+	require.NoError(t, fwd.ForwardPacket(packet))
+
+	h, inner, err := decodeRelayed(relayedPayload(t, relayTunnel))
+	require.NoError(t, err)
+	assert.Equal(t, relayedHeader{ttl: MaxRelayHops, origin: "alice", target: "bob"}, h)
+	assert.Equal(t, packet, inner)
+	assert.Empty(t, coordRelay.GetPackets(), "coordinator relay is the last resort")
+	assert.Equal(t, uint64(1), fwd.Stats().PeerRelayedSent)
+
+	// Without a tunnel to the relay peer, the coordinator relay is used
+	tunnelMgr.Remove("relay")
+	require.NoError(t, fwd.ForwardPacket(packet))
+	assert.Len(t, coordRelay.GetPackets(), 1)
+}
+
+func TestForwarder_RelayPeer_RelaysToTarget(t *testing.T) {
+	fwd, tunnelMgr, tun := newRelayTestForwarder("relay")
+	fwd.SetAllowRelay(true)
+	bobTunnel := newMockTunnel()
+	tunnelMgr.Add("bob", bobTunnel)
+
+	packet := BuildIPv4Packet(net.ParseIP("10.42.0.1").To4(), net.ParseIP("10.42.0.3").To4(), ProtoUDP, []byte("to bob"))
+	frame, err := encodeRelayed(relayedHeader{ttl: 2, origin: "alice", target: "bob"}, packet)
+	require.NoError(t, err)
+	fwd.handleRelayed("alice", frame[FrameHeaderSize:])
+
+	h, inner, err := decodeRelayed(relayedPayload(t, bobTunnel))
+	require.NoError(t, err)
+	assert.Equal(t, relayedHeader{ttl: 1, origin: "alice", target: "bob"}, h)
+	assert.Equal(t, packet, inner)
+	assert.Empty(t, tun.GetWrittenPackets(), "relayed packets are not delivered locally")
+	assert.Equal(t, uint64(1), fwd.Stats().PacketsRelayed)
+}
+
+func TestForwarder_RelayPeer_Drops(t *testing.T) {
+	packet := BuildIPv4Packet(net.ParseIP("10.42.0.1").To4(), net.ParseIP("10.42.0.3").To4(), ProtoUDP, []byte("to bob"))
+
+	tests := []struct {
+		name       string
+		allowRelay bool
+		header     relayedHeader
+		from       string
+	}{
+		{"relaying not allowed", false, relayedHeader{ttl: 2, origin: "alice", target: "bob"}, "alice"},
+		{"TTL exhausted", true, relayedHeader{ttl: 0, origin: "alice", target: "bob"}, "alice"},
+		{"own frame looped back", true, relayedHeader{ttl: 2, origin: "relay", target: "bob"}, "alice"},
+		{"no path to target", true, relayedHeader{ttl: 2, origin: "alice", target: "carol"}, "alice"},
+		{"back to the sender", true, relayedHeader{ttl: 2, origin: "alice", target: "bob"}, "bob"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fwd, tunnelMgr, _ := newRelayTestForwarder("relay")
+			fwd.SetAllowRelay(tt.allowRelay)
+			bobTunnel := newMockTunnel()
+			tunnelMgr.Add("bob", bobTunnel)
+
+			frame, err := encodeRelayed(tt.header, packet)
+			require.NoError(t, err)
+			fwd.handleRelayed(tt.from, frame[FrameHeaderSize:])
+
+			assert.Empty(t, bobTunnel.GetData())
+			assert.Equal(t, uint64(1), fwd.Stats().DroppedRelay)
+		})
+	}
+}
+
+func TestForwarder_RelayPeer_DeliversFromOrigin(t *testing.T) {
+	fwd, _, tun := newRelayTestForwarder("bob")
+	fwd.RelayPaths().Update("bob", map[string]map[string]int64{"relay": {"alice": 0, "bob": 0}}, nil)
+
+	packet := BuildIPv4Packet(net.ParseIP("10.42.0.1").To4(), net.ParseIP("10.42.0.3").To4(), ProtoUDP, []byte("from alice"))
+	frame, err := encodeRelayed(relayedHeader{ttl: 1, origin: "alice", target: "bob"}, packet)
+	require.NoError(t, err)
+
+	// Frames arrive through tunnels
+	relayTunnel := newMockTunnel()
+	_, err = relayTunnel.Write(frame)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fwd.HandleTunnel(ctx, "relay", relayTunnel)
+
+	assert.Equal(t, packet, tun.GetWrittenPackets())
+	assert.Equal(t, uint64(1), fwd.Stats().PacketsReceived)
+}
+
+func TestForwarder_RelayPeer_RejectsSpoofedOrigin(t *testing.T) {
+	fwd, _, tun := newRelayTestForwarder("bob")
+	fwd.RelayPaths().Update("bob", map[string]map[string]int64{"relay": {"alice": 0, "bob": 0}}, nil)
+
+	// The relay peer claims a packet from its own mesh IP comes from alice
+	packet := BuildIPv4Packet(net.ParseIP("10.42.0.2").To4(), net.ParseIP("10.42.0.3").To4(), ProtoUDP, []byte("spoofed"))
+	frame, err := encodeRelayed(relayedHeader{ttl: 1, origin: "alice", target: "bob"}, packet)
+	require.NoError(t, err)
+	fwd.handleRelayed("relay", frame[FrameHeaderSize:])
+	assert.Empty(t, tun.GetWrittenPackets())
+
+	// Internet traffic is only expected from exit peers
+	external := BuildIPv4Packet(net.ParseIP("8.8.8.8").To4(), net.ParseIP("10.42.0.3").To4(), ProtoUDP, []byte("reply"))
+	frame, err = encodeRelayed(relayedHeader{ttl: 1, origin: "alice", target: "bob"}, external)
+	require.NoError(t, err)
+	fwd.handleRelayed("relay", frame[FrameHeaderSize:])
+	assert.Empty(t, tun.GetWrittenPackets())
+	assert.Equal(t, uint64(2), fwd.Stats().DroppedRelay)
+
+	fwd.SetExitPeer("alice")
+	fwd.handleRelayed("relay", frame[FrameHeaderSize:])
+	assert.Equal(t, external, tun.GetWrittenPackets())
+}
+
+func TestForwarder_RelayPeer_RejectsNonRelaySender(t *testing.T) {
+	fwd, _, tun := newRelayTestForwarder("bob")
+	fwd.RelayPaths().Update("bob", map[string]map[string]int64{"relay": {"bob": 0}}, nil)
+
+	// A peer with a tunnel to bob, but that is not a relay peer, sends a
+	// packet with alice's address as if relayed from her
+	packet := BuildIPv4Packet(net.ParseIP("10.42.0.1").To4(), net.ParseIP("10.42.0.3").To4(), ProtoUDP, []byte("spoofed"))
+	frame, err := encodeRelayed(relayedHeader{ttl: 1, origin: "alice", target: "bob"}, packet)
+	require.NoError(t, err)
+	fwd.handleRelayed("carol", frame[FrameHeaderSize:])
+	assert.Empty(t, tun.GetWrittenPackets())
+
+	// Nor can a relay peer that reports no tunnel to alice
+	fwd.handleRelayed("relay", frame[FrameHeaderSize:])
+	assert.Empty(t, tun.GetWrittenPackets())
+	assert.Equal(t, uint64(2), fwd.Stats().DroppedRelay)
+	assert.Zero(t, fwd.Stats().PacketsReceived)
+}
+
+func TestForwarder_RelayPeer_DoesNotRelaySpoofedOrigin(t *testing.T) {
+	fwd, tunnelMgr, _ := newRelayTestForwarder("relay")
+	fwd.SetAllowRelay(true)
+	bobTunnel := newMockTunnel()
+	tunnelMgr.Add("bob", bobTunnel)
+
+	// carol can't get a packet claiming to be alice's relayed on to bob
+	packet := BuildIPv4Packet(net.ParseIP("10.42.0.1").To4(), net.ParseIP("10.42.0.3").To4(), ProtoUDP, []byte("spoofed"))
+	frame, err := encodeRelayed(relayedHeader{ttl: 2, origin: "alice", target: "bob"}, packet)
+	require.NoError(t, err)
+	fwd.handleRelayed("carol", frame[FrameHeaderSize:])
+	assert.Empty(t, bobTunnel.GetData())
+	assert.Equal(t, uint64(1), fwd.Stats().DroppedRelay)
+}
+
 // Stats Feature Gate Tests
 
 func TestForwarderStats_EnabledByDefault(t *testing.T) {
@@ -1235,3 +1549,88 @@ func TestForwarder_PacketFilter_PeerSpecific(t *testing.T) {
 		assert.Equal(t, uint64(0), stats.PacketsReceived, "no packets should be received")
 	})
 }
+
+func TestForwarder_PacketFilter_Conntrack(t *testing.T) {
+	router := NewRouter()
+	router.AddRoute("10.42.0.2", "peer2")
+	tunnelMgr := NewMockTunnelManager()
+	tunnelMgr.Add("peer2", newMockTunnel())
+	mockTun := newMockTUN()
+
+	fwd := NewForwarder(router, tunnelMgr)
+	fwd.SetTUN(mockTun)
+	fwd.SetFilter(NewPacketFilter(true))
+
+	local := net.ParseIP("10.42.0.1").To4()
+	remote := net.ParseIP("10.42.0.2").To4()
+	reply := buildUDPPacket(remote, local, 53, 40000)
+
+	// Unsolicited UDP is dropped in allowlist mode
+	require.NoError(t, fwd.ReceivePacketFromPeer(reply, "peer2"))
+	assert.Empty(t, mockTun.GetWrittenPackets())
+
+	// Replies to a packet this peer sent are let in from the peer it was
+	// routed to, and only from it
+	require.NoError(t, fwd.ForwardPacket(buildUDPPacket(local, remote, 40000, 53)))
+	require.NoError(t, fwd.ReceivePacketFromPeer(reply, "peer3"))
+	require.NoError(t, fwd.ReceivePacketFromPeer(reply, "peer2"))
+	assert.Equal(t, reply, mockTun.GetWrittenPackets())
+	assert.Equal(t, uint64(2), fwd.Stats().DroppedFiltered)
+}
+
+func TestForwarder_ForwardPacket_IPv6(t *testing.T) {
+	router := NewRouter()
+	router.AddRoute("fd42:6d65:7368::2", "peer2")
+
+	tunnelMgr := NewMockTunnelManager()
+	peerTunnel := newMockTunnel()
+	tunnelMgr.Add("peer2", peerTunnel)
+
+	tun := newMockTUN()
+	fwd := NewForwarder(router, tunnelMgr)
+	fwd.SetTUN(tun)
+	fwd.SetLocalIPv6(net.ParseIP("fd42:6d65:7368::1"))
+
+	src := net.ParseIP("fd42:6d65:7368::1")
+	packet := BuildIPv6Packet(src, net.ParseIP("fd42:6d65:7368::2"), ProtoUDP, []byte("hello"))
+	require.NoError(t, fwd.ForwardPacket(packet))
+	assert.Equal(t, packet, peerTunnel.GetData()[FrameHeaderSize:])
+
+	// Local destination goes back to the TUN
+	local := BuildIPv6Packet(src, src, ProtoUDP, []byte("loopback"))
+	require.NoError(t, fwd.ForwardPacket(local))
+	assert.Equal(t, local, tun.GetWrittenPackets())
+
+	// Link-local and multicast traffic is dropped without error
+	require.NoError(t, fwd.ForwardPacket(BuildIPv6Packet(net.ParseIP("fe80::1"), net.ParseIP("ff02::2"), ProtoICMPv6, make([]byte, 8))))
+	require.NoError(t, fwd.ForwardPacket(BuildIPv6Packet(src, net.ParseIP("fe80::2"), ProtoUDP, nil)))
+
+	err := fwd.ForwardPacket(BuildIPv6Packet(src, net.ParseIP("fd42:6d65:7368::9"), ProtoUDP, nil))
+	assert.ErrorIs(t, err, ErrNoRoute)
+	assert.Equal(t, uint64(0), fwd.Stats().DroppedNonIPv4)
+}
+
+func TestForwarder_IPv6ExitPeer(t *testing.T) {
+	router := NewRouter()
+	tunnelMgr := NewMockTunnelManager()
+	exitTunnel := newMockTunnel()
+	tunnelMgr.Add("exit-server", exitTunnel)
+
+	fwd := NewForwarder(router, tunnelMgr)
+	_, meshNet6, _ := net.ParseCIDR("fd42:6d65:7368::/64")
+	fwd.SetExitPeer("exit-server")
+
+	src := net.ParseIP("fd42:6d65:7368::1")
+	external := BuildIPv6Packet(src, net.ParseIP("2001:4860:4860::8888"), ProtoUDP, []byte("dns"))
+
+	// Without an IPv6 mesh prefix, IPv6 destinations cannot be classified
+	assert.False(t, fwd.IsExternalTraffic(net.ParseIP("2001:4860:4860::8888")))
+
+	fwd.SetMeshCIDRv6(meshNet6)
+	assert.True(t, fwd.IsExternalTraffic(net.ParseIP("2001:4860:4860::8888")))
+	assert.False(t, fwd.IsExternalTraffic(net.ParseIP("fd42:6d65:7368::2")))
+
+	require.NoError(t, fwd.ForwardPacket(external))
+	assert.NotEmpty(t, exitTunnel.GetData(), "external IPv6 traffic should go to exit node")
+	assert.Equal(t, uint64(1), fwd.Stats().ExitPacketsSent)
+}
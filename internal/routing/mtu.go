@@ -0,0 +1,227 @@
+package routing
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Tunnels that know the path MTU to their peer (UDP sessions, once path MTU
+// discovery completes) report the largest write they send unfragmented.
+// Packets forwarded through them are kept within it: TCP SYNs get their MSS
+// clamped so connections never send larger segments, and other packets that
+// are too big are dropped and answered with an ICMP "fragmentation needed"
+// (ICMPv6 "packet too big"), so the sender lowers its path MTU instead of
+// having them silently lost on the path. IPv4 packets that may be fragmented
+// are sent as they are: tunnel packets don't have the don't-fragment bit set,
+// so they are fragmented on the way. A write the local stack refuses as too
+// big (EMSGSIZE) falls back to relay like any failed write, but doesn't mark
+// the tunnel dead.
+//
+// Relayed packets have their MSS clamped at every hop, for the MTU of the
+// tunnel to the next hop less the relayed frame header. Nothing else is done
+// about their size: the MTU of the hops after the first isn't known to the
+// origin, and a relay peer can't send the origin an ICMP error.
+
+// MTUReporter is implemented by tunnels that send each write as one packet
+// and know the largest that isn't fragmented on the path to their peer.
+type MTUReporter interface {
+	// MaxWriteSize returns the largest write sent unfragmented, or 0 if unknown.
+	MaxWriteSize() int
+}
+
+const (
+	// tcpHeaderLen is the length of a TCP header without options.
+	tcpHeaderLen = 20
+	// tcpOptMSS is the kind of the TCP maximum segment size option.
+	tcpOptMSS = 2
+
+	// icmpv4DestUnreachable and icmpv4FragNeeded are the type and code of an
+	// ICMP "fragmentation needed and DF set" message.
+	icmpv4DestUnreachable = 3
+	icmpv4FragNeeded      = 4
+	// icmpv6PacketTooBig is the type of an ICMPv6 "packet too big" message.
+	icmpv6PacketTooBig = 2
+	// icmpHeaderLen is the length of the ICMP header of both messages.
+	icmpHeaderLen = 8
+
+	// maxICMPv4Len and minIPv6MTU limit the ICMP messages we send, which
+	// quote as much of the offending packet as fits.
+	maxICMPv4Len = 576
+	minIPv6MTU   = 1280
+)
+
+// tunnelMTU returns the largest IP packet a tunnel carries unfragmented, or
+// 0 if unknown.
+func tunnelMTU(tunnel io.Writer) int {
+	r, ok := tunnel.(MTUReporter)
+	if !ok {
+		return 0
+	}
+	if n := r.MaxWriteSize(); n > FrameHeaderSize {
+		return n - FrameHeaderSize
+	}
+	return 0
+}
+
+// clampRelayed clamps the MSS of a TCP SYN relayed through a tunnel in a
+// frame with header h. Returns true if the packet was changed.
+func clampRelayed(packet []byte, tunnel io.Writer, h relayedHeader) bool {
+	mtu := tunnelMTU(tunnel) - relayedHeaderSize(h)
+	return mtu > 0 && clampMSS(packet, mtu)
+}
+
+// fitTunnel keeps a packet forwarded through a tunnel with the given MTU
+// within it, clamping the MSS of TCP SYNs in place. Returns false if the
+// packet is too big and was answered with an ICMP error instead. Packets we
+// can't send an ICMP error for, like IPv4 packets that may be fragmented,
+// are sent anyway and fragmented on the way, as are all packets while the
+// tunnel's MTU isn't known.
+func (f *Forwarder) fitTunnel(packet []byte, mtu int) bool {
+	if mtu == 0 {
+		return true
+	}
+	clampMSS(packet, mtu)
+	if len(packet) <= mtu {
+		return true
+	}
+	reply := tooBigICMP(packet, mtu)
+	if reply == nil {
+		return true
+	}
+
+	f.incStat(atomic.LoadUint32(&f.statsEnabled) == 1, &f.stats.DroppedTooBig)
+	f.tunMu.RLock()
+	tun := f.tun
+	f.tunMu.RUnlock()
+	if tun == nil {
+		return false
+	}
+	if _, err := tun.Write(reply); err != nil {
+		log.Debug().Err(err).Msg("failed to write ICMP packet too big to TUN")
+	}
+	return false
+}
+
+// writeTooBig reports whether a tunnel write failed because the packet was
+// too big to send (EMSGSIZE). The tunnel itself is fine, so it isn't marked
+// dead.
+func writeTooBig(err error) bool {
+	return errors.Is(err, syscall.EMSGSIZE)
+}
+
+// clampMSS lowers the MSS option of a TCP SYN so that segments fit in mtu,
+// updating the TCP checksum. Returns true if the packet was changed.
+func clampMSS(packet []byte, mtu int) bool {
+	protocol, offset, ok := TransportHeader(packet)
+	if !ok || protocol != ProtoTCP || len(packet) < offset+tcpHeaderLen {
+		return false
+	}
+	tcp := packet[offset:]
+	if tcp[13]&tcpFlagSYN == 0 {
+		return false
+	}
+	dataOffset := int(tcp[12]>>4) * 4
+	if dataOffset < tcpHeaderLen || dataOffset > len(tcp) {
+		return false
+	}
+	mss := mtu - offset - tcpHeaderLen
+	if mss <= 0 {
+		return false
+	}
+
+	for i := tcpHeaderLen; i < dataOffset; {
+		switch tcp[i] {
+		case 0: // End of options
+			return false
+		case 1: // No-op
+			i++
+			continue
+		}
+		if i+1 >= dataOffset || tcp[i+1] < 2 || i+int(tcp[i+1]) > dataOffset {
+			return false
+		}
+		if tcp[i] == tcpOptMSS && tcp[i+1] == 4 {
+			old := binary.BigEndian.Uint16(tcp[i+2 : i+4])
+			if int(old) <= mss {
+				return false
+			}
+			binary.BigEndian.PutUint16(tcp[i+2:i+4], uint16(mss))
+			// A field at an odd offset adds to the checksum byte-swapped
+			if (i+2)%2 == 0 {
+				updateChecksum(tcp[16:18], old, uint16(mss))
+			} else {
+				updateChecksum(tcp[16:18], old<<8|old>>8, uint16(mss)<<8|uint16(mss)>>8)
+			}
+			return true
+		}
+		i += int(tcp[i+1])
+	}
+	return false
+}
+
+// updateChecksum incrementally updates an Internet checksum for a 16-bit
+// word changed from old to updated (RFC 1624).
+func updateChecksum(field []byte, old, updated uint16) {
+	sum := uint32(^binary.BigEndian.Uint16(field)) + uint32(^old) + uint32(updated)
+	sum = (sum & 0xFFFF) + (sum >> 16)
+	sum = (sum & 0xFFFF) + (sum >> 16)
+	binary.BigEndian.PutUint16(field, ^uint16(sum))
+}
+
+// tooBigICMP returns the ICMP error telling the sender of a packet that it
+// is larger than mtu, or nil if none should be sent: for IPv4 packets that
+// may be fragmented, ICMP errors, or an mtu below the IPv6 minimum. The
+// error is sent from the packet's destination, as if the path told it.
+func tooBigICMP(packet []byte, mtu int) []byte {
+	info, err := ParsePacket(packet)
+	if err != nil || isICMPError(info) {
+		return nil
+	}
+
+	if packet[0]>>4 == 6 {
+		if mtu < minIPv6MTU {
+			return nil
+		}
+		quote := packet[:min(len(packet), minIPv6MTU-IPv6HeaderLen-icmpHeaderLen)]
+		msg := make([]byte, icmpHeaderLen+len(quote))
+		msg[0] = icmpv6PacketTooBig
+		binary.BigEndian.PutUint32(msg[4:8], uint32(mtu))
+		copy(msg[icmpHeaderLen:], quote)
+
+		// The ICMPv6 checksum covers a pseudo-header
+		pseudo := make([]byte, 0, 40+len(msg))
+		pseudo = append(pseudo, info.DstIP.To16()...)
+		pseudo = append(pseudo, info.SrcIP.To16()...)
+		pseudo = binary.BigEndian.AppendUint32(pseudo, uint32(len(msg)))
+		pseudo = append(pseudo, 0, 0, 0, ProtoICMPv6)
+		pseudo = append(pseudo, msg...)
+		binary.BigEndian.PutUint16(msg[2:4], CalculateIPv4Checksum(pseudo))
+		return BuildIPv6Packet(info.DstIP, info.SrcIP, ProtoICMPv6, msg)
+	}
+
+	if packet[6]&0x40 == 0 {
+		return nil // DF not set: routers may fragment it
+	}
+	quote := packet[:min(len(packet), maxICMPv4Len-20-icmpHeaderLen)]
+	msg := make([]byte, icmpHeaderLen+len(quote))
+	msg[0] = icmpv4DestUnreachable
+	msg[1] = icmpv4FragNeeded
+	binary.BigEndian.PutUint16(msg[6:8], uint16(mtu))
+	copy(msg[icmpHeaderLen:], quote)
+	binary.BigEndian.PutUint16(msg[2:4], CalculateIPv4Checksum(msg))
+	return BuildIPv4Packet(info.DstIP, info.SrcIP, ProtoICMP, msg)
+}
+
+// isICMPError reports whether a packet is an ICMP error message, which must
+// never be answered with another.
+func isICMPError(info *PacketInfo) bool {
+	if len(info.Payload) == 0 || !isICMP(info.Protocol) {
+		return false
+	}
+	return isICMPErrorType(info.Protocol, info.Payload[0])
+}
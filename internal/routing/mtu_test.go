@@ -0,0 +1,210 @@
+package routing
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mtuTunnel is a tunnel that reports its largest unfragmented write.
+type mtuTunnel struct {
+	*mockTunnel
+	maxWrite int
+}
+
+func (m *mtuTunnel) MaxWriteSize() int {
+	return m.maxWrite
+}
+
+// transportChecksum computes the checksum of a TCP segment or ICMPv6
+// message, including the pseudo-header.
+func transportChecksum(src, dst net.IP, proto uint8, segment []byte) uint16 {
+	var pseudo []byte
+	if src.To4() != nil {
+		pseudo = append(pseudo, src.To4()...)
+		pseudo = append(pseudo, dst.To4()...)
+		pseudo = append(pseudo, 0, proto)
+		pseudo = binary.BigEndian.AppendUint16(pseudo, uint16(len(segment)))
+	} else {
+		pseudo = append(pseudo, src.To16()...)
+		pseudo = append(pseudo, dst.To16()...)
+		pseudo = binary.BigEndian.AppendUint32(pseudo, uint32(len(segment)))
+		pseudo = append(pseudo, 0, 0, 0, proto)
+	}
+	return CalculateIPv4Checksum(append(pseudo, segment...))
+}
+
+// buildSYN builds a TCP SYN with the given options and a valid checksum.
+func buildSYN(src, dst net.IP, options []byte) []byte {
+	tcp := make([]byte, tcpHeaderLen+len(options))
+	binary.BigEndian.PutUint16(tcp[0:2], 40000)
+	binary.BigEndian.PutUint16(tcp[2:4], 443)
+	tcp[12] = byte((tcpHeaderLen+len(options))/4) << 4
+	tcp[13] = tcpFlagSYN
+	copy(tcp[tcpHeaderLen:], options)
+	binary.BigEndian.PutUint16(tcp[16:18], transportChecksum(src, dst, ProtoTCP, tcp))
+	if src.To4() != nil {
+		return BuildIPv4Packet(src, dst, ProtoTCP, tcp)
+	}
+	return BuildIPv6Packet(src, dst, ProtoTCP, tcp)
+}
+
+func TestClampMSS(t *testing.T) {
+	src4, dst4 := net.ParseIP("10.42.0.1").To4(), net.ParseIP("10.42.0.2").To4()
+	src6, dst6 := net.ParseIP("fd00::1"), net.ParseIP("fd00::2")
+	mss1460 := []byte{tcpOptMSS, 4, 0x05, 0xB4}
+
+	tests := []struct {
+		name     string
+		src, dst net.IP
+		options  []byte
+		mtu      int
+		wantMSS  int // 0 if the packet must be left unchanged
+	}{
+		{name: "IPv4", src: src4, dst: dst4, options: mss1460, mtu: 1300, wantMSS: 1260},
+		{name: "IPv6", src: src6, dst: dst6, options: mss1460, mtu: 1300, wantMSS: 1240},
+		{
+			name: "odd offset", src: src4, dst: dst4, mtu: 1300, wantMSS: 1260,
+			options: []byte{1, tcpOptMSS, 4, 0x05, 0xB4, 1, 1, 1},
+		},
+		{name: "MSS already fits", src: src4, dst: dst4, options: mss1460, mtu: 1500},
+		{name: "no MSS option", src: src4, dst: dst4, options: []byte{1, 1, 1, 0}, mtu: 1300},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packet := buildSYN(tt.src, tt.dst, tt.options)
+			original := append([]byte(nil), packet...)
+
+			changed := clampMSS(packet, tt.mtu)
+			if tt.wantMSS == 0 {
+				assert.False(t, changed)
+				assert.Equal(t, original, packet)
+				return
+			}
+			require.True(t, changed)
+
+			_, offset, _ := TransportHeader(packet)
+			tcp := packet[offset:]
+			i := tcpHeaderLen
+			for tcp[i] == 1 {
+				i++
+			}
+			assert.Equal(t, tt.wantMSS, int(binary.BigEndian.Uint16(tcp[i+2:i+4])))
+			assert.Zero(t, transportChecksum(tt.src, tt.dst, ProtoTCP, tcp), "TCP checksum no longer valid")
+		})
+	}
+
+	// Only SYNs are clamped
+	packet := buildSYN(src4, dst4, mss1460)
+	packet[20+13] = tcpFlagACK
+	assert.False(t, clampMSS(packet, 1300))
+}
+
+func TestTooBigICMP(t *testing.T) {
+	src, dst := net.ParseIP("10.42.0.1").To4(), net.ParseIP("10.42.0.2").To4()
+	packet := BuildIPv4Packet(src, dst, ProtoUDP, make([]byte, 1400))
+	assert.Nil(t, tooBigICMP(packet, 1300), "answered a packet that may be fragmented")
+
+	packet[6] |= 0x40 // DF
+	reply := tooBigICMP(packet, 1300)
+	require.NotNil(t, reply)
+	info, err := ParsePacket(reply)
+	require.NoError(t, err)
+	assert.True(t, info.SrcIP.Equal(dst))
+	assert.True(t, info.DstIP.Equal(src))
+	assert.Equal(t, uint8(ProtoICMP), info.Protocol)
+	assert.Equal(t, []byte{icmpv4DestUnreachable, icmpv4FragNeeded}, info.Payload[:2])
+	assert.Equal(t, 1300, int(binary.BigEndian.Uint16(info.Payload[6:8])))
+	assert.Zero(t, CalculateIPv4Checksum(info.Payload), "ICMP checksum invalid")
+	assert.LessOrEqual(t, len(reply), maxICMPv4Len)
+
+	// ICMP errors are never answered
+	assert.Nil(t, tooBigICMP(reply, 100))
+
+	src6, dst6 := net.ParseIP("fd00::1"), net.ParseIP("fd00::2")
+	packet = BuildIPv6Packet(src6, dst6, ProtoUDP, make([]byte, 1400))
+	reply = tooBigICMP(packet, 1300)
+	require.NotNil(t, reply)
+	info, err = ParsePacket(reply)
+	require.NoError(t, err)
+	assert.True(t, info.SrcIP.Equal(dst6))
+	assert.Equal(t, uint8(ProtoICMPv6), info.Protocol)
+	assert.Equal(t, byte(icmpv6PacketTooBig), info.Payload[0])
+	assert.Equal(t, 1300, int(binary.BigEndian.Uint32(info.Payload[4:8])))
+	assert.Zero(t, transportChecksum(dst6, src6, ProtoICMPv6, info.Payload), "ICMPv6 checksum invalid")
+	assert.LessOrEqual(t, len(reply), minIPv6MTU)
+
+	assert.Nil(t, tooBigICMP(packet, 1200), "reported an MTU below the IPv6 minimum")
+}
+
+func TestForwarder_TunnelMTU(t *testing.T) {
+	router := NewRouter()
+	router.AddRoute("10.42.0.2", "peer1")
+	tunnelMgr := NewMockTunnelManager()
+	tunnel := &mtuTunnel{mockTunnel: newMockTunnel(), maxWrite: 1300 + FrameHeaderSize}
+	tunnelMgr.Add("peer1", tunnel)
+	tun := newMockTUN()
+
+	fwd := NewForwarder(router, tunnelMgr)
+	fwd.SetTUN(tun)
+
+	src, dst := net.ParseIP("10.42.0.1").To4(), net.ParseIP("10.42.0.2").To4()
+
+	// A SYN is clamped on its way through
+	require.NoError(t, fwd.ForwardPacket(buildSYN(src, dst, []byte{tcpOptMSS, 4, 0x05, 0xB4})))
+	frame := tunnel.GetData()
+	require.Len(t, frame, FrameHeaderSize+44)
+	assert.Equal(t, 1260, int(binary.BigEndian.Uint16(frame[FrameHeaderSize+42:])))
+
+	// A packet too big for the tunnel is answered with ICMP instead
+	packet := BuildIPv4Packet(src, dst, ProtoUDP, make([]byte, 1400))
+	packet[6] |= 0x40
+	packet[10], packet[11] = 0, 0
+	checksum := CalculateIPv4Checksum(packet[:20])
+	binary.BigEndian.PutUint16(packet[10:12], checksum)
+	require.NoError(t, fwd.ForwardPacket(packet))
+	assert.Len(t, tunnel.GetData(), len(frame), "oversized packet reached the tunnel")
+	reply := tun.GetWrittenPackets()
+	require.NotEmpty(t, reply)
+	assert.Equal(t, byte(icmpv4DestUnreachable), reply[20])
+	assert.Equal(t, uint64(1), fwd.Stats().DroppedTooBig)
+
+	// Tunnels that don't know their MTU are left alone
+	tunnel.maxWrite = 0
+	require.NoError(t, fwd.ForwardPacket(packet))
+	assert.Len(t, tunnel.GetData(), len(frame)+FrameHeaderSize+len(packet))
+}
+
+func TestForwarder_RelayedMTU(t *testing.T) {
+	src, dst := net.ParseIP("10.42.0.1").To4(), net.ParseIP("10.42.0.3").To4()
+	overhead := relayedHeaderSize(relayedHeader{origin: "alice", target: "bob"})
+
+	// The origin clamps for its tunnel to the relay peer
+	fwd, tunnelMgr, _ := newRelayTestForwarder("alice")
+	relayTunnel := &mtuTunnel{mockTunnel: newMockTunnel(), maxWrite: 1300 + FrameHeaderSize}
+	tunnelMgr.Add("relay", relayTunnel)
+	fwd.RelayPaths().Update("alice", map[string]map[string]int64{"relay": {"bob": 1_000}}, nil)
+
+	require.NoError(t, fwd.ForwardPacket(buildSYN(src, dst, []byte{tcpOptMSS, 4, 0x05, 0xB4})))
+	h, syn, err := decodeRelayed(relayedPayload(t, relayTunnel.mockTunnel))
+	require.NoError(t, err)
+	assert.Equal(t, 1300-overhead-40, int(binary.BigEndian.Uint16(syn[42:])))
+	assert.Zero(t, transportChecksum(src, dst, ProtoTCP, syn[20:]), "TCP checksum invalid")
+
+	// And the relay peer for its tunnel to the target
+	relay, relayMgr, _ := newRelayTestForwarder("relay")
+	relay.SetAllowRelay(true)
+	bobTunnel := &mtuTunnel{mockTunnel: newMockTunnel(), maxWrite: 1200 + FrameHeaderSize}
+	relayMgr.Add("bob", bobTunnel)
+	frame, err := encodeRelayed(h, syn)
+	require.NoError(t, err)
+	relay.handleRelayed("alice", frame[FrameHeaderSize:])
+	_, syn, err = decodeRelayed(relayedPayload(t, bobTunnel.mockTunnel))
+	require.NoError(t, err)
+	assert.Equal(t, 1200-overhead-40, int(binary.BigEndian.Uint16(syn[42:])))
+	assert.Zero(t, transportChecksum(src, dst, ProtoTCP, syn[20:]), "TCP checksum invalid")
+}
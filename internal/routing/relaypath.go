@@ -0,0 +1,191 @@
+package routing
+
+import (
+	"encoding/binary"
+	"errors"
+	"slices"
+	"sort"
+	"sync"
+)
+
+const (
+	// FrameTypeIP is a frame carrying an IP packet for the receiving peer.
+	FrameTypeIP = 0x01
+	// FrameTypeRelayed is a frame carrying an IP packet relayed by a peer.
+	// Payload: [1 byte TTL][1 byte origin len][origin][1 byte target len][target][IP packet]
+	FrameTypeRelayed = 0x02
+
+	// MaxRelayHops is the TTL of relayed frames: the number of relay peers
+	// a packet may cross before it is dropped.
+	MaxRelayHops = 3
+	// unknownHopCost is the cost in microseconds of a hop whose RTT has not
+	// been measured, such as over SSH tunnels.
+	unknownHopCost = 50_000
+	// maxRelayCandidates bounds the relay peers tried per target.
+	maxRelayCandidates = 3
+)
+
+var errBadRelayedFrame = errors.New("malformed relayed frame")
+
+// relayedHeader is the header of a FrameTypeRelayed payload.
+type relayedHeader struct {
+	ttl    uint8
+	origin string // Peer that sent the packet
+	target string // Peer the packet is for
+}
+
+// encodeRelayed builds a complete FrameTypeRelayed frame.
+func encodeRelayed(h relayedHeader, packet []byte) ([]byte, error) {
+	if h.origin == "" || h.target == "" || len(h.origin) > 255 || len(h.target) > 255 {
+		return nil, errBadRelayedFrame
+	}
+	payloadLen := relayedHeaderSize(h) + len(packet)
+	if payloadLen+1 > MaxPacketSize {
+		return nil, errBadRelayedFrame
+	}
+
+	frame := make([]byte, FrameHeaderSize+payloadLen)
+	binary.BigEndian.PutUint16(frame[0:2], uint16(payloadLen+1))
+	frame[2] = FrameTypeRelayed
+	b := frame[FrameHeaderSize:]
+	b[0] = h.ttl
+	b[1] = byte(len(h.origin))
+	n := 2 + copy(b[2:], h.origin)
+	b[n] = byte(len(h.target))
+	n++
+	n += copy(b[n:], h.target)
+	copy(b[n:], packet)
+	return frame, nil
+}
+
+// relayedHeaderSize returns the size of a relayed frame's header, after the
+// frame header.
+func relayedHeaderSize(h relayedHeader) int {
+	return 3 + len(h.origin) + len(h.target)
+}
+
+// decodeRelayed parses a FrameTypeRelayed payload, returning the header and
+// the IP packet.
+func decodeRelayed(payload []byte) (relayedHeader, []byte, error) {
+	var h relayedHeader
+	if len(payload) < 2 {
+		return h, nil, errBadRelayedFrame
+	}
+	h.ttl = payload[0]
+	n := int(payload[1])
+	rest := payload[2:]
+	if n == 0 || len(rest) < n+1 {
+		return h, nil, errBadRelayedFrame
+	}
+	h.origin = string(rest[:n])
+	rest = rest[n:]
+	n = int(rest[0])
+	rest = rest[1:]
+	if n == 0 || len(rest) < n {
+		return h, nil, errBadRelayedFrame
+	}
+	h.target = string(rest[:n])
+	return h, rest[n:], nil
+}
+
+// RelayPaths holds, for each peer, the relay peers that can forward traffic
+// to it when there is no direct tunnel, cheapest path first. Relay peers are
+// peers that opted in to relaying and report the peers they have tunnels to.
+type RelayPaths struct {
+	mu    sync.RWMutex
+	paths map[string][]string         // Target peer -> relay peers, cheapest first
+	reach map[string]map[string]int64 // Relay peer -> peers it has tunnels to
+}
+
+// NewRelayPaths creates an empty relay path table.
+func NewRelayPaths() *RelayPaths {
+	return &RelayPaths{paths: make(map[string][]string), reach: make(map[string]map[string]int64)}
+}
+
+// Update recomputes the relay paths. relays maps each relay peer to the
+// peers it has tunnels to and its RTT to them in microseconds (0 if not
+// measured). local holds this peer's RTTs to other peers. The cost of the
+// path through a relay is the sum of both RTTs.
+func (r *RelayPaths) Update(self string, relays map[string]map[string]int64, local map[string]int64) {
+	hopCost := func(rtt int64) int64 {
+		if rtt <= 0 {
+			return unknownHopCost
+		}
+		return rtt
+	}
+
+	type candidate struct {
+		relay string
+		cost  int64
+	}
+	byTarget := make(map[string][]candidate)
+	reachable := make(map[string]map[string]int64, len(relays))
+	for relay, reach := range relays {
+		if relay == self {
+			continue
+		}
+		reachable[relay] = reach
+		for target, rtt := range reach {
+			if target == self || target == relay {
+				continue
+			}
+			byTarget[target] = append(byTarget[target], candidate{
+				relay: relay,
+				cost:  hopCost(local[relay]) + hopCost(rtt),
+			})
+		}
+	}
+
+	paths := make(map[string][]string, len(byTarget))
+	for target, candidates := range byTarget {
+		sort.Slice(candidates, func(i, j int) bool {
+			if candidates[i].cost != candidates[j].cost {
+				return candidates[i].cost < candidates[j].cost
+			}
+			return candidates[i].relay < candidates[j].relay
+		})
+		for i := 0; i < len(candidates) && i < maxRelayCandidates; i++ {
+			paths[target] = append(paths[target], candidates[i].relay)
+		}
+	}
+
+	r.mu.Lock()
+	r.paths = paths
+	r.reach = reachable
+	r.mu.Unlock()
+}
+
+// Reaches reports whether a relay peer can be passing on packets from a
+// peer: it reports a tunnel to the peer, or to a relay peer that does,
+// through at most MaxRelayHops relay peers. Peers that report no tunnels
+// are not relay peers and reach no one.
+func (r *RelayPaths) Reaches(relay, peer string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	frontier := []string{relay}
+	seen := map[string]bool{relay: true}
+	for hop := 0; hop < MaxRelayHops && len(frontier) > 0; hop++ {
+		var next []string
+		for _, via := range frontier {
+			for target := range r.reach[via] {
+				if target == peer {
+					return true
+				}
+				if _, isRelay := r.reach[target]; isRelay && !seen[target] {
+					seen[target] = true
+					next = append(next, target)
+				}
+			}
+		}
+		frontier = next
+	}
+	return false
+}
+
+// Candidates returns the relay peers for a target, cheapest first.
+func (r *RelayPaths) Candidates(target string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Clone(r.paths[target])
+}
@@ -0,0 +1,92 @@
+package routing
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayedFrame_RoundTrip(t *testing.T) {
+	packet := BuildIPv4Packet(net.ParseIP("10.42.0.1").To4(), net.ParseIP("10.42.0.3").To4(), ProtoUDP, []byte("hello"))
+	h := relayedHeader{ttl: 2, origin: "alice", target: "bob"}
+
+	frame, err := encodeRelayed(h, packet)
+	require.NoError(t, err)
+	assert.Equal(t, byte(FrameTypeRelayed), frame[2])
+	assert.Equal(t, len(frame)-2, int(frame[0])<<8|int(frame[1]))
+
+	got, gotPacket, err := decodeRelayed(frame[FrameHeaderSize:])
+	require.NoError(t, err)
+	assert.Equal(t, h, got)
+	assert.Equal(t, packet, gotPacket)
+}
+
+func TestRelayedFrame_Malformed(t *testing.T) {
+	_, err := encodeRelayed(relayedHeader{ttl: 1, target: "bob"}, nil)
+	assert.Error(t, err, "origin required")
+
+	for _, payload := range [][]byte{
+		nil,
+		{1},
+		{1, 0},              // empty origin
+		{1, 5, 'a', 'b'},    // truncated origin
+		{1, 1, 'a'},         // missing target
+		{1, 1, 'a', 0},      // empty target
+		{1, 1, 'a', 3, 'b'}, // truncated target
+	} {
+		_, _, err := decodeRelayed(payload)
+		assert.Error(t, err, "payload %v", payload)
+	}
+}
+
+func TestRelayPaths_CheapestFirst(t *testing.T) {
+	r := NewRelayPaths()
+	r.Update("alice", map[string]map[string]int64{
+		"relay-far":   {"bob": 80_000, "carol": 1_000},
+		"relay-near":  {"bob": 5_000, "alice": 1_000},
+		"relay-ssh":   {"bob": 0}, // Not measured
+		"alice":       {"bob": 1}, // Ourselves
+		"relay-other": {"relay-other": 1},
+	}, map[string]int64{
+		"relay-far":  1_000,
+		"relay-near": 2_000,
+		"relay-ssh":  1_000,
+	})
+
+	// 7ms, 51ms (unknown hop), 81ms
+	assert.Equal(t, []string{"relay-near", "relay-ssh", "relay-far"}, r.Candidates("bob"))
+	assert.Equal(t, []string{"relay-far"}, r.Candidates("carol"))
+	assert.Empty(t, r.Candidates("alice"), "no paths to ourselves")
+	assert.Empty(t, r.Candidates("relay-other"))
+
+	r.Update("alice", nil, nil)
+	assert.Empty(t, r.Candidates("bob"))
+}
+
+func TestRelayPaths_MaxCandidates(t *testing.T) {
+	r := NewRelayPaths()
+	relays := make(map[string]map[string]int64)
+	for _, name := range []string{"r1", "r2", "r3", "r4", "r5"} {
+		relays[name] = map[string]int64{"bob": 1_000}
+	}
+	r.Update("alice", relays, nil)
+	assert.Equal(t, []string{"r1", "r2", "r3"}, r.Candidates("bob"))
+}
+
+func TestRelayPaths_Reaches(t *testing.T) {
+	r := NewRelayPaths()
+	r.Update("bob", map[string]map[string]int64{
+		"r1": {"alice": 0, "r2": 0},
+		"r2": {"r1": 0, "r3": 0},
+		"r3": {"r2": 0, "bob": 0},
+		"r4": {"r3": 0},
+	}, nil)
+
+	assert.True(t, r.Reaches("r1", "alice"))
+	assert.True(t, r.Reaches("r3", "alice"), "through r2 and r1")
+	assert.False(t, r.Reaches("r4", "alice"), "more than MaxRelayHops relay peers")
+	assert.False(t, r.Reaches("carol", "alice"), "not a relay peer")
+	assert.False(t, r.Reaches("r1", "carol"))
+}
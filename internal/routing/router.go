@@ -5,119 +5,179 @@ import (
 	"encoding/binary"
 	"fmt"
 	"net"
+	"sort"
 	"sync"
 	"sync/atomic"
 )
 
 // Protocol constants for IP packets.
 const (
-	ProtoICMP = 1
-	ProtoTCP  = 6
-	ProtoUDP  = 17
+	ProtoICMP   = 1
+	ProtoTCP    = 6
+	ProtoUDP    = 17
+	ProtoICMPv6 = 58
 )
 
+// IPv6 extension headers skipped to find the upper-layer header.
+const (
+	ipv6HopByHop = 0
+	ipv6Routing  = 43
+	ipv6Fragment = 44
+	ipv6AH       = 51
+	ipv6DestOpts = 60
+)
+
+// IPv6HeaderLen is the length of the fixed IPv6 header.
+const IPv6HeaderLen = 40
+
 // ipv4Key is a fixed-size key for IPv4 addresses (avoids string allocation).
 type ipv4Key [4]byte
 
-// Router manages routes from mesh IPs to peer IDs.
-// Uses copy-on-write for lock-free reads in the hot path.
-type Router struct {
-	routes atomic.Pointer[map[ipv4Key]string] // Lock-free reads
-	mu     sync.Mutex                         // Serializes writes only
+// ipv6Key is a fixed-size key for IPv6 addresses.
+type ipv6Key [16]byte
+
+// routeTable maps mesh IPs of either family to peer IDs.
+type routeTable struct {
+	v4 map[ipv4Key]string
+	v6 map[ipv6Key]string
 }
 
-// NewRouter creates a new Router.
-func NewRouter() *Router {
-	r := &Router{}
-	routes := make(map[ipv4Key]string)
-	r.routes.Store(&routes)
-	return r
+// clone returns a copy of the table to modify, with room for n more routes.
+func (t *routeTable) clone(n int) *routeTable {
+	c := &routeTable{
+		v4: make(map[ipv4Key]string, len(t.v4)+n),
+		v6: make(map[ipv6Key]string, len(t.v6)+n),
+	}
+	for k, v := range t.v4 {
+		c.v4[k] = v
+	}
+	for k, v := range t.v6 {
+		c.v6[k] = v
+	}
+	return c
 }
 
-// parseIPv4Key converts an IP string to a binary key.
-func parseIPv4Key(ip string) (ipv4Key, bool) {
+// set adds a route, returning false if the IP is invalid.
+func (t *routeTable) set(ip string, peerID string) bool {
 	parsed := net.ParseIP(ip)
 	if parsed == nil {
-		return ipv4Key{}, false
+		return false
 	}
-	v4 := parsed.To4()
-	if v4 == nil {
-		return ipv4Key{}, false
+	if v4 := parsed.To4(); v4 != nil {
+		t.v4[ipv4Key(v4)] = peerID
+	} else {
+		t.v6[ipv6Key(parsed)] = peerID
 	}
-	var key ipv4Key
-	copy(key[:], v4)
-	return key, true
+	return true
 }
 
-// netIPToKey converts a net.IP to a binary key.
-func netIPToKey(ip net.IP) ipv4Key {
-	var key ipv4Key
-	v4 := ip.To4()
-	if v4 != nil {
-		copy(key[:], v4)
+// lookup returns the peer ID for a mesh IP.
+func (t *routeTable) lookup(ip net.IP) (string, bool) {
+	if v4 := ip.To4(); v4 != nil {
+		peerID, ok := t.v4[ipv4Key(v4)]
+		return peerID, ok
+	}
+	if len(ip) != net.IPv6len {
+		return "", false
 	}
-	return key
+	peerID, ok := t.v6[ipv6Key(ip)]
+	return peerID, ok
+}
+
+// subnetRoute is a prefix advertised by a peer into the mesh.
+type subnetRoute struct {
+	network uint32
+	mask    uint32
+	bits    int
+	prefix  string
+	peerID  string
+}
+
+// Router manages routes from mesh IPs (IPv4 and IPv6) to peer IDs, and from
+// subnet prefixes advertised by peers. Uses copy-on-write for lock-free reads
+// in the hot path.
+type Router struct {
+	routes  atomic.Pointer[routeTable]    // Lock-free reads
+	subnets atomic.Pointer[[]subnetRoute] // Sorted longest prefix first
+	mu      sync.Mutex                    // Serializes writes only
+}
+
+// NewRouter creates a new Router.
+func NewRouter() *Router {
+	r := &Router{}
+	r.routes.Store(&routeTable{v4: make(map[ipv4Key]string), v6: make(map[ipv6Key]string)})
+	subnets := []subnetRoute{}
+	r.subnets.Store(&subnets)
+	return r
 }
 
 // AddRoute adds a route for an IP to a peer.
-// Uses copy-on-write: creates a new map with the addition.
+// Uses copy-on-write: creates a new table with the addition.
 func (r *Router) AddRoute(ip string, peerID string) {
-	key, ok := parseIPv4Key(ip)
-	if !ok {
+	if net.ParseIP(ip) == nil {
 		return // Invalid IP, silently ignore
 	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	// Copy-on-write: create new map with the addition
-	oldRoutes := r.routes.Load()
-	newRoutes := make(map[ipv4Key]string, len(*oldRoutes)+1)
-	for k, v := range *oldRoutes {
-		newRoutes[k] = v
-	}
-	newRoutes[key] = peerID
-	r.routes.Store(&newRoutes)
+	// Copy-on-write: create new table with the addition
+	newRoutes := r.routes.Load().clone(1)
+	newRoutes.set(ip, peerID)
+	r.routes.Store(newRoutes)
 }
 
 // RemoveRoute removes a route for an IP.
-// Uses copy-on-write: creates a new map without the route.
+// Uses copy-on-write: creates a new table without the route.
 func (r *Router) RemoveRoute(ip string) {
-	key, ok := parseIPv4Key(ip)
-	if !ok {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
 		return
 	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	oldRoutes := r.routes.Load()
-	if _, exists := (*oldRoutes)[key]; !exists {
+	if _, exists := oldRoutes.lookup(parsed); !exists {
 		return // Nothing to remove
 	}
 
-	// Copy-on-write: create new map without the route
-	newRoutes := make(map[ipv4Key]string, len(*oldRoutes))
-	for k, v := range *oldRoutes {
-		if k != key {
-			newRoutes[k] = v
-		}
+	// Copy-on-write: create new table without the route
+	newRoutes := oldRoutes.clone(0)
+	if v4 := parsed.To4(); v4 != nil {
+		delete(newRoutes.v4, ipv4Key(v4))
+	} else {
+		delete(newRoutes.v6, ipv6Key(parsed))
 	}
-	r.routes.Store(&newRoutes)
+	r.routes.Store(newRoutes)
 }
 
-// Lookup finds the peer ID for a destination IP.
+// Lookup finds the peer ID for a destination IP. Mesh IPs match first, then
+// the longest matching subnet prefix (IPv4 only).
 // Lock-free: uses atomic load for maximum throughput in hot path.
 func (r *Router) Lookup(ip net.IP) (string, bool) {
-	key := netIPToKey(ip)
-	routes := r.routes.Load()
-	peerID, ok := (*routes)[key]
-	return peerID, ok
+	if peerID, ok := r.routes.Load().lookup(ip); ok {
+		return peerID, true
+	}
+
+	subnets := *r.subnets.Load()
+	v4 := ip.To4()
+	if len(subnets) == 0 || v4 == nil {
+		return "", false
+	}
+	addr := binary.BigEndian.Uint32(v4)
+	for i := range subnets {
+		if addr&subnets[i].mask == subnets[i].network {
+			return subnets[i].peerID, true
+		}
+	}
+	return "", false
 }
 
 // Count returns the number of routes.
 // Lock-free: uses atomic load.
 func (r *Router) Count() int {
 	routes := r.routes.Load()
-	return len(*routes)
+	return len(routes.v4) + len(routes.v6)
 }
 
 // UpdateRoutes replaces all routes with a new set.
@@ -126,28 +186,70 @@ func (r *Router) UpdateRoutes(routes map[string]string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	newRoutes := make(map[ipv4Key]string, len(routes))
+	newRoutes := &routeTable{v4: make(map[ipv4Key]string, len(routes)), v6: make(map[ipv6Key]string)}
 	for ip, peerID := range routes {
-		if key, ok := parseIPv4Key(ip); ok {
-			newRoutes[key] = peerID
-		}
+		newRoutes.set(ip, peerID)
 	}
-	r.routes.Store(&newRoutes)
+	r.routes.Store(newRoutes)
 }
 
 // ListRoutes returns a copy of all routes.
 // Lock-free: uses atomic load.
 func (r *Router) ListRoutes() map[string]string {
 	routes := r.routes.Load()
-	result := make(map[string]string, len(*routes))
-	for key, peerID := range *routes {
-		ip := net.IP(key[:]).String()
-		result[ip] = peerID
+	result := make(map[string]string, len(routes.v4)+len(routes.v6))
+	for key, peerID := range routes.v4 {
+		result[net.IP(key[:]).String()] = peerID
+	}
+	for key, peerID := range routes.v6 {
+		result[net.IP(key[:]).String()] = peerID
+	}
+	return result
+}
+
+// UpdateSubnetRoutes replaces all subnet routes with a new set mapping IPv4
+// prefixes in CIDR notation to peer IDs. Invalid prefixes are ignored.
+func (r *Router) UpdateSubnetRoutes(routes map[string]string) {
+	subnets := make([]subnetRoute, 0, len(routes))
+	for prefix, peerID := range routes {
+		_, ipNet, err := net.ParseCIDR(prefix)
+		if err != nil || ipNet.IP.To4() == nil {
+			continue
+		}
+		bits, _ := ipNet.Mask.Size()
+		mask := binary.BigEndian.Uint32(net.IP(ipNet.Mask).To4())
+		subnets = append(subnets, subnetRoute{
+			network: binary.BigEndian.Uint32(ipNet.IP.To4()),
+			mask:    mask,
+			bits:    bits,
+			prefix:  ipNet.String(),
+			peerID:  peerID,
+		})
+	}
+	sort.Slice(subnets, func(i, j int) bool {
+		if subnets[i].bits != subnets[j].bits {
+			return subnets[i].bits > subnets[j].bits
+		}
+		return subnets[i].prefix < subnets[j].prefix
+	})
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subnets.Store(&subnets)
+}
+
+// ListSubnetRoutes returns a copy of all subnet routes, keyed by prefix.
+// Lock-free: uses atomic load.
+func (r *Router) ListSubnetRoutes() map[string]string {
+	subnets := *r.subnets.Load()
+	result := make(map[string]string, len(subnets))
+	for _, route := range subnets {
+		result[route.prefix] = route.peerID
 	}
 	return result
 }
 
-// PacketInfo contains parsed information from an IPv4 packet.
+// PacketInfo contains parsed information from an IPv4 or IPv6 packet.
 type PacketInfo struct {
 	SrcIP    net.IP
 	DstIP    net.IP
@@ -231,6 +333,125 @@ func ParseIPv4Packet(packet []byte) (*PacketInfo, error) {
 	return info, nil
 }
 
+// BuildIPv6Packet constructs a minimal IPv6 packet without extension headers.
+func BuildIPv6Packet(src, dst net.IP, proto uint8, payload []byte) []byte {
+	packet := make([]byte, IPv6HeaderLen+len(payload))
+
+	// Version (6), traffic class and flow label
+	packet[0] = 0x60
+
+	// Payload Length
+	binary.BigEndian.PutUint16(packet[4:6], uint16(len(payload)))
+
+	// Next Header and Hop Limit
+	packet[6] = proto
+	packet[7] = 64
+
+	// Source and Destination IP
+	copy(packet[8:24], src.To16())
+	copy(packet[24:40], dst.To16())
+
+	// Payload
+	copy(packet[IPv6HeaderLen:], payload)
+
+	return packet
+}
+
+// ParseIPv6Packet parses an IPv6 packet and returns its components. Protocol
+// and Payload are those of the upper layer, after any extension headers; if
+// the extension headers cannot be walked (or the packet is a non-initial
+// fragment), they are the fixed header's Next Header and everything after it.
+func ParseIPv6Packet(packet []byte) (*PacketInfo, error) {
+	if len(packet) < IPv6HeaderLen {
+		return nil, fmt.Errorf("packet too short: %d bytes", len(packet))
+	}
+
+	version := packet[0] >> 4
+	if version != 6 {
+		return nil, fmt.Errorf("not an IPv6 packet: version %d", version)
+	}
+
+	info := &PacketInfo{
+		SrcIP:    net.IP(packet[8:24]),
+		DstIP:    net.IP(packet[24:40]),
+		Protocol: packet[6],
+	}
+
+	offset := IPv6HeaderLen
+	if protocol, transport, ok := TransportHeader(packet); ok {
+		info.Protocol, offset = protocol, transport
+	}
+	if len(packet) > offset {
+		info.Payload = packet[offset:]
+	}
+
+	return info, nil
+}
+
+// ParsePacket parses an IPv4 or IPv6 packet, according to its version.
+func ParsePacket(packet []byte) (*PacketInfo, error) {
+	if len(packet) > 0 && packet[0]>>4 == 6 {
+		return ParseIPv6Packet(packet)
+	}
+	return ParseIPv4Packet(packet)
+}
+
+// TransportHeader returns the upper-layer protocol of an IPv4 or IPv6 packet
+// and the offset of its header, skipping IPv6 extension headers. ok is false
+// for malformed packets and for fragments other than the first, which carry
+// no upper-layer header.
+func TransportHeader(packet []byte) (protocol uint8, offset int, ok bool) {
+	if len(packet) == 0 {
+		return 0, 0, false
+	}
+
+	switch packet[0] >> 4 {
+	case 4:
+		ihl := int(packet[0]&0x0F) * 4
+		if len(packet) < 20 || ihl < 20 || ihl > len(packet) {
+			return 0, 0, false
+		}
+		// Fragment offset is non-zero for all but the first fragment
+		if binary.BigEndian.Uint16(packet[6:8])&0x1FFF != 0 {
+			return 0, 0, false
+		}
+		return packet[9], ihl, true
+
+	case 6:
+		if len(packet) < IPv6HeaderLen {
+			return 0, 0, false
+		}
+		next, offset := packet[6], IPv6HeaderLen
+		for {
+			switch next {
+			case ipv6HopByHop, ipv6Routing, ipv6DestOpts, ipv6Fragment, ipv6AH:
+				if len(packet) < offset+8 {
+					return 0, 0, false
+				}
+			default:
+				if offset > len(packet) {
+					return 0, 0, false
+				}
+				return next, offset, true
+			}
+
+			switch next {
+			case ipv6Fragment:
+				if binary.BigEndian.Uint16(packet[offset+2:offset+4])&0xFFF8 != 0 {
+					return 0, 0, false
+				}
+				next, offset = packet[offset], offset+8
+			case ipv6AH:
+				next, offset = packet[offset], offset+(int(packet[offset+1])+2)*4
+			default:
+				next, offset = packet[offset], offset+(int(packet[offset+1])+1)*8
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
 // CalculateIPv4Checksum calculates the IPv4 header checksum.
 func CalculateIPv4Checksum(header []byte) uint16 {
 	var sum uint32
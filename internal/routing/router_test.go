@@ -80,6 +80,50 @@ func TestRouter_ListRoutes(t *testing.T) {
 	assert.Equal(t, "peer2", routes["10.42.0.3"])
 }
 
+func TestRouter_SubnetRoutes(t *testing.T) {
+	r := NewRouter()
+	r.AddRoute("10.42.0.2", "peer1")
+	r.UpdateSubnetRoutes(map[string]string{
+		"192.168.0.0/16":  "office",
+		"192.168.10.0/24": "lab",
+		"10.42.0.0/24":    "shadowed",
+		"not-a-prefix":    "ignored",
+	})
+
+	// Longest prefix wins
+	peer, ok := r.Lookup(net.ParseIP("192.168.10.20"))
+	require.True(t, ok)
+	assert.Equal(t, "lab", peer)
+
+	peer, ok = r.Lookup(net.ParseIP("192.168.11.20"))
+	require.True(t, ok)
+	assert.Equal(t, "office", peer)
+
+	// Mesh IPs match before any prefix
+	peer, ok = r.Lookup(net.ParseIP("10.42.0.2"))
+	require.True(t, ok)
+	assert.Equal(t, "peer1", peer)
+
+	_, ok = r.Lookup(net.ParseIP("172.16.0.1"))
+	assert.False(t, ok)
+
+	assert.Equal(t, map[string]string{
+		"192.168.0.0/16":  "office",
+		"192.168.10.0/24": "lab",
+		"10.42.0.0/24":    "shadowed",
+	}, r.ListSubnetRoutes())
+
+	// Mesh routes and subnet routes are replaced independently
+	r.UpdateRoutes(map[string]string{"10.42.0.3": "peer2"})
+	peer, ok = r.Lookup(net.ParseIP("192.168.10.20"))
+	require.True(t, ok)
+	assert.Equal(t, "lab", peer)
+
+	r.UpdateSubnetRoutes(nil)
+	_, ok = r.Lookup(net.ParseIP("192.168.10.20"))
+	assert.False(t, ok)
+}
+
 func TestBuildIPv4Packet(t *testing.T) {
 	src := net.ParseIP("10.42.0.1").To4()
 	dst := net.ParseIP("10.42.0.2").To4()
@@ -262,3 +306,82 @@ func BenchmarkZeroCopyBuffer(b *testing.B) {
 		_ = zcBuf.Frame(len(payload))
 	}
 }
+
+func TestRouter_IPv6Routes(t *testing.T) {
+	r := NewRouter()
+	r.AddRoute("10.42.0.5", "peer1")
+	r.AddRoute("fd42:6d65:7368::5", "peer1")
+	r.AddRoute("fd42:6d65:7368::6", "peer2")
+
+	peerID, ok := r.Lookup(net.ParseIP("fd42:6d65:7368::5"))
+	require.True(t, ok)
+	assert.Equal(t, "peer1", peerID)
+	peerID, ok = r.Lookup(net.ParseIP("10.42.0.5"))
+	require.True(t, ok)
+	assert.Equal(t, "peer1", peerID)
+	assert.Equal(t, 3, r.Count())
+
+	r.RemoveRoute("fd42:6d65:7368::6")
+	_, ok = r.Lookup(net.ParseIP("fd42:6d65:7368::6"))
+	assert.False(t, ok)
+
+	r.UpdateRoutes(map[string]string{"10.42.0.7": "peer3", "fd42:6d65:7368::7": "peer3"})
+	assert.Equal(t, map[string]string{"10.42.0.7": "peer3", "fd42:6d65:7368::7": "peer3"}, r.ListRoutes())
+
+	// IPv4-mapped and IPv4 addresses share the IPv4 table
+	peerID, ok = r.Lookup(net.ParseIP("::ffff:10.42.0.7"))
+	require.True(t, ok)
+	assert.Equal(t, "peer3", peerID)
+}
+
+func TestBuildAndParseIPv6Packet(t *testing.T) {
+	src := net.ParseIP("fd42:6d65:7368::1")
+	dst := net.ParseIP("fd42:6d65:7368::2")
+	payload := []byte("test payload")
+
+	packet := BuildIPv6Packet(src, dst, ProtoUDP, payload)
+	require.Len(t, packet, IPv6HeaderLen+len(payload))
+
+	info, err := ParsePacket(packet)
+	require.NoError(t, err)
+	assert.True(t, src.Equal(info.SrcIP))
+	assert.True(t, dst.Equal(info.DstIP))
+	assert.Equal(t, uint8(ProtoUDP), info.Protocol)
+	assert.Equal(t, payload, info.Payload)
+
+	_, err = ParseIPv6Packet(packet[:20])
+	assert.Error(t, err)
+	_, err = ParseIPv6Packet(BuildIPv4Packet(net.IPv4(10, 42, 0, 1), net.IPv4(10, 42, 0, 2), ProtoUDP, make([]byte, 40)))
+	assert.Error(t, err)
+}
+
+func TestTransportHeader_IPv6ExtensionHeaders(t *testing.T) {
+	src := net.ParseIP("fd42:6d65:7368::1")
+	dst := net.ParseIP("fd42:6d65:7368::2")
+
+	// Hop-by-hop options (8 bytes) then a first fragment (8 bytes) then TCP
+	ext := make([]byte, 16)
+	ext[0] = ipv6Fragment // Next header after hop-by-hop
+	ext[8] = ProtoTCP     // Next header after the fragment header
+	packet := BuildIPv6Packet(src, dst, ipv6HopByHop, append(ext, make([]byte, 20)...))
+
+	protocol, offset, ok := TransportHeader(packet)
+	require.True(t, ok)
+	assert.Equal(t, uint8(ProtoTCP), protocol)
+	assert.Equal(t, IPv6HeaderLen+16, offset)
+
+	// Later fragments carry no transport header
+	packet[IPv6HeaderLen+8+3] = 0x08 // Fragment offset 1
+	_, _, ok = TransportHeader(packet)
+	assert.False(t, ok)
+
+	// Truncated extension header
+	_, _, ok = TransportHeader(packet[:IPv6HeaderLen+4])
+	assert.False(t, ok)
+
+	// IPv4 uses the IHL
+	protocol, offset, ok = TransportHeader(BuildIPv4Packet(net.IPv4(10, 42, 0, 1), net.IPv4(10, 42, 0, 2), ProtoUDP, make([]byte, 8)))
+	require.True(t, ok)
+	assert.Equal(t, uint8(ProtoUDP), protocol)
+	assert.Equal(t, 20, offset)
+}
@@ -0,0 +1,69 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// AcceptQueue holds incoming connections until a listener's Accept takes
+// them.
+type AcceptQueue struct {
+	ch     chan Connection
+	mu     sync.Mutex // Protects channel operations to prevent send-on-closed-channel panic
+	closed bool
+}
+
+// NewAcceptQueue creates a queue holding up to size connections.
+func NewAcceptQueue(size int) *AcceptQueue {
+	return &AcceptQueue{ch: make(chan Connection, size)}
+}
+
+// TrySend queues a connection. Returns false if the queue is closed or
+// full, in which case the caller still owns the connection.
+func (q *AcceptQueue) TrySend(conn Connection) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return false
+	}
+	select {
+	case q.ch <- conn:
+		return true
+	default:
+		return false
+	}
+}
+
+// Accept waits for and returns the next connection.
+func (q *AcceptQueue) Accept(ctx context.Context) (Connection, error) {
+	select {
+	case conn, ok := <-q.ch:
+		if !ok {
+			return nil, fmt.Errorf("listener closed")
+		}
+		return conn, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Closed reports whether the queue is closed.
+func (q *AcceptQueue) Closed() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.closed
+}
+
+// Close closes the queue, so Accept fails once the connections still
+// queued are taken. Returns false if it was already closed.
+func (q *AcceptQueue) Close() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return false
+	}
+	q.closed = true
+	close(q.ch)
+	return true
+}
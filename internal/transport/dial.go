@@ -0,0 +1,89 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"strconv"
+	"time"
+)
+
+const (
+	// DialStagger is the delay between connection attempts to a peer's
+	// successive addresses.
+	DialStagger = 250 * time.Millisecond
+
+	// MaxDialAddrs limits the addresses tried per dial.
+	MaxDialAddrs = 6
+)
+
+// DialAddrs returns the addresses to try for a peer on port, private ones
+// first. IPv4-mapped IPv6 addresses are unmapped.
+func DialAddrs(peer *PeerInfo, port int) []netip.AddrPort {
+	var addrs []netip.AddrPort
+	seen := make(map[netip.AddrPort]bool)
+	for _, ips := range [][]string{peer.PrivateIPs, peer.PublicIPs} {
+		for _, ip := range ips {
+			addr, err := netip.ParseAddrPort(net.JoinHostPort(ip, strconv.Itoa(port)))
+			if err != nil {
+				continue
+			}
+			addr = netip.AddrPortFrom(addr.Addr().Unmap(), addr.Port())
+			if seen[addr] || len(addrs) == MaxDialAddrs {
+				continue
+			}
+			seen[addr] = true
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// DialFirst connects to the first of addrs to answer. Attempts to
+// successive addresses start DialStagger apart, and the first to return a
+// connection wins; the others are cancelled, or closed if they complete
+// anyway. Returns the errors of all attempts if none succeeds.
+func DialFirst(ctx context.Context, addrs []netip.AddrPort, dial func(context.Context, netip.AddrPort) (Connection, error)) (Connection, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		conn Connection
+		err  error
+	}
+	results := make(chan result, len(addrs))
+	for i, addr := range addrs {
+		go func() {
+			select {
+			case <-time.After(time.Duration(i) * DialStagger):
+			case <-ctx.Done():
+				results <- result{err: ctx.Err()}
+				return
+			}
+			conn, err := dial(ctx, addr)
+			results <- result{conn: conn, err: err}
+		}()
+	}
+
+	var (
+		winner Connection
+		errs   []error
+	)
+	for range addrs {
+		r := <-results
+		switch {
+		case r.err != nil:
+			errs = append(errs, r.err)
+		case winner == nil:
+			winner = r.conn
+			cancel()
+		default:
+			_ = r.conn.Close()
+		}
+	}
+	if winner == nil {
+		return nil, errors.Join(errs...)
+	}
+	return winner, nil
+}
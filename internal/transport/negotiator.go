@@ -2,6 +2,7 @@ package transport
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -129,6 +130,10 @@ func (n *Negotiator) Negotiate(ctx context.Context, peerInfo *PeerInfo, dialOpts
 				Str("transport", string(transportType)).
 				Int("attempt", attempt+1).
 				Msg("connection attempt failed")
+
+			if errors.Is(err, ErrPeerUnsupported) {
+				break
+			}
 		}
 
 		if !n.config.EnableFallback {
@@ -0,0 +1,133 @@
+package quic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/tunnelmesh/tunnelmesh/internal/transport"
+)
+
+const (
+	// maxWriteSizeTTL is how long a datagram size limit learned from a
+	// failed write is reported, after which the path is assumed to allow
+	// more again.
+	maxWriteSizeTTL = time.Minute
+
+	// datagramOverhead is the most a short header packet adds to the data
+	// of a DATAGRAM frame: header byte, connection ID, packet number, AEAD
+	// tag and frame header. quic-go's size limit leaves it out once path
+	// MTU discovery has raised the packet size, dropping datagrams that
+	// pass the limit but don't fit.
+	datagramOverhead = 1 + 20 + 4 + 16 + 3
+)
+
+// Connection is a QUIC connection to a peer carrying tunnel packets in
+// unreliable DATAGRAM frames. Each Write sends one datagram.
+type Connection struct {
+	conn     *quic.Conn
+	peerName string // Verified from the peer's certificate
+
+	readMu  sync.Mutex
+	readBuf bytes.Buffer
+
+	mu          sync.Mutex
+	maxWrite    int       // Largest datagram that fit when a write was too large
+	maxWriteSet time.Time // When maxWrite was learned
+}
+
+func newConnection(conn *quic.Conn, peerName string) *Connection {
+	return &Connection{conn: conn, peerName: peerName}
+}
+
+// Read reads data from the connection. Each datagram received can be read
+// in one or more calls.
+func (c *Connection) Read(p []byte) (int, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+	if c.readBuf.Len() == 0 {
+		data, err := c.conn.ReceiveDatagram(context.Background())
+		if err != nil {
+			var appErr *quic.ApplicationError
+			if errors.As(err, &appErr) && appErr.ErrorCode == 0 {
+				return 0, io.EOF
+			}
+			return 0, err
+		}
+		c.readBuf.Write(data)
+	}
+	return c.readBuf.Read(p)
+}
+
+// Write sends p as one datagram. Datagrams larger than the path allows
+// fail with an error wrapping EMSGSIZE, and the size that fits is reported
+// by MaxWriteSize.
+func (c *Connection) Write(p []byte) (int, error) {
+	err := c.conn.SendDatagram(p)
+	var tooLarge *quic.DatagramTooLargeError
+	if errors.As(err, &tooLarge) {
+		c.mu.Lock()
+		c.maxWrite = max(int(tooLarge.MaxDatagramPayloadSize)-datagramOverhead, 0)
+		c.maxWriteSet = time.Now()
+		c.mu.Unlock()
+		return 0, fmt.Errorf("datagram of %d bytes: %w", len(p), syscall.EMSGSIZE)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// MaxWriteSize returns the largest datagram the path allowed when a write
+// was last too large, or 0 if none was recently.
+func (c *Connection) MaxWriteSize() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.maxWriteSet) > maxWriteSizeTTL {
+		return 0
+	}
+	return c.maxWrite
+}
+
+// Close closes the connection, telling the peer.
+func (c *Connection) Close() error {
+	return c.conn.CloseWithError(0, "")
+}
+
+// PeerName returns the peer name, verified from its certificate.
+func (c *Connection) PeerName() string {
+	return c.peerName
+}
+
+// Type returns the transport type.
+func (c *Connection) Type() transport.TransportType {
+	return transport.TransportQUIC
+}
+
+// LocalAddr returns the local address of the transport's socket.
+func (c *Connection) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// RemoteAddr returns the current address of the peer, which changes when
+// the connection migrates.
+func (c *Connection) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// IsHealthy returns true if the connection is neither closed nor idle.
+func (c *Connection) IsHealthy() bool {
+	return c.conn.Context().Err() == nil
+}
+
+// RTT returns the smoothed round-trip time.
+func (c *Connection) RTT() time.Duration {
+	return c.conn.ConnectionStats().SmoothedRTT
+}
@@ -0,0 +1,482 @@
+package quic
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/netip"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/tunnelmesh/tunnelmesh/internal/transport"
+)
+
+// testCA issues mesh certificates for tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key}
+}
+
+// peerCert issues a certificate like the coordinator's peer certificates.
+func (ca *testCA) peerCert(t *testing.T, name string) *tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name + ".tunnelmesh"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{name + ".tunnelmesh"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// verify checks certificates like peer.TLSManager.VerifyConnection.
+func (ca *testCA) verify(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("no certificate presented")
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{DNSName: cs.ServerName, Roots: roots})
+	return err
+}
+
+func newTestTransport(t *testing.T, ca *testCA, certCA *testCA, name string) *Transport {
+	t.Helper()
+	cert := certCA.peerCert(t, name)
+	tr, err := New(Config{
+		Certificate:      func() (*tls.Certificate, error) { return cert, nil },
+		VerifyConnection: ca.verify,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = tr.Close() })
+	return tr
+}
+
+func peerInfo(name string, tr *Transport) *transport.PeerInfo {
+	return &transport.PeerInfo{
+		Name:       name,
+		PrivateIPs: []string{"127.0.0.1"},
+		QUICPort:   tr.LocalAddr().(*net.UDPAddr).Port,
+	}
+}
+
+func TestDialAndAccept(t *testing.T) {
+	ca := newTestCA(t)
+	alice := newTestTransport(t, ca, ca, "alice")
+	bob := newTestTransport(t, ca, ca, "bob")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	listener, err := bob.Listen(ctx, transport.ListenOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := alice.Dial(ctx, transport.DialOptions{PeerName: "bob", PeerInfo: peerInfo("bob", bob)})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	if conn.PeerName() != "bob" || conn.Type() != transport.TransportQUIC || !conn.IsHealthy() {
+		t.Errorf("unexpected connection %s %s healthy=%v", conn.PeerName(), conn.Type(), conn.IsHealthy())
+	}
+
+	accepted, err := listener.Accept(ctx)
+	if err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	if accepted.PeerName() != "alice" {
+		t.Errorf("accepted peer = %q, want alice", accepted.PeerName())
+	}
+
+	// Datagrams in both directions
+	buf := make([]byte, 2048)
+	for i := 0; i < 20; i++ {
+		msg := bytes.Repeat([]byte{byte(i)}, 1000+i)
+		if _, err := conn.Write(msg); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		n, err := accepted.Read(buf)
+		if err != nil || !bytes.Equal(buf[:n], msg) {
+			t.Fatalf("read %d: %v", i, err)
+		}
+		if _, err := accepted.Write(msg[:10]); err != nil {
+			t.Fatalf("write back: %v", err)
+		}
+		if n, err = conn.Read(buf); err != nil || !bytes.Equal(buf[:n], msg[:10]) {
+			t.Fatalf("read back %d: %v", i, err)
+		}
+	}
+
+	// Closing tells the other side
+	_ = conn.Close()
+	done := make(chan error, 1)
+	go func() {
+		_, err := accepted.Read(buf)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, io.EOF) {
+			t.Errorf("read after close = %v, want EOF", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("peer close not noticed")
+	}
+	if accepted.IsHealthy() {
+		t.Error("closed connection still healthy")
+	}
+}
+
+// TestWriteTooLarge writes a datagram larger than the path allows, which
+// must fail with EMSGSIZE and report the size that fits.
+func TestWriteTooLarge(t *testing.T) {
+	ca := newTestCA(t)
+	alice := newTestTransport(t, ca, ca, "alice")
+	bob := newTestTransport(t, ca, ca, "bob")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	listener, err := bob.Listen(ctx, transport.ListenOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := alice.Dial(ctx, transport.DialOptions{PeerName: "bob", PeerInfo: peerInfo("bob", bob)})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	accepted, err := listener.Accept(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qc := conn.(*Connection)
+	if n := qc.MaxWriteSize(); n != 0 {
+		t.Errorf("MaxWriteSize before a failed write = %d, want 0", n)
+	}
+	if _, err := conn.Write(make([]byte, 65000)); !errors.Is(err, syscall.EMSGSIZE) {
+		t.Fatalf("oversized write = %v, want EMSGSIZE", err)
+	}
+	max := qc.MaxWriteSize()
+	if max <= 0 || max >= 65000 {
+		t.Fatalf("MaxWriteSize = %d", max)
+	}
+
+	msg := bytes.Repeat([]byte{0xab}, max)
+	if _, err := conn.Write(msg); err != nil {
+		t.Fatalf("write of MaxWriteSize bytes: %v", err)
+	}
+	buf := make([]byte, 65536)
+	if n, err := accepted.Read(buf); err != nil || !bytes.Equal(buf[:n], msg) {
+		t.Fatalf("read: %d bytes, %v", n, err)
+	}
+}
+
+func TestDialRejectsForeignCertificates(t *testing.T) {
+	ca := newTestCA(t)
+	other := newTestCA(t)
+	bob := newTestTransport(t, ca, ca, "bob")
+	mallory := newTestTransport(t, ca, other, "mallory")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	listener, err := bob.Listen(ctx, transport.ListenOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := mallory.Dial(ctx, transport.DialOptions{PeerName: "bob", PeerInfo: peerInfo("bob", bob)}); err == nil {
+		t.Fatal("expected dial with a foreign certificate to fail")
+	}
+
+	// Bob's certificate isn't valid for another peer name either
+	alice := newTestTransport(t, ca, ca, "alice")
+	if _, err := alice.Dial(ctx, transport.DialOptions{PeerName: "carol", PeerInfo: peerInfo("carol", bob)}); err == nil {
+		t.Fatal("expected dial to the wrong peer to fail")
+	}
+
+	acceptCtx, acceptCancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer acceptCancel()
+	if conn, err := listener.Accept(acceptCtx); err == nil {
+		t.Errorf("unexpected connection from %s", conn.PeerName())
+	}
+}
+
+func TestDialUnsupportedPeer(t *testing.T) {
+	ca := newTestCA(t)
+	alice := newTestTransport(t, ca, ca, "alice")
+
+	_, err := alice.Dial(context.Background(), transport.DialOptions{
+		PeerName: "bob",
+		PeerInfo: &transport.PeerInfo{Name: "bob", PublicIPs: []string{"192.0.2.1"}},
+	})
+	if !errors.Is(err, transport.ErrPeerUnsupported) {
+		t.Errorf("err = %v, want ErrPeerUnsupported", err)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	ca := newTestCA(t)
+	alice := newTestTransport(t, ca, ca, "alice")
+	bob := newTestTransport(t, ca, ca, "bob")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	listener, err := bob.Listen(ctx, transport.ListenOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rtt, err := alice.Probe(ctx, transport.ProbeOptions{PeerInfo: peerInfo("bob", bob), Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if rtt <= 0 || rtt > time.Second {
+		t.Errorf("rtt = %v", rtt)
+	}
+
+	// Probes are not handed to the listener
+	acceptCtx, acceptCancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer acceptCancel()
+	if _, err := listener.Accept(acceptCtx); err == nil {
+		t.Error("probe was accepted as a connection")
+	}
+}
+
+// TestMigration moves the client to a new address mid-connection, through
+// a relay whose outgoing socket is swapped, as after a NAT rebinding.
+func TestMigration(t *testing.T) {
+	ca := newTestCA(t)
+	alice := newTestTransport(t, ca, ca, "alice")
+	bob := newTestTransport(t, ca, ca, "bob")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	listener, err := bob.Listen(ctx, transport.ListenOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	relay := newTestRelay(t, netip.AddrPortFrom(netip.MustParseAddr("127.0.0.1"), uint16(bob.LocalAddr().(*net.UDPAddr).Port)))
+	conn, err := alice.Dial(ctx, transport.DialOptions{
+		PeerName: "bob",
+		PeerInfo: &transport.PeerInfo{Name: "bob", PrivateIPs: []string{"127.0.0.1"}, QUICPort: int(relay.addr().Port())},
+	})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	accepted, err := listener.Accept(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := accepted.RemoteAddr().String()
+
+	relay.rebind(t)
+	// Bob switches to the new address once he has validated it
+	buf := make([]byte, 100)
+	for accepted.RemoteAddr().String() == before {
+		if ctx.Err() != nil {
+			t.Fatalf("remote address still %s", before)
+		}
+		if _, err := conn.Write([]byte("after rebind")); err != nil {
+			t.Fatal(err)
+		}
+		if n, err := accepted.Read(buf); err != nil || string(buf[:n]) != "after rebind" {
+			t.Fatalf("read: %q %v", buf[:n], err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Bob's replies go to the new address
+	if _, err := accepted.Write([]byte("reply")); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := conn.Read(buf); err != nil || string(buf[:n]) != "reply" {
+		t.Fatalf("read reply: %q %v", buf[:n], err)
+	}
+}
+
+// TestHandshakeLoss completes the handshake when the first datagrams in
+// each direction are lost, retransmitting on probe timeouts.
+func TestHandshakeLoss(t *testing.T) {
+	ca := newTestCA(t)
+	alice := newTestTransport(t, ca, ca, "alice")
+	bob := newTestTransport(t, ca, ca, "bob")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	listener, err := bob.Listen(ctx, transport.ListenOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	relay := newTestRelay(t, netip.AddrPortFrom(netip.MustParseAddr("127.0.0.1"), uint16(bob.LocalAddr().(*net.UDPAddr).Port)))
+	relay.dropToServer.Store(1)
+	relay.dropToClient.Store(2)
+	conn, err := alice.Dial(ctx, transport.DialOptions{
+		PeerName: "bob",
+		PeerInfo: &transport.PeerInfo{Name: "bob", PrivateIPs: []string{"127.0.0.1"}, QUICPort: int(relay.addr().Port())},
+	})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	accepted, err := listener.Accept(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 100)
+	if n, err := accepted.Read(buf); err != nil || string(buf[:n]) != "hello" {
+		t.Fatalf("read: %q %v", buf[:n], err)
+	}
+}
+
+// testRelay forwards datagrams between one client and a server, sending to
+// the server from a socket that can be swapped, and dropping the first
+// datagrams in each direction on request.
+type testRelay struct {
+	front  *net.UDPConn
+	server netip.AddrPort
+	back   chan *net.UDPConn
+	client chan netip.AddrPort
+
+	dropToServer atomic.Int32
+	dropToClient atomic.Int32
+}
+
+// drop reports whether to drop a datagram, counting down a drop counter.
+func drop(counter *atomic.Int32) bool {
+	return counter.Add(-1) >= 0
+}
+
+func newTestRelay(t *testing.T, server netip.AddrPort) *testRelay {
+	t.Helper()
+	front, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &testRelay{front: front, server: server, back: make(chan *net.UDPConn, 1), client: make(chan netip.AddrPort, 1)}
+	t.Cleanup(func() { _ = front.Close() })
+	r.rebind(t)
+
+	go func() {
+		buf := make([]byte, 65536)
+		var back *net.UDPConn
+		clientSeen := false
+		for {
+			n, from, err := front.ReadFromUDPAddrPort(buf)
+			if err != nil {
+				return
+			}
+			if !clientSeen {
+				r.client <- from
+				clientSeen = true
+			}
+			select {
+			case back = <-r.back:
+			default:
+			}
+			if !drop(&r.dropToServer) {
+				_, _ = back.WriteToUDPAddrPort(buf[:n], server)
+			}
+		}
+	}()
+	return r
+}
+
+func (r *testRelay) addr() netip.AddrPort {
+	return r.front.LocalAddr().(*net.UDPAddr).AddrPort()
+}
+
+// rebind replaces the socket packets reach the server from, forwarding
+// replies from it back to the client.
+func (r *testRelay) rebind(t *testing.T) {
+	t.Helper()
+	back, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = back.Close() })
+	r.back <- back
+
+	go func() {
+		var client netip.AddrPort
+		buf := make([]byte, 65536)
+		for {
+			n, _, err := back.ReadFromUDPAddrPort(buf)
+			if err != nil {
+				return
+			}
+			if !client.IsValid() {
+				client = <-r.client
+				r.client <- client
+			}
+			if !drop(&r.dropToClient) {
+				_, _ = r.front.WriteToUDPAddrPort(buf[:n], client)
+			}
+		}
+	}()
+}
@@ -0,0 +1,429 @@
+// Package quic implements a QUIC transport carrying tunnel packets in
+// unreliable DATAGRAM frames (RFC 9221), on top of quic-go. Peers
+// authenticate each other with mutual TLS using their mesh CA certificates.
+//
+// Connections get congestion control, migration across address changes and
+// a single UDP port for all peers. Servers answer every new client with a
+// Retry, so no state is kept for spoofed addresses.
+package quic
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/rs/zerolog/log"
+	"github.com/tunnelmesh/tunnelmesh/internal/mesh"
+	"github.com/tunnelmesh/tunnelmesh/internal/transport"
+)
+
+const (
+	// alpn is the TLS application protocol negotiated by mesh peers.
+	alpn = "tunnelmesh"
+
+	// probeALPN is negotiated by probes, which the server doesn't hand to
+	// the listener.
+	probeALPN = "tunnelmesh-probe"
+
+	// accepted is sent by the server once it has verified the client's
+	// certificate, which a TLS 1.3 client can't otherwise tell from a
+	// completed handshake.
+	accepted = 0x01
+
+	// handshakeTimeout limits waiting for the server to accept us, and
+	// for a probing client to close its connection.
+	handshakeTimeout = 10 * time.Second
+
+	// idleTimeout closes connections nothing has been received on for this long.
+	idleTimeout = 30 * time.Second
+
+	// keepaliveInterval is how long a connection can go without sending
+	// anything before it sends a PING, keeping NAT bindings open and telling
+	// the peer our address after it changes.
+	keepaliveInterval = 10 * time.Second
+)
+
+// Config holds QUIC transport configuration.
+type Config struct {
+	// Port is the UDP port to listen on (0 for an ephemeral port)
+	Port int
+
+	// Certificate returns our mesh certificate, presented to servers and clients
+	Certificate func() (*tls.Certificate, error)
+
+	// VerifyConnection verifies the other side's certificate against the
+	// mesh CA, for the name in ConnectionState.ServerName
+	VerifyConnection func(tls.ConnectionState) error
+}
+
+// Transport implements the QUIC transport over one UDP socket shared by
+// all connections.
+type Transport struct {
+	config Config
+
+	mu       sync.RWMutex
+	conn     *net.UDPConn
+	qt       *quic.Transport
+	conns    map[*Connection]struct{}
+	listener *Listener
+
+	running atomic.Bool
+}
+
+// New creates a new QUIC transport.
+func New(cfg Config) (*Transport, error) {
+	if cfg.Certificate == nil || cfg.VerifyConnection == nil {
+		return nil, errors.New("certificate and verification are required")
+	}
+	return &Transport{
+		config: cfg,
+		conns:  make(map[*Connection]struct{}),
+	}, nil
+}
+
+// Type returns the transport type.
+func (t *Transport) Type() transport.TransportType {
+	return transport.TransportQUIC
+}
+
+// Start opens the UDP socket and starts receiving packets.
+func (t *Transport) Start() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.running.Load() {
+		return fmt.Errorf("transport already running")
+	}
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: t.config.Port})
+	if err != nil {
+		return fmt.Errorf("listen UDP: %w", err)
+	}
+	t.conn = conn
+	t.qt = &quic.Transport{
+		Conn: conn,
+		// Clients prove they receive packets at their address before we
+		// keep any state for them
+		VerifySourceAddress: func(net.Addr) bool { return true },
+	}
+	t.running.Store(true)
+
+	log.Info().Str("addr", conn.LocalAddr().String()).Msg("QUIC transport started")
+	return nil
+}
+
+// LocalAddr returns the address of the transport's socket, or nil before
+// it is started.
+func (t *Transport) LocalAddr() net.Addr {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.conn == nil {
+		return nil
+	}
+	return t.conn.LocalAddr()
+}
+
+// quicConfig returns the QUIC configuration of our connections, which
+// carry datagrams only.
+func quicConfig() *quic.Config {
+	return &quic.Config{
+		EnableDatagrams:       true,
+		MaxIdleTimeout:        idleTimeout,
+		KeepAlivePeriod:       keepaliveInterval,
+		MaxIncomingStreams:    -1,
+		MaxIncomingUniStreams: 1, // The server's acceptance
+	}
+}
+
+// clientConfig returns the TLS configuration for dialing a peer.
+func (t *Transport) clientConfig(peerName, proto string) *tls.Config {
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS13,
+		ServerName:         peerName + mesh.DomainSuffix,
+		NextProtos:         []string{proto},
+		InsecureSkipVerify: true, //nolint:gosec // verified in VerifyConnection
+		VerifyConnection:   t.config.VerifyConnection,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return t.config.Certificate()
+		},
+	}
+}
+
+// serverConfig returns the TLS configuration for accepting mesh peers.
+func (t *Transport) serverConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS13,
+		NextProtos: []string{alpn, probeALPN},
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return t.config.Certificate()
+		},
+		ClientAuth:             tls.RequireAnyClientCert,
+		VerifyConnection:       t.verifyClient,
+		SessionTicketsDisabled: true,
+	}
+}
+
+// verifyClient checks that a client presented a mesh peer certificate,
+// valid for client authentication, for the name in its common name.
+func (t *Transport) verifyClient(cs tls.ConnectionState) error {
+	leaf := cs.PeerCertificates[0]
+	if _, ok := certPeerName(leaf); !ok {
+		return fmt.Errorf("client certificate %q is not a mesh peer certificate", leaf.Subject.CommonName)
+	}
+	if !slices.Contains(leaf.ExtKeyUsage, x509.ExtKeyUsageClientAuth) {
+		return fmt.Errorf("client certificate %q is not valid for client authentication", leaf.Subject.CommonName)
+	}
+	cs.ServerName = leaf.Subject.CommonName
+	return t.config.VerifyConnection(cs)
+}
+
+// certPeerName returns the name of the peer a mesh certificate was issued to.
+func certPeerName(cert *x509.Certificate) (string, bool) {
+	name, ok := strings.CutSuffix(cert.Subject.CommonName, mesh.DomainSuffix)
+	return name, ok && name != ""
+}
+
+// track keeps a connection until it is closed, so closing the transport
+// can tell its peer.
+func (t *Transport) track(c *Connection) {
+	t.mu.Lock()
+	t.conns[c] = struct{}{}
+	t.mu.Unlock()
+	go func() {
+		<-c.conn.Context().Done()
+		t.mu.Lock()
+		delete(t.conns, c)
+		t.mu.Unlock()
+	}()
+}
+
+// Dial connects to a peer, trying its private addresses before its public
+// ones. Attempts to successive addresses start a little apart and the
+// first to complete its handshake wins.
+func (t *Transport) Dial(ctx context.Context, opts transport.DialOptions) (transport.Connection, error) {
+	conn, err := t.dialPeer(ctx, opts, alpn)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (t *Transport) dialPeer(ctx context.Context, opts transport.DialOptions, proto string) (*Connection, error) {
+	if opts.PeerInfo == nil {
+		return nil, fmt.Errorf("peer info required")
+	}
+	if opts.PeerInfo.QUICPort == 0 {
+		return nil, fmt.Errorf("%w: %s has no QUIC port", transport.ErrPeerUnsupported, opts.PeerName)
+	}
+	addrs := transport.DialAddrs(opts.PeerInfo, opts.PeerInfo.QUICPort)
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no addresses for peer %s", opts.PeerName)
+	}
+	if !t.running.Load() {
+		if err := t.Start(); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	conn, err := transport.DialFirst(ctx, addrs, func(ctx context.Context, addr netip.AddrPort) (transport.Connection, error) {
+		conn, err := t.dial(ctx, opts.PeerName, addr, proto)
+		if err != nil {
+			return nil, err
+		}
+		return conn, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", opts.PeerName, err)
+	}
+	log.Debug().
+		Str("peer", opts.PeerName).
+		Str("addr", conn.RemoteAddr().String()).
+		Msg("QUIC connection established")
+	return conn.(*Connection), nil
+}
+
+// dial connects to one address of a peer.
+func (t *Transport) dial(ctx context.Context, peerName string, addr netip.AddrPort, proto string) (*Connection, error) {
+	t.mu.RLock()
+	qt := t.qt
+	t.mu.RUnlock()
+	qconn, err := qt.Dial(ctx, net.UDPAddrFromAddrPort(addr), t.clientConfig(peerName, proto), quicConfig())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", addr, err)
+	}
+
+	// Wait for the server to accept our certificate
+	ctx, cancel := context.WithTimeout(ctx, handshakeTimeout)
+	defer cancel()
+	stream, err := qconn.AcceptUniStream(ctx)
+	var b [1]byte
+	if err == nil {
+		_, err = io.ReadFull(stream, b[:])
+		if err == nil && b[0] != accepted {
+			err = errors.New("unexpected handshake response")
+		}
+	}
+	if err != nil {
+		_ = qconn.CloseWithError(0, "")
+		return nil, fmt.Errorf("%s: %w", addr, err)
+	}
+
+	c := newConnection(qconn, peerName)
+	t.track(c)
+	return c, nil
+}
+
+// Listen returns a listener for incoming connections.
+func (t *Transport) Listen(ctx context.Context, opts transport.ListenOptions) (transport.Listener, error) {
+	if !t.running.Load() {
+		if err := t.Start(); err != nil {
+			return nil, err
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ql, err := t.qt.Listen(t.serverConfig(), quicConfig())
+	if err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+	l := &Listener{
+		transport: t,
+		listener:  ql,
+		queue:     transport.NewAcceptQueue(16),
+	}
+	t.listener = l
+	go l.acceptLoop()
+	return l, nil
+}
+
+// Probe tests if the peer is reachable over QUIC, with a full handshake
+// the peer doesn't treat as a new connection. Returns the handshake RTT.
+func (t *Transport) Probe(ctx context.Context, opts transport.ProbeOptions) (time.Duration, error) {
+	if opts.PeerInfo == nil {
+		return 0, fmt.Errorf("peer info required")
+	}
+	conn, err := t.dialPeer(ctx, transport.DialOptions{
+		PeerName: opts.PeerInfo.Name,
+		PeerInfo: opts.PeerInfo,
+		Timeout:  opts.Timeout,
+	}, probeALPN)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = conn.Close() }()
+	return conn.RTT(), nil
+}
+
+// Close shuts down the transport and its connections.
+func (t *Transport) Close() error {
+	if !t.running.Swap(false) {
+		return nil
+	}
+
+	t.mu.Lock()
+	conns := make([]*Connection, 0, len(t.conns))
+	for c := range t.conns {
+		conns = append(conns, c)
+	}
+	l := t.listener
+	t.mu.Unlock()
+
+	if l != nil {
+		_ = l.Close()
+	}
+	for _, c := range conns {
+		_ = c.Close()
+	}
+	_ = t.qt.Close()
+	return t.conn.Close()
+}
+
+// Listener accepts incoming QUIC connections.
+type Listener struct {
+	transport *Transport
+	listener  *quic.Listener
+	queue     *transport.AcceptQueue
+}
+
+// acceptLoop accepts connections until the listener is closed, handling
+// each in its own goroutine.
+func (l *Listener) acceptLoop() {
+	for {
+		qconn, err := l.listener.Accept(context.Background())
+		if err != nil {
+			return
+		}
+		go l.handle(qconn)
+	}
+}
+
+// handle tells a client whose certificate was verified in the handshake
+// that it was accepted, handing its connection to Accept unless it is a
+// probe.
+func (l *Listener) handle(qconn *quic.Conn) {
+	state := qconn.ConnectionState().TLS
+	name, _ := certPeerName(state.PeerCertificates[0])
+
+	stream, err := qconn.OpenUniStream()
+	if err == nil {
+		if _, err = stream.Write([]byte{accepted}); err == nil {
+			err = stream.Close()
+		}
+	}
+	if err != nil {
+		log.Debug().Err(err).Str("peer", name).Msg("failed to accept QUIC connection")
+		_ = qconn.CloseWithError(0, "")
+		return
+	}
+
+	if state.NegotiatedProtocol == probeALPN {
+		// The client closes the probe once it knows it was accepted
+		select {
+		case <-qconn.Context().Done():
+		case <-time.After(handshakeTimeout):
+			_ = qconn.CloseWithError(0, "")
+		}
+		return
+	}
+
+	c := newConnection(qconn, name)
+	l.transport.track(c)
+	if !l.queue.TrySend(c) {
+		log.Warn().Str("peer", name).Msg("QUIC listener not accepting, dropping connection")
+		_ = c.Close()
+	}
+}
+
+// Accept waits for and returns the next connection.
+func (l *Listener) Accept(ctx context.Context) (transport.Connection, error) {
+	return l.queue.Accept(ctx)
+}
+
+// Addr returns the listener's address.
+func (l *Listener) Addr() net.Addr {
+	return l.transport.LocalAddr()
+}
+
+// Close closes the listener.
+func (l *Listener) Close() error {
+	if !l.queue.Close() {
+		return nil
+	}
+	return l.listener.Close()
+}
@@ -0,0 +1,245 @@
+package tls
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tunnelmesh/tunnelmesh/internal/transport"
+)
+
+// errHelloRead stops the handshake started to read a ClientHello.
+var errHelloRead = errors.New("client hello read")
+
+// Listener implements the transport.Listener interface for TLS.
+type Listener struct {
+	transport *Transport
+	listener  net.Listener
+	queue     *transport.AcceptQueue
+}
+
+// acceptLoop accepts TCP connections until the listener is closed,
+// handling each in its own goroutine.
+func (l *Listener) acceptLoop() {
+	for {
+		conn, err := l.listener.Accept()
+		if err != nil {
+			if l.queue.Closed() {
+				return
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			log.Error().Err(err).Msg("TLS accept error")
+			return
+		}
+		go l.handle(conn)
+	}
+}
+
+// handle completes the outer handshake of a new connection as the web
+// site, then the mesh handshake with mesh peers inside it, proxying
+// anything else to the fallback.
+func (l *Listener) handle(conn net.Conn) {
+	_ = conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	ctx, cancel := context.WithTimeout(context.Background(), handshakeTimeout)
+	defer cancel()
+
+	var backend *tls.Conn
+	outer := tls.Server(conn, l.outerConfig(&backend))
+	if err := outer.HandshakeContext(ctx); err != nil {
+		log.Debug().Err(err).Str("remote", conn.RemoteAddr().String()).Msg("TLS handshake failed")
+		if backend != nil {
+			_ = backend.Close()
+		}
+		_ = conn.Close()
+		return
+	}
+
+	// Web clients send HTTP, mesh peers a ClientHello of their own
+	protos, hello, err := readClientHello(outer)
+	replay := &replayConn{Conn: outer, r: io.MultiReader(bytes.NewReader(hello), outer)}
+	if err != nil || (!slices.Contains(protos, alpn) && !slices.Contains(protos, probeALPN)) {
+		_ = conn.SetDeadline(time.Time{})
+		l.passThrough(replay, backend)
+		return
+	}
+	if backend != nil {
+		_ = backend.Close()
+	}
+
+	tlsConn := tls.Server(replay, l.transport.serverConfig())
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		log.Warn().Err(err).Str("remote", conn.RemoteAddr().String()).Msg("TLS handshake failed")
+		_ = conn.Close()
+		return
+	}
+	if _, err := tlsConn.Write([]byte{accepted}); err != nil {
+		_ = tlsConn.Close()
+		return
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	state := tlsConn.ConnectionState()
+	if state.NegotiatedProtocol == probeALPN {
+		_ = tlsConn.Close()
+		return
+	}
+	name, _ := certPeerName(state.PeerCertificates[0])
+	if !l.queue.TrySend(newConnection(tlsConn, name)) {
+		log.Warn().Str("peer", name).Msg("TLS listener not accepting, closing connection")
+		_ = tlsConn.Close()
+	}
+}
+
+// outerConfig returns the TLS configuration of the outer handshake. With a
+// fallback, it connects to it for the server name and application
+// protocols the client offers, storing the connection in backend, and
+// negotiates the application protocol the fallback chose, so the handshake
+// goes as it would with the web server itself.
+func (l *Listener) outerConfig(backend **tls.Conn) *tls.Config {
+	config := &tls.Config{
+		NextProtos: webProtos,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return l.transport.siteCertificate()
+		},
+	}
+	fallback := l.transport.config.Fallback
+	if fallback == "" {
+		return config
+	}
+	config.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		dialer := &tls.Dialer{Config: &tls.Config{
+			ServerName:         hello.ServerName,
+			NextProtos:         hello.SupportedProtos,
+			InsecureSkipVerify: true, //nolint:gosec // the client verifies the site certificate we present
+		}}
+		conn, err := dialer.DialContext(hello.Context(), "tcp", fallback)
+		if err != nil {
+			// Mesh peers can still connect
+			log.Warn().Err(err).Str("fallback", fallback).Msg("failed to connect to TLS fallback")
+			return nil, nil
+		}
+		*backend = conn.(*tls.Conn)
+		c := config.Clone()
+		c.GetConfigForClient = nil
+		c.NextProtos = nil
+		if proto := (*backend).ConnectionState().NegotiatedProtocol; proto != "" {
+			c.NextProtos = []string{proto}
+		}
+		return c, nil
+	}
+	return config
+}
+
+// passThrough proxies a connection that isn't from a mesh peer to the
+// fallback web server, or closes it if there is none.
+func (l *Listener) passThrough(conn *replayConn, backend *tls.Conn) {
+	defer func() { _ = conn.Close() }()
+	if backend == nil {
+		return
+	}
+	defer func() { _ = backend.Close() }()
+
+	var wg sync.WaitGroup
+	wg.Go(func() { proxy(backend, conn) })
+	proxy(conn, backend)
+	wg.Wait()
+}
+
+// proxy copies src to dst, then closes dst for writing so the other side
+// sees the end of the stream.
+func proxy(dst net.Conn, src io.Reader) {
+	_, _ = io.Copy(dst, src)
+	if cw, ok := dst.(interface{ CloseWrite() error }); ok {
+		_ = cw.CloseWrite()
+	} else {
+		_ = dst.Close()
+	}
+}
+
+// readClientHello reads the ClientHello of a new connection, returning the
+// application protocols the client offers and the bytes read, which must
+// be replayed to whatever handles the connection, even if they weren't a
+// ClientHello.
+func readClientHello(conn net.Conn) ([]string, []byte, error) {
+	sniff := &sniffConn{Conn: conn}
+	var (
+		protos []string
+		read   bool
+	)
+	err := tls.Server(sniff, &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			protos = slices.Clone(hello.SupportedProtos)
+			read = true
+			return nil, errHelloRead
+		},
+	}).Handshake()
+	if !read {
+		return nil, sniff.buf.Bytes(), fmt.Errorf("read ClientHello: %w", err)
+	}
+	return protos, sniff.buf.Bytes(), nil
+}
+
+// sniffConn records what is read from a connection and discards what is
+// written to it, so a handshake can be started just to read a ClientHello.
+type sniffConn struct {
+	net.Conn
+	buf bytes.Buffer
+}
+
+func (c *sniffConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.buf.Write(p[:n])
+	return n, err
+}
+
+func (c *sniffConn) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+// replayConn reads the bytes consumed reading the inner ClientHello before
+// the rest of the connection.
+type replayConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *replayConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// CloseWrite closes the connection for writing.
+func (c *replayConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return c.Conn.Close()
+}
+
+// Accept waits for and returns the next connection.
+func (l *Listener) Accept(ctx context.Context) (transport.Connection, error) {
+	return l.queue.Accept(ctx)
+}
+
+// Addr returns the listener's network address.
+func (l *Listener) Addr() net.Addr {
+	return l.listener.Addr()
+}
+
+// Close stops listening.
+func (l *Listener) Close() error {
+	if !l.queue.Close() {
+		return nil
+	}
+	return l.listener.Close()
+}
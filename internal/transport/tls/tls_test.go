@@ -0,0 +1,346 @@
+package tls
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/tunnelmesh/tunnelmesh/internal/transport"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key}
+}
+
+// peerCert issues a certificate like the coordinator's peer certificates.
+func (ca *testCA) peerCert(t *testing.T, name string) *tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name + ".tunnelmesh"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{name + ".tunnelmesh"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// verify checks certificates like peer.TLSManager.VerifyConnection.
+func (ca *testCA) verify(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("no certificate presented")
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{DNSName: cs.ServerName, Roots: roots})
+	return err
+}
+
+func newTestTransport(t *testing.T, ca *testCA, certCA *testCA, name, fallback string) *Transport {
+	t.Helper()
+	cert := certCA.peerCert(t, name)
+	tr, err := New(Config{
+		Certificate:      func() (*tls.Certificate, error) { return cert, nil },
+		VerifyConnection: ca.verify,
+		Fallback:         fallback,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = tr.Close() })
+	return tr
+}
+
+func listen(t *testing.T, tr *Transport) transport.Listener {
+	t.Helper()
+	listener, err := tr.Listen(context.Background(), transport.ListenOptions{Address: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return listener
+}
+
+func peerInfo(name string, listener transport.Listener) *transport.PeerInfo {
+	return &transport.PeerInfo{
+		Name:       name,
+		PrivateIPs: []string{"127.0.0.1"},
+		TLSPort:    listener.Addr().(*net.TCPAddr).Port,
+	}
+}
+
+func TestDialAndAccept(t *testing.T) {
+	ca := newTestCA(t)
+	alice := newTestTransport(t, ca, ca, "alice", "")
+	bob := newTestTransport(t, ca, ca, "bob", "")
+	listener := listen(t, bob)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := alice.Dial(ctx, transport.DialOptions{PeerName: "bob", PeerInfo: peerInfo("bob", listener)})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	if conn.PeerName() != "bob" || conn.Type() != transport.TransportTLS || !conn.IsHealthy() {
+		t.Errorf("unexpected connection %s %s healthy=%v", conn.PeerName(), conn.Type(), conn.IsHealthy())
+	}
+
+	accepted, err := listener.Accept(ctx)
+	if err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	defer func() { _ = accepted.Close() }()
+	if accepted.PeerName() != "alice" {
+		t.Errorf("accepted peer = %q, want alice", accepted.PeerName())
+	}
+
+	msg := bytes.Repeat([]byte("tunnel"), 1000)
+	go func() { _, _ = conn.Write(msg) }()
+	buf := make([]byte, len(msg))
+	if _, err := io.ReadFull(accepted, buf); err != nil || !bytes.Equal(buf, msg) {
+		t.Fatalf("read: %v", err)
+	}
+	if _, err := accepted.Write(msg[:10]); err != nil {
+		t.Fatalf("write back: %v", err)
+	}
+	if _, err := io.ReadFull(conn, buf[:10]); err != nil || !bytes.Equal(buf[:10], msg[:10]) {
+		t.Fatalf("read back: %v", err)
+	}
+
+	_ = conn.Close()
+	if conn.IsHealthy() {
+		t.Error("closed connection reported healthy")
+	}
+}
+
+func TestDialRejectsForeignCertificates(t *testing.T) {
+	ca := newTestCA(t)
+	other := newTestCA(t)
+	bob := newTestTransport(t, ca, ca, "bob", "")
+	mallory := newTestTransport(t, ca, other, "mallory", "")
+	listener := listen(t, bob)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := mallory.Dial(ctx, transport.DialOptions{PeerName: "bob", PeerInfo: peerInfo("bob", listener)}); err == nil {
+		t.Fatal("expected dial with a foreign certificate to fail")
+	}
+
+	// Bob's certificate isn't valid for another peer name either
+	alice := newTestTransport(t, ca, ca, "alice", "")
+	if _, err := alice.Dial(ctx, transport.DialOptions{PeerName: "carol", PeerInfo: peerInfo("carol", listener)}); err == nil {
+		t.Fatal("expected dial to the wrong peer to fail")
+	}
+
+	acceptCtx, acceptCancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer acceptCancel()
+	if conn, err := listener.Accept(acceptCtx); err == nil {
+		t.Errorf("unexpected connection from %s", conn.PeerName())
+	}
+}
+
+func TestDialUnsupportedPeer(t *testing.T) {
+	ca := newTestCA(t)
+	alice := newTestTransport(t, ca, ca, "alice", "")
+
+	_, err := alice.Dial(context.Background(), transport.DialOptions{
+		PeerName: "bob",
+		PeerInfo: &transport.PeerInfo{Name: "bob", PublicIPs: []string{"192.0.2.1"}},
+	})
+	if !errors.Is(err, transport.ErrPeerUnsupported) {
+		t.Errorf("err = %v, want ErrPeerUnsupported", err)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	ca := newTestCA(t)
+	alice := newTestTransport(t, ca, ca, "alice", "")
+	bob := newTestTransport(t, ca, ca, "bob", "")
+	listener := listen(t, bob)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	latency, err := alice.Probe(ctx, transport.ProbeOptions{PeerInfo: peerInfo("bob", listener), Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if latency <= 0 || latency > time.Second {
+		t.Errorf("latency = %v", latency)
+	}
+
+	// Probes are not handed to the listener
+	acceptCtx, acceptCancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer acceptCancel()
+	if _, err := listener.Accept(acceptCtx); err == nil {
+		t.Error("probe was accepted as a connection")
+	}
+}
+
+// TestDialLooksLikeHTTPS checks the ClientHello peers send carries the
+// web site's server name and web application protocols.
+func TestDialLooksLikeHTTPS(t *testing.T) {
+	ca := newTestCA(t)
+	alice := newTestTransport(t, ca, ca, "alice", "")
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	hellos := make(chan *tls.ClientHelloInfo, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		_ = tls.Server(conn, &tls.Config{
+			GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+				hellos <- hello
+				return nil, errHelloRead
+			},
+		}).Handshake()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	info := &transport.PeerInfo{
+		Name:          "bob",
+		PrivateIPs:    []string{"127.0.0.1"},
+		TLSPort:       ln.Addr().(*net.TCPAddr).Port,
+		TLSServerName: "www.example.com",
+	}
+	_, _ = alice.Dial(ctx, transport.DialOptions{PeerName: "bob", PeerInfo: info})
+
+	select {
+	case hello := <-hellos:
+		if hello.ServerName != "www.example.com" {
+			t.Errorf("server name = %q, want www.example.com", hello.ServerName)
+		}
+		if !slices.Equal(hello.SupportedProtos, []string{"h2", "http/1.1"}) {
+			t.Errorf("application protocols = %q, want h2 and http/1.1", hello.SupportedProtos)
+		}
+	case <-ctx.Done():
+		t.Fatal("no ClientHello received")
+	}
+}
+
+// TestFallback checks HTTPS clients reach the web server sharing the port
+// while mesh peers still connect.
+func TestFallback(t *testing.T) {
+	web := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "hello from the web server over "+r.Proto)
+	}))
+	web.EnableHTTP2 = true
+	web.StartTLS()
+	defer web.Close()
+
+	ca := newTestCA(t)
+	alice := newTestTransport(t, ca, ca, "alice", "")
+	bob := newTestTransport(t, ca, ca, "bob", web.Listener.Addr().String())
+	bob.config.SiteCertificate = func() (*tls.Certificate, error) { return &web.TLS.Certificates[0], nil }
+	listener := listen(t, bob)
+
+	roots := web.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+	for _, h2 := range []bool{true, false} {
+		protos := []string{"http/1.1"}
+		if h2 {
+			protos = webProtos
+		}
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: roots, NextProtos: protos},
+			ForceAttemptHTTP2: h2,
+		}}
+		resp, err := client.Get("https://" + listener.Addr().String() + "/")
+		if err != nil {
+			t.Fatalf("GET through the TLS transport: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		want := "hello from the web server over HTTP/1.1"
+		if h2 {
+			want = "hello from the web server over HTTP/2.0"
+		}
+		if string(body) != want {
+			t.Errorf("body = %q, want %q", body, want)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := alice.Dial(ctx, transport.DialOptions{PeerName: "bob", PeerInfo: peerInfo("bob", listener)})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	_ = conn.Close()
+}
+
+func TestFallbackDisabled(t *testing.T) {
+	ca := newTestCA(t)
+	bob := newTestTransport(t, ca, ca, "bob", "")
+	listener := listen(t, bob)
+
+	client := &http.Client{
+		Timeout:   5 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}, //nolint:gosec // test
+	}
+	if resp, err := client.Get("https://" + listener.Addr().String() + "/"); err == nil {
+		_ = resp.Body.Close()
+		t.Fatal("expected connection from a non-mesh client to be closed")
+	}
+}
@@ -0,0 +1,368 @@
+// Package tls implements a TLS 1.3 over TCP transport for networks where
+// only outbound HTTPS works. Connections look like HTTPS to the web site
+// the listening peer serves: the outer handshake carries the site's server
+// name and the usual web application protocols. Inside it, peers
+// authenticate each other with mutual TLS using their mesh CA certificates.
+// The listening port can be shared with a web server: connections that
+// don't start a mesh handshake inside the outer one are proxied to it.
+package tls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tunnelmesh/tunnelmesh/internal/mesh"
+	"github.com/tunnelmesh/tunnelmesh/internal/transport"
+)
+
+// webProtos are the application protocols offered in the outer handshake,
+// as browsers do.
+var webProtos = []string{"h2", "http/1.1"}
+
+const (
+	// alpn is the TLS application protocol negotiated by mesh peers inside
+	// the outer connection, which tells them apart from web clients.
+	alpn = "tunnelmesh"
+
+	// probeALPN is negotiated by probes, which the server closes instead of
+	// accepting once the handshake completes.
+	probeALPN = "tunnelmesh-probe"
+
+	// DefaultPort is the port the transport listens on by default.
+	DefaultPort = 443
+
+	// accepted is sent by the server once it has verified the client's
+	// certificate, which a TLS 1.3 client can't otherwise tell from a
+	// completed handshake.
+	accepted = 0x01
+
+	// handshakeTimeout limits reading the ClientHello and the handshake of
+	// incoming connections, and waiting for the server to accept ours.
+	handshakeTimeout = 10 * time.Second
+)
+
+// Config holds TLS transport configuration.
+type Config struct {
+	// Certificate returns our mesh certificate, presented to servers and clients
+	Certificate func() (*tls.Certificate, error)
+
+	// VerifyConnection verifies the other side's certificate against the
+	// mesh CA, for the name in ConnectionState.ServerName
+	VerifyConnection func(tls.ConnectionState) error
+
+	// SiteCertificate returns the certificate of the web site the port
+	// serves, presented in the outer handshake (nil presents Certificate)
+	SiteCertificate func() (*tls.Certificate, error)
+
+	// Fallback is the address of the HTTPS server of that web site, which
+	// connections from anything but mesh peers are proxied to (empty
+	// closes them)
+	Fallback string
+}
+
+// Transport implements the TLS transport.
+type Transport struct {
+	config Config
+
+	mu       sync.Mutex
+	listener *Listener
+	closed   atomic.Bool
+}
+
+// New creates a new TLS transport.
+func New(cfg Config) (*Transport, error) {
+	if cfg.Certificate == nil {
+		return nil, fmt.Errorf("certificate is required")
+	}
+	if cfg.VerifyConnection == nil {
+		return nil, fmt.Errorf("certificate verification is required")
+	}
+	return &Transport{config: cfg}, nil
+}
+
+// Type returns the transport type.
+func (t *Transport) Type() transport.TransportType {
+	return transport.TransportTLS
+}
+
+// Dial connects to a peer, trying its private addresses before its public
+// ones. Attempts to successive addresses start a little apart and the
+// first to complete its handshake wins. The outer handshake is sent with
+// the server name of the web site the peer serves, if it has one.
+func (t *Transport) Dial(ctx context.Context, opts transport.DialOptions) (transport.Connection, error) {
+	conn, err := t.dialPeer(ctx, opts, alpn)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (t *Transport) dialPeer(ctx context.Context, opts transport.DialOptions, proto string) (*Connection, error) {
+	if t.closed.Load() {
+		return nil, fmt.Errorf("transport is closed")
+	}
+	if opts.PeerInfo == nil {
+		return nil, fmt.Errorf("peer info required")
+	}
+	if opts.PeerInfo.TLSPort == 0 {
+		return nil, fmt.Errorf("%w: %s has no TLS port", transport.ErrPeerUnsupported, opts.PeerName)
+	}
+	addrs := transport.DialAddrs(opts.PeerInfo, opts.PeerInfo.TLSPort)
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no addresses for peer %s", opts.PeerName)
+	}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	conn, err := transport.DialFirst(ctx, addrs, func(ctx context.Context, addr netip.AddrPort) (transport.Connection, error) {
+		conn, err := t.dial(ctx, opts.PeerName, addr.String(), opts.PeerInfo.TLSServerName, proto)
+		if err != nil {
+			return nil, err
+		}
+		return conn, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", opts.PeerName, err)
+	}
+	log.Debug().
+		Str("peer", opts.PeerName).
+		Str("addr", conn.RemoteAddr().String()).
+		Msg("TLS connection established")
+	return conn.(*Connection), nil
+}
+
+// dial connects to one address of a peer.
+func (t *Transport) dial(ctx context.Context, peerName, addr, serverName, proto string) (*Connection, error) {
+	dialer := &tls.Dialer{Config: outerClientConfig(serverName)}
+	outer, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", addr, err)
+	}
+	conn := tls.Client(outer, t.clientConfig(peerName, proto))
+	if err := conn.HandshakeContext(ctx); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("%s: %w", addr, err)
+	}
+	if conn.ConnectionState().NegotiatedProtocol != proto {
+		_ = conn.Close()
+		return nil, fmt.Errorf("%s: peer did not negotiate %s", addr, proto)
+	}
+
+	// Wait for the server to accept our certificate
+	_ = conn.SetReadDeadline(time.Now().Add(handshakeTimeout))
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetReadDeadline(time.Now()) })
+	defer stop()
+	var b [1]byte
+	if _, err := io.ReadFull(conn, b[:]); err != nil || b[0] != accepted {
+		_ = conn.Close()
+		if err == nil {
+			err = fmt.Errorf("unexpected handshake response")
+		}
+		return nil, fmt.Errorf("%s: %w", addr, err)
+	}
+	if !stop() {
+		_ = conn.Close()
+		return nil, fmt.Errorf("%s: %w", addr, ctx.Err())
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+	return newConnection(conn, peerName), nil
+}
+
+// outerClientConfig returns the TLS configuration of the outer handshake
+// with a peer serving the web site serverName (empty sends no server name,
+// as for a site reached by IP address).
+func outerClientConfig(serverName string) *tls.Config {
+	return &tls.Config{
+		ServerName:         serverName,
+		NextProtos:         webProtos,
+		InsecureSkipVerify: true, //nolint:gosec // peers authenticate each other inside the connection
+	}
+}
+
+// clientConfig returns the TLS configuration for the mesh handshake with a
+// peer, inside the outer connection.
+func (t *Transport) clientConfig(peerName, proto string) *tls.Config {
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS13,
+		ServerName:         peerName + mesh.DomainSuffix,
+		NextProtos:         []string{proto},
+		InsecureSkipVerify: true, //nolint:gosec // verified in VerifyConnection
+		VerifyConnection:   t.config.VerifyConnection,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return t.config.Certificate()
+		},
+	}
+}
+
+// siteCertificate returns the certificate presented in the outer handshake.
+func (t *Transport) siteCertificate() (*tls.Certificate, error) {
+	if t.config.SiteCertificate != nil {
+		return t.config.SiteCertificate()
+	}
+	return t.config.Certificate()
+}
+
+// serverConfig returns the TLS configuration for accepting mesh peers,
+// inside the outer connection.
+func (t *Transport) serverConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS13,
+		NextProtos: []string{alpn, probeALPN},
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return t.config.Certificate()
+		},
+		ClientAuth:             tls.RequireAnyClientCert,
+		VerifyConnection:       t.verifyClient,
+		SessionTicketsDisabled: true,
+	}
+}
+
+// verifyClient checks that a client presented a mesh peer certificate,
+// valid for client authentication, for the name in its common name.
+func (t *Transport) verifyClient(cs tls.ConnectionState) error {
+	leaf := cs.PeerCertificates[0]
+	if _, ok := certPeerName(leaf); !ok {
+		return fmt.Errorf("client certificate %q is not a mesh peer certificate", leaf.Subject.CommonName)
+	}
+	if !slices.Contains(leaf.ExtKeyUsage, x509.ExtKeyUsageClientAuth) {
+		return fmt.Errorf("client certificate %q is not valid for client authentication", leaf.Subject.CommonName)
+	}
+	cs.ServerName = leaf.Subject.CommonName
+	return t.config.VerifyConnection(cs)
+}
+
+// certPeerName returns the name of the peer a mesh certificate was issued to.
+func certPeerName(cert *x509.Certificate) (string, bool) {
+	name, ok := strings.CutSuffix(cert.Subject.CommonName, mesh.DomainSuffix)
+	return name, ok && name != ""
+}
+
+// Listen starts listening for incoming connections.
+func (t *Transport) Listen(ctx context.Context, opts transport.ListenOptions) (transport.Listener, error) {
+	if t.closed.Load() {
+		return nil, fmt.Errorf("transport is closed")
+	}
+	addr := opts.Address
+	if addr == "" {
+		addr = fmt.Sprintf(":%d", opts.Port)
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+
+	l := &Listener{
+		transport: t,
+		listener:  ln,
+		queue:     transport.NewAcceptQueue(16),
+	}
+	t.mu.Lock()
+	t.listener = l
+	t.mu.Unlock()
+	go l.acceptLoop()
+	return l, nil
+}
+
+// Probe tests if the peer is reachable over TLS, with a full handshake
+// the peer doesn't treat as a new connection. Returns the time to connect
+// and complete the handshake.
+func (t *Transport) Probe(ctx context.Context, opts transport.ProbeOptions) (time.Duration, error) {
+	if opts.PeerInfo == nil {
+		return 0, fmt.Errorf("peer info required")
+	}
+	start := time.Now()
+	conn, err := t.dialPeer(ctx, transport.DialOptions{
+		PeerName: opts.PeerInfo.Name,
+		PeerInfo: opts.PeerInfo,
+		Timeout:  opts.Timeout,
+	}, probeALPN)
+	if err != nil {
+		return 0, err
+	}
+	latency := time.Since(start)
+	_ = conn.Close()
+	return latency, nil
+}
+
+// Close shuts down the transport.
+func (t *Transport) Close() error {
+	if t.closed.Swap(true) {
+		return nil // Already closed
+	}
+	t.mu.Lock()
+	l := t.listener
+	t.mu.Unlock()
+	if l != nil {
+		return l.Close()
+	}
+	return nil
+}
+
+// Connection wraps a TLS connection as a transport.Connection.
+type Connection struct {
+	conn     *tls.Conn
+	peerName string
+	closed   atomic.Bool
+}
+
+func newConnection(conn *tls.Conn, peerName string) *Connection {
+	return &Connection{conn: conn, peerName: peerName}
+}
+
+// Read reads data from the connection.
+func (c *Connection) Read(p []byte) (int, error) {
+	return c.conn.Read(p)
+}
+
+// Write writes data to the connection.
+func (c *Connection) Write(p []byte) (int, error) {
+	return c.conn.Write(p)
+}
+
+// Close closes the connection.
+func (c *Connection) Close() error {
+	if c.closed.Swap(true) {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// PeerName returns the peer name.
+func (c *Connection) PeerName() string {
+	return c.peerName
+}
+
+// Type returns the transport type.
+func (c *Connection) Type() transport.TransportType {
+	return transport.TransportTLS
+}
+
+// LocalAddr returns the local network address.
+func (c *Connection) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// RemoteAddr returns the remote network address.
+func (c *Connection) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// IsHealthy returns true until the connection is closed.
+func (c *Connection) IsHealthy() bool {
+	return !c.closed.Load()
+}
@@ -1,9 +1,10 @@
 // Package transport provides a pluggable transport abstraction layer
-// supporting multiple connection types (SSH, UDP, Relay) per peer.
+// supporting multiple connection types (SSH, UDP, QUIC, TLS, Relay) per peer.
 package transport
 
 import (
 	"context"
+	"errors"
 	"io"
 	"net"
 	"time"
@@ -15,10 +16,16 @@ type TransportType string
 const (
 	TransportSSH   TransportType = "ssh"
 	TransportUDP   TransportType = "udp"
+	TransportQUIC  TransportType = "quic"
+	TransportTLS   TransportType = "tls"
 	TransportRelay TransportType = "relay"
 	TransportAuto  TransportType = "auto"
 )
 
+// ErrPeerUnsupported is returned by Dial when the peer doesn't support the
+// transport, so retrying it is pointless.
+var ErrPeerUnsupported = errors.New("peer does not support transport")
+
 // Connection represents an established transport connection.
 // Extends io.ReadWriteCloser with transport-specific metadata.
 type Connection interface {
@@ -103,6 +110,9 @@ type PeerInfo struct {
 	PrivateIPs       []string
 	SSHPort          int
 	UDPPort          int
+	QUICPort         int    // Zero if the peer doesn't accept QUIC connections
+	TLSPort          int    // Zero if the peer doesn't accept TLS connections
+	TLSServerName    string // Web site the peer's TLS port serves, sent in TLS handshakes
 	Connectable      bool
 	BehindNAT        bool
 	PublicKey        string
@@ -0,0 +1,306 @@
+package udp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/blake2s"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Handshake DoS protection, as in WireGuard: initiators append two MACs to
+// their handshake initiation. MAC1 is keyed with the responder's public key,
+// so responders drop initiations from anyone who doesn't know it before
+// doing any Diffie-Hellman. When under load, responders also require MAC2,
+// keyed with a cookie bound to the initiator's address, and answer
+// initiations without a valid one with a cookie reply instead of a
+// handshake response, so only initiators able to receive packets at their
+// address cost them any Diffie-Hellman.
+const (
+	// macSize is the size of MAC1 and MAC2.
+	macSize = 16
+
+	// handshakeMACsSize is the size of the MACs ending a handshake initiation.
+	handshakeMACsSize = 2 * macSize
+
+	// cookieSecretLifetime is how often responders change the secret their
+	// cookies are derived from, and so how long a cookie can be used.
+	cookieSecretLifetime = 2 * time.Minute
+
+	// DefaultUnderLoadHandshakes is the rate of handshake initiations per
+	// second above which responders require cookies.
+	DefaultUnderLoadHandshakes = 64
+
+	// underLoadQueueLen is the packet queue length above which responders
+	// require cookies, like WireGuard's eighth of its handshake queue.
+	underLoadQueueLen = PacketQueueSize / 8
+
+	// underLoadGrace is how long responders keep requiring cookies once load
+	// drops.
+	underLoadGrace = time.Second
+)
+
+// Labels of the keys derived from the responder's public key.
+var (
+	labelMAC1   = []byte("mac1----")
+	labelCookie = []byte("cookie--")
+)
+
+// initType is the type byte of handshake initiations, covered by their MACs.
+var initType = []byte{PacketTypeHandshakeInit}
+
+// HandshakeStats holds cumulative handshake DoS protection counters.
+type HandshakeStats struct {
+	CookieReplies    uint64 // Cookie challenges sent to initiators while under load
+	DroppedMAC1      uint64 // Initiations dropped for a missing or invalid MAC1
+	DroppedUnderLoad uint64 // Initiations without MACs dropped while under load, when they are allowed
+}
+
+// macKey derives the key for MAC1 or cookie encryption from a public key.
+func macKey(label []byte, public [32]byte) [32]byte {
+	h, _ := blake2s.New256(nil)
+	h.Write(label)
+	h.Write(public[:])
+	var key [32]byte
+	h.Sum(key[:0])
+	return key
+}
+
+// mac computes a 16-byte keyed BLAKE2s MAC over the concatenated inputs.
+func mac(key []byte, in ...[]byte) [macSize]byte {
+	h, _ := blake2s.New128(key)
+	for _, b := range in {
+		h.Write(b)
+	}
+	var sum [macSize]byte
+	h.Sum(sum[:0])
+	return sum
+}
+
+// addrBytes returns the bytes of an address a cookie is bound to.
+func addrBytes(addr *net.UDPAddr) []byte {
+	b := addr.IP.To16()
+	if ip4 := addr.IP.To4(); ip4 != nil {
+		b = ip4
+	}
+	return binary.BigEndian.AppendUint16(append([]byte(nil), b...), uint16(addr.Port))
+}
+
+// appendHandshakeMACs appends MAC1 and MAC2 to a handshake initiation packet
+// (including its type byte) for a responder. MAC2 is zero without a cookie.
+// Returns the packet and its MAC1, which a cookie reply is bound to.
+func appendHandshakeMACs(packet []byte, responderPublic [32]byte, cookie []byte) ([]byte, [macSize]byte) {
+	key := macKey(labelMAC1, responderPublic)
+	mac1 := mac(key[:], packet)
+	packet = append(packet, mac1[:]...)
+	var mac2 [macSize]byte
+	if cookie != nil {
+		mac2 = mac(cookie, packet)
+	}
+	return append(packet, mac2[:]...), mac1
+}
+
+// splitHandshakeInit splits a handshake initiation (without its type byte)
+// into the Noise message, the bytes following it (the PSK epoch) and its
+// MACs, which are nil for initiators that predate them.
+func splitHandshakeInit(data []byte) (msg, trailer, macs []byte) {
+	msg, rest := data[:128], data[128:]
+	if len(rest) >= handshakeMACsSize {
+		return msg, rest[:len(rest)-handshakeMACsSize], rest[len(rest)-handshakeMACsSize:]
+	}
+	return msg, rest, nil
+}
+
+// cookieChecker verifies handshake MACs and issues cookies as a responder.
+type cookieChecker struct {
+	mac1Key   [32]byte
+	cookieKey [32]byte
+
+	mu         sync.Mutex
+	secret     [32]byte
+	secretTime time.Time
+}
+
+func newCookieChecker(public [32]byte) *cookieChecker {
+	return &cookieChecker{
+		mac1Key:   macKey(labelMAC1, public),
+		cookieKey: macKey(labelCookie, public),
+	}
+}
+
+// checkMAC1 verifies MAC1 of a handshake initiation, given without its
+// type byte, which the MACs also cover.
+func (c *cookieChecker) checkMAC1(data []byte) bool {
+	n := len(data) - handshakeMACsSize
+	want := mac(c.mac1Key[:], initType, data[:n])
+	return hmac.Equal(want[:], data[n:n+macSize])
+}
+
+// checkMAC2 verifies MAC2 of a handshake initiation, given without its type
+// byte, computed with the cookie for the address it came from.
+func (c *cookieChecker) checkMAC2(data []byte, addr *net.UDPAddr) bool {
+	n := len(data) - macSize
+	cookie := c.cookie(addr)
+	want := mac(cookie[:], initType, data[:n])
+	return hmac.Equal(want[:], data[n:])
+}
+
+// cookie returns the cookie for an address, changing the secret it is
+// derived from every cookieSecretLifetime.
+func (c *cookieChecker) cookie(addr *net.UDPAddr) [macSize]byte {
+	c.mu.Lock()
+	if time.Since(c.secretTime) > cookieSecretLifetime {
+		_, _ = rand.Read(c.secret[:])
+		c.secretTime = time.Now()
+	}
+	secret := c.secret
+	c.mu.Unlock()
+	return mac(secret[:], addrBytes(addr))
+}
+
+// createReply returns a cookie reply to a handshake initiation from addr,
+// encrypted to the initiator with MAC1 of the initiation as additional data.
+func (c *cookieChecker) createReply(receiver uint32, mac1 []byte, addr *net.UDPAddr) (*CookieReplyPacket, error) {
+	reply := &CookieReplyPacket{Receiver: receiver}
+	if _, err := rand.Read(reply.Nonce[:]); err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.NewX(c.cookieKey[:])
+	if err != nil {
+		return nil, err
+	}
+	cookie := c.cookie(addr)
+	aead.Seal(reply.EncryptedCookie[:0], reply.Nonce[:], cookie[:], mac1)
+	return reply, nil
+}
+
+// consumeCookieReply decrypts the cookie in a reply from a responder to an
+// initiation with the given MAC1.
+func consumeCookieReply(reply *CookieReplyPacket, responderPublic [32]byte, mac1 [macSize]byte) ([]byte, error) {
+	key := macKey(labelCookie, responderPublic)
+	aead, err := chacha20poly1305.NewX(key[:])
+	if err != nil {
+		return nil, err
+	}
+	cookie, err := aead.Open(nil, reply.Nonce[:], reply.EncryptedCookie[:], mac1[:])
+	if err != nil {
+		return nil, fmt.Errorf("decrypt cookie: %w", err)
+	}
+	return cookie, nil
+}
+
+// HandshakeStats returns the handshake DoS protection counters.
+func (t *Transport) HandshakeStats() HandshakeStats {
+	return HandshakeStats{
+		CookieReplies:    t.cookieReplies.Load(),
+		DroppedMAC1:      t.droppedMAC1.Load(),
+		DroppedUnderLoad: t.droppedUnderLoad.Load(),
+	}
+}
+
+// underLoad records a handshake initiation and reports whether we are under
+// load: the packet queue is backing up or initiations arrive faster than
+// UnderLoadHandshakes per second, now or within the last underLoadGrace.
+func (t *Transport) underLoad() bool {
+	now := time.Now()
+	t.loadMu.Lock()
+	defer t.loadMu.Unlock()
+	if now.Sub(t.initWindow) >= time.Second {
+		t.initWindow = now
+		t.initCount = 0
+	}
+	t.initCount++
+	if t.initCount > t.config.UnderLoadHandshakes || len(t.packetQueue) >= underLoadQueueLen {
+		t.lastUnderLoad = now
+	}
+	return now.Sub(t.lastUnderLoad) < underLoadGrace
+}
+
+// checkHandshakeMACs reports whether a handshake initiation (without its
+// type byte) is worth the Diffie-Hellman of processing it. Under load, it
+// answers initiations without a valid MAC2 with a cookie reply. Initiations
+// without MACs, from peers predating them, are dropped unless
+// AllowHandshakesWithoutMACs is set, and then only accepted when we are not
+// under load.
+func (t *Transport) checkHandshakeMACs(data, macs []byte, remoteAddr *net.UDPAddr, conn *net.UDPConn) bool {
+	if macs == nil && !t.config.AllowHandshakesWithoutMACs {
+		t.droppedMAC1.Add(1)
+		log.Debug().Str("remote", remoteAddr.String()).Msg("dropping handshake init without MACs")
+		return false
+	}
+	if macs != nil && !t.cookies.checkMAC1(data) {
+		t.droppedMAC1.Add(1)
+		log.Debug().Str("remote", remoteAddr.String()).Msg("dropping handshake init with invalid MAC1")
+		return false
+	}
+	if !t.underLoad() {
+		return true
+	}
+	if macs == nil {
+		t.droppedUnderLoad.Add(1)
+		log.Debug().Str("remote", remoteAddr.String()).Msg("dropping handshake init without MACs while under load")
+		return false
+	}
+	if t.cookies.checkMAC2(data, remoteAddr) {
+		return true
+	}
+
+	reply, err := t.cookies.createReply(binary.LittleEndian.Uint32(data[0:4]), macs[:macSize], remoteAddr)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to create cookie reply")
+		return false
+	}
+	if _, err := conn.WriteToUDP(reply.Marshal(), remoteAddr); err != nil {
+		log.Debug().Err(err).Msg("failed to send cookie reply")
+		return false
+	}
+	t.cookieReplies.Add(1)
+	log.Debug().Str("remote", remoteAddr.String()).Msg("under load, sent cookie reply to handshake init")
+	return false
+}
+
+// handleCookieReply routes a cookie reply to the handshake it answers.
+func (t *Transport) handleCookieReply(data []byte, remoteAddr *net.UDPAddr) {
+	reply, err := UnmarshalCookieReply(data)
+	if err != nil {
+		return
+	}
+
+	t.mu.RLock()
+	respChan, ok := t.pendingHandshakes[reply.Receiver]
+	t.mu.RUnlock()
+	if !ok {
+		log.Debug().Uint32("receiver_index", reply.Receiver).Msg("no pending handshake for cookie reply")
+		return
+	}
+
+	select {
+	case respChan <- handshakeResponse{data: data, remoteAddr: remoteAddr, cookie: reply}:
+	default:
+		log.Debug().Uint32("receiver_index", reply.Receiver).Msg("handshake response channel full or closed")
+	}
+}
+
+// peerCookie returns the cookie last received from a peer, or nil if there
+// is none it could still accept.
+func (t *Transport) peerCookie(peerName string) []byte {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	c, ok := t.peerCookies[peerName]
+	if !ok || time.Since(c.received) >= cookieSecretLifetime {
+		return nil
+	}
+	return c.cookie
+}
+
+// receivedCookie is a cookie received from a responder under load.
+type receivedCookie struct {
+	cookie   []byte
+	received time.Time
+}
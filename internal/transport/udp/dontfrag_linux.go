@@ -0,0 +1,112 @@
+//go:build linux
+
+package udp
+
+import (
+	"context"
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// allowFragmentation makes the socket send IPv4 packets without the
+// don't-fragment bit, as WireGuard does, so packets too big for the path
+// are fragmented on the way rather than lost where ICMP is filtered. The
+// IPv4 option also covers IPv4-mapped addresses on dual-stack IPv6 sockets.
+func allowFragmentation(conn *net.UDPConn) error {
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sockErr error
+	err = rc.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, unix.IP_PMTUDISC_DONT)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// listenProbe opens a socket for path MTU probes on the address of conn. It
+// sends packets with the don't-fragment bit set (for IPv6, never fragmented
+// locally), ignoring the kernel's cached path MTU, so probes too big for the
+// path are dropped rather than fragmented. It shares the port of conn, so
+// probes take the same NAT mappings as data packets, and a reuseport
+// program hands every packet arriving on the port to conn, so nothing is
+// ever read from it.
+func listenProbe(conn *net.UDPConn) (*net.UDPConn, error) {
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	network := "udp4"
+	var sockErr error
+	err = rc.Control(func(fd uintptr) {
+		// Set after bind, so other sockets can't take the port, but ours can
+		// join it
+		if sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1); sockErr != nil {
+			return
+		}
+		domain, err := unix.GetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_DOMAIN)
+		if err != nil {
+			sockErr = err
+			return
+		}
+		if domain == unix.AF_INET6 {
+			network = "udp6"
+			if v6only, err := unix.GetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_V6ONLY); err == nil && v6only == 0 {
+				network = "udp"
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sockErr != nil {
+		return nil, sockErr
+	}
+
+	lc := net.ListenConfig{Control: func(network, _ string, c syscall.RawConn) error {
+		var sockErr error
+		err := c.Control(func(fd uintptr) {
+			if sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1); sockErr != nil {
+				return
+			}
+			if network != "udp4" {
+				if sockErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_MTU_DISCOVER, unix.IPV6_PMTUDISC_PROBE); sockErr != nil {
+					return
+				}
+			}
+			sockErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, unix.IP_PMTUDISC_PROBE)
+		})
+		if err != nil {
+			return err
+		}
+		return sockErr
+	}}
+	pc, err := lc.ListenPacket(context.Background(), network, conn.LocalAddr().String())
+	if err != nil {
+		return nil, err
+	}
+	probe := pc.(*net.UDPConn)
+
+	// Steer every packet to the first socket on the port, conn
+	prc, err := probe.SyscallConn()
+	if err == nil {
+		err = prc.Control(func(fd uintptr) {
+			filter := []unix.SockFilter{{Code: unix.BPF_RET | unix.BPF_K, K: 0}}
+			sockErr = unix.SetsockoptSockFprog(int(fd), unix.SOL_SOCKET, unix.SO_ATTACH_REUSEPORT_CBPF,
+				&unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]})
+		})
+	}
+	if err == nil {
+		err = sockErr
+	}
+	if err != nil {
+		_ = probe.Close()
+		return nil, err
+	}
+	return probe, nil
+}
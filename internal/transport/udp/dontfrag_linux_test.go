@@ -0,0 +1,122 @@
+//go:build linux
+
+package udp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// mtuDiscover returns a socket's IPv4 (and for IPv6 sockets, IPv6) path MTU
+// discovery mode.
+func mtuDiscover(t *testing.T, conn *net.UDPConn, network string) (v4, v6 int) {
+	t.Helper()
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var err4, err6 error
+	_ = rc.Control(func(fd uintptr) {
+		v4, err4 = unix.GetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_MTU_DISCOVER)
+		if network == "udp6" {
+			v6, err6 = unix.GetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_MTU_DISCOVER)
+		}
+	})
+	if err4 != nil || err6 != nil {
+		t.Fatal(err4, err6)
+	}
+	return v4, v6
+}
+
+func TestAllowFragmentation(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		t.Skipf("no udp4 socket: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	if err := allowFragmentation(conn); err != nil {
+		t.Fatal(err)
+	}
+	if v4, _ := mtuDiscover(t, conn, "udp4"); v4 != unix.IP_PMTUDISC_DONT {
+		t.Errorf("IP_MTU_DISCOVER = %d, want %d", v4, unix.IP_PMTUDISC_DONT)
+	}
+}
+
+func TestListenProbe(t *testing.T) {
+	for _, tc := range []struct{ network, loopback string }{
+		{"udp4", "127.0.0.1:0"},
+		{"udp6", "[::1]:0"},
+	} {
+		t.Run(tc.network, func(t *testing.T) {
+			laddr, err := net.ResolveUDPAddr(tc.network, tc.loopback)
+			if err != nil {
+				t.Fatal(err)
+			}
+			conn, err := net.ListenUDP(tc.network, laddr)
+			if err != nil {
+				t.Skipf("no %s socket: %v", tc.network, err)
+			}
+			defer func() { _ = conn.Close() }()
+
+			probe, err := listenProbe(conn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = probe.Close() }()
+			if probe.LocalAddr().String() != conn.LocalAddr().String() {
+				t.Errorf("probe socket on %s, want %s", probe.LocalAddr(), conn.LocalAddr())
+			}
+
+			v4, v6 := mtuDiscover(t, probe, tc.network)
+			if v4 != unix.IP_PMTUDISC_PROBE {
+				t.Errorf("IP_MTU_DISCOVER = %d, want %d", v4, unix.IP_PMTUDISC_PROBE)
+			}
+			if tc.network == "udp6" && v6 != unix.IPV6_PMTUDISC_PROBE {
+				t.Errorf("IPV6_MTU_DISCOVER = %d, want %d", v6, unix.IPV6_PMTUDISC_PROBE)
+			}
+
+			// Other sockets still can't take the port
+			if other, err := net.ListenUDP(tc.network, conn.LocalAddr().(*net.UDPAddr)); err == nil {
+				_ = other.Close()
+				t.Error("another socket bound the port")
+			}
+
+			// Everything arriving on the port, probe replies included, is
+			// read from conn
+			sender, err := net.ListenUDP(tc.network, &net.UDPAddr{IP: laddr.IP})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = sender.Close() }()
+			const packets = 20
+			for range packets {
+				if _, err := sender.WriteToUDP([]byte("x"), conn.LocalAddr().(*net.UDPAddr)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+			buf := make([]byte, 16)
+			for i := range packets {
+				if _, _, err := conn.ReadFromUDP(buf); err != nil {
+					t.Fatalf("packet %d not read from conn: %v", i, err)
+				}
+			}
+
+			// Probes go out from the shared port
+			if _, err := probe.WriteToUDP([]byte("probe"), sender.LocalAddr().(*net.UDPAddr)); err != nil {
+				t.Fatal(err)
+			}
+			_ = sender.SetReadDeadline(time.Now().Add(time.Second))
+			_, from, err := sender.ReadFromUDP(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if from.Port != conn.LocalAddr().(*net.UDPAddr).Port {
+				t.Errorf("probe sent from port %d, want %d", from.Port, conn.LocalAddr().(*net.UDPAddr).Port)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package udp
+
+import "net"
+
+// allowFragmentation is a no-op where sockets keep the system's default.
+func allowFragmentation(*net.UDPConn) error {
+	return nil
+}
+
+// listenProbe returns no probe socket where the don't-fragment bit isn't
+// set: probes go out on the data socket and may be fragmented.
+func listenProbe(*net.UDPConn) (*net.UDPConn, error) {
+	return nil, nil
+}
@@ -61,6 +61,9 @@ type HandshakeState struct {
 	// Timestamp for replay protection
 	timestamp [12]byte
 
+	// Pre-shared key mixed in after the responder's ephemeral (zero if unset)
+	psk [32]byte
+
 	// Role tracking
 	isInitiator bool
 }
@@ -319,10 +322,9 @@ func (hs *HandshakeState) CreateResponse() ([]byte, error) {
 	}
 	mixKeyStatic(&hs.chainingKey, &hs.chainingKey, se)
 
-	// KDF3 for PSK mixing (we use empty PSK) - get tau, key
+	// KDF3 for PSK mixing - get tau, key
 	var tau, key [32]byte
-	var psk [32]byte // Zero PSK
-	KDF3(&hs.chainingKey, &tau, &key, hs.chainingKey[:], psk[:])
+	KDF3(&hs.chainingKey, &tau, &key, hs.chainingKey[:], hs.psk[:])
 	mixHashStatic(&hs.hash, &hs.hash, tau[:])
 
 	// Encrypt empty payload
@@ -373,10 +375,9 @@ func (hs *HandshakeState) ConsumeResponse(msg []byte) error {
 	}
 	mixKeyStatic(&hs.chainingKey, &hs.chainingKey, es)
 
-	// KDF3 for PSK mixing (we use empty PSK) - get tau, key
+	// KDF3 for PSK mixing - get tau, key
 	var tau, key [32]byte
-	var psk [32]byte // Zero PSK
-	KDF3(&hs.chainingKey, &tau, &key, hs.chainingKey[:], psk[:])
+	KDF3(&hs.chainingKey, &tau, &key, hs.chainingKey[:], hs.psk[:])
 	mixHashStatic(&hs.hash, &hs.hash, tau[:])
 
 	// Decrypt empty payload (verify)
@@ -399,6 +400,13 @@ func (hs *HandshakeState) ConsumeResponse(msg []byte) error {
 	return nil
 }
 
+// SetPSK sets the pre-shared key mixed into the handshake. Both sides must
+// use the same key or the response fails to decrypt. It must be set before
+// CreateResponse or ConsumeResponse.
+func (hs *HandshakeState) SetPSK(psk [32]byte) {
+	hs.psk = psk
+}
+
 // DeriveKeys derives the final transport keys after handshake completion.
 func (hs *HandshakeState) DeriveKeys() (sendKey, recvKey [32]byte, err error) {
 	// Final key derivation using KDF2 (like WireGuard)
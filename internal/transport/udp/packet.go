@@ -16,6 +16,9 @@ const (
 	PacketTypeRekeyRequired     = 0x06 // Sent when session not found, tells peer to re-handshake
 	PacketTypePing              = 0x07 // Latency probe request
 	PacketTypePong              = 0x08 // Latency probe response
+	PacketTypeCookieReply       = 0x09 // Sent instead of a handshake response when under load
+	PacketTypeMTUProbe          = 0x0A // Padded path MTU probe
+	PacketTypeMTUProbeAck       = 0x0B // Acknowledges an MTU probe that got through
 )
 
 // Packet sizes
@@ -164,6 +167,41 @@ func UnmarshalRekeyRequired(data []byte) (*RekeyRequiredPacket, error) {
 	}, nil
 }
 
+// CookieReplySize is the size of a cookie reply packet.
+// Format: [1 type][4 receiver][24 nonce][32 encrypted cookie]
+const CookieReplySize = 61
+
+// CookieReplyPacket is sent instead of a handshake response by a responder
+// under load, for initiations without a valid MAC2. It carries a cookie
+// bound to the initiator's address, encrypted to the initiator, which must
+// compute MAC2 with it when retrying.
+type CookieReplyPacket struct {
+	Receiver        uint32   // Initiator's session index (from the init)
+	Nonce           [24]byte // XChaCha20-Poly1305 nonce
+	EncryptedCookie [32]byte // AEAD(cookie) with the init's MAC1 as additional data
+}
+
+// Marshal serializes the cookie reply packet.
+func (p *CookieReplyPacket) Marshal() []byte {
+	buf := make([]byte, CookieReplySize)
+	buf[0] = PacketTypeCookieReply
+	binary.BigEndian.PutUint32(buf[1:5], p.Receiver)
+	copy(buf[5:29], p.Nonce[:])
+	copy(buf[29:61], p.EncryptedCookie[:])
+	return buf
+}
+
+// UnmarshalCookieReply parses a cookie reply packet from bytes.
+func UnmarshalCookieReply(data []byte) (*CookieReplyPacket, error) {
+	if len(data) < CookieReplySize {
+		return nil, fmt.Errorf("cookie reply too short: %d < %d", len(data), CookieReplySize)
+	}
+	p := &CookieReplyPacket{Receiver: binary.BigEndian.Uint32(data[1:5])}
+	copy(p.Nonce[:], data[5:29])
+	copy(p.EncryptedCookie[:], data[29:61])
+	return p, nil
+}
+
 // PingPacketSize is the size of a ping/pong packet.
 // Format: [1 type][4 receiver][8 timestamp_nanos]
 const PingPacketSize = 13
@@ -236,3 +274,69 @@ func UnmarshalPong(data []byte) (*PongPacket, error) {
 		Timestamp: int64(binary.BigEndian.Uint64(data[5:13])),
 	}, nil
 }
+
+// MTUProbeHeaderSize is the size of an MTU probe before its padding.
+// Format: [1 type][4 receiver][8 token][padding]
+const MTUProbeHeaderSize = 13
+
+// MTUProbePacket is padded to the size being probed. The peer acknowledges
+// probes that get through with the size it received.
+type MTUProbePacket struct {
+	Receiver uint32 // Receiver's session index
+	Token    uint64 // Random token echoed in the ack
+	Size     int    // Total packet size, including padding
+}
+
+// Marshal serializes the MTU probe, padded with zeros to its size.
+func (p *MTUProbePacket) Marshal() []byte {
+	buf := make([]byte, max(p.Size, MTUProbeHeaderSize))
+	buf[0] = PacketTypeMTUProbe
+	binary.BigEndian.PutUint32(buf[1:5], p.Receiver)
+	binary.BigEndian.PutUint64(buf[5:13], p.Token)
+	return buf
+}
+
+// UnmarshalMTUProbe parses an MTU probe from bytes.
+func UnmarshalMTUProbe(data []byte) (*MTUProbePacket, error) {
+	if len(data) < MTUProbeHeaderSize {
+		return nil, fmt.Errorf("MTU probe too short: %d < %d", len(data), MTUProbeHeaderSize)
+	}
+	return &MTUProbePacket{
+		Receiver: binary.BigEndian.Uint32(data[1:5]),
+		Token:    binary.BigEndian.Uint64(data[5:13]),
+		Size:     len(data),
+	}, nil
+}
+
+// MTUProbeAckSize is the size of an MTU probe ack.
+// Format: [1 type][4 receiver][8 token][2 probe size]
+const MTUProbeAckSize = 15
+
+// MTUProbeAckPacket acknowledges an MTU probe.
+type MTUProbeAckPacket struct {
+	Receiver uint32 // Receiver's session index (the prober)
+	Token    uint64 // Token from the probe
+	Size     int    // Size of the probe received
+}
+
+// Marshal serializes the MTU probe ack.
+func (p *MTUProbeAckPacket) Marshal() []byte {
+	buf := make([]byte, MTUProbeAckSize)
+	buf[0] = PacketTypeMTUProbeAck
+	binary.BigEndian.PutUint32(buf[1:5], p.Receiver)
+	binary.BigEndian.PutUint64(buf[5:13], p.Token)
+	binary.BigEndian.PutUint16(buf[13:15], uint16(p.Size))
+	return buf
+}
+
+// UnmarshalMTUProbeAck parses an MTU probe ack from bytes.
+func UnmarshalMTUProbeAck(data []byte) (*MTUProbeAckPacket, error) {
+	if len(data) < MTUProbeAckSize {
+		return nil, fmt.Errorf("MTU probe ack too short: %d < %d", len(data), MTUProbeAckSize)
+	}
+	return &MTUProbeAckPacket{
+		Receiver: binary.BigEndian.Uint32(data[1:5]),
+		Token:    binary.BigEndian.Uint64(data[5:13]),
+		Size:     int(binary.BigEndian.Uint16(data[13:15])),
+	}, nil
+}
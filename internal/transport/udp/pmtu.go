@@ -0,0 +1,299 @@
+package udp
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"net"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Path MTU discovery, in the style of DPLPMTUD (RFC 8899): each session
+// probes its path with padded probe packets the peer acknowledges, and
+// searches for the largest that gets through. This works where ICMP is
+// filtered, as on many PPPoE, cellular and nested VPN links. Probes rely on
+// the don't-fragment bit, so a probe too big for the path is dropped rather
+// than fragmented. Linux only sets it on UDP packets that fit the cached
+// path MTU, fragmenting larger ones locally, so probes go out through a
+// socket of their own that sets it on every packet (see listenProbe), and
+// data packets without it, so those that don't fit are fragmented rather
+// than lost. Elsewhere probes may be fragmented and discovery finds the
+// largest size probed.
+// Peers predating probes never acknowledge them, which leaves the MTU of
+// their sessions unknown (0), as it is until the first search completes.
+const (
+	// minPathMTU is the smallest path MTU probed, the IPv6 minimum. If a
+	// probe this size doesn't get through, the path MTU stays unknown.
+	minPathMTU = 1280
+
+	// maxPathMTU is the largest path MTU probed.
+	maxPathMTU = 1500
+
+	// pmtuSearchStep ends a search once the largest size known to get
+	// through and the smallest known not to are this close.
+	pmtuSearchStep = 8
+
+	// pmtuProbeTimeout is how long to wait for a probe's ack.
+	pmtuProbeTimeout = time.Second
+
+	// pmtuMaxProbes is how many probes of a size are sent before deciding
+	// it doesn't get through.
+	pmtuMaxProbes = 3
+
+	// pmtuConfirmInterval is how often the discovered MTU is probed again,
+	// to notice the path shrinking, e.g. after roaming.
+	pmtuConfirmInterval = time.Minute
+
+	// pmtuSearchInterval is how often the search is repeated, to notice the
+	// path growing.
+	pmtuSearchInterval = 10 * time.Minute
+
+	// udpHeaderSize is the size of a UDP header.
+	udpHeaderSize = 8
+)
+
+// ipHeaderSize returns the size of the IP header of packets to addr.
+func ipHeaderSize(addr *net.UDPAddr) int {
+	if addr != nil && addr.IP.To4() != nil {
+		return 20
+	}
+	return 40
+}
+
+// listenProbe lets a socket's data packets be fragmented and opens the
+// socket path MTU probes are sent from on its port. Returns nil if probes go
+// out on the socket itself, in which case they may be fragmented and the
+// path MTU found too high.
+func (t *Transport) listenProbe(conn *net.UDPConn) *net.UDPConn {
+	if err := allowFragmentation(conn); err != nil {
+		log.Warn().Err(err).Str("addr", conn.LocalAddr().String()).Msg("failed to allow fragmentation on UDP socket")
+	}
+	probe, err := listenProbe(conn)
+	if err != nil {
+		log.Warn().Err(err).Str("addr", conn.LocalAddr().String()).Msg("failed to open UDP socket for path MTU probes")
+	}
+	return probe
+}
+
+// probeConnFor returns the socket to send path MTU probes for a session on
+// conn from.
+func (t *Transport) probeConnFor(conn *net.UDPConn) *net.UDPConn {
+	switch {
+	case conn == t.conn && t.probeConn != nil:
+		return t.probeConn
+	case conn == t.conn6 && t.probeConn6 != nil:
+		return t.probeConn6
+	}
+	return conn
+}
+
+// PathMTU returns the discovered path MTU, or 0 if it is not known yet.
+func (s *Session) PathMTU() int {
+	return int(s.pathMTU.Load())
+}
+
+// setPathMTU records the discovered path MTU.
+func (s *Session) setPathMTU(mtu int) {
+	if old := int(s.pathMTU.Swap(int32(mtu))); old != mtu {
+		log.Debug().
+			Str("peer", s.peerName).
+			Int("old_mtu", old).
+			Int("mtu", mtu).
+			Msg("path MTU changed")
+	}
+}
+
+// MaxWriteSize returns the largest data packet sent without fragmentation
+// on the path, or 0 if the path MTU is not known yet.
+func (s *Session) MaxWriteSize() int {
+	mtu := s.PathMTU()
+	if mtu == 0 {
+		return 0
+	}
+	return mtu - ipHeaderSize(s.RemoteAddr()) - udpHeaderSize - HeaderSize - AuthTagSize
+}
+
+// sendMTUProbe sends a probe padded to a path MTU from conn.
+func (s *Session) sendMTUProbe(conn *net.UDPConn, mtu int, token uint64) error {
+	s.mu.RLock()
+	if s.state != SessionStateEstablished {
+		s.mu.RUnlock()
+		return ErrSessionNotEstablished
+	}
+	remoteIndex := s.remoteIndex
+	remoteAddr := s.remoteAddr
+	s.mu.RUnlock()
+
+	pkt := &MTUProbePacket{
+		Receiver: remoteIndex,
+		Token:    token,
+		Size:     mtu - ipHeaderSize(remoteAddr) - udpHeaderSize,
+	}
+	_, err := conn.WriteToUDP(pkt.Marshal(), remoteAddr)
+	return err
+}
+
+// SendMTUProbeAck acknowledges an MTU probe.
+func (s *Session) SendMTUProbeAck(probe *MTUProbePacket) error {
+	s.mu.RLock()
+	if s.state != SessionStateEstablished {
+		s.mu.RUnlock()
+		return ErrSessionNotEstablished
+	}
+	remoteIndex := s.remoteIndex
+	remoteAddr := s.remoteAddr
+	s.mu.RUnlock()
+
+	pkt := &MTUProbeAckPacket{Receiver: remoteIndex, Token: probe.Token, Size: probe.Size}
+	_, err := s.conn.WriteToUDP(pkt.Marshal(), remoteAddr)
+	return err
+}
+
+// MaxWriteSize returns the largest write sent as one unfragmented packet,
+// or 0 if the path MTU is not known yet.
+func (c *Connection) MaxWriteSize() int {
+	return c.session.MaxWriteSize()
+}
+
+// PathMTUs returns the discovered path MTU of the session with each peer,
+// for those where it is known.
+func (t *Transport) PathMTUs() map[string]int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	mtus := make(map[string]int)
+	for peerName, s := range t.peerSessions {
+		if mtu := s.PathMTU(); mtu > 0 {
+			mtus[peerName] = mtu
+		}
+	}
+	return mtus
+}
+
+// handleMTUProbe acknowledges an MTU probe.
+func (t *Transport) handleMTUProbe(data []byte, remoteAddr *net.UDPAddr) {
+	probe, err := UnmarshalMTUProbe(data)
+	if err != nil {
+		return
+	}
+
+	t.mu.RLock()
+	session, ok := t.sessions[probe.Receiver]
+	t.mu.RUnlock()
+	if !ok {
+		log.Debug().
+			Uint32("receiver_index", probe.Receiver).
+			Str("from", remoteAddr.String()).
+			Msg("MTU probe for unknown session")
+		return
+	}
+
+	if err := session.SendMTUProbeAck(probe); err != nil {
+		log.Debug().Err(err).Str("peer", session.PeerName()).Msg("failed to send MTU probe ack")
+	}
+}
+
+// handleMTUProbeAck hands an MTU probe ack to the session's prober.
+func (t *Transport) handleMTUProbeAck(data []byte) {
+	ack, err := UnmarshalMTUProbeAck(data)
+	if err != nil {
+		return
+	}
+
+	t.mu.RLock()
+	session, ok := t.sessions[ack.Receiver]
+	t.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	select {
+	case session.probeAcks <- ack:
+	default: // Stale ack while another is pending
+	}
+}
+
+// discoverPMTU runs path MTU discovery for a session until it is closed.
+func (t *Transport) discoverPMTU(s *Session) {
+	ticker := time.NewTicker(pmtuConfirmInterval)
+	defer ticker.Stop()
+
+	var lastSearch time.Time
+	for {
+		mtu := s.PathMTU()
+		switch {
+		case mtu == 0 || time.Since(lastSearch) >= pmtuSearchInterval:
+			s.setPathMTU(t.searchPMTU(s))
+			lastSearch = time.Now()
+		case !t.probePMTU(s, mtu):
+			log.Debug().Str("peer", s.PeerName()).Int("mtu", mtu).Msg("path MTU no longer fits, searching again")
+			s.setPathMTU(t.searchPMTU(s))
+			lastSearch = time.Now()
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.closeCh:
+			return
+		case <-t.closeCh:
+			return
+		}
+	}
+}
+
+// searchPMTU returns the largest path MTU a probe gets through at, or 0 if
+// even minPathMTU doesn't.
+func (t *Transport) searchPMTU(s *Session) int {
+	if t.probePMTU(s, maxPathMTU) {
+		return maxPathMTU
+	}
+	if !t.probePMTU(s, minPathMTU) {
+		return 0
+	}
+	lo, hi := minPathMTU, maxPathMTU
+	for hi-lo > pmtuSearchStep {
+		mid := (lo + hi) / 2
+		if t.probePMTU(s, mid) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+// probePMTU reports whether a probe padded to a path MTU gets through.
+func (t *Transport) probePMTU(s *Session, mtu int) bool {
+	conn := t.probeConnFor(s.conn)
+	for range pmtuMaxProbes {
+		var b [8]byte
+		_, _ = rand.Read(b[:])
+		token := binary.BigEndian.Uint64(b[:])
+		if err := s.sendMTUProbe(conn, mtu, token); err != nil {
+			// Too big for the local interface, or the session is gone
+			return false
+		}
+
+		timer := time.NewTimer(pmtuProbeTimeout)
+	wait:
+		for {
+			select {
+			case ack := <-s.probeAcks:
+				if ack.Token != token {
+					continue // Ack of an earlier probe
+				}
+				timer.Stop()
+				return ack.Size == mtu-ipHeaderSize(s.RemoteAddr())-udpHeaderSize
+			case <-timer.C:
+				break wait
+			case <-s.closeCh:
+				timer.Stop()
+				return false
+			case <-t.closeCh:
+				timer.Stop()
+				return false
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package udp
+
+import (
+	"encoding/binary"
+)
+
+// pskEpochSize is the size of the PSK epoch appended to handshake initiations
+// by initiators using a pre-shared key. Responders predating PSK support
+// ignore it, since they only read the first 128 bytes.
+const pskEpochSize = 4
+
+// PSK handshake failure reasons, passed to the PSK failure callback.
+const (
+	PSKFailureMissing      = "missing"       // Initiator sent no PSK but we require one
+	PSKFailureUnexpected   = "unexpected"    // Initiator sent a PSK but we have none
+	PSKFailureUnknownEpoch = "unknown_epoch" // Initiator used an epoch we don't hold keys for
+	PSKFailureMismatch     = "mismatch"      // Responder used a different key (response failed to decrypt)
+)
+
+// PSKSet holds the pre-shared keys of one rotation epoch.
+type PSKSet struct {
+	Epoch uint32
+	Mesh  [32]byte            // Used with peers without a pair key
+	Pairs map[string][32]byte // Peer name -> key shared with that peer only
+}
+
+// key returns the pre-shared key to use with a peer.
+func (s *PSKSet) key(peerName string) [32]byte {
+	if key, ok := s.Pairs[peerName]; ok {
+		return key
+	}
+	return s.Mesh
+}
+
+// SetPSKs replaces the pre-shared keys mixed into handshakes. Handshakes we
+// initiate use the keys of the current epoch; incoming handshakes may use
+// any epoch in sets, which lets peers rotate at slightly different times.
+// Passing no sets disables PSKs.
+func (t *Transport) SetPSKs(current uint32, sets []PSKSet) {
+	psks := make(map[uint32]*PSKSet, len(sets))
+	for i := range sets {
+		psks[sets[i].Epoch] = &sets[i]
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.psks = psks
+	t.pskCurrent = current
+}
+
+// SetPSKFailureCallback sets the callback for handshakes that fail because
+// of the pre-shared key, with the peer name and one of the PSKFailure reasons.
+func (t *Transport) SetPSKFailureCallback(cb func(peerName, reason string)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onPSKFailure = cb
+}
+
+// initiatorPSK returns the pre-shared key and epoch to initiate a handshake
+// with a peer, or false if PSKs are disabled.
+func (t *Transport) initiatorPSK(peerName string) ([32]byte, uint32, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	set, ok := t.psks[t.pskCurrent]
+	if !ok {
+		return [32]byte{}, 0, false
+	}
+	return set.key(peerName), set.Epoch, true
+}
+
+// responderPSK returns the pre-shared key for a handshake initiation from a
+// peer, given the bytes following the Noise message. Returns a PSKFailure
+// reason if the initiation doesn't match our PSK configuration.
+func (t *Transport) responderPSK(peerName string, trailer []byte) ([32]byte, string) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if len(trailer) < pskEpochSize {
+		if len(t.psks) > 0 {
+			return [32]byte{}, PSKFailureMissing
+		}
+		return [32]byte{}, ""
+	}
+	if len(t.psks) == 0 {
+		return [32]byte{}, PSKFailureUnexpected
+	}
+	set, ok := t.psks[binary.BigEndian.Uint32(trailer)]
+	if !ok {
+		return [32]byte{}, PSKFailureUnknownEpoch
+	}
+	return set.key(peerName), ""
+}
+
+// pskFailure reports a handshake that failed because of the pre-shared key.
+func (t *Transport) pskFailure(peerName, reason string) {
+	t.mu.RLock()
+	cb := t.onPSKFailure
+	t.mu.RUnlock()
+	if cb != nil {
+		cb(peerName, reason)
+	}
+}
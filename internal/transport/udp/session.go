@@ -48,6 +48,10 @@ type Session struct {
 	packetsIn   atomic.Uint64
 	packetsOut  atomic.Uint64
 
+	// Path MTU discovery
+	pathMTU   atomic.Int32            // Confirmed path MTU, 0 until discovery completes
+	probeAcks chan *MTUProbeAckPacket // Acks for the probe in flight
+
 	// Channels
 	recvChan chan []byte // Decrypted data packets
 	closeCh  chan struct{}
@@ -86,6 +90,7 @@ func NewSession(cfg SessionConfig) *Session {
 		state:      SessionStateNew,
 		recvChan:   make(chan []byte, cfg.RecvBufSize),
 		closeCh:    make(chan struct{}),
+		probeAcks:  make(chan *MTUProbeAckPacket, 1),
 	}
 }
 
@@ -396,6 +401,7 @@ func (s *Session) Stats() SessionStats {
 		Established: s.established,
 		LastSend:    s.lastSend,
 		LastRecv:    s.lastRecv,
+		PathMTU:     s.PathMTU(),
 	}
 }
 
@@ -408,6 +414,7 @@ type SessionStats struct {
 	Established time.Time
 	LastSend    time.Time
 	LastRecv    time.Time
+	PathMTU     int // Discovered path MTU, 0 until discovery completes
 }
 
 // Errors
@@ -38,6 +38,7 @@ type packetWork struct {
 type handshakeResponse struct {
 	data       []byte
 	remoteAddr *net.UDPAddr
+	cookie     *CookieReplyPacket // Set for a cookie reply instead of a response
 }
 
 // Transport implements encrypted UDP transport.
@@ -48,9 +49,11 @@ type Transport struct {
 	config Config
 
 	// Network - dual-stack support with separate IPv4 and IPv6 sockets
-	conn     *net.UDPConn // IPv4 socket (or single socket if dual-stack disabled)
-	conn6    *net.UDPConn // IPv6 socket (nil if not available)
-	listener *Listener
+	conn       *net.UDPConn // IPv4 socket (or single socket if dual-stack disabled)
+	conn6      *net.UDPConn // IPv6 socket (nil if not available)
+	probeConn  *net.UDPConn // Path MTU probe socket on the port of conn (nil if none)
+	probeConn6 *net.UDPConn // Path MTU probe socket on the port of conn6 (nil if none)
+	listener   *Listener
 
 	// Identity
 	staticPrivate [32]byte
@@ -87,8 +90,31 @@ type Transport struct {
 	// Callback for pong responses (used for latency measurement)
 	onPong func(peerName string, rtt time.Duration)
 
+	// Pre-shared keys mixed into handshakes (epoch -> keys, empty if disabled)
+	psks       map[uint32]*PSKSet
+	pskCurrent uint32 // Epoch used for handshakes we initiate
+
+	// Callback for handshakes failing because of the pre-shared key
+	onPSKFailure func(peerName, reason string)
+
+	// Handshake DoS protection
+	cookies     *cookieChecker            // Verifies MACs and issues cookies as responder
+	peerCookies map[string]receivedCookie // Peer name -> cookie from its last cookie reply
+
+	// Under-load detection (protected by loadMu)
+	loadMu        sync.Mutex
+	initWindow    time.Time // Start of the current one-second window
+	initCount     int       // Handshake initiations received in the window
+	lastUnderLoad time.Time
+
+	// Handshake DoS protection counters
+	cookieReplies    atomic.Uint64
+	droppedMAC1      atomic.Uint64
+	droppedUnderLoad atomic.Uint64
+
 	// Worker pool for packet processing (avoids per-packet goroutine spawning)
 	packetQueue chan packetWork
+	receivers   sync.WaitGroup // Receive loops feeding packetQueue
 
 	// State
 	running atomic.Bool
@@ -121,6 +147,11 @@ type Config struct {
 	// AuthToken is the shared auth token for coordination server endpoints
 	AuthToken string
 
+	// AuthTokenFunc, if set, returns the token for coordination server
+	// endpoints in place of AuthToken, so the peer's JWT token can be used
+	// as it is renewed
+	AuthTokenFunc func() string
+
 	// JWTToken for authenticating with relay (not used for hole-punch)
 	JWTToken string
 
@@ -146,6 +177,18 @@ type Config struct {
 	// HolePunchRetries is the number of hole-punch attempts
 	HolePunchRetries int
 
+	// UnderLoadHandshakes is the rate of handshake initiations per second
+	// above which initiators must prove they own their address with a
+	// cookie before we do any Diffie-Hellman for them.
+	// Default: DefaultUnderLoadHandshakes
+	UnderLoadHandshakes int
+
+	// AllowHandshakesWithoutMACs accepts handshake initiations without
+	// MACs, from peers predating them, when we are not under load. They
+	// skip the MAC1 check, so anyone can make us do Diffie-Hellman until
+	// the load check kicks in. Default: false
+	AllowHandshakesWithoutMACs bool
+
 	// EnablePortMapping enables PCP/NAT-PMP/UPnP port mapping.
 	// When enabled, the transport will attempt to create a port mapping
 	// on the gateway for better NAT traversal. Default: true (opportunistic)
@@ -160,12 +203,13 @@ type Config struct {
 // DefaultConfig returns sensible defaults.
 func DefaultConfig() Config {
 	return Config{
-		Port:              51820,
-		KeepaliveInterval: 25 * time.Second,
-		HandshakeTimeout:  10 * time.Second,
-		HolePunchTimeout:  10 * time.Second,
-		SessionTimeout:    75 * time.Second,
-		HolePunchRetries:  5,
+		Port:                51820,
+		KeepaliveInterval:   25 * time.Second,
+		HandshakeTimeout:    10 * time.Second,
+		HolePunchTimeout:    10 * time.Second,
+		SessionTimeout:      75 * time.Second,
+		HolePunchRetries:    5,
+		UnderLoadHandshakes: DefaultUnderLoadHandshakes,
 	}
 }
 
@@ -186,6 +230,9 @@ func New(cfg Config) (*Transport, error) {
 	if cfg.SessionTimeout == 0 {
 		cfg.SessionTimeout = 75 * time.Second
 	}
+	if cfg.UnderLoadHandshakes == 0 {
+		cfg.UnderLoadHandshakes = DefaultUnderLoadHandshakes
+	}
 
 	// Use provided HTTP client or create a new one with sensible defaults
 	httpClient := cfg.HTTPClient
@@ -204,6 +251,8 @@ func New(cfg Config) (*Transport, error) {
 		pendingHandshakes:    make(map[uint32]chan handshakeResponse),
 		pendingOutboundPeers: make(map[string]uint32),
 		rekeyRateLimit:       make(map[string]time.Time),
+		cookies:              newCookieChecker(cfg.StaticPublic),
+		peerCookies:          make(map[string]receivedCookie),
 		closeCh:              make(chan struct{}),
 		httpClient:           httpClient,
 	}
@@ -375,6 +424,7 @@ func (t *Transport) Start() error {
 			return fmt.Errorf("listen UDP: %w", err)
 		}
 		t.conn = conn
+		t.probeConn = t.listenProbe(conn)
 	} else {
 		// Create IPv4 socket
 		udpAddr4 := &net.UDPAddr{IP: net.IPv4zero, Port: port}
@@ -383,6 +433,7 @@ func (t *Transport) Start() error {
 			log.Warn().Err(err).Msg("failed to create IPv4 UDP socket")
 		} else {
 			t.conn = conn4
+			t.probeConn = t.listenProbe(conn4)
 			log.Debug().Str("addr", conn4.LocalAddr().String()).Msg("IPv4 UDP socket created")
 		}
 
@@ -393,6 +444,7 @@ func (t *Transport) Start() error {
 			log.Warn().Err(err).Msg("failed to create IPv6 UDP socket")
 		} else {
 			t.conn6 = conn6
+			t.probeConn6 = t.listenProbe(conn6)
 			log.Debug().Str("addr", conn6.LocalAddr().String()).Msg("IPv6 UDP socket created")
 		}
 
@@ -414,10 +466,10 @@ func (t *Transport) Start() error {
 
 	// Start packet receivers for each socket
 	if t.conn != nil {
-		go t.receiveLoop(t.conn)
+		t.receivers.Go(func() { t.receiveLoop(t.conn) })
 	}
 	if t.conn6 != nil {
-		go t.receiveLoop(t.conn6)
+		t.receivers.Go(func() { t.receiveLoop(t.conn6) })
 	}
 
 	// Start keepalive sender
@@ -553,6 +605,12 @@ func (t *Transport) handlePacket(data []byte, remoteAddr *net.UDPAddr, conn *net
 		t.handlePing(data, remoteAddr)
 	case PacketTypePong:
 		t.handlePong(data, remoteAddr)
+	case PacketTypeCookieReply:
+		t.handleCookieReply(data, remoteAddr)
+	case PacketTypeMTUProbe:
+		t.handleMTUProbe(data, remoteAddr)
+	case PacketTypeMTUProbeAck:
+		t.handleMTUProbeAck(data)
 	}
 }
 
@@ -806,6 +864,12 @@ func (t *Transport) handleHandshakeInit(data []byte, remoteAddr *net.UDPAddr, co
 		return
 	}
 
+	// Check the MACs before doing any Diffie-Hellman
+	msg, trailer, macs := splitHandshakeInit(data)
+	if !t.checkHandshakeMACs(data, macs, remoteAddr, conn) {
+		return
+	}
+
 	// Create responder handshake state
 	// Responder doesn't know initiator's static key until they decrypt it from the message
 	hs, err := NewResponderHandshake(t.staticPrivate, t.staticPublic)
@@ -814,7 +878,7 @@ func (t *Transport) handleHandshakeInit(data []byte, remoteAddr *net.UDPAddr, co
 		return
 	}
 
-	if err := hs.ConsumeInitiation(data); err != nil {
+	if err := hs.ConsumeInitiation(msg); err != nil {
 		log.Debug().Err(err).Msg("failed to consume initiation")
 		return
 	}
@@ -835,6 +899,20 @@ func (t *Transport) handleHandshakeInit(data []byte, remoteAddr *net.UDPAddr, co
 		return
 	}
 
+	// The PSK only affects the response, so a wrong key can't be detected
+	// here, but a missing one or an unknown epoch can.
+	psk, reason := t.responderPSK(peerName, trailer)
+	if reason != "" {
+		log.Warn().
+			Str("peer", peerName).
+			Str("remote", remoteAddr.String()).
+			Str("reason", reason).
+			Msg("rejecting handshake: PSK mismatch")
+		t.pskFailure(peerName, reason)
+		return
+	}
+	hs.SetPSK(psk)
+
 	// Check for crossing handshakes: if we have a pending outbound handshake to this peer,
 	// use public key comparison as tie-breaker. The node with the "lower" public key wins
 	// (its outgoing handshake is kept). This ensures both sides agree on which handshake to use.
@@ -957,9 +1035,9 @@ func (t *Transport) handleHandshakeInit(data []byte, remoteAddr *net.UDPAddr, co
 			session: session,
 			readBuf: new(bytes.Buffer),
 		}
-		if t.listener.trySend(conn) {
+		if t.listener.queue.TrySend(conn) {
 			log.Debug().Str("peer", peerName).Msg("incoming connection queued for accept")
-		} else if !t.listener.closed.Load() {
+		} else if !t.listener.queue.Closed() {
 			log.Warn().Str("peer", peerName).Msg("listener accept channel full, dropping connection")
 		}
 	}
@@ -1238,7 +1316,7 @@ func (t *Transport) initiateHandshake(ctx context.Context, peerName string, peer
 	}
 
 	// Register pending handshake before sending
-	respChan := make(chan handshakeResponse, 1)
+	respChan := make(chan handshakeResponse, 2) // Room for a cookie reply and the response
 	localIndex := hs.LocalIndex()
 
 	t.mu.Lock()
@@ -1254,10 +1332,17 @@ func (t *Transport) initiateHandshake(ctx context.Context, peerName string, peer
 		t.mu.Unlock()
 	}()
 
-	// Prepend packet type header
-	packet := make([]byte, 1+len(initMsg))
+	// Prepend packet type header, and append the PSK epoch if we use one
+	psk, epoch, usePSK := t.initiatorPSK(peerName)
+	packet := make([]byte, 1+len(initMsg), 1+len(initMsg)+pskEpochSize+handshakeMACsSize)
 	packet[0] = PacketTypeHandshakeInit
 	copy(packet[1:], initMsg)
+	if usePSK {
+		hs.SetPSK(psk)
+		packet = binary.BigEndian.AppendUint32(packet, epoch)
+	}
+	unsigned := packet
+	packet, mac1 := appendHandshakeMACs(unsigned, peerPublic, t.peerCookie(peerName))
 
 	// Send initiation
 	if _, err := conn.WriteToUDP(packet, peerAddr); err != nil {
@@ -1273,21 +1358,55 @@ func (t *Transport) initiateHandshake(ctx context.Context, peerName string, peer
 	}
 
 	// Wait for response via channel (routed by receiveLoop)
-	select {
-	case <-ctx.Done():
-		return nil, ctx.Err()
-	case <-time.After(timeout):
-		return nil, fmt.Errorf("handshake timeout")
-	case resp := <-respChan:
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		var resp handshakeResponse
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-deadline.C:
+			return nil, fmt.Errorf("handshake timeout")
+		case resp = <-respChan:
+		}
+
 		// Verify it's from the expected peer
 		if resp.remoteAddr.String() != peerAddr.String() {
 			return nil, fmt.Errorf("response from unexpected peer: %s", resp.remoteAddr)
 		}
 
+		// The peer is under load: resend the initiation with a MAC2 proving
+		// we own our address, using the cookie it sent
+		if resp.cookie != nil {
+			cookie, err := consumeCookieReply(resp.cookie, peerPublic, mac1)
+			if err != nil {
+				log.Debug().Err(err).Str("peer", peerName).Msg("invalid cookie reply")
+				continue
+			}
+			t.mu.Lock()
+			t.peerCookies[peerName] = receivedCookie{cookie: cookie, received: time.Now()}
+			t.mu.Unlock()
+			packet, mac1 = appendHandshakeMACs(unsigned[:len(unsigned):len(unsigned)], peerPublic, cookie)
+			if _, err := conn.WriteToUDP(packet, peerAddr); err != nil {
+				return nil, err
+			}
+			log.Debug().Str("peer", peerName).Msg("peer under load, resent handshake init with cookie")
+			continue
+		}
+
 		// Process response
 		if err := hs.ConsumeResponse(resp.data); err != nil {
+			if usePSK {
+				// The responder accepted our epoch but mixed a different key
+				log.Warn().
+					Str("peer", peerName).
+					Uint32("epoch", epoch).
+					Msg("handshake failed: PSK mismatch")
+				t.pskFailure(peerName, PSKFailureMismatch)
+			}
 			return nil, fmt.Errorf("invalid response: %w", err)
 		}
+		break
 	}
 
 	// Derive keys
@@ -1379,8 +1498,8 @@ func (t *Transport) holePunch(ctx context.Context, peerName string, peerAddr *ne
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	if t.config.AuthToken != "" {
-		req.Header.Set("Authorization", "Bearer "+t.config.AuthToken)
+	if token := t.authToken(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
 	}
 
 	resp, err := t.httpClient.Do(req)
@@ -1430,6 +1549,14 @@ func (t *Transport) holePunch(ctx context.Context, peerName string, peerAddr *ne
 	return nil
 }
 
+// authToken returns the token for coordination server requests.
+func (t *Transport) authToken() string {
+	if t.config.AuthTokenFunc != nil {
+		return t.config.AuthTokenFunc()
+	}
+	return t.config.AuthToken
+}
+
 // getPeerEndpoint retrieves the peer's UDP endpoint from coordination server.
 // Returns the endpoint address that matches local connectivity (IPv4 or IPv6).
 func (t *Transport) getPeerEndpoint(ctx context.Context, peerName string) (string, error) {
@@ -1443,8 +1570,8 @@ func (t *Transport) getPeerEndpoint(ctx context.Context, peerName string) (strin
 		return "", err
 	}
 
-	if t.config.AuthToken != "" {
-		req.Header.Set("Authorization", "Bearer "+t.config.AuthToken)
+	if token := t.authToken(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
 	}
 
 	resp, err := t.httpClient.Do(req)
@@ -1507,7 +1634,7 @@ func (t *Transport) Listen(ctx context.Context, opts transport.ListenOptions) (t
 
 	t.listener = &Listener{
 		transport: t,
-		acceptCh:  make(chan *Connection, 16),
+		queue:     transport.NewAcceptQueue(16),
 	}
 
 	return t.listener, nil
@@ -1551,18 +1678,15 @@ func (t *Transport) Close() error {
 		_ = t.portMapper.Stop()
 	}
 
-	// Close packet queue to signal workers to exit
-	if t.packetQueue != nil {
-		close(t.packetQueue)
-	}
-
+	// Close sessions outside the lock, as they remove themselves on close
 	t.mu.Lock()
-	for _, s := range t.sessions {
-		_ = s.Close()
-	}
+	sessions := t.sessions
 	t.sessions = make(map[uint32]*Session)
 	t.peerSessions = make(map[string]*Session)
 	t.mu.Unlock()
+	for _, s := range sessions {
+		_ = s.Close()
+	}
 
 	if t.conn != nil {
 		_ = t.conn.Close()
@@ -1570,6 +1694,19 @@ func (t *Transport) Close() error {
 	if t.conn6 != nil {
 		_ = t.conn6.Close()
 	}
+	if t.probeConn != nil {
+		_ = t.probeConn.Close()
+	}
+	if t.probeConn6 != nil {
+		_ = t.probeConn6.Close()
+	}
+
+	// Close packet queue to signal workers to exit, once the receive loops
+	// can no longer send to it
+	t.receivers.Wait()
+	if t.packetQueue != nil {
+		close(t.packetQueue)
+	}
 
 	return nil
 }
@@ -1652,6 +1789,8 @@ func (t *Transport) registerSession(session *Session) bool {
 		_ = oldSession.Close()
 	}
 
+	go t.discoverPMTU(session)
+
 	return true
 }
 
@@ -1758,22 +1897,12 @@ func (c *Connection) IsHealthy() bool {
 // Listener accepts incoming UDP connections.
 type Listener struct {
 	transport *Transport
-	acceptCh  chan *Connection
-	closed    atomic.Bool
-	mu        sync.Mutex // Protects channel operations to prevent send-on-closed-channel panic
+	queue     *transport.AcceptQueue
 }
 
 // Accept waits for and returns the next connection.
 func (l *Listener) Accept(ctx context.Context) (transport.Connection, error) {
-	select {
-	case conn, ok := <-l.acceptCh:
-		if !ok {
-			return nil, fmt.Errorf("listener closed")
-		}
-		return conn, nil
-	case <-ctx.Done():
-		return nil, ctx.Err()
-	}
+	return l.queue.Accept(ctx)
 }
 
 // Addr returns the listener's address.
@@ -1786,32 +1915,10 @@ func (l *Listener) Addr() net.Addr {
 
 // Close closes the listener.
 func (l *Listener) Close() error {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	if l.closed.Load() {
-		return nil
-	}
-	l.closed.Store(true)
-	close(l.acceptCh)
+	l.queue.Close()
 	return nil
 }
 
-// trySend attempts to send a connection to the accept channel.
-// Returns true if sent, false if listener is closed or channel is full.
-func (l *Listener) trySend(conn *Connection) bool {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	if l.closed.Load() {
-		return false
-	}
-	select {
-	case l.acceptCh <- conn:
-		return true
-	default:
-		return false
-	}
-}
-
 // RegisterUDPEndpoint registers our UDP endpoint with the coordination server.
 // Attempts to register via both IPv4 and IPv6 to support dual-stack connectivity.
 func (t *Transport) RegisterUDPEndpoint(ctx context.Context, peerName string) error {
@@ -1882,8 +1989,8 @@ func (t *Transport) registerEndpointVia(ctx context.Context, peerName, localAddr
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	if t.config.AuthToken != "" {
-		req.Header.Set("Authorization", "Bearer "+t.config.AuthToken)
+	if token := t.authToken(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
 	}
 
 	// Create HTTP client that forces specific network
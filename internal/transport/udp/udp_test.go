@@ -2,8 +2,11 @@ package udp
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"net"
+	"sync"
 	"testing"
 	"time"
 
@@ -1289,6 +1292,93 @@ func TestTransportClose_DualStack(t *testing.T) {
 	}
 }
 
+func newLoopbackTransport(t *testing.T) *Transport {
+	t.Helper()
+	priv, pub, _ := X25519KeyPair()
+	disabled := false
+	transport, err := New(Config{
+		ListenAddr:        "127.0.0.1:0",
+		StaticPrivate:     priv,
+		StaticPublic:      pub,
+		EnablePortMapping: &disabled,
+	})
+	if err != nil {
+		t.Fatalf("create transport: %v", err)
+	}
+	if err := transport.Start(); err != nil {
+		t.Fatalf("start transport: %v", err)
+	}
+	return transport
+}
+
+// closeWithin closes a transport, failing the test if it doesn't return in time.
+func closeWithin(t *testing.T, transport *Transport, timeout time.Duration) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		_ = transport.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		t.Fatal("Close did not return")
+	}
+}
+
+func TestTransportClose_WithSessions(t *testing.T) {
+	transport := newLoopbackTransport(t)
+
+	// Sessions remove themselves from the transport when closed
+	for i := uint32(1); i <= 3; i++ {
+		session := NewSession(SessionConfig{
+			LocalIndex: i,
+			PeerName:   fmt.Sprintf("peer%d", i),
+			RemoteAddr: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1234},
+			Conn:       transport.conn,
+		})
+		session.SetOnClose(transport.removeSession)
+		transport.mu.Lock()
+		transport.sessions[i] = session
+		transport.peerSessions[session.PeerName()] = session
+		transport.mu.Unlock()
+	}
+
+	closeWithin(t, transport, 2*time.Second)
+}
+
+func TestTransportClose_WhileReceiving(t *testing.T) {
+	for range 20 {
+		transport := newLoopbackTransport(t)
+		addr := transport.conn.LocalAddr().(*net.UDPAddr)
+
+		sender, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatalf("listen: %v", err)
+		}
+		stop := make(chan struct{})
+		var wg sync.WaitGroup
+		wg.Go(func() {
+			packet := []byte{PacketTypeKeepalive}
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					_, _ = sender.WriteToUDP(packet, addr)
+				}
+			}
+		})
+
+		// Receive loops must not send on the packet queue once it is closed
+		time.Sleep(5 * time.Millisecond)
+		closeWithin(t, transport, 2*time.Second)
+		close(stop)
+		wg.Wait()
+		_ = sender.Close()
+	}
+}
+
 // =============================================================================
 // Worker Pool Tests
 // =============================================================================
@@ -1674,3 +1764,359 @@ func TestPingPongRoundtrip(t *testing.T) {
 		t.Errorf("RTT too large: %v", rtt)
 	}
 }
+
+// =============================================================================
+// Pre-shared Key Tests
+// =============================================================================
+
+func TestHandshakePSK(t *testing.T) {
+	initPriv, initPub, _ := X25519KeyPair()
+	respPriv, respPub, _ := X25519KeyPair()
+
+	handshake := func(initPSK, respPSK [32]byte) error {
+		initiator, _ := NewInitiatorHandshake(initPriv, initPub, respPub)
+		responder, _ := NewResponderHandshake(respPriv, respPub)
+		initiator.SetPSK(initPSK)
+		responder.SetPSK(respPSK)
+		initMsg, _ := initiator.CreateInitiation()
+		if err := responder.ConsumeInitiation(initMsg); err != nil {
+			return err
+		}
+		respMsg, _ := responder.CreateResponse()
+		return initiator.ConsumeResponse(respMsg)
+	}
+
+	psk := [32]byte{1, 2, 3}
+	if err := handshake(psk, psk); err != nil {
+		t.Errorf("handshake with matching PSKs failed: %v", err)
+	}
+	if err := handshake(psk, [32]byte{}); err == nil {
+		t.Error("handshake with mismatched PSKs succeeded")
+	}
+}
+
+type pskTestPeer struct {
+	name      string
+	transport *Transport
+	failures  chan string
+}
+
+func newPSKTestPeer(t *testing.T, name string, peers map[[32]byte]string, opts ...func(*Config)) *pskTestPeer {
+	t.Helper()
+	priv, pub, _ := X25519KeyPair()
+	peers[pub] = name
+	disabled := false
+	cfg := Config{
+		ListenAddr:        "127.0.0.1:0",
+		StaticPrivate:     priv,
+		StaticPublic:      pub,
+		HandshakeTimeout:  500 * time.Millisecond,
+		EnablePortMapping: &disabled,
+		PeerResolver:      func(key [32]byte) string { return peers[key] },
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	tr, err := New(cfg)
+	if err != nil {
+		t.Fatalf("create transport: %v", err)
+	}
+	if err := tr.Start(); err != nil {
+		t.Fatalf("start transport: %v", err)
+	}
+	t.Cleanup(func() { _ = tr.Close() })
+
+	p := &pskTestPeer{name: name, transport: tr, failures: make(chan string, 4)}
+	tr.SetPSKFailureCallback(func(peerName, reason string) { p.failures <- peerName + ":" + reason })
+	return p
+}
+
+func (p *pskTestPeer) handshake(to *pskTestPeer) error {
+	addr := to.transport.conn.LocalAddr().(*net.UDPAddr)
+	_, err := p.transport.initiateHandshake(context.Background(), to.name, to.transport.staticPublic, addr, p.transport.conn)
+	return err
+}
+
+func TestTransportHandshakePSK(t *testing.T) {
+	mesh := [32]byte{1}
+	pair := [32]byte{2}
+
+	tests := []struct {
+		name        string
+		alice, bob  []PSKSet
+		current     uint32
+		wantFailure string // "<reporting peer>:<peer>:<reason>", empty on success
+	}{
+		{name: "no PSKs"},
+		{
+			name:  "mesh PSK",
+			alice: []PSKSet{{Epoch: 7, Mesh: mesh}},
+			bob:   []PSKSet{{Epoch: 7, Mesh: mesh}},
+		},
+		{
+			name:  "pair PSK",
+			alice: []PSKSet{{Epoch: 7, Mesh: mesh, Pairs: map[string][32]byte{"bob": pair}}},
+			bob:   []PSKSet{{Epoch: 7, Mesh: mesh, Pairs: map[string][32]byte{"alice": pair}}},
+		},
+		{
+			name:  "responder holds an older epoch too",
+			alice: []PSKSet{{Epoch: 8, Mesh: pair}},
+			bob:   []PSKSet{{Epoch: 7, Mesh: mesh}, {Epoch: 8, Mesh: pair}},
+		},
+		{
+			name:        "pair PSK on one side only",
+			alice:       []PSKSet{{Epoch: 7, Mesh: mesh, Pairs: map[string][32]byte{"bob": pair}}},
+			bob:         []PSKSet{{Epoch: 7, Mesh: mesh}},
+			wantFailure: "alice:bob:" + PSKFailureMismatch,
+		},
+		{
+			name:        "initiator without PSK",
+			bob:         []PSKSet{{Epoch: 7, Mesh: mesh}},
+			wantFailure: "bob:alice:" + PSKFailureMissing,
+		},
+		{
+			name:        "responder without PSK",
+			alice:       []PSKSet{{Epoch: 7, Mesh: mesh}},
+			wantFailure: "bob:alice:" + PSKFailureUnexpected,
+		},
+		{
+			name:        "unknown epoch",
+			alice:       []PSKSet{{Epoch: 8, Mesh: mesh}},
+			bob:         []PSKSet{{Epoch: 7, Mesh: mesh}},
+			wantFailure: "bob:alice:" + PSKFailureUnknownEpoch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			peers := make(map[[32]byte]string)
+			alice := newPSKTestPeer(t, "alice", peers)
+			bob := newPSKTestPeer(t, "bob", peers)
+			if len(tt.alice) > 0 {
+				alice.transport.SetPSKs(tt.alice[0].Epoch, tt.alice)
+			}
+			if len(tt.bob) > 0 {
+				bob.transport.SetPSKs(tt.bob[len(tt.bob)-1].Epoch, tt.bob)
+			}
+
+			err := alice.handshake(bob)
+			if tt.wantFailure == "" {
+				if err != nil {
+					t.Fatalf("handshake failed: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("handshake succeeded")
+			}
+			var got string
+			select {
+			case f := <-alice.failures:
+				got = "alice:" + f
+			case f := <-bob.failures:
+				got = "bob:" + f
+			case <-time.After(time.Second):
+			}
+			if got != tt.wantFailure {
+				t.Errorf("PSK failure = %q, want %q", got, tt.wantFailure)
+			}
+		})
+	}
+}
+
+func TestCookieReplyPacket(t *testing.T) {
+	reply := &CookieReplyPacket{Receiver: 0x12345678}
+	reply.Nonce[0] = 0xAA
+	reply.EncryptedCookie[31] = 0xBB
+
+	data := reply.Marshal()
+	if len(data) != CookieReplySize || data[0] != PacketTypeCookieReply {
+		t.Fatalf("unexpected packet: len=%d type=0x%02x", len(data), data[0])
+	}
+	got, err := UnmarshalCookieReply(data)
+	if err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if *got != *reply {
+		t.Errorf("round trip = %+v, want %+v", got, reply)
+	}
+	if _, err := UnmarshalCookieReply(data[:CookieReplySize-1]); err == nil {
+		t.Error("expected error for short packet")
+	}
+}
+
+func TestHandshakeMACs(t *testing.T) {
+	_, respPub, _ := X25519KeyPair()
+	_, otherPub, _ := X25519KeyPair()
+	checker := newCookieChecker(respPub)
+	addr := &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 51820}
+
+	base := append([]byte{PacketTypeHandshakeInit}, bytes.Repeat([]byte{7}, 132)...)
+	packet, mac1 := appendHandshakeMACs(base, respPub, nil)
+	if !checker.checkMAC1(packet[1:]) {
+		t.Error("valid MAC1 rejected")
+	}
+	if checker.checkMAC2(packet[1:], addr) {
+		t.Error("MAC2 without a cookie accepted")
+	}
+	wrong, _ := appendHandshakeMACs(base[:len(base):len(base)], otherPub, nil)
+	if checker.checkMAC1(wrong[1:]) {
+		t.Error("MAC1 for another responder accepted")
+	}
+
+	// The cookie reply only decrypts for the initiation it answers
+	reply, err := checker.createReply(1, mac1[:], addr)
+	if err != nil {
+		t.Fatalf("create reply: %v", err)
+	}
+	if _, err := consumeCookieReply(reply, respPub, [macSize]byte{}); err == nil {
+		t.Error("cookie reply decrypted with the wrong MAC1")
+	}
+	cookie, err := consumeCookieReply(reply, respPub, mac1)
+	if err != nil {
+		t.Fatalf("consume reply: %v", err)
+	}
+
+	signed, _ := appendHandshakeMACs(base[:len(base):len(base)], respPub, cookie)
+	if !checker.checkMAC1(signed[1:]) || !checker.checkMAC2(signed[1:], addr) {
+		t.Error("MACs with a valid cookie rejected")
+	}
+	other := &net.UDPAddr{IP: net.ParseIP("192.0.2.2"), Port: 51820}
+	if checker.checkMAC2(signed[1:], other) {
+		t.Error("cookie accepted from another address")
+	}
+}
+
+func TestTransportHandshakeUnderLoad(t *testing.T) {
+	peers := make(map[[32]byte]string)
+	alice := newPSKTestPeer(t, "alice", peers)
+	bob := newPSKTestPeer(t, "bob", peers, func(cfg *Config) { cfg.AllowHandshakesWithoutMACs = true })
+	bob.transport.loadMu.Lock()
+	bob.transport.lastUnderLoad = time.Now().Add(time.Hour)
+	bob.transport.loadMu.Unlock()
+
+	// The first handshake gets a cookie reply and succeeds once resent
+	if err := alice.handshake(bob); err != nil {
+		t.Fatalf("handshake under load failed: %v", err)
+	}
+	if stats := bob.transport.HandshakeStats(); stats.CookieReplies != 1 {
+		t.Errorf("cookie replies = %d, want 1", stats.CookieReplies)
+	}
+
+	// Later handshakes reuse the cookie
+	if alice.transport.peerCookie("bob") == nil {
+		t.Error("cookie from bob not kept")
+	}
+
+	// Initiations without MACs, even where allowed, or with an invalid
+	// MAC1 are dropped
+	addr := bob.transport.conn.LocalAddr().(*net.UDPAddr)
+	init := append([]byte{PacketTypeHandshakeInit}, make([]byte, 128)...)
+	_, _ = alice.transport.conn.WriteToUDP(init, addr)
+	_, _ = alice.transport.conn.WriteToUDP(append(init, make([]byte, handshakeMACsSize)...), addr)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		stats := bob.transport.HandshakeStats()
+		if stats.DroppedUnderLoad == 1 && stats.DroppedMAC1 == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("stats = %+v, want one initiation dropped for each reason", stats)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTransportHandshakeRequiresMACs(t *testing.T) {
+	peers := make(map[[32]byte]string)
+	alice := newPSKTestPeer(t, "alice", peers)
+	bob := newPSKTestPeer(t, "bob", peers)
+
+	// Initiations without MACs are dropped even when not under load
+	addr := bob.transport.conn.LocalAddr().(*net.UDPAddr)
+	init := append([]byte{PacketTypeHandshakeInit}, make([]byte, 128)...)
+	_, _ = alice.transport.conn.WriteToUDP(init, addr)
+	deadline := time.Now().Add(2 * time.Second)
+	for bob.transport.HandshakeStats().DroppedMAC1 != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("stats = %+v, want the initiation dropped for its missing MAC1", bob.transport.HandshakeStats())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Initiations with MACs still go through
+	if err := alice.handshake(bob); err != nil {
+		t.Fatalf("handshake failed: %v", err)
+	}
+}
+
+func TestMTUProbePacket(t *testing.T) {
+	probe := &MTUProbePacket{Receiver: 0x12345678, Token: 0xDEADBEEFCAFE, Size: 1472}
+	data := probe.Marshal()
+	if len(data) != 1472 {
+		t.Fatalf("expected probe padded to %d, got %d", 1472, len(data))
+	}
+	if data[0] != PacketTypeMTUProbe {
+		t.Errorf("expected packet type %d, got %d", PacketTypeMTUProbe, data[0])
+	}
+	parsed, err := UnmarshalMTUProbe(data)
+	if err != nil {
+		t.Fatalf("unmarshal probe failed: %v", err)
+	}
+	if *parsed != *probe {
+		t.Errorf("parsed probe = %+v, want %+v", parsed, probe)
+	}
+
+	ack := &MTUProbeAckPacket{Receiver: 0x87654321, Token: probe.Token, Size: probe.Size}
+	data = ack.Marshal()
+	if len(data) != MTUProbeAckSize {
+		t.Errorf("expected ack size %d, got %d", MTUProbeAckSize, len(data))
+	}
+	parsedAck, err := UnmarshalMTUProbeAck(data)
+	if err != nil {
+		t.Fatalf("unmarshal ack failed: %v", err)
+	}
+	if *parsedAck != *ack {
+		t.Errorf("parsed ack = %+v, want %+v", parsedAck, ack)
+	}
+
+	if _, err := UnmarshalMTUProbe(data[:MTUProbeHeaderSize-1]); err == nil {
+		t.Error("expected error for short probe")
+	}
+	if _, err := UnmarshalMTUProbeAck(data[:MTUProbeAckSize-1]); err == nil {
+		t.Error("expected error for short ack")
+	}
+}
+
+func TestTransportPathMTU(t *testing.T) {
+	peers := make(map[[32]byte]string)
+	alice := newPSKTestPeer(t, "alice", peers)
+	bob := newPSKTestPeer(t, "bob", peers)
+	if err := alice.handshake(bob); err != nil {
+		t.Fatalf("handshake failed: %v", err)
+	}
+
+	// Loopback carries the largest size probed, in both directions
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		aliceMTU, bobMTU := alice.transport.PathMTUs()["bob"], bob.transport.PathMTUs()["alice"]
+		if aliceMTU == maxPathMTU && bobMTU == maxPathMTU {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("path MTUs = %d, %d, want %d", aliceMTU, bobMTU, maxPathMTU)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	alice.transport.mu.RLock()
+	session := alice.transport.peerSessions["bob"]
+	alice.transport.mu.RUnlock()
+	if got, want := session.MaxWriteSize(), maxPathMTU-20-udpHeaderSize-HeaderSize-AuthTagSize; got != want {
+		t.Errorf("MaxWriteSize() = %d, want %d", got, want)
+	}
+	if got := session.Stats().PathMTU; got != maxPathMTU {
+		t.Errorf("Stats().PathMTU = %d, want %d", got, maxPathMTU)
+	}
+}
@@ -18,6 +18,9 @@ type Config struct {
 	Name    string // Interface name (e.g., "tun-mesh0")
 	MTU     int    // Maximum transmission unit
 	Address string // IP address with CIDR (e.g., "10.42.0.1/16")
+	// Address6 is an optional IPv6 address with prefix length
+	// (e.g., "fd42:6d65:7368::1/64"), added alongside Address.
+	Address6 string
 }
 
 // Validate checks if the configuration is valid.
@@ -36,6 +39,15 @@ func (c *Config) Validate() error {
 	if err != nil {
 		return fmt.Errorf("invalid address: %w", err)
 	}
+	if c.Address6 != "" {
+		ip, _, err := net.ParseCIDR(c.Address6)
+		if err != nil {
+			return fmt.Errorf("invalid IPv6 address: %w", err)
+		}
+		if ip.To4() != nil {
+			return fmt.Errorf("invalid IPv6 address: %s is IPv4", c.Address6)
+		}
+	}
 	return nil
 }
 
@@ -45,6 +57,7 @@ type Device struct {
 	name    string
 	ip      net.IP
 	network *net.IPNet
+	ip6     net.IP // nil when the device has no IPv6 address
 	mtu     int
 }
 
@@ -87,6 +100,13 @@ func Create(cfg Config) (*Device, error) {
 		return nil, fmt.Errorf("configure interface: %w", err)
 	}
 
+	// IPv6 is best effort: the host may have it disabled
+	if cfg.Address6 != "" {
+		if err := dev.configureIPv6(cfg.Address6); err != nil {
+			log.Warn().Err(err).Str("address", cfg.Address6).Msg("failed to add IPv6 address, mesh IPv6 unavailable")
+		}
+	}
+
 	log.Info().
 		Str("name", dev.name).
 		Str("ip", ip.String()).
@@ -217,6 +237,52 @@ func (d *Device) configureWindows() error {
 	return nil
 }
 
+// buildIPv6AddressCommands builds the OS-specific commands adding an IPv6
+// address (with prefix length) to the interface, and a route for its prefix.
+func buildIPv6AddressCommands(iface, addr, goos string) [][]string {
+	ip, network, err := net.ParseCIDR(addr)
+	if err != nil {
+		return nil
+	}
+	ones, _ := network.Mask.Size()
+
+	switch goos {
+	case "darwin":
+		return [][]string{
+			{"ifconfig", iface, "inet6", ip.String(), "prefixlen", fmt.Sprintf("%d", ones), "alias"},
+			{"route", "add", "-inet6", "-net", network.String(), "-interface", iface},
+		}
+	case "linux":
+		// nodad: nothing else on the link can hold the address
+		return [][]string{
+			{"ip", "-6", "addr", "add", addr, "dev", iface, "nodad"},
+		}
+	case "windows":
+		return [][]string{
+			{"netsh", "interface", "ipv6", "add", "address", iface, addr},
+		}
+	}
+	return nil
+}
+
+// configureIPv6 adds the IPv6 address to the interface.
+func (d *Device) configureIPv6(addr string) error {
+	cmds := buildIPv6AddressCommands(d.name, addr, runtime.GOOS)
+	if cmds == nil {
+		return fmt.Errorf("unsupported OS: %s", runtime.GOOS)
+	}
+	for _, cmdArgs := range cmds {
+		out, err := exec.Command(cmdArgs[0], cmdArgs[1:]...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%s failed: %s: %w", strings.Join(cmdArgs, " "), string(out), err)
+		}
+	}
+
+	d.ip6, _, _ = net.ParseCIDR(addr)
+	log.Info().Str("name", d.name).Str("ip", d.ip6.String()).Msg("TUN IPv6 address added")
+	return nil
+}
+
 // Name returns the interface name.
 func (d *Device) Name() string {
 	return d.name
@@ -227,6 +293,11 @@ func (d *Device) IP() net.IP {
 	return d.ip
 }
 
+// IP6 returns the device's IPv6 address, or nil if it has none.
+func (d *Device) IP6() net.IP {
+	return d.ip6
+}
+
 // Network returns the device's network.
 func (d *Device) Network() *net.IPNet {
 	return d.network
@@ -272,44 +343,60 @@ func ParseIPConfig(ipStr, cidrStr string) (net.IP, net.IPMask, error) {
 	return ip, network.Mask, nil
 }
 
-// ExtractDestIP extracts the destination IP from an IPv4 packet.
+// ExtractDestIP extracts the destination IP from an IPv4 or IPv6 packet.
 func ExtractDestIP(packet []byte) net.IP {
 	if len(packet) < 20 {
 		return nil
 	}
-	// IPv4 header: destination IP is at bytes 16-19
-	version := packet[0] >> 4
-	if version != 4 {
-		return nil
+	switch packet[0] >> 4 {
+	case 4:
+		// IPv4 header: destination IP is at bytes 16-19
+		return net.IP(packet[16:20])
+	case 6:
+		// IPv6 header: destination IP is at bytes 24-39
+		if len(packet) < 40 {
+			return nil
+		}
+		return net.IP(packet[24:40])
 	}
-	return net.IP(packet[16:20])
+	return nil
 }
 
-// ExtractSrcIP extracts the source IP from an IPv4 packet.
+// ExtractSrcIP extracts the source IP from an IPv4 or IPv6 packet.
 func ExtractSrcIP(packet []byte) net.IP {
 	if len(packet) < 20 {
 		return nil
 	}
-	version := packet[0] >> 4
-	if version != 4 {
-		return nil
+	switch packet[0] >> 4 {
+	case 4:
+		return net.IP(packet[12:16])
+	case 6:
+		if len(packet) < 40 {
+			return nil
+		}
+		return net.IP(packet[8:24])
 	}
-	return net.IP(packet[12:16])
+	return nil
 }
 
-// ExtractProtocol extracts the protocol number from an IPv4 packet.
+// ExtractProtocol extracts the protocol number from an IPv4 packet, or the
+// Next Header of an IPv6 packet (which precedes any extension headers).
 func ExtractProtocol(packet []byte) uint8 {
 	if len(packet) < 20 {
 		return 0
 	}
+	if packet[0]>>4 == 6 {
+		return packet[6]
+	}
 	return packet[9]
 }
 
 // Protocol constants
 const (
-	ProtoICMP = 1
-	ProtoTCP  = 6
-	ProtoUDP  = 17
+	ProtoICMP   = 1
+	ProtoTCP    = 6
+	ProtoUDP    = 17
+	ProtoICMPv6 = 58
 )
 
 // ProtocolName returns a human-readable name for a protocol number.
@@ -321,6 +408,8 @@ func ProtocolName(proto uint8) string {
 		return "TCP"
 	case ProtoUDP:
 		return "UDP"
+	case ProtoICMPv6:
+		return "ICMPv6"
 	default:
 		return fmt.Sprintf("proto-%d", proto)
 	}
@@ -335,6 +424,7 @@ func IsInNetwork(ip net.IP, network *net.IPNet) bool {
 type ExitRouteConfig struct {
 	InterfaceName string // TUN interface name
 	MeshCIDR      string // Mesh network CIDR (for NAT exclusion)
+	MeshCIDR6     string // Mesh network IPv6 prefix; empty to route IPv4 only
 	IsExitPeer    bool   // True if this node accepts exit traffic
 }
 
@@ -350,6 +440,11 @@ func (c *ExitRouteConfig) Validate() error {
 	if err != nil {
 		return fmt.Errorf("invalid mesh CIDR: %w", err)
 	}
+	if c.MeshCIDR6 != "" {
+		if _, _, err := net.ParseCIDR(c.MeshCIDR6); err != nil {
+			return fmt.Errorf("invalid mesh IPv6 CIDR: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -392,6 +487,25 @@ func buildDefaultRouteCommands(cfg ExitRouteConfig, goos string) ([][]string, []
 		}
 	}
 
+	if cfg.MeshCIDR6 == "" {
+		return addCmds, removeCmds
+	}
+
+	// IPv6 equivalents: ::/1 and 8000::/1 cover everything but the default route
+	for _, prefix := range []string{"::/1", "8000::/1"} {
+		switch goos {
+		case "darwin":
+			addCmds = append(addCmds, []string{"route", "add", "-inet6", "-net", prefix, "-interface", cfg.InterfaceName})
+			removeCmds = append(removeCmds, []string{"route", "delete", "-inet6", "-net", prefix})
+		case "linux":
+			addCmds = append(addCmds, []string{"ip", "-6", "route", "add", prefix, "dev", cfg.InterfaceName})
+			removeCmds = append(removeCmds, []string{"ip", "-6", "route", "delete", prefix, "dev", cfg.InterfaceName})
+		case "windows":
+			addCmds = append(addCmds, []string{"netsh", "interface", "ipv6", "add", "route", prefix, cfg.InterfaceName})
+			removeCmds = append(removeCmds, []string{"netsh", "interface", "ipv6", "delete", "route", prefix, cfg.InterfaceName})
+		}
+	}
+
 	return addCmds, removeCmds
 }
 
@@ -430,11 +544,30 @@ func buildExitNATCommands(cfg ExitRouteConfig, goos string) ([][]string, [][]str
 		}
 	}
 
+	if cfg.MeshCIDR6 == "" {
+		return addCmds, removeCmds
+	}
+
+	switch goos {
+	case "darwin":
+		addCmds = append(addCmds, []string{"sysctl", "-w", "net.inet6.ip6.forwarding=1"})
+	case "linux":
+		addCmds = append(addCmds,
+			[]string{"sysctl", "-w", "net.ipv6.conf.all.forwarding=1"},
+			[]string{"ip6tables", "-t", "nat", "-A", "POSTROUTING", "-s", cfg.MeshCIDR6, "!", "-d", cfg.MeshCIDR6, "-j", "MASQUERADE"})
+		removeCmds = append(removeCmds,
+			[]string{"ip6tables", "-t", "nat", "-D", "POSTROUTING", "-s", cfg.MeshCIDR6, "!", "-d", cfg.MeshCIDR6, "-j", "MASQUERADE"})
+	case "windows":
+		addCmds = append(addCmds, []string{"netsh", "interface", "ipv6", "set", "interface", cfg.InterfaceName, "forwarding=enabled"})
+		removeCmds = append(removeCmds, []string{"netsh", "interface", "ipv6", "set", "interface", cfg.InterfaceName, "forwarding=disabled"})
+	}
+
 	return addCmds, removeCmds
 }
 
 // ConfigureExitRoutes sets up default routes for exit node clients.
-// This routes all internet traffic (0.0.0.0/1 and 128.0.0.0/1) through the TUN interface.
+// This routes all internet traffic (0.0.0.0/1 and 128.0.0.0/1, and ::/1 and
+// 8000::/1 when MeshCIDR6 is set) through the TUN interface.
 func ConfigureExitRoutes(cfg ExitRouteConfig) error {
 	if err := cfg.Validate(); err != nil {
 		return fmt.Errorf("invalid config: %w", err)
@@ -540,34 +673,176 @@ func RemoveExitNAT(cfg ExitRouteConfig) error {
 	return nil
 }
 
-// AddRoute adds a route to the system routing table.
-func AddRoute(dest *net.IPNet, gateway net.IP, iface string) error {
-	switch runtime.GOOS {
+// SubnetRouteConfig holds configuration for a peer routing LAN prefixes it
+// advertises into the mesh.
+type SubnetRouteConfig struct {
+	InterfaceName string   // TUN interface name
+	MeshCIDR      string   // Mesh network CIDR (source of forwarded traffic)
+	Routes        []string // Advertised prefixes in CIDR notation
+}
+
+// Validate checks if the subnet route configuration is valid.
+func (c *SubnetRouteConfig) Validate() error {
+	if c.InterfaceName == "" {
+		return fmt.Errorf("interface name is required")
+	}
+	if _, _, err := net.ParseCIDR(c.MeshCIDR); err != nil {
+		return fmt.Errorf("invalid mesh CIDR: %w", err)
+	}
+	for _, route := range c.Routes {
+		if _, _, err := net.ParseCIDR(route); err != nil {
+			return fmt.Errorf("invalid route %q: %w", route, err)
+		}
+	}
+	return nil
+}
+
+// buildSubnetNATCommands builds OS-specific commands forwarding mesh traffic
+// into the advertised prefixes. Traffic is masqueraded so hosts on the LAN
+// reply without a route back to the mesh.
+// Returns (addCommands, removeCommands).
+func buildSubnetNATCommands(cfg SubnetRouteConfig, goos string) ([][]string, [][]string) {
+	var addCmds, removeCmds [][]string
+
+	switch goos {
 	case "darwin":
-		ones, _ := dest.Mask.Size()
-		cmd := exec.Command("route", "add", "-net",
-			fmt.Sprintf("%s/%d", dest.IP.String(), ones),
-			gateway.String())
-		out, err := cmd.CombinedOutput()
-		if err != nil && !strings.Contains(string(out), "exists") {
-			return fmt.Errorf("route add failed: %s: %w", string(out), err)
+		// macOS: Enable IP forwarding; NAT requires a pf anchor, as for exit peers
+		addCmds = [][]string{
+			{"sysctl", "-w", "net.inet.ip.forwarding=1"},
 		}
 	case "linux":
-		ones, _ := dest.Mask.Size()
-		cmd := exec.Command("ip", "route", "add",
-			fmt.Sprintf("%s/%d", dest.IP.String(), ones),
-			"via", gateway.String())
-		out, err := cmd.CombinedOutput()
-		if err != nil && !strings.Contains(string(out), "exists") {
-			return fmt.Errorf("ip route add failed: %s: %w", string(out), err)
+		addCmds = [][]string{
+			{"sysctl", "-w", "net.ipv4.ip_forward=1"},
+		}
+		for _, route := range cfg.Routes {
+			addCmds = append(addCmds,
+				[]string{"iptables", "-t", "nat", "-A", "POSTROUTING", "-s", cfg.MeshCIDR, "-d", route, "-j", "MASQUERADE"})
+			removeCmds = append(removeCmds,
+				[]string{"iptables", "-t", "nat", "-D", "POSTROUTING", "-s", cfg.MeshCIDR, "-d", route, "-j", "MASQUERADE"})
 		}
 	case "windows":
-		mask := net.IP(dest.Mask).String()
-		cmd := exec.Command("route", "add", dest.IP.String(), "mask", mask, gateway.String())
+		addCmds = [][]string{
+			{"netsh", "interface", "ipv4", "set", "interface", cfg.InterfaceName, "forwarding=enabled"},
+		}
+		removeCmds = [][]string{
+			{"netsh", "interface", "ipv4", "set", "interface", cfg.InterfaceName, "forwarding=disabled"},
+		}
+	}
+
+	return addCmds, removeCmds
+}
+
+// ConfigureSubnetNAT sets up forwarding from the mesh into advertised prefixes.
+func ConfigureSubnetNAT(cfg SubnetRouteConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	if len(cfg.Routes) == 0 {
+		return nil // Nothing to do
+	}
+
+	addCmds, _ := buildSubnetNATCommands(cfg, runtime.GOOS)
+
+	for _, cmdArgs := range addCmds {
+		cmd := exec.Command(cmdArgs[0], cmdArgs[1:]...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			log.Warn().
+				Str("cmd", strings.Join(cmdArgs, " ")).
+				Str("output", string(out)).
+				Msg("failed to configure subnet NAT")
+		} else {
+			log.Info().Str("cmd", strings.Join(cmdArgs, " ")).Msg("subnet NAT configured")
+		}
+	}
+
+	return nil
+}
+
+// RemoveSubnetNAT removes forwarding configured by ConfigureSubnetNAT.
+func RemoveSubnetNAT(cfg SubnetRouteConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	if len(cfg.Routes) == 0 {
+		return nil // Nothing to do
+	}
+
+	_, removeCmds := buildSubnetNATCommands(cfg, runtime.GOOS)
+
+	for _, cmdArgs := range removeCmds {
+		cmd := exec.Command(cmdArgs[0], cmdArgs[1:]...)
 		out, err := cmd.CombinedOutput()
 		if err != nil {
-			return fmt.Errorf("route add failed: %s: %w", string(out), err)
+			log.Debug().
+				Str("cmd", strings.Join(cmdArgs, " ")).
+				Str("output", string(out)).
+				Msg("failed to remove subnet NAT (may not exist)")
+		} else {
+			log.Info().Str("cmd", strings.Join(cmdArgs, " ")).Msg("subnet NAT removed")
+		}
+	}
+
+	return nil
+}
+
+// buildRouteCommand builds the OS-specific command adding (op "add") or
+// removing (op "delete") a route to dest, via gateway or, when gateway is nil,
+// directly out of iface.
+func buildRouteCommand(op string, dest *net.IPNet, gateway net.IP, iface, goos string) []string {
+	ones, _ := dest.Mask.Size()
+	prefix := fmt.Sprintf("%s/%d", dest.IP.String(), ones)
+
+	switch goos {
+	case "darwin":
+		if op == "delete" {
+			return []string{"route", "delete", "-net", prefix}
 		}
+		if gateway == nil {
+			return []string{"route", "add", "-net", prefix, "-interface", iface}
+		}
+		return []string{"route", "add", "-net", prefix, gateway.String()}
+	case "linux":
+		if gateway == nil {
+			return []string{"ip", "route", op, prefix, "dev", iface}
+		}
+		return []string{"ip", "route", op, prefix, "via", gateway.String()}
+	case "windows":
+		mask := net.IP(dest.Mask).String()
+		if op == "delete" {
+			return []string{"route", "delete", dest.IP.String(), "mask", mask}
+		}
+		if gateway == nil {
+			return []string{"route", "add", dest.IP.String(), "mask", mask, "0.0.0.0", "if", iface}
+		}
+		return []string{"route", "add", dest.IP.String(), "mask", mask, gateway.String()}
+	}
+	return nil
+}
+
+// AddRoute adds a route to the system routing table, via gateway or, when
+// gateway is nil, directly out of the interface iface.
+func AddRoute(dest *net.IPNet, gateway net.IP, iface string) error {
+	cmdArgs := buildRouteCommand("add", dest, gateway, iface, runtime.GOOS)
+	if cmdArgs == nil {
+		return nil
+	}
+	out, err := exec.Command(cmdArgs[0], cmdArgs[1:]...).CombinedOutput()
+	if err != nil && (runtime.GOOS == "windows" || !strings.Contains(string(out), "exists")) {
+		return fmt.Errorf("%s failed: %s: %w", strings.Join(cmdArgs, " "), string(out), err)
+	}
+	return nil
+}
+
+// RemoveRoute removes a route added with AddRoute without a gateway.
+func RemoveRoute(dest *net.IPNet, iface string) error {
+	cmdArgs := buildRouteCommand("delete", dest, nil, iface, runtime.GOOS)
+	if cmdArgs == nil {
+		return nil
+	}
+	out, err := exec.Command(cmdArgs[0], cmdArgs[1:]...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s failed: %s: %w", strings.Join(cmdArgs, " "), string(out), err)
 	}
 	return nil
 }
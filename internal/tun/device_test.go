@@ -94,6 +94,26 @@ func TestConfig_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "with IPv6 address",
+			cfg: Config{
+				Name:     "tun-mesh0",
+				MTU:      1400,
+				Address:  "10.42.0.1/16",
+				Address6: "fd42:6d65:7368::1/64",
+			},
+			wantErr: false,
+		},
+		{
+			name: "IPv4 as IPv6 address",
+			cfg: Config{
+				Name:     "tun-mesh0",
+				MTU:      1400,
+				Address:  "10.42.0.1/16",
+				Address6: "10.43.0.1/16",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -320,3 +340,102 @@ func findSubstr(s, substr string) bool {
 	}
 	return false
 }
+
+func TestBuildRouteCommand(t *testing.T) {
+	_, dest, _ := net.ParseCIDR("192.168.10.0/24")
+	gateway := net.ParseIP("10.0.0.1")
+
+	tests := []struct {
+		name    string
+		op      string
+		gateway net.IP
+		goos    string
+		want    []string
+	}{
+		{"linux via gateway", "add", gateway, "linux", []string{"ip", "route", "add", "192.168.10.0/24", "via", "10.0.0.1"}},
+		{"linux via interface", "add", nil, "linux", []string{"ip", "route", "add", "192.168.10.0/24", "dev", "tun0"}},
+		{"linux delete", "delete", nil, "linux", []string{"ip", "route", "delete", "192.168.10.0/24", "dev", "tun0"}},
+		{"darwin via interface", "add", nil, "darwin", []string{"route", "add", "-net", "192.168.10.0/24", "-interface", "tun0"}},
+		{"darwin delete", "delete", nil, "darwin", []string{"route", "delete", "-net", "192.168.10.0/24"}},
+		{"windows via interface", "add", nil, "windows", []string{"route", "add", "192.168.10.0", "mask", "255.255.255.0", "0.0.0.0", "if", "tun0"}},
+		{"windows delete", "delete", nil, "windows", []string{"route", "delete", "192.168.10.0", "mask", "255.255.255.0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, buildRouteCommand(tt.op, dest, tt.gateway, "tun0", tt.goos))
+		})
+	}
+}
+
+func TestBuildSubnetNATCommands_Linux(t *testing.T) {
+	cfg := SubnetRouteConfig{
+		InterfaceName: "tun0",
+		MeshCIDR:      "10.42.0.0/16",
+		Routes:        []string{"192.168.10.0/24", "172.17.0.0/16"},
+	}
+	require.NoError(t, cfg.Validate())
+
+	addCmds, removeCmds := buildSubnetNATCommands(cfg, "linux")
+
+	assert.Equal(t, [][]string{
+		{"sysctl", "-w", "net.ipv4.ip_forward=1"},
+		{"iptables", "-t", "nat", "-A", "POSTROUTING", "-s", "10.42.0.0/16", "-d", "192.168.10.0/24", "-j", "MASQUERADE"},
+		{"iptables", "-t", "nat", "-A", "POSTROUTING", "-s", "10.42.0.0/16", "-d", "172.17.0.0/16", "-j", "MASQUERADE"},
+	}, addCmds)
+	// Forwarding stays enabled on removal as other services may need it
+	assert.Len(t, removeCmds, 2)
+}
+
+func TestExtractIPs_IPv6(t *testing.T) {
+	packet := make([]byte, 48)
+	packet[0] = 0x60
+	packet[6] = ProtoICMPv6
+	copy(packet[8:24], net.ParseIP("fd42:6d65:7368::1"))
+	copy(packet[24:40], net.ParseIP("fd42:6d65:7368::2"))
+
+	assert.Equal(t, "fd42:6d65:7368::1", ExtractSrcIP(packet).String())
+	assert.Equal(t, "fd42:6d65:7368::2", ExtractDestIP(packet).String())
+	assert.Equal(t, uint8(ProtoICMPv6), ExtractProtocol(packet))
+	assert.Equal(t, "ICMPv6", ProtocolName(ExtractProtocol(packet)))
+
+	// Truncated IPv6 header
+	assert.Nil(t, ExtractDestIP(packet[:30]))
+	assert.Nil(t, ExtractSrcIP(packet[:30]))
+}
+
+func TestBuildIPv6AddressCommands(t *testing.T) {
+	assert.Equal(t, [][]string{
+		{"ip", "-6", "addr", "add", "fd42:6d65:7368::1/64", "dev", "tun0", "nodad"},
+	}, buildIPv6AddressCommands("tun0", "fd42:6d65:7368::1/64", "linux"))
+
+	assert.Equal(t, [][]string{
+		{"ifconfig", "utun5", "inet6", "fd42:6d65:7368::1", "prefixlen", "64", "alias"},
+		{"route", "add", "-inet6", "-net", "fd42:6d65:7368::/64", "-interface", "utun5"},
+	}, buildIPv6AddressCommands("utun5", "fd42:6d65:7368::1/64", "darwin"))
+
+	assert.Nil(t, buildIPv6AddressCommands("tun0", "not-a-cidr", "linux"))
+}
+
+func TestBuildExitCommands_IPv6(t *testing.T) {
+	cfg := ExitRouteConfig{
+		InterfaceName: "tun0",
+		MeshCIDR:      "10.42.0.0/16",
+		MeshCIDR6:     "fd42:6d65:7368::/64",
+	}
+	require.NoError(t, cfg.Validate())
+
+	addCmds, removeCmds := buildDefaultRouteCommands(cfg, "linux")
+	require.Len(t, addCmds, 4)
+	require.Len(t, removeCmds, 4)
+	assert.Equal(t, []string{"ip", "-6", "route", "add", "::/1", "dev", "tun0"}, addCmds[2])
+	assert.Equal(t, []string{"ip", "-6", "route", "add", "8000::/1", "dev", "tun0"}, addCmds[3])
+
+	addCmds, removeCmds = buildExitNATCommands(cfg, "linux")
+	assert.Contains(t, addCmds, []string{"sysctl", "-w", "net.ipv6.conf.all.forwarding=1"})
+	assert.Contains(t, addCmds, []string{"ip6tables", "-t", "nat", "-A", "POSTROUTING", "-s", "fd42:6d65:7368::/64", "!", "-d", "fd42:6d65:7368::/64", "-j", "MASQUERADE"})
+	assert.Contains(t, removeCmds, []string{"ip6tables", "-t", "nat", "-D", "POSTROUTING", "-s", "fd42:6d65:7368::/64", "!", "-d", "fd42:6d65:7368::/64", "-j", "MASQUERADE"})
+
+	cfg.MeshCIDR6 = "fd42::/zz"
+	assert.Error(t, cfg.Validate())
+}
@@ -41,20 +41,44 @@ var ErrNotConnected = errors.New("not connected to relay")
 
 // FilterRuleWire is the wire format for a filter rule (matches coord/relay.go).
 type FilterRuleWire struct {
-	Port       uint16 `json:"port"`
-	Protocol   string `json:"protocol"`    // "tcp" or "udp"
-	Action     string `json:"action"`      // "allow" or "deny"
-	SourcePeer string `json:"source_peer"` // Source peer (empty = any peer)
+	Port        uint16 `json:"port"`
+	PortEnd     uint16 `json:"port_end,omitempty"`     // Last port of a range (0 = port only)
+	Protocol    string `json:"protocol"`               // "tcp", "udp", "icmp" or "icmpv6"
+	ICMPType    uint8  `json:"icmp_type,omitempty"`    // ICMP message type (0 = any)
+	Action      string `json:"action"`                 // "allow" or "deny"
+	SourcePeer  string `json:"source_peer"`            // Source peer (empty = any peer)
+	SourceCIDR  string `json:"source_cidr,omitempty"`  // Source address prefix (empty = any)
+	SourceGroup string `json:"source_group,omitempty"` // RBAC group of the source peer
+	DestGroup   string `json:"dest_group,omitempty"`   // RBAC group of the receiving peer
 }
 
 // FilterRuleWithSourceWire is a filter rule with its origin source for display.
 type FilterRuleWithSourceWire struct {
-	Port       uint16 `json:"port"`
-	Protocol   string `json:"protocol"`    // "tcp" or "udp"
-	Action     string `json:"action"`      // "allow" or "deny"
-	SourcePeer string `json:"source_peer"` // Source peer (empty = any peer)
-	Source     string `json:"source"`      // "coordinator", "config", "temporary", or "service"
-	Expires    int64  `json:"expires"`     // Unix timestamp, 0=permanent
+	Port        uint16 `json:"port"`
+	PortEnd     uint16 `json:"port_end,omitempty"`     // Last port of a range (0 = port only)
+	Protocol    string `json:"protocol"`               // "tcp", "udp", "icmp" or "icmpv6"
+	ICMPType    uint8  `json:"icmp_type,omitempty"`    // ICMP message type (0 = any)
+	Action      string `json:"action"`                 // "allow" or "deny"
+	SourcePeer  string `json:"source_peer"`            // Source peer (empty = any peer)
+	SourceCIDR  string `json:"source_cidr,omitempty"`  // Source address prefix (empty = any)
+	SourceGroup string `json:"source_group,omitempty"` // RBAC group of the source peer
+	DestGroup   string `json:"dest_group,omitempty"`   // RBAC group of the receiving peer
+	Source      string `json:"source"`                 // "coordinator", "config", "temporary", or "service"
+	Expires     int64  `json:"expires"`                // Unix timestamp, 0=permanent
+}
+
+// FilterFlowWire is a flow in the packet filter's connection table, for the
+// coordinator's flow queries.
+type FilterFlowWire struct {
+	Protocol     string `json:"protocol"`
+	Src          string `json:"src"`       // Originator address:port (ICMP: echo identifier)
+	Dst          string `json:"dst"`       // Responder address:port
+	State        string `json:"state"`     // e.g. "ESTABLISHED", "REPLIED"
+	Direction    string `json:"direction"` // "in" if a remote peer originated the flow, "out" otherwise
+	Peer         string `json:"peer,omitempty"`
+	PacketsOrig  uint64 `json:"packets_orig"`
+	PacketsReply uint64 `json:"packets_reply"`
+	Expires      int64  `json:"expires"` // Unix timestamp the flow expires at if idle
 }
 
 // relayPacketPool pools relay packet buffers to reduce GC pressure.
@@ -93,6 +117,18 @@ const (
 
 	// Coordinator discovery message types
 	MsgTypeCoordListUpdate byte = 0x36 // Server -> Client: updated coordinator IP list
+
+	// Connection tracking message types
+	MsgTypeFilterFlowsQuery byte = 0x37 // Server -> Client: request the connection table
+	MsgTypeFilterFlowsReply byte = 0x38 // Client -> Server: response with tracked flows
+
+	// Certificate lifecycle message types
+	MsgTypeCertRenew   byte = 0x40 // Client -> Server: certificate signing request
+	MsgTypeCertIssued  byte = 0x41 // Server -> Client: renewed certificate with trust bundle and CRL
+	MsgTypeTrustUpdate byte = 0x42 // Server -> Client: trust bundle and CRL
+
+	// Handshake pre-shared key message types
+	MsgTypePSKUpdate byte = 0x43 // Server -> Client: JSON proto.PSKUpdate
 )
 
 // PersistentRelay maintains a persistent connection to the coordination server
@@ -124,10 +160,14 @@ type PersistentRelay struct {
 	onReconnectError   func(err error)                                // Called when reconnection fails (for re-registration)
 	onFilterRulesSync  func(rules []FilterRuleWire)                   // Called when server syncs coordinator filter rules
 	onFilterRuleAdd    func(rule FilterRuleWire)                      // Called when server pushes a single rule add
-	onFilterRuleRemove func(port uint16, protocol string)             // Called when server removes a rule
+	onFilterRuleRemove func(rule FilterRuleWire)                      // Called when server removes a rule
 	onServicePorts     func(ports []uint16)                           // Called when server announces service ports
 	getFilterRules     func() []FilterRuleWithSourceWire              // Returns all filter rules with their sources
+	getFilterFlows     func() []FilterFlowWire                        // Returns the flows in the connection table
 	onCoordListUpdate  func(coordIPs []string)                        // Called when server sends updated coordinator IP list
+	onCertIssued       func(certPEM, bundlePEM, crlDER []byte)        // Called when server sends a renewed certificate
+	onTrustUpdate      func(bundlePEM, crlDER []byte)                 // Called when server pushes the CA trust bundle and CRL
+	onPSKUpdate        func(update proto.PSKUpdate)                   // Called when server pushes handshake pre-shared keys
 
 	// Reconnection control
 	reconnecting bool // Prevents concurrent autoReconnect goroutines
@@ -634,20 +674,39 @@ func (p *PersistentRelay) handleMessage(data []byte) {
 		}
 
 	case MsgTypeFilterRuleRemove:
-		// Format: [MsgTypeFilterRuleRemove][port:2][protocol_len:1][protocol]
+		// Format: [MsgTypeFilterRuleRemove][port:2][protocol_len:1][protocol][source_peer_len:1][source_peer][rule_len:2][rule JSON]
+		// The source peer and rule JSON are optional; the JSON carries the
+		// selectors of rules with port ranges, CIDRs, groups or ICMP types.
 		if len(data) < 4 {
 			log.Debug().Int("len", len(data)).Msg("persistent relay: filter rule remove too short")
 			return
 		}
-		port := uint16(data[1])<<8 | uint16(data[2])
+		rule := FilterRuleWire{Port: uint16(data[1])<<8 | uint16(data[2])}
 		protoLen := int(data[3])
 		if len(data) < 4+protoLen {
 			log.Debug().Int("len", len(data)).Int("proto_len", protoLen).Msg("persistent relay: filter rule remove truncated")
 			return
 		}
-		protocol := string(data[4 : 4+protoLen])
+		rule.Protocol = string(data[4 : 4+protoLen])
+		offset := 4 + protoLen
+		if len(data) > offset {
+			peerLen := int(data[offset])
+			if len(data) >= offset+1+peerLen {
+				rule.SourcePeer = string(data[offset+1 : offset+1+peerLen])
+			}
+			offset += 1 + peerLen
+		}
+		if len(data) >= offset+2 {
+			ruleLen := int(data[offset])<<8 | int(data[offset+1])
+			if len(data) >= offset+2+ruleLen {
+				if err := json.Unmarshal(data[offset+2:offset+2+ruleLen], &rule); err != nil {
+					log.Debug().Err(err).Msg("persistent relay: failed to unmarshal filter rule remove")
+					return
+				}
+			}
+		}
 
-		log.Debug().Uint16("port", port).Str("protocol", protocol).Msg("received filter rule remove")
+		log.Debug().Uint16("port", rule.Port).Str("protocol", rule.Protocol).Msg("received filter rule remove")
 
 		// Dispatch to callback
 		p.mu.RLock()
@@ -655,7 +714,7 @@ func (p *PersistentRelay) handleMessage(data []byte) {
 		p.mu.RUnlock()
 
 		if handler != nil {
-			handler(port, protocol)
+			handler(rule)
 		}
 
 	case MsgTypeServicePortNotify:
@@ -716,6 +775,70 @@ func (p *PersistentRelay) handleMessage(data []byte) {
 			handler(coordIPs)
 		}
 
+	case MsgTypeCertIssued:
+		// Format: [MsgTypeCertIssued][cert_len:2][cert PEM][bundle_len:2][bundle PEM][CRL DER]
+		if len(data) < 3 {
+			log.Debug().Int("len", len(data)).Msg("persistent relay: cert issued too short")
+			return
+		}
+		certLen := int(binary.BigEndian.Uint16(data[1:3]))
+		if len(data) < 3+certLen {
+			log.Debug().Int("len", len(data)).Msg("persistent relay: cert issued truncated")
+			return
+		}
+		certPEM := data[3 : 3+certLen]
+		bundlePEM, crlDER, ok := parseTrustPayload(data[3+certLen:])
+		if !ok {
+			log.Debug().Int("len", len(data)).Msg("persistent relay: cert issued trust payload truncated")
+			return
+		}
+
+		log.Debug().Msg("received renewed certificate")
+
+		p.mu.RLock()
+		handler := p.onCertIssued
+		p.mu.RUnlock()
+
+		if handler != nil {
+			handler(certPEM, bundlePEM, crlDER)
+		}
+
+	case MsgTypeTrustUpdate:
+		// Format: [MsgTypeTrustUpdate][bundle_len:2][bundle PEM][CRL DER]
+		bundlePEM, crlDER, ok := parseTrustPayload(data[1:])
+		if !ok {
+			log.Debug().Int("len", len(data)).Msg("persistent relay: trust update truncated")
+			return
+		}
+
+		log.Debug().Msg("received trust update")
+
+		p.mu.RLock()
+		handler := p.onTrustUpdate
+		p.mu.RUnlock()
+
+		if handler != nil {
+			handler(bundlePEM, crlDER)
+		}
+
+	case MsgTypePSKUpdate:
+		// Format: [MsgTypePSKUpdate][JSON proto.PSKUpdate]
+		var update proto.PSKUpdate
+		if err := json.Unmarshal(data[1:], &update); err != nil {
+			log.Debug().Err(err).Msg("persistent relay: failed to unmarshal PSK update")
+			return
+		}
+
+		log.Debug().Uint32("epoch", update.Current).Int("epochs", len(update.Keys)).Msg("received PSK update")
+
+		p.mu.RLock()
+		handler := p.onPSKUpdate
+		p.mu.RUnlock()
+
+		if handler != nil {
+			handler(update)
+		}
+
 	case MsgTypeFilterRulesQuery:
 		// Format: [MsgTypeFilterRulesQuery][reqID:4]
 		if len(data) < 5 {
@@ -735,35 +858,70 @@ func (p *PersistentRelay) handleMessage(data []byte) {
 		if handler != nil {
 			rules = handler()
 		}
+		p.sendQueryReply(MsgTypeFilterRulesReply, reqID, rules)
 
-		// Marshal and send response
-		rulesJSON, err := json.Marshal(rules)
-		if err != nil {
-			log.Error().Err(err).Msg("failed to marshal filter rules for query response")
+	case MsgTypeFilterFlowsQuery:
+		// Format: [MsgTypeFilterFlowsQuery][reqID:4]
+		if len(data) < 5 {
+			log.Debug().Int("len", len(data)).Msg("persistent relay: filter flows query too short")
 			return
 		}
+		reqID := uint32(data[1])<<24 | uint32(data[2])<<16 | uint32(data[3])<<8 | uint32(data[4])
 
-		// Build response: [MsgTypeFilterRulesReply][reqID:4][rules JSON]
-		reply := make([]byte, 5+len(rulesJSON))
-		reply[0] = MsgTypeFilterRulesReply
-		reply[1] = byte(reqID >> 24)
-		reply[2] = byte(reqID >> 16)
-		reply[3] = byte(reqID >> 8)
-		reply[4] = byte(reqID)
-		copy(reply[5:], rulesJSON)
+		log.Debug().Uint32("req_id", reqID).Msg("received filter flows query")
 
-		// Send reply
 		p.mu.RLock()
-		writeChan := p.writeChan
+		handler := p.getFilterFlows
 		p.mu.RUnlock()
 
-		if writeChan != nil {
-			select {
-			case writeChan <- writeRequest{data: reply}:
-				log.Debug().Uint32("req_id", reqID).Int("rules", len(rules)).Msg("sent filter rules reply")
-			default:
-				log.Debug().Uint32("req_id", reqID).Msg("failed to send filter rules reply: channel full")
-			}
+		var flows []FilterFlowWire
+		if handler != nil {
+			flows = handler()
+		}
+		p.sendQueryReply(MsgTypeFilterFlowsReply, reqID, flows)
+	}
+}
+
+// parseTrustPayload splits a trust bundle and CRL.
+// Format: [bundle_len:2][bundle PEM][CRL DER]
+func parseTrustPayload(data []byte) (bundlePEM, crlDER []byte, ok bool) {
+	if len(data) < 2 {
+		return nil, nil, false
+	}
+	bundleLen := int(binary.BigEndian.Uint16(data))
+	if len(data) < 2+bundleLen {
+		return nil, nil, false
+	}
+	return data[2 : 2+bundleLen], data[2+bundleLen:], true
+}
+
+// sendQueryReply answers a coordinator query with a JSON payload.
+// Format: [replyType][reqID:4][JSON]
+func (p *PersistentRelay) sendQueryReply(replyType byte, reqID uint32, payload any) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to marshal query reply")
+		return
+	}
+
+	reply := make([]byte, 5+len(body))
+	reply[0] = replyType
+	reply[1] = byte(reqID >> 24)
+	reply[2] = byte(reqID >> 16)
+	reply[3] = byte(reqID >> 8)
+	reply[4] = byte(reqID)
+	copy(reply[5:], body)
+
+	p.mu.RLock()
+	writeChan := p.writeChan
+	p.mu.RUnlock()
+
+	if writeChan != nil {
+		select {
+		case writeChan <- writeRequest{data: reply}:
+			log.Debug().Uint32("req_id", reqID).Int("len", len(body)).Msg("sent query reply")
+		default:
+			log.Debug().Uint32("req_id", reqID).Msg("failed to send query reply: channel full")
 		}
 	}
 }
@@ -915,7 +1073,7 @@ func (p *PersistentRelay) SetFilterRuleAddHandler(handler func(rule FilterRuleWi
 
 // SetFilterRuleRemoveHandler sets a callback for filter rule removals.
 // This is called when the admin panel removes a rule.
-func (p *PersistentRelay) SetFilterRuleRemoveHandler(handler func(port uint16, protocol string)) {
+func (p *PersistentRelay) SetFilterRuleRemoveHandler(handler func(rule FilterRuleWire)) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	p.onFilterRuleRemove = handler
@@ -937,6 +1095,14 @@ func (p *PersistentRelay) SetGetFilterRulesHandler(handler func() []FilterRuleWi
 	p.getFilterRules = handler
 }
 
+// SetGetFilterFlowsHandler sets a callback to retrieve the flows in the
+// packet filter's connection table, for the coordinator's flow queries.
+func (p *PersistentRelay) SetGetFilterFlowsHandler(handler func() []FilterFlowWire) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.getFilterFlows = handler
+}
+
 // SetCoordListUpdateHandler sets a callback for coordinator list updates.
 // This is called when the server notifies of coordinator join/leave events.
 func (p *PersistentRelay) SetCoordListUpdateHandler(handler func(coordIPs []string)) {
@@ -945,6 +1111,56 @@ func (p *PersistentRelay) SetCoordListUpdateHandler(handler func(coordIPs []stri
 	p.onCoordListUpdate = handler
 }
 
+// SetCertIssuedHandler sets a callback for renewed certificates.
+// The certificate is sent in reply to RequestCertRenewal.
+func (p *PersistentRelay) SetCertIssuedHandler(handler func(certPEM, bundlePEM, crlDER []byte)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onCertIssued = handler
+}
+
+// SetTrustUpdateHandler sets a callback for CA trust bundle and CRL updates.
+// The server pushes them on connect, after a CA rollover step and after a
+// revocation.
+func (p *PersistentRelay) SetTrustUpdateHandler(handler func(bundlePEM, crlDER []byte)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onTrustUpdate = handler
+}
+
+// SetPSKUpdateHandler sets a callback for handshake pre-shared key updates.
+// The server pushes them on connect and at every key rotation.
+func (p *PersistentRelay) SetPSKUpdateHandler(handler func(update proto.PSKUpdate)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onPSKUpdate = handler
+}
+
+// RequestCertRenewal asks the coordination server to issue a certificate for
+// the key in the given DER-encoded certificate signing request.
+func (p *PersistentRelay) RequestCertRenewal(csrDER []byte) error {
+	p.mu.RLock()
+	connected := p.connected
+	writeChan := p.writeChan
+	p.mu.RUnlock()
+
+	if !connected || writeChan == nil {
+		return ErrNotConnected
+	}
+
+	// Build message: [MsgTypeCertRenew][CSR DER]
+	msg := make([]byte, 1+len(csrDER))
+	msg[0] = MsgTypeCertRenew
+	copy(msg[1:], csrDER)
+
+	select {
+	case writeChan <- writeRequest{data: msg, pooled: false}:
+		return nil
+	default:
+		return fmt.Errorf("relay write channel full")
+	}
+}
+
 // SendHeartbeat sends a heartbeat with stats to the coordination server.
 func (p *PersistentRelay) SendHeartbeat(stats *proto.PeerStats) error {
 	p.mu.RLock()
@@ -318,6 +318,51 @@ func TestPersistentRelay_SendNotConnected(t *testing.T) {
 	assert.True(t, errors.Is(err, ErrNotConnected))
 }
 
+func TestPersistentRelay_FilterRuleRemove(t *testing.T) {
+	relay := NewPersistentRelay("http://localhost:9999", "peer1")
+	var got []FilterRuleWire
+	relay.SetFilterRuleRemoveHandler(func(rule FilterRuleWire) {
+		got = append(got, rule)
+	})
+
+	// Legacy format: port and protocol only
+	relay.handleMessage([]byte{MsgTypeFilterRuleRemove, 0, 22, 3, 't', 'c', 'p'})
+
+	// With source peer and the full rule
+	ruleJSON, err := json.Marshal(FilterRuleWire{Port: 8000, PortEnd: 8100, Protocol: "tcp", SourcePeer: "bob", SourceGroup: "ops"})
+	require.NoError(t, err)
+	msg := []byte{MsgTypeFilterRuleRemove, 0x1f, 0x40, 3, 't', 'c', 'p', 3, 'b', 'o', 'b'}
+	msg = append(msg, byte(len(ruleJSON)>>8), byte(len(ruleJSON)))
+	msg = append(msg, ruleJSON...)
+	relay.handleMessage(msg)
+
+	assert.Equal(t, []FilterRuleWire{
+		{Port: 22, Protocol: "tcp"},
+		{Port: 8000, PortEnd: 8100, Protocol: "tcp", SourcePeer: "bob", SourceGroup: "ops"},
+	}, got)
+}
+
+func TestPersistentRelay_FilterFlowsQuery(t *testing.T) {
+	relay := NewPersistentRelay("http://localhost:9999", "peer1")
+	flows := []FilterFlowWire{
+		{Protocol: "udp", Src: "10.42.0.1:40000", Dst: "10.42.0.2:53", State: "REPLIED", Direction: "out", Peer: "dns"},
+	}
+	relay.SetGetFilterFlowsHandler(func() []FilterFlowWire { return flows })
+
+	relay.handleMessage([]byte{MsgTypeFilterFlowsQuery, 0, 0, 1, 2})
+
+	select {
+	case req := <-relay.writeChan:
+		require.GreaterOrEqual(t, len(req.data), 5)
+		assert.Equal(t, []byte{MsgTypeFilterFlowsReply, 0, 0, 1, 2}, req.data[:5])
+		var got []FilterFlowWire
+		require.NoError(t, json.Unmarshal(req.data[5:], &got))
+		assert.Equal(t, flows, got)
+	case <-time.After(time.Second):
+		t.Fatal("no reply sent")
+	}
+}
+
 func TestPeerTunnel_ReadWrite(t *testing.T) {
 	server := newMockRelayServer(t)
 	defer server.Close()
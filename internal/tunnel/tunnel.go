@@ -65,6 +65,12 @@ type HealthChecker interface {
 	IsHealthy() bool
 }
 
+// MTUReporter is an optional interface for connections that know the largest
+// write they send unfragmented, such as UDP sessions after path MTU discovery.
+type MTUReporter interface {
+	MaxWriteSize() int
+}
+
 // NewTunnelFromTransport creates a tunnel from a transport connection.
 func NewTunnelFromTransport(conn TransportConn) *Tunnel {
 	return &Tunnel{
@@ -322,6 +328,15 @@ func (t *Tunnel) IsHealthy() bool {
 	// nolint:revive // TunnelManager name kept for clarity despite stuttering
 }
 
+// MaxWriteSize returns the largest write the underlying connection sends
+// unfragmented, or 0 if unknown.
+func (t *Tunnel) MaxWriteSize() int {
+	if r, ok := t.transportConn.(MTUReporter); ok {
+		return r.MaxWriteSize()
+	}
+	return 0
+}
+
 // TunnelManager manages multiple tunnels to peers.
 type TunnelManager struct {
 	tunnels map[string]TunnelConnection
@@ -445,3 +460,12 @@ func (a *ConnectionAdapter) Close() error {
 func (a *ConnectionAdapter) PeerName() string {
 	return a.peerName
 }
+
+// MaxWriteSize returns the largest write the connection sends unfragmented,
+// or 0 if unknown.
+func (a *ConnectionAdapter) MaxWriteSize() int {
+	if r, ok := a.conn.(MTUReporter); ok {
+		return r.MaxWriteSize()
+	}
+	return 0
+}
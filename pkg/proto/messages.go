@@ -6,6 +6,8 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -45,23 +47,35 @@ func (g *GeoLocation) IsSet() bool {
 
 // Peer represents a node in the mesh network.
 type Peer struct {
-	Name              string       `json:"name"`
-	PublicKey         string       `json:"public_key"`                    // SSH public key (base64 encoded wire format)
-	PublicIPs         []string     `json:"public_ips"`                    // Externally reachable IPs
-	PrivateIPs        []string     `json:"private_ips"`                   // Internal network IPs
-	SSHPort           int          `json:"ssh_port"`                      // SSH server port
-	UDPPort           int          `json:"udp_port,omitempty"`            // UDP transport port
-	MeshIP            string       `json:"mesh_ip"`                       // Assigned mesh network IP (10.42.x.x)
-	LastSeen          time.Time    `json:"last_seen"`                     // Last heartbeat time
-	Connectable       bool         `json:"connectable"`                   // Can accept incoming connections
-	BehindNAT         bool         `json:"behind_nat"`                    // Public IP was fetched externally (behind NAT)
-	ExternalEndpoint  string       `json:"external_endpoint,omitempty"`   // STUN-discovered external address for UDP
-	Version           string       `json:"version,omitempty"`             // Application version
-	PCPMapped         bool         `json:"pcp_mapped,omitempty"`          // Whether peer has PCP/NAT-PMP port mapping
-	Location          *GeoLocation `json:"location,omitempty"`            // Geographic location
-	AllowsExitTraffic bool         `json:"allows_exit_traffic,omitempty"` // Can act as exit node for other peers
-	ExitPeer          string       `json:"exit_node,omitempty"`           // Name of peer used as exit node
-	IsCoordinator     bool         `json:"is_coordinator,omitempty"`      // True if peer is running coordinator services
+	Name              string              `json:"name"`
+	PublicKey         string              `json:"public_key"`                    // SSH public key (base64 encoded wire format)
+	PublicIPs         []string            `json:"public_ips"`                    // Externally reachable IPs
+	PrivateIPs        []string            `json:"private_ips"`                   // Internal network IPs
+	SSHPort           int                 `json:"ssh_port"`                      // SSH server port
+	UDPPort           int                 `json:"udp_port,omitempty"`            // UDP transport port
+	QUICPort          int                 `json:"quic_port,omitempty"`           // QUIC transport port (0 if disabled)
+	TLSPort           int                 `json:"tls_port,omitempty"`            // TLS transport port (0 if disabled)
+	TLSServerName     string              `json:"tls_server_name,omitempty"`     // Web site the TLS transport port serves
+	MeshIP            string              `json:"mesh_ip"`                       // Assigned mesh network IP (10.42.x.x)
+	MeshIPv6          string              `json:"mesh_ipv6,omitempty"`           // Assigned mesh network IPv6 (fd42:6d65:7368::/64)
+	LastSeen          time.Time           `json:"last_seen"`                     // Last heartbeat time
+	Connectable       bool                `json:"connectable"`                   // Can accept incoming connections
+	BehindNAT         bool                `json:"behind_nat"`                    // Public IP was fetched externally (behind NAT)
+	ExternalEndpoint  string              `json:"external_endpoint,omitempty"`   // STUN-discovered external address for UDP
+	Version           string              `json:"version,omitempty"`             // Application version
+	PCPMapped         bool                `json:"pcp_mapped,omitempty"`          // Whether peer has PCP/NAT-PMP port mapping
+	Location          *GeoLocation        `json:"location,omitempty"`            // Geographic location
+	AllowsExitTraffic bool                `json:"allows_exit_traffic,omitempty"` // Can act as exit node for other peers
+	ExitPeer          string              `json:"exit_node,omitempty"`           // Name of peer used as exit node
+	ExitPeers         []string            `json:"exit_peers,omitempty"`          // Configured exit peers, in order of preference
+	ExitRoutes        map[string]string   `json:"exit_routes,omitempty"`         // Exit policy name -> exit peer in use
+	RelayTo           map[string]int64    `json:"relay_to,omitempty"`            // Relay peer: peers it relays to -> RTT in microseconds (0 if unknown)
+	IsCoordinator     bool                `json:"is_coordinator,omitempty"`      // True if peer is running coordinator services
+	Routes            []string            `json:"routes,omitempty"`              // Advertised subnet routes approved by an admin
+	Groups            []string            `json:"groups,omitempty"`              // RBAC groups, for group-based filter rules
+	Tags              []string            `json:"tags,omitempty"`                // Tags assigned by an admin or the peer's join key
+	ExitAllowTags     []string            `json:"exit_allow_tags,omitempty"`     // Exit peer: tags of the peers it forwards exit traffic for (empty = all)
+	ClientTags        map[string][]string `json:"client_tags,omitempty"`         // WireGuard concentrator: client mesh IP -> tags
 }
 
 // RegisterRequest is sent by a peer to join the mesh.
@@ -78,6 +92,8 @@ type RegisterRequest struct {
 	AllowsExitTraffic bool         `json:"allows_exit_traffic,omitempty"` // Allow this node to act as exit node
 	ExitPeer          string       `json:"exit_node,omitempty"`           // Name of peer to use as exit node
 	Aliases           []string     `json:"aliases,omitempty"`             // Custom DNS aliases for this peer
+	Services          []Service    `json:"services,omitempty"`            // Services advertised as DNS SRV records
+	Routes            []string     `json:"routes,omitempty"`              // Subnet routes advertised into the mesh (CIDR)
 	IsCoordinator     bool         `json:"is_coordinator,omitempty"`      // True if peer is running coordinator services
 	HasMonitoring     bool         `json:"has_monitoring,omitempty"`      // True if coordinator has monitoring (Prometheus/Grafana) configured
 }
@@ -86,6 +102,8 @@ type RegisterRequest struct {
 type RegisterResponse struct {
 	MeshIP        string   `json:"mesh_ip"`                  // Assigned mesh IP address
 	MeshCIDR      string   `json:"mesh_cidr"`                // Full mesh CIDR for routing
+	MeshIPv6      string   `json:"mesh_ipv6,omitempty"`      // Assigned mesh IPv6 address
+	MeshCIDRv6    string   `json:"mesh_cidr_v6,omitempty"`   // Full mesh IPv6 prefix for routing
 	Domain        string   `json:"domain"`                   // Domain suffix (e.g., ".tunnelmesh")
 	Token         string   `json:"token"`                    // JWT token for relay authentication
 	TLSCert       string   `json:"tls_cert,omitempty"`       // PEM-encoded TLS certificate signed by mesh CA
@@ -110,7 +128,26 @@ type PeerStats struct {
 	Errors          uint64            `json:"errors"`
 	ActiveTunnels   int               `json:"active_tunnels"`
 	Location        *GeoLocation      `json:"location,omitempty"`    // Geographic location (sent with every heartbeat)
-	Connections     map[string]string `json:"connections,omitempty"` // Active connections: peerName -> transport type ("ssh", "udp", "relay")
+	Connections     map[string]string `json:"connections,omitempty"` // Active connections: peerName -> transport type ("ssh", "udp", "quic", "tls", "relay")
+	PathMTUs        map[string]int    `json:"path_mtus,omitempty"`   // Discovered path MTU of UDP connections: peerName -> MTU
+
+	// Exit peers, reported when exit peers or policies are configured
+	ExitPeer   string            `json:"exit_peer,omitempty"`   // Exit peer in use, empty if none is healthy
+	ExitPeers  []string          `json:"exit_peers,omitempty"`  // Configured exit peers, in order of preference
+	ExitRoutes map[string]string `json:"exit_routes,omitempty"` // Exit policy name -> exit peer in use
+
+	// Peers this peer has tunnels to, reported when it allows relaying
+	RelayTo map[string]int64 `json:"relay_to,omitempty"` // Peer name -> RTT in microseconds (0 if unknown)
+
+	// Tags of the peers an exit peer forwards exit traffic for (empty = all)
+	ExitAllowTags []string `json:"exit_allow_tags,omitempty"`
+	// Tags of the WireGuard clients a concentrator serves, by client mesh IP
+	ClientTags map[string][]string `json:"client_tags,omitempty"`
+
+	// Ports of the optional transports, reported when they are listening
+	QUICPort      int    `json:"quic_port,omitempty"`
+	TLSPort       int    `json:"tls_port,omitempty"`
+	TLSServerName string `json:"tls_server_name,omitempty"`
 
 	// Latency metrics
 	HeartbeatSentAt  int64            `json:"heartbeat_sent_at,omitempty"`  // Unix nano timestamp when heartbeat was sent
@@ -136,14 +173,343 @@ type PeerListResponse struct {
 }
 
 // DNSRecord represents a hostname to IP mapping.
+// Records for peer names (not aliases) also carry the peer's metadata,
+// served as TXT records, and its advertised services, served as SRV records.
+// Peers and WireGuard clients carry their tags, resolvable as <tag>.tag names.
 type DNSRecord struct {
-	Hostname string `json:"hostname"`
-	MeshIP   string `json:"mesh_ip"`
+	Hostname string    `json:"hostname"`
+	MeshIP   string    `json:"mesh_ip"`
+	MeshIPv6 string    `json:"mesh_ipv6,omitempty"`
+	PeerID   string    `json:"peer_id,omitempty"`
+	Version  string    `json:"version,omitempty"`
+	Services []Service `json:"services,omitempty"`
+	Tags     []string  `json:"tags,omitempty"`
+}
+
+// MaxServices is the maximum number of services a peer can advertise.
+const MaxServices = 32
+
+// Service is a network service advertised by a peer, resolvable as
+// _name._protocol.peer.tunnelmesh SRV records.
+type Service struct {
+	Name     string `json:"name"`     // Service name without the leading underscore, e.g. "http"
+	Protocol string `json:"protocol"` // "tcp" or "udp"
+	Port     int    `json:"port"`
+}
+
+// Validate checks the service name (an RFC 6335 service name), protocol and port.
+func (s *Service) Validate() error {
+	if len(s.Name) == 0 || len(s.Name) > 15 {
+		return fmt.Errorf("service name must be 1-15 characters, got %q", s.Name)
+	}
+	for i, c := range s.Name {
+		isAlnum := (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9')
+		if !isAlnum && (c != '-' || i == 0 || i == len(s.Name)-1) {
+			return fmt.Errorf("service name %q must be lowercase letters, digits and inner hyphens", s.Name)
+		}
+	}
+	if s.Protocol != "tcp" && s.Protocol != "udp" {
+		return fmt.Errorf("service %q protocol must be tcp or udp, got %q", s.Name, s.Protocol)
+	}
+	if s.Port < 1 || s.Port > 65535 {
+		return fmt.Errorf("service %q port must be between 1 and 65535, got %d", s.Name, s.Port)
+	}
+	return nil
+}
+
+// MaxRoutes is the maximum number of subnet routes a peer can advertise.
+const MaxRoutes = 32
+
+// ParseRoute parses a subnet route advertised into the mesh: an IPv4 prefix in
+// CIDR notation, such as "192.168.10.0/24". Host bits are masked off. The
+// default route is reserved for exit peers, and loopback and multicast
+// prefixes are rejected.
+func ParseRoute(route string) (*net.IPNet, error) {
+	_, ipNet, err := net.ParseCIDR(strings.TrimSpace(route))
+	if err != nil {
+		return nil, fmt.Errorf("invalid route %q: must be a prefix in CIDR notation", route)
+	}
+	if ipNet.IP.To4() == nil {
+		return nil, fmt.Errorf("invalid route %q: only IPv4 prefixes are supported", route)
+	}
+	if ones, _ := ipNet.Mask.Size(); ones == 0 {
+		return nil, fmt.Errorf("invalid route %q: use an exit peer for the default route", route)
+	}
+	if ipNet.IP.IsLoopback() || ipNet.IP.IsMulticast() || ipNet.IP.IsUnspecified() {
+		return nil, fmt.Errorf("invalid route %q: loopback, multicast and unspecified prefixes cannot be routed", route)
+	}
+	return ipNet, nil
+}
+
+// SubnetRoute is a subnet route as listed by the coordinator admin API.
+// Routes are only served to other peers once advertised and approved.
+type SubnetRoute struct {
+	Peer       string `json:"peer"`
+	Prefix     string `json:"prefix"`
+	Advertised bool   `json:"advertised"` // Currently advertised by the peer
+	Approved   bool   `json:"approved"`   // Approved by an admin
+}
+
+// JoinKey is a pre-auth key issued by the coordinator. Peers use it in place
+// of the mesh token to join: a single-use key joins one peer, a reusable key
+// any number until it expires. Peers that joined with a key keep using it,
+// also once it expired or was revoked, unless the peer itself is revoked.
+type JoinKey struct {
+	ID          string    `json:"id"`
+	Key         string    `json:"key,omitempty"` // Only returned when the key is created
+	Description string    `json:"description,omitempty"`
+	Reusable    bool      `json:"reusable"`
+	Name        string    `json:"name,omitempty"`   // Name given to peers joining with the key
+	Groups      []string  `json:"groups,omitempty"` // Groups peers joining with the key are added to
+	Tags        []string  `json:"tags,omitempty"`   // Tags given to peers joining with the key
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at,omitzero"` // Zero if the key does not expire
+	Revoked     bool      `json:"revoked,omitempty"`
+	PeerIDs     []string  `json:"peer_ids,omitempty"` // Peers that joined with the key
+}
+
+// CanJoin reports whether a new peer can join with the key.
+func (k *JoinKey) CanJoin(now time.Time) bool {
+	if k.Revoked || k.Expired(now) {
+		return false
+	}
+	return k.Reusable || len(k.PeerIDs) == 0
+}
+
+// Expired reports whether the key has passed its expiry time.
+func (k *JoinKey) Expired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && !now.Before(k.ExpiresAt)
+}
+
+// JoinKeyRequest creates a join key through the coordinator admin API.
+type JoinKeyRequest struct {
+	Description string   `json:"description,omitempty"`
+	Reusable    bool     `json:"reusable,omitempty"`
+	TTL         int      `json:"ttl,omitempty"` // Seconds until the key expires, 0 for never
+	Name        string   `json:"name,omitempty"`
+	Groups      []string `json:"groups,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// RevokedPeer is a peer an admin cut off from the mesh. Its registrations,
+// relay tokens and mesh TLS certificate are rejected.
+type RevokedPeer struct {
+	PeerID    string    `json:"peer_id"`
+	Name      string    `json:"name"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+// IssuedCert is a mesh TLS certificate the coordinator's CA issued to a
+// peer, tracked until it expires so it can be revoked.
+type IssuedCert struct {
+	Serial    string    `json:"serial"` // Hex-encoded serial number
+	Peer      string    `json:"peer"`
+	NotAfter  time.Time `json:"not_after"`
+	RevokedAt time.Time `json:"revoked_at,omitzero"` // Zero unless revoked
+}
+
+// CA root states during a rollover.
+const (
+	CARootSigning = "signing" // Signs new certificates
+	CARootStaged  = "staged"  // Trusted, signs once promoted
+	CARootRetired = "retired" // Trusted until TrustedUntil, no longer signs
+)
+
+// CARoot is a root certificate of the mesh CA.
+type CARoot struct {
+	Name         string    `json:"name"`
+	Fingerprint  string    `json:"fingerprint"` // SHA-256 of the certificate, hex-encoded
+	State        string    `json:"state"`
+	NotAfter     time.Time `json:"not_after"`
+	TrustedUntil time.Time `json:"trusted_until,omitzero"` // Retired roots only
+}
+
+// CAStatus describes the mesh CA: its trusted roots and the certificates it
+// issued that have not expired yet.
+type CAStatus struct {
+	Roots        []CARoot     `json:"roots"`
+	CertLifetime string       `json:"cert_lifetime"`
+	Certs        []IssuedCert `json:"certs"`
+}
+
+// ValidateTag checks a peer tag: "tag:" followed by lowercase letters, digits
+// and hyphens, such as tag:prod.
+func ValidateTag(tag string) error {
+	name, ok := strings.CutPrefix(tag, "tag:")
+	if !ok || name == "" || len(name) > 63 {
+		return fmt.Errorf("invalid tag %q: must be tag:<name>", tag)
+	}
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			return fmt.Errorf("invalid tag %q: names may only contain lowercase letters, digits and hyphens", tag)
+		}
+	}
+	return nil
+}
+
+// NormalizeTags validates tags and returns them sorted, without duplicates.
+func NormalizeTags(tags []string) ([]string, error) {
+	for _, tag := range tags {
+		if err := ValidateTag(tag); err != nil {
+			return nil, err
+		}
+	}
+	tags = slices.Clone(tags)
+	slices.Sort(tags)
+	return slices.Compact(tags), nil
+}
+
+// HasAnyTag reports whether tags contains any of wanted.
+func HasAnyTag(tags, wanted []string) bool {
+	for _, tag := range wanted {
+		if slices.Contains(tags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// PeerTags are the tags an admin assigned to a peer.
+type PeerTags struct {
+	Peer string   `json:"peer"`
+	Tags []string `json:"tags"`
+}
+
+// PSKUpdate carries the pre-shared keys mixed into UDP handshakes, pushed by
+// the coordinator over the relay. Keys holds the epochs around Current so
+// handshakes keep working while peers rotate; no keys disables PSKs.
+type PSKUpdate struct {
+	Current uint32   `json:"current"` // Epoch to initiate handshakes with
+	Keys    []PSKSet `json:"keys,omitempty"`
+}
+
+// PSKSet holds the pre-shared keys of one rotation epoch.
+type PSKSet struct {
+	Epoch uint32            `json:"epoch"`
+	Mesh  []byte            `json:"mesh"`            // Used with peers without a pair key
+	Pairs map[string][]byte `json:"pairs,omitempty"` // Peer name -> key shared with that peer only
 }
 
 // DNSUpdateNotification is sent when DNS records change.
 type DNSUpdateNotification struct {
-	Records []DNSRecord `json:"records"`
+	Records []DNSRecord     `json:"records"`
+	Zone    []DNSZoneRecord `json:"zone,omitempty"` // Custom records managed through the coordinator
+}
+
+// Custom DNS record limits.
+const (
+	MaxZoneRecordValues = 32
+	MaxZoneRecordTTL    = 86400
+)
+
+// DNSZoneRecord is a custom record set in the mesh zone, e.g. grafana.ops A
+// pointing at a container on some peer. Several values of an A or AAAA set
+// are served round-robin.
+type DNSZoneRecord struct {
+	Name   string   `json:"name"`          // Relative to the mesh domain, e.g. "grafana.ops"
+	Type   string   `json:"type"`          // "A", "AAAA", "CNAME", "SRV" or "TXT"
+	Values []string `json:"values"`        // One per record, see Validate
+	TTL    uint32   `json:"ttl,omitempty"` // Seconds; 0 uses the resolver's TTL
+}
+
+// Validate checks the record name, TTL and values. Values are IP addresses
+// for A and AAAA, a single target name for CNAME, "priority weight port
+// target" for SRV and free text (at most 255 bytes) for TXT. Target names
+// ending in "." are absolute; others are mesh names.
+func (z *DNSZoneRecord) Validate() error {
+	if err := validateZoneName(z.Name, false); err != nil {
+		return err
+	}
+	if z.TTL > MaxZoneRecordTTL {
+		return fmt.Errorf("record %s: TTL must be at most %d, got %d", z.Name, MaxZoneRecordTTL, z.TTL)
+	}
+	if len(z.Values) == 0 {
+		return fmt.Errorf("record %s %s: at least one value is required", z.Name, z.Type)
+	}
+	if len(z.Values) > MaxZoneRecordValues {
+		return fmt.Errorf("record %s %s: too many values (max %d)", z.Name, z.Type, MaxZoneRecordValues)
+	}
+
+	seen := make(map[string]bool, len(z.Values))
+	for _, v := range z.Values {
+		if seen[v] {
+			return fmt.Errorf("record %s %s: duplicate value %q", z.Name, z.Type, v)
+		}
+		seen[v] = true
+
+		var err error
+		switch z.Type {
+		case "A":
+			if ip := net.ParseIP(v); ip == nil || ip.To4() == nil {
+				err = fmt.Errorf("%q is not an IPv4 address", v)
+			}
+		case "AAAA":
+			if ip := net.ParseIP(v); ip == nil || ip.To4() != nil {
+				err = fmt.Errorf("%q is not an IPv6 address", v)
+			}
+		case "CNAME":
+			if len(z.Values) > 1 {
+				err = fmt.Errorf("a CNAME has exactly one target")
+			} else {
+				err = validateZoneName(strings.TrimSuffix(v, "."), true)
+			}
+		case "SRV":
+			_, _, _, _, err = ParseSRVValue(v)
+		case "TXT":
+			if len(v) > 255 {
+				err = fmt.Errorf("TXT value longer than 255 bytes")
+			}
+		default:
+			return fmt.Errorf("record %s: unsupported type %q (use A, AAAA, CNAME, SRV or TXT)", z.Name, z.Type)
+		}
+		if err != nil {
+			return fmt.Errorf("record %s %s: %w", z.Name, z.Type, err)
+		}
+	}
+	return nil
+}
+
+// ParseSRVValue parses an SRV record value "priority weight port target".
+func ParseSRVValue(v string) (priority, weight, port uint16, target string, err error) {
+	fields := strings.Fields(v)
+	if len(fields) != 4 {
+		return 0, 0, 0, "", fmt.Errorf("SRV value %q must be \"priority weight port target\"", v)
+	}
+	var nums [3]uint16
+	for i := range nums {
+		n, convErr := strconv.ParseUint(fields[i], 10, 16)
+		if convErr != nil {
+			return 0, 0, 0, "", fmt.Errorf("SRV value %q: invalid number %q", v, fields[i])
+		}
+		nums[i] = uint16(n)
+	}
+	if nums[2] == 0 {
+		return 0, 0, 0, "", fmt.Errorf("SRV value %q: port must be between 1 and 65535", v)
+	}
+	if err := validateZoneName(strings.TrimSuffix(fields[3], "."), true); err != nil {
+		return 0, 0, 0, "", fmt.Errorf("SRV value %q: %w", v, err)
+	}
+	return nums[0], nums[1], nums[2], fields[3], nil
+}
+
+// validateZoneName checks a lowercase DNS name. Underscores are allowed so
+// records such as _http._tcp.web can be defined; targets may be mixed case.
+func validateZoneName(name string, target bool) error {
+	if name == "" || len(name) > 253 {
+		return fmt.Errorf("name %q must be 1-253 characters", name)
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 {
+			return fmt.Errorf("name %q has an empty or over-long label", name)
+		}
+		for i, c := range label {
+			isAlnum := (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || (target && c >= 'A' && c <= 'Z')
+			if !isAlnum && c != '_' && (c != '-' || i == 0 || i == len(label)-1) {
+				return fmt.Errorf("name %q must be lowercase letters, digits, underscores and inner hyphens", name)
+			}
+		}
+	}
+	return nil
 }
 
 // ConnectionHint suggests how to connect to a peer.
@@ -592,3 +592,110 @@ func TestPeerStats_LatencyWithConnections(t *testing.T) {
 	assert.Equal(t, int64(20), decoded.PeerLatencies["peer-a"])
 	assert.Equal(t, "udp", decoded.Connections["peer-a"])
 }
+
+func TestDNSZoneRecord_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rec     DNSZoneRecord
+		wantErr bool
+	}{
+		{"A round-robin", DNSZoneRecord{Name: "web", Type: "A", Values: []string{"10.42.0.1", "10.42.0.2"}}, false},
+		{"AAAA", DNSZoneRecord{Name: "web", Type: "AAAA", Values: []string{"fd00::1"}}, false},
+		{"CNAME to mesh name", DNSZoneRecord{Name: "grafana.ops", Type: "CNAME", Values: []string{"monitor"}}, false},
+		{"CNAME to absolute name", DNSZoneRecord{Name: "docs", Type: "CNAME", Values: []string{"Example.com."}}, false},
+		{"SRV", DNSZoneRecord{Name: "_http._tcp.web", Type: "SRV", Values: []string{"10 5 8080 web"}}, false},
+		{"TXT", DNSZoneRecord{Name: "web", Type: "TXT", Values: []string{"owner=ops team"}, TTL: 300}, false},
+		{"uppercase name", DNSZoneRecord{Name: "Web", Type: "A", Values: []string{"10.42.0.1"}}, true},
+		{"empty label", DNSZoneRecord{Name: "a..b", Type: "A", Values: []string{"10.42.0.1"}}, true},
+		{"unsupported type", DNSZoneRecord{Name: "web", Type: "MX", Values: []string{"10 mail"}}, true},
+		{"no values", DNSZoneRecord{Name: "web", Type: "A"}, true},
+		{"duplicate value", DNSZoneRecord{Name: "web", Type: "A", Values: []string{"10.42.0.1", "10.42.0.1"}}, true},
+		{"IPv6 in A", DNSZoneRecord{Name: "web", Type: "A", Values: []string{"fd00::1"}}, true},
+		{"IPv4 in AAAA", DNSZoneRecord{Name: "web", Type: "AAAA", Values: []string{"10.42.0.1"}}, true},
+		{"two CNAME targets", DNSZoneRecord{Name: "web", Type: "CNAME", Values: []string{"a", "b"}}, true},
+		{"SRV missing target", DNSZoneRecord{Name: "_http._tcp.web", Type: "SRV", Values: []string{"10 5 8080"}}, true},
+		{"SRV port zero", DNSZoneRecord{Name: "_http._tcp.web", Type: "SRV", Values: []string{"10 5 0 web"}}, true},
+		{"TTL too long", DNSZoneRecord{Name: "web", Type: "A", Values: []string{"10.42.0.1"}, TTL: MaxZoneRecordTTL + 1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rec.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestParseRoute(t *testing.T) {
+	tests := []struct {
+		route   string
+		want    string
+		wantErr bool
+	}{
+		{"192.168.10.0/24", "192.168.10.0/24", false},
+		{" 172.17.0.1/16 ", "172.17.0.0/16", false},
+		{"10.0.0.5/32", "10.0.0.5/32", false},
+		{"192.168.10.0", "", true},
+		{"0.0.0.0/0", "", true},
+		{"0.0.0.0/8", "", true},
+		{"127.0.0.0/8", "", true},
+		{"224.0.0.0/4", "", true},
+		{"fd00::/64", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.route, func(t *testing.T) {
+			ipNet, err := ParseRoute(tt.route)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ipNet.String())
+		})
+	}
+}
+
+func TestJoinKey_CanJoin(t *testing.T) {
+	now := time.Now()
+
+	key := JoinKey{ExpiresAt: now.Add(time.Hour)}
+	assert.True(t, key.CanJoin(now))
+	assert.False(t, key.CanJoin(now.Add(time.Hour)), "expired")
+
+	key.PeerIDs = []string{"abc"}
+	assert.False(t, key.CanJoin(now), "single-use key already used")
+	key.Reusable = true
+	assert.True(t, key.CanJoin(now))
+
+	key.Revoked = true
+	assert.False(t, key.CanJoin(now), "revoked")
+
+	assert.True(t, (&JoinKey{}).CanJoin(now), "no expiry")
+}
+
+func TestValidateTag(t *testing.T) {
+	for _, tag := range []string{"tag:prod", "tag:ci-runner", "tag:k8s"} {
+		assert.NoError(t, ValidateTag(tag), tag)
+	}
+	for _, tag := range []string{"prod", "tag:", "tag:Prod", "tag:a_b", "group:ops"} {
+		assert.Error(t, ValidateTag(tag), tag)
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	tags, err := NormalizeTags([]string{"tag:prod", "tag:ci", "tag:prod"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tag:ci", "tag:prod"}, tags)
+
+	_, err = NormalizeTags([]string{"tag:ci", "prod"})
+	assert.Error(t, err)
+
+	assert.True(t, HasAnyTag(tags, []string{"tag:dev", "tag:ci"}))
+	assert.False(t, HasAnyTag(tags, []string{"tag:dev"}))
+	assert.False(t, HasAnyTag(nil, nil))
+}